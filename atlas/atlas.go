@@ -74,6 +74,13 @@ type Atlas struct {
 	maps map[string]Map
 	// holds a reference to the cache backend
 	cacher cache.Interface
+	// keyVersioning indicates if a map's Version should be part of its cache keys
+	keyVersioning bool
+	// sweepUnversioned indicates if the sweeps of old generations remove the tiles
+	// cached before key versioning was turned on
+	sweepUnversioned bool
+	// emptyTiles is how seeded tiles without features are cached
+	emptyTiles EmptyTileMode
 
 	// holds a reference to the observer backend
 	observer observability.Interface
@@ -85,6 +92,18 @@ type Atlas struct {
 	publishBuildInfo bool
 }
 
+// EmptyTileMode is how seeding caches the tiles which have no features
+type EmptyTileMode uint8
+
+const (
+	// EmptyTileStore caches empty tiles like any other tile
+	EmptyTileStore EmptyTileMode = iota
+	// EmptyTileSkip doesn't cache empty tiles
+	EmptyTileSkip
+	// EmptyTileSentinel caches the cache.EmptyTile sentinel for empty tiles
+	EmptyTileSentinel
+)
+
 // AllMaps returns a slice of all maps contained in the Atlas so far.
 func (a *Atlas) AllMaps() []Map {
 
@@ -114,15 +133,19 @@ func (a *Atlas) AllMaps() []Map {
 // SeedMapTile will generate a tile and persist it to the
 // configured cache backend
 func (a *Atlas) SeedMapTile(ctx context.Context, m Map, z, x, y uint) error {
+	return a.SeedMapTileVariant(ctx, m, z, x, y, TileVariant{})
+}
+
+// SeedMapTileVariant will generate a variant of a tile, for a single layer or
+// with query parameters, and persist it to the configured cache backend under
+// the key the tile cache of the server uses for the same request.
+// Nothing is seeded when the map has no layer named v.LayerName.
+func (a *Atlas) SeedMapTileVariant(ctx context.Context, m Map, z, x, y uint, v TileVariant) error {
 
 	if a == nil {
 		// Use the default Atlas if a, is nil. This way the empty value is
 		// still useful.
-		return defaultAtlas.SeedMapTile(ctx, m, z, x, y)
-	}
-
-	if len(m.Params) > 0 {
-		return nil
+		return defaultAtlas.SeedMapTileVariant(ctx, m, z, x, y, v)
 	}
 
 	ctx = context.WithValue(ctx, observability.ObserveVarMapName, m.Name)
@@ -131,50 +154,66 @@ func (a *Atlas) SeedMapTile(ctx context.Context, m Map, z, x, y uint) error {
 		return ErrMissingCache
 	}
 
+	params, err := m.ParamValues(v.Params)
+	if err != nil {
+		return err
+	}
+
+	if v.LayerName != "" {
+		if m = m.FilterLayersByName(v.LayerName); len(m.Layers) == 0 {
+			return nil
+		}
+	}
+
 	tile := slippy.Tile{Z: slippy.Zoom(z), X: x, Y: y}
 
-	// encode the tile
-	b, err := m.Encode(ctx, tile, nil)
+	// encode the tile. empty tiles are only told apart when they are not cached as is
+	mode := a.EmptyTiles()
+	var (
+		b     []byte
+		empty bool
+	)
+	if mode == EmptyTileStore {
+		b, err = m.Encode(ctx, tile, params)
+	} else {
+		b, empty, err = m.EncodeCheckEmpty(ctx, tile, params)
+	}
 	if err != nil {
 		return err
 	}
 
-	// cache key
-	key := cache.Key{
-		MapName: m.Name,
-		Z:       z,
-		X:       x,
-		Y:       y,
+	if empty {
+		switch mode {
+		case EmptyTileSkip:
+			return nil
+		case EmptyTileSentinel:
+			b = cache.EmptyTile
+		}
 	}
 
-	return a.cacher.Set(ctx, &key, b)
+	return a.cacher.Set(ctx, a.CacheKey(m, tile, v), b)
 }
 
 // PurgeMapTile will purge a map tile from the configured cache backend
 func (a *Atlas) PurgeMapTile(ctx context.Context, m Map, tile *tegola.Tile) error {
+	return a.PurgeMapTileVariant(ctx, m, tile, TileVariant{})
+}
+
+// PurgeMapTileVariant will purge a variant of a map tile, for a single layer or
+// with query parameters, from the configured cache backend
+func (a *Atlas) PurgeMapTileVariant(ctx context.Context, m Map, tile *tegola.Tile, v TileVariant) error {
 	if a == nil {
 		// Use the default Atlas if a, is nil. This way the empty value is
 		// still useful.
-		return defaultAtlas.PurgeMapTile(ctx, m, tile)
-	}
-
-	if len(m.Params) > 0 {
-		return nil
+		return defaultAtlas.PurgeMapTileVariant(ctx, m, tile, v)
 	}
 
 	if a.cacher == nil {
 		return ErrMissingCache
 	}
 
-	// cache key
-	key := cache.Key{
-		MapName: m.Name,
-		Z:       tile.Z,
-		X:       tile.X,
-		Y:       tile.Y,
-	}
-
-	return a.cacher.Purge(ctx, &key)
+	key := a.CacheKey(m, slippy.Tile{Z: slippy.Zoom(tile.Z), X: tile.X, Y: tile.Y}, v)
+	return a.cacher.Purge(ctx, key)
 }
 
 // Map looks up a Map by name and returns a copy of the Map
@@ -218,7 +257,169 @@ func (a *Atlas) AddMap(m Map) {
 		a.maps = map[string]Map{}
 	}
 
+	old, replaced := a.maps[m.Name]
 	a.maps[m.Name] = m
+
+	// a changed map config starts a fresh keyspace, clean up the old one
+	if replaced && a.keyVersioning && old.Version != m.Version {
+		go a.sweepGenerations(m.Name, m.Version)
+	}
+
+	a.describeTileset(m)
+}
+
+// describeTileset hands the description of m to the cache backend if it stores tileset metadata.
+// The caller is expected to hold the lock.
+func (a *Atlas) describeTileset(m Map) {
+	if a.cacher == nil {
+		return
+	}
+
+	describer, ok := cache.Original(a.cacher).(cache.TilesetDescriber)
+	if !ok {
+		return
+	}
+
+	version := ""
+	if a.keyVersioning {
+		version = m.Version
+	}
+
+	if err := describer.DescribeTileset(context.Background(), m.Tileset(version)); err != nil {
+		log.Errorf("failed describing tileset of map (%v) to the cache: %v", m.Name, err)
+	}
+}
+
+// SweepGenerations removes the cached tiles of every map which were written under
+// another version than the map's current version, such as the tiles left over by a run
+// with an older config. It does nothing unless cache key versioning is turned on.
+// The tiles of other instances sharing the cache with another config are removed as
+// well, so it's only called at startup when asked for.
+func (a *Atlas) SweepGenerations() {
+	if a == nil {
+		// Use the default Atlas if a, is nil. This way the empty value is
+		// still useful.
+		defaultAtlas.SweepGenerations()
+		return
+	}
+	a.RLock()
+	if !a.keyVersioning {
+		a.RUnlock()
+		return
+	}
+	versions := make(map[string]string, len(a.maps))
+	for name, m := range a.maps {
+		versions[name] = m.Version
+	}
+	a.RUnlock()
+
+	for name, version := range versions {
+		a.sweepGenerations(name, version)
+	}
+}
+
+// sweepGenerations removes the tiles of all generations of a map other than keepVersion
+// from the cache backend, if the backend supports it.
+func (a *Atlas) sweepGenerations(mapName, keepVersion string) {
+	a.RLock()
+	cacher, unversioned := a.cacher, a.sweepUnversioned
+	a.RUnlock()
+
+	if cacher == nil || keepVersion == "" {
+		return
+	}
+
+	purger, ok := cache.Original(cacher).(cache.GenerationPurger)
+	if !ok {
+		log.Debugf("cache backend does not support purging generations, skipping sweep of map (%v)", mapName)
+		return
+	}
+
+	log.Infof("sweeping cached tiles of map (%v) not belonging to version (%v)", mapName, keepVersion)
+	if err := purger.PurgeGenerations(context.Background(), mapName, keepVersion, unversioned); err != nil {
+		log.Errorf("failed sweeping old cache generations of map (%v): %v", mapName, err)
+	}
+}
+
+// SetEmptyTiles sets how seeding caches the tiles which have no features
+func (a *Atlas) SetEmptyTiles(mode EmptyTileMode) {
+	if a == nil {
+		// Use the default Atlas if a, is nil. This way the empty value is
+		// still useful.
+		defaultAtlas.SetEmptyTiles(mode)
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+
+	a.emptyTiles = mode
+}
+
+// EmptyTiles returns how seeding caches the tiles which have no features
+func (a *Atlas) EmptyTiles() EmptyTileMode {
+	if a == nil {
+		// Use the default Atlas if a, is nil. This way the empty value is
+		// still useful.
+		return defaultAtlas.EmptyTiles()
+	}
+	a.RLock()
+	defer a.RUnlock()
+
+	return a.emptyTiles
+}
+
+// SetCacheKeyVersioning turns the use of the map Version in cache keys on or off.
+func (a *Atlas) SetCacheKeyVersioning(enabled bool) {
+	if a == nil {
+		// Use the default Atlas if a, is nil. This way the empty value is
+		// still useful.
+		defaultAtlas.SetCacheKeyVersioning(enabled)
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+
+	if a.keyVersioning == enabled {
+		return
+	}
+
+	a.keyVersioning = enabled
+	// the version is part of the tileset description
+	for _, m := range a.maps {
+		a.describeTileset(m)
+	}
+}
+
+// SetCacheSweepUnversioned sets if the sweeps of old generations remove the tiles cached
+// without a version, before cache key versioning was turned on.
+func (a *Atlas) SetCacheSweepUnversioned(enabled bool) {
+	if a == nil {
+		// Use the default Atlas if a, is nil. This way the empty value is
+		// still useful.
+		defaultAtlas.SetCacheSweepUnversioned(enabled)
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+
+	a.sweepUnversioned = enabled
+}
+
+// KeyVersion returns the version segment to use in the cache keys of m.
+// It's empty unless cache key versioning has been turned on.
+func (a *Atlas) KeyVersion(m Map) string {
+	if a == nil {
+		// Use the default Atlas if a, is nil. This way the empty value is
+		// still useful.
+		return defaultAtlas.KeyVersion(m)
+	}
+	a.RLock()
+	defer a.RUnlock()
+
+	if !a.keyVersioning {
+		return ""
+	}
+	return m.Version
 }
 
 // GetCache returns the registered cache if one is registered, otherwise nil
@@ -244,7 +445,32 @@ func (a *Atlas) SetCache(c cache.Interface) {
 	if a.observer != nil {
 		c = a.observer.InstrumentedCache(c)
 	}
+
+	a.Lock()
+	defer a.Unlock()
+
 	a.cacher = c
+	for _, m := range a.maps {
+		a.describeTileset(m)
+	}
+}
+
+// FlushCache persists any writes buffered by the cache backend, if the backend buffers writes
+func (a *Atlas) FlushCache(ctx context.Context) error {
+	if a == nil {
+		// Use the default Atlas if a, is nil. This way the empty value is
+		// still useful.
+		return defaultAtlas.FlushCache(ctx)
+	}
+	if a.cacher == nil {
+		return nil
+	}
+
+	flusher, ok := cache.Original(a.cacher).(cache.Flusher)
+	if !ok {
+		return nil
+	}
+	return flusher.Flush(ctx)
 }
 
 // SetObservability will set the observability backend
@@ -329,6 +555,37 @@ func SetCache(c cache.Interface) {
 	defaultAtlas.SetCache(c)
 }
 
+// SetCacheKeyVersioning turns the use of the map Version in cache keys on or off for defaultAtlas
+func SetCacheKeyVersioning(enabled bool) {
+	defaultAtlas.SetCacheKeyVersioning(enabled)
+}
+
+// SetCacheSweepUnversioned sets if the sweeps of old generations remove the tiles cached
+// without a version for defaultAtlas
+func SetCacheSweepUnversioned(enabled bool) {
+	defaultAtlas.SetCacheSweepUnversioned(enabled)
+}
+
+// SetEmptyTiles sets how seeding caches the tiles which have no features for defaultAtlas
+func SetEmptyTiles(mode EmptyTileMode) {
+	defaultAtlas.SetEmptyTiles(mode)
+}
+
+// EmptyTiles returns how seeding caches the tiles which have no features for defaultAtlas
+func EmptyTiles() EmptyTileMode {
+	return defaultAtlas.EmptyTiles()
+}
+
+// KeyVersion returns the version segment to use in the cache keys of m for defaultAtlas
+func KeyVersion(m Map) string {
+	return defaultAtlas.KeyVersion(m)
+}
+
+// FlushCache persists any writes buffered by the cache backend of defaultAtlas
+func FlushCache(ctx context.Context) error {
+	return defaultAtlas.FlushCache(ctx)
+}
+
 // SeedMapTile will generate a tile and persist it to the
 // configured cache backend for the defaultAtlas
 func SeedMapTile(ctx context.Context, m Map, z, x, y uint) error {
@@ -341,7 +598,23 @@ func PurgeMapTile(ctx context.Context, m Map, tile *tegola.Tile) error {
 	return defaultAtlas.PurgeMapTile(ctx, m, tile)
 }
 
+// SeedMapTileVariant will generate a variant of a tile and persist it to the
+// configured cache backend for the defaultAtlas
+func SeedMapTileVariant(ctx context.Context, m Map, z, x, y uint, v TileVariant) error {
+	return defaultAtlas.SeedMapTileVariant(ctx, m, z, x, y, v)
+}
+
+// PurgeMapTileVariant will purge a variant of a map tile from the configured
+// cache backend for the defaultAtlas
+func PurgeMapTileVariant(ctx context.Context, m Map, tile *tegola.Tile, v TileVariant) error {
+	return defaultAtlas.PurgeMapTileVariant(ctx, m, tile, v)
+}
+
 // SetObservability sets the observability backend for the defaultAtlas
 func SetObservability(o observability.Interface) { defaultAtlas.SetObservability(o) }
 
 func StartSubProcesses() { defaultAtlas.StartSubProcesses() }
+
+// SweepGenerations removes the cached tiles of the maps of the defaultAtlas which were not
+// written under their current version
+func SweepGenerations() { defaultAtlas.SweepGenerations() }
@@ -0,0 +1,12 @@
+// +build !noMbtilesCache
+
+package atlas
+
+// The point of this file is to load and register the mbtiles cache backend.
+// the mbtiles cache can be excluded during the build with the `noMbtilesCache` build flag
+// for example from the cmd/tegola directory:
+//
+// go build -tags 'noMbtilesCache'
+import (
+	_ "github.com/go-spatial/tegola/cache/mbtiles"
+)
@@ -0,0 +1,141 @@
+package atlas_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/cache/file"
+	"github.com/go-spatial/tegola/dict"
+)
+
+func TestAddMapSweepsOldGenerations(t *testing.T) {
+	ctx := context.Background()
+
+	mc, err := file.New(dict.Dict{
+		file.ConfigKeyBasepath: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	var a atlas.Atlas
+	a.SetCache(mc)
+	a.SetCacheKeyVersioning(true)
+
+	m := atlas.NewWebMercatorMap("versioned")
+	m.Version = "v1"
+	a.AddMap(m)
+
+	if got := a.KeyVersion(m); got != "v1" {
+		t.Fatalf("key version, expected %v got %v", "v1", got)
+	}
+
+	oldKey := cache.Key{MapName: m.Name, Version: a.KeyVersion(m), Z: 1}
+	if err = mc.Set(ctx, &oldKey, []byte("old")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	m.Version = "v2"
+	a.AddMap(m)
+
+	// the sweep runs in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, hit, err := mc.Get(ctx, &oldKey)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if !hit {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected tile of old generation to be swept")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSweepGenerations(t *testing.T) {
+	type tcase struct {
+		sweepUnversioned bool
+		// expectedHits per key after the sweep
+		expectedHits map[string]bool
+	}
+
+	keys := map[string]cache.Key{
+		"old version": {MapName: "versioned", Version: "v1", Z: 1},
+		"unversioned": {MapName: "versioned", Z: 1},
+		"current":     {MapName: "versioned", Version: "v2", Z: 1},
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			ctx := context.Background()
+
+			mc, err := file.New(dict.Dict{
+				file.ConfigKeyBasepath: t.TempDir(),
+			})
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			// tiles cached by a previous run
+			for _, key := range keys {
+				key := key
+				if err = mc.Set(ctx, &key, []byte("tile")); err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+			}
+
+			var a atlas.Atlas
+			a.SetCache(mc)
+			a.SetCacheKeyVersioning(true)
+			a.SetCacheSweepUnversioned(tc.sweepUnversioned)
+
+			m := atlas.NewWebMercatorMap("versioned")
+			m.Version = "v2"
+			a.AddMap(m)
+
+			a.SweepGenerations()
+
+			for name, expected := range tc.expectedHits {
+				key := keys[name]
+				_, hit, err := mc.Get(ctx, &key)
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				if hit != expected {
+					t.Errorf("%v: expected hit %v got %v", name, expected, hit)
+				}
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"versions": {
+			expectedHits: map[string]bool{"old version": false, "unversioned": true, "current": true},
+		},
+		"unversioned": {
+			sweepUnversioned: true,
+			expectedHits:     map[string]bool{"old version": false, "unversioned": false, "current": true},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestKeyVersionDisabled(t *testing.T) {
+	var a atlas.Atlas
+
+	m := atlas.NewWebMercatorMap("unversioned")
+	m.Version = "v1"
+
+	if got := a.KeyVersion(m); got != "" {
+		t.Errorf("key version, expected empty got %v", got)
+	}
+}
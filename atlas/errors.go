@@ -17,3 +17,14 @@ type ErrMapNotFound struct {
 func (e ErrMapNotFound) Error() string {
 	return fmt.Sprintf("atlas: map (%v) not found", e.Name)
 }
+
+// ErrDuplicateLayerName is returned when the layers of different providers of a
+// composite map are encoded with the same name
+type ErrDuplicateLayerName struct {
+	Map  string
+	Name string
+}
+
+func (e ErrDuplicateLayerName) Error() string {
+	return fmt.Sprintf("atlas: map (%v) has more than one layer named (%v)", e.Map, e.Name)
+}
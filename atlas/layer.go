@@ -13,8 +13,14 @@ type Layer struct {
 	ProviderLayerName string
 	MinZoom           uint
 	MaxZoom           uint
+	// the name of the provider of the layer, as configured
+	ProviderName string
 	// instantiated provider
 	Provider provider.Tiler
+	// instantiated mvt provider, set for the layers of mvt providers which
+	// encode the layer themselves. Provider is not used when it's set. The
+	// layers of an mvt provider are encoded together.
+	MVTProvider provider.MVTTiler
 	// default tags to include when encoding the layer. provider tags take precedence
 	DefaultTags env.Dict
 	GeomType    geom.Geometry
@@ -39,7 +45,11 @@ func (l *Layer) MVTName() string {
 }
 
 func (l Layer) Collectors(prefix string, config func(configKey string) map[string]interface{}) ([]observability.Collector, error) {
-	collect, ok := l.Provider.(observability.Observer)
+	var p interface{} = l.Provider
+	if l.MVTProvider != nil {
+		p = l.MVTProvider
+	}
+	collect, ok := p.(observability.Observer)
 	if !ok {
 		return nil, nil
 	}
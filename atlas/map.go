@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
 
@@ -15,9 +16,11 @@ import (
 
 	"github.com/go-spatial/geom"
 	"github.com/go-spatial/geom/encoding/mvt"
+	vectorTile "github.com/go-spatial/geom/encoding/mvt/vector_tile"
 	"github.com/go-spatial/geom/slippy"
 	"github.com/go-spatial/tegola"
 	"github.com/go-spatial/tegola/basic"
+	"github.com/go-spatial/tegola/cache"
 	"github.com/go-spatial/tegola/dict"
 	"github.com/go-spatial/tegola/internal/convert"
 	"github.com/go-spatial/tegola/internal/log"
@@ -56,6 +59,10 @@ type Map struct {
 	Layers []Layer
 	// Params holds configured query parameters
 	Params []provider.QueryParameter
+	// Version is a hash of the map's resolved config (layers, provider SQL,
+	// params and buffers). When cache key versioning is turned on it's used
+	// as the version segment of the map's cache keys.
+	Version string
 
 	SRID uint64
 	// MVT output values
@@ -68,7 +75,9 @@ type Map struct {
 	observer observability.Interface
 }
 
-// HasMVTProvider indicates if map is a mvt provider based map
+// HasMVTProvider indicates if map is a mvt provider based map, whose layers are all
+// from a single mvt provider. Maps mixing the layers of mvt providers with other
+// providers are composite maps, which don't have a map mvt provider.
 func (m Map) HasMVTProvider() bool { return m.mvtProvider != nil }
 
 // MVTProvider returns the mvt provider if this map is a mvt provider based map, otherwise nil
@@ -84,6 +93,19 @@ func (m *Map) SetMVTProvider(name string, p provider.MVTTiler) provider.MVTTiler
 	return p
 }
 
+// Cacheable reports whether the tiles of the map can be cached. The tiles of maps with
+// layers of live providers are rendered for every request, so a single live layer turns
+// off caching for the map tile. The tiles of its other layers, filtered with
+// FilterLayersByName, are still cacheable.
+func (m Map) Cacheable() bool {
+	for i := range m.Layers {
+		if live, ok := m.Layers[i].Provider.(provider.Live); ok && live.Live() {
+			return false
+		}
+	}
+	return true
+}
+
 func (m Map) Collectors(prefix string, config func(configKey string) map[string]interface{}) ([]observability.Collector, error) {
 	if m.mvtProviderName != "" {
 		collect, ok := m.mvtProvider.(observability.Observer)
@@ -106,6 +128,57 @@ func (m Map) Collectors(prefix string, config func(configKey string) map[string]
 	return collection, nil
 }
 
+// Tileset returns a description of the map for cache backends which store tileset metadata.
+// The zoom range spans the zoom ranges of all the layers and layers sharing an MVT name are merged.
+func (m Map) Tileset(version string) cache.Tileset {
+	ts := cache.Tileset{
+		Name:        m.Name,
+		Version:     version,
+		Attribution: m.Attribution,
+		Center:      m.Center,
+		Bounds:      [4]float64{-180, -85.0511, 180, 85.0511},
+	}
+	if m.Bounds != nil {
+		ts.Bounds = [4]float64{m.Bounds.MinX(), m.Bounds.MinY(), m.Bounds.MaxX(), m.Bounds.MaxY()}
+	}
+
+	for i, l := range m.Layers {
+		if i == 0 || l.MinZoom < ts.MinZoom {
+			ts.MinZoom = l.MinZoom
+		}
+		if l.MaxZoom > ts.MaxZoom {
+			ts.MaxZoom = l.MaxZoom
+		}
+
+		merged := false
+		for j := range ts.Layers {
+			if ts.Layers[j].ID != l.MVTName() {
+				continue
+			}
+			if l.MinZoom < ts.Layers[j].MinZoom {
+				ts.Layers[j].MinZoom = l.MinZoom
+			}
+			if l.MaxZoom > ts.Layers[j].MaxZoom {
+				ts.Layers[j].MaxZoom = l.MaxZoom
+			}
+			merged = true
+			break
+		}
+		if merged {
+			continue
+		}
+
+		ts.Layers = append(ts.Layers, cache.TilesetLayer{
+			ID:      l.MVTName(),
+			MinZoom: l.MinZoom,
+			MaxZoom: l.MaxZoom,
+			Fields:  map[string]string{},
+		})
+	}
+
+	return ts
+}
+
 // AddDebugLayers returns a copy of a Map with the debug layers appended to the layer list
 func (m Map) AddDebugLayers() Map {
 	// can not modify the layers of an mvt provider based map
@@ -182,7 +255,9 @@ func (m Map) FilterLayersByName(names ...string) Map {
 	return m
 }
 
-func (m Map) encodeMVTProviderTile(ctx context.Context, tile slippy.Tile, params provider.Params) ([]byte, error) {
+// encodeMVTProviderTile encodes the tile with the mvt provider. The tile is only decoded
+// to report whether it's empty when checkEmpty is true.
+func (m Map) encodeMVTProviderTile(ctx context.Context, tile slippy.Tile, params provider.Params, checkEmpty bool) ([]byte, bool, error) {
 	// get the list of our layers
 	ptile := provider.NewTile(tile.Z, tile.X, tile.Y, uint(m.TileBuffer), uint(m.SRID))
 
@@ -193,13 +268,24 @@ func (m Map) encodeMVTProviderTile(ctx context.Context, tile slippy.Tile, params
 			MVTName: m.Layers[i].MVTName(),
 		}
 	}
-	return m.mvtProvider.MVTForLayers(ctx, ptile, params, layers)
+	b, err := m.mvtProvider.MVTForLayers(ctx, ptile, params, layers)
+	if err != nil {
+		return nil, false, err
+	}
+	if !checkEmpty {
+		return b, false, nil
+	}
 
+	empty, err := isEmptyMVT(b)
+	if err != nil {
+		return nil, false, err
+	}
+	return b, empty, nil
 }
 
 // encodeMVTTile will encode the given tile into mvt format
 // TODO (arolek): support for max zoom
-func (m Map) encodeMVTTile(ctx context.Context, tile slippy.Tile, params provider.Params) ([]byte, error) {
+func (m Map) encodeMVTTile(ctx context.Context, tile slippy.Tile, params provider.Params) ([]byte, bool, error) {
 
 	// tile container
 	var mvtTile mvt.Tile
@@ -359,38 +445,168 @@ func (m Map) encodeMVTTile(ctx context.Context, tile slippy.Tile, params provide
 	// otherwise the server continues processing even if the request was canceled
 	// as the WaitGroup was not notified of the cancel
 	if ctx.Err() != nil {
-		return nil, ctx.Err()
+		return nil, false, ctx.Err()
 	}
 
 	// add layers to our tile
 	err := mvtTile.AddLayers(mvtLayers...)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// generate the MVT tile
 	vtile, err := mvtTile.VTile(ctx)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// encode our mvt tile
-	return proto.Marshal(vtile)
+	b, err := proto.Marshal(vtile)
+	if err != nil {
+		return nil, false, err
+	}
+	return b, !hasFeatures(vtile), nil
 }
 
-// Encode will encode the given tile into mvt format
-func (m Map) Encode(ctx context.Context, tile slippy.Tile, params provider.Params) ([]byte, error) {
+// isComposite reports whether some of the layers of a map which isn't a mvt provider
+// based map are from mvt providers
+func (m Map) isComposite() bool {
+	if m.HasMVTProvider() {
+		return false
+	}
+	for i := range m.Layers {
+		if m.Layers[i].MVTProvider != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeCompositeTile will encode the given tile of a composite map into mvt format.
+// The layers of standard providers are encoded by encodeMVTTile and the layers of each
+// mvt provider by the provider, then the layers are merged into one tile in the order
+// of the map layers. Layer names must be unique across the providers.
+func (m Map) encodeCompositeTile(ctx context.Context, tile slippy.Tile, params provider.Params) ([]byte, bool, error) {
+	// group the layers, the standard provider layers first then the layers of each mvt provider
 	var (
-		tileBytes []byte
-		err       error
+		groups   [][]Layer
+		groupOf  = map[string]int{}
+		stdGroup = -1
 	)
-	if m.HasMVTProvider() {
-		tileBytes, err = m.encodeMVTProviderTile(ctx, tile, params)
-	} else {
-		tileBytes, err = m.encodeMVTTile(ctx, tile, params)
+	for _, l := range m.Layers {
+		if l.MVTProvider == nil {
+			if stdGroup == -1 {
+				stdGroup = len(groups)
+				groups = append(groups, nil)
+			}
+			groups[stdGroup] = append(groups[stdGroup], l)
+			continue
+		}
+		i, ok := groupOf[l.ProviderName]
+		if !ok {
+			i = len(groups)
+			groupOf[l.ProviderName] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], l)
+	}
+
+	// encode the groups concurrently
+	var (
+		wg    sync.WaitGroup
+		tiles = make([][]byte, len(groups))
+		errs  = make([]error, len(groups))
+	)
+	wg.Add(len(groups))
+	for i := range groups {
+		go func(i int) {
+			defer wg.Done()
+
+			gm := m
+			gm.Layers = groups[i]
+			if i == stdGroup {
+				tiles[i], _, errs[i] = gm.encodeMVTTile(ctx, tile, params)
+				return
+			}
+			gm.SetMVTProvider(groups[i][0].ProviderName, groups[i][0].MVTProvider)
+			tiles[i], _, errs[i] = gm.encodeMVTProviderTile(ctx, tile, params, false)
+		}(i)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, false, ctx.Err()
 	}
+
+	// index the encoded layers by name
+	layers := map[string]*vectorTile.Tile_Layer{}
+	var names []string
+	for i := range tiles {
+		if errs[i] != nil {
+			return nil, false, errs[i]
+		}
+
+		var vtile vectorTile.Tile
+		if err := proto.Unmarshal(tiles[i], &vtile); err != nil {
+			return nil, false, err
+		}
+		for _, l := range vtile.Layers {
+			if _, ok := layers[l.GetName()]; ok {
+				return nil, false, ErrDuplicateLayerName{Map: m.Name, Name: l.GetName()}
+			}
+			layers[l.GetName()] = l
+			names = append(names, l.GetName())
+		}
+	}
+
+	// merge the layers in the order of the map layers. layers the providers encoded
+	// under other names are kept after them.
+	var vtile vectorTile.Tile
+	for i := range m.Layers {
+		name := m.Layers[i].MVTName()
+		if l, ok := layers[name]; ok {
+			vtile.Layers = append(vtile.Layers, l)
+			delete(layers, name)
+		}
+	}
+	for _, name := range names {
+		if l, ok := layers[name]; ok {
+			vtile.Layers = append(vtile.Layers, l)
+		}
+	}
+
+	b, err := proto.Marshal(&vtile)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	return b, !hasFeatures(&vtile), nil
+}
+
+// Encode will encode the given tile into mvt format
+func (m Map) Encode(ctx context.Context, tile slippy.Tile, params provider.Params) ([]byte, error) {
+	b, _, err := m.encode(ctx, tile, params, false)
+	return b, err
+}
+
+// EncodeCheckEmpty encodes the tile like Encode and reports whether none of the layers
+// of the tile have features. The tiles of mvt providers are decoded to find out, so it's
+// meant for the callers which act on empty tiles, such as seeding.
+func (m Map) EncodeCheckEmpty(ctx context.Context, tile slippy.Tile, params provider.Params) (b []byte, empty bool, err error) {
+	return m.encode(ctx, tile, params, true)
+}
+
+func (m Map) encode(ctx context.Context, tile slippy.Tile, params provider.Params, checkEmpty bool) (b []byte, empty bool, err error) {
+	var tileBytes []byte
+	switch {
+	case m.HasMVTProvider():
+		tileBytes, empty, err = m.encodeMVTProviderTile(ctx, tile, params, checkEmpty)
+	case m.isComposite():
+		tileBytes, empty, err = m.encodeCompositeTile(ctx, tile, params)
+	default:
+		tileBytes, empty, err = m.encodeMVTTile(ctx, tile, params)
+	}
+	if err != nil {
+		return nil, false, err
 	}
 
 	// buffer to store our compressed bytes
@@ -400,14 +616,55 @@ func (m Map) Encode(ctx context.Context, tile slippy.Tile, params provider.Param
 	w := gzip.NewWriter(&gzipBuf)
 	_, err = w.Write(tileBytes)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// flush and close the writer
 	if err = w.Close(); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// return encoded, gzipped tile
-	return gzipBuf.Bytes(), nil
+	return gzipBuf.Bytes(), empty, nil
+}
+
+// IsEmptyTile reports whether an encoded tile, as returned by Encode, has no features.
+// The cache.EmptyTile sentinel is an empty tile.
+func IsEmptyTile(b []byte) (bool, error) {
+	if cache.IsEmptyTile(b) {
+		return true, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return false, err
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return false, err
+	}
+	return isEmptyMVT(raw)
+}
+
+// isEmptyMVT reports whether an uncompressed mvt tile has no features
+func isEmptyMVT(b []byte) (bool, error) {
+	if len(b) == 0 {
+		return true, nil
+	}
+
+	var vtile vectorTile.Tile
+	if err := proto.Unmarshal(b, &vtile); err != nil {
+		return false, err
+	}
+	return !hasFeatures(&vtile), nil
+}
+
+// hasFeatures reports whether a layer of the tile has features
+func hasFeatures(vtile *vectorTile.Tile) bool {
+	for _, l := range vtile.Layers {
+		if len(l.Features) > 0 {
+			return true
+		}
+	}
+	return false
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"errors"
 	"io"
 	"reflect"
 	"testing"
@@ -443,3 +444,175 @@ func TestEncode(t *testing.T) {
 		t.Run(name, fn(tc))
 	}
 }
+
+func TestEncodeComposite(t *testing.T) {
+	polygon := vectorTile.Tile_POLYGON
+
+	// mvtTile returns an encoded tile with a layer for each name
+	mvtTile := func(t *testing.T, names ...string) []byte {
+		t.Helper()
+
+		var tile vectorTile.Tile
+		for _, name := range names {
+			tile.Layers = append(tile.Layers, &vectorTile.Tile_Layer{
+				Version: p.Uint32(2),
+				Name:    p.String(name),
+				Features: []*vectorTile.Tile_Feature{
+					{
+						Id:       p.Uint64(1),
+						Type:     &polygon,
+						Geometry: []uint32{9, 0, 0, 26, 8192, 0, 0, 8192, 8191, 0, 15},
+					},
+				},
+				Extent: p.Uint32(vectorTile.Default_Tile_Layer_Extent),
+			})
+		}
+		b, err := proto.Marshal(&tile)
+		if err != nil {
+			t.Fatalf("marshal, expected nil got %v", err)
+		}
+		return b
+	}
+
+	type tcase struct {
+		layers   func(t *testing.T) []atlas.Layer
+		expected []string
+		err      error
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			grid := atlas.Map{
+				Name:       "composite",
+				Layers:     tc.layers(t),
+				TileExtent: 4096,
+				TileBuffer: 64,
+				SRID:       3857,
+			}
+			out, empty, err := grid.EncodeCheckEmpty(context.Background(), slippy.Tile{Z: 2, X: 3, Y: 3}, nil)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("expected %v got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if empty {
+				t.Errorf("empty, expected false got true")
+			}
+
+			r, err := gzip.NewReader(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("gzip, expected nil got %v", err)
+			}
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("gzip, expected nil got %v", err)
+			}
+			var tile vectorTile.Tile
+			if err = proto.Unmarshal(b, &tile); err != nil {
+				t.Fatalf("unmarshal, expected nil got %v", err)
+			}
+
+			var names []string
+			for _, l := range tile.Layers {
+				if len(l.Features) == 0 {
+					t.Errorf("layer %v features, expected 1 or more got 0", l.GetName())
+				}
+				names = append(names, l.GetName())
+			}
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, names)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"standard and mvt layers": {
+			layers: func(t *testing.T) []atlas.Layer {
+				mvtProvider := &test.TileProvider{MVTTile: mvtTile(t, "water", "roads")}
+				return []atlas.Layer{
+					{Name: "land", Provider: &test.TileProvider{}},
+					{Name: "water", MVTProvider: mvtProvider, ProviderName: "mvt"},
+					{Name: "buildings", Provider: &test.TileProvider{}},
+					{Name: "roads", MVTProvider: mvtProvider, ProviderName: "mvt"},
+				}
+			},
+			expected: []string{"land", "water", "buildings", "roads"},
+		},
+		"two mvt providers": {
+			layers: func(t *testing.T) []atlas.Layer {
+				return []atlas.Layer{
+					{Name: "roads", MVTProvider: &test.TileProvider{MVTTile: mvtTile(t, "roads")}, ProviderName: "mvt1"},
+					{Name: "water", MVTProvider: &test.TileProvider{MVTTile: mvtTile(t, "water")}, ProviderName: "mvt2"},
+				}
+			},
+			expected: []string{"roads", "water"},
+		},
+		"duplicate layer name": {
+			layers: func(t *testing.T) []atlas.Layer {
+				return []atlas.Layer{
+					{Name: "water", Provider: &test.TileProvider{}},
+					{Name: "water", MVTProvider: &test.TileProvider{MVTTile: mvtTile(t, "water")}, ProviderName: "mvt"},
+				}
+			},
+			err: atlas.ErrDuplicateLayerName{Map: "composite", Name: "water"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestEncodeMVTProviderCheckEmpty(t *testing.T) {
+	type tcase struct {
+		mvtTile []byte
+		empty   bool
+		err     bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			m := atlas.NewWebMercatorMap("mvt")
+			m.Layers = []atlas.Layer{{Name: "water", ProviderLayerName: "water"}}
+			m.SetMVTProvider("mvt", &test.TileProvider{MVTTile: tc.mvtTile})
+			tile := slippy.Tile{Z: 2, X: 1, Y: 1}
+
+			// the tile is passed through without being decoded
+			if _, err := m.Encode(context.Background(), tile, nil); err != nil {
+				t.Fatalf("encode, expected nil got %v", err)
+			}
+
+			_, empty, err := m.EncodeCheckEmpty(context.Background(), tile, nil)
+			if tc.err {
+				if err == nil {
+					t.Errorf("check empty, expected error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("check empty, expected nil got %v", err)
+			}
+			if empty != tc.empty {
+				t.Errorf("empty, expected %v got %v", tc.empty, empty)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"no data": {
+			empty: true,
+		},
+		"invalid data": {
+			mvtTile: []byte{0xff, 0xff, 0xff},
+			err:     true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !noArchiveProvider
+// +build !noArchiveProvider
+
+package atlas
+
+// The point of this file is to load and register the mvt_mbtiles and mvt_pmtiles providers.
+// the providers can be excluded during the build with the `noArchiveProvider` build flag
+// for example from the cmd/tegola directory:
+//
+// go build -tags 'noArchiveProvider'
+import (
+	_ "github.com/go-spatial/tegola/provider/archive"
+)
@@ -0,0 +1,13 @@
+//go:build !noGeofileProvider
+// +build !noGeofileProvider
+
+package atlas
+
+// The point of this file is to load and register the GeoJSON and FlatGeobuf providers.
+// the providers can be excluded during the build with the `noGeofileProvider` build flag
+// for example from the cmd/tegola directory:
+//
+// go build -tags 'noGeofileProvider'
+import (
+	_ "github.com/go-spatial/tegola/provider/geofile"
+)
@@ -0,0 +1,13 @@
+//go:build !noGeoparquetProvider
+// +build !noGeoparquetProvider
+
+package atlas
+
+// The point of this file is to load and register the geoparquet provider.
+// the provider can be excluded during the build with the `noGeoparquetProvider` build flag
+// for example from the cmd/tegola directory:
+//
+// go build -tags 'noGeoparquetProvider'
+import (
+	_ "github.com/go-spatial/tegola/provider/geoparquet"
+)
@@ -0,0 +1,13 @@
+//go:build !noInMemoryProvider
+// +build !noInMemoryProvider
+
+package atlas
+
+// The point of this file is to load and register the inmemory provider.
+// the provider can be excluded during the build with the `noInMemoryProvider` build flag
+// for example from the cmd/tegola directory:
+//
+// go build -tags 'noInMemoryProvider'
+import (
+	_ "github.com/go-spatial/tegola/provider/inmemory"
+)
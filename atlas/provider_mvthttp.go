@@ -0,0 +1,13 @@
+//go:build !noMvtHTTPProvider
+// +build !noMvtHTTPProvider
+
+package atlas
+
+// The point of this file is to load and register the mvt_http provider.
+// the provider can be excluded during the build with the `noMvtHTTPProvider` build flag
+// for example from the cmd/tegola directory:
+//
+// go build -tags 'noMvtHTTPProvider'
+import (
+	_ "github.com/go-spatial/tegola/provider/mvthttp"
+)
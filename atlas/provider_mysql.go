@@ -0,0 +1,13 @@
+//go:build !noMysqlProvider
+// +build !noMysqlProvider
+
+package atlas
+
+// The point of this file is to load and register the MySQL provider.
+// the provider can be excluded during the build with the `noMysqlProvider` build flag
+// for example from the cmd/tegola directory:
+//
+// go build -tags 'noMysqlProvider'
+import (
+	_ "github.com/go-spatial/tegola/provider/mysql"
+)
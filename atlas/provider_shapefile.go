@@ -0,0 +1,13 @@
+//go:build !noShapefileProvider
+// +build !noShapefileProvider
+
+package atlas
+
+// The point of this file is to load and register the shapefile provider.
+// the provider can be excluded during the build with the `noShapefileProvider` build flag
+// for example from the cmd/tegola directory:
+//
+// go build -tags 'noShapefileProvider'
+import (
+	_ "github.com/go-spatial/tegola/provider/shapefile"
+)
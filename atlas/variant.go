@@ -0,0 +1,140 @@
+package atlas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"reflect"
+
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// TileVariant selects which tile of a map is seeded or purged, the same way
+// the path and the query string of a tile request do.
+type TileVariant struct {
+	// LayerName restricts the tile to a single layer, as requested by
+	// /maps/:map_name/:layer_name/:z/:x/:y. Empty for the map tile.
+	LayerName string
+	// Params holds the raw values of the map query parameters by name, as
+	// they would be passed in the query string. Missing parameters use their
+	// configured defaults.
+	Params map[string]string
+}
+
+// ParamsKey returns the cache key segment for the raw query parameter values.
+// It only depends on the parameters and their values, not on their order. An
+// empty string is returned when there are no values.
+func ParamsKey(values map[string]string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	q := make(url.Values, len(values))
+	for name, val := range values {
+		q.Set(name, val)
+	}
+
+	// Encode sorts by parameter name
+	sum := sha256.Sum256([]byte(q.Encode()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ParamsKey returns the cache key segment for the raw query parameter values of
+// the map. The values are keyed by their decoded value, so values decoding to the
+// same value (i.e. 1 and 01 for an int) and values equal to the parameter default
+// share a key. An empty string is returned when all the values are defaults.
+func (m Map) ParamsKey(values map[string]string) (string, error) {
+	params, err := m.ParamValues(values)
+	if err != nil {
+		return "", err
+	}
+
+	decoded := make(map[string]string, len(values))
+	for _, param := range m.Params {
+		if _, ok := values[param.Name]; !ok {
+			continue
+		}
+
+		val := params[param.Token]
+		def, err := param.ToDefaultValue()
+		if err == nil && val.SQL == def.SQL && reflect.DeepEqual(val.Value, def.Value) {
+			continue
+		}
+		decoded[param.Name] = fmt.Sprint(val.Value)
+	}
+
+	return ParamsKey(decoded), nil
+}
+
+// ParamValues decodes the raw query parameter values by name into the Params
+// used to encode a tile of the map. Parameters without a value use their
+// default value, and names which are not query parameters of the map are an error.
+func (m Map) ParamValues(values map[string]string) (provider.Params, error) {
+	for name := range values {
+		if !m.HasParam(name) {
+			return nil, fmt.Errorf("map (%v) has no query parameter (%v)", m.Name, name)
+		}
+	}
+
+	if len(m.Params) == 0 {
+		return nil, nil
+	}
+
+	params := make(provider.Params, len(m.Params))
+	for _, param := range m.Params {
+		var (
+			val provider.QueryParameterValue
+			err error
+		)
+		if raw, ok := values[param.Name]; ok {
+			val, err = param.ToValue(raw)
+		} else {
+			val, err = param.ToDefaultValue()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("map (%v) query parameter (%v): %w", m.Name, param.Name, err)
+		}
+		params[param.Token] = val
+	}
+
+	return params, nil
+}
+
+// HasParam reports if name is a configured query parameter of the map
+func (m Map) HasParam(name string) bool {
+	for i := range m.Params {
+		if m.Params[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CacheKey returns the key a variant of a map tile is cached under. The params of
+// the variant are expected to be valid, see ParamValues.
+func (a *Atlas) CacheKey(m Map, tile slippy.Tile, v TileVariant) *cache.Key {
+	params, err := m.ParamsKey(v.Params)
+	if err != nil {
+		// not a key of a rendered tile, but it doesn't match the key of valid values
+		params = ParamsKey(v.Params)
+	}
+
+	z, x, y := tile.ZXY()
+	return &cache.Key{
+		MapName:   m.Name,
+		Version:   a.KeyVersion(m),
+		Params:    params,
+		LayerName: v.LayerName,
+		Z:         uint(z),
+		X:         x,
+		Y:         y,
+	}
+}
+
+// CacheKey returns the key a variant of a map tile is cached under for the defaultAtlas
+func CacheKey(m Map, tile slippy.Tile, v TileVariant) *cache.Key {
+	return defaultAtlas.CacheKey(m, tile, v)
+}
@@ -0,0 +1,272 @@
+package atlas_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/cache/file"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/provider"
+	"github.com/go-spatial/tegola/provider/test"
+	"github.com/go-spatial/tegola/provider/test/emptycollection"
+)
+
+func TestParamsKey(t *testing.T) {
+	a := atlas.ParamsKey(map[string]string{"a": "1", "b": "2"})
+	b := atlas.ParamsKey(map[string]string{"b": "2", "a": "1"})
+	if a != b {
+		t.Errorf("params key, expected %v got %v", a, b)
+	}
+	if c := atlas.ParamsKey(map[string]string{"a": "1", "b": "3"}); c == a {
+		t.Errorf("params key of other values, expected not %v", a)
+	}
+	if got := atlas.ParamsKey(nil); got != "" {
+		t.Errorf("params key of no values, expected empty got %v", got)
+	}
+}
+
+func TestMapParamsKey(t *testing.T) {
+	type tcase struct {
+		values map[string]string
+		same   map[string]string
+		err    bool
+	}
+
+	m := atlas.NewWebMercatorMap("params")
+	m.Params = []provider.QueryParameter{
+		{Name: "count", Token: "!COUNT!", Type: "int", SQL: "?", DefaultValue: "1"},
+		{Name: "name", Token: "!NAME!", Type: "string", SQL: "?", DefaultSQL: " "},
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			got, err := m.ParamsKey(tc.values)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %v got %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+
+			expected, err := m.ParamsKey(tc.same)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if got != expected {
+				t.Errorf("expected %q got %q", expected, got)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"default value": {
+			values: map[string]string{"count": "1"},
+			same:   nil,
+		},
+		"decoded value": {
+			values: map[string]string{"count": "05", "name": "a"},
+			same:   map[string]string{"count": "5", "name": "a"},
+		},
+		"default sql": {
+			values: map[string]string{"count": "1", "name": "a"},
+			same:   map[string]string{"name": "a"},
+		},
+		"invalid value": {
+			values: map[string]string{"count": "many"},
+			err:    true,
+		},
+		"unknown param": {
+			values: map[string]string{"size": "1"},
+			err:    true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+
+	if key, _ := m.ParamsKey(map[string]string{"count": "5"}); key == "" {
+		t.Errorf("expected a key for a value other than the default")
+	}
+}
+
+func TestSeedMapTileVariant(t *testing.T) {
+	type tcase struct {
+		variant atlas.TileVariant
+		key     cache.Key
+		err     bool
+		cached  bool
+	}
+
+	m := atlas.NewWebMercatorMap("params")
+	m.Layers = []atlas.Layer{{
+		Name:              "points",
+		ProviderLayerName: "test-layer",
+		MinZoom:           0,
+		MaxZoom:           20,
+		Provider:          &test.TileProvider{},
+		GeomType:          geom.Point{},
+	}}
+	m.Params = []provider.QueryParameter{
+		{Name: "count", Token: "!COUNT!", Type: "int", SQL: "?", DefaultValue: "1"},
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			ctx := context.Background()
+			mc, err := file.New(dict.Dict{
+				file.ConfigKeyBasepath: t.TempDir(),
+			})
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			var a atlas.Atlas
+			a.SetCache(mc)
+
+			err = a.SeedMapTileVariant(ctx, m, 2, 1, 1, tc.variant)
+			if (err != nil) != tc.err {
+				t.Fatalf("seed, expected error %v got %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if key := a.CacheKey(m, slippy.Tile{Z: 2, X: 1, Y: 1}, tc.variant); *key != tc.key {
+				t.Errorf("key, expected %+v got %+v", tc.key, *key)
+			}
+
+			_, hit, _ := mc.Get(ctx, &tc.key)
+			if hit != tc.cached {
+				t.Errorf("cached, expected %v got %v", tc.cached, hit)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"default params": {
+			key:    cache.Key{MapName: "params", Z: 2, X: 1, Y: 1},
+			cached: true,
+		},
+		"params": {
+			variant: atlas.TileVariant{Params: map[string]string{"count": "5"}},
+			key:     cache.Key{MapName: "params", Params: atlas.ParamsKey(map[string]string{"count": "5"}), Z: 2, X: 1, Y: 1},
+			cached:  true,
+		},
+		"layer": {
+			variant: atlas.TileVariant{LayerName: "points"},
+			key:     cache.Key{MapName: "params", LayerName: "points", Z: 2, X: 1, Y: 1},
+			cached:  true,
+		},
+		"missing layer": {
+			variant: atlas.TileVariant{LayerName: "lines"},
+			key:     cache.Key{MapName: "params", LayerName: "lines", Z: 2, X: 1, Y: 1},
+		},
+		"unknown param": {
+			variant: atlas.TileVariant{Params: map[string]string{"size": "5"}},
+			err:     true,
+		},
+		"invalid value": {
+			variant: atlas.TileVariant{Params: map[string]string{"count": "five"}},
+			err:     true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestSeedMapTileEmpty(t *testing.T) {
+	type tcase struct {
+		mode     atlas.EmptyTileMode
+		provider provider.Tiler
+		cached   bool
+		sentinel bool
+		empty    bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			ctx := context.Background()
+			mc, err := file.New(dict.Dict{
+				file.ConfigKeyBasepath: t.TempDir(),
+			})
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			var a atlas.Atlas
+			a.SetCache(mc)
+			a.SetEmptyTiles(tc.mode)
+
+			m := atlas.NewWebMercatorMap("empty")
+			m.Layers = []atlas.Layer{{
+				Name:              "layer",
+				ProviderLayerName: "test-layer",
+				MinZoom:           0,
+				MaxZoom:           20,
+				Provider:          tc.provider,
+				GeomType:          geom.Point{},
+			}}
+
+			if err = a.SeedMapTile(ctx, m, 2, 1, 1); err != nil {
+				t.Fatalf("seed, expected nil got %v", err)
+			}
+
+			val, hit, err := mc.Get(ctx, &cache.Key{MapName: "empty", Z: 2, X: 1, Y: 1})
+			if err != nil {
+				t.Fatalf("get, expected nil got %v", err)
+			}
+			if hit != tc.cached {
+				t.Fatalf("cached, expected %v got %v", tc.cached, hit)
+			}
+			if !hit {
+				return
+			}
+			if got := cache.IsEmptyTile(val); got != tc.sentinel {
+				t.Errorf("sentinel, expected %v got %v", tc.sentinel, got)
+			}
+			empty, err := atlas.IsEmptyTile(val)
+			if err != nil {
+				t.Fatalf("is empty, expected nil got %v", err)
+			}
+			if empty != tc.empty {
+				t.Errorf("empty, expected %v got %v", tc.empty, empty)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"store": {
+			mode:     atlas.EmptyTileStore,
+			provider: &emptycollection.TileProvider{},
+			cached:   true,
+			empty:    true,
+		},
+		"skip": {
+			mode:     atlas.EmptyTileSkip,
+			provider: &emptycollection.TileProvider{},
+		},
+		"sentinel": {
+			mode:     atlas.EmptyTileSentinel,
+			provider: &emptycollection.TileProvider{},
+			cached:   true,
+			sentinel: true,
+			empty:    true,
+		},
+		"not empty": {
+			mode:     atlas.EmptyTileSkip,
+			provider: &test.TileProvider{},
+			cached:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
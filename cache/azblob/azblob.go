@@ -7,7 +7,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/Azure/azure-storage-blob-go/2017-07-29/azblob"
 
@@ -263,3 +265,38 @@ func (azb *Cache) makeBlob(key *cache.Key) azblob.BlobURL {
 
 	return azb.Container.NewBlobURL(k)
 }
+
+// List pages through the blobs under the basepath and calls fn for each tile matching the filter.
+func (azb *Cache) List(ctx context.Context, filter cache.ListFilter, fn func(cache.KeyInfo) error) error {
+	prefix := path.Join(azb.Basepath, filter.Prefix())
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		res, err := azb.Container.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Prefix: prefix,
+		})
+		if err != nil {
+			return err
+		}
+		marker = res.NextMarker
+
+		for _, blob := range res.Blobs.Blob {
+			key, ok := cache.MatchStoredKey(strings.TrimPrefix(blob.Name, azb.Basepath), filter)
+			if !ok {
+				continue
+			}
+
+			var size int64
+			if blob.Properties.ContentLength != nil {
+				size = *blob.Properties.ContentLength
+			}
+			if err = fn(cache.KeyInfo{Key: *key, Size: size}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
@@ -21,18 +22,80 @@ type Interface interface {
 	Purge(ctx context.Context, key *Key) error
 }
 
+// GenerationPurger is implemented by cache backends that are able to remove
+// every tile cached for a map that was written under another version than the
+// given version. The tiles written without a version are only removed when
+// unversioned is set.
+type GenerationPurger interface {
+	PurgeGenerations(ctx context.Context, mapName, keepVersion string, unversioned bool) error
+}
+
+// Flusher is implemented by cache backends that buffer writes.
+// Flush persists any writes that are still buffered.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Tileset describes a map whose tiles are stored in a cache backend
+type Tileset struct {
+	Name        string
+	Version     string
+	Attribution string
+	// Bounds in WGS84 in the order west, south, east, north
+	Bounds [4]float64
+	// Center in WGS84 as longitude, latitude, zoom
+	Center  [3]float64
+	MinZoom uint
+	MaxZoom uint
+	Layers  []TilesetLayer
+}
+
+// TilesetLayer describes a vector layer of a Tileset
+type TilesetLayer struct {
+	ID      string
+	MinZoom uint
+	MaxZoom uint
+	// Fields maps attribute names to their type, may be empty when unknown
+	Fields map[string]string
+}
+
+// TilesetDescriber is implemented by cache backends which store metadata
+// along with the tiles of a map, such as tileset archives.
+type TilesetDescriber interface {
+	DescribeTileset(ctx context.Context, ts Tileset) error
+}
+
+// EmptyTile is the compact sentinel value stored in place of a tile without any
+// features. Encoded tiles are gzipped, so they never match it, and a zero length
+// value is never the sentinel but a truncated or broken tile.
+var EmptyTile = []byte("tegola:empty-tile")
+
+// IsEmptyTile reports whether a value read from a cache is the EmptyTile sentinel
+func IsEmptyTile(val []byte) bool {
+	return bytes.Equal(val, EmptyTile)
+}
+
 // Wrapped Cache are for cache backend that wrap other cache backends
 // Original will return the first cache backend to be wrapped
 type Wrapped interface {
 	Original() Interface
 }
 
+// Original returns the cache backend wrapped by c if c is a Wrapped cache,
+// otherwise c is returned as is. This is useful to check a backend for optional
+// interfaces when it has been instrumented by an observer.
+func Original(c Interface) Interface {
+	if w, ok := c.(Wrapped); ok {
+		if o := w.Original(); o != nil {
+			return o
+		}
+	}
+	return c
+}
+
 // ParseKey will parse a string in the format /:map/:layer/:z/:x/:y into a Key struct. The :layer value is optional
 // ParseKey also supports other OS delimiters (i.e. Windows - "\")
 func ParseKey(str string) (*Key, error) {
-	var err error
-	var key Key
-
 	// convert to all slashes to forward slashes. without this reading from certain OSes (i.e. windows)
 	// will fail our keyParts check since it uses backslashes.
 	str = filepath.ToSlash(str)
@@ -40,15 +103,31 @@ func ParseKey(str string) (*Key, error) {
 	// remove the base-path and the first slash, then split the parts
 	keyParts := strings.Split(strings.TrimLeft(str, "/"), "/")
 
+	key, err := parseKeyParts(str, keyParts)
+	if err != nil {
+		switch err.(type) {
+		case ErrInvalidFileKeyParts:
+			log.Println(err.Error())
+		default:
+			log.Printf("cache: invalid file key: %s", err.Error())
+		}
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// parseKeyParts parses the map, layer, z, x, y parts of a key. The map and layer parts are optional.
+func parseKeyParts(str string, keyParts []string) (*Key, error) {
+	var err error
+	var key Key
+
 	// we're expecting a z/x/y scheme
 	if len(keyParts) < 3 || len(keyParts) > 5 {
-		err = ErrInvalidFileKeyParts{
+		return nil, ErrInvalidFileKeyParts{
 			path:          str,
 			keyPartsCount: len(keyParts),
 		}
-
-		log.Println(err.Error())
-		return nil, err
 	}
 
 	var zxy []string
@@ -69,14 +148,11 @@ func ParseKey(str string) (*Key, error) {
 	var placeholder uint64
 	placeholder, err = strconv.ParseUint(zxy[0], 10, 32)
 	if err != nil || placeholder > tegola.MaxZ {
-		err = ErrInvalidFileKey{
+		return nil, ErrInvalidFileKey{
 			path: str,
 			key:  "Z",
 			val:  zxy[0],
 		}
-
-		log.Printf("cache: invalid file key: %s", err.Error())
-		return nil, err
 	}
 
 	key.Z = uint(placeholder)
@@ -84,14 +160,11 @@ func ParseKey(str string) (*Key, error) {
 
 	placeholder, err = strconv.ParseUint(zxy[1], 10, 32)
 	if err != nil || placeholder > maxXYatZ {
-		err = ErrInvalidFileKey{
+		return nil, ErrInvalidFileKey{
 			path: str,
 			key:  "X",
 			val:  zxy[1],
 		}
-
-		log.Printf("cache: invalid file key: %s", err.Error())
-		return nil, err
 	}
 
 	key.X = uint(placeholder)
@@ -100,14 +173,11 @@ func ParseKey(str string) (*Key, error) {
 	yParts := strings.Split(zxy[2], ".")
 	placeholder, err = strconv.ParseUint(yParts[0], 10, 64)
 	if err != nil || placeholder > maxXYatZ {
-		err = ErrInvalidFileKey{
+		return nil, ErrInvalidFileKey{
 			path: str,
 			key:  "Y",
 			val:  zxy[2],
 		}
-
-		log.Printf("cache: invalid file key: %s", err.Error())
-		return nil, err
 	}
 	key.Y = uint(placeholder)
 
@@ -115,22 +185,76 @@ func ParseKey(str string) (*Key, error) {
 }
 
 type Key struct {
-	MapName   string
+	MapName string
+	// Version is an optional segment which identifies the generation of the
+	// map config the tile was rendered with. When set, it's placed directly
+	// after the map name, prefixed with VersionKeyPrefix, so all tiles of a
+	// generation share a common prefix.
+	Version string
+	// Params is an optional segment which identifies the values of the query
+	// parameters the tile was rendered with. It's placed after the version,
+	// prefixed with ParamsKeyPrefix.
+	Params    string
 	LayerName string
 	Z         uint
 	X         uint
 	Y         uint
 }
 
+// ParamsKeyPrefix is prepended to the Params segment of a key string, which
+// tells it apart from the version and layer segments
+const ParamsKeyPrefix = "@"
+
+// VersionKeyPrefix is prepended to the Version segment of a key string, which
+// tells it apart from the params and layer segments
+const VersionKeyPrefix = "~"
+
+// VersionSegment returns the key segment of version, or an empty string for an
+// empty version
+func VersionSegment(version string) string {
+	if version == "" {
+		return ""
+	}
+	return VersionKeyPrefix + version
+}
+
 func (k Key) String() string {
+	var params string
+	if k.Params != "" {
+		params = ParamsKeyPrefix + k.Params
+	}
+
 	return filepath.Join(
 		k.MapName,
+		VersionSegment(k.Version),
+		params,
 		k.LayerName,
 		strconv.FormatUint(uint64(k.Z), 10),
 		strconv.FormatUint(uint64(k.X), 10),
 		strconv.FormatUint(uint64(k.Y), 10))
 }
 
+// IsStaleGeneration reports whether the key string k, as produced by Key.String,
+// belongs to mapName but was written under another version than keepVersion. Keys
+// written without a version are only reported when unversioned is set. An empty
+// keepVersion never reports a key as stale.
+func IsStaleGeneration(k, mapName, keepVersion string, unversioned bool) bool {
+	if keepVersion == "" {
+		return false
+	}
+
+	k = filepath.ToSlash(k)
+	if !strings.HasPrefix(k, mapName+"/") {
+		return false
+	}
+
+	rest := strings.TrimPrefix(k, mapName+"/")
+	if !strings.HasPrefix(rest, VersionKeyPrefix) {
+		return unversioned
+	}
+	return !strings.HasPrefix(rest, VersionSegment(keepVersion)+"/")
+}
+
 // InitFunc initialize a cache given a config map.
 // The InitFunc should validate the config map, and report any errors.
 // This is called by the For function.
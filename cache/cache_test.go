@@ -54,3 +54,143 @@ func TestParseKey(t *testing.T) {
 		}
 	}
 }
+
+func TestKeyString(t *testing.T) {
+	type tcase struct {
+		key      cache.Key
+		expected string
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			if got := tc.key.String(); got != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, got)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"map": {
+			key:      cache.Key{MapName: "osm", Z: 1, X: 2, Y: 3},
+			expected: "osm/1/2/3",
+		},
+		"map layer": {
+			key:      cache.Key{MapName: "osm", LayerName: "roads", Z: 1, X: 2, Y: 3},
+			expected: "osm/roads/1/2/3",
+		},
+		"map version": {
+			key:      cache.Key{MapName: "osm", Version: "abc123", Z: 1, X: 2, Y: 3},
+			expected: "osm/~abc123/1/2/3",
+		},
+		"map version layer": {
+			key:      cache.Key{MapName: "osm", Version: "abc123", LayerName: "roads", Z: 1, X: 2, Y: 3},
+			expected: "osm/~abc123/roads/1/2/3",
+		},
+		"map version params layer": {
+			key:      cache.Key{MapName: "osm", Version: "abc123", Params: "f00d", LayerName: "roads", Z: 1, X: 2, Y: 3},
+			expected: "osm/~abc123/@f00d/roads/1/2/3",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestIsStaleGeneration(t *testing.T) {
+	type tcase struct {
+		key         string
+		mapName     string
+		keepVersion string
+		unversioned bool
+		expected    bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			if got := cache.IsStaleGeneration(tc.key, tc.mapName, tc.keepVersion, tc.unversioned); got != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, got)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"current version": {
+			key:         "osm/~v2/1/2/3",
+			mapName:     "osm",
+			keepVersion: "v2",
+			expected:    false,
+		},
+		"old version": {
+			key:         "osm/~v1/roads/1/2/3",
+			mapName:     "osm",
+			keepVersion: "v2",
+			expected:    true,
+		},
+		"unversioned": {
+			key:         "osm/1/2/3",
+			mapName:     "osm",
+			keepVersion: "v2",
+			expected:    false,
+		},
+		"unversioned swept": {
+			key:         "osm/1/2/3",
+			mapName:     "osm",
+			keepVersion: "v2",
+			unversioned: true,
+			expected:    true,
+		},
+		"other map": {
+			key:         "osm-lite/~v1/1/2/3",
+			mapName:     "osm",
+			keepVersion: "v2",
+			expected:    false,
+		},
+		"no keep version": {
+			key:      "osm/~v1/1/2/3",
+			mapName:  "osm",
+			expected: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestIsEmptyTile(t *testing.T) {
+	type tcase struct {
+		val      []byte
+		expected bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			if got := cache.IsEmptyTile(tc.val); got != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, got)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"sentinel": {
+			val:      cache.EmptyTile,
+			expected: true,
+		},
+		"copy of the sentinel": {
+			val:      []byte(string(cache.EmptyTile)),
+			expected: true,
+		},
+		"zero length": {
+			val: []byte{},
+		},
+		"nil": {},
+		"gzipped tile": {
+			val: []byte{0x1f, 0x8b, 0x08, 0x00},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
@@ -3,9 +3,15 @@ package file
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-spatial/tegola"
 	"github.com/go-spatial/tegola/cache"
@@ -14,6 +20,7 @@ import (
 
 var (
 	ErrMissingBasepath = errors.New("filecache: missing required param 'basepath'")
+	ErrInvalidEviction = fmt.Errorf("filecache: invalid eviction, expected %q or %q", EvictionLRU, EvictionOldest)
 )
 
 const CacheType = "file"
@@ -21,6 +28,17 @@ const CacheType = "file"
 const (
 	ConfigKeyBasepath = "basepath"
 	ConfigKeyMaxZoom  = "max_zoom"
+
+	ConfigKeyMaxSize         = "max_size"
+	ConfigKeyMaxAge          = "max_age"
+	ConfigKeyEviction        = "eviction"
+	ConfigKeyJanitorInterval = "janitor_interval"
+)
+
+var (
+	defaultMaxAge          = 0
+	defaultEviction        = EvictionLRU
+	defaultJanitorInterval = 60
 )
 
 func init() {
@@ -31,6 +49,13 @@ func init() {
 //
 //	basepath (string): a path to where the cache will be written
 //	max_zoom (int): max zoom to use the cache. beyond this zoom cache Set() calls will be ignored
+//	max_size (int | string): max number of bytes of the stored tiles, i.e. 1073741824 or "1GB". defaults to no limit
+//	max_age (int): number of seconds tiles are kept after being written. defaults to no limit
+//	eviction (string): the tiles evicted first once max_size is reached, "lru" (default) or "oldest"
+//	janitor_interval (int): number of seconds between evictions. defaults to 60
+//
+// When max_size or max_age are set, the basepath is scanned to account for the
+// already stored tiles and a janitor evicts tiles in the background.
 func New(config dict.Dicter) (cache.Interface, error) {
 	var err error
 
@@ -57,6 +82,52 @@ func New(config dict.Dicter) (cache.Interface, error) {
 		return nil, err
 	}
 
+	if v, ok := config.Interface(ConfigKeyMaxSize); ok {
+		if fc.MaxSize, err = parseSize(v); err != nil {
+			return nil, err
+		}
+		if fc.MaxSize < 0 {
+			return nil, fmt.Errorf("filecache: invalid size (%v)", v)
+		}
+	}
+
+	maxAge, err := config.Int(ConfigKeyMaxAge, &defaultMaxAge)
+	if err != nil {
+		return nil, err
+	}
+	fc.MaxAge = time.Duration(maxAge) * time.Second
+
+	fc.Eviction, err = config.String(ConfigKeyEviction, &defaultEviction)
+	if err != nil {
+		return nil, err
+	}
+	if fc.Eviction != EvictionLRU && fc.Eviction != EvictionOldest {
+		return nil, ErrInvalidEviction
+	}
+
+	janitorInterval, err := config.Int(ConfigKeyJanitorInterval, &defaultJanitorInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	if fc.MaxSize <= 0 && fc.MaxAge <= 0 {
+		return &fc, nil
+	}
+
+	fc.index = newIndex(fc.Eviction == EvictionLRU)
+	if err = fc.scan(); err != nil {
+		return nil, fmt.Errorf("filecache: scanning basepath: %w", err)
+	}
+	// evict right away in case the limits were lowered since the last run
+	if err = fc.Evict(time.Now()); err != nil {
+		return nil, err
+	}
+
+	if janitorInterval <= 0 {
+		janitorInterval = defaultJanitorInterval
+	}
+	go fc.janitor(time.Duration(janitorInterval) * time.Second)
+
 	return &fc, nil
 }
 
@@ -66,6 +137,15 @@ type Cache struct {
 	// zoom, cache Set() calls will be ignored. This is useful if the cache
 	// should not be leveraged for higher zooms when data changes often.
 	MaxZoom uint
+	// MaxSize is the max number of bytes of the stored tiles. 0 means no limit.
+	MaxSize int64
+	// MaxAge is how long tiles are kept after being written. 0 means no limit.
+	MaxAge time.Duration
+	// Eviction is the policy used to pick the tiles to evict once MaxSize is reached
+	Eviction string
+
+	// index is only set when the cache is bounded by MaxSize or MaxAge
+	index *index
 }
 
 //	Get reads a z,x,y entry from the cache and returns the contents
@@ -94,6 +174,10 @@ func (fc *Cache) Get(ctx context.Context, key *cache.Key) ([]byte, bool, error)
 		return nil, false, err
 	}
 
+	if fc.index != nil {
+		fc.index.touch(key.String())
+	}
+
 	return val, true, nil
 }
 
@@ -109,20 +193,20 @@ func (fc *Cache) Set(ctx context.Context, key *cache.Key, val []byte) error {
 		return err
 	}
 
-	// the tmpPath uses the destPath with a simple "-tmp" suffix. we're going to do
-	// a Rename at the end of this method and according to the os.Rename() docs:
-	// "If newpath already exists and is not a directory, Rename replaces it.
-	// OS-specific restrictions may apply when oldpath and newpath are in different directories"
+	// tiles are written to a temp file in the destination directory which is then
+	// renamed into place, so readers never see a partially written tile. according
+	// to the os.Rename() docs: "If newpath already exists and is not a directory,
+	// Rename replaces it. OS-specific restrictions may apply when oldpath and
+	// newpath are in different directories"
 	destPath := filepath.Join(fc.Basepath, key.String())
-	tmpPath := destPath + "-tmp"
 
 	// the key can have a directory syntax so we need to makeAll
 	if err = os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
 		return err
 	}
 
-	// create the file
-	f, err := os.Create(tmpPath)
+	tmpPath := tempPath(destPath)
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
 	if err != nil {
 		return err
 	}
@@ -132,16 +216,33 @@ func (fc *Cache) Set(ctx context.Context, key *cache.Key, val []byte) error {
 	if err != nil {
 		// close the file, can't use 'defer f.Close()'' otherwise rename wont happen
 		f.Close()
+		os.Remove(tmpPath)
 		return err
 	}
 
 	// close the file, can't use 'defer f.Close()'' otherwise rename wont happen
 	if err = f.Close(); err != nil {
+		os.Remove(tmpPath)
 		return err
 	}
 
 	// move the temp file to the destination
-	return os.Rename(tmpPath, destPath)
+	if err = os.Rename(tmpPath, destPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if fc.index != nil {
+		fc.index.add(key.String(), int64(len(val)), time.Now())
+		if fc.MaxSize > 0 && fc.index.Size() > fc.MaxSize {
+			select {
+			case fc.index.evict <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	return nil
 }
 
 func (fc *Cache) Purge(ctx context.Context, key *cache.Key) error {
@@ -156,6 +257,104 @@ func (fc *Cache) Purge(ctx context.Context, key *cache.Key) error {
 		return err
 	}
 
+	if fc.index != nil {
+		fc.index.remove(key.String())
+	}
+
 	// remove the locker key on purge
 	return os.Remove(path)
 }
+
+// PurgeGenerations removes every tile of mapName written under another version than
+// keepVersion, and the tiles written without a version when unversioned is set.
+func (fc *Cache) PurgeGenerations(ctx context.Context, mapName, keepVersion string, unversioned bool) error {
+	if keepVersion == "" || mapName == "" {
+		return nil
+	}
+
+	mapPath := filepath.Join(fc.Basepath, mapName)
+	entries, err := os.ReadDir(mapPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.Name() == cache.VersionSegment(keepVersion) {
+			continue
+		}
+		// the other entries of an unversioned map are its params, layers and zooms
+		if !unversioned && !strings.HasPrefix(entry.Name(), cache.VersionKeyPrefix) {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := os.RemoveAll(filepath.Join(mapPath, entry.Name())); err != nil {
+			return err
+		}
+
+		if fc.index != nil {
+			fc.index.removeDir(filepath.Join(mapName, entry.Name()))
+		}
+	}
+
+	return nil
+}
+
+// tempPath returns the path of the file a tile is written to before it's renamed to
+// destPath. The random part of the name keeps concurrent writes of the same tile from
+// sharing a temp file.
+func tempPath(destPath string) string {
+	return destPath + "." + strconv.FormatUint(rand.Uint64(), 36) + tmpSuffix
+}
+
+// List walks the files under the basepath and calls fn for each tile matching the filter.
+func (fc *Cache) List(ctx context.Context, filter cache.ListFilter, fn func(cache.KeyInfo) error) error {
+	root := filepath.Join(fc.Basepath, filepath.FromSlash(filter.Prefix()))
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// the temp files of writes in progress have a tile name followed by an extension
+		if strings.HasSuffix(d.Name(), tmpSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(fc.Basepath, path)
+		if err != nil {
+			return err
+		}
+		// skips other files which are not tiles
+		key, ok := cache.MatchStoredKey(rel, filter)
+		if !ok {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			// the file was removed while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		return fn(cache.KeyInfo{Key: *key, Size: info.Size()})
+	})
+	return err
+}
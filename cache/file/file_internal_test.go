@@ -0,0 +1,51 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/dict"
+)
+
+func TestListWriteInProgress(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := New(dict.Dict{
+		"basepath": t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected err, expected %v got %v", nil, err)
+	}
+	fc := c.(*Cache)
+
+	key := cache.Key{MapName: "osm", Z: 1, X: 0, Y: 1}
+	if err = fc.Set(ctx, &key, []byte("tile")); err != nil {
+		t.Fatalf("write failed. err: %v", err)
+	}
+
+	// the temp files of a tile being overwritten and of a new tile, which are not renamed yet
+	for _, k := range []cache.Key{key, {MapName: "osm", Z: 1, X: 0, Y: 0}} {
+		tmpPath := tempPath(filepath.Join(fc.Basepath, k.String()))
+		if err = os.WriteFile(tmpPath, []byte("partial tile"), 0666); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+
+	var got []cache.KeyInfo
+	err = fc.List(ctx, cache.ListFilter{MapName: "osm"}, func(ki cache.KeyInfo) error {
+		got = append(got, ki)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("list failed. err: %v", err)
+	}
+
+	expected := []cache.KeyInfo{{Key: key, Size: 4}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
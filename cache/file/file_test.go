@@ -3,8 +3,11 @@ package file_test
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/go-spatial/tegola"
 	"github.com/go-spatial/tegola/cache"
@@ -49,6 +52,7 @@ func TestNew(t *testing.T) {
 			expected: &file.Cache{
 				Basepath: "testfiles/tegola-cache",
 				MaxZoom:  tegola.MaxZ,
+				Eviction: file.EvictionLRU,
 			},
 			err: nil,
 		},
@@ -60,9 +64,26 @@ func TestNew(t *testing.T) {
 			expected: &file.Cache{
 				Basepath: "testfiles/tegola-cache",
 				MaxZoom:  9,
+				Eviction: file.EvictionLRU,
 			},
 			err: nil,
 		},
+		"invalid eviction": {
+			config: map[string]interface{}{
+				"basepath": "testfiles/tegola-cache",
+				"eviction": "random",
+			},
+			expected: nil,
+			err:      file.ErrInvalidEviction,
+		},
+		"invalid max size": {
+			config: map[string]interface{}{
+				"basepath": "testfiles/tegola-cache",
+				"max_size": "10 parsecs",
+			},
+			expected: nil,
+			err:      fmt.Errorf("filecache: invalid size (10 parsecs)"),
+		},
 		"missing basepath": {
 			config:   map[string]interface{}{},
 			expected: nil,
@@ -318,3 +339,185 @@ func TestMaxZoom(t *testing.T) {
 		t.Run(name, fn(tc))
 	}
 }
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+
+	fc, err := file.New(dict.Dict{
+		"basepath": t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected err, expected %v got %v", nil, err)
+	}
+
+	keys := []cache.Key{
+		{MapName: "osm", Version: "v1", Z: 1, X: 0, Y: 1},
+		{MapName: "osm", Version: "v1", LayerName: "roads", Z: 2, X: 3, Y: 1},
+		{MapName: "osm", Version: "v0", Z: 1, X: 0, Y: 1},
+		{MapName: "other", Version: "v1", Z: 1, X: 0, Y: 1},
+	}
+	for i := range keys {
+		if err = fc.Set(ctx, &keys[i], []byte("tile")); err != nil {
+			t.Fatalf("write failed. err: %v", err)
+		}
+	}
+
+	var got []cache.Key
+	err = fc.(cache.Lister).List(ctx, cache.ListFilter{MapName: "osm", Version: "v1"}, func(ki cache.KeyInfo) error {
+		if ki.Size != 4 {
+			t.Errorf("size of %v, expected %v got %v", ki.Key, 4, ki.Size)
+		}
+		got = append(got, ki.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("list failed. err: %v", err)
+	}
+
+	// WalkDir visits files in lexical order
+	expected := keys[:2]
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+
+	type tcase struct {
+		config dict.Dict
+		// read is the index of a key read after all the keys are written
+		read int
+		// expectedHits per key after eviction
+		expectedHits []bool
+	}
+
+	keys := []cache.Key{
+		{MapName: "osm", Z: 1, X: 0, Y: 0},
+		{MapName: "osm", Z: 1, X: 0, Y: 1},
+		{MapName: "osm", Z: 1, X: 1, Y: 0},
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			tc.config["basepath"] = t.TempDir()
+			// keep the janitor out of the way, evictions are triggered by the test
+			tc.config["janitor_interval"] = 3600
+
+			c, err := file.New(tc.config)
+			if err != nil {
+				t.Fatalf("unexpected err, expected %v got %v", nil, err)
+			}
+			fc := c.(*file.Cache)
+			defer fc.Close()
+
+			for i := range keys {
+				if err = fc.Set(ctx, &keys[i], []byte("tile")); err != nil {
+					t.Fatalf("write failed. err: %v", err)
+				}
+			}
+			if _, _, err = fc.Get(ctx, &keys[tc.read]); err != nil {
+				t.Fatalf("read failed. err: %v", err)
+			}
+
+			if err = fc.Evict(time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("evict failed. err: %v", err)
+			}
+
+			for i := range keys {
+				_, hit, err := fc.Get(ctx, &keys[i])
+				if err != nil {
+					t.Fatalf("read failed. err: %v", err)
+				}
+				if hit != tc.expectedHits[i] {
+					t.Errorf("key %v, expected hit %t got %t", keys[i], tc.expectedHits[i], hit)
+				}
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"lru": {
+			config:       dict.Dict{"max_size": "8B"},
+			read:         0,
+			expectedHits: []bool{true, false, true},
+		},
+		"oldest": {
+			config:       dict.Dict{"max_size": 8, "eviction": "oldest"},
+			read:         0,
+			expectedHits: []bool{false, true, true},
+		},
+		"max age": {
+			config:       dict.Dict{"max_age": 60},
+			read:         0,
+			expectedHits: []bool{false, false, false},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestStartupScan(t *testing.T) {
+	ctx := context.Background()
+	basepath := t.TempDir()
+
+	// fill an unbounded cache and leave a temp file behind
+	c, err := file.New(dict.Dict{"basepath": basepath})
+	if err != nil {
+		t.Fatalf("unexpected err, expected %v got %v", nil, err)
+	}
+	keys := []cache.Key{
+		{MapName: "osm", Z: 1, X: 0, Y: 0},
+		{MapName: "osm", Z: 1, X: 0, Y: 1},
+	}
+	for i := range keys {
+		if err = c.Set(ctx, &keys[i], []byte("tile")); err != nil {
+			t.Fatalf("write failed. err: %v", err)
+		}
+		// make the write order visible in the modification times
+		mtime := time.Now().Add(time.Duration(i-len(keys)) * time.Minute)
+		if err = os.Chtimes(filepath.Join(basepath, keys[i].String()), mtime, mtime); err != nil {
+			t.Fatalf("chtimes failed. err: %v", err)
+		}
+	}
+	tmpPath := filepath.Join(basepath, "osm", "1", "0", "0.abc-tmp")
+	if err = os.WriteFile(tmpPath, []byte("partial"), 0666); err != nil {
+		t.Fatalf("write failed. err: %v", err)
+	}
+	mtime := time.Now().Add(-2 * time.Hour)
+	if err = os.Chtimes(tmpPath, mtime, mtime); err != nil {
+		t.Fatalf("chtimes failed. err: %v", err)
+	}
+	// a recent temp file may be a write in progress of another process
+	inProgressPath := filepath.Join(basepath, "osm", "1", "0", "1.def-tmp")
+	if err = os.WriteFile(inProgressPath, []byte("partial"), 0666); err != nil {
+		t.Fatalf("write failed. err: %v", err)
+	}
+
+	// reopening it bounded evicts the oldest tile right away
+	c, err = file.New(dict.Dict{"basepath": basepath, "max_size": 4, "janitor_interval": 3600})
+	if err != nil {
+		t.Fatalf("unexpected err, expected %v got %v", nil, err)
+	}
+	defer c.(*file.Cache).Close()
+
+	expectedHits := []bool{false, true}
+	for i := range keys {
+		_, hit, err := c.Get(ctx, &keys[i])
+		if err != nil {
+			t.Fatalf("read failed. err: %v", err)
+		}
+		if hit != expectedHits[i] {
+			t.Errorf("key %v, expected hit %t got %t", keys[i], expectedHits[i], hit)
+		}
+	}
+
+	if _, err = os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Errorf("expected the temp file to be removed, got err %v", err)
+	}
+	if _, err = os.Stat(inProgressPath); err != nil {
+		t.Errorf("expected the recent temp file to be kept, got err %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package file
+
+import (
+	"container/list"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spatial/tegola/internal/log"
+)
+
+// tmpSuffix is the suffix of the files tiles are written to before being renamed into place
+const tmpSuffix = "-tmp"
+
+// staleTmpAge is the age of the temp files removed by the startup scan. Younger temp
+// files may belong to writes in progress of other processes sharing the basepath.
+const staleTmpAge = time.Hour
+
+// eviction policies
+const (
+	// EvictionLRU evicts the least recently read or written tiles first
+	EvictionLRU = "lru"
+	// EvictionOldest evicts the least recently written tiles first
+	EvictionOldest = "oldest"
+)
+
+// indexEntry is a tile file tracked by the index
+type indexEntry struct {
+	// path relative to the basepath
+	path    string
+	size    int64
+	written time.Time
+}
+
+// index keeps track of the size of the stored tiles and the order in which
+// they should be evicted. It's only used when the cache is bounded.
+type index struct {
+	mu sync.Mutex
+	// order holds *indexEntry values, the back being evicted first
+	order   *list.List
+	entries map[string]*list.Element
+	size    int64
+	// lru moves tiles to the front when they are read
+	lru bool
+
+	// evict is signaled when a write puts the cache over its max size
+	evict chan struct{}
+	done  chan struct{}
+}
+
+func newIndex(lru bool) *index {
+	return &index{
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+		lru:     lru,
+		evict:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// add tracks a written tile, replacing the previous entry of the same path
+func (idx *index) add(path string, size int64, written time.Time) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if el, ok := idx.entries[path]; ok {
+		idx.size -= el.Value.(*indexEntry).size
+		idx.order.Remove(el)
+	}
+
+	idx.entries[path] = idx.order.PushFront(&indexEntry{path: path, size: size, written: written})
+	idx.size += size
+}
+
+// touch marks a tile as read
+func (idx *index) touch(path string) {
+	if !idx.lru {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if el, ok := idx.entries[path]; ok {
+		idx.order.MoveToFront(el)
+	}
+}
+
+func (idx *index) remove(path string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(path)
+}
+
+func (idx *index) removeLocked(path string) {
+	el, ok := idx.entries[path]
+	if !ok {
+		return
+	}
+	idx.size -= el.Value.(*indexEntry).size
+	idx.order.Remove(el)
+	delete(idx.entries, path)
+}
+
+// removeDir stops tracking the tiles under dir
+func (idx *index) removeDir(dir string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	prefix := dir + string(filepath.Separator)
+	for path := range idx.entries {
+		if strings.HasPrefix(path, prefix) {
+			idx.removeLocked(path)
+		}
+	}
+}
+
+// Size returns the number of bytes of the tracked tiles
+func (idx *index) Size() int64 {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return idx.size
+}
+
+// scan walks the basepath to rebuild the index. Leftover temp files of
+// interrupted writes older than staleTmpAge are removed.
+func (fc *Cache) scan() error {
+	var entries []*indexEntry
+	staleTmp := time.Now().Add(-staleTmpAge)
+
+	err := filepath.WalkDir(fc.Basepath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if strings.HasSuffix(d.Name(), tmpSuffix) {
+			if !info.ModTime().Before(staleTmp) {
+				return nil
+			}
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(fc.Basepath, path)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, &indexEntry{path: rel, size: info.Size(), written: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the access times are not reliable (i.e. noatime mounts), so the modification
+	// times are used to order the tiles for both policies
+	sort.Slice(entries, func(i, j int) bool { return entries[i].written.Before(entries[j].written) })
+	for _, e := range entries {
+		fc.index.add(e.path, e.size, e.written)
+	}
+
+	return nil
+}
+
+// janitor evicts tiles every interval, or as soon as a write puts the cache
+// over its max size, until Close is called.
+func (fc *Cache) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fc.index.done:
+			return
+		case <-ticker.C:
+		case <-fc.index.evict:
+		}
+
+		if err := fc.Evict(time.Now()); err != nil {
+			log.Errorf("filecache: eviction failed: %v", err)
+		}
+	}
+}
+
+// Evict removes the tiles written before now - MaxAge, then removes tiles in
+// eviction order until the cache is within MaxSize.
+func (fc *Cache) Evict(now time.Time) error {
+	if fc.index == nil {
+		return nil
+	}
+
+	victims := fc.index.evictions(now, fc.MaxAge, fc.MaxSize)
+	for _, path := range victims {
+		// the tile may have been written again since it was picked
+		if fc.index.has(path) {
+			continue
+		}
+
+		err := os.Remove(filepath.Join(fc.Basepath, path))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// evictions picks the tiles written before now - maxAge, then the tiles in eviction
+// order until the index is within maxSize, and stops tracking them. The files are
+// removed by the caller, so reads and writes don't wait for the disk while the
+// lock is held.
+func (idx *index) evictions(now time.Time, maxAge time.Duration, maxSize int64) []string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var victims []string
+	if maxAge > 0 {
+		deadline := now.Add(-maxAge)
+		for el := idx.order.Back(); el != nil; {
+			entry, prev := el.Value.(*indexEntry), el.Prev()
+			if entry.written.Before(deadline) {
+				victims = append(victims, entry.path)
+				idx.removeLocked(entry.path)
+			}
+			el = prev
+		}
+	}
+
+	for maxSize > 0 && idx.size > maxSize {
+		el := idx.order.Back()
+		if el == nil {
+			break
+		}
+		path := el.Value.(*indexEntry).path
+		victims = append(victims, path)
+		idx.removeLocked(path)
+	}
+
+	return victims
+}
+
+// has reports if the tile at path is tracked
+func (idx *index) has(path string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	_, ok := idx.entries[path]
+	return ok
+}
+
+// Close stops the janitor of a bounded cache
+func (fc *Cache) Close() error {
+	if fc.index == nil {
+		return nil
+	}
+
+	select {
+	case <-fc.index.done:
+	default:
+		close(fc.index.done)
+	}
+	return nil
+}
+
+// parseSize parses a number of bytes. Strings can use the KB, MB, GB and TB
+// suffixes, which are powers of 1024.
+func parseSize(v interface{}) (int64, error) {
+	switch s := v.(type) {
+	case int:
+		return int64(s), nil
+	case int64:
+		return s, nil
+	case uint:
+		return int64(s), nil
+	case uint64:
+		return int64(s), nil
+	case float64:
+		return int64(s), nil
+	case string:
+		str := strings.ToUpper(strings.TrimSpace(s))
+		multiplier := int64(1)
+		for _, unit := range []struct {
+			suffix string
+			size   int64
+		}{
+			{"TB", 1 << 40},
+			{"GB", 1 << 30},
+			{"MB", 1 << 20},
+			{"KB", 1 << 10},
+			{"B", 1},
+		} {
+			if strings.HasSuffix(str, unit.suffix) {
+				multiplier = unit.size
+				str = strings.TrimSpace(strings.TrimSuffix(str, unit.suffix))
+				break
+			}
+		}
+
+		n, err := strconv.ParseFloat(str, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("filecache: invalid size (%v)", s)
+		}
+		return int64(n * float64(multiplier)), nil
+	default:
+		return 0, fmt.Errorf("filecache: invalid size (%v)", v)
+	}
+}
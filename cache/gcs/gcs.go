@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"io"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/go-spatial/tegola"
 	"github.com/go-spatial/tegola/cache"
@@ -12,6 +14,7 @@ import (
 	"github.com/go-spatial/tegola/internal/log"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
 )
 
 const CacheType = "gcs"
@@ -188,3 +191,30 @@ func (gcsCache *GCSCache) Purge(ctx context.Context, key *cache.Key) error {
 
 	return nil
 }
+
+// List iterates the objects under the basepath and calls fn for each tile matching the filter.
+func (gcsCache *GCSCache) List(ctx context.Context, filter cache.ListFilter, fn func(cache.KeyInfo) error) error {
+	prefix := path.Join(gcsCache.Basepath, filter.Prefix())
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	it := gcsCache.Bucket.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		key, ok := cache.MatchStoredKey(strings.TrimPrefix(attrs.Name, gcsCache.Basepath), filter)
+		if !ok {
+			continue
+		}
+		if err = fn(cache.KeyInfo{Key: *key, Size: attrs.Size}); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrStopListing can be returned by the callback of Lister.List to stop
+// iterating early. List returns it as is so callers can check for it.
+var ErrStopListing = errors.New("cache: stop listing")
+
+// ListFilter narrows down the keys iterated by a Lister. Zero values match everything.
+type ListFilter struct {
+	MapName string
+	// Version is the key version of the map. Keys of other versions are not matched.
+	Version   string
+	LayerName string
+	// Zooms restricts the listing to the given zoom levels
+	Zooms []uint
+}
+
+// Prefix returns the longest key prefix shared by all the keys matching the filter.
+// The prefix uses forward slashes and, when not empty, ends with a slash.
+func (f ListFilter) Prefix() string {
+	var parts []string
+	for _, p := range []string{f.MapName, VersionSegment(f.Version), f.LayerName} {
+		if p == "" {
+			break
+		}
+		parts = append(parts, p)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, "/") + "/"
+}
+
+// Match reports if the key matches the filter
+func (f ListFilter) Match(key Key) bool {
+	if f.MapName != "" && key.MapName != f.MapName {
+		return false
+	}
+	if f.Version != key.Version {
+		return false
+	}
+	if f.LayerName != "" && key.LayerName != f.LayerName {
+		return false
+	}
+	if len(f.Zooms) == 0 {
+		return true
+	}
+	for _, z := range f.Zooms {
+		if z == key.Z {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyInfo describes a tile stored in a cache backend
+type KeyInfo struct {
+	Key Key
+	// Size of the stored tile in bytes
+	Size int64
+}
+
+// Lister is implemented by cache backends that are able to iterate the keys they hold.
+type Lister interface {
+	// List calls fn for every stored tile matching the filter. Stored objects that are
+	// not tiles are skipped. If fn returns an error the iteration is stopped and the
+	// error is returned.
+	List(ctx context.Context, filter ListFilter, fn func(KeyInfo) error) error
+}
+
+// ParseStoredKey parses a key as produced by Key.String. The version and params
+// segments are told apart from the layer segment by their prefixes.
+// Unlike ParseKey, it does not log keys it can't parse.
+func ParseStoredKey(str string) (*Key, error) {
+	str = strings.Trim(path.Clean("/"+strings.ReplaceAll(str, "\\", "/")), "/")
+	parts := strings.Split(str, "/")
+
+	var version string
+	if len(parts) >= 5 && strings.HasPrefix(parts[1], VersionKeyPrefix) {
+		version = strings.TrimPrefix(parts[1], VersionKeyPrefix)
+		parts = append(parts[:1:1], parts[2:]...)
+	}
+
+	var params string
+	if len(parts) >= 5 && strings.HasPrefix(parts[1], ParamsKeyPrefix) {
+		params = strings.TrimPrefix(parts[1], ParamsKeyPrefix)
+		parts = append(parts[:1:1], parts[2:]...)
+	}
+
+	key, err := parseKeyParts(str, parts)
+	if err != nil {
+		return nil, err
+	}
+	key.Version = version
+	key.Params = params
+	return key, nil
+}
+
+// MatchStoredKey parses str, as produced by Key.String, and reports if it matches the filter.
+func MatchStoredKey(str string, filter ListFilter) (*Key, bool) {
+	key, err := ParseStoredKey(str)
+	if err != nil {
+		return nil, false
+	}
+	return key, filter.Match(*key)
+}
+
+// ZoomStats holds the number of tiles and bytes stored for a zoom level
+type ZoomStats struct {
+	Zoom  uint
+	Tiles int64
+	Bytes int64
+}
+
+// MapStats holds the tile statistics of a map
+type MapStats struct {
+	MapName string
+	// Tiles, Bytes and Zooms count the tiles of the whole map, or of the layer of
+	// the filter, rendered without query parameters
+	Tiles int64
+	Bytes int64
+	// Zooms is sorted by zoom level
+	Zooms []ZoomStats
+	// Variants counts the other tiles of the map, which are the tiles of a single layer
+	// or rendered with query parameters. It's sorted by layer name and params.
+	Variants []VariantStats
+}
+
+// VariantStats holds the number of tiles and bytes stored for a layer of a map, or
+// for the tiles rendered with a set of query parameters
+type VariantStats struct {
+	LayerName string
+	Params    string
+	Tiles     int64
+	Bytes     int64
+}
+
+// Stats walks the keys of the lister matching the filter and reports the number of
+// tiles and bytes per map and zoom. The tiles of single layers and the tiles rendered
+// with query parameters are reported apart, as variants. The result is sorted by map name.
+func Stats(ctx context.Context, l Lister, filter ListFilter) ([]MapStats, error) {
+	type variantKey struct {
+		layerName, params string
+	}
+	type mapCounts struct {
+		zooms    map[uint]*ZoomStats
+		variants map[variantKey]*VariantStats
+	}
+	byMap := map[string]*mapCounts{}
+
+	err := l.List(ctx, filter, func(ki KeyInfo) error {
+		mc, ok := byMap[ki.Key.MapName]
+		if !ok {
+			mc = &mapCounts{
+				zooms:    map[uint]*ZoomStats{},
+				variants: map[variantKey]*VariantStats{},
+			}
+			byMap[ki.Key.MapName] = mc
+		}
+
+		if ki.Key.LayerName != filter.LayerName || ki.Key.Params != "" {
+			vk := variantKey{ki.Key.LayerName, ki.Key.Params}
+			vs, ok := mc.variants[vk]
+			if !ok {
+				vs = &VariantStats{LayerName: vk.layerName, Params: vk.params}
+				mc.variants[vk] = vs
+			}
+			vs.Tiles++
+			vs.Bytes += ki.Size
+			return nil
+		}
+
+		zs, ok := mc.zooms[ki.Key.Z]
+		if !ok {
+			zs = &ZoomStats{Zoom: ki.Key.Z}
+			mc.zooms[ki.Key.Z] = zs
+		}
+		zs.Tiles++
+		zs.Bytes += ki.Size
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrStopListing) {
+		return nil, err
+	}
+
+	stats := make([]MapStats, 0, len(byMap))
+	for name, mc := range byMap {
+		ms := MapStats{MapName: name}
+		for _, zs := range mc.zooms {
+			ms.Tiles += zs.Tiles
+			ms.Bytes += zs.Bytes
+			ms.Zooms = append(ms.Zooms, *zs)
+		}
+		sort.Slice(ms.Zooms, func(i, j int) bool { return ms.Zooms[i].Zoom < ms.Zooms[j].Zoom })
+
+		for _, vs := range mc.variants {
+			ms.Variants = append(ms.Variants, *vs)
+		}
+		sort.Slice(ms.Variants, func(i, j int) bool {
+			if ms.Variants[i].LayerName != ms.Variants[j].LayerName {
+				return ms.Variants[i].LayerName < ms.Variants[j].LayerName
+			}
+			return ms.Variants[i].Params < ms.Variants[j].Params
+		})
+
+		stats = append(stats, ms)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].MapName < stats[j].MapName })
+
+	return stats, nil
+}
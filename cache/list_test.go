@@ -0,0 +1,138 @@
+package cache_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-spatial/tegola/cache"
+)
+
+func TestListFilterPrefix(t *testing.T) {
+	type tcase struct {
+		filter   cache.ListFilter
+		expected string
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			if got := tc.filter.Prefix(); got != tc.expected {
+				t.Errorf("expected %q got %q", tc.expected, got)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"empty": {
+			filter:   cache.ListFilter{},
+			expected: "",
+		},
+		"map": {
+			filter:   cache.ListFilter{MapName: "osm"},
+			expected: "osm/",
+		},
+		"map version layer": {
+			filter:   cache.ListFilter{MapName: "osm", Version: "v1", LayerName: "roads"},
+			expected: "osm/~v1/roads/",
+		},
+		"layer without version": {
+			filter:   cache.ListFilter{MapName: "osm", LayerName: "roads"},
+			expected: "osm/",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestMatchStoredKey(t *testing.T) {
+	type tcase struct {
+		key      string
+		filter   cache.ListFilter
+		expected *cache.Key
+		match    bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			key, ok := cache.MatchStoredKey(tc.key, tc.filter)
+			if ok != tc.match {
+				t.Errorf("match, expected %t got %t", tc.match, ok)
+				return
+			}
+			if tc.expected != nil && !reflect.DeepEqual(key, tc.expected) {
+				t.Errorf("key, expected %+v got %+v", tc.expected, key)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"unversioned map": {
+			key:      "osm/2/1/3",
+			filter:   cache.ListFilter{MapName: "osm"},
+			expected: &cache.Key{MapName: "osm", Z: 2, X: 1, Y: 3},
+			match:    true,
+		},
+		"versioned layer": {
+			key:      "osm/~v1/roads/2/1/3",
+			filter:   cache.ListFilter{MapName: "osm", Version: "v1"},
+			expected: &cache.Key{MapName: "osm", Version: "v1", LayerName: "roads", Z: 2, X: 1, Y: 3},
+			match:    true,
+		},
+		"versioned map": {
+			key:      "osm/~v1/2/1/3",
+			filter:   cache.ListFilter{MapName: "osm", Version: "v1"},
+			expected: &cache.Key{MapName: "osm", Version: "v1", Z: 2, X: 1, Y: 3},
+			match:    true,
+		},
+		"params": {
+			key:      "osm/@f00d/2/1/3",
+			filter:   cache.ListFilter{MapName: "osm"},
+			expected: &cache.Key{MapName: "osm", Params: "f00d", Z: 2, X: 1, Y: 3},
+			match:    true,
+		},
+		"versioned params layer": {
+			key:      "osm/~v1/@f00d/roads/2/1/3",
+			filter:   cache.ListFilter{MapName: "osm", Version: "v1"},
+			expected: &cache.Key{MapName: "osm", Version: "v1", Params: "f00d", LayerName: "roads", Z: 2, X: 1, Y: 3},
+			match:    true,
+		},
+		"versioned params map": {
+			key:      "osm/~v1/@f00d/2/1/3",
+			filter:   cache.ListFilter{MapName: "osm", Version: "v1"},
+			expected: &cache.Key{MapName: "osm", Version: "v1", Params: "f00d", Z: 2, X: 1, Y: 3},
+			match:    true,
+		},
+		"versioned key without version filter": {
+			key:      "osm/~v1/roads/2/1/3",
+			filter:   cache.ListFilter{MapName: "osm"},
+			expected: &cache.Key{MapName: "osm", Version: "v1", LayerName: "roads", Z: 2, X: 1, Y: 3},
+		},
+		"layer named as the version": {
+			key:      "osm/v1/2/1/3",
+			filter:   cache.ListFilter{MapName: "osm", LayerName: "v1"},
+			expected: &cache.Key{MapName: "osm", LayerName: "v1", Z: 2, X: 1, Y: 3},
+			match:    true,
+		},
+		"other version": {
+			key:    "osm/~v0/2/1/3",
+			filter: cache.ListFilter{MapName: "osm", Version: "v1"},
+		},
+		"unversioned key with version filter": {
+			key:    "osm/roads/2/1/3",
+			filter: cache.ListFilter{MapName: "osm", Version: "v1"},
+		},
+		"zoom filter": {
+			key:    "osm/4/2/3",
+			filter: cache.ListFilter{Zooms: []uint{1, 2, 3}},
+		},
+		"not a tile": {
+			key:    "osm/1/2/tile.tmp",
+			filter: cache.ListFilter{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
@@ -0,0 +1,44 @@
+// Package mbtiles implements a cache backend which stores the tiles of each map
+// in a MBTiles (SQLite) file, so the cache can be used directly as an offline tileset.
+package mbtiles
+
+import (
+	"errors"
+	"fmt"
+)
+
+const CacheType = "mbtiles"
+
+const (
+	ConfigKeyBasepath      = "basepath"
+	ConfigKeyMaxZoom       = "max_zoom"
+	ConfigKeyBatchSize     = "batch_size"
+	ConfigKeyBatchInterval = "batch_interval"
+)
+
+const (
+	// DefaultBatchSize is the default number of tiles written in a single transaction
+	DefaultBatchSize = 100
+	// DefaultBatchInterval is the default max number of milliseconds a tile is buffered
+	DefaultBatchInterval = 1000
+	// FileExtension of the tileset files
+	FileExtension = ".mbtiles"
+	// VersionSeparator separates the map name and version in the file name of versioned tilesets
+	VersionSeparator = "@"
+)
+
+var (
+	ErrMissingBasepath  = errors.New("mbtilescache: missing required param 'basepath'")
+	ErrInvalidBatchSize = errors.New("mbtilescache: 'batch_size' must be greater than 0")
+	ErrUnsupported      = errors.New("mbtilescache: the mbtiles cache requires tegola to be built with cgo")
+)
+
+// ErrTilesetPurging is returned when reading or writing the tiles of a file which is
+// being removed by PurgeGenerations
+type ErrTilesetPurging struct {
+	Name string
+}
+
+func (e ErrTilesetPurging) Error() string {
+	return fmt.Sprintf("mbtilescache: tileset (%v) is being purged", e.Name)
+}
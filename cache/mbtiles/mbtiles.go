@@ -0,0 +1,678 @@
+//go:build cgo
+// +build cgo
+
+package mbtiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/maths"
+)
+
+func init() {
+	cache.Register(CacheType, New)
+}
+
+// New instantiates a Cache. The config expects the following params:
+//
+//	basepath (string): a path to the directory the .mbtiles files will be written to
+//	max_zoom (int): max zoom to use the cache. beyond this zoom cache Set() calls will be ignored
+//	batch_size (int): the number of tiles to buffer before they are written in a single transaction
+//	batch_interval (int): the max number of milliseconds a tile is buffered before it's written
+func New(config dict.Dicter) (cache.Interface, error) {
+	var err error
+
+	mbc := Cache{
+		files:   map[string]*tileset{},
+		purging: map[string]struct{}{},
+	}
+
+	mbc.Basepath, err = config.String(ConfigKeyBasepath, nil)
+	if err != nil || mbc.Basepath == "" {
+		return nil, ErrMissingBasepath
+	}
+
+	defaultMaxZoom := uint(tegola.MaxZ)
+	mbc.MaxZoom, err = config.Uint(ConfigKeyMaxZoom, &defaultMaxZoom)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultBatchSize := DefaultBatchSize
+	mbc.BatchSize, err = config.Int(ConfigKeyBatchSize, &defaultBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if mbc.BatchSize < 1 {
+		return nil, ErrInvalidBatchSize
+	}
+
+	defaultBatchInterval := DefaultBatchInterval
+	batchInterval, err := config.Int(ConfigKeyBatchInterval, &defaultBatchInterval)
+	if err != nil {
+		return nil, err
+	}
+	mbc.BatchInterval = time.Duration(batchInterval) * time.Millisecond
+
+	// make our basepath if it does not exist
+	if err = os.MkdirAll(mbc.Basepath, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	if mbc.BatchSize > 1 && mbc.BatchInterval > 0 {
+		go mbc.flushLoop()
+	}
+
+	return &mbc, nil
+}
+
+// Cache stores the tiles of each map in a MBTiles file named after the map
+type Cache struct {
+	// Basepath is the directory the MBTiles files are written to
+	Basepath string
+	// MaxZoom determines the max zoom the cache to persist. Beyond this
+	// zoom, cache Set() calls will be ignored.
+	MaxZoom uint
+	// BatchSize is the number of tiles buffered before they are written to
+	// the file in a single transaction
+	BatchSize int
+	// BatchInterval is the max duration a tile is buffered before being written
+	BatchInterval time.Duration
+
+	// guards files and purging
+	sync.Mutex
+	files map[string]*tileset
+	// purging holds the names of the files being removed, which can't be opened
+	purging map[string]struct{}
+}
+
+// tileset is a single MBTiles file
+type tileset struct {
+	path string
+	db   *sql.DB
+	// users tracks the callers of open using db, which is closed once they are done
+	// when the tileset is purged
+	users sync.WaitGroup
+
+	// serializes the writes of batches and purges, so a tile purged while a batch
+	// is written is not written back
+	writeMu sync.Mutex
+
+	// guards pending and writing
+	sync.Mutex
+	// pending holds tiles that have not been written yet, keyed by tileID
+	pending map[tileID][]byte
+	// writing holds the tiles of the batch being written, which are read from
+	// the buffer until the batch is committed
+	writing map[tileID][]byte
+}
+
+// release marks the caller of open as done with the tileset
+func (ts *tileset) release() {
+	ts.users.Done()
+}
+
+type tileID struct {
+	z, x, y uint
+}
+
+// fileName returns the name of the MBTiles file that stores the tiles of a map version
+func fileName(mapName, version string) string {
+	if version == "" {
+		return mapName + FileExtension
+	}
+	return mapName + VersionSeparator + version + FileExtension
+}
+
+// emptyTileData is an empty mvt tile, gzipped as the encoded tiles are. It's stored in place
+// of the cache.EmptyTile sentinel, which is not valid tile data, so the file remains a valid
+// tileset. The tiles stored as the sentinel are recorded in the tegola_empty_tiles table, as
+// a rendered tile can have the same data.
+var emptyTileData = func() []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Close()
+	return buf.Bytes()
+}()
+
+// tmsRow flips the y value of a XYZ tile to the TMS row used by MBTiles
+func tmsRow(z, y uint) uint {
+	return uint(maths.Exp2(uint64(z))) - 1 - y
+}
+
+// errNoTileset is returned by open when the file of the tileset does not exist and is not created
+var errNoTileset = errors.New("tileset does not exist")
+
+// open returns the tileset for the map version. The file and schema are created if needed
+// when create is set, otherwise errNoTileset is returned for a missing file, so reads
+// don't leave files behind for maps that were never cached.
+// The caller is expected to release the tileset once done with it.
+func (mbc *Cache) open(mapName, version string, create bool) (*tileset, error) {
+	name := fileName(mapName, version)
+
+	mbc.Lock()
+	defer mbc.Unlock()
+
+	if ts, ok := mbc.files[name]; ok {
+		ts.users.Add(1)
+		return ts, nil
+	}
+	if _, ok := mbc.purging[name]; ok {
+		return nil, ErrTilesetPurging{Name: name}
+	}
+
+	path := filepath.Join(mbc.Basepath, name)
+	if !create {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, errNoTileset
+		}
+	}
+
+	// WAL mode allows readers to continue while a batch is being written
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL", path))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stmt := range append(schema, cacheSchema...) {
+		if _, err = db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error creating mbtiles schema in (%v): %w", path, err)
+		}
+	}
+
+	ts := &tileset{
+		path:    path,
+		db:      db,
+		pending: map[tileID][]byte{},
+	}
+	ts.users.Add(1)
+	mbc.files[name] = ts
+
+	return ts, nil
+}
+
+// isTilesetKey reports if the key addresses a map tile. Layer tiles and tiles rendered
+// with query parameters are not part of a tileset and are not cached.
+func isTilesetKey(key *cache.Key) bool {
+	return key.MapName != "" && key.LayerName == "" && key.Params == ""
+}
+
+// Get reads a z,x,y entry from the cache and returns the contents
+// if there is a hit. the second argument denotes a hit or miss
+func (mbc *Cache) Get(ctx context.Context, key *cache.Key) ([]byte, bool, error) {
+	if !isTilesetKey(key) {
+		return nil, false, nil
+	}
+
+	ts, err := mbc.open(key.MapName, key.Version, false)
+	if errors.Is(err, errNoTileset) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer ts.release()
+
+	id := tileID{key.Z, key.X, key.Y}
+	ts.Lock()
+	val, ok := ts.pending[id]
+	if !ok {
+		val, ok = ts.writing[id]
+	}
+	ts.Unlock()
+	if ok {
+		return val, true, nil
+	}
+
+	var empty bool
+	err = ts.db.QueryRowContext(ctx,
+		`SELECT t.tile_data, EXISTS (
+			SELECT 1 FROM tegola_empty_tiles e
+			WHERE e.zoom_level = t.zoom_level AND e.tile_column = t.tile_column AND e.tile_row = t.tile_row
+		) FROM tiles t WHERE t.zoom_level = ? AND t.tile_column = ? AND t.tile_row = ?`,
+		key.Z, key.X, tmsRow(key.Z, key.Y),
+	).Scan(&val, &empty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	}
+
+	if empty {
+		return cache.EmptyTile, true, nil
+	}
+	return val, true, nil
+}
+
+// Set buffers the tile and writes the buffer to the file once BatchSize tiles are pending
+func (mbc *Cache) Set(ctx context.Context, key *cache.Key, val []byte) error {
+	// check for maxzoom
+	if key.Z > mbc.MaxZoom || !isTilesetKey(key) {
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ts, err := mbc.open(key.MapName, key.Version, true)
+	if err != nil {
+		return err
+	}
+	defer ts.release()
+
+	ts.Lock()
+	ts.pending[tileID{key.Z, key.X, key.Y}] = val
+	pending := len(ts.pending)
+	ts.Unlock()
+
+	if pending < mbc.BatchSize {
+		return nil
+	}
+
+	return ts.flush(ctx)
+}
+
+// Purge removes a tile from the file and from the buffer
+func (mbc *Cache) Purge(ctx context.Context, key *cache.Key) error {
+	if !isTilesetKey(key) {
+		return nil
+	}
+
+	ts, err := mbc.open(key.MapName, key.Version, false)
+	if errors.Is(err, errNoTileset) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer ts.release()
+
+	ts.writeMu.Lock()
+	defer ts.writeMu.Unlock()
+
+	ts.Lock()
+	delete(ts.pending, tileID{key.Z, key.X, key.Y})
+	ts.Unlock()
+
+	for _, table := range []string{"tiles", "tegola_empty_tiles"} {
+		_, err = ts.db.ExecContext(ctx,
+			"DELETE FROM "+table+" WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?",
+			key.Z, key.X, tmsRow(key.Z, key.Y),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes all buffered tiles to their files
+func (mbc *Cache) Flush(ctx context.Context) error {
+	mbc.Lock()
+	tilesets := make([]*tileset, 0, len(mbc.files))
+	for _, ts := range mbc.files {
+		ts.users.Add(1)
+		tilesets = append(tilesets, ts)
+	}
+	mbc.Unlock()
+
+	var err error
+	for _, ts := range tilesets {
+		if err == nil {
+			err = ts.flush(ctx)
+		}
+		ts.release()
+	}
+	return err
+}
+
+// flushLoop periodically flushes the buffered tiles so a tile is not held back
+// longer than BatchInterval when writes are slow.
+func (mbc *Cache) flushLoop() {
+	ticker := time.NewTicker(mbc.BatchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := mbc.Flush(context.Background()); err != nil {
+			log.Errorf("mbtiles cache: error flushing tiles: %v", err)
+		}
+	}
+}
+
+// flush writes the pending tiles in a single transaction. The tiles are moved out of
+// the buffer while they are written, so the lock is not held during the transaction.
+func (ts *tileset) flush(ctx context.Context) error {
+	ts.writeMu.Lock()
+	defer ts.writeMu.Unlock()
+
+	ts.Lock()
+	batch := ts.pending
+	if len(batch) == 0 {
+		ts.Unlock()
+		return nil
+	}
+	ts.pending = map[tileID][]byte{}
+	ts.writing = batch
+	ts.Unlock()
+
+	err := ts.write(ctx, batch)
+
+	ts.Lock()
+	ts.writing = nil
+	if err != nil {
+		// put the batch back, tiles set while it was written are newer
+		for id, val := range batch {
+			if _, ok := ts.pending[id]; !ok {
+				ts.pending[id] = val
+			}
+		}
+	}
+	ts.Unlock()
+
+	return err
+}
+
+// write writes the tiles in a single transaction
+func (ts *tileset) write(ctx context.Context, tiles map[tileID][]byte) error {
+	tx, err := ts.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT OR REPLACE INTO tiles (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	setEmpty, err := tx.PrepareContext(ctx, "INSERT OR REPLACE INTO tegola_empty_tiles (zoom_level, tile_column, tile_row) VALUES (?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer setEmpty.Close()
+
+	unsetEmpty, err := tx.PrepareContext(ctx, "DELETE FROM tegola_empty_tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer unsetEmpty.Close()
+
+	for id, val := range tiles {
+		row := tmsRow(id.z, id.y)
+
+		mark := unsetEmpty
+		if cache.IsEmptyTile(val) {
+			val, mark = emptyTileData, setEmpty
+		}
+		if _, err = stmt.ExecContext(ctx, id.z, id.x, row, val); err == nil {
+			_, err = mark.ExecContext(ctx, id.z, id.x, row)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error writing tile (%v/%v/%v) to (%v): %w", id.z, id.x, id.y, ts.path, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// List calls fn for each tile matching the filter. Buffered tiles are written before
+// listing so they are included.
+func (mbc *Cache) List(ctx context.Context, filter cache.ListFilter, fn func(cache.KeyInfo) error) error {
+	// layer tiles are never stored
+	if filter.LayerName != "" {
+		return nil
+	}
+
+	if err := mbc.Flush(ctx); err != nil {
+		return err
+	}
+
+	pattern := "*" + FileExtension
+	if filter.MapName != "" {
+		pattern = fileName(filter.MapName, filter.Version)
+	}
+	matches, err := filepath.Glob(filepath.Join(mbc.Basepath, pattern))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range matches {
+		mapName, version := strings.TrimSuffix(filepath.Base(path), FileExtension), ""
+		if i := strings.Index(mapName, VersionSeparator); i != -1 {
+			mapName, version = mapName[:i], mapName[i+len(VersionSeparator):]
+		}
+		if version != filter.Version {
+			continue
+		}
+
+		ts, err := mbc.open(mapName, version, false)
+		if errors.Is(err, errNoTileset) {
+			// removed since the glob
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = ts.list(ctx, mapName, version, filter, fn)
+		ts.release()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// list calls fn for each tile of the file matching the filter
+func (ts *tileset) list(ctx context.Context, mapName, version string, filter cache.ListFilter, fn func(cache.KeyInfo) error) error {
+	rows, err := ts.db.QueryContext(ctx, "SELECT zoom_level, tile_column, tile_row, length(tile_data) FROM tiles")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key  = cache.Key{MapName: mapName, Version: version}
+			row  uint
+			size int64
+		)
+		if err = rows.Scan(&key.Z, &key.X, &row, &size); err != nil {
+			return err
+		}
+		key.Y = tmsRow(key.Z, row)
+
+		if !filter.Match(key) {
+			continue
+		}
+		if err = fn(cache.KeyInfo{Key: key, Size: size}); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// vectorLayer is an entry of the vector_layers metadata
+type vectorLayer struct {
+	ID          string            `json:"id"`
+	Description string            `json:"description"`
+	MinZoom     uint              `json:"minzoom"`
+	MaxZoom     uint              `json:"maxzoom"`
+	Fields      map[string]string `json:"fields"`
+}
+
+// DescribeTileset writes the metadata table of the map's file
+func (mbc *Cache) DescribeTileset(ctx context.Context, desc cache.Tileset) error {
+	ts, err := mbc.open(desc.Name, desc.Version, true)
+	if err != nil {
+		return err
+	}
+	defer ts.release()
+
+	return writeMetadata(ctx, ts.db, desc)
+}
+
+// writeMetadata writes the description of the tileset to the metadata table of db
+func writeMetadata(ctx context.Context, db *sql.DB, desc cache.Tileset) error {
+	layers := make([]vectorLayer, 0, len(desc.Layers))
+	for _, l := range desc.Layers {
+		fields := l.Fields
+		if fields == nil {
+			fields = map[string]string{}
+		}
+		layers = append(layers, vectorLayer{
+			ID:      l.ID,
+			MinZoom: l.MinZoom,
+			MaxZoom: l.MaxZoom,
+			Fields:  fields,
+		})
+	}
+	layersJSON, err := json.Marshal(struct {
+		VectorLayers []vectorLayer `json:"vector_layers"`
+	}{layers})
+	if err != nil {
+		return err
+	}
+
+	formatFloats := func(vals ...float64) string {
+		strs := make([]string, len(vals))
+		for i, v := range vals {
+			strs[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return strings.Join(strs, ",")
+	}
+
+	metadata := [][2]string{
+		{"name", desc.Name},
+		{"format", "pbf"},
+		{"type", "overlay"},
+		{"scheme", "tms"},
+		{"bounds", formatFloats(desc.Bounds[:]...)},
+		{"center", formatFloats(desc.Center[0], desc.Center[1]) + "," + strconv.Itoa(int(desc.Center[2]))},
+		{"minzoom", strconv.FormatUint(uint64(desc.MinZoom), 10)},
+		{"maxzoom", strconv.FormatUint(uint64(desc.MaxZoom), 10)},
+		{"attribution", desc.Attribution},
+		{"json", string(layersJSON)},
+	}
+	if desc.Version != "" {
+		metadata = append(metadata, [2]string{"version", desc.Version})
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, md := range metadata {
+		if _, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO metadata (name, value) VALUES (?, ?)", md[0], md[1]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PurgeGenerations removes the files of mapName written under another version than
+// keepVersion, and the file written without a version when unversioned is set.
+func (mbc *Cache) PurgeGenerations(ctx context.Context, mapName, keepVersion string, unversioned bool) error {
+	if keepVersion == "" || mapName == "" {
+		return nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(mbc.Basepath, mapName+VersionSeparator+"*"+FileExtension))
+	if err != nil {
+		return err
+	}
+	if unversioned {
+		matches = append(matches, filepath.Join(mbc.Basepath, fileName(mapName, "")))
+	}
+
+	keep := fileName(mapName, keepVersion)
+	for _, path := range matches {
+		name := filepath.Base(path)
+		if name == keep {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := mbc.removeFile(name, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeFile removes the file of a tileset. The tileset can't be opened while it's
+// removed, and its db is closed once the callers still using it are done.
+func (mbc *Cache) removeFile(name, path string) error {
+	mbc.Lock()
+	ts, ok := mbc.files[name]
+	delete(mbc.files, name)
+	mbc.purging[name] = struct{}{}
+	mbc.Unlock()
+
+	defer func() {
+		mbc.Lock()
+		delete(mbc.purging, name)
+		mbc.Unlock()
+	}()
+
+	if ok {
+		ts.users.Wait()
+		ts.db.Close()
+	}
+
+	// remove the database along with its WAL files
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Remove(path + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// schema is the standard MBTiles schema
+var schema = []string{
+	"CREATE TABLE IF NOT EXISTS metadata (name TEXT, value TEXT)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS metadata_name ON metadata (name)",
+	"CREATE TABLE IF NOT EXISTS tiles (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_data BLOB)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS tile_index ON tiles (zoom_level, tile_column, tile_row)",
+}
+
+// cacheSchema holds the tables the cache adds to the standard schema. Other readers of
+// the file ignore them.
+var cacheSchema = []string{
+	// the tiles stored in place of the cache.EmptyTile sentinel
+	"CREATE TABLE IF NOT EXISTS tegola_empty_tiles (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS tegola_empty_tiles_index ON tegola_empty_tiles (zoom_level, tile_column, tile_row)",
+}
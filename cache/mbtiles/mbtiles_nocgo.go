@@ -0,0 +1,43 @@
+//go:build !cgo
+// +build !cgo
+
+package mbtiles
+
+import (
+	"context"
+
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/dict"
+)
+
+func init() {
+	cache.Register(CacheType, New)
+}
+
+// New reports the mbtiles cache is not available, as SQLite requires cgo
+func New(_ dict.Dicter) (cache.Interface, error) {
+	return nil, ErrUnsupported
+}
+
+// Writer is not available, as SQLite requires cgo
+type Writer struct{}
+
+// NewWriter reports the mbtiles writer is not available, as SQLite requires cgo
+func NewWriter(_ string) (*Writer, error) {
+	return nil, ErrUnsupported
+}
+
+// WriteTile is not available, as SQLite requires cgo
+func (w *Writer) WriteTile(_ context.Context, _, _, _ uint, _ []byte) error {
+	return ErrUnsupported
+}
+
+// Close is not available, as SQLite requires cgo
+func (w *Writer) Close(_ context.Context, _ cache.Tileset) error {
+	return ErrUnsupported
+}
+
+// Abort is not available, as SQLite requires cgo
+func (w *Writer) Abort() error {
+	return ErrUnsupported
+}
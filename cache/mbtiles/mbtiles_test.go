@@ -0,0 +1,416 @@
+//go:build cgo
+// +build cgo
+
+package mbtiles_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/cache/mbtiles"
+	"github.com/go-spatial/tegola/dict"
+)
+
+func newCache(t *testing.T, batchSize int) *mbtiles.Cache {
+	t.Helper()
+
+	c, err := mbtiles.New(dict.Dict{
+		mbtiles.ConfigKeyBasepath:      t.TempDir(),
+		mbtiles.ConfigKeyBatchSize:     batchSize,
+		mbtiles.ConfigKeyBatchInterval: 0,
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	return c.(*mbtiles.Cache)
+}
+
+func TestNew(t *testing.T) {
+	type tcase struct {
+		config dict.Dict
+		err    error
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			_, err := mbtiles.New(tc.config)
+			if err != tc.err {
+				t.Errorf("expected err %v got %v", tc.err, err)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"missing basepath": {
+			config: dict.Dict{},
+			err:    mbtiles.ErrMissingBasepath,
+		},
+		"invalid batch size": {
+			config: dict.Dict{
+				"basepath":   t.TempDir(),
+				"batch_size": 0,
+			},
+			err: mbtiles.ErrInvalidBatchSize,
+		},
+		"valid": {
+			config: dict.Dict{
+				"basepath": t.TempDir(),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestSetGetPurge(t *testing.T) {
+	ctx := context.Background()
+
+	type tcase struct {
+		batchSize int
+		key       cache.Key
+		hit       bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			mbc := newCache(t, tc.batchSize)
+			data := []byte("\x53\x69\x6c\x61\x73")
+
+			if err := mbc.Set(ctx, &tc.key, data); err != nil {
+				t.Fatalf("set failed with err: %v", err)
+			}
+
+			val, hit, err := mbc.Get(ctx, &tc.key)
+			if err != nil {
+				t.Fatalf("get failed with err: %v", err)
+			}
+			if hit != tc.hit {
+				t.Fatalf("expected hit %v got %v", tc.hit, hit)
+			}
+			if !tc.hit {
+				return
+			}
+			if !reflect.DeepEqual(val, data) {
+				t.Fatalf("expected %v got %v", data, val)
+			}
+
+			if err = mbc.Purge(ctx, &tc.key); err != nil {
+				t.Fatalf("purge failed with err: %v", err)
+			}
+			if _, hit, _ = mbc.Get(ctx, &tc.key); hit {
+				t.Errorf("expected a miss after purge")
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"write through": {
+			batchSize: 1,
+			key:       cache.Key{MapName: "osm", Z: 3, X: 1, Y: 2},
+			hit:       true,
+		},
+		"buffered": {
+			batchSize: 10,
+			key:       cache.Key{MapName: "osm", Z: 3, X: 1, Y: 2},
+			hit:       true,
+		},
+		"layer tiles are not cached": {
+			batchSize: 1,
+			key:       cache.Key{MapName: "osm", LayerName: "roads", Z: 3, X: 1, Y: 2},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestTMSRowAndMetadata(t *testing.T) {
+	ctx := context.Background()
+	mbc := newCache(t, 10)
+
+	key := cache.Key{MapName: "osm", Z: 3, X: 1, Y: 2}
+	if err := mbc.Set(ctx, &key, []byte("tile")); err != nil {
+		t.Fatalf("set failed with err: %v", err)
+	}
+	if err := mbc.DescribeTileset(ctx, cache.Tileset{
+		Name:    "osm",
+		Bounds:  [4]float64{-10, -20, 10, 20},
+		Center:  [3]float64{1.5, 2.5, 3},
+		MinZoom: 0,
+		MaxZoom: 14,
+		Layers: []cache.TilesetLayer{
+			{ID: "roads", MinZoom: 6, MaxZoom: 14},
+		},
+	}); err != nil {
+		t.Fatalf("describe tileset failed with err: %v", err)
+	}
+	if err := mbc.Flush(ctx); err != nil {
+		t.Fatalf("flush failed with err: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(mbc.Basepath, "osm.mbtiles"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer db.Close()
+
+	var row uint
+	if err = db.QueryRow("SELECT tile_row FROM tiles WHERE zoom_level = 3 AND tile_column = 1").Scan(&row); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// 2^3 - 1 - 2
+	if row != 5 {
+		t.Errorf("tile_row, expected %v got %v", 5, row)
+	}
+
+	metadata := map[string]string{}
+	rows, err := db.Query("SELECT name, value FROM metadata")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, value string
+		if err = rows.Scan(&name, &value); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		metadata[name] = value
+	}
+
+	for name, expected := range map[string]string{
+		"name":    "osm",
+		"format":  "pbf",
+		"bounds":  "-10,-20,10,20",
+		"center":  "1.5,2.5,3",
+		"minzoom": "0",
+		"maxzoom": "14",
+	} {
+		if metadata[name] != expected {
+			t.Errorf("metadata %v, expected %v got %v", name, expected, metadata[name])
+		}
+	}
+
+	var layers struct {
+		VectorLayers []struct {
+			ID      string `json:"id"`
+			MinZoom uint   `json:"minzoom"`
+		} `json:"vector_layers"`
+	}
+	if err = json.Unmarshal([]byte(metadata["json"]), &layers); err != nil {
+		t.Fatalf("unexpected err decoding json metadata: %v", err)
+	}
+	if len(layers.VectorLayers) != 1 || layers.VectorLayers[0].ID != "roads" || layers.VectorLayers[0].MinZoom != 6 {
+		t.Errorf("unexpected vector_layers %+v", layers.VectorLayers)
+	}
+}
+
+func TestPurgeGenerations(t *testing.T) {
+	ctx := context.Background()
+	mbc := newCache(t, 1)
+
+	for _, version := range []string{"", "v1", "v2"} {
+		key := cache.Key{MapName: "osm", Version: version, Z: 1}
+		if err := mbc.Set(ctx, &key, []byte("tile")); err != nil {
+			t.Fatalf("set failed with err: %v", err)
+		}
+	}
+
+	if err := mbc.PurgeGenerations(ctx, "osm", "v2", false); err != nil {
+		t.Fatalf("purge generations failed with err: %v", err)
+	}
+
+	for name, exists := range map[string]bool{
+		"osm.mbtiles":    true,
+		"osm@v1.mbtiles": false,
+		"osm@v2.mbtiles": true,
+	} {
+		_, err := os.Stat(filepath.Join(mbc.Basepath, name))
+		if exists != (err == nil) {
+			t.Errorf("%v: expected exists %v, got stat err %v", name, exists, err)
+		}
+	}
+
+	if err := mbc.PurgeGenerations(ctx, "osm", "v2", true); err != nil {
+		t.Fatalf("purge generations failed with err: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(mbc.Basepath, "osm.mbtiles")); !os.IsNotExist(err) {
+		t.Errorf("expected the unversioned file to be removed, got stat err %v", err)
+	}
+}
+
+func TestEmptyTile(t *testing.T) {
+	ctx := context.Background()
+	mbc := newCache(t, 1)
+
+	key := cache.Key{MapName: "osm", Z: 3, X: 1, Y: 2}
+	if err := mbc.Set(ctx, &key, cache.EmptyTile); err != nil {
+		t.Fatalf("set failed with err: %v", err)
+	}
+
+	val, hit, err := mbc.Get(ctx, &key)
+	if err != nil {
+		t.Fatalf("get failed with err: %v", err)
+	}
+	if !hit || !cache.IsEmptyTile(val) {
+		t.Errorf("expected the empty tile sentinel got hit %v val %v", hit, val)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(mbc.Basepath, "osm.mbtiles"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer db.Close()
+
+	var data []byte
+	if err = db.QueryRow("SELECT tile_data FROM tiles WHERE zoom_level = 3 AND tile_column = 1").Scan(&data); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	// the file holds a valid empty tile
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected gzipped tile data, got err: %v", err)
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(raw) != 0 {
+		t.Errorf("expected an empty tile got %v bytes", len(raw))
+	}
+}
+
+func TestEmptyTileData(t *testing.T) {
+	ctx := context.Background()
+	mbc := newCache(t, 1)
+
+	// a rendered tile without layers has the same data as the stored sentinel
+	var buf bytes.Buffer
+	gzip.NewWriter(&buf).Close()
+	data := buf.Bytes()
+
+	key := cache.Key{MapName: "osm", Z: 3, X: 1, Y: 2}
+	if err := mbc.Set(ctx, &key, cache.EmptyTile); err != nil {
+		t.Fatalf("set failed with err: %v", err)
+	}
+	if err := mbc.Set(ctx, &key, data); err != nil {
+		t.Fatalf("set failed with err: %v", err)
+	}
+
+	val, hit, err := mbc.Get(ctx, &key)
+	if err != nil {
+		t.Fatalf("get failed with err: %v", err)
+	}
+	if !hit || !reflect.DeepEqual(val, data) {
+		t.Errorf("expected the tile data %v got hit %v val %v", data, hit, val)
+	}
+}
+
+func TestGetMissingTileset(t *testing.T) {
+	ctx := context.Background()
+	mbc := newCache(t, 1)
+
+	key := cache.Key{MapName: "unknown", Z: 0}
+	_, hit, err := mbc.Get(ctx, &key)
+	if err != nil {
+		t.Fatalf("get failed with err: %v", err)
+	}
+	if hit {
+		t.Errorf("expected a miss")
+	}
+	if err = mbc.Purge(ctx, &key); err != nil {
+		t.Fatalf("purge failed with err: %v", err)
+	}
+
+	// reads don't create files
+	if _, err = os.Stat(filepath.Join(mbc.Basepath, "unknown.mbtiles")); !os.IsNotExist(err) {
+		t.Errorf("expected no file, got stat err %v", err)
+	}
+}
+
+func TestPurgeGenerationsConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	mbc := newCache(t, 4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for x := uint(0); x < 50; x++ {
+				key := cache.Key{MapName: "osm", Version: "v1", Z: 6, X: x, Y: uint(i)}
+				// the file can't be used while it's removed
+				var purging mbtiles.ErrTilesetPurging
+				if err := mbc.Set(ctx, &key, []byte("tile")); err != nil && !errors.As(err, &purging) {
+					t.Errorf("set failed with err: %v", err)
+					return
+				}
+				if _, _, err := mbc.Get(ctx, &key); err != nil && !errors.As(err, &purging) {
+					t.Errorf("get failed with err: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+
+	if err := mbc.PurgeGenerations(ctx, "osm", "v2", true); err != nil {
+		t.Errorf("purge generations failed with err: %v", err)
+	}
+	wg.Wait()
+}
+
+func TestWriter(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "export.mbtiles")
+
+	w, err := mbtiles.NewWriter(path)
+	if err != nil {
+		t.Fatalf("new writer failed with err: %v", err)
+	}
+	// more tiles than a batch, so several transactions are committed
+	for x := uint(0); x < mbtiles.DefaultBatchSize+5; x++ {
+		if err = w.WriteTile(ctx, 8, x, 2, []byte("tile")); err != nil {
+			t.Fatalf("write tile failed with err: %v", err)
+		}
+	}
+	if err = w.Close(ctx, cache.Tileset{Name: "osm", MaxZoom: 8}); err != nil {
+		t.Fatalf("close failed with err: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err = db.QueryRow("SELECT count(*) FROM tiles WHERE zoom_level = 8 AND tile_row = 253").Scan(&count); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if count != mbtiles.DefaultBatchSize+5 {
+		t.Errorf("tiles, expected %v got %v", mbtiles.DefaultBatchSize+5, count)
+	}
+
+	var name string
+	if err = db.QueryRow("SELECT value FROM metadata WHERE name = 'name'").Scan(&name); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if name != "osm" {
+		t.Errorf("metadata name, expected osm got %v", name)
+	}
+}
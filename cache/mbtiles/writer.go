@@ -0,0 +1,109 @@
+//go:build cgo
+// +build cgo
+
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/go-spatial/tegola/cache"
+)
+
+// Writer writes the tiles of a single tileset to a standalone MBTiles file, for
+// tilesets exported out of tegola
+type Writer struct {
+	path string
+	db   *sql.DB
+	tx   *sql.Tx
+	stmt *sql.Stmt
+	// pending is the number of tiles written in the current transaction
+	pending int
+}
+
+// NewWriter creates the MBTiles file at path, which is expected not to exist
+func NewWriter(path string) (*Writer, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_synchronous=OFF", path))
+	if err != nil {
+		return nil, err
+	}
+	// a single connection keeps the transaction and the statements on the same file handle
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range schema {
+		if _, err = db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error creating mbtiles schema in (%v): %w", path, err)
+		}
+	}
+
+	return &Writer{path: path, db: db}, nil
+}
+
+// WriteTile writes the tile data of z/x/y. Tiles are committed in batches of DefaultBatchSize.
+func (w *Writer) WriteTile(ctx context.Context, z, x, y uint, data []byte) (err error) {
+	if w.tx == nil {
+		if w.tx, err = w.db.BeginTx(ctx, nil); err != nil {
+			return err
+		}
+		w.stmt, err = w.tx.PrepareContext(ctx, "INSERT OR REPLACE INTO tiles (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?)")
+		if err != nil {
+			w.tx.Rollback()
+			w.tx = nil
+			return err
+		}
+	}
+
+	if _, err = w.stmt.ExecContext(ctx, z, x, tmsRow(z, y), data); err != nil {
+		return fmt.Errorf("error writing tile (%v/%v/%v) to (%v): %w", z, x, y, w.path, err)
+	}
+
+	w.pending++
+	if w.pending < DefaultBatchSize {
+		return nil
+	}
+	return w.commit()
+}
+
+// commit commits the current transaction, if any
+func (w *Writer) commit() error {
+	if w.tx == nil {
+		return nil
+	}
+
+	w.stmt.Close()
+	err := w.tx.Commit()
+	w.tx, w.stmt, w.pending = nil, nil, 0
+	return err
+}
+
+// Close writes the metadata table describing the tileset and closes the file
+func (w *Writer) Close(ctx context.Context, desc cache.Tileset) error {
+	if err := w.commit(); err != nil {
+		w.db.Close()
+		return err
+	}
+	if err := writeMetadata(ctx, w.db, desc); err != nil {
+		w.db.Close()
+		return err
+	}
+	return w.db.Close()
+}
+
+// Abort closes and removes the file
+func (w *Writer) Abort() error {
+	if w.tx != nil {
+		w.stmt.Close()
+		w.tx.Rollback()
+	}
+	w.db.Close()
+
+	for _, suffix := range []string{"", "-journal"} {
+		if err := os.Remove(w.path + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
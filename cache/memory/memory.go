@@ -55,3 +55,42 @@ func (mc *MemoryCache) Purge(ctx context.Context, key *cache.Key) error {
 
 	return nil
 }
+
+// PurgeGenerations removes every tile of mapName written under another version than
+// keepVersion, and the tiles written without a version when unversioned is set.
+func (mc *MemoryCache) PurgeGenerations(ctx context.Context, mapName, keepVersion string, unversioned bool) error {
+	mc.Lock()
+	defer mc.Unlock()
+
+	for k := range mc.keyVals {
+		if cache.IsStaleGeneration(k, mapName, keepVersion, unversioned) {
+			delete(mc.keyVals, k)
+		}
+	}
+
+	return nil
+}
+
+// List calls fn for each tile matching the filter. The matching tiles are collected
+// before fn is called, so fn can call back into the cache.
+func (mc *MemoryCache) List(ctx context.Context, filter cache.ListFilter, fn func(cache.KeyInfo) error) error {
+	var infos []cache.KeyInfo
+
+	mc.RLock()
+	for k, val := range mc.keyVals {
+		key, ok := cache.MatchStoredKey(k, filter)
+		if !ok {
+			continue
+		}
+		infos = append(infos, cache.KeyInfo{Key: *key, Size: int64(len(val))})
+	}
+	mc.RUnlock()
+
+	for _, ki := range infos {
+		if err := fn(ki); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -165,3 +165,161 @@ func TestSetOverwrite(t *testing.T) {
 		t.Run(name, fn(tc))
 	}
 }
+
+func TestPurgeGenerations(t *testing.T) {
+	ctx := context.Background()
+
+	mc, err := memory.New(dict.Dict{})
+	if err != nil {
+		t.Fatalf("unexpected err, expected %v got %v", nil, err)
+	}
+
+	keys := map[string]cache.Key{
+		"unversioned":   {MapName: "osm", Z: 1},
+		"old version":   {MapName: "osm", Version: "v1", Z: 1},
+		"new version":   {MapName: "osm", Version: "v2", Z: 1},
+		"other map":     {MapName: "other", Version: "v1", Z: 1},
+		"new layer key": {MapName: "osm", Version: "v2", LayerName: "roads", Z: 1},
+	}
+	for name, key := range keys {
+		key := key
+		if err = mc.Set(ctx, &key, []byte(name)); err != nil {
+			t.Fatalf("write failed with err, expected %v got %v", nil, err)
+		}
+	}
+
+	purger, ok := mc.(cache.GenerationPurger)
+	if !ok {
+		t.Fatalf("expected memory cache to implement cache.GenerationPurger")
+	}
+	if err = purger.PurgeGenerations(ctx, "osm", "v2", false); err != nil {
+		t.Fatalf("purge generations failed with err, expected %v got %v", nil, err)
+	}
+
+	expectedHits := map[string]bool{
+		"unversioned":   true,
+		"old version":   false,
+		"new version":   true,
+		"other map":     true,
+		"new layer key": true,
+	}
+	for name, expected := range expectedHits {
+		key := keys[name]
+		_, hit, err := mc.Get(ctx, &key)
+		if err != nil {
+			t.Errorf("%v: read failed with err, expected %v got %v", name, nil, err)
+			continue
+		}
+		if hit != expected {
+			t.Errorf("%v: expected hit %t got %t", name, expected, hit)
+		}
+	}
+
+	// the tiles cached before versioning was turned on are only removed when asked for
+	if err = purger.PurgeGenerations(ctx, "osm", "v2", true); err != nil {
+		t.Fatalf("purge generations failed with err, expected %v got %v", nil, err)
+	}
+
+	expectedHits = map[string]bool{
+		"unversioned":   false,
+		"old version":   false,
+		"new version":   true,
+		"other map":     true,
+		"new layer key": true,
+	}
+	for name, expected := range expectedHits {
+		key := keys[name]
+		_, hit, err := mc.Get(ctx, &key)
+		if err != nil {
+			t.Errorf("%v: read failed with err, expected %v got %v", name, nil, err)
+			continue
+		}
+		if hit != expected {
+			t.Errorf("%v: expected hit %t got %t", name, expected, hit)
+		}
+	}
+}
+
+func TestListCallsBack(t *testing.T) {
+	ctx := context.Background()
+
+	mc, err := memory.New(dict.Dict{})
+	if err != nil {
+		t.Fatalf("unexpected err, expected %v got %v", nil, err)
+	}
+
+	key := cache.Key{MapName: "osm", Z: 1}
+	if err = mc.Set(ctx, &key, []byte("tile")); err != nil {
+		t.Fatalf("write failed with err, expected %v got %v", nil, err)
+	}
+
+	// the callback replaces the listed tile, as pruning does
+	err = mc.(cache.Lister).List(ctx, cache.ListFilter{MapName: "osm"}, func(ki cache.KeyInfo) error {
+		return mc.Set(ctx, &ki.Key, cache.EmptyTile)
+	})
+	if err != nil {
+		t.Fatalf("list failed with err, expected %v got %v", nil, err)
+	}
+
+	val, _, err := mc.Get(ctx, &key)
+	if err != nil {
+		t.Fatalf("read failed with err, expected %v got %v", nil, err)
+	}
+	if !cache.IsEmptyTile(val) {
+		t.Errorf("expected the tile to be replaced got %q", val)
+	}
+}
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+
+	mc, err := memory.New(dict.Dict{})
+	if err != nil {
+		t.Fatalf("unexpected err, expected %v got %v", nil, err)
+	}
+
+	tiles := map[cache.Key][]byte{
+		{MapName: "osm", Version: "v1", Z: 1, X: 0, Y: 0}:                     []byte("12"),
+		{MapName: "osm", Version: "v1", Z: 1, X: 1, Y: 0}:                     []byte("1234"),
+		{MapName: "osm", Version: "v1", Z: 2, X: 0, Y: 0}:                     []byte("1"),
+		{MapName: "osm", Version: "v1", LayerName: "roads", Z: 2, X: 0, Y: 0}: []byte("123"),
+		{MapName: "osm", Version: "v1", Params: "f00d", Z: 2, X: 0, Y: 0}:     []byte("12"),
+		{MapName: "osm", Version: "v0", Z: 1, X: 0, Y: 0}:                     []byte("12345"),
+		{MapName: "other", Z: 1, X: 0, Y: 0}:                                  []byte("12345"),
+	}
+	for key, data := range tiles {
+		key := key
+		if err = mc.Set(ctx, &key, data); err != nil {
+			t.Fatalf("write failed with err, expected %v got %v", nil, err)
+		}
+	}
+
+	lister, ok := mc.(cache.Lister)
+	if !ok {
+		t.Fatalf("expected memory cache to implement cache.Lister")
+	}
+
+	stats, err := cache.Stats(ctx, lister, cache.ListFilter{MapName: "osm", Version: "v1"})
+	if err != nil {
+		t.Fatalf("stats failed with err, expected %v got %v", nil, err)
+	}
+
+	expected := []cache.MapStats{
+		{
+			MapName: "osm",
+			Tiles:   3,
+			Bytes:   7,
+			Zooms: []cache.ZoomStats{
+				{Zoom: 1, Tiles: 2, Bytes: 6},
+				{Zoom: 2, Tiles: 1, Bytes: 1},
+			},
+			Variants: []cache.VariantStats{
+				{Params: "f00d", Tiles: 1, Bytes: 2},
+				{LayerName: "roads", Tiles: 1, Bytes: 3},
+			},
+		},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v got %+v", expected, stats)
+	}
+}
@@ -1,5 +1,7 @@
 package redis
 
+import "fmt"
+
 // ErrHostMissing is raised when Redis Addr is missing Host
 type ErrHostMissing struct {
 	msg string
@@ -8,3 +10,22 @@ type ErrHostMissing struct {
 func (error *ErrHostMissing) Error() string {
 	return error.msg
 }
+
+// ErrInvalidMode is raised when the configured mode is not supported
+type ErrInvalidMode struct {
+	Mode string
+}
+
+func (e ErrInvalidMode) Error() string {
+	return fmt.Sprintf("rediscache: invalid mode (%v). expected one of %q, %q or %q", e.Mode, ModeStandalone, ModeSentinel, ModeCluster)
+}
+
+// ErrMissingConfig is raised when a config key required by the configured mode is not set
+type ErrMissingConfig struct {
+	Key  string
+	Mode string
+}
+
+func (e ErrMissingConfig) Error() string {
+	return fmt.Sprintf("rediscache: %v is required in %v mode", e.Key, e.Mode)
+}
@@ -0,0 +1,223 @@
+package redis_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/cache/redis"
+	"github.com/go-spatial/tegola/dict"
+)
+
+// newSentinel starts a stand-in for a redis sentinel which reports master as the
+// primary of every master name and no replicas nor other sentinels.
+func newSentinel(t *testing.T, master *miniredis.Miniredis) string {
+	t.Helper()
+
+	srv, err := server.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected err starting sentinel: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	srv.Register("SENTINEL", func(c *server.Peer, cmd string, args []string) {
+		if len(args) == 0 {
+			c.WriteError("ERR wrong number of arguments")
+			return
+		}
+		switch strings.ToLower(args[0]) {
+		case "get-master-addr-by-name":
+			c.WriteLen(2)
+			c.WriteBulk(master.Host())
+			c.WriteBulk(master.Port())
+		default: // sentinels, replicas, slaves
+			c.WriteLen(0)
+		}
+	})
+	srv.Register("SUBSCRIBE", func(c *server.Peer, cmd string, args []string) {
+		for i, channel := range args {
+			c.WriteLen(3)
+			c.WriteBulk("subscribe")
+			c.WriteBulk(channel)
+			c.WriteInt(i + 1)
+		}
+	})
+
+	return srv.Addr().String()
+}
+
+func TestModes(t *testing.T) {
+	ctx := context.Background()
+
+	type tcase struct {
+		config func(mr *miniredis.Miniredis) dict.Dict
+		// expectedKeys are the redis keys of the stored tiles
+		expectedKeys []string
+	}
+
+	keys := []cache.Key{
+		{MapName: "osm", Version: "v1", Z: 1, X: 1, Y: 0},
+		{MapName: "osm", Version: "v0", Z: 1, X: 1, Y: 0},
+		{MapName: "other", Version: "v1", Z: 1, X: 1, Y: 0},
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			mr := miniredis.RunT(t)
+
+			rc, err := redis.New(tc.config(mr))
+			if err != nil {
+				t.Fatalf("unexpected err, expected %v got %v", nil, err)
+			}
+
+			for i := range keys {
+				if err = rc.Set(ctx, &keys[i], []byte("tile")); err != nil {
+					t.Fatalf("write failed with err, expected %v got %v", nil, err)
+				}
+			}
+
+			stored := mr.Keys()
+			sort.Strings(stored)
+			if !reflect.DeepEqual(stored, tc.expectedKeys) {
+				t.Errorf("stored keys, expected %v got %v", tc.expectedKeys, stored)
+			}
+
+			val, hit, err := rc.Get(ctx, &keys[0])
+			if err != nil {
+				t.Fatalf("read failed with err, expected %v got %v", nil, err)
+			}
+			if !hit || string(val) != "tile" {
+				t.Errorf("read, expected hit with %q got hit %t with %q", "tile", hit, val)
+			}
+
+			var listed []cache.Key
+			err = rc.(cache.Lister).List(ctx, cache.ListFilter{MapName: "osm", Version: "v1"}, func(ki cache.KeyInfo) error {
+				listed = append(listed, ki.Key)
+				if ki.Size != int64(len("tile")) {
+					t.Errorf("list size, expected %v got %v", len("tile"), ki.Size)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("list failed with err, expected %v got %v", nil, err)
+			}
+			if !reflect.DeepEqual(listed, keys[:1]) {
+				t.Errorf("list, expected %v got %v", keys[:1], listed)
+			}
+
+			if err = rc.(cache.GenerationPurger).PurgeGenerations(ctx, "osm", "v1", false); err != nil {
+				t.Fatalf("purge generations failed with err, expected %v got %v", nil, err)
+			}
+			if _, hit, _ = rc.Get(ctx, &keys[1]); hit {
+				t.Errorf("expected tile of the old generation to be purged")
+			}
+
+			if err = rc.Purge(ctx, &keys[0]); err != nil {
+				t.Fatalf("purge failed with err, expected %v got %v", nil, err)
+			}
+			if _, hit, _ = rc.Get(ctx, &keys[0]); hit {
+				t.Errorf("expected purged tile to miss")
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"standalone": {
+			config: func(mr *miniredis.Miniredis) dict.Dict {
+				return dict.Dict{"uri": "redis://" + mr.Addr() + "/0"}
+			},
+			expectedKeys: []string{"osm/~v0/1/1/0", "osm/~v1/1/1/0", "other/~v1/1/1/0"},
+		},
+		"standalone hash tag": {
+			config: func(mr *miniredis.Miniredis) dict.Dict {
+				return dict.Dict{"uri": "redis://" + mr.Addr() + "/0", "hash_tag": true}
+			},
+			expectedKeys: []string{"{osm}/~v0/1/1/0", "{osm}/~v1/1/1/0", "{other}/~v1/1/1/0"},
+		},
+		"cluster": {
+			config: func(mr *miniredis.Miniredis) dict.Dict {
+				return dict.Dict{
+					"mode":  "cluster",
+					"nodes": []string{mr.Addr()},
+				}
+			},
+			expectedKeys: []string{"osm/~v0/1/1/0", "osm/~v1/1/1/0", "other/~v1/1/1/0"},
+		},
+		"cluster hash tag": {
+			config: func(mr *miniredis.Miniredis) dict.Dict {
+				return dict.Dict{
+					"mode":     "cluster",
+					"nodes":    []string{mr.Addr()},
+					"hash_tag": true,
+				}
+			},
+			expectedKeys: []string{"{osm}/~v0/1/1/0", "{osm}/~v1/1/1/0", "{other}/~v1/1/1/0"},
+		},
+		"sentinel": {
+			config: func(mr *miniredis.Miniredis) dict.Dict {
+				return dict.Dict{
+					"mode":               "sentinel",
+					"master_name":        "tegola",
+					"sentinel_addresses": []string{newSentinel(t, mr)},
+					"read_from_replica":  true,
+				}
+			},
+			expectedKeys: []string{"osm/~v0/1/1/0", "osm/~v1/1/1/0", "other/~v1/1/1/0"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestModeConfigErrors(t *testing.T) {
+	type tcase struct {
+		config      dict.Dict
+		expectedErr error
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			_, err := redis.New(tc.config)
+			if !reflect.DeepEqual(err, tc.expectedErr) {
+				t.Errorf("expected err %v got %v", tc.expectedErr, err)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"invalid mode": {
+			config:      dict.Dict{"mode": "ring"},
+			expectedErr: redis.ErrInvalidMode{Mode: "ring"},
+		},
+		"sentinel without master name": {
+			config: dict.Dict{
+				"mode":               "sentinel",
+				"sentinel_addresses": []string{"127.0.0.1:26379"},
+			},
+			expectedErr: redis.ErrMissingConfig{Key: "master_name", Mode: "sentinel"},
+		},
+		"sentinel without addresses": {
+			config: dict.Dict{
+				"mode":        "sentinel",
+				"master_name": "tegola",
+			},
+			expectedErr: redis.ErrMissingConfig{Key: "sentinel_addresses", Mode: "sentinel"},
+		},
+		"cluster without nodes": {
+			config:      dict.Dict{"mode": "cluster"},
+			expectedErr: redis.ErrMissingConfig{Key: "nodes", Mode: "cluster"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
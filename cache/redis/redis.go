@@ -5,6 +5,8 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -26,6 +28,21 @@ const (
 	ConfigKeyTTL      = "ttl"
 	ConfigKeySSL      = "ssl"
 	ConfigKeyURI      = "uri"
+
+	ConfigKeyMode             = "mode"
+	ConfigKeyMasterName       = "master_name"
+	ConfigKeySentinelAddrs    = "sentinel_addresses"
+	ConfigKeySentinelPassword = "sentinel_password"
+	ConfigKeyNodes            = "nodes"
+	ConfigKeyReadFromReplica  = "read_from_replica"
+	ConfigKeyHashTag          = "hash_tag"
+)
+
+// supported values of the mode config key
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
 )
 
 var (
@@ -38,6 +55,12 @@ var (
 	defaultMaxZoom  = uint(tegola.MaxZ)
 	defaultTTL      = 0
 	defaultSSL      = false
+
+	defaultMode             = ModeStandalone
+	defaultMasterName       = ""
+	defaultSentinelPassword = ""
+	defaultReadFromReplica  = false
+	defaultHashTag          = false
 )
 
 func init() {
@@ -113,14 +136,157 @@ func CreateOptions(c dict.Dicter) (opts *redis.Options, err error) {
 	return o, nil
 }
 
+// CreateFailoverOptions creates redis.FailoverOptions, used to connect to a
+// Sentinel managed primary, from c
+func CreateFailoverOptions(c dict.Dicter) (*redis.FailoverOptions, error) {
+	masterName, err := c.String(ConfigKeyMasterName, &defaultMasterName)
+	if err != nil {
+		return nil, err
+	}
+	if masterName == "" {
+		return nil, ErrMissingConfig{Key: ConfigKeyMasterName, Mode: ModeSentinel}
+	}
+
+	sentinelAddrs, err := c.StringSlice(ConfigKeySentinelAddrs)
+	if err != nil {
+		return nil, err
+	}
+	if len(sentinelAddrs) == 0 {
+		return nil, ErrMissingConfig{Key: ConfigKeySentinelAddrs, Mode: ModeSentinel}
+	}
+
+	sentinelPassword, err := c.String(ConfigKeySentinelPassword, &defaultSentinelPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := c.String(ConfigKeyPassword, &defaultPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := c.Int(ConfigKeyDB, &defaultDB)
+	if err != nil {
+		return nil, err
+	}
+
+	ssl, err := c.Bool(ConfigKeySSL, &defaultSSL)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &redis.FailoverOptions{
+		MasterName:       masterName,
+		SentinelAddrs:    sentinelAddrs,
+		SentinelPassword: sentinelPassword,
+		Password:         password,
+		DB:               db,
+		PoolSize:         2,
+		DialTimeout:      3 * time.Second,
+	}
+	if ssl {
+		o.TLSConfig = &tls.Config{}
+	}
+
+	return o, nil
+}
+
+// CreateClusterOptions creates redis.ClusterOptions, used to connect to a
+// Redis Cluster through its seed nodes, from c
+func CreateClusterOptions(c dict.Dicter) (*redis.ClusterOptions, error) {
+	nodes, err := c.StringSlice(ConfigKeyNodes)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrMissingConfig{Key: ConfigKeyNodes, Mode: ModeCluster}
+	}
+
+	password, err := c.String(ConfigKeyPassword, &defaultPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	readFromReplica, err := c.Bool(ConfigKeyReadFromReplica, &defaultReadFromReplica)
+	if err != nil {
+		return nil, err
+	}
+
+	ssl, err := c.Bool(ConfigKeySSL, &defaultSSL)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &redis.ClusterOptions{
+		Addrs:       nodes,
+		Password:    password,
+		ReadOnly:    readFromReplica,
+		PoolSize:    2,
+		DialTimeout: 3 * time.Second,
+	}
+	if ssl {
+		o.TLSConfig = &tls.Config{}
+	}
+
+	return o, nil
+}
+
 func New(c dict.Dicter) (rcache cache.Interface, err error) {
 	ctx := context.Background()
-	opts, err := CreateOptions(c)
+
+	mode, err := c.String(ConfigKeyMode, &defaultMode)
 	if err != nil {
 		return nil, err
 	}
 
-	client := redis.NewClient(opts)
+	readFromReplica, err := c.Bool(ConfigKeyReadFromReplica, &defaultReadFromReplica)
+	if err != nil {
+		return nil, err
+	}
+
+	hashTag, err := c.Bool(ConfigKeyHashTag, &defaultHashTag)
+	if err != nil {
+		return nil, err
+	}
+
+	var client, replica redis.UniversalClient
+	switch mode {
+	case ModeStandalone:
+		if readFromReplica {
+			return nil, fmt.Errorf("rediscache: %v requires the %q or %q mode", ConfigKeyReadFromReplica, ModeSentinel, ModeCluster)
+		}
+
+		opts, err := CreateOptions(c)
+		if err != nil {
+			return nil, err
+		}
+		client = redis.NewClient(opts)
+
+	case ModeSentinel:
+		opts, err := CreateFailoverOptions(c)
+		if err != nil {
+			return nil, err
+		}
+		client = redis.NewFailoverClient(opts)
+
+		if readFromReplica {
+			// the replica client falls back to the primary when no replica is available
+			replicaOpts := *opts
+			replicaOpts.ReplicaOnly = true
+			replica = redis.NewFailoverClient(&replicaOpts)
+		}
+
+	case ModeCluster:
+		// reads are routed to the replicas by the cluster client itself
+		opts, err := CreateClusterOptions(c)
+		if err != nil {
+			return nil, err
+		}
+		client = redis.NewClusterClient(opts)
+
+	default:
+		return nil, ErrInvalidMode{Mode: mode}
+	}
 
 	pong, err := client.Ping(ctx).Result()
 	if err != nil {
@@ -143,15 +309,93 @@ func New(c dict.Dicter) (rcache cache.Interface, err error) {
 
 	return &RedisCache{
 		Redis:      client,
+		Replica:    replica,
 		MaxZoom:    maxZoom,
 		Expiration: time.Duration(ttl) * time.Second,
+		HashTag:    hashTag,
 	}, nil
 }
 
 type RedisCache struct {
-	Redis      *redis.Client
+	// Redis is a *redis.Client, a failover client or a *redis.ClusterClient depending on the mode
+	Redis redis.UniversalClient
+	// Replica, when set, is used to read tiles instead of Redis
+	Replica    redis.UniversalClient
 	Expiration time.Duration
 	MaxZoom    uint
+	// HashTag wraps the map name of the keys in braces, i.e. {osm}/10/1/2, so all
+	// the tiles of a map are stored in the same cluster slot
+	HashTag bool
+}
+
+// keyName returns the redis key of a tile
+func (rdc *RedisCache) keyName(key *cache.Key) string {
+	k := key.String()
+	if !rdc.HashTag || key.MapName == "" {
+		return k
+	}
+	return "{" + key.MapName + "}" + strings.TrimPrefix(k, key.MapName)
+}
+
+// mapPrefix returns the prefix of the redis keys of the tiles of mapName
+func (rdc *RedisCache) mapPrefix(mapName string) string {
+	if !rdc.HashTag {
+		return mapName + "/"
+	}
+	return "{" + mapName + "}/"
+}
+
+// stripHashTag removes the braces around the map name of k
+func stripHashTag(k string) string {
+	if !strings.HasPrefix(k, "{") {
+		return k
+	}
+	end := strings.Index(k, "}")
+	if end == -1 {
+		return k
+	}
+	return k[1:end] + k[end+1:]
+}
+
+// scan calls fn for each key matching the pattern. In cluster mode the keys of every
+// primary are scanned, unless mapName is set and the keys are hash tagged, in which
+// case only the primary holding the map is scanned.
+func (rdc *RedisCache) scan(ctx context.Context, mapName, match string, fn func(k string) error) error {
+	cc, ok := rdc.Redis.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, rdc.Redis, match, fn)
+	}
+
+	if rdc.HashTag && mapName != "" {
+		node, err := cc.MasterForKey(ctx, "{"+mapName+"}")
+		if err != nil {
+			return err
+		}
+		return scanNode(ctx, node, match, fn)
+	}
+
+	// primaries are scanned concurrently
+	var mu sync.Mutex
+	return cc.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		return scanNode(ctx, node, match, func(k string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return fn(k)
+		})
+	})
+}
+
+// scanCount is the number of keys asked for by each SCAN call
+const scanCount = 1000
+
+func scanNode(ctx context.Context, node redis.Cmdable, match string, fn func(k string) error) error {
+	iter := node.Scan(ctx, 0, match, scanCount).Iterator()
+	for iter.Next(ctx) {
+		if err := fn(iter.Val()); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
 }
 
 func (rdc *RedisCache) Set(ctx context.Context, key *cache.Key, val []byte) error {
@@ -160,12 +404,21 @@ func (rdc *RedisCache) Set(ctx context.Context, key *cache.Key, val []byte) erro
 	}
 
 	return rdc.Redis.
-		Set(ctx, key.String(), val, rdc.Expiration).
+		Set(ctx, rdc.keyName(key), val, rdc.Expiration).
 		Err()
 }
 
 func (rdc *RedisCache) Get(ctx context.Context, key *cache.Key) (val []byte, hit bool, err error) {
-	val, err = rdc.Redis.Get(ctx, key.String()).Bytes()
+	k := rdc.keyName(key)
+	if rdc.Replica != nil {
+		val, err = rdc.Replica.Get(ctx, k).Bytes()
+		if err != nil && err != redis.Nil {
+			log.Warnf("rediscache: reading %v from replica failed, falling back to primary: %v", k, err)
+			val, err = rdc.Redis.Get(ctx, k).Bytes()
+		}
+	} else {
+		val, err = rdc.Redis.Get(ctx, k).Bytes()
+	}
 
 	switch err {
 	case nil: // cache hit
@@ -178,5 +431,78 @@ func (rdc *RedisCache) Get(ctx context.Context, key *cache.Key) (val []byte, hit
 }
 
 func (rdc *RedisCache) Purge(ctx context.Context, key *cache.Key) (err error) {
-	return rdc.Redis.Del(ctx, key.String()).Err()
+	return rdc.Redis.Del(ctx, rdc.keyName(key)).Err()
+}
+
+// PurgeGenerations removes every tile of mapName written under another version than
+// keepVersion, and the tiles written without a version when unversioned is set.
+// The keyspace is walked with SCAN so the server is not blocked on large caches.
+func (rdc *RedisCache) PurgeGenerations(ctx context.Context, mapName, keepVersion string, unversioned bool) error {
+	if keepVersion == "" || mapName == "" {
+		return nil
+	}
+
+	return rdc.scan(ctx, mapName, rdc.mapPrefix(mapName)+"*", func(k string) error {
+		if !cache.IsStaleGeneration(stripHashTag(k), mapName, keepVersion, unversioned) {
+			return nil
+		}
+		return rdc.Redis.Del(ctx, k).Err()
+	})
+}
+
+// List walks the keyspace with SCAN and calls fn for each tile matching the filter.
+// The size of a tile is the length of the stored value, which is read for batches of
+// keys in a single pipeline.
+func (rdc *RedisCache) List(ctx context.Context, filter cache.ListFilter, fn func(cache.KeyInfo) error) error {
+	match := filter.Prefix() + "*"
+	if filter.MapName != "" {
+		match = rdc.mapPrefix(filter.MapName) + strings.TrimPrefix(filter.Prefix(), filter.MapName+"/") + "*"
+	}
+
+	var (
+		names []string
+		keys  []cache.Key
+	)
+	flush := func() error {
+		if len(names) == 0 {
+			return nil
+		}
+
+		pipe := rdc.Redis.Pipeline()
+		sizes := make([]*redis.IntCmd, len(names))
+		for i, k := range names {
+			sizes[i] = pipe.StrLen(ctx, k)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+
+		for i, key := range keys {
+			if err := fn(cache.KeyInfo{Key: key, Size: sizes[i].Val()}); err != nil {
+				return err
+			}
+		}
+
+		names, keys = names[:0], keys[:0]
+		return nil
+	}
+
+	err := rdc.scan(ctx, filter.MapName, match, func(k string) error {
+		key, ok := cache.MatchStoredKey(stripHashTag(k), filter)
+		if !ok {
+			return nil
+		}
+
+		names = append(names, k)
+		keys = append(keys, *key)
+		if len(names) < scanCount {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
 }
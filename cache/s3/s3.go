@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -389,3 +390,34 @@ func (s3c *Cache) Purge(ctx context.Context, key *cache.Key) error {
 
 	return nil
 }
+
+// List pages through the objects under the basepath and calls fn for each tile matching the filter.
+func (s3c *Cache) List(ctx context.Context, filter cache.ListFilter, fn func(cache.KeyInfo) error) error {
+	prefix := path.Join(s3c.Basepath, filter.Prefix())
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	input := s3.ListObjectsV2Input{
+		Bucket: aws.String(s3c.Bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	var fnErr error
+	err := s3c.Client.ListObjectsV2PagesWithContext(ctx, &input, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			key, ok := cache.MatchStoredKey(strings.TrimPrefix(aws.StringValue(obj.Key), s3c.Basepath), filter)
+			if !ok {
+				continue
+			}
+			if fnErr = fn(cache.KeyInfo{Key: *key, Size: aws.Int64Value(obj.Size)}); fnErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
@@ -7,6 +7,17 @@ import (
 	"github.com/go-spatial/tegola/dict"
 )
 
+const (
+	// ConfigKeyKeyVersioning is the cache config key used to turn on versioned cache keys
+	ConfigKeyKeyVersioning = "key_versioning"
+	// ConfigKeySweepOnStart is the cache config key used to remove the tiles of the
+	// other versions of the maps when the server starts
+	ConfigKeySweepOnStart = "sweep_on_start"
+	// ConfigKeySweepUnversioned is the cache config key used to remove the tiles cached
+	// without a version along with the other versions
+	ConfigKeySweepUnversioned = "sweep_unversioned"
+)
+
 var (
 	ErrCacheTypeMissing = errors.New("register: cache 'type' parameter missing")
 	ErrCacheTypeInvalid = errors.New("register: cache 'type' value must be a string")
@@ -29,3 +40,24 @@ func Cache(config dict.Dicter) (cache.Interface, error) {
 	// register the provider
 	return cache.For(cType, config)
 }
+
+// CacheKeyVersioning reports if the cache config asks for the map config version to be
+// part of the cache keys. Defaults to false.
+func CacheKeyVersioning(config dict.Dicter) (bool, error) {
+	enabled := false
+	return config.Bool(ConfigKeyKeyVersioning, &enabled)
+}
+
+// CacheSweepOnStart reports if the cache config asks for the tiles of the other versions
+// of the maps to be removed when the server starts. Defaults to false.
+func CacheSweepOnStart(config dict.Dicter) (bool, error) {
+	enabled := false
+	return config.Bool(ConfigKeySweepOnStart, &enabled)
+}
+
+// CacheSweepUnversioned reports if the cache config asks for the tiles cached without a
+// version to be removed along with the other versions. Defaults to false.
+func CacheSweepUnversioned(config dict.Dicter) (bool, error) {
+	enabled := false
+	return config.Bool(ConfigKeySweepUnversioned, &enabled)
+}
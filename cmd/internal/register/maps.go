@@ -1,13 +1,18 @@
 package register
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"html"
 	"regexp"
 	"strings"
 
 	"github.com/go-spatial/geom"
 	"github.com/go-spatial/tegola/atlas"
-	"github.com/go-spatial/tegola/config"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/env"
+	"github.com/go-spatial/tegola/internal/log"
 	"github.com/go-spatial/tegola/provider"
 )
 
@@ -95,44 +100,122 @@ func atlasLayerFromConfigLayer(cfg *provider.MapLayer, mapName string, layerProv
 	return layer, nil
 }
 
-func selectProvider(name string, mapName string, newMap *atlas.Map, providers map[string]provider.TilerUnion) (provider.Layerer, error) {
-	if newMap.HasMVTProvider() {
-		if newMap.MVTProviderName() != name {
-			return nil, config.ErrMVTDifferentProviders{
-				Original: newMap.MVTProviderName(),
-				Current:  name,
-			}
-		}
-		return newMap.MVTProvider(), nil
-	}
+// selectProvider returns the provider of a map layer, and the mvt provider when it's
+// a mvt provider
+func selectProvider(name string, providers map[string]provider.TilerUnion) (provider.Layerer, provider.MVTTiler, error) {
 	if prvd, ok := providers[name]; ok {
 		// Need to see what type of provider we got.
 		if prvd.Std != nil {
-			return prvd.Std, nil
+			return prvd.Std, nil, nil
 		}
 		if prvd.Mvt == nil {
-			return nil, ErrProviderNotFound{name}
+			return nil, nil, ErrProviderNotFound{name}
 		}
-		if len(newMap.Layers) != 0 {
-			return nil, config.ErrMixedProviders{
-				Map: string(mapName),
-			}
+		return prvd.Mvt, prvd.Mvt, nil
+	}
+	return nil, nil, ErrProviderNotFound{name}
+}
+
+// setMapMVTProvider makes a map whose layers are all from the same mvt provider a mvt
+// provider based map. Maps mixing providers are encoded as composite maps.
+func setMapMVTProvider(m *atlas.Map) {
+	if len(m.Layers) == 0 || m.Layers[0].MVTProvider == nil {
+		return
+	}
+	name := m.Layers[0].ProviderName
+	for i := range m.Layers {
+		if m.Layers[i].MVTProvider == nil || m.Layers[i].ProviderName != name {
+			return
 		}
-		return newMap.SetMVTProvider(name, prvd.Mvt), nil
 	}
-	return nil, ErrProviderNotFound{name}
+	m.SetMVTProvider(name, m.Layers[0].MVTProvider)
 }
 
-// Maps registers maps with with atlas
-func Maps(a *atlas.Atlas, maps []provider.Map, providers map[string]provider.TilerUnion) error {
+// mapVersionLength is the number of hex characters of the config hash used as a map version
+const mapVersionLength = 16
 
-	var (
-		layerer provider.Layerer
-	)
+// mapVersionInput holds the parts of a map's resolved config which influence the rendered tiles
+type mapVersionInput struct {
+	Layers         []provider.MapLayer       `json:"layers"`
+	Params         []provider.QueryParameter `json:"params"`
+	TileBuffer     *env.Int                  `json:"tile_buffer"`
+	ProviderLayers []map[string]interface{}  `json:"provider_layers"`
+}
+
+// findProviderConfig returns the provider config with the given name, or nil
+func findProviderConfig(providerConfigs []dict.Dicter, name string) dict.Dicter {
+	for _, p := range providerConfigs {
+		if pname, _ := p.String("name", nil); pname == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// findProviderLayerConfig returns the layer config with the given name from a provider config, or nil
+func findProviderLayerConfig(providerConfig dict.Dicter, name string) dict.Dicter {
+	layers, err := providerConfig.MapSlice("layers")
+	if err != nil {
+		return nil
+	}
+	for _, l := range layers {
+		if lname, _ := l.String("name", nil); lname == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// MapVersion returns a hash of the resolved config of a map: its layers, the SQL and fields
+// of the provider layers it references, its params and its buffer. Any change to these
+// produces a different version, which can be used to start a fresh cache keyspace.
+func MapVersion(cfg provider.Map, providerConfigs []dict.Dicter) string {
+	input := mapVersionInput{
+		Layers:     cfg.Layers,
+		Params:     cfg.Parameters,
+		TileBuffer: cfg.TileBuffer,
+	}
+
+	for _, l := range cfg.Layers {
+		providerName, layerName, err := l.ProviderLayerName()
+		if err != nil {
+			continue
+		}
+
+		resolved := map[string]interface{}{
+			"provider": providerName,
+		}
+		if p := findProviderConfig(providerConfigs, providerName); p != nil {
+			for _, key := range []string{"type", "srid"} {
+				if v, ok := p.Interface(key); ok {
+					resolved[key] = v
+				}
+			}
+			if pl := findProviderLayerConfig(p, layerName); pl != nil {
+				resolved["layer"] = pl
+			}
+		}
+		input.ProviderLayers = append(input.ProviderLayers, resolved)
+	}
+
+	b, err := json.Marshal(input)
+	if err != nil {
+		log.Warnf("unable to compute config version for map (%v): %v", cfg.Name, err)
+		return ""
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:mapVersionLength]
+}
+
+// Maps registers maps with with atlas. The providerConfigs are used to compute
+// each map's config version, see MapVersion.
+func Maps(a *atlas.Atlas, maps []provider.Map, providers map[string]provider.TilerUnion, providerConfigs []dict.Dicter) error {
 
 	// iterate our maps
 	for _, m := range maps {
 		newMap := webMercatorMapFromConfigMap(m)
+		newMap.Version = MapVersion(m, providerConfigs)
 
 		// iterate our layers
 		for _, l := range m.Layers {
@@ -145,7 +228,7 @@ func Maps(a *atlas.Atlas, maps []provider.Map, providers map[string]provider.Til
 			}
 
 			// find our layer provider
-			layerer, err = selectProvider(providerName, string(m.Name), &newMap, providers)
+			layerer, mvtTiler, err := selectProvider(providerName, providers)
 			if err != nil {
 				return err
 			}
@@ -154,8 +237,11 @@ func Maps(a *atlas.Atlas, maps []provider.Map, providers map[string]provider.Til
 			if err != nil {
 				return err
 			}
+			layer.ProviderName = providerName
+			layer.MVTProvider = mvtTiler
 			newMap.Layers = append(newMap.Layers, layer)
 		}
+		setMapMVTProvider(&newMap)
 
 		a.AddMap(newMap)
 	}
@@ -9,6 +9,7 @@ import (
 	"github.com/go-spatial/tegola/dict"
 	"github.com/go-spatial/tegola/internal/env"
 	"github.com/go-spatial/tegola/provider"
+	_ "github.com/go-spatial/tegola/provider/test"
 )
 
 func TestMaps(t *testing.T) {
@@ -35,7 +36,7 @@ func TestMaps(t *testing.T) {
 				return
 			}
 
-			err = register.Maps(&tc.atlas, tc.maps, providers)
+			err = register.Maps(&tc.atlas, tc.maps, providers, provArr)
 			if !errors.Is(err, tc.expectedErr) {
 				t.Errorf("invalid error, expected %v got %v", tc.expectedErr, err)
 			}
@@ -125,6 +126,32 @@ func TestMaps(t *testing.T) {
 				},
 			},
 		},
+		"mixed providers": {
+			maps: []provider.Map{
+				{
+					Name: "foo",
+					Layers: []provider.MapLayer{
+						{
+							ProviderLayer: "test.debug-tile-outline",
+						},
+						{
+							ProviderLayer: "mvt.test-layer",
+						},
+					},
+				},
+			},
+			providers: []dict.Dict{
+				{
+					"name": "test",
+					"type": "debug",
+				},
+				{
+					"name":      "mvt",
+					"type":      "mvt_test",
+					"test_file": "../../../provider/testdata/11_358_827.pbf",
+				},
+			},
+		},
 		"success": {
 			maps: []provider.Map{},
 			providers: []dict.Dict{
@@ -179,3 +206,93 @@ func TestSanitizeAttribution(t *testing.T) {
 		t.Run(name, fn(tc))
 	}
 }
+
+func TestMapVersion(t *testing.T) {
+	newMap := func() provider.Map {
+		return provider.Map{
+			Name:        "foo",
+			Attribution: "foo",
+			Layers: []provider.MapLayer{
+				{
+					ProviderLayer: "test.roads",
+				},
+			},
+		}
+	}
+	newProviders := func(sql string) []dict.Dicter {
+		return []dict.Dicter{
+			dict.Dict{
+				"name": "test",
+				"type": "postgis",
+				"layers": []map[string]interface{}{
+					{
+						"name": "roads",
+						"sql":  sql,
+					},
+					{
+						"name": "rivers",
+						"sql":  "SELECT * FROM rivers WHERE !BBOX!",
+					},
+				},
+			},
+		}
+	}
+
+	base := register.MapVersion(newMap(), newProviders("SELECT * FROM roads WHERE !BBOX!"))
+	if base == "" {
+		t.Fatalf("expected a version")
+	}
+
+	type tcase struct {
+		m         provider.Map
+		providers []dict.Dicter
+		changed   bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			got := register.MapVersion(tc.m, tc.providers)
+			if changed := got != base; changed != tc.changed {
+				t.Errorf("version changed, expected %v got %v (%v vs %v)", tc.changed, changed, base, got)
+			}
+		}
+	}
+
+	buffer := env.Int(128)
+	attribution := newMap()
+	attribution.Attribution = "bar"
+	tileBuffer := newMap()
+	tileBuffer.TileBuffer = &buffer
+	layer := newMap()
+	layer.Layers[0].DontClip = true
+
+	tests := map[string]tcase{
+		"same config": {
+			m:         newMap(),
+			providers: newProviders("SELECT * FROM roads WHERE !BBOX!"),
+		},
+		"attribution": {
+			m:         attribution,
+			providers: newProviders("SELECT * FROM roads WHERE !BBOX!"),
+		},
+		"sql": {
+			m:         newMap(),
+			providers: newProviders("SELECT * FROM roads WHERE highway AND !BBOX!"),
+			changed:   true,
+		},
+		"tile buffer": {
+			m:         tileBuffer,
+			providers: newProviders("SELECT * FROM roads WHERE !BBOX!"),
+			changed:   true,
+		},
+		"layer": {
+			m:         layer,
+			providers: newProviders("SELECT * FROM roads WHERE !BBOX!"),
+			changed:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
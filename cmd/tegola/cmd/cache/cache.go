@@ -42,6 +42,9 @@ var Cmd = &cobra.Command{
 
 func init() {
 	Cmd.AddCommand(SeedPurgeCmd)
+	Cmd.AddCommand(StatsCmd)
+	Cmd.AddCommand(PruneEmptyCmd)
+	Cmd.AddCommand(VerifyCmd)
 	Cmd.SetUsageTemplate(`Usage: {{.CommandPath}} [command]{{if .HasExample}}
 
 Examples:
@@ -49,7 +52,10 @@ Examples:
 
 Available Commands:
   {{rpad "seed" .NamePadding}} seed tiles to the cache
-  {{rpad "purge" .NamePadding}} purge tiles from the cache{{if .HasAvailableLocalFlags}}
+  {{rpad "purge" .NamePadding}} purge tiles from the cache
+  {{rpad "stats" .NamePadding}} report the number and size of cached tiles
+  {{rpad "prune-empty" .NamePadding}} remove the cached tiles which have no features
+  {{rpad "verify" .NamePadding}} check that the cached tiles can be decoded and match the map config{{if .HasAvailableLocalFlags}}
 
 Flags:
 {{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}{{if .HasAvailableInheritedFlags}}
@@ -67,12 +73,44 @@ type TileChannel struct {
 	isClosed bool
 	l        sync.RWMutex
 	err      error
+	// mapNames, when set, holds the names of the maps each tile is processed for.
+	// Otherwise the tiles are processed for all the maps.
+	mapNames map[slippy.Tile][]string
+	// ranges, when set, holds the tiles of each zoom, so they can be counted and
+	// sampled without generating them
+	ranges map[uint]tileRange
+	// start, when set, starts generating the tiles on the first call to Channel,
+	// so a dry run can count the ranges without generating them
+	start     func()
+	startOnce sync.Once
+}
+
+// tileMaps returns the maps, out of maps, the tile is processed for
+func (tc *TileChannel) tileMaps(tile slippy.Tile, maps []atlas.Map) []atlas.Map {
+	if tc == nil || tc.mapNames == nil {
+		return maps
+	}
+
+	names := tc.mapNames[tile]
+	filtered := make([]atlas.Map, 0, len(names))
+	for _, m := range maps {
+		for _, name := range names {
+			if m.Name == name {
+				filtered = append(filtered, m)
+				break
+			}
+		}
+	}
+	return filtered
 }
 
 func (tc *TileChannel) Channel() <-chan slippy.Tile {
 	if tc == nil {
 		return nil
 	}
+	if tc.start != nil {
+		tc.startOnce.Do(tc.start)
+	}
 	return tc.channel
 }
 
@@ -113,9 +151,23 @@ func (tc *TileChannel) Close() {
 type MapTile struct {
 	MapName string
 	Tile    slippy.Tile
+
+	// seq is the position of the tile in the generation order of its zoom
+	seq uint64
+}
+
+// doWork runs worker for every map tile of the tiles read from tileChannel. prog, which
+// can be nil, tracks the progress of the run and the tiles completed by previous runs.
+// Once the tiles are processed, the writes buffered by the cache are flushed.
+func doWork(ctx context.Context, tileChannel *TileChannel, maps []atlas.Map, concurrency int, prog *progress, worker func(context.Context, MapTile) error) (err error) {
+	return processTiles(ctx, tileChannel, maps, concurrency, prog, worker, true)
 }
 
-func doWork(ctx context.Context, tileChannel *TileChannel, maps []atlas.Map, concurrency int, worker func(context.Context, MapTile) error) (err error) {
+// processTiles runs worker for every map tile of the tiles read from tileChannel. When
+// finish is set, the process exits if the workers don't finish within 60 seconds of the
+// end of the tiles, and the cache is flushed once they are done. The jobs of a distributed
+// worker leave both to the worker, which runs many of them.
+func processTiles(ctx context.Context, tileChannel *TileChannel, maps []atlas.Map, concurrency int, prog *progress, worker func(context.Context, MapTile) error, finish bool) (err error) {
 	var wg sync.WaitGroup
 	// new channel for the workers
 	tiler := make(chan MapTile)
@@ -141,13 +193,19 @@ func doWork(ctx context.Context, tileChannel *TileChannel, maps []atlas.Map, con
 					cleanup = true
 					break
 				}
-				if err := worker(ctx, mt); err != nil {
+				err := worker(ctx, mt)
+				// failed tiles go to the retry list instead of stopping the run when one is set up
+				if err != nil && (!prog.retries() || errors.Is(err, context.Canceled)) {
 					cleanup = true
 					errLock.Lock()
 					mapTileErr = err
 					errLock.Unlock()
 					break
 				}
+				if err != nil {
+					log.Errorf("%v. added to the retry list", err)
+				}
+				prog.finish(mt, err)
 			}
 			if cleanup {
 				log.Debugf("worker %v waiting on clean up of tiler", i)
@@ -160,20 +218,21 @@ func doWork(ctx context.Context, tileChannel *TileChannel, maps []atlas.Map, con
 		}(i)
 	}
 
-	nonParamMaps := make([]atlas.Map, 0)
-
-	for _, m := range maps {
-		// we don't support caching for maps with custom parameters
-		if m.Params != nil || len(m.Params) > 0 {
-			log.Warnf("caching is disabled for map %s as it has custom parameters configures", m.Name)
-		}
-		nonParamMaps = append(nonParamMaps, m)
-	}
+	progCtx, stopProgress := context.WithCancel(ctx)
+	defer stopProgress()
+	go prog.run(progCtx, cacheProgressInterval)
 
 	// run through the incoming tiles, and generate the mapTiles as needed.
 TileChannelLoop:
 	for tile := range tileChannel.Channel() {
-		for _, m := range nonParamMaps {
+		tileMaps := tileChannel.tileMaps(tile, maps)
+		seq, skip := prog.start(tile, len(tileMaps))
+		if skip {
+			// completed by a previous run
+			continue
+		}
+
+		for _, m := range tileMaps {
 			if ctx.Err() != nil {
 				cleanup = true
 				break
@@ -192,6 +251,7 @@ TileChannelLoop:
 			mapTile := MapTile{
 				MapName: m.Name,
 				Tile:    tile,
+				seq:     seq,
 			}
 
 			select {
@@ -210,25 +270,37 @@ TileChannelLoop:
 			continue
 		}
 	}
-	// let our workers finish up
-	log.Info("waiting for workers to finish up")
-	shouldExit := true
-	go func() {
-		<-time.After(60 * time.Second)
-		if !shouldExit {
-			log.Info("60 seconds passed killing")
-			os.Exit(1)
+	if !finish {
+		wg.Wait()
+	} else {
+		// let our workers finish up
+		log.Info("waiting for workers to finish up")
+		shouldExit := true
+		go func() {
+			<-time.After(60 * time.Second)
+			if !shouldExit {
+				log.Info("60 seconds passed killing")
+				os.Exit(1)
+			}
+		}()
+		wg.Wait()
+		log.Info("all workers are done")
+		shouldExit = false
+		// some cache backends buffer their writes
+		if err := atlas.FlushCache(context.Background()); err != nil {
+			log.Errorf("error flushing the cache: %v", err)
 		}
-	}()
-	wg.Wait()
-	log.Info("all workers are done")
-	shouldExit = false
+	}
+	stopProgress()
 	err = tileChannel.Err()
 	if err == nil {
 		err = mapTileErr
 	}
 	if errors.Is(err, context.Canceled) {
-		return nil
+		err = nil
+	}
+	if perr := prog.close(err); perr != nil {
+		log.Errorf("error writing the progress of the run: %v", perr)
 	}
 	return err
 }
@@ -0,0 +1,549 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spatial/cobra"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/internal/log"
+)
+
+// flag parameters
+var (
+	// cacheCoordinator splits the tiles into jobs pushed to the queue instead of processing them
+	cacheCoordinator bool
+	// cacheWorker processes the jobs pulled from the queue
+	cacheWorker bool
+	// cacheQueue is the uri of the job queue, i.e. redis://localhost:6379/0
+	cacheQueue string
+	// cacheQueueName is the prefix of the keys of the job queue
+	cacheQueueName string
+	// cacheJobSize is the number of tiles per job
+	cacheJobSize int
+	// cacheVisibilityTimeout is how long a job is hidden from the other workers after being claimed
+	cacheVisibilityTimeout time.Duration
+	// cacheMaxAttempts is the number of times a job is claimed before it's moved to the failed jobs
+	cacheMaxAttempts int
+)
+
+// seedPurgeQueue is set when running as a coordinator or a worker
+var seedPurgeQueue jobQueue
+
+// queuePollInterval is how long a worker waits for a job before checking if the run is
+// over, and how often the coordinator checks the queue
+var queuePollInterval = 2 * time.Second
+
+// ErrQueueNotEmpty is returned when a coordinator is started on a queue with unfinished jobs
+type ErrQueueNotEmpty struct {
+	Name      string
+	Remaining int64
+}
+
+func (e ErrQueueNotEmpty) Error() string {
+	return fmt.Sprintf("job queue (%v) has %v unfinished jobs. wait for the workers to finish or use another queue name", e.Name, e.Remaining)
+}
+
+// ErrRunMismatch is returned by a worker whose settings differ from the settings of the
+// coordinator of the run
+type ErrRunMismatch struct {
+	Setting     string
+	Coordinator string
+	Worker      string
+}
+
+func (e ErrRunMismatch) Error() string {
+	return fmt.Sprintf("the %v of the worker (%v) doesn't match the %v of the coordinator (%v). start the worker with the same flags as the coordinator", e.Setting, e.Worker, e.Setting, e.Coordinator)
+}
+
+// seedJob is a batch of tiles for a worker to seed or purge
+type seedJob struct {
+	// ID is assigned by the queue
+	ID string `json:"-"`
+	// Run is the ID of the run header the job was pushed with
+	Run   string   `json:"run"`
+	Maps  []string `json:"maps"`
+	Tiles []string `json:"tiles"`
+}
+
+// runHeader holds the settings of a run, which the coordinator stores with the queue. The
+// workers process the jobs with their own settings, so they have to match the header.
+type runHeader struct {
+	// ID identifies the run, it's set by the coordinator
+	ID         string                         `json:"id"`
+	Command    string                         `json:"command"`
+	Overwrite  bool                           `json:"overwrite"`
+	EmptyTiles atlas.EmptyTileMode            `json:"empty_tiles"`
+	Variants   map[string][]atlas.TileVariant `json:"variants"`
+	// Maps holds the versions of the maps by name. The coordinator stores the maps of the
+	// run, the workers all their maps.
+	Maps map[string]runMap `json:"maps"`
+}
+
+// runMap holds the versions of a map of a run
+type runMap struct {
+	// Version is the version of the map config, which changes with its layers
+	Version string `json:"version"`
+	// KeyVersion is the version in the cache keys of the map
+	KeyVersion string `json:"key_version"`
+}
+
+// currentRunHeader returns the settings of the run from the flags and the maps
+func currentRunHeader(maps []atlas.Map) runHeader {
+	h := runHeader{
+		Command:    seedPurgeCmdName,
+		Overwrite:  cacheOverwrite,
+		EmptyTiles: atlas.EmptyTiles(),
+		Variants:   seedPurgeVariants,
+		Maps:       make(map[string]runMap, len(maps)),
+	}
+	for _, m := range maps {
+		h.Maps[m.Name] = runMap{Version: m.Version, KeyVersion: atlas.KeyVersion(m)}
+	}
+	return h
+}
+
+// check returns an ErrRunMismatch if the settings of the worker h don't match the settings
+// of the coordinator
+func (h runHeader) check(coordinator runHeader) error {
+	if h.Command != coordinator.Command {
+		return ErrRunMismatch{Setting: "command", Coordinator: coordinator.Command, Worker: h.Command}
+	}
+	if h.Overwrite != coordinator.Overwrite {
+		return ErrRunMismatch{
+			Setting:     "overwrite",
+			Coordinator: strconv.FormatBool(coordinator.Overwrite),
+			Worker:      strconv.FormatBool(h.Overwrite),
+		}
+	}
+	if h.EmptyTiles != coordinator.EmptyTiles {
+		return ErrRunMismatch{
+			Setting:     "empty tiles mode",
+			Coordinator: emptyTilesString(coordinator.EmptyTiles),
+			Worker:      emptyTilesString(h.EmptyTiles),
+		}
+	}
+
+	// the variants are compared as they are stored in the header
+	coordinatorVariants, err := json.Marshal(coordinator.Variants)
+	if err != nil {
+		return err
+	}
+	workerVariants, err := json.Marshal(h.Variants)
+	if err != nil {
+		return err
+	}
+	if string(coordinatorVariants) != string(workerVariants) {
+		return ErrRunMismatch{Setting: "params and layers", Coordinator: string(coordinatorVariants), Worker: string(workerVariants)}
+	}
+
+	// the worker processes the tiles of the maps of the run with its own config, which
+	// must render the same layers under the same keys
+	names := make([]string, 0, len(coordinator.Maps))
+	for name := range coordinator.Maps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cm := coordinator.Maps[name]
+		wm, ok := h.Maps[name]
+		switch {
+		case !ok:
+			return ErrRunMismatch{Setting: fmt.Sprintf("map (%v)", name), Coordinator: "configured", Worker: "not configured"}
+		case wm.Version != cm.Version:
+			return ErrRunMismatch{Setting: fmt.Sprintf("config version of map (%v)", name), Coordinator: cm.Version, Worker: wm.Version}
+		case wm.KeyVersion != cm.KeyVersion:
+			return ErrRunMismatch{Setting: fmt.Sprintf("cache key version of map (%v)", name), Coordinator: cm.KeyVersion, Worker: wm.KeyVersion}
+		}
+	}
+
+	return nil
+}
+
+// emptyTilesString returns the flag which sets the empty tiles mode
+func emptyTilesString(mode atlas.EmptyTileMode) string {
+	switch mode {
+	case atlas.EmptyTileSkip:
+		return "skip-empty"
+	case atlas.EmptyTileSentinel:
+		return "empty-sentinel"
+	default:
+		return "store"
+	}
+}
+
+// queueStats is the state of a job queue
+type queueStats struct {
+	Enqueued int64
+	Acked    int64
+	Failed   int64
+	// Remaining is the number of jobs waiting or being worked on
+	Remaining int64
+	// Sealed is true once the coordinator pushed all the jobs
+	Sealed bool
+}
+
+// jobQueue distributes the jobs of a run from a coordinator to many workers
+type jobQueue interface {
+	// Reset prepares the queue for a new run with the header. It fails with
+	// ErrQueueNotEmpty if the queue has unfinished jobs.
+	Reset(ctx context.Context, header runHeader) error
+	// Header returns the header of the last run, or nil if the queue was never reset
+	Header(ctx context.Context) (*runHeader, error)
+	Push(ctx context.Context, job seedJob) error
+	// Seal marks that all the jobs of the run were pushed
+	Seal(ctx context.Context) error
+	// Claim returns the next job, or a job whose visibility timeout expired. It
+	// returns nil when no job becomes available within the poll interval.
+	Claim(ctx context.Context, consumer string) (*seedJob, error)
+	// Extend restarts the visibility timeout of a claimed job
+	Extend(ctx context.Context, consumer string, job *seedJob) error
+	// Ack removes a finished job from the queue
+	Ack(ctx context.Context, job *seedJob) error
+	Stats(ctx context.Context) (queueStats, error)
+	Close() error
+}
+
+// newJobQueue returns the job queue for the uri scheme
+func newJobQueue(uri, name string) (jobQueue, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queue uri (%v): %w", uri, err)
+	}
+
+	switch u.Scheme {
+	case "redis", "rediss":
+		return newRedisQueue(uri, name, cacheVisibilityTimeout, cacheMaxAttempts)
+	default:
+		return nil, fmt.Errorf("unsupported queue (%v). supported schemes are: redis, rediss", uri)
+	}
+}
+
+// distributedValidate validates the coordinator and worker flags and sets up the job queue
+func distributedValidate(cmd *cobra.Command) (err error) {
+	if !cacheCoordinator && !cacheWorker {
+		return nil
+	}
+
+	switch {
+	case cacheCoordinator && cacheWorker:
+		return fmt.Errorf("only one of coordinator or worker can be used")
+	case cacheQueue == "":
+		return fmt.Errorf("queue is required with coordinator and worker")
+	case cacheWorker && cmd != SeedPurgeCmd:
+		return fmt.Errorf("worker takes the tiles from the queue and can't be used with %v", cmd.Name())
+	case cacheCheckpointFile != "" || cacheRetryFile != "" || cacheReportFile != "":
+		return fmt.Errorf("checkpoint-file, retry-file and report-file can't be used with coordinator and worker")
+	case cacheJobSize <= 0:
+		return fmt.Errorf("invalid value for job-size (%v). expecting a positive number", cacheJobSize)
+	case cacheVisibilityTimeout <= 0:
+		return fmt.Errorf("invalid value for visibility-timeout (%v). expecting a positive duration", cacheVisibilityTimeout)
+	case cacheMaxAttempts <= 0:
+		return fmt.Errorf("invalid value for max-attempts (%v). expecting a positive number", cacheMaxAttempts)
+	}
+
+	seedPurgeQueue, err = newJobQueue(cacheQueue, cacheQueueName)
+	return err
+}
+
+// runTiles processes the generated tiles, or pushes them to the job queue when running as a coordinator.
+// A dry run only counts them.
+func runTiles(ctx context.Context, tileChannel *TileChannel, prog *progress) error {
+	if cacheDryRun {
+		return runDryRun(ctx, tileChannel, cacheSample)
+	}
+	if cacheCoordinator {
+		return runCoordinator(ctx, seedPurgeQueue, currentRunHeader(seedPurgeMaps), tileChannel, seedPurgeMaps, cacheJobSize)
+	}
+	return doWork(ctx, tileChannel, seedPurgeMaps, cacheConcurrency, prog, seedPurgeWorker)
+}
+
+// runCoordinator splits the tiles into jobs of jobSize tiles, pushes them to the queue with
+// the header of the run and waits for the workers to finish them
+func runCoordinator(ctx context.Context, q jobQueue, header runHeader, tileChannel *TileChannel, maps []atlas.Map, jobSize int) (err error) {
+	defer q.Close()
+
+	header.ID = strconv.FormatUint(rand.Uint64(), 36)
+	if err = q.Reset(ctx, header); err != nil {
+		return err
+	}
+
+	// the tiles are grouped into jobs by the maps they are processed for
+	type pendingJob struct {
+		maps  []string
+		tiles []string
+	}
+	var (
+		jobs    int
+		pending = map[string]*pendingJob{}
+		order   []string
+	)
+	push := func(job *pendingJob) error {
+		jobs++
+		err := q.Push(ctx, seedJob{Run: header.ID, Maps: job.maps, Tiles: job.tiles})
+		job.tiles = make([]string, 0, jobSize)
+		return err
+	}
+
+	for tile := range tileChannel.Channel() {
+		if err != nil {
+			// keep draining the channel so the generator can exit
+			continue
+		}
+
+		tileMaps := tileChannel.tileMaps(tile, maps)
+		names := make([]string, len(tileMaps))
+		for i := range tileMaps {
+			names[i] = tileMaps[i].Name
+		}
+		key := strings.Join(names, "\n")
+
+		job, ok := pending[key]
+		if !ok {
+			job = &pendingJob{maps: names, tiles: make([]string, 0, jobSize)}
+			pending[key] = job
+			order = append(order, key)
+		}
+		job.tiles = append(job.tiles, fmt.Sprintf("%v/%v/%v", tile.Z, tile.X, tile.Y))
+		if len(job.tiles) == jobSize {
+			err = push(job)
+		}
+	}
+	for _, key := range order {
+		if err == nil && len(pending[key].tiles) > 0 {
+			err = push(pending[key])
+		}
+	}
+	if err == nil {
+		err = tileChannel.Err()
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+
+	if err = q.Seal(ctx); err != nil {
+		return err
+	}
+	log.Infof("pushed %v jobs to the queue, waiting for the workers", jobs)
+
+	interval := cacheProgressInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	poll := time.NewTicker(queuePollInterval)
+	defer poll.Stop()
+	lastLog := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-poll.C:
+		}
+
+		stats, err := q.Stats(ctx)
+		if err != nil {
+			return err
+		}
+
+		if stats.Remaining == 0 {
+			log.Infof("all jobs are done: %v acked, %v failed", stats.Acked, stats.Failed)
+			if stats.Failed > 0 {
+				return fmt.Errorf("%v jobs failed after %v attempts", stats.Failed, cacheMaxAttempts)
+			}
+			return nil
+		}
+
+		if time.Since(lastLog) >= interval {
+			lastLog = time.Now()
+			log.Infof("jobs: %v/%v done, %v failed, %v remaining", stats.Acked, stats.Enqueued, stats.Failed, stats.Remaining)
+		}
+	}
+}
+
+// runWorker claims jobs from the queue and processes them with the seed / purge worker
+// until the coordinator pushed all the jobs and none are left. The settings of the worker,
+// in header, are checked against the header of the run of the jobs.
+func runWorker(ctx context.Context, q jobQueue, header runHeader, worker func(context.Context, MapTile) error) error {
+	defer q.Close()
+
+	// exit if the job in progress doesn't stop within 60 seconds of a cancellation
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		select {
+		case <-done:
+		case <-time.After(60 * time.Second):
+			log.Info("60 seconds passed killing")
+			os.Exit(1)
+		}
+	}()
+
+	// some cache backends buffer their writes, they are flushed once the worker is done
+	defer func() {
+		if err := atlas.FlushCache(context.Background()); err != nil {
+			log.Errorf("error flushing the cache: %v", err)
+		}
+	}()
+
+	// checked is the ID of the run whose header matches the settings of the worker
+	var checked string
+
+	host, _ := os.Hostname()
+	consumer := fmt.Sprintf("%v-%v", host, os.Getpid())
+	log.Infof("worker %v waiting for jobs", consumer)
+
+	for ctx.Err() == nil {
+		job, err := q.Claim(ctx, consumer)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+
+		if job == nil {
+			stats, err := q.Stats(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				return err
+			}
+			if stats.Sealed && stats.Remaining == 0 {
+				log.Infof("worker %v done, no jobs left", consumer)
+				return nil
+			}
+			continue
+		}
+
+		if job.Run != checked {
+			// the header is checked once per run, the worker outlives the run when
+			// another coordinator starts a run on the queue
+			if err = checkRun(ctx, q, header, job); err != nil {
+				return err
+			}
+			checked = job.Run
+		}
+
+		if err = runJob(ctx, q, consumer, job, worker); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			// the job is claimed again once its visibility timeout expires
+			log.Errorf("job %v failed: %v", job.ID, err)
+			continue
+		}
+
+		if err = q.Ack(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkRun checks the settings of the worker, in header, against the header of the run of job
+func checkRun(ctx context.Context, q jobQueue, header runHeader, job *seedJob) error {
+	coordinator, err := q.Header(ctx)
+	if err != nil {
+		return err
+	}
+	if coordinator == nil || coordinator.ID != job.Run {
+		return fmt.Errorf("job %v belongs to run (%v) which is not the current run of the queue", job.ID, job.Run)
+	}
+
+	return header.check(*coordinator)
+}
+
+// runJob processes the tiles of a job, extending its visibility timeout while it's worked on
+func runJob(ctx context.Context, q jobQueue, consumer string, job *seedJob, worker func(context.Context, MapTile) error) error {
+	maps := make([]atlas.Map, 0, len(job.Maps))
+	for _, name := range job.Maps {
+		m, err := atlas.GetMap(name)
+		if err != nil {
+			return err
+		}
+		maps = append(maps, m)
+	}
+
+	tiles := make([]slippy.Tile, 0, len(job.Tiles))
+	for _, t := range job.Tiles {
+		z, x, y, err := defaultTileNameFormat.Parse(t)
+		if err != nil {
+			return err
+		}
+		tiles = append(tiles, slippy.Tile{Z: slippy.Zoom(z), X: x, Y: y})
+	}
+
+	var wg sync.WaitGroup
+	heartbeatCtx, stop := context.WithCancel(ctx)
+	defer func() {
+		stop()
+		wg.Wait()
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(cacheVisibilityTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-heartbeatCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := q.Extend(heartbeatCtx, consumer, job); err != nil && heartbeatCtx.Err() == nil {
+				log.Warnf("extending the visibility timeout of job %v: %v", job.ID, err)
+			}
+		}
+	}()
+
+	err := processTiles(ctx, generateTilesForSlice(ctx, tiles), maps, cacheConcurrency, nil, worker, false)
+	if err == nil && ctx.Err() != nil {
+		// processTiles reports a cancellation as a success
+		err = ctx.Err()
+	}
+	return err
+}
+
+// generateTilesForSlice publishes the tiles of a slice, in order
+func generateTilesForSlice(ctx context.Context, tiles []slippy.Tile) *TileChannel {
+	tce := &TileChannel{
+		channel: make(chan slippy.Tile),
+	}
+
+	go func() {
+		defer tce.Close()
+		for _, tile := range tiles {
+			select {
+			case tce.channel <- tile:
+			case <-ctx.Done():
+				// we have been cancelled
+				return
+			}
+		}
+	}()
+	return tce
+}
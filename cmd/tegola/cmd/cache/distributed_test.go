@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+)
+
+func setQueueFlags(t *testing.T, visibility time.Duration) {
+	pollInterval, concurrency := queuePollInterval, cacheConcurrency
+	queuePollInterval, cacheConcurrency, cacheVisibilityTimeout, cacheMaxAttempts = 20*time.Millisecond, 2, visibility, 2
+	t.Cleanup(func() {
+		queuePollInterval, cacheConcurrency = pollInterval, concurrency
+	})
+}
+
+func TestDistributedSeed(t *testing.T) {
+	setQueueFlags(t, time.Minute)
+	atlas.AddMap(atlas.Map{Name: "distributed"})
+	maps := []atlas.Map{{Name: "distributed"}}
+
+	mr := miniredis.RunT(t)
+	uri := "redis://" + mr.Addr()
+	ctx := context.Background()
+
+	var (
+		wg        sync.WaitGroup
+		processed []func() sTiles
+		errs      = make([]error, 3)
+	)
+
+	// workers started before the coordinator wait for the queue to be set up
+	for i := 0; i < 2; i++ {
+		q, err := newRedisQueue(uri, "test", cacheVisibilityTimeout, cacheMaxAttempts)
+		if err != nil {
+			t.Fatalf("new queue, expected nil got %v", err)
+		}
+		worker, tiles := recordingWorker(nil)
+		processed = append(processed, tiles)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = runWorker(ctx, q, runHeader{Command: "seed"}, worker)
+		}(i)
+	}
+
+	q, err := newRedisQueue(uri, "test", cacheVisibilityTimeout, cacheMaxAttempts)
+	if err != nil {
+		t.Fatalf("new queue, expected nil got %v", err)
+	}
+	tileChannel := generateTilesForTileName(ctx, slippy.Tile{}, false, []uint{0, 1, 2})
+	errs[2] = runCoordinator(ctx, q, runHeader{Command: "seed"}, tileChannel, maps, 4)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("run %v, expected nil got %v", i, err)
+		}
+	}
+
+	seen := map[slippy.Tile]int{}
+	for _, tiles := range processed {
+		for _, tile := range tiles() {
+			seen[tile]++
+		}
+	}
+	for tile := range generateTilesForTileName(ctx, slippy.Tile{}, false, []uint{0, 1, 2}).Channel() {
+		if seen[tile] != 1 {
+			t.Errorf("tile %v, expected to be processed once got %v", tile, seen[tile])
+		}
+		delete(seen, tile)
+	}
+	if len(seen) != 0 {
+		t.Errorf("unexpected tiles processed: %v", seen)
+	}
+
+	// a new run can use the queue once it's empty
+	q, err = newRedisQueue(uri, "test", cacheVisibilityTimeout, cacheMaxAttempts)
+	if err != nil {
+		t.Fatalf("new queue, expected nil got %v", err)
+	}
+	defer q.Close()
+	if err = q.Reset(ctx, runHeader{}); err != nil {
+		t.Errorf("reset, expected nil got %v", err)
+	}
+}
+
+func TestDistributedRunMismatch(t *testing.T) {
+	setQueueFlags(t, time.Minute)
+	atlas.AddMap(atlas.Map{Name: "distributed"})
+	maps := []atlas.Map{{Name: "distributed"}}
+
+	coordinator := runHeader{
+		Command:    "seed",
+		Overwrite:  true,
+		EmptyTiles: atlas.EmptyTileSentinel,
+		Variants: map[string][]atlas.TileVariant{
+			"distributed": {{Params: map[string]string{"lang": "en"}}},
+		},
+		Maps: map[string]runMap{
+			"distributed": {Version: "v1", KeyVersion: "v1"},
+		},
+	}
+
+	type tcase struct {
+		worker  func(h runHeader) runHeader
+		setting string
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			uri := "redis://" + mr.Addr()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			q, err := newRedisQueue(uri, "test", cacheVisibilityTimeout, cacheMaxAttempts)
+			if err != nil {
+				t.Fatalf("new queue, expected nil got %v", err)
+			}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				runCoordinator(ctx, q, coordinator, generateTilesForTileName(ctx, slippy.Tile{}, false, []uint{0}), maps, 4)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			q, err = newRedisQueue(uri, "test", cacheVisibilityTimeout, cacheMaxAttempts)
+			if err != nil {
+				t.Fatalf("new queue, expected nil got %v", err)
+			}
+			worker, tiles := recordingWorker(nil)
+			err = runWorker(ctx, q, tc.worker(coordinator), worker)
+
+			var mismatch ErrRunMismatch
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("expected ErrRunMismatch got %v", err)
+			}
+			if mismatch.Setting != tc.setting {
+				t.Errorf("setting, expected %v got %v", tc.setting, mismatch.Setting)
+			}
+			if processed := tiles(); len(processed) != 0 {
+				t.Errorf("expected no tiles to be processed got %v", processed)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"command": {
+			worker: func(h runHeader) runHeader {
+				h.Command = "purge"
+				return h
+			},
+			setting: "command",
+		},
+		"overwrite": {
+			worker: func(h runHeader) runHeader {
+				h.Overwrite = false
+				return h
+			},
+			setting: "overwrite",
+		},
+		"empty tiles": {
+			worker: func(h runHeader) runHeader {
+				h.EmptyTiles = atlas.EmptyTileSkip
+				return h
+			},
+			setting: "empty tiles mode",
+		},
+		"variants": {
+			worker: func(h runHeader) runHeader {
+				h.Variants = nil
+				return h
+			},
+			setting: "params and layers",
+		},
+		"map config version": {
+			worker: func(h runHeader) runHeader {
+				h.Maps = map[string]runMap{"distributed": {Version: "v2", KeyVersion: "v1"}}
+				return h
+			},
+			setting: "config version of map (distributed)",
+		},
+		"map key version": {
+			worker: func(h runHeader) runHeader {
+				h.Maps = map[string]runMap{"distributed": {Version: "v1"}}
+				return h
+			},
+			setting: "cache key version of map (distributed)",
+		},
+		"missing map": {
+			worker: func(h runHeader) runHeader {
+				h.Maps = map[string]runMap{"other": {Version: "v1", KeyVersion: "v1"}}
+				return h
+			},
+			setting: "map (distributed)",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestRedisQueueVisibilityTimeout(t *testing.T) {
+	setQueueFlags(t, time.Minute)
+
+	mr := miniredis.RunT(t)
+	now := time.Now()
+	mr.SetTime(now)
+
+	ctx := context.Background()
+	q, err := newRedisQueue("redis://"+mr.Addr(), "test", cacheVisibilityTimeout, cacheMaxAttempts)
+	if err != nil {
+		t.Fatalf("new queue, expected nil got %v", err)
+	}
+	defer q.Close()
+
+	if err = q.Reset(ctx, runHeader{}); err != nil {
+		t.Fatalf("reset, expected nil got %v", err)
+	}
+	if err = q.Push(ctx, seedJob{Maps: []string{"osm"}, Tiles: []string{"0/0/0"}}); err != nil {
+		t.Fatalf("push, expected nil got %v", err)
+	}
+	if err = q.Seal(ctx); err != nil {
+		t.Fatalf("seal, expected nil got %v", err)
+	}
+
+	claim := func(consumer string) *seedJob {
+		t.Helper()
+		job, err := q.Claim(ctx, consumer)
+		if err != nil {
+			t.Fatalf("claim, expected nil got %v", err)
+		}
+		return job
+	}
+
+	first := claim("crashed")
+	if first == nil || len(first.Tiles) != 1 || first.Tiles[0] != "0/0/0" {
+		t.Fatalf("claim, expected job with tile 0/0/0 got %+v", first)
+	}
+	if job := claim("other"); job != nil {
+		t.Fatalf("claim before the visibility timeout, expected nil got %+v", job)
+	}
+
+	mr.SetTime(now.Add(2 * time.Minute))
+	second := claim("other")
+	if second == nil || second.ID != first.ID {
+		t.Fatalf("claim after the visibility timeout, expected job %v got %+v", first.ID, second)
+	}
+
+	// extending keeps the job hidden
+	mr.SetTime(now.Add(150 * time.Second))
+	if err = q.Extend(ctx, "other", second); err != nil {
+		t.Fatalf("extend, expected nil got %v", err)
+	}
+	mr.SetTime(now.Add(200 * time.Second))
+	if job := claim("third"); job != nil {
+		t.Fatalf("claim after extending, expected nil got %+v", job)
+	}
+
+	// the job was claimed max attempts times
+	mr.SetTime(now.Add(5 * time.Minute))
+	if job := claim("third"); job != nil {
+		t.Fatalf("claim after max attempts, expected nil got %+v", job)
+	}
+
+	stats, err := q.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats, expected nil got %v", err)
+	}
+	expected := queueStats{Enqueued: 1, Failed: 1, Sealed: true}
+	if stats != expected {
+		t.Errorf("stats, expected %+v got %+v", expected, stats)
+	}
+
+	// acking a failed job is a no-op
+	if err = q.Ack(ctx, second); err != nil {
+		t.Errorf("ack, expected nil got %v", err)
+	}
+	if stats, _ = q.Stats(ctx); stats != expected {
+		t.Errorf("stats after ack, expected %+v got %+v", expected, stats)
+	}
+}
@@ -0,0 +1,285 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-spatial/cobra"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/internal/log"
+)
+
+// flag parameters
+var (
+	// cacheDryRun counts the tiles the command would process, without touching the cache
+	cacheDryRun bool
+	// cacheSample is the number of tiles per zoom rendered by a dry run to estimate its cost
+	cacheSample int
+)
+
+// zoomEstimate is the dry run estimate of a zoom
+type zoomEstimate struct {
+	Zoom uint `json:"zoom"`
+	// Tiles is the number of tiles of the zoom
+	Tiles uint64 `json:"tiles"`
+	// Renders is the number of tiles rendered for every map, layer and parameter set
+	Renders uint64 `json:"renders"`
+	// Sampled is the number of sample tiles rendered
+	Sampled int `json:"sampled,omitempty"`
+	// the following are extrapolated from the sample tiles
+	RenderTime time.Duration `json:"render_time,omitempty"`
+	Bytes      uint64        `json:"bytes,omitempty"`
+	EmptyRatio float64       `json:"empty_ratio,omitempty"`
+
+	// sample holds the sample tiles, chosen uniformly among the tiles of the zoom
+	sample []slippy.Tile
+	// the measures of the rendered sample tiles
+	renderTime time.Duration
+	bytes      uint64
+	empty      int
+}
+
+// dryRunReport is written to the report file by a dry run
+type dryRunReport struct {
+	Command string          `json:"command"`
+	Maps    []string        `json:"maps"`
+	Sample  int             `json:"sample,omitempty"`
+	Zooms   []*zoomEstimate `json:"zooms"`
+	Tiles   uint64          `json:"tiles"`
+	Renders uint64          `json:"renders"`
+	// RenderTime is the extrapolated render time of all the tiles, and Duration the
+	// run time it amounts to with the concurrency of the command
+	RenderTime time.Duration `json:"render_time,omitempty"`
+	Duration   time.Duration `json:"duration,omitempty"`
+	Bytes      uint64        `json:"bytes,omitempty"`
+}
+
+// dryRunValidate validates the dry-run and sample flags
+func dryRunValidate(cmd *cobra.Command) error {
+	switch {
+	case !cacheDryRun && cmd.Flags().Changed("sample"):
+		return fmt.Errorf("sample can only be used with dry-run")
+	case !cacheDryRun:
+		return nil
+	case cacheSample < 0:
+		return fmt.Errorf("invalid value for sample (%v). expecting a positive number", cacheSample)
+	case cacheSample > 0 && seedPurgeCmdName != "seed":
+		return fmt.Errorf("sample can only be used when seeding")
+	case cacheCoordinator || cacheWorker:
+		return fmt.Errorf("dry-run can't be used with coordinator and worker")
+	case cacheCheckpointFile != "" || cacheRetryFile != "":
+		return fmt.Errorf("checkpoint-file and retry-file can't be used with dry-run")
+	}
+	return nil
+}
+
+// runDryRun counts the tiles per zoom and, when sample is positive, renders up to sample
+// tiles per zoom to extrapolate the cost of rendering all of them. The tiles of ranges are
+// counted and sampled without generating them, the other tiles are drained from the channel.
+// The estimate is logged to stdout, or written to the report file when set.
+func runDryRun(ctx context.Context, tileChannel *TileChannel, sample int) error {
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	zooms := map[uint]*zoomEstimate{}
+	if tileChannel.ranges != nil {
+		renders := variantCount(seedPurgeMaps, seedPurgeVariants)
+		for z, r := range tileChannel.ranges {
+			zooms[z] = &zoomEstimate{
+				Zoom:    z,
+				Tiles:   r.count(),
+				Renders: r.count() * renders,
+				sample:  r.sample(rnd, z, sample),
+			}
+		}
+		return dryRunEstimate(ctx, tileChannel, zooms, sample)
+	}
+
+	for tile := range tileChannel.Channel() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		z := uint(tile.Z)
+		ze, ok := zooms[z]
+		if !ok {
+			ze = &zoomEstimate{Zoom: z}
+			zooms[z] = ze
+		}
+		ze.Tiles++
+		ze.Renders += variantCount(tileChannel.tileMaps(tile, seedPurgeMaps), seedPurgeVariants)
+
+		// reservoir sampling
+		switch {
+		case len(ze.sample) < sample:
+			ze.sample = append(ze.sample, tile)
+		case sample > 0:
+			if i := rnd.Int63n(int64(ze.Tiles)); i < int64(sample) {
+				ze.sample[i] = tile
+			}
+		}
+	}
+	if err := tileChannel.Err(); err != nil {
+		return err
+	}
+
+	return dryRunEstimate(ctx, tileChannel, zooms, sample)
+}
+
+// dryRunEstimate renders the sample tiles of the zooms and reports the estimate
+func dryRunEstimate(ctx context.Context, tileChannel *TileChannel, zooms map[uint]*zoomEstimate, sample int) error {
+	report := dryRunReport{
+		Command: seedPurgeCmdName,
+		Sample:  sample,
+	}
+	for _, m := range seedPurgeMaps {
+		report.Maps = append(report.Maps, m.Name)
+	}
+
+	tileMaps := func(tile slippy.Tile) []atlas.Map { return tileChannel.tileMaps(tile, seedPurgeMaps) }
+	for _, ze := range zooms {
+		if err := ze.render(ctx, tileMaps, seedPurgeVariants); err != nil {
+			return err
+		}
+
+		report.Zooms = append(report.Zooms, ze)
+		report.Tiles += ze.Tiles
+		report.Renders += ze.Renders
+		report.RenderTime += ze.RenderTime
+		report.Bytes += ze.Bytes
+	}
+	sort.Slice(report.Zooms, func(i, j int) bool { return report.Zooms[i].Zoom < report.Zooms[j].Zoom })
+
+	if cacheConcurrency > 0 {
+		report.Duration = report.RenderTime / time.Duration(cacheConcurrency)
+	}
+
+	switch cacheReportFile {
+	case "":
+		return writeDryRunReport(os.Stdout, report)
+	case "-":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	default:
+		return writeJSONFile(cacheReportFile, report)
+	}
+}
+
+// sample returns n tiles of the range at zoom z, picked at random, or all the tiles of
+// the range when it has no more than n tiles
+func (r tileRange) sample(rnd *rand.Rand, z uint, n int) []slippy.Tile {
+	if n <= 0 {
+		return nil
+	}
+
+	var tiles []slippy.Tile
+	if r.count() <= uint64(n) {
+		for x := r.minX; x <= r.maxX; x++ {
+			for y := r.minY; y <= r.maxY; y++ {
+				tiles = append(tiles, slippy.Tile{Z: slippy.Zoom(z), X: x, Y: y})
+			}
+		}
+		return tiles
+	}
+
+	for i := 0; i < n; i++ {
+		tiles = append(tiles, slippy.Tile{
+			Z: slippy.Zoom(z),
+			X: r.minX + uint(rnd.Int63n(int64(r.maxX-r.minX+1))),
+			Y: r.minY + uint(rnd.Int63n(int64(r.maxY-r.minY+1))),
+		})
+	}
+	return tiles
+}
+
+// variantCount returns the number of tiles rendered for a tile of the maps
+func variantCount(maps []atlas.Map, variants map[string][]atlas.TileVariant) (n uint64) {
+	for _, m := range maps {
+		n += uint64(len(variants[m.Name]))
+	}
+	return n
+}
+
+// render renders the sample tiles of the zoom for every map of the tile and variant, and
+// extrapolates their measures to all the tiles of the zoom
+func (ze *zoomEstimate) render(ctx context.Context, tileMaps func(slippy.Tile) []atlas.Map, variants map[string][]atlas.TileVariant) error {
+	var renders int
+	for _, tile := range ze.sample {
+		for _, m := range tileMaps(tile) {
+			zm := m.FilterLayersByZoom(tile.Z)
+
+			for _, v := range variants[m.Name] {
+				vm := zm
+				if v.LayerName != "" {
+					if vm = zm.FilterLayersByName(v.LayerName); len(vm.Layers) == 0 {
+						continue
+					}
+				}
+				params, err := vm.ParamValues(v.Params)
+				if err != nil {
+					return err
+				}
+
+				t := time.Now()
+				b, empty, err := vm.EncodeCheckEmpty(ctx, tile, params)
+				if err != nil {
+					return fmt.Errorf("rendering map (%v) %v (%v/%v/%v): %w", m.Name, variantString(v), tile.Z, tile.X, tile.Y, err)
+				}
+				ze.renderTime += time.Since(t)
+				ze.bytes += uint64(len(b))
+
+				if empty {
+					ze.empty++
+				}
+				renders++
+			}
+		}
+	}
+
+	ze.Sampled = len(ze.sample)
+	if renders == 0 {
+		return nil
+	}
+
+	ze.RenderTime = ze.renderTime / time.Duration(renders) * time.Duration(ze.Renders)
+	ze.Bytes = ze.bytes * ze.Renders / uint64(renders)
+	ze.EmptyRatio = float64(ze.empty) / float64(renders)
+
+	log.Debugf("rendered %v sample tiles of zoom %v in %v", renders, ze.Zoom, ze.renderTime)
+	return nil
+}
+
+// writeDryRunReport writes the estimate as a table
+func writeDryRunReport(w io.Writer, r dryRunReport) error {
+	fmt.Fprintf(w, "%v dry run of maps: %v\n", r.Command, r.Maps)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	if r.Sample == 0 {
+		fmt.Fprintf(tw, "zoom\ttiles\trenders\t\n")
+		for _, ze := range r.Zooms {
+			fmt.Fprintf(tw, "%d\t%d\t%d\t\n", ze.Zoom, ze.Tiles, ze.Renders)
+		}
+		fmt.Fprintf(tw, "total\t%d\t%d\t\n", r.Tiles, r.Renders)
+		return tw.Flush()
+	}
+
+	fmt.Fprintf(tw, "zoom\ttiles\trenders\tsampled\trender time\tbytes\tempty\t\n")
+	for _, ze := range r.Zooms {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%v\t%d\t%.1f%%\t\n", ze.Zoom, ze.Tiles, ze.Renders, ze.Sampled, ze.RenderTime.Round(time.Second), ze.Bytes, ze.EmptyRatio*100)
+	}
+	fmt.Fprintf(tw, "total\t%d\t%d\t\t%v\t%d\t\t\n", r.Tiles, r.Renders, r.RenderTime.Round(time.Second), r.Bytes)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "estimated duration with a concurrency of %v: %v\n", cacheConcurrency, r.Duration.Round(time.Second))
+	return err
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/provider/test"
+)
+
+func TestRunDryRun(t *testing.T) {
+	type tcase struct {
+		sample  int
+		sampled []int
+	}
+
+	m := atlas.NewWebMercatorMap("osm")
+	m.Layers = []atlas.Layer{{
+		Name:              "points",
+		ProviderLayerName: "test-layer",
+		MinZoom:           0,
+		MaxZoom:           20,
+		Provider:          &test.TileProvider{},
+		GeomType:          geom.Point{},
+	}}
+
+	tiles := []slippy.Tile{
+		{Z: 0, X: 0, Y: 0},
+		{Z: 1, X: 0, Y: 0},
+		{Z: 1, X: 1, Y: 0},
+		{Z: 1, X: 0, Y: 1},
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			reportFile := filepath.Join(t.TempDir(), "report.json")
+			setProgressFlags(t, "", reportFile, "")
+
+			seedPurgeCmdName, seedPurgeMaps = "seed", []atlas.Map{m}
+			seedPurgeVariants = map[string][]atlas.TileVariant{
+				"osm": {{}, {LayerName: "points"}},
+			}
+			defer func() { seedPurgeMaps, seedPurgeVariants = nil, nil }()
+
+			err := runDryRun(context.Background(), generateTilesForSlice(context.Background(), tiles), tc.sample)
+			if err != nil {
+				t.Fatalf("dry run, expected nil got %v", err)
+			}
+
+			b, err := os.ReadFile(reportFile)
+			if err != nil {
+				t.Fatalf("read report, expected nil got %v", err)
+			}
+			var report dryRunReport
+			if err = json.Unmarshal(b, &report); err != nil {
+				t.Fatalf("decode report, expected nil got %v", err)
+			}
+
+			if report.Tiles != 4 || report.Renders != 8 {
+				t.Errorf("tiles and renders, expected 4 8 got %v %v", report.Tiles, report.Renders)
+			}
+			if len(report.Zooms) != 2 {
+				t.Fatalf("zooms, expected 2 got %v", len(report.Zooms))
+			}
+			for i, ze := range report.Zooms {
+				if ze.Sampled != tc.sampled[i] {
+					t.Errorf("zoom %v sampled, expected %v got %v", ze.Zoom, tc.sampled[i], ze.Sampled)
+				}
+				if (ze.Bytes > 0) != (tc.sample > 0) {
+					t.Errorf("zoom %v bytes, expected estimate %v got %v", ze.Zoom, tc.sample > 0, ze.Bytes)
+				}
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"count": {
+			sampled: []int{0, 0},
+		},
+		"sample": {
+			sample:  2,
+			sampled: []int{1, 2},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestRunDryRunRanges(t *testing.T) {
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	setProgressFlags(t, "", reportFile, "")
+
+	m := atlas.NewWebMercatorMap("osm")
+	m.Layers = []atlas.Layer{{
+		Name:              "points",
+		ProviderLayerName: "test-layer",
+		MinZoom:           0,
+		MaxZoom:           20,
+		Provider:          &test.TileProvider{},
+		GeomType:          geom.Point{},
+	}}
+	seedPurgeCmdName, seedPurgeMaps = "seed", []atlas.Map{m}
+	seedPurgeVariants = map[string][]atlas.TileVariant{"osm": {{}}}
+	defer func() { seedPurgeMaps, seedPurgeVariants = nil, nil }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the 4^20 tiles of zoom 20 are counted, not generated
+	tile := slippy.Tile{Z: 1, X: 1, Y: 0}
+	zooms := []uint{0, 1, 2, 20}
+	if err := runDryRun(ctx, generateTilesForTileName(ctx, tile, false, zooms), 3); err != nil {
+		t.Fatalf("dry run, expected nil got %v", err)
+	}
+
+	b, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatalf("read report, expected nil got %v", err)
+	}
+	var report dryRunReport
+	if err = json.Unmarshal(b, &report); err != nil {
+		t.Fatalf("decode report, expected nil got %v", err)
+	}
+
+	expected := countTilesForTileName(tile, false, zooms)
+	sampled := map[uint]int{0: 1, 1: 1, 2: 3, 20: 3}
+	if len(report.Zooms) != len(expected) {
+		t.Fatalf("zooms, expected %v got %v", len(expected), len(report.Zooms))
+	}
+	for _, ze := range report.Zooms {
+		if ze.Tiles != expected[ze.Zoom] || ze.Renders != expected[ze.Zoom] {
+			t.Errorf("zoom %v tiles and renders, expected %v got %v %v", ze.Zoom, expected[ze.Zoom], ze.Tiles, ze.Renders)
+		}
+		if ze.Sampled != sampled[ze.Zoom] {
+			t.Errorf("zoom %v sampled, expected %v got %v", ze.Zoom, sampled[ze.Zoom], ze.Sampled)
+		}
+	}
+}
+
+func TestTileRangeSample(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	r := tileRange{minX: 8, minY: 4, maxX: 15, maxY: 7}
+
+	tiles := r.sample(rnd, 5, 10)
+	if len(tiles) != 10 {
+		t.Fatalf("sample, expected 10 tiles got %v", len(tiles))
+	}
+	for _, tile := range tiles {
+		if tile.Z != 5 || tile.X < r.minX || tile.X > r.maxX || tile.Y < r.minY || tile.Y > r.maxY {
+			t.Errorf("sample tile, expected within %+v at zoom 5 got %v", r, tile)
+		}
+	}
+
+	// small ranges are sampled whole
+	if tiles = r.sample(rnd, 5, 100); uint64(len(tiles)) != r.count() {
+		t.Errorf("sample, expected %v tiles got %v", r.count(), len(tiles))
+	}
+}
@@ -0,0 +1,422 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/go-spatial/geom/encoding/wkt"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/proj"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// flag parameters
+var (
+	// cacheGeometryFile is the path to a GeoJSON or WKT file holding the geometry to seed / purge.
+	// the geometry is expected to be in the --bounds-srid
+	cacheGeometryFile string
+	// cacheFromLayer is a map layer, in the format map.layer, whose features are used as the geometry to seed / purge
+	cacheFromLayer string
+	// cacheGeometryBuffer is the distance, in units of the geometry's srid, to buffer the geometry by
+	cacheGeometryBuffer float64
+)
+
+// seedPurgeGeometry is the geometry read from the --geometry-file flag
+var seedPurgeGeometry geom.Geometry
+
+// readGeometryFile reads a GeoJSON geometry, feature or feature collection, or a WKT geometry from filename
+func readGeometryFile(filename string) (geom.Geometry, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, fmt.Errorf("geometry file (%v) is empty", filename)
+	}
+
+	if b[0] != '{' {
+		g, err := wkt.DecodeBytes(b)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding WKT geometry file (%v): %w", filename, err)
+		}
+		return g, nil
+	}
+
+	g, err := decodeGeoJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding GeoJSON geometry file (%v): %w", filename, err)
+	}
+	return g, nil
+}
+
+func decodeGeoJSON(b []byte) (geom.Geometry, error) {
+	v, err := geojson.Unmarshal(b)
+	if errors.Is(err, geojson.ErrUnknownFeatureType) {
+		// a bare geometry
+		var g geojson.Geometry
+		if err = g.UnmarshalJSON(b); err != nil {
+			return nil, err
+		}
+		return g.Geometry, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch f := v.(type) {
+	case geojson.Feature:
+		return f.Geometry.Geometry, nil
+	case geojson.FeatureCollection:
+		var c geom.Collection
+		for _, feature := range f.Features {
+			if feature.Geometry.Geometry != nil {
+				c = append(c, feature.Geometry.Geometry)
+			}
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unsupported GeoJSON type %T", v)
+	}
+}
+
+// geometryFromLayer collects the geometries of the features of a map layer, given in the format map.layer.
+// The geometries are returned in web mercator.
+func geometryFromLayer(ctx context.Context, mapLayer string) (geom.Geometry, error) {
+	parts := strings.SplitN(mapLayer, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid value for from-layer (%v). expecting map.layer", mapLayer)
+	}
+
+	m, err := atlas.GetMap(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var layer *atlas.Layer
+	for i := range m.Layers {
+		if m.Layers[i].MVTName() == parts[1] {
+			layer = &m.Layers[i]
+			break
+		}
+	}
+	if layer == nil {
+		return nil, fmt.Errorf("layer (%v) not found in map (%v)", parts[1], parts[0])
+	}
+	if layer.Provider == nil {
+		return nil, fmt.Errorf("the provider of layer (%v) can not be queried for features", mapLayer)
+	}
+
+	var c geom.Collection
+	// the zoom 0 tile covers the whole layer
+	tile := provider.NewTile(0, 0, 0, 0, uint(proj.WebMercator))
+	err = layer.Provider.TileFeatures(ctx, layer.ProviderLayerName, tile, nil, func(f *provider.Feature) error {
+		if f.Geometry != nil {
+			c = append(c, f.Geometry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching the features of layer (%v): %w", mapLayer, err)
+	}
+	if len(c) == 0 {
+		return nil, fmt.Errorf("layer (%v) has no features", mapLayer)
+	}
+
+	return c, nil
+}
+
+// coverage is a geometry flattened for tile intersection tests
+type coverage struct {
+	// segments of the lines and polygon rings. points are stored as zero length segments
+	segments [][2][2]float64
+	// ring is set for the segments belonging to a polygon ring
+	ring []bool
+	// bands indexes the ring segments by the horizontal bands they span, for point in polygon tests
+	bands    [][]int32
+	minY     float64
+	bandSize float64
+	buffer   float64
+}
+
+const coverageBands = 1024
+
+func newCoverage(g geom.Geometry, buffer float64) (*coverage, error) {
+	cov := coverage{buffer: buffer}
+	if err := cov.add(g); err != nil {
+		return nil, err
+	}
+	if len(cov.segments) == 0 {
+		return nil, fmt.Errorf("geometry is empty")
+	}
+
+	// index the ring segments
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for i, seg := range cov.segments {
+		if !cov.ring[i] {
+			continue
+		}
+		minY = math.Min(minY, math.Min(seg[0][1], seg[1][1]))
+		maxY = math.Max(maxY, math.Max(seg[0][1], seg[1][1]))
+	}
+	if math.IsInf(minY, 1) {
+		return &cov, nil
+	}
+
+	cov.minY = minY
+	cov.bandSize = (maxY - minY) / coverageBands
+	if cov.bandSize == 0 {
+		cov.bandSize = 1
+	}
+	cov.bands = make([][]int32, coverageBands)
+	for i, seg := range cov.segments {
+		if !cov.ring[i] {
+			continue
+		}
+		from, to := cov.band(math.Min(seg[0][1], seg[1][1])), cov.band(math.Max(seg[0][1], seg[1][1]))
+		for b := from; b <= to; b++ {
+			cov.bands[b] = append(cov.bands[b], int32(i))
+		}
+	}
+
+	return &cov, nil
+}
+
+func (cov *coverage) band(y float64) int {
+	b := int((y - cov.minY) / cov.bandSize)
+	if b < 0 {
+		return 0
+	}
+	if b >= coverageBands {
+		return coverageBands - 1
+	}
+	return b
+}
+
+func (cov *coverage) addLine(pts [][2]float64, ring bool) {
+	if len(pts) == 1 {
+		cov.segments = append(cov.segments, [2][2]float64{pts[0], pts[0]})
+		cov.ring = append(cov.ring, false)
+		return
+	}
+	for i := 0; i < len(pts)-1; i++ {
+		cov.segments = append(cov.segments, [2][2]float64{pts[i], pts[i+1]})
+		cov.ring = append(cov.ring, ring)
+	}
+	// rings are not required to be closed
+	if ring && len(pts) > 2 && pts[0] != pts[len(pts)-1] {
+		cov.segments = append(cov.segments, [2][2]float64{pts[len(pts)-1], pts[0]})
+		cov.ring = append(cov.ring, true)
+	}
+}
+
+func (cov *coverage) add(g geom.Geometry) error {
+	switch g := g.(type) {
+	case nil:
+		return nil
+	case geom.Pointer:
+		cov.addLine([][2]float64{g.XY()}, false)
+	case geom.MultiPointer:
+		for _, pt := range g.Points() {
+			cov.addLine([][2]float64{pt}, false)
+		}
+	case geom.LineStringer:
+		cov.addLine(g.Vertices(), false)
+	case geom.MultiLineStringer:
+		for _, ls := range g.LineStrings() {
+			cov.addLine(ls, false)
+		}
+	case geom.Polygoner:
+		for _, ring := range g.LinearRings() {
+			cov.addLine(ring, true)
+		}
+	case geom.MultiPolygoner:
+		for _, p := range g.Polygons() {
+			for _, ring := range p {
+				cov.addLine(ring, true)
+			}
+		}
+	case geom.Collectioner:
+		for _, cg := range g.Geometries() {
+			if err := cov.add(cg); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported geometry type %T", g)
+	}
+	return nil
+}
+
+// inside reports if pt is inside the polygons of the coverage, using the even-odd rule
+func (cov *coverage) inside(pt [2]float64) bool {
+	if cov.bands == nil || pt[1] < cov.minY || pt[1] > cov.minY+cov.bandSize*coverageBands {
+		return false
+	}
+
+	in := false
+	for _, i := range cov.bands[cov.band(pt[1])] {
+		a, b := cov.segments[i][0], cov.segments[i][1]
+		if (a[1] > pt[1]) == (b[1] > pt[1]) {
+			continue
+		}
+		if pt[0] < a[0]+(pt[1]-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+			in = !in
+		}
+	}
+	return in
+}
+
+// segmentIntersects reports if the segment intersects the extent, using the Liang-Barsky algorithm
+func segmentIntersects(seg [2][2]float64, ext *geom.Extent) bool {
+	t0, t1 := 0.0, 1.0
+	dx, dy := seg[1][0]-seg[0][0], seg[1][1]-seg[0][1]
+
+	for _, edge := range [4][2]float64{
+		{-dx, seg[0][0] - ext.MinX()},
+		{dx, ext.MaxX() - seg[0][0]},
+		{-dy, seg[0][1] - ext.MinY()},
+		{dy, ext.MaxY() - seg[0][1]},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return false
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 {
+			if r > t1 {
+				return false
+			}
+			t0 = math.Max(t0, r)
+		} else {
+			if r < t0 {
+				return false
+			}
+			t1 = math.Min(t1, r)
+		}
+	}
+	return true
+}
+
+// coverageCell is a tile crossed by the boundary of the coverage, along with the
+// indices of the segments crossing it
+type coverageCell struct {
+	tile     slippy.Tile
+	segments []int32
+}
+
+// generateTilesForGeometry generates the tiles of each zoom intersecting the geometry
+// buffered by buffer. The geometry is expected to be in the grid's srid.
+func generateTilesForGeometry(ctx context.Context, g geom.Geometry, buffer float64, zooms []uint, grid slippy.TileGridder) *TileChannel {
+	tce := &TileChannel{
+		channel: make(chan slippy.Tile),
+	}
+
+	if grid == nil {
+		grid = slippy.NewGrid(proj.EPSGCode(cacheBoundsSRID), 0)
+	}
+
+	go func() {
+		defer tce.Close()
+
+		if len(zooms) == 0 {
+			return
+		}
+
+		cov, err := newCoverage(g, buffer)
+		if err != nil {
+			tce.setError(err)
+			return
+		}
+
+		wanted := make(map[uint]bool, len(zooms))
+		maxZoom := uint(0)
+		for _, z := range zooms {
+			wanted[z] = true
+			if z > maxZoom {
+				maxZoom = z
+			}
+		}
+
+		send := func(tile slippy.Tile) bool {
+			select {
+			case tce.channel <- tile:
+				return true
+			case <-ctx.Done():
+				// we have been cancelled
+				return false
+			}
+		}
+
+		all := make([]int32, len(cov.segments))
+		for i := range all {
+			all[i] = int32(i)
+		}
+		cells := []coverageCell{{tile: slippy.Tile{}, segments: all}}
+		// tiles fully inside the coverage. all their descendants intersect it
+		var inside []slippy.Tile
+
+		for z := uint(0); z <= maxZoom; z++ {
+			var next []coverageCell
+			for _, cell := range cells {
+				ext, err := slippy.Extent(grid, cell.tile)
+				if err != nil {
+					tce.setError(fmt.Errorf("got error trying to get tiles: %w", err))
+					return
+				}
+				bext := ext.ExpandBy(cov.buffer)
+
+				var segments []int32
+				for _, i := range cell.segments {
+					if segmentIntersects(cov.segments[i], bext) {
+						segments = append(segments, i)
+					}
+				}
+
+				switch {
+				case len(segments) > 0:
+					if wanted[z] && !send(cell.tile) {
+						return
+					}
+					if z == maxZoom {
+						continue
+					}
+					for child := range cell.tile.FamilyAt(slippy.Zoom(z + 1)) {
+						next = append(next, coverageCell{tile: child, segments: segments})
+					}
+
+				case cov.inside([2]float64{(ext.MinX() + ext.MaxX()) / 2, (ext.MinY() + ext.MaxY()) / 2}):
+					inside = append(inside, cell.tile)
+				}
+			}
+
+			if wanted[z] {
+				for _, tile := range inside {
+					n := uint(1) << (z - uint(tile.Z))
+					for x := tile.X * n; x < (tile.X+1)*n; x++ {
+						for y := tile.Y * n; y < (tile.Y+1)*n; y++ {
+							if !send(slippy.Tile{Z: slippy.Zoom(z), X: x, Y: y}) {
+								return
+							}
+						}
+					}
+				}
+			}
+
+			cells = next
+		}
+	}()
+	return tce
+}
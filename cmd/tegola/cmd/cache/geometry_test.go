@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/proj"
+)
+
+func TestGenerateTilesForGeometry(t *testing.T) {
+	grid := slippy.NewGrid(proj.EPSG4326, 0)
+
+	type tcase struct {
+		geometry geom.Geometry
+		buffer   float64
+		zooms    []uint
+		tiles    sTiles
+	}
+
+	fn := func(tc tcase) func(t *testing.T) {
+		return func(t *testing.T) {
+			tilechannel := generateTilesForGeometry(context.Background(), tc.geometry, tc.buffer, tc.zooms, grid)
+			tiles := make(sTiles, 0, len(tc.tiles))
+			for tile := range tilechannel.Channel() {
+				tiles = append(tiles, tile)
+			}
+			if err := tilechannel.Err(); err != nil {
+				t.Fatalf("error, expected nil got %v", err)
+			}
+
+			sort.Sort(tiles)
+			sort.Sort(tc.tiles)
+			if !tc.tiles.IsEqual(tiles) {
+				t.Errorf("unexpected tile list generated, expected %v got %v", tc.tiles, tiles)
+			}
+		}
+	}
+
+	// every tile of zoom 2 but x=1,y=1
+	var holed sTiles
+	for x := uint(0); x < 4; x++ {
+		for y := uint(0); y < 4; y++ {
+			if x != 1 || y != 1 {
+				holed = append(holed, slippy.Tile{Z: 2, X: x, Y: y})
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"polygon": {
+			geometry: geom.Polygon{{{10, 10}, {170, 10}, {170, 80}, {10, 80}}},
+			zooms:    []uint{0, 1, 2},
+			tiles: sTiles{
+				{Z: 0},
+				{Z: 1, X: 1, Y: 0},
+				{Z: 2, X: 2, Y: 0}, {Z: 2, X: 2, Y: 1}, {Z: 2, X: 3, Y: 0}, {Z: 2, X: 3, Y: 1},
+			},
+		},
+		"polygon with hole": {
+			geometry: geom.Polygon{
+				{{-170, -80}, {170, -80}, {170, 80}, {-170, 80}},
+				{{-100, -10}, {10, -10}, {10, 70}, {-100, 70}},
+			},
+			zooms: []uint{2},
+			tiles: holed,
+		},
+		"point": {
+			geometry: geom.Point{5, 5},
+			zooms:    []uint{2},
+			tiles:    sTiles{{Z: 2, X: 2, Y: 1}},
+		},
+		"buffered point": {
+			geometry: geom.Point{5, 5},
+			buffer:   10,
+			zooms:    []uint{2},
+			tiles:    sTiles{{Z: 2, X: 1, Y: 1}, {Z: 2, X: 1, Y: 2}, {Z: 2, X: 2, Y: 1}, {Z: 2, X: 2, Y: 2}},
+		},
+		"line": {
+			geometry: geom.LineString{{-45, 30}, {45, 30}},
+			zooms:    []uint{2},
+			tiles:    sTiles{{Z: 2, X: 1, Y: 1}, {Z: 2, X: 2, Y: 1}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+// TestGenerateTilesForGeometryBruteForce checks the generated tiles against the
+// tiles of the bounds of the geometry filtered one by one.
+func TestGenerateTilesForGeometryBruteForce(t *testing.T) {
+	grid := slippy.NewGrid(proj.EPSG4326, 0)
+	triangle := geom.Polygon{{{-30, -20}, {60, 10}, {-10, 50}}}
+	zoom := uint(6)
+
+	cov, err := newCoverage(triangle, 0)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var expected sTiles
+	for x := uint(0); x < 1<<zoom; x++ {
+		for y := uint(0); y < 1<<zoom; y++ {
+			tile := slippy.Tile{Z: slippy.Zoom(zoom), X: x, Y: y}
+			ext, err := slippy.Extent(grid, tile)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			intersects := cov.inside([2]float64{(ext.MinX() + ext.MaxX()) / 2, (ext.MinY() + ext.MaxY()) / 2})
+			for _, seg := range cov.segments {
+				intersects = intersects || segmentIntersects(seg, ext)
+			}
+			if intersects {
+				expected = append(expected, tile)
+			}
+		}
+	}
+
+	tilechannel := generateTilesForGeometry(context.Background(), triangle, 0, []uint{zoom}, grid)
+	var tiles sTiles
+	for tile := range tilechannel.Channel() {
+		tiles = append(tiles, tile)
+	}
+	if err := tilechannel.Err(); err != nil {
+		t.Fatalf("error, expected nil got %v", err)
+	}
+
+	sort.Sort(tiles)
+	sort.Sort(expected)
+	if !expected.IsEqual(tiles) {
+		t.Errorf("unexpected tile list generated, expected %v got %v", expected, tiles)
+	}
+}
+
+func TestReadGeometryFile(t *testing.T) {
+	type tcase struct {
+		contents string
+		expected geom.Geometry
+	}
+
+	fn := func(tc tcase) func(t *testing.T) {
+		return func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "geometry")
+			if err := os.WriteFile(filename, []byte(tc.contents), 0666); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			g, err := readGeometryFile(filename)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if !reflect.DeepEqual(g, tc.expected) {
+				t.Errorf("expected %#v got %#v", tc.expected, g)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"wkt": {
+			contents: "POINT (1 2)",
+			expected: geom.Point{1, 2},
+		},
+		"geojson geometry": {
+			contents: `{"type": "Point", "coordinates": [1, 2]}`,
+			expected: geom.Point{1, 2},
+		},
+		"geojson feature": {
+			contents: `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, 2]}, "properties": {}}`,
+			expected: geom.Point{1, 2},
+		},
+		"geojson feature collection": {
+			contents: `{"type": "FeatureCollection", "features": [
+				{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, 2]}, "properties": {}},
+				{"type": "Feature", "geometry": {"type": "Point", "coordinates": [3, 4]}, "properties": {}}
+			]}`,
+			expected: geom.Collection{geom.Point{1, 2}, geom.Point{3, 4}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
@@ -0,0 +1,283 @@
+package cache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/tilestats"
+)
+
+// flag parameters
+var (
+	// cacheFromStats seeds the tiles recorded by the server tile_stats
+	cacheFromStats bool
+	// cacheFromLog is an HTTP access log file whose tile requests are seeded. "-" reads stdin
+	cacheFromLog string
+	// cacheTop is the number of most requested tiles to seed. 0 means all of them
+	cacheTop int
+	// cacheMinHits is the number of requests a tile needs to be seeded
+	cacheMinHits uint64
+)
+
+var (
+	// clfRequest matches the request line and the status of the common and combined
+	// log formats used by apache and nginx, i.e. "GET /maps/osm/1/0/1.pbf HTTP/1.1" 200
+	clfRequest = regexp.MustCompile(`"(?:GET|HEAD) (\S+) HTTP/[0-9.]+" (\d{3})`)
+	// mapsPath matches a tile path in the other log formats, i.e. JSON or tab separated logs
+	mapsPath = regexp.MustCompile(`[^\s"']*/maps/[^\s"'?]+`)
+)
+
+// tileCounts holds the number of requests of the tiles, by map name
+type tileCounts map[string]map[slippy.Tile]uint64
+
+// add adds hits to the count of the tile of the map
+func (tc tileCounts) add(mapName string, tile slippy.Tile, hits uint64) {
+	counts, ok := tc[mapName]
+	if !ok {
+		counts = map[slippy.Tile]uint64{}
+		tc[mapName] = counts
+	}
+	counts[tile] += hits
+}
+
+// hotTileCounts returns the number of requests of the tiles of seedPurgeMaps, read from the
+// tile stats store or the access log
+func hotTileCounts(ctx context.Context) (tileCounts, error) {
+	if cacheFromLog != "" {
+		return readAccessLogFile(cacheFromLog)
+	}
+
+	if Config == nil || len(Config.TileStats) == 0 {
+		return nil, fmt.Errorf("from-stats requires tile_stats to be configured")
+	}
+
+	store, err := tilestats.New(Config.TileStats)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	counts := tileCounts{}
+	for _, m := range seedPurgeMaps {
+		tiles, err := store.Top(ctx, m.Name, 0, 1)
+		if err != nil {
+			return nil, err
+		}
+		for _, tc := range tiles {
+			counts.add(m.Name, slippy.Tile{Z: slippy.Zoom(tc.Z), X: tc.X, Y: tc.Y}, tc.Hits)
+		}
+	}
+
+	return counts, nil
+}
+
+// readAccessLogFile parses an access log file, which can be gzipped
+func readAccessLogFile(filename string) (tileCounts, error) {
+	var in io.Reader = os.Stdin
+
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+
+		if strings.HasSuffix(filename, ".gz") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return nil, fmt.Errorf("reading %v: %w", filename, err)
+			}
+			defer gz.Close()
+			in = gz
+		}
+	}
+
+	names := make(map[string]bool, len(seedPurgeMaps))
+	for _, m := range seedPurgeMaps {
+		names[m.Name] = true
+	}
+
+	return parseAccessLog(in, names)
+}
+
+// parseAccessLog counts the successful requests of the tiles of the maps in an HTTP access log.
+// Lines in the common or combined log formats are only counted for 2xx and 304 statuses. For
+// other formats, the first /maps/ tile path of the line is counted.
+func parseAccessLog(r io.Reader, maps map[string]bool) (tileCounts, error) {
+	counts := tileCounts{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines, requests int
+	for scanner.Scan() {
+		lines++
+		line := scanner.Text()
+
+		var path string
+		if m := clfRequest.FindStringSubmatch(line); m != nil {
+			if !strings.HasPrefix(m[2], "2") && m[2] != "304" {
+				continue
+			}
+			path = m[1]
+		} else {
+			path = mapsPath.FindString(line)
+		}
+
+		mapName, tile, ok := parseTilePath(path)
+		if !ok || !maps[mapName] {
+			continue
+		}
+		counts.add(mapName, tile, 1)
+		requests++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var tiles int
+	for _, mapCounts := range counts {
+		tiles += len(mapCounts)
+	}
+	log.Infof("found %v tile requests of %v map tiles in %v log lines", requests, tiles, lines)
+	return counts, nil
+}
+
+// parseTilePath parses the map name and the tile of a /maps/:map_name/:z/:x/:y or
+// /maps/:map_name/:layer_name/:z/:x/:y path. The path can have a prefix, a tile
+// extension and query parameters.
+func parseTilePath(path string) (mapName string, tile slippy.Tile, ok bool) {
+	i := strings.Index(path, "/maps/")
+	if i < 0 {
+		return "", tile, false
+	}
+	path = path[i+len("/maps/"):]
+	if i = strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 4 && len(parts) != 5 {
+		return "", tile, false
+	}
+
+	zxy := parts[len(parts)-3:]
+	// trim the extension of the y value
+	if i = strings.Index(zxy[2], "."); i >= 0 {
+		zxy[2] = zxy[2][:i]
+	}
+
+	z, x, y, err := defaultTileNameFormat.Parse(strings.Join(zxy, "/"))
+	if err != nil {
+		return "", tile, false
+	}
+
+	return parts[0], slippy.Tile{Z: slippy.Zoom(z), X: x, Y: y}, true
+}
+
+// hotTiles returns the tiles of the zooms requested at least minHits times, the most
+// requested first, limited to the top n tiles when n > 0
+func hotTiles(counts map[slippy.Tile]uint64, zooms []uint, n int, minHits uint64) []slippy.Tile {
+	inZooms := make(map[slippy.Zoom]bool, len(zooms))
+	for _, z := range zooms {
+		inZooms[slippy.Zoom(z)] = true
+	}
+
+	tiles := make([]slippy.Tile, 0, len(counts))
+	for tile, hits := range counts {
+		if hits >= minHits && inZooms[tile.Z] {
+			tiles = append(tiles, tile)
+		}
+	}
+
+	sort.Slice(tiles, func(i, j int) bool {
+		a, b := tiles[i], tiles[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Y < b.Y
+	})
+
+	if n > 0 && len(tiles) > n {
+		tiles = tiles[:n]
+	}
+	return tiles
+}
+
+// hotMapTiles applies hotTiles to the counts of each map. It returns the tiles of all the
+// maps, the top tiles of every map first, and the names of the maps each tile is hot for.
+func hotMapTiles(counts tileCounts, maps []atlas.Map, zooms []uint, n int, minHits uint64) ([]slippy.Tile, map[slippy.Tile][]string) {
+	var (
+		byMap   = make([][]slippy.Tile, len(maps))
+		longest int
+	)
+	for i, m := range maps {
+		byMap[i] = hotTiles(counts[m.Name], zooms, n, minHits)
+		if len(byMap[i]) > longest {
+			longest = len(byMap[i])
+		}
+	}
+
+	var tiles []slippy.Tile
+	mapNames := map[slippy.Tile][]string{}
+	for rank := 0; rank < longest; rank++ {
+		for i, m := range maps {
+			if rank >= len(byMap[i]) {
+				continue
+			}
+			tile := byMap[i][rank]
+			if _, ok := mapNames[tile]; !ok {
+				tiles = append(tiles, tile)
+			}
+			mapNames[tile] = append(mapNames[tile], m.Name)
+		}
+	}
+
+	return tiles, mapNames
+}
+
+// countTiles returns the number of tiles per zoom
+func countTiles(tiles []slippy.Tile) map[uint]uint64 {
+	totals := map[uint]uint64{}
+	for _, tile := range tiles {
+		totals[uint(tile.Z)]++
+	}
+	return totals
+}
+
+// tilesFingerprint identifies a list of tiles, and the maps of each tile, in the checkpoint
+// fingerprint
+func tilesFingerprint(tiles []slippy.Tile, mapNames map[slippy.Tile][]string) string {
+	var sb strings.Builder
+	for _, tile := range tiles {
+		sb.WriteString(strconv.Itoa(int(tile.Z)))
+		sb.WriteByte('/')
+		sb.WriteString(strconv.FormatUint(uint64(tile.X), 10))
+		sb.WriteByte('/')
+		sb.WriteString(strconv.FormatUint(uint64(tile.Y), 10))
+		for _, name := range mapNames[tile] {
+			sb.WriteByte(' ')
+			sb.WriteString(name)
+		}
+		sb.WriteByte('\n')
+	}
+	return runFingerprint(sb.String())
+}
@@ -0,0 +1,251 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+)
+
+func TestParseTilePath(t *testing.T) {
+	type tcase struct {
+		path    string
+		mapName string
+		tile    slippy.Tile
+		ok      bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			mapName, tile, ok := parseTilePath(tc.path)
+			if ok != tc.ok {
+				t.Fatalf("ok, expected %v got %v", tc.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if mapName != tc.mapName || tile != tc.tile {
+				t.Errorf("tile, expected %v %v got %v %v", tc.mapName, tc.tile, mapName, tile)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"map": {
+			path:    "/maps/osm/2/1/3.pbf",
+			mapName: "osm",
+			tile:    slippy.Tile{Z: 2, X: 1, Y: 3},
+			ok:      true,
+		},
+		"map layer": {
+			path:    "/maps/osm/roads/2/1/3.pbf",
+			mapName: "osm",
+			tile:    slippy.Tile{Z: 2, X: 1, Y: 3},
+			ok:      true,
+		},
+		"prefix and query": {
+			path:    "https://example.com/tegola/maps/osm/2/1/3?param=value",
+			mapName: "osm",
+			tile:    slippy.Tile{Z: 2, X: 1, Y: 3},
+			ok:      true,
+		},
+		"style": {
+			path: "/maps/osm/style.json",
+		},
+		"out of range": {
+			path: "/maps/osm/2/1/4.pbf",
+		},
+		"capabilities": {
+			path: "/capabilities/osm.json",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestParseAccessLog(t *testing.T) {
+	logs := strings.Join([]string{
+		// combined
+		`127.0.0.1 - - [10/Oct/2024:13:55:36 -0700] "GET /maps/osm/2/1/3.pbf HTTP/1.1" 200 2326 "-" "Mozilla/5.0"`,
+		`127.0.0.1 - - [10/Oct/2024:13:55:37 -0700] "GET /maps/osm/roads/2/1/3.pbf HTTP/2.0" 304 0 "-" "Mozilla/5.0"`,
+		`127.0.0.1 - - [10/Oct/2024:13:55:38 -0700] "GET /tegola/maps/osm/0/0/0.pbf?debug=true HTTP/1.1" 200 100`,
+		// not counted
+		`127.0.0.1 - - [10/Oct/2024:13:55:39 -0700] "GET /maps/osm/2/1/2.pbf HTTP/1.1" 500 0`,
+		`127.0.0.1 - - [10/Oct/2024:13:55:40 -0700] "GET /maps/other/2/1/2.pbf HTTP/1.1" 200 10`,
+		`127.0.0.1 - - [10/Oct/2024:13:55:41 -0700] "GET /maps/osm/style.json HTTP/1.1" 200 10`,
+		`not a log line`,
+		// json
+		`{"time":"2024-10-10T13:55:42Z","method":"GET","path":"/maps/osm/2/1/3.pbf","status":200}`,
+	}, "\n")
+
+	counts, err := parseAccessLog(strings.NewReader(logs), map[string]bool{"osm": true})
+	if err != nil {
+		t.Fatalf("parse, expected nil got %v", err)
+	}
+
+	expected := tileCounts{
+		"osm": {
+			{Z: 2, X: 1, Y: 3}: 3,
+			{Z: 0, X: 0, Y: 0}: 1,
+		},
+	}
+	if !reflect.DeepEqual(expected, counts) {
+		t.Errorf("counts, expected %v got %v", expected, counts)
+	}
+}
+
+func TestHotTiles(t *testing.T) {
+	type tcase struct {
+		zooms    []uint
+		n        int
+		minHits  uint64
+		expected []slippy.Tile
+	}
+
+	counts := map[slippy.Tile]uint64{
+		{Z: 0, X: 0, Y: 0}: 10,
+		{Z: 1, X: 1, Y: 0}: 4,
+		{Z: 1, X: 0, Y: 0}: 4,
+		{Z: 2, X: 3, Y: 3}: 7,
+		{Z: 3, X: 1, Y: 1}: 1,
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			got := hotTiles(counts, tc.zooms, tc.n, tc.minHits)
+			if !reflect.DeepEqual(tc.expected, got) {
+				t.Errorf("tiles, expected %v got %v", tc.expected, got)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"all": {
+			zooms: []uint{0, 1, 2, 3},
+			expected: []slippy.Tile{
+				{Z: 0, X: 0, Y: 0},
+				{Z: 2, X: 3, Y: 3},
+				{Z: 1, X: 0, Y: 0},
+				{Z: 1, X: 1, Y: 0},
+				{Z: 3, X: 1, Y: 1},
+			},
+		},
+		"top": {
+			zooms: []uint{0, 1, 2, 3},
+			n:     2,
+			expected: []slippy.Tile{
+				{Z: 0, X: 0, Y: 0},
+				{Z: 2, X: 3, Y: 3},
+			},
+		},
+		"min hits": {
+			zooms:   []uint{0, 1, 2, 3},
+			minHits: 5,
+			expected: []slippy.Tile{
+				{Z: 0, X: 0, Y: 0},
+				{Z: 2, X: 3, Y: 3},
+			},
+		},
+		"zooms": {
+			zooms: []uint{1, 3},
+			n:     2,
+			expected: []slippy.Tile{
+				{Z: 1, X: 0, Y: 0},
+				{Z: 1, X: 1, Y: 0},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestHotMapTiles(t *testing.T) {
+	counts := tileCounts{
+		"a": {
+			{Z: 0, X: 0, Y: 0}: 10,
+			{Z: 1, X: 0, Y: 0}: 8,
+			{Z: 1, X: 1, Y: 0}: 6,
+		},
+		"b": {
+			{Z: 1, X: 1, Y: 1}: 3,
+			{Z: 1, X: 1, Y: 0}: 2,
+			{Z: 0, X: 0, Y: 0}: 1,
+		},
+	}
+	maps := []atlas.Map{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+
+	tiles, mapNames := hotMapTiles(counts, maps, []uint{0, 1}, 2, 0)
+
+	// the top 2 of each map, not the top 2 of the summed counts
+	expectedTiles := []slippy.Tile{
+		{Z: 0, X: 0, Y: 0},
+		{Z: 1, X: 1, Y: 1},
+		{Z: 1, X: 0, Y: 0},
+		{Z: 1, X: 1, Y: 0},
+	}
+	if !reflect.DeepEqual(expectedTiles, tiles) {
+		t.Errorf("tiles, expected %v got %v", expectedTiles, tiles)
+	}
+
+	expectedNames := map[slippy.Tile][]string{
+		{Z: 0, X: 0, Y: 0}: {"a"},
+		{Z: 1, X: 1, Y: 1}: {"b"},
+		{Z: 1, X: 0, Y: 0}: {"a"},
+		{Z: 1, X: 1, Y: 0}: {"b"},
+	}
+	if !reflect.DeepEqual(expectedNames, mapNames) {
+		t.Errorf("map names, expected %v got %v", expectedNames, mapNames)
+	}
+
+	tc := TileChannel{mapNames: mapNames}
+	if got := tc.tileMaps(slippy.Tile{Z: 1, X: 1, Y: 1}, maps); len(got) != 1 || got[0].Name != "b" {
+		t.Errorf("tile maps, expected [b] got %v", got)
+	}
+}
+
+func TestDoWorkHotMapTiles(t *testing.T) {
+	setProgressFlags(t, "", "", "")
+
+	var (
+		mu   sync.Mutex
+		seen = map[string][]slippy.Tile{}
+	)
+	worker := func(_ context.Context, mt MapTile) error {
+		mu.Lock()
+		seen[mt.MapName] = append(seen[mt.MapName], mt.Tile)
+		mu.Unlock()
+		return nil
+	}
+	maps := []atlas.Map{{Name: "a"}, {Name: "b"}}
+
+	prog, err := newProgress("seed", []string{"a", "b"}, nil, "fingerprint")
+	if err != nil {
+		t.Fatalf("new progress, expected nil got %v", err)
+	}
+
+	tiles := []slippy.Tile{{Z: 0}, {Z: 1, X: 1}}
+	tileChannel := generateTilesForSlice(context.Background(), tiles)
+	tileChannel.mapNames = map[slippy.Tile][]string{
+		{Z: 0}:       {"a", "b"},
+		{Z: 1, X: 1}: {"b"},
+	}
+	if err = doWork(context.Background(), tileChannel, maps, 1, prog, worker); err != nil {
+		t.Fatalf("run, expected nil got %v", err)
+	}
+
+	expected := map[string][]slippy.Tile{
+		"a": {{Z: 0}},
+		"b": {{Z: 0}, {Z: 1, X: 1}},
+	}
+	if !reflect.DeepEqual(expected, seen) {
+		t.Errorf("processed tiles, expected %v got %v", expected, seen)
+	}
+}
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/internal/log"
+)
+
+// flag parameters
+var (
+	// cacheParams are query parameter values, in the format name=value, used for every tile
+	cacheParams []string
+	// cacheParamFile is a CSV file of query parameter sets. The header holds the parameter
+	// names and each record a set of values. Empty values use the parameter default
+	cacheParamFile string
+	// cacheLayers are the layers whose individual tiles are used instead of the map tiles
+	cacheLayers []string
+)
+
+// seedPurgeVariants are the variants of the tiles of each map which are seeded or purged,
+// by map name. Set by the command.
+var seedPurgeVariants map[string][]atlas.TileVariant
+
+// ParseParamFlags parses name=value parameter flags
+func ParseParamFlags(flags []string) (map[string]string, error) {
+	values := make(map[string]string, len(flags))
+	for _, f := range flags {
+		name, val, ok := strings.Cut(f, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid value for param (%v). expecting name=value", f)
+		}
+		if _, ok := values[name]; ok {
+			return nil, fmt.Errorf("param (%v) is set more than once", name)
+		}
+		values[name] = val
+	}
+	return values, nil
+}
+
+// readParamSets reads the query parameter sets of a CSV file
+func readParamSets(filename string) ([]map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sets, err := parseParamSets(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %v: %w", filename, err)
+	}
+	return sets, nil
+}
+
+// parseParamSets parses CSV records of query parameter values. The first record holds the
+// parameter names. Empty values are left out of the set so the parameter default is used.
+func parseParamSets(r io.Reader) ([]map[string]string, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing header with the parameter names")
+	}
+
+	header := records[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+		if header[i] == "" {
+			return nil, fmt.Errorf("empty parameter name in column %v", i+1)
+		}
+	}
+
+	sets := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		set := make(map[string]string, len(header))
+		for i, val := range record {
+			if val != "" {
+				set[header[i]] = val
+			}
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// tileVariants combines each parameter set with the common parameter values and
+// each layer. A nil sets is a single empty set, and no layers means the map tile.
+func tileVariants(common map[string]string, sets []map[string]string, layers []string) ([]atlas.TileVariant, error) {
+	if sets == nil {
+		sets = []map[string]string{{}}
+	}
+	if len(layers) == 0 {
+		layers = []string{""}
+	}
+
+	var variants []atlas.TileVariant
+	for _, set := range sets {
+		params := make(map[string]string, len(common)+len(set))
+		for name, val := range set {
+			params[name] = val
+		}
+		for name, val := range common {
+			if _, ok := params[name]; ok {
+				return nil, fmt.Errorf("param (%v) is set by both the param flag and the param file", name)
+			}
+			params[name] = val
+		}
+		if len(params) == 0 {
+			params = nil
+		}
+
+		for _, layer := range layers {
+			variants = append(variants, atlas.TileVariant{LayerName: layer, Params: params})
+		}
+	}
+	return variants, nil
+}
+
+// mapTileVariants returns the variants which apply to each map. A variant doesn't apply to a
+// map which doesn't have its layer or one of its query parameters, when its parameter
+// values are not valid for the map, or when its tiles have live layers, which are never
+// read from the cache. Every variant is expected to apply to at least one map.
+func mapTileVariants(maps []atlas.Map, variants []atlas.TileVariant) (map[string][]atlas.TileVariant, error) {
+	byMap := make(map[string][]atlas.TileVariant, len(maps))
+	used := make([]bool, len(variants))
+	// live tracks the variants skipped for maps with live layers
+	live := make([]bool, len(variants))
+
+	for _, m := range maps {
+	VariantLoop:
+		for i, v := range variants {
+			if v.LayerName != "" && len(m.FilterLayersByName(v.LayerName).Layers) == 0 {
+				continue
+			}
+			for name := range v.Params {
+				if !m.HasParam(name) {
+					continue VariantLoop
+				}
+			}
+			if _, err := m.ParamValues(v.Params); err != nil {
+				log.Warnf("skipping %v of map (%v): %v", variantString(v), m.Name, err)
+				continue
+			}
+			vm := m
+			if v.LayerName != "" {
+				vm = m.FilterLayersByName(v.LayerName)
+			}
+			if !vm.Cacheable() {
+				log.Warnf("skipping %v of map (%v): its tiles have live layers, which are not cached", variantString(v), m.Name)
+				live[i] = true
+				continue
+			}
+
+			byMap[m.Name] = append(byMap[m.Name], v)
+			used[i] = true
+		}
+
+		if len(byMap[m.Name]) == 0 {
+			log.Warnf("skipping map (%v): none of the layers and params apply to it", m.Name)
+		}
+	}
+
+	for i, v := range variants {
+		switch {
+		case !used[i] && live[i]:
+			return nil, fmt.Errorf("%v only applies to maps with live layers, whose tiles are not cached", variantString(v))
+		case !used[i]:
+			return nil, fmt.Errorf("%v does not apply to any of the maps", variantString(v))
+		}
+	}
+	return byMap, nil
+}
+
+// variantString describes a tile variant in logs and errors
+func variantString(v atlas.TileVariant) string {
+	var parts []string
+	if v.LayerName != "" {
+		parts = append(parts, fmt.Sprintf("layer (%v)", v.LayerName))
+	}
+	if len(v.Params) > 0 {
+		names := make([]string, 0, len(v.Params))
+		for name := range v.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			names[i] = name + "=" + v.Params[name]
+		}
+		parts = append(parts, fmt.Sprintf("params (%v)", strings.Join(names, ", ")))
+	}
+	if len(parts) == 0 {
+		return "map tile"
+	}
+	return strings.Join(parts, " with ")
+}
+
+// variantsValidate sets seedPurgeVariants from the param, param-file and layers flags
+func variantsValidate() error {
+	common, err := ParseParamFlags(cacheParams)
+	if err != nil {
+		return err
+	}
+
+	var sets []map[string]string
+	if cacheParamFile != "" {
+		if sets, err = readParamSets(cacheParamFile); err != nil {
+			return err
+		}
+		if len(sets) == 0 {
+			return fmt.Errorf("param file (%v) has no parameter sets", cacheParamFile)
+		}
+	}
+
+	for i, layer := range cacheLayers {
+		if cacheLayers[i] = strings.TrimSpace(layer); cacheLayers[i] == "" {
+			return fmt.Errorf("invalid value for layers (%v). expecting layer names", strings.Join(cacheLayers, ","))
+		}
+	}
+
+	variants, err := tileVariants(common, sets, cacheLayers)
+	if err != nil {
+		return err
+	}
+
+	if seedPurgeVariants, err = mapTileVariants(seedPurgeMaps, variants); err != nil {
+		return err
+	}
+
+	// the maps none of the variants apply to, such as maps with live layers, are skipped
+	maps := seedPurgeMaps[:0]
+	for _, m := range seedPurgeMaps {
+		if len(seedPurgeVariants[m.Name]) > 0 {
+			maps = append(maps, m)
+		}
+	}
+	seedPurgeMaps = maps
+	return nil
+}
@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/provider"
+	"github.com/go-spatial/tegola/provider/test"
+)
+
+func TestParseParamSets(t *testing.T) {
+	type tcase struct {
+		csv      string
+		expected []map[string]string
+		err      bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			got, err := parseParamSets(strings.NewReader(tc.csv))
+			if (err != nil) != tc.err {
+				t.Fatalf("error, expected %v got %v", tc.err, err)
+			}
+			if !reflect.DeepEqual(tc.expected, got) {
+				t.Errorf("sets, expected %v got %v", tc.expected, got)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"sets": {
+			csv: "year,kind\n2020,road\n2021,\n",
+			expected: []map[string]string{
+				{"year": "2020", "kind": "road"},
+				{"year": "2021"},
+			},
+		},
+		"header only": {
+			csv:      "year\n",
+			expected: []map[string]string{},
+		},
+		"empty": {
+			err: true,
+		},
+		"empty name": {
+			csv: "year,\n2020,road\n",
+			err: true,
+		},
+		"missing value": {
+			csv: "year,kind\n2020\n",
+			err: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestTileVariants(t *testing.T) {
+	type tcase struct {
+		common   map[string]string
+		sets     []map[string]string
+		layers   []string
+		expected []atlas.TileVariant
+		err      bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			got, err := tileVariants(tc.common, tc.sets, tc.layers)
+			if (err != nil) != tc.err {
+				t.Fatalf("error, expected %v got %v", tc.err, err)
+			}
+			if !reflect.DeepEqual(tc.expected, got) {
+				t.Errorf("variants, expected %v got %v", tc.expected, got)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"map tile": {
+			expected: []atlas.TileVariant{{}},
+		},
+		"common params": {
+			common:   map[string]string{"year": "2020"},
+			expected: []atlas.TileVariant{{Params: map[string]string{"year": "2020"}}},
+		},
+		"sets and layers": {
+			common: map[string]string{"kind": "road"},
+			sets:   []map[string]string{{"year": "2020"}, {"year": "2021"}},
+			layers: []string{"roads", "rivers"},
+			expected: []atlas.TileVariant{
+				{LayerName: "roads", Params: map[string]string{"year": "2020", "kind": "road"}},
+				{LayerName: "rivers", Params: map[string]string{"year": "2020", "kind": "road"}},
+				{LayerName: "roads", Params: map[string]string{"year": "2021", "kind": "road"}},
+				{LayerName: "rivers", Params: map[string]string{"year": "2021", "kind": "road"}},
+			},
+		},
+		"conflict": {
+			common: map[string]string{"year": "2020"},
+			sets:   []map[string]string{{"year": "2021"}},
+			err:    true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+// liveTileProvider is a provider whose features are live
+type liveTileProvider struct {
+	*test.TileProvider
+}
+
+func (liveTileProvider) Live() bool { return true }
+
+func TestMapTileVariants(t *testing.T) {
+	layer := atlas.Layer{
+		Name:              "roads",
+		ProviderLayerName: "test-layer",
+		Provider:          &test.TileProvider{},
+		GeomType:          geom.Line{},
+	}
+
+	plain := atlas.NewWebMercatorMap("plain")
+	plain.Layers = []atlas.Layer{layer}
+
+	params := atlas.NewWebMercatorMap("params")
+	params.Layers = []atlas.Layer{layer}
+	params.Params = []provider.QueryParameter{
+		{Name: "year", Token: "!YEAR!", Type: "int", SQL: "?", DefaultValue: "2020"},
+	}
+
+	required := atlas.NewWebMercatorMap("required")
+	required.Params = []provider.QueryParameter{
+		{Name: "year", Token: "!YEAR!", Type: "int", SQL: "?"},
+	}
+
+	live := atlas.NewWebMercatorMap("live")
+	live.Layers = []atlas.Layer{layer, {
+		Name:              "vehicles",
+		ProviderLayerName: "test-layer",
+		Provider:          liveTileProvider{&test.TileProvider{}},
+		GeomType:          geom.Point{},
+	}}
+
+	maps := []atlas.Map{plain, params, required, live}
+
+	type tcase struct {
+		variants []atlas.TileVariant
+		expected map[string][]atlas.TileVariant
+		err      bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			got, err := mapTileVariants(maps, tc.variants)
+			if (err != nil) != tc.err {
+				t.Fatalf("error, expected %v got %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(tc.expected, got) {
+				t.Errorf("variants, expected %v got %v", tc.expected, got)
+			}
+		}
+	}
+
+	year := map[string]string{"year": "2021"}
+
+	tests := map[string]tcase{
+		"map tile": {
+			variants: []atlas.TileVariant{{}},
+			expected: map[string][]atlas.TileVariant{
+				"plain":  {{}},
+				"params": {{}},
+			},
+		},
+		"params": {
+			variants: []atlas.TileVariant{{Params: year}},
+			expected: map[string][]atlas.TileVariant{
+				"params":   {{Params: year}},
+				"required": {{Params: year}},
+			},
+		},
+		"layer": {
+			variants: []atlas.TileVariant{{LayerName: "roads"}},
+			expected: map[string][]atlas.TileVariant{
+				"plain":  {{LayerName: "roads"}},
+				"params": {{LayerName: "roads"}},
+				"live":   {{LayerName: "roads"}},
+			},
+		},
+		"live layer": {
+			variants: []atlas.TileVariant{{LayerName: "vehicles"}},
+			err:      true,
+		},
+		"unknown layer": {
+			variants: []atlas.TileVariant{{LayerName: "rivers"}},
+			err:      true,
+		},
+		"unknown param": {
+			variants: []atlas.TileVariant{{Params: map[string]string{"kind": "road"}}},
+			err:      true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
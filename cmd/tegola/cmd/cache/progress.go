@@ -0,0 +1,531 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/internal/log"
+)
+
+// flag parameters
+var (
+	// cacheCheckpointFile is the file completed tile ranges are recorded to, so an interrupted run can be resumed
+	cacheCheckpointFile string
+	// cacheProgressInterval is how often the progress is logged and the checkpoint written
+	cacheProgressInterval time.Duration
+	// cacheReportFile is the file the final JSON report is written to. "-" writes it to stdout
+	cacheReportFile string
+	// cacheRetryFile is the file failed tiles are appended to. when set, failed tiles don't stop the run
+	cacheRetryFile string
+)
+
+// checkpoint is the state of a run stored in the checkpoint file
+type checkpoint struct {
+	// Fingerprint identifies the run the checkpoint was written for
+	Fingerprint string    `json:"fingerprint"`
+	Updated     time.Time `json:"updated"`
+	// Completed is the number of tiles per zoom, in generation order, which are all done
+	Completed map[uint]uint64 `json:"completed"`
+}
+
+// readCheckpoint reads the checkpoint file. A missing file is an empty checkpoint.
+func readCheckpoint(filename, fingerprint string) (*checkpoint, error) {
+	cp := checkpoint{
+		Fingerprint: fingerprint,
+		Completed:   map[uint]uint64{},
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &cp, nil
+		}
+		return nil, err
+	}
+
+	var stored checkpoint
+	if err = json.Unmarshal(b, &stored); err != nil {
+		return nil, fmt.Errorf("error decoding checkpoint file (%v): %w", filename, err)
+	}
+	if stored.Fingerprint != fingerprint {
+		return nil, fmt.Errorf("checkpoint file (%v) was written by a run with different flags or maps. remove it to start over", filename)
+	}
+	if stored.Completed != nil {
+		cp.Completed = stored.Completed
+	}
+
+	return &cp, nil
+}
+
+// writeJSONFile atomically replaces filename with the JSON encoding of v
+func writeJSONFile(filename string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filename + "-tmp"
+	if err = os.WriteFile(tmp, b, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// runFingerprint hashes the values identifying a run
+func runFingerprint(values ...interface{}) string {
+	h := sha256.New()
+	for _, v := range values {
+		fmt.Fprintf(h, "%v\n", v)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// zoomProgress is the progress of a zoom
+type zoomProgress struct {
+	Zoom uint `json:"zoom"`
+	// Total is the number of tiles of the zoom. 0 when unknown
+	Total uint64 `json:"total,omitempty"`
+	// Done is the number of tiles processed by this run
+	Done uint64 `json:"done"`
+	// Skipped is the number of tiles completed by a previous run
+	Skipped uint64 `json:"skipped"`
+	Errors  uint64 `json:"errors"`
+	// Rate is the number of tiles processed per second
+	Rate float64 `json:"tiles_per_second"`
+
+	started time.Time
+	// dispatched is the number of tiles handed to the workers or skipped, in generation order
+	dispatched uint64
+	// completed is the number of tiles, in generation order, which are all done
+	completed uint64
+	// pending holds the number of map tiles left for the tiles in flight
+	pending map[uint64]int
+	// failed holds the tiles which failed for at least one map
+	failed map[uint64]bool
+}
+
+func (zp *zoomProgress) rate(now time.Time) float64 {
+	elapsed := now.Sub(zp.started).Seconds()
+	if zp.started.IsZero() || elapsed <= 0 {
+		return 0
+	}
+	return float64(zp.Done) / elapsed
+}
+
+// progress tracks the tiles processed by a seed / purge run
+type progress struct {
+	mu      sync.Mutex
+	command string
+	maps    []string
+	started time.Time
+	zooms   map[uint]*zoomProgress
+
+	checkpointFile string
+	checkpoint     *checkpoint
+
+	retryFile   string
+	retryWriter io.WriteCloser
+
+	// problems counts the bad tiles found by verify, by kind
+	problems map[string]uint64
+}
+
+// newProgress sets up the progress tracking of a run. totals, the number of tiles per
+// zoom, can be nil when unknown. fingerprint identifies the run in the checkpoint file.
+func newProgress(command string, maps []string, totals map[uint]uint64, fingerprint string) (*progress, error) {
+	p := progress{
+		command:        command,
+		maps:           maps,
+		started:        time.Now(),
+		zooms:          map[uint]*zoomProgress{},
+		checkpointFile: cacheCheckpointFile,
+		retryFile:      cacheRetryFile,
+	}
+
+	for z, total := range totals {
+		p.zoom(z).Total = total
+	}
+
+	if p.checkpointFile != "" {
+		cp, err := readCheckpoint(p.checkpointFile, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		p.checkpoint = cp
+		for z, completed := range cp.Completed {
+			if completed > 0 {
+				log.Infof("resuming zoom %v after %v completed tiles", z, completed)
+			}
+		}
+	}
+
+	if p.retryFile != "" {
+		f, err := os.OpenFile(p.retryFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return nil, err
+		}
+		p.retryWriter = f
+	}
+
+	return &p, nil
+}
+
+func (p *progress) zoom(z uint) *zoomProgress {
+	zp, ok := p.zooms[z]
+	if !ok {
+		zp = &zoomProgress{
+			Zoom:    z,
+			pending: map[uint64]int{},
+			failed:  map[uint64]bool{},
+		}
+		p.zooms[z] = zp
+	}
+	return zp
+}
+
+// start registers a tile read from the tile channel, to be processed for the given number
+// of maps. skip is true if the tile was completed by a previous run.
+func (p *progress) start(tile slippy.Tile, maps int) (seq uint64, skip bool) {
+	if p == nil {
+		return 0, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	zp := p.zoom(uint(tile.Z))
+	seq = zp.dispatched
+	zp.dispatched++
+
+	if p.checkpoint != nil && seq < p.checkpoint.Completed[uint(tile.Z)] {
+		zp.Skipped++
+		zp.completed = seq + 1
+		return seq, true
+	}
+
+	if zp.started.IsZero() {
+		zp.started = time.Now()
+	}
+	zp.pending[seq] = maps
+	return seq, false
+}
+
+// retries reports if failed tiles are recorded instead of stopping the run
+func (p *progress) retries() bool {
+	return p != nil && p.retryWriter != nil
+}
+
+// finish registers a processed map tile. err is the error of the worker, if any.
+func (p *progress) finish(mt MapTile, err error) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	zp := p.zoom(uint(mt.Tile.Z))
+
+	if err != nil && !zp.failed[mt.seq] {
+		zp.failed[mt.seq] = true
+		zp.Errors++
+		if p.retryWriter != nil {
+			if _, werr := fmt.Fprintf(p.retryWriter, "%v/%v/%v\n", mt.Tile.Z, mt.Tile.X, mt.Tile.Y); werr != nil {
+				log.Errorf("error writing to retry file (%v): %v", p.retryFile, werr)
+			}
+		}
+	}
+
+	zp.pending[mt.seq]--
+	if zp.pending[mt.seq] > 0 {
+		return
+	}
+	delete(zp.pending, mt.seq)
+	delete(zp.failed, mt.seq)
+	zp.Done++
+
+	// advance over the tiles done in generation order
+	for zp.completed < zp.dispatched {
+		if _, inFlight := zp.pending[zp.completed]; inFlight {
+			break
+		}
+		zp.completed++
+	}
+}
+
+// problem counts a bad tile of the given kind found by verify
+func (p *progress) problem(kind string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.problems == nil {
+		p.problems = map[string]uint64{}
+	}
+	p.problems[kind]++
+}
+
+// run logs the progress and writes the checkpoint every interval until ctx is done
+func (p *progress) run(ctx context.Context, interval time.Duration) {
+	if p == nil || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		p.logProgress()
+		if err := p.writeCheckpoint(); err != nil {
+			log.Errorf("error writing checkpoint file (%v): %v", p.checkpointFile, err)
+		}
+	}
+}
+
+// sortedZooms returns the zooms with any activity, sorted
+func (p *progress) sortedZooms() []*zoomProgress {
+	zps := make([]*zoomProgress, 0, len(p.zooms))
+	for _, zp := range p.zooms {
+		if zp.dispatched > 0 {
+			zps = append(zps, zp)
+		}
+	}
+	sort.Slice(zps, func(i, j int) bool { return zps[i].Zoom < zps[j].Zoom })
+	return zps
+}
+
+func (p *progress) logProgress() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for _, zp := range p.sortedZooms() {
+		done := zp.Done + zp.Skipped
+		rate := zp.rate(now)
+
+		if zp.Total == 0 {
+			log.Infof("zoom %v: %v tiles, %.1f tiles/s, %v errors", zp.Zoom, done, rate, zp.Errors)
+			continue
+		}
+
+		eta := "unknown"
+		if done >= zp.Total {
+			eta = "done"
+		} else if rate > 0 {
+			eta = (time.Duration(float64(zp.Total-done)/rate) * time.Second).String()
+		}
+		log.Infof("zoom %v: %v/%v tiles (%.1f%%), %.1f tiles/s, eta %v, %v errors",
+			zp.Zoom, done, zp.Total, 100*float64(done)/float64(zp.Total), rate, eta, zp.Errors)
+	}
+}
+
+func (p *progress) writeCheckpoint() error {
+	if p == nil || p.checkpoint == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	for z, zp := range p.zooms {
+		p.checkpoint.Completed[z] = zp.completed
+	}
+	p.checkpoint.Updated = time.Now()
+	err := writeJSONFile(p.checkpointFile, p.checkpoint)
+	p.mu.Unlock()
+
+	return err
+}
+
+// report is the final JSON report of a run
+type report struct {
+	Command         string          `json:"command"`
+	Maps            []string        `json:"maps"`
+	Started         time.Time       `json:"started"`
+	Finished        time.Time       `json:"finished"`
+	DurationSeconds float64         `json:"duration_seconds"`
+	Done            uint64          `json:"done"`
+	Skipped         uint64          `json:"skipped"`
+	Errors          uint64          `json:"errors"`
+	Zooms           []*zoomProgress `json:"zooms"`
+	RetryFile       string          `json:"retry_file,omitempty"`
+	// Problems is the number of bad tiles found by verify, by kind
+	Problems map[string]uint64 `json:"problems,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+func (p *progress) report(runErr error) report {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	r := report{
+		Command:         p.command,
+		Maps:            p.maps,
+		Started:         p.started,
+		Finished:        now,
+		DurationSeconds: now.Sub(p.started).Seconds(),
+		Zooms:           p.sortedZooms(),
+		RetryFile:       p.retryFile,
+		Problems:        p.problems,
+	}
+	for _, zp := range r.Zooms {
+		zp.Rate = zp.rate(now)
+		r.Done += zp.Done
+		r.Skipped += zp.Skipped
+		r.Errors += zp.Errors
+	}
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+	return r
+}
+
+// close writes the checkpoint and the report of the run, which ended with runErr
+func (p *progress) close(runErr error) error {
+	if p == nil {
+		return nil
+	}
+
+	var errs []error
+	if p.retryWriter != nil {
+		errs = append(errs, p.retryWriter.Close())
+	}
+	errs = append(errs, p.writeCheckpoint())
+
+	p.logProgress()
+
+	if cacheReportFile != "" {
+		r := p.report(runErr)
+		if cacheReportFile == "-" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			errs = append(errs, enc.Encode(r))
+		} else {
+			if err := os.MkdirAll(filepath.Dir(cacheReportFile), os.ModePerm); err != nil {
+				errs = append(errs, err)
+			}
+			errs = append(errs, writeJSONFile(cacheReportFile, r))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// newRunProgress sets up the progress of the current seed / purge run. args are the
+// flags, besides the maps and zooms, which identify the run in the checkpoint file.
+func newRunProgress(totals map[uint]uint64, args ...interface{}) (*progress, error) {
+	names := make([]string, len(seedPurgeMaps))
+	for i := range seedPurgeMaps {
+		names[i] = seedPurgeMaps[i].Name
+	}
+
+	fingerprint := runFingerprint(append([]interface{}{seedPurgeCmdName, names, zooms, seedPurgeVariants}, args...)...)
+	return newProgress(seedPurgeCmdName, names, totals, fingerprint)
+}
+
+// tileRange is the rectangle of the tiles of a zoom
+type tileRange struct {
+	minX, minY, maxX, maxY uint
+}
+
+// count returns the number of tiles of the range
+func (r tileRange) count() uint64 {
+	return uint64(r.maxX-r.minX+1) * uint64(r.maxY-r.minY+1)
+}
+
+// countTilesForRanges returns the number of tiles per zoom of the ranges
+func countTilesForRanges(ranges map[uint]tileRange) map[uint]uint64 {
+	totals := make(map[uint]uint64, len(ranges))
+	for z, r := range ranges {
+		totals[z] = r.count()
+	}
+	return totals
+}
+
+// tileRangesForBounds returns the tiles per zoom generateTilesForBounds generates
+func tileRangesForBounds(bounds [4]float64, zooms []uint, grid slippy.TileGridder) (map[uint]tileRange, error) {
+	var extent geom.Extent = bounds
+	ranges := make(map[uint]tileRange, len(zooms))
+
+	for _, z := range zooms {
+		p1, err := grid.FromNative(slippy.Zoom(z), extent.Min())
+		if err != nil {
+			return nil, err
+		}
+		p2, err := grid.FromNative(slippy.Zoom(z), extent.Max())
+		if err != nil {
+			return nil, err
+		}
+
+		r := tileRange{minX: p1.X, minY: p1.Y, maxX: p2.X, maxY: p2.Y}
+		if r.minX > r.maxX {
+			r.minX, r.maxX = r.maxX, r.minX
+		}
+		if r.minY > r.maxY {
+			r.minY, r.maxY = r.maxY, r.minY
+		}
+		ranges[z] = r
+	}
+
+	return ranges, nil
+}
+
+// countTilesForBounds returns the number of tiles per zoom generateTilesForBounds generates
+func countTilesForBounds(bounds [4]float64, zooms []uint, grid slippy.TileGridder) (map[uint]uint64, error) {
+	ranges, err := tileRangesForBounds(bounds, zooms, grid)
+	if err != nil {
+		return nil, err
+	}
+	return countTilesForRanges(ranges), nil
+}
+
+// tileRangesForTileName returns the tiles per zoom generateTilesForTileName generates
+func tileRangesForTileName(tile slippy.Tile, explicit bool, zooms []uint) map[uint]tileRange {
+	if explicit || len(zooms) == 0 {
+		return map[uint]tileRange{uint(tile.Z): {minX: tile.X, minY: tile.Y, maxX: tile.X, maxY: tile.Y}}
+	}
+
+	ranges := make(map[uint]tileRange, len(zooms))
+	for _, z := range zooms {
+		if z < uint(tile.Z) {
+			// the ancestor of the tile
+			d := uint(tile.Z) - z
+			ranges[z] = tileRange{minX: tile.X >> d, minY: tile.Y >> d, maxX: tile.X >> d, maxY: tile.Y >> d}
+			continue
+		}
+		// each zoom has 4 times the tiles of the zoom above
+		d := z - uint(tile.Z)
+		ranges[z] = tileRange{
+			minX: tile.X << d,
+			minY: tile.Y << d,
+			maxX: (tile.X+1)<<d - 1,
+			maxY: (tile.Y+1)<<d - 1,
+		}
+	}
+	return ranges
+}
+
+// countTilesForTileName returns the number of tiles per zoom generateTilesForTileName generates
+func countTilesForTileName(tile slippy.Tile, explicit bool, zooms []uint) map[uint]uint64 {
+	return countTilesForRanges(tileRangesForTileName(tile, explicit, zooms))
+}
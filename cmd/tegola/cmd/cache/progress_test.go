@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/proj"
+	"github.com/go-spatial/tegola/atlas"
+)
+
+// recordingWorker records the tiles it was called for and fails for the tiles in fail
+func recordingWorker(fail map[slippy.Tile]bool) (func(context.Context, MapTile) error, func() sTiles) {
+	var (
+		mu    sync.Mutex
+		tiles sTiles
+	)
+
+	worker := func(_ context.Context, mt MapTile) error {
+		if fail[mt.Tile] {
+			return seedPurgeWorkerTileError{Tile: mt.Tile, Err: errors.New("failed")}
+		}
+		mu.Lock()
+		tiles = append(tiles, mt.Tile)
+		mu.Unlock()
+		return nil
+	}
+
+	processed := func() sTiles {
+		mu.Lock()
+		defer mu.Unlock()
+		sort.Sort(tiles)
+		return tiles
+	}
+
+	return worker, processed
+}
+
+func setProgressFlags(t *testing.T, checkpointFile, reportFile, retryFile string) {
+	cacheCheckpointFile, cacheReportFile, cacheRetryFile, cacheProgressInterval = checkpointFile, reportFile, retryFile, 0
+	t.Cleanup(func() {
+		cacheCheckpointFile, cacheReportFile, cacheRetryFile = "", "", ""
+	})
+}
+
+func TestCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+	setProgressFlags(t, checkpointFile, "", "")
+
+	var (
+		bounds = [4]float64{-180, -85.0511, 180, 85.0511}
+		zooms  = []uint{0, 1, 2}
+		grid   = slippy.NewGrid(proj.WGS84, 0)
+		maps   = []atlas.Map{{Name: "osm"}}
+	)
+
+	totals, err := countTilesForBounds(bounds, zooms, grid)
+	if err != nil {
+		t.Fatalf("count tiles, expected nil got %v", err)
+	}
+	var total uint64
+	for _, n := range totals {
+		total += n
+	}
+
+	all := make(sTiles, 0, total)
+	for tile := range generateTilesForBounds(context.Background(), bounds, zooms, grid).Channel() {
+		all = append(all, tile)
+	}
+	sort.Sort(all)
+	if uint64(len(all)) != total {
+		t.Fatalf("tile count, expected %v got %v", len(all), total)
+	}
+
+	// the first run stops at the failing tile
+	failing := all[len(all)/2]
+	worker, processed := recordingWorker(map[slippy.Tile]bool{failing: true})
+
+	prog, err := newProgress("seed", []string{"osm"}, totals, "fingerprint")
+	if err != nil {
+		t.Fatalf("new progress, expected nil got %v", err)
+	}
+	err = doWork(context.Background(), generateTilesForBounds(context.Background(), bounds, zooms, grid), maps, 1, prog, worker)
+	if err == nil {
+		t.Fatalf("first run, expected error got nil")
+	}
+	first := processed()
+
+	cp, err := readCheckpoint(checkpointFile, "fingerprint")
+	if err != nil {
+		t.Fatalf("read checkpoint, expected nil got %v", err)
+	}
+	var completed uint64
+	for _, n := range cp.Completed {
+		completed += n
+	}
+	if completed == 0 || completed >= total {
+		t.Fatalf("completed tiles, expected between 0 and %v got %v", total, completed)
+	}
+
+	// a different run can't use the checkpoint
+	if _, err = newProgress("seed", []string{"osm"}, totals, "other"); err == nil {
+		t.Errorf("mismatched fingerprint, expected error got nil")
+	}
+
+	// the second run picks up after the completed tiles
+	worker, processed = recordingWorker(nil)
+	if prog, err = newProgress("seed", []string{"osm"}, totals, "fingerprint"); err != nil {
+		t.Fatalf("new progress, expected nil got %v", err)
+	}
+	err = doWork(context.Background(), generateTilesForBounds(context.Background(), bounds, zooms, grid), maps, 1, prog, worker)
+	if err != nil {
+		t.Fatalf("second run, expected nil got %v", err)
+	}
+	second := processed()
+
+	if uint64(len(second)) != total-completed {
+		t.Errorf("second run tiles, expected %v got %v", total-completed, len(second))
+	}
+
+	seen := map[slippy.Tile]bool{}
+	for _, tile := range append(first, second...) {
+		seen[tile] = true
+	}
+	for _, tile := range all {
+		if !seen[tile] {
+			t.Errorf("tile %v was not processed by either run", tile)
+		}
+	}
+
+	if cp, err = readCheckpoint(checkpointFile, "fingerprint"); err != nil {
+		t.Fatalf("read checkpoint, expected nil got %v", err)
+	}
+	for z, n := range totals {
+		if cp.Completed[z] != n {
+			t.Errorf("completed tiles of zoom %v, expected %v got %v", z, n, cp.Completed[z])
+		}
+	}
+}
+
+func TestRetryFile(t *testing.T) {
+	dir := t.TempDir()
+	reportFile := filepath.Join(dir, "report.json")
+	retryFile := filepath.Join(dir, "retry.txt")
+	setProgressFlags(t, "", reportFile, retryFile)
+
+	tile := slippy.Tile{Z: 2, X: 1, Y: 2}
+	failing := map[slippy.Tile]bool{
+		{Z: 2, X: 1, Y: 1}: true,
+		{Z: 2, X: 3, Y: 0}: true,
+	}
+	worker, processed := recordingWorker(failing)
+	maps := []atlas.Map{{Name: "a"}, {Name: "b"}}
+
+	prog, err := newProgress("seed", []string{"a", "b"}, nil, "fingerprint")
+	if err != nil {
+		t.Fatalf("new progress, expected nil got %v", err)
+	}
+
+	tiles := generateTilesForTileList(context.Background(), strings.NewReader("2/0/0\n2/1/1\n2/3/0\n2/1/2\n"), true, nil, defaultTileNameFormat)
+	if err = doWork(context.Background(), tiles, maps, 2, prog, worker); err != nil {
+		t.Fatalf("run, expected nil got %v", err)
+	}
+
+	if got := processed(); len(got) != 4 || got[0] != (slippy.Tile{Z: 2}) || got[3] != tile {
+		t.Errorf("processed tiles, expected 2/0/0 and 2/1/2 for both maps got %v", got)
+	}
+
+	b, err := os.ReadFile(retryFile)
+	if err != nil {
+		t.Fatalf("read retry file, expected nil got %v", err)
+	}
+	retries := make(sTiles, 0, len(failing))
+	for tile := range generateTilesForTileList(context.Background(), strings.NewReader(string(b)), true, nil, defaultTileNameFormat).Channel() {
+		retries = append(retries, tile)
+	}
+	sort.Sort(retries)
+	expected := sTiles{{Z: 2, X: 1, Y: 1}, {Z: 2, X: 3, Y: 0}}
+	if !expected.IsEqual(retries) {
+		t.Errorf("retry tiles, expected %v got %v", expected, retries)
+	}
+
+	b, err = os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatalf("read report file, expected nil got %v", err)
+	}
+	var r report
+	if err = json.Unmarshal(b, &r); err != nil {
+		t.Fatalf("decode report, expected nil got %v", err)
+	}
+	if r.Done != 4 || r.Errors != 2 || r.Skipped != 0 {
+		t.Errorf("report, expected 4 done, 2 errors and 0 skipped got %v done, %v errors and %v skipped", r.Done, r.Errors, r.Skipped)
+	}
+	if len(r.Zooms) != 1 || r.Zooms[0].Zoom != 2 {
+		t.Errorf("report zooms, expected zoom 2 got %+v", r.Zooms)
+	}
+}
+
+func TestCountTilesForTileName(t *testing.T) {
+	tile := slippy.Tile{Z: 2, X: 1, Y: 1}
+	totals := countTilesForTileName(tile, false, []uint{0, 1, 2, 3, 4})
+
+	for z, expected := range map[uint]uint64{0: 1, 1: 1, 2: 1, 3: 4, 4: 16} {
+		var n uint64
+		slippy.RangeFamilyAt(tile, slippy.Zoom(z), func(slippy.Tile) bool {
+			n++
+			return true
+		})
+		if n != expected || totals[z] != expected {
+			t.Errorf("zoom %v, expected %v got %v (generated %v)", z, expected, totals[z], n)
+		}
+	}
+}
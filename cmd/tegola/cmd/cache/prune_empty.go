@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-spatial/cobra"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/cache"
+	gdcmd "github.com/go-spatial/tegola/internal/cmd"
+	"github.com/go-spatial/tegola/internal/log"
+)
+
+var (
+	// pruneMap is the name of the map to prune. defaults to all maps
+	pruneMap string
+	// pruneSentinel replaces the empty tiles with the cache.EmptyTile sentinel instead of removing them
+	pruneSentinel bool
+	// pruneMaxSize is the size in bytes above which tiles are not read, as they can't be empty
+	pruneMaxSize int64
+)
+
+var PruneEmptyCmd = &cobra.Command{
+	Use:     "prune-empty",
+	Short:   "remove the cached tiles which have no features",
+	Example: "tegola cache prune-empty --map osm --min-zoom 10 --max-zoom 16",
+	PreRunE: minMaxZoomValidate,
+	RunE:    pruneEmptyCommand,
+}
+
+func init() {
+	setupMinMaxZoomFlags(PruneEmptyCmd, 0, atlas.MaxZoom)
+	PruneEmptyCmd.Flags().StringVarP(&pruneMap, "map", "", "", "map name as defined in the config. defaults to all maps")
+	PruneEmptyCmd.Flags().BoolVarP(&pruneSentinel, "sentinel", "", false, "replace the empty tiles with the compact sentinel answered with 204 No Content by the server, instead of removing them")
+	PruneEmptyCmd.Flags().Int64VarP(&pruneMaxSize, "max-size", "", 1024, "tiles larger than this number of bytes are not read to check if they are empty")
+}
+
+// pruneStats counts the tiles checked by pruneEmpty
+type pruneStats struct {
+	// Listed is the number of tiles listed
+	Listed int64
+	// Checked is the number of tiles read to check if they are empty
+	Checked int64
+	// Pruned is the number of empty tiles removed or replaced by the sentinel
+	Pruned int64
+	// Bytes is the number of bytes of the pruned tiles
+	Bytes int64
+}
+
+func pruneEmptyCommand(_ *cobra.Command, _ []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer gdcmd.New().Complete()
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gdcmd.Cancelled():
+			cancel()
+		}
+	}()
+
+	c := atlas.GetCache()
+	lister, ok := cache.Original(c).(cache.Lister)
+	if !ok {
+		return fmt.Errorf("the configured cache backend does not support listing tiles")
+	}
+
+	maps := atlas.AllMaps()
+	if pruneMap != "" {
+		m, err := atlas.GetMap(pruneMap)
+		if err != nil {
+			return err
+		}
+		maps = []atlas.Map{m}
+	}
+
+	for _, m := range maps {
+		filter := cache.ListFilter{
+			MapName: m.Name,
+			Version: atlas.KeyVersion(m),
+			Zooms:   zooms,
+		}
+
+		stats, err := pruneEmpty(ctx, c, lister, filter, pruneSentinel, pruneMaxSize)
+		if err != nil {
+			return fmt.Errorf("error pruning tiles of map (%v): %w", m.Name, err)
+		}
+		log.Infof("map (%v): pruned %v empty tiles of %v bytes, read %v of %v tiles", m.Name, stats.Pruned, stats.Bytes, stats.Checked, stats.Listed)
+	}
+
+	return atlas.FlushCache(ctx)
+}
+
+// pruneEmpty removes the tiles matching the filter which have no features, or replaces
+// them with the cache.EmptyTile sentinel when sentinel is true. Only the tiles up to
+// maxSize bytes are read. Tiles which already are the sentinel are left as is.
+func pruneEmpty(ctx context.Context, c cache.Interface, lister cache.Lister, filter cache.ListFilter, sentinel bool, maxSize int64) (stats pruneStats, err error) {
+	// the tiles are read and removed once listed, as not all backends support
+	// other operations while listing
+	var candidates []cache.KeyInfo
+	err = lister.List(ctx, filter, func(ki cache.KeyInfo) error {
+		stats.Listed++
+		if ki.Size > 0 && ki.Size <= maxSize {
+			candidates = append(candidates, ki)
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	for _, ki := range candidates {
+		if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+
+		val, hit, err := c.Get(ctx, &ki.Key)
+		if err != nil {
+			return stats, err
+		}
+		stats.Checked++
+		if !hit || cache.IsEmptyTile(val) {
+			continue
+		}
+
+		isEmpty, err := atlas.IsEmptyTile(val)
+		if err != nil {
+			log.Warnf("skipping tile (%v): %v", ki.Key.String(), err)
+			continue
+		}
+		if !isEmpty {
+			continue
+		}
+
+		if sentinel {
+			err = c.Set(ctx, &ki.Key, cache.EmptyTile)
+		} else {
+			err = c.Purge(ctx, &ki.Key)
+		}
+		if err != nil {
+			return stats, err
+		}
+		stats.Pruned++
+		stats.Bytes += ki.Size
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/cache/memory"
+	"github.com/go-spatial/tegola/provider"
+	"github.com/go-spatial/tegola/provider/test"
+	"github.com/go-spatial/tegola/provider/test/emptycollection"
+)
+
+func encodeTestTile(t *testing.T, p provider.Tiler) []byte {
+	t.Helper()
+
+	m := atlas.NewWebMercatorMap("osm")
+	m.Layers = []atlas.Layer{{
+		Name:              "layer",
+		ProviderLayerName: "test-layer",
+		MaxZoom:           20,
+		Provider:          p,
+		GeomType:          geom.Point{},
+	}}
+
+	b, err := m.Encode(context.Background(), slippy.Tile{Z: 2, X: 1, Y: 1}, nil)
+	if err != nil {
+		t.Fatalf("encode, expected nil got %v", err)
+	}
+	return b
+}
+
+func TestPruneEmpty(t *testing.T) {
+	type tcase struct {
+		sentinel bool
+		maxSize  int64
+		pruned   int64
+		// expected values of the tiles by key, nil when removed
+		expected map[cache.Key][]byte
+	}
+
+	var (
+		ctx      = context.Background()
+		empty    = encodeTestTile(t, &emptycollection.TileProvider{})
+		notEmpty = encodeTestTile(t, &test.TileProvider{})
+
+		emptyKey    = cache.Key{MapName: "osm", Z: 1, X: 0, Y: 0}
+		layerKey    = cache.Key{MapName: "osm", LayerName: "roads", Z: 1, X: 1, Y: 0}
+		notEmptyKey = cache.Key{MapName: "osm", Z: 1, X: 0, Y: 1}
+		sentinelKey = cache.Key{MapName: "osm", Z: 1, X: 1, Y: 1}
+		otherKey    = cache.Key{MapName: "other", Z: 1, X: 0, Y: 0}
+	)
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			c, _ := memory.New(nil)
+			for key, val := range map[cache.Key][]byte{
+				emptyKey:    empty,
+				layerKey:    empty,
+				notEmptyKey: notEmpty,
+				sentinelKey: cache.EmptyTile,
+				otherKey:    empty,
+			} {
+				key := key
+				if err := c.Set(ctx, &key, val); err != nil {
+					t.Fatalf("set, expected nil got %v", err)
+				}
+			}
+
+			stats, err := pruneEmpty(ctx, c, c.(cache.Lister), cache.ListFilter{MapName: "osm"}, tc.sentinel, tc.maxSize)
+			if err != nil {
+				t.Fatalf("prune, expected nil got %v", err)
+			}
+			if stats.Listed != 4 || stats.Pruned != tc.pruned {
+				t.Errorf("listed and pruned, expected 4 %v got %v %v", tc.pruned, stats.Listed, stats.Pruned)
+			}
+
+			for key, expected := range tc.expected {
+				key := key
+				val, hit, err := c.Get(ctx, &key)
+				if err != nil {
+					t.Fatalf("get, expected nil got %v", err)
+				}
+				if hit != (expected != nil) || !bytes.Equal(val, expected) {
+					t.Errorf("tile %v, expected %v got %v (hit %v)", key.String(), expected, val, hit)
+				}
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"remove": {
+			maxSize: 1024,
+			pruned:  2,
+			expected: map[cache.Key][]byte{
+				emptyKey:    nil,
+				layerKey:    nil,
+				notEmptyKey: notEmpty,
+				sentinelKey: cache.EmptyTile,
+				otherKey:    empty,
+			},
+		},
+		"sentinel": {
+			sentinel: true,
+			maxSize:  1024,
+			pruned:   2,
+			expected: map[cache.Key][]byte{
+				emptyKey:    cache.EmptyTile,
+				layerKey:    cache.EmptyTile,
+				notEmptyKey: notEmpty,
+			},
+		},
+		"max size": {
+			maxSize: int64(len(empty)) - 1,
+			expected: map[cache.Key][]byte{
+				emptyKey: empty,
+				layerKey: empty,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
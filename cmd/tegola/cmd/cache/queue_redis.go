@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisQueueGroup is the consumer group of the workers
+	redisQueueGroup = "workers"
+	// redisQueueField is the stream entry field holding the job
+	redisQueueField = "job"
+)
+
+// redisQueue is a job queue on a redis stream. Jobs are claimed through a consumer
+// group, so a job claimed by a crashed worker stays in the group's pending entries
+// until its visibility timeout expires and another worker claims it.
+//
+// The keys used, for a queue named name, are:
+//
+//	name:jobs   the stream of unfinished jobs
+//	name:state  hash with the enqueued, acked and failed counts, the sealed flag and
+//	            the run header
+//	name:failed list of the jobs which failed max attempts times
+type redisQueue struct {
+	client *redis.Client
+	name   string
+	// visibility is how long a claimed job is hidden from the other workers
+	visibility  time.Duration
+	maxAttempts int64
+}
+
+func newRedisQueue(uri, name string, visibility time.Duration, maxAttempts int) (*redisQueue, error) {
+	opts, err := redis.ParseURL(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queue uri (%v): %w", uri, err)
+	}
+
+	client := redis.NewClient(opts)
+	if err = client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to queue (%v): %w", opts.Addr, err)
+	}
+
+	return &redisQueue{
+		client:      client,
+		name:        name,
+		visibility:  visibility,
+		maxAttempts: int64(maxAttempts),
+	}, nil
+}
+
+func (q *redisQueue) stream() string    { return q.name + ":jobs" }
+func (q *redisQueue) stateKey() string  { return q.name + ":state" }
+func (q *redisQueue) failedKey() string { return q.name + ":failed" }
+
+func (q *redisQueue) Reset(ctx context.Context, header runHeader) error {
+	remaining, err := q.client.XLen(ctx, q.stream()).Result()
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		return ErrQueueNotEmpty{Name: q.name, Remaining: remaining}
+	}
+
+	b, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	// the header is set before the group is created, so workers find it once they can claim jobs
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, q.stream(), q.stateKey(), q.failedKey())
+		pipe.HSet(ctx, q.stateKey(), "header", b)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return q.client.XGroupCreateMkStream(ctx, q.stream(), redisQueueGroup, "0").Err()
+}
+
+func (q *redisQueue) Header(ctx context.Context) (*runHeader, error) {
+	b, err := q.client.HGet(ctx, q.stateKey(), "header").Bytes()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	var header runHeader
+	if err = json.Unmarshal(b, &header); err != nil {
+		return nil, fmt.Errorf("decoding run header in %v: %w", q.stateKey(), err)
+	}
+	return &header, nil
+}
+
+func (q *redisQueue) Push(ctx context.Context, job seedJob) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: q.stream(),
+			Values: map[string]interface{}{redisQueueField: b},
+		})
+		pipe.HIncrBy(ctx, q.stateKey(), "enqueued", 1)
+		return nil
+	})
+	return err
+}
+
+func (q *redisQueue) Seal(ctx context.Context) error {
+	return q.client.HSet(ctx, q.stateKey(), "sealed", 1).Err()
+}
+
+func (q *redisQueue) Claim(ctx context.Context, consumer string) (*seedJob, error) {
+	// jobs whose visibility timeout expired are claimed first
+	for {
+		pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: q.stream(),
+			Group:  redisQueueGroup,
+			Idle:   q.visibility,
+			Start:  "-",
+			End:    "+",
+			Count:  1,
+		}).Result()
+		if err != nil {
+			if isNoGroup(err) {
+				// the coordinator did not set up the queue yet
+				return nil, sleepContext(ctx, queuePollInterval)
+			}
+			return nil, err
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		if pending[0].RetryCount >= q.maxAttempts {
+			if err = q.fail(ctx, pending[0].ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		msgs, err := q.client.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   q.stream(),
+			Group:    redisQueueGroup,
+			Consumer: consumer,
+			MinIdle:  q.visibility,
+			Messages: []string{pending[0].ID},
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		// another worker can claim the job first
+		if len(msgs) > 0 {
+			return decodeRedisJob(msgs[0])
+		}
+	}
+
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    redisQueueGroup,
+		Consumer: consumer,
+		Streams:  []string{q.stream(), ">"},
+		Count:    1,
+		Block:    queuePollInterval,
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		if isNoGroup(err) {
+			return nil, sleepContext(ctx, queuePollInterval)
+		}
+		return nil, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, nil
+	}
+
+	return decodeRedisJob(streams[0].Messages[0])
+}
+
+// fail moves a job which was claimed max attempts times to the failed list
+func (q *redisQueue) fail(ctx context.Context, id string) error {
+	msgs, err := q.client.XRange(ctx, q.stream(), id, id).Result()
+	if err != nil {
+		return err
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, q.stream(), redisQueueGroup, id)
+		pipe.XDel(ctx, q.stream(), id)
+		for _, msg := range msgs {
+			pipe.RPush(ctx, q.failedKey(), msg.Values[redisQueueField])
+		}
+		pipe.HIncrBy(ctx, q.stateKey(), "failed", 1)
+		return nil
+	})
+	return err
+}
+
+func (q *redisQueue) Extend(ctx context.Context, consumer string, job *seedJob) error {
+	// claiming the job again resets its idle time without counting a delivery
+	return q.client.XClaimJustID(ctx, &redis.XClaimArgs{
+		Stream:   q.stream(),
+		Group:    redisQueueGroup,
+		Consumer: consumer,
+		Messages: []string{job.ID},
+	}).Err()
+}
+
+func (q *redisQueue) Ack(ctx context.Context, job *seedJob) error {
+	acked, err := q.client.XAck(ctx, q.stream(), redisQueueGroup, job.ID).Result()
+	if err != nil || acked == 0 {
+		// the job was finished by another worker after its visibility timeout expired
+		return err
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XDel(ctx, q.stream(), job.ID)
+		pipe.HIncrBy(ctx, q.stateKey(), "acked", 1)
+		return nil
+	})
+	return err
+}
+
+func (q *redisQueue) Stats(ctx context.Context) (stats queueStats, err error) {
+	if stats.Remaining, err = q.client.XLen(ctx, q.stream()).Result(); err != nil {
+		return stats, err
+	}
+
+	state, err := q.client.HGetAll(ctx, q.stateKey()).Result()
+	if err != nil {
+		return stats, err
+	}
+	for field, dst := range map[string]*int64{
+		"enqueued": &stats.Enqueued,
+		"acked":    &stats.Acked,
+		"failed":   &stats.Failed,
+	} {
+		if v, ok := state[field]; ok {
+			if *dst, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return stats, fmt.Errorf("invalid %v count (%v) in %v", field, v, q.stateKey())
+			}
+		}
+	}
+	stats.Sealed = state["sealed"] == "1"
+
+	return stats, nil
+}
+
+func (q *redisQueue) Close() error {
+	return q.client.Close()
+}
+
+func decodeRedisJob(msg redis.XMessage) (*seedJob, error) {
+	var job seedJob
+
+	v, ok := msg.Values[redisQueueField].(string)
+	if !ok {
+		return nil, fmt.Errorf("job %v has no %v field", msg.ID, redisQueueField)
+	}
+	if err := json.Unmarshal([]byte(v), &job); err != nil {
+		return nil, fmt.Errorf("decoding job %v: %w", msg.ID, err)
+	}
+	job.ID = msg.ID
+
+	return &job, nil
+}
+
+// isNoGroup reports if err is returned because the stream or its consumer group doesn't exist
+func isNoGroup(err error) bool {
+	return strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
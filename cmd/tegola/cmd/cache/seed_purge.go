@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/go-spatial/cobra"
 	"github.com/go-spatial/geom"
@@ -55,6 +56,10 @@ var (
 	cacheMap string
 	// cacheLogThreshold is cache threshold while seeding, to log output for tiles that take longer than this (in milliseconds) to render
 	cacheLogThreshold int64
+	// cacheSkipEmpty doesn't cache the seeded tiles which have no features
+	cacheSkipEmpty bool
+	// cacheEmptySentinel caches a compact sentinel for the seeded tiles which have no features
+	cacheEmptySentinel bool
 )
 
 // variables that are not flags but set by the command.
@@ -62,6 +67,8 @@ var (
 	seedPurgeWorker func(context.Context, MapTile) error
 	seedPurgeBounds [4]float64
 	seedPurgeMaps   []atlas.Map
+	// seedPurgeCmdName is the name the command was called as, seed or purge
+	seedPurgeCmdName string
 )
 
 var SeedPurgeCmd = &cobra.Command{
@@ -69,7 +76,7 @@ var SeedPurgeCmd = &cobra.Command{
 	Aliases: []string{"purge"},
 	Short:   "seed or purge tiles from the cache",
 	Long:    "command to seed or purge tiles from the cache",
-	Example: "tegola cache seed --bounds lng,lat,lng,lat",
+	Example: "tegola cache seed --bounds lng,lat,lng,lat\n  tegola cache seed --geometry-file country.geojson --min-zoom 0 --max-zoom 14",
 }
 
 func init() {
@@ -78,9 +85,34 @@ func init() {
 	SeedPurgeCmd.PersistentFlags().IntVarP(&cacheConcurrency, "concurrency", "", runtime.NumCPU(), "the amount of concurrency to use. defaults to the number of CPUs on the machine")
 	SeedPurgeCmd.PersistentFlags().BoolVarP(&cacheOverwrite, "overwrite", "", false, "overwrite the cache if a tile already exists (default false)")
 	SeedPurgeCmd.PersistentFlags().Int64VarP(&cacheLogThreshold, "log-threshold", "", 0, "during seeding, only log tiles that take this number of milliseconds or longer to render (default all tiles)")
+	SeedPurgeCmd.PersistentFlags().BoolVarP(&cacheSkipEmpty, "skip-empty", "", false, "during seeding, don't cache the tiles which have no features")
+	SeedPurgeCmd.PersistentFlags().BoolVarP(&cacheEmptySentinel, "empty-sentinel", "", false, "during seeding, cache a compact sentinel, answered with 204 No Content by the server, for the tiles which have no features")
+	SeedPurgeCmd.PersistentFlags().StringArrayVarP(&cacheParams, "param", "", nil, "query parameter value, in the format name=value, to render the tiles with. can be repeated")
+	SeedPurgeCmd.PersistentFlags().StringVarP(&cacheParamFile, "param-file", "", "", "CSV file of query parameter sets to render the tiles with. the header holds the parameter names and each record a set of values")
+	SeedPurgeCmd.PersistentFlags().StringSliceVarP(&cacheLayers, "layers", "", nil, "comma separated list of layers whose individual tiles, served by /maps/:map/:layer/:z/:x/:y, are used instead of the map tiles")
+	SeedPurgeCmd.PersistentFlags().BoolVarP(&cacheDryRun, "dry-run", "", false, "count the tiles per zoom without touching the cache")
+	SeedPurgeCmd.PersistentFlags().IntVarP(&cacheSample, "sample", "", 0, "with dry-run, render this number of random tiles per zoom to estimate the render time, tile bytes and ratio of empty tiles")
+	SeedPurgeCmd.PersistentFlags().StringVarP(&cacheCheckpointFile, "checkpoint-file", "", "", "file to record the completed tiles to. a run restarted with the same checkpoint file and flags skips the tiles already completed")
+	SeedPurgeCmd.PersistentFlags().DurationVarP(&cacheProgressInterval, "progress-interval", "", 30*time.Second, "how often to log the progress and write the checkpoint file. 0 disables the progress log")
+	SeedPurgeCmd.PersistentFlags().StringVarP(&cacheReportFile, "report-file", "", "", "file to write a JSON report of the run to once done. use - for stdout")
+	SeedPurgeCmd.PersistentFlags().StringVarP(&cacheRetryFile, "retry-file", "", "", "file to append the failed tiles to, in the z/x/y format of the tile-list command. when set, failed tiles don't stop the run")
+	SeedPurgeCmd.PersistentFlags().BoolVarP(&cacheCoordinator, "coordinator", "", false, "split the tiles into jobs pushed to the queue for workers to process, and wait for them to be done")
+	SeedPurgeCmd.PersistentFlags().BoolVarP(&cacheWorker, "worker", "", false, "process the jobs pushed to the queue by a coordinator")
+	SeedPurgeCmd.PersistentFlags().StringVarP(&cacheQueue, "queue", "", "", "uri of the job queue used by coordinator and worker, i.e. redis://localhost:6379/0")
+	SeedPurgeCmd.PersistentFlags().StringVarP(&cacheQueueName, "queue-name", "", "tegola:seed", "prefix of the keys of the job queue")
+	SeedPurgeCmd.PersistentFlags().IntVarP(&cacheJobSize, "job-size", "", 256, "number of tiles per job pushed by the coordinator")
+	SeedPurgeCmd.PersistentFlags().DurationVarP(&cacheVisibilityTimeout, "visibility-timeout", "", 5*time.Minute, "how long a job claimed by a worker is hidden from the other workers. the jobs of crashed workers are claimed again once it expires")
+	SeedPurgeCmd.PersistentFlags().IntVarP(&cacheMaxAttempts, "max-attempts", "", 3, "number of times a job is claimed before it's moved to the failed jobs")
 
 	SeedPurgeCmd.Flags().StringVarP(&cacheBounds, "bounds", "", "-180,-85.0511,180,85.0511", "lng/lat bounds to seed the cache with in the format: minx, miny, maxx, maxy")
 	SeedPurgeCmd.Flags().IntVarP(&cacheBoundsSRID, "bounds-srid", "", int(proj.EPSG4326), "the srid of the grid system for bounds.")
+	SeedPurgeCmd.Flags().StringVarP(&cacheGeometryFile, "geometry-file", "", "", "GeoJSON or WKT file with the geometry to seed the cache with, in the bounds-srid. only the tiles intersecting the geometry are used")
+	SeedPurgeCmd.Flags().StringVarP(&cacheFromLayer, "from-layer", "", "", "map layer, in the format map.layer, whose features are used as the geometry to seed the cache with")
+	SeedPurgeCmd.Flags().Float64VarP(&cacheGeometryBuffer, "geometry-buffer", "", 0, "distance to buffer the geometry by, in units of the bounds-srid (web mercator meters for from-layer)")
+	SeedPurgeCmd.Flags().BoolVarP(&cacheFromStats, "from-stats", "", false, "use the most requested tiles recorded by the server tile_stats")
+	SeedPurgeCmd.Flags().StringVarP(&cacheFromLog, "from-log", "", "", "HTTP access log file, optionally gzipped, whose /maps/ tile requests are used. use - for stdin")
+	SeedPurgeCmd.Flags().IntVarP(&cacheTop, "top", "", 0, "with from-stats or from-log, only use the given number of most requested tiles of each map (default all tiles)")
+	SeedPurgeCmd.Flags().Uint64VarP(&cacheMinHits, "min-hits", "", 1, "with from-stats or from-log, only use the tiles requested at least this number of times")
 
 	SeedPurgeCmd.PersistentPreRunE = seedPurgeCmdValidatePersistent
 	SeedPurgeCmd.PreRunE = seedPurgeCmdValidate
@@ -131,19 +163,36 @@ func seedPurgeCmdValidatePersistent(cmd *cobra.Command, args []string) error {
 		seedcmd = seedcmd.Parent()
 	}
 
+	if err := variantsValidate(); err != nil {
+		return err
+	}
+
+	switch {
+	case cacheSkipEmpty && cacheEmptySentinel:
+		return fmt.Errorf("only one of skip-empty or empty-sentinel can be used")
+	case cacheSkipEmpty:
+		atlas.SetEmptyTiles(atlas.EmptyTileSkip)
+	case cacheEmptySentinel:
+		atlas.SetEmptyTiles(atlas.EmptyTileSentinel)
+	}
+
 	//cmdName := strings.ToLower(strings.TrimSpace(cmd.CalledAs()))
 	switch cmdName {
 	case "purge":
-		seedPurgeWorker = purgeWorker
+		seedPurgeWorker = purgeWorker(seedPurgeVariants)
 	case "seed":
-		seedPurgeWorker = seedWorker(cacheOverwrite, cacheLogThreshold)
+		seedPurgeWorker = seedWorker(cacheOverwrite, cacheLogThreshold, seedPurgeVariants)
 	default:
 
 		return fmt.Errorf("expected purge/seed got (%v) for command name", cmdName)
 	}
+	seedPurgeCmdName = cmdName
 	build.Commands = append(build.Commands, "cache", cmdName)
 
-	return nil
+	if err := dryRunValidate(cmd); err != nil {
+		return err
+	}
+	return distributedValidate(cmd)
 
 }
 
@@ -174,6 +223,31 @@ func seedPurgeCmdValidate(cmd *cobra.Command, args []string) (err error) {
 		return errors.New(str.String())
 	}
 
+	geometryFlags := 0
+	for _, name := range []string{"bounds", "geometry-file", "from-layer", "from-stats", "from-log"} {
+		if cmd.Flags().Changed(name) {
+			geometryFlags++
+		}
+	}
+	if geometryFlags > 1 {
+		return fmt.Errorf("only one of bounds, geometry-file, from-layer, from-stats or from-log can be used")
+	}
+	if (cmd.Flags().Changed("top") || cmd.Flags().Changed("min-hits")) && !cacheFromStats && cacheFromLog == "" {
+		return fmt.Errorf("top and min-hits can only be used with from-stats or from-log")
+	}
+	if cacheTop < 0 {
+		return fmt.Errorf("invalid value for top (%v). expecting a positive number", cacheTop)
+	}
+	if cacheGeometryBuffer < 0 {
+		return fmt.Errorf("invalid value for geometry-buffer (%v). expecting a positive number", cacheGeometryBuffer)
+	}
+
+	if cacheGeometryFile != "" {
+		if seedPurgeGeometry, err = readGeometryFile(cacheGeometryFile); err != nil {
+			return err
+		}
+	}
+
 	// validate and set bounds flag
 	boundsParts := strings.Split(strings.TrimSpace(cacheBounds), ",")
 	if len(boundsParts) != 4 {
@@ -221,12 +295,60 @@ func seedPurgeCommand(_ *cobra.Command, _ []string) (err error) {
 		}
 	}()
 
+	if cacheWorker {
+		return runWorker(ctx, seedPurgeQueue, currentRunHeader(atlas.AllMaps()), seedPurgeWorker)
+	}
+
 	grid := slippy.NewGrid(proj.EPSGCode(cacheBoundsSRID), 0)
 
 	log.Info("zoom list: ", zooms)
-	tileChannel := generateTilesForBounds(ctx, seedPurgeBounds, zooms, grid)
+	var (
+		tileChannel *TileChannel
+		prog        *progress
+	)
+	switch {
+	case cacheFromStats || cacheFromLog != "":
+		counts, err := hotTileCounts(ctx)
+		if err != nil {
+			return err
+		}
+		// each map is seeded with its own most requested tiles
+		tiles, mapNames := hotMapTiles(counts, seedPurgeMaps, zooms, cacheTop, cacheMinHits)
+		log.Infof("using the most requested tiles of each map, %v tiles in all", len(tiles))
 
-	return doWork(ctx, tileChannel, seedPurgeMaps, cacheConcurrency, seedPurgeWorker)
+		// the requested tiles change over time, so the checkpoint is tied to the list of tiles
+		if prog, err = newRunProgress(countTiles(tiles), "hot-tiles", tilesFingerprint(tiles, mapNames)); err != nil {
+			return err
+		}
+		tileChannel = generateTilesForSlice(ctx, tiles)
+		tileChannel.mapNames = mapNames
+	case cacheFromLayer != "":
+		g, err := geometryFromLayer(ctx, cacheFromLayer)
+		if err != nil {
+			return err
+		}
+		if prog, err = newRunProgress(nil, "from-layer", cacheFromLayer, cacheGeometryBuffer); err != nil {
+			return err
+		}
+		// layer features are fetched in web mercator
+		tileChannel = generateTilesForGeometry(ctx, g, cacheGeometryBuffer, zooms, slippy.NewGrid(proj.WebMercator, 0))
+	case seedPurgeGeometry != nil:
+		if prog, err = newRunProgress(nil, "geometry-file", cacheGeometryFile, cacheBoundsSRID, cacheGeometryBuffer); err != nil {
+			return err
+		}
+		tileChannel = generateTilesForGeometry(ctx, seedPurgeGeometry, cacheGeometryBuffer, zooms, grid)
+	default:
+		totals, err := countTilesForBounds(seedPurgeBounds, zooms, grid)
+		if err != nil {
+			return err
+		}
+		if prog, err = newRunProgress(totals, "bounds", seedPurgeBounds, cacheBoundsSRID); err != nil {
+			return err
+		}
+		tileChannel = generateTilesForBounds(ctx, seedPurgeBounds, zooms, grid)
+	}
+
+	return runTiles(ctx, tileChannel, prog)
 }
 
 func generateTilesForBounds(ctx context.Context, bounds [4]float64, zooms []uint, grid slippy.TileGridder) *TileChannel {
@@ -238,29 +360,34 @@ func generateTilesForBounds(ctx context.Context, bounds [4]float64, zooms []uint
 	if grid == nil {
 		grid = slippy.NewGrid(proj.EPSGCode(cacheBoundsSRID), 0)
 	}
+	// without ranges a dry run falls back to counting the generated tiles
+	tce.ranges, _ = tileRangesForBounds(bounds, zooms, grid)
 
-	go func() {
-		defer tce.Close()
+	// the tiles are generated once the channel is read
+	tce.start = func() {
+		go func() {
+			defer tce.Close()
 
-		var extent geom.Extent = bounds
-		for _, z := range zooms {
+			var extent geom.Extent = bounds
+			for _, z := range zooms {
 
-			tiles, err := slippy.FromBounds(grid, &extent, slippy.Zoom(z))
-			if err != nil {
-				tce.setError(fmt.Errorf("got error trying to get tiles: %w", err))
-				tce.Close()
-				return
-			}
-			for _, tile := range tiles {
-				t := tile
-				select {
-				case tce.channel <- t:
-				case <-ctx.Done():
-					// we have been cancelled
+				tiles, err := slippy.FromBounds(grid, &extent, slippy.Zoom(z))
+				if err != nil {
+					tce.setError(fmt.Errorf("got error trying to get tiles: %w", err))
+					tce.Close()
 					return
 				}
+				for _, tile := range tiles {
+					t := tile
+					select {
+					case tce.channel <- t:
+					case <-ctx.Done():
+						// we have been cancelled
+						return
+					}
+				}
 			}
-		}
-	}()
+		}()
+	}
 	return tce
 }
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/go-spatial/cobra"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/cache"
+	gdcmd "github.com/go-spatial/tegola/internal/cmd"
+)
+
+var (
+	// statsMap is the name of the map to report on. defaults to all maps
+	statsMap string
+	// statsLayer is the name of the layer to report on. defaults to all layers
+	statsLayer string
+)
+
+var StatsCmd = &cobra.Command{
+	Use:     "stats",
+	Short:   "report the number of cached tiles and their size per map and zoom",
+	Example: "tegola cache stats --map osm --min-zoom 10 --max-zoom 14",
+	PreRunE: minMaxZoomValidate,
+	RunE:    statsCommand,
+}
+
+func init() {
+	setupMinMaxZoomFlags(StatsCmd, 0, atlas.MaxZoom)
+	StatsCmd.Flags().StringVarP(&statsMap, "map", "", "", "map name as defined in the config. defaults to all maps")
+	StatsCmd.Flags().StringVarP(&statsLayer, "layer", "", "", "only report the tiles cached for this map layer")
+}
+
+func statsCommand(_ *cobra.Command, _ []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer gdcmd.New().Complete()
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gdcmd.Cancelled():
+			cancel()
+		}
+	}()
+
+	lister, ok := cache.Original(atlas.GetCache()).(cache.Lister)
+	if !ok {
+		return fmt.Errorf("the configured cache backend does not support listing tiles")
+	}
+
+	maps := atlas.AllMaps()
+	if statsMap != "" {
+		m, err := atlas.GetMap(statsMap)
+		if err != nil {
+			return err
+		}
+		maps = []atlas.Map{m}
+	}
+
+	for _, m := range maps {
+		filter := cache.ListFilter{
+			MapName:   m.Name,
+			Version:   atlas.KeyVersion(m),
+			LayerName: statsLayer,
+			Zooms:     zooms,
+		}
+
+		stats, err := cache.Stats(ctx, lister, filter)
+		if err != nil {
+			return fmt.Errorf("error listing tiles of map (%v): %w", m.Name, err)
+		}
+
+		ms := cache.MapStats{MapName: m.Name}
+		if len(stats) > 0 {
+			ms = stats[0]
+		}
+		if err = writeMapStats(os.Stdout, ms, filter.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeMapStats writes a per zoom table of the map stats to w, followed by a table of
+// the layer and query parameter tiles of the map
+func writeMapStats(w io.Writer, ms cache.MapStats, version string) error {
+	title := ms.MapName
+	if version != "" {
+		title = fmt.Sprintf("%v (version %v)", ms.MapName, version)
+	}
+	fmt.Fprintf(w, "map: %v\n", title)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "zoom\ttiles\tbytes\tavg bytes\t")
+	for _, zs := range ms.Zooms {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t\n", zs.Zoom, zs.Tiles, zs.Bytes, zs.Bytes/zs.Tiles)
+	}
+
+	var avg int64
+	if ms.Tiles > 0 {
+		avg = ms.Bytes / ms.Tiles
+	}
+	fmt.Fprintf(tw, "total\t%d\t%d\t%d\t\n", ms.Tiles, ms.Bytes, avg)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// the layer and query parameter tiles are not part of the totals
+	if len(ms.Variants) > 0 {
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "layer\tparams\ttiles\tbytes\t")
+		for _, vs := range ms.Variants {
+			layerName, params := vs.LayerName, vs.Params
+			if layerName == "" {
+				layerName = "-"
+			}
+			if params == "" {
+				params = "-"
+			}
+			fmt.Fprintf(tw, "%v\t%v\t%d\t%d\t\n", layerName, params, vs.Tiles, vs.Bytes)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
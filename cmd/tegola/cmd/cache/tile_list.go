@@ -90,10 +90,15 @@ func tileListCommand(cmd *cobra.Command, args []string) (err error) {
 
 	log.Info("zoom list: ", zooms)
 
+	prog, err := newRunProgress(nil, "tile-list", args[0], explicit, format)
+	if err != nil {
+		return err
+	}
+
 	tilechannel := generateTilesForTileList(ctx, in, explicit, zooms, format)
 
 	// start up workers here
-	return doWork(ctx, tilechannel, seedPurgeMaps, cacheConcurrency, seedPurgeWorker)
+	return runTiles(ctx, tilechannel, prog)
 }
 
 // generateTilesForTileList will return a channel where all the tiles in the list will be published
@@ -71,44 +71,53 @@ func tileNameCommand(cmd *cobra.Command, args []string) (err error) {
 	}()
 
 	log.Info("zoom list: ", zooms)
+	prog, err := newRunProgress(countTilesForTileName(tileNameTile, explicit, zooms), "tile-name", tileNameTile, explicit)
+	if err != nil {
+		return err
+	}
+
 	tilechannel := generateTilesForTileName(ctx, tileNameTile, explicit, zooms)
 
 	// start up workers
-	return doWork(ctx, tilechannel, seedPurgeMaps, cacheConcurrency, seedPurgeWorker)
+	return runTiles(ctx, tilechannel, prog)
 
 }
 
 func generateTilesForTileName(ctx context.Context, tile slippy.Tile, explicit bool, zooms []uint) *TileChannel {
 	tce := &TileChannel{
 		channel: make(chan slippy.Tile),
+		ranges:  tileRangesForTileName(tile, explicit, zooms),
 	}
 
-	go func() {
-		defer tce.Close()
-		if explicit || len(zooms) == 0 {
-			select {
-			case tce.channel <- tile:
-			case <-ctx.Done():
-				// we have been cancelled
-				return
-			}
-			return
-		}
-		for _, zoom := range zooms {
-			// range will include the original tile.
-			slippy.RangeFamilyAt(tile, slippy.Zoom(zoom), func(tile slippy.Tile) bool {
+	// the tiles are generated once the channel is read
+	tce.start = func() {
+		go func() {
+			defer tce.Close()
+			if explicit || len(zooms) == 0 {
 				select {
 				case tce.channel <- tile:
 				case <-ctx.Done():
-					return false
+					// we have been cancelled
+					return
 				}
-				return true
-			})
-			// gracefully stop if cancelled
-			if ctx.Err() != nil {
 				return
 			}
-		}
-	}()
+			for _, zoom := range zooms {
+				// range will include the original tile.
+				slippy.RangeFamilyAt(tile, slippy.Zoom(zoom), func(tile slippy.Tile) bool {
+					select {
+					case tce.channel <- tile:
+					case <-ctx.Done():
+						return false
+					}
+					return true
+				})
+				// gracefully stop if cancelled
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}()
+	}
 	return tce
 }
@@ -0,0 +1,310 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/go-spatial/cobra"
+	vectorTile "github.com/go-spatial/geom/encoding/mvt/vector_tile"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/cache"
+	gdcmd "github.com/go-spatial/tegola/internal/cmd"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	// verifyMap is the name of the map to verify. defaults to all maps
+	verifyMap string
+	// verifyPurge purges the bad tiles
+	verifyPurge bool
+)
+
+// kinds of bad tiles
+const (
+	// problemCorrupt tiles can't be decompressed or decoded
+	problemCorrupt = "corrupt"
+	// problemTruncated tiles end before the end of their gzip stream
+	problemTruncated = "truncated"
+	// problemStale tiles have layers which are not in the current map config
+	problemStale = "stale"
+)
+
+var VerifyCmd = &cobra.Command{
+	Use:     "verify",
+	Short:   "check that the cached tiles can be decoded and match the map config",
+	Example: "tegola cache verify --map osm --min-zoom 0 --max-zoom 14 --purge",
+	PreRunE: minMaxZoomValidate,
+	RunE:    verifyCommand,
+}
+
+func init() {
+	setupMinMaxZoomFlags(VerifyCmd, 0, atlas.MaxZoom)
+	VerifyCmd.Flags().StringVarP(&verifyMap, "map", "", "", "map name as defined in the config. defaults to all maps")
+	VerifyCmd.Flags().BoolVarP(&verifyPurge, "purge", "", false, "purge the corrupt, truncated and stale tiles")
+	VerifyCmd.Flags().IntVarP(&cacheConcurrency, "concurrency", "", runtime.NumCPU(), "the amount of concurrency to use. defaults to the number of CPUs on the machine")
+	VerifyCmd.Flags().StringVarP(&cacheCheckpointFile, "checkpoint-file", "", "", "file to record the verified tiles to. a run restarted with the same checkpoint file and flags skips the tiles already verified. requires a backend which lists tiles in a stable order")
+	VerifyCmd.Flags().DurationVarP(&cacheProgressInterval, "progress-interval", "", 30*time.Second, "how often to log the progress and write the checkpoint file. 0 disables the progress log")
+	VerifyCmd.Flags().StringVarP(&cacheReportFile, "report-file", "", "", "file to write a JSON report of the run to once done. use - for stdout")
+	VerifyCmd.Flags().StringVarP(&cacheRetryFile, "retry-file", "", "", "file to append the bad tiles to, in the z/x/y format of the tile-list command, to seed them again")
+}
+
+// errBadTile is the problem found with a cached tile
+type errBadTile struct {
+	Problem string
+	Key     cache.Key
+	Err     error
+}
+
+func (e errBadTile) Error() string {
+	return fmt.Sprintf("%v tile (%v): %v", e.Problem, e.Key.String(), e.Err)
+}
+
+func (e errBadTile) Unwrap() error { return e.Err }
+
+func verifyCommand(_ *cobra.Command, _ []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer gdcmd.New().Complete()
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gdcmd.Cancelled():
+			cancel()
+		}
+	}()
+
+	if cacheConcurrency <= 0 {
+		return fmt.Errorf("invalid value for concurrency (%v). expecting a positive number", cacheConcurrency)
+	}
+	if verifyPurge && cacheCheckpointFile != "" {
+		// the checkpoint counts the listed tiles, which purging removes
+		return fmt.Errorf("checkpoint-file can't be used with purge")
+	}
+
+	c := atlas.GetCache()
+	lister, ok := cache.Original(c).(cache.Lister)
+	if !ok {
+		return fmt.Errorf("the configured cache backend does not support listing tiles")
+	}
+
+	maps := atlas.AllMaps()
+	if verifyMap != "" {
+		m, err := atlas.GetMap(verifyMap)
+		if err != nil {
+			return err
+		}
+		maps = []atlas.Map{m}
+	}
+
+	names := make([]string, len(maps))
+	for i := range maps {
+		names[i] = maps[i].Name
+	}
+	prog, err := newProgress("verify", names, nil, runFingerprint("verify", names, zooms, verifyPurge))
+	if err != nil {
+		return err
+	}
+
+	return runVerify(ctx, c, lister, maps, cacheConcurrency, verifyPurge, prog)
+}
+
+// verifyJob is a listed tile to verify
+type verifyJob struct {
+	key cache.Key
+	mt  MapTile
+}
+
+// runVerify lists the cached tiles of the maps in the zooms, and verifies them with
+// concurrency workers. The bad tiles are counted and, when purge is true, purged.
+// Errors reading from the cache stop the run.
+func runVerify(ctx context.Context, c cache.Interface, lister cache.Lister, maps []atlas.Map, concurrency int, purge bool, prog *progress) (err error) {
+	defer func() {
+		if cerr := prog.close(err); err == nil {
+			err = cerr
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	byName := make(map[string]atlas.Map, len(maps))
+	for _, m := range maps {
+		byName[m.Name] = m
+	}
+
+	var (
+		wg      sync.WaitGroup
+		errOnce sync.Once
+		runErr  error
+		jobs    = make(chan verifyJob)
+	)
+	stop := func(err error) {
+		errOnce.Do(func() {
+			runErr = err
+			cancel()
+		})
+	}
+
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				err := verifyCachedTile(ctx, c, byName[job.mt.MapName], job.key, purge)
+
+				var bad errBadTile
+				switch {
+				case errors.As(err, &bad):
+					log.Warnf("%v", err)
+					prog.problem(bad.Problem)
+				case err != nil:
+					stop(err)
+					continue
+				}
+				prog.finish(job.mt, err)
+			}
+		}()
+	}
+
+	progCtx, stopProgress := context.WithCancel(ctx)
+	defer stopProgress()
+	go prog.run(progCtx, cacheProgressInterval)
+
+	listErr := func() error {
+		defer close(jobs)
+
+		for _, m := range maps {
+			filter := cache.ListFilter{
+				MapName: m.Name,
+				Version: atlas.KeyVersion(m),
+				Zooms:   zooms,
+			}
+
+			// the tiles are verified while listing, so the keys of a map are never
+			// held in memory all at once
+			err := lister.List(ctx, filter, func(ki cache.KeyInfo) error {
+				tile := slippy.Tile{Z: slippy.Zoom(ki.Key.Z), X: ki.Key.X, Y: ki.Key.Y}
+				seq, skip := prog.start(tile, 1)
+				if skip {
+					// verified by a previous run
+					return nil
+				}
+
+				select {
+				case jobs <- verifyJob{key: ki.Key, mt: MapTile{MapName: m.Name, Tile: tile, seq: seq}}:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return fmt.Errorf("error listing tiles of map (%v): %w", m.Name, err)
+			}
+		}
+		return nil
+	}()
+
+	wg.Wait()
+	stopProgress()
+
+	if runErr != nil {
+		return runErr
+	}
+	if listErr != nil {
+		return listErr
+	}
+	return atlas.FlushCache(ctx)
+}
+
+// verifyCachedTile reads a tile from the cache and checks it, purging it when it's bad and
+// purge is true. Bad tiles are reported with an errBadTile error.
+func verifyCachedTile(ctx context.Context, c cache.Interface, m atlas.Map, key cache.Key, purge bool) error {
+	val, hit, err := c.Get(ctx, &key)
+	if err != nil {
+		return fmt.Errorf("error reading tile (%v): %w", key.String(), err)
+	}
+	if !hit {
+		// removed since it was listed
+		return nil
+	}
+
+	problem, err := verifyTile(m, key, val)
+	if problem == "" {
+		return nil
+	}
+
+	if purge {
+		if perr := c.Purge(ctx, &key); perr != nil {
+			return fmt.Errorf("error purging tile (%v): %w", key.String(), perr)
+		}
+		err = fmt.Errorf("%w. purged", err)
+	}
+	return errBadTile{Problem: problem, Key: key, Err: err}
+}
+
+// verifyTile decompresses and decodes the tile cached under key, and checks its layers are
+// layers of the map at the tile zoom. The problem of a bad tile is returned along with
+// its details. The empty tile sentinel is a valid tile, any other value without data is
+// truncated.
+func verifyTile(m atlas.Map, key cache.Key, val []byte) (problem string, err error) {
+	switch {
+	case cache.IsEmptyTile(val):
+		return "", nil
+	case len(val) == 0:
+		return problemTruncated, errors.New("tile has no data")
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(val))
+	if err != nil {
+		return readProblem(err), err
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return readProblem(err), err
+	}
+
+	var tile vectorTile.Tile
+	if err = proto.Unmarshal(raw, &tile); err != nil {
+		return problemCorrupt, err
+	}
+
+	layers := m.FilterLayersByZoom(slippy.Zoom(key.Z))
+	if key.LayerName != "" {
+		if layers = layers.FilterLayersByName(key.LayerName); len(layers.Layers) == 0 {
+			return problemStale, fmt.Errorf("map has no layer (%v) at zoom %v", key.LayerName, key.Z)
+		}
+	}
+
+	names := make(map[string]bool, len(layers.Layers))
+	for _, l := range layers.Layers {
+		names[l.MVTName()] = true
+	}
+	for _, l := range tile.Layers {
+		if !names[l.GetName()] {
+			return problemStale, fmt.Errorf("map has no layer (%v) at zoom %v", l.GetName(), key.Z)
+		}
+	}
+
+	return "", nil
+}
+
+// readProblem tells truncated tiles apart from otherwise corrupt ones
+func readProblem(err error) string {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return problemTruncated
+	}
+	return problemCorrupt
+}
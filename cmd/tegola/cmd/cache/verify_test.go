@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/cache/memory"
+	"github.com/go-spatial/tegola/provider/test"
+)
+
+func TestRunVerify(t *testing.T) {
+	type tcase struct {
+		purge    bool
+		problems map[string]uint64
+		retries  []string
+		// expected values of the tiles by key, nil when removed
+		expected map[cache.Key][]byte
+	}
+
+	var (
+		ctx   = context.Background()
+		valid = encodeTestTile(t, &test.TileProvider{})
+
+		validKey     = cache.Key{MapName: "osm", Z: 1, X: 0, Y: 0}
+		sentinelKey  = cache.Key{MapName: "osm", Z: 1, X: 1, Y: 0}
+		truncatedKey = cache.Key{MapName: "osm", Z: 1, X: 0, Y: 1}
+		corruptKey   = cache.Key{MapName: "osm", Z: 1, X: 1, Y: 1}
+		staleKey     = cache.Key{MapName: "osm", LayerName: "roads", Z: 2, X: 0, Y: 0}
+		noDataKey    = cache.Key{MapName: "osm", Z: 2, X: 1, Y: 1}
+	)
+
+	m := atlas.NewWebMercatorMap("osm")
+	m.Layers = []atlas.Layer{{
+		Name:              "layer",
+		ProviderLayerName: "test-layer",
+		MaxZoom:           20,
+		Provider:          &test.TileProvider{},
+		GeomType:          geom.Point{},
+	}}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			dir := t.TempDir()
+			reportFile, retryFile := filepath.Join(dir, "report.json"), filepath.Join(dir, "retry.txt")
+			setProgressFlags(t, "", reportFile, retryFile)
+
+			c, _ := memory.New(nil)
+			for key, val := range map[cache.Key][]byte{
+				validKey:     valid,
+				sentinelKey:  cache.EmptyTile,
+				truncatedKey: valid[:len(valid)/2],
+				corruptKey:   []byte("not a tile"),
+				staleKey:     valid,
+				noDataKey:    {},
+			} {
+				key := key
+				if err := c.Set(ctx, &key, val); err != nil {
+					t.Fatalf("set, expected nil got %v", err)
+				}
+			}
+
+			prog, err := newProgress("verify", []string{"osm"}, nil, "")
+			if err != nil {
+				t.Fatalf("progress, expected nil got %v", err)
+			}
+			if err = runVerify(ctx, c, c.(cache.Lister), []atlas.Map{m}, 2, tc.purge, prog); err != nil {
+				t.Fatalf("verify, expected nil got %v", err)
+			}
+
+			b, err := os.ReadFile(reportFile)
+			if err != nil {
+				t.Fatalf("read report, expected nil got %v", err)
+			}
+			var r report
+			if err = json.Unmarshal(b, &r); err != nil {
+				t.Fatalf("decode report, expected nil got %v", err)
+			}
+			if !reflect.DeepEqual(r.Problems, tc.problems) {
+				t.Errorf("problems, expected %v got %v", tc.problems, r.Problems)
+			}
+
+			b, err = os.ReadFile(retryFile)
+			if err != nil {
+				t.Fatalf("read retry file, expected nil got %v", err)
+			}
+			retries := strings.Fields(string(b))
+			if len(retries) != len(tc.retries) {
+				t.Errorf("retries, expected %v got %v", tc.retries, retries)
+			}
+
+			for key, expected := range tc.expected {
+				key := key
+				val, hit, err := c.Get(ctx, &key)
+				if err != nil {
+					t.Fatalf("get, expected nil got %v", err)
+				}
+				if hit != (expected != nil) || !bytes.Equal(val, expected) {
+					t.Errorf("tile %v, expected %v got %v (hit %v)", key.String(), expected, val, hit)
+				}
+			}
+		}
+	}
+
+	problems := map[string]uint64{
+		problemTruncated: 2,
+		problemCorrupt:   1,
+		problemStale:     1,
+	}
+
+	tests := map[string]tcase{
+		"report": {
+			problems: problems,
+			retries:  []string{"1/0/1", "1/1/1", "2/0/0", "2/1/1"},
+			expected: map[cache.Key][]byte{
+				validKey:     valid,
+				sentinelKey:  cache.EmptyTile,
+				truncatedKey: valid[:len(valid)/2],
+				staleKey:     valid,
+				noDataKey:    {},
+			},
+		},
+		"purge": {
+			purge:    true,
+			problems: problems,
+			retries:  []string{"1/0/1", "1/1/1", "2/0/0", "2/1/1"},
+			expected: map[cache.Key][]byte{
+				validKey:     valid,
+				sentinelKey:  cache.EmptyTile,
+				truncatedKey: nil,
+				corruptKey:   nil,
+				staleKey:     nil,
+				noDataKey:    nil,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestVerifyTile(t *testing.T) {
+	type tcase struct {
+		key     cache.Key
+		val     []byte
+		problem string
+	}
+
+	valid := encodeTestTile(t, &test.TileProvider{})
+
+	m := atlas.NewWebMercatorMap("osm")
+	m.Layers = []atlas.Layer{{
+		Name:              "layer",
+		ProviderLayerName: "test-layer",
+		MinZoom:           0,
+		MaxZoom:           10,
+		Provider:          &test.TileProvider{},
+		GeomType:          geom.Point{},
+	}}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			problem, err := verifyTile(m, tc.key, tc.val)
+			if problem != tc.problem {
+				t.Errorf("problem, expected %q got %q (%v)", tc.problem, problem, err)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"valid": {
+			key: cache.Key{MapName: "osm", Z: 2},
+			val: valid,
+		},
+		"valid layer": {
+			key: cache.Key{MapName: "osm", LayerName: "layer", Z: 2},
+			val: valid,
+		},
+		"sentinel": {
+			key: cache.Key{MapName: "osm", Z: 2},
+			val: cache.EmptyTile,
+		},
+		"truncated": {
+			key:     cache.Key{MapName: "osm", Z: 2},
+			val:     valid[:len(valid)-4],
+			problem: problemTruncated,
+		},
+		"no data": {
+			key:     cache.Key{MapName: "osm", Z: 2},
+			val:     []byte{},
+			problem: problemTruncated,
+		},
+		"corrupt": {
+			key:     cache.Key{MapName: "osm", Z: 2},
+			val:     []byte("not a tile"),
+			problem: problemCorrupt,
+		},
+		"layer out of zoom": {
+			key:     cache.Key{MapName: "osm", Z: 12},
+			val:     valid,
+			problem: problemStale,
+		},
+		"removed layer": {
+			key:     cache.Key{MapName: "osm", LayerName: "roads", Z: 2},
+			val:     valid,
+			problem: problemStale,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
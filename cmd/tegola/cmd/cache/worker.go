@@ -10,7 +10,6 @@ import (
 	"github.com/go-spatial/geom/slippy"
 	"github.com/go-spatial/tegola"
 	"github.com/go-spatial/tegola/atlas"
-	"github.com/go-spatial/tegola/cache"
 	"github.com/go-spatial/tegola/internal/log"
 )
 
@@ -28,11 +27,8 @@ func (s seedPurgeWorkerTileError) Error() string {
 	return fmt.Sprintf("error %v tile (%+v): %v", cmd, s.Tile, s.Err)
 }
 
-func seedWorker(overwrite bool, logThresholdMs int64) func(ctx context.Context, mt MapTile) error {
+func seedWorker(overwrite bool, logThresholdMs int64, variants map[string][]atlas.TileVariant) func(ctx context.Context, mt MapTile) error {
 	return func(ctx context.Context, mt MapTile) error {
-		// track how long the tile generation is taking
-		t := time.Now()
-
 		//	lookup the Map
 		m, err := atlas.GetMap(mt.MapName)
 		if err != nil {
@@ -47,52 +43,49 @@ func seedWorker(overwrite bool, logThresholdMs int64) func(ctx context.Context,
 		//	filter down the layers we need for this zoom
 		m = m.FilterLayersByZoom(z)
 
-		//	check if overwriting the cache is not ok
-		if !overwrite {
-			//	lookup our cache
-			c := atlas.GetCache()
-			if c == nil {
-				return fmt.Errorf("error fetching cache: %v", err)
+		for _, v := range variants[mt.MapName] {
+			// track how long the tile generation is taking
+			t := time.Now()
+
+			//	check if overwriting the cache is not ok
+			if !overwrite {
+				//	lookup our cache
+				c := atlas.GetCache()
+				if c == nil {
+					return fmt.Errorf("error fetching cache: %v", err)
+				}
+
+				//	read the tile from the cache
+				_, hit, err := c.Get(ctx, atlas.CacheKey(m, mt.Tile, v))
+				if err != nil {
+					return fmt.Errorf("error reading from cache: %v", err)
+				}
+				//	if we have a cache hit, then skip processing this tile
+				if hit {
+					log.Infof("cache seed set to not overwrite existing tiles. skipping map (%v) %v (%v/%v/%v)", mt.MapName, variantString(v), z, x, y)
+					continue
+				}
 			}
 
-			//	cache key
-			key := cache.Key{
-				MapName: mt.MapName,
-				Z:       uint(z),
-				X:       x,
-				Y:       y,
+			//	seed the tile
+			if err = atlas.SeedMapTileVariant(ctx, m, uint(z), x, y, v); err != nil {
+				if errors.Is(err, context.Canceled) {
+					return err
+				}
+				return seedPurgeWorkerTileError{
+					Tile: mt.Tile,
+					Err:  err,
+				}
 			}
 
-			//	read the tile from the cache
-			_, hit, err := c.Get(ctx, &key)
-			if err != nil {
-				return fmt.Errorf("error reading from cache: %v", err)
-			}
-			//	if we have a cache hit, then skip processing this tile
-			if hit {
-				log.Infof("cache seed set to not overwrite existing tiles. skipping map (%v) tile (%v/%v/%v)", mt.MapName, z, x, y)
-				return nil
-			}
-		}
+			//	TODO: this is a hack to get around large arrays not being garbage collected
+			//	https://github.com/golang/go/issues/14045 - should be addressed in Go 1.11
+			runtime.GC()
 
-		//	seed the tile
-		if err = atlas.SeedMapTile(ctx, m, uint(z), x, y); err != nil {
-			if errors.Is(err, context.Canceled) {
-				return err
+			durationMs := time.Now().Sub(t).Nanoseconds() / 1000000
+			if durationMs >= logThresholdMs {
+				log.Infof("seeding map (%v) %v (%v/%v/%v) took: %dms", mt.MapName, variantString(v), z, x, y, durationMs)
 			}
-			return seedPurgeWorkerTileError{
-				Tile: mt.Tile,
-				Err:  err,
-			}
-		}
-
-		//	TODO: this is a hack to get around large arrays not being garbage collected
-		//	https://github.com/golang/go/issues/14045 - should be addressed in Go 1.11
-		runtime.GC()
-
-		durationMs := time.Now().Sub(t).Nanoseconds() / 1000000
-		if durationMs >= logThresholdMs {
-			log.Infof("seeding map (%v) tile (%v/%v/%v) took: %dms", mt.MapName, z, x, y, durationMs)
 		}
 
 		return nil
@@ -100,32 +93,35 @@ func seedWorker(overwrite bool, logThresholdMs int64) func(ctx context.Context,
 
 }
 
-func purgeWorker(ctx context.Context, mt MapTile) error {
-
-	z, x, y := mt.Tile.ZXY()
-
-	log.Infof("purging map (%v) tile (%v/%v/%v)", mt.MapName, z, x, y)
+func purgeWorker(variants map[string][]atlas.TileVariant) func(ctx context.Context, mt MapTile) error {
+	return func(ctx context.Context, mt MapTile) error {
+		z, x, y := mt.Tile.ZXY()
 
-	//	lookup the Map
-	m, err := atlas.GetMap(mt.MapName)
-	if err != nil {
-		return seedPurgeWorkerTileError{
-			Purge: true,
-			Tile:  mt.Tile,
-			Err:   err,
+		//	lookup the Map
+		m, err := atlas.GetMap(mt.MapName)
+		if err != nil {
+			return seedPurgeWorkerTileError{
+				Purge: true,
+				Tile:  mt.Tile,
+				Err:   err,
+			}
 		}
-	}
 
-	//	purge the tile
-	ttile := tegola.TileFromSlippyTile(mt.Tile)
+		//	purge the tile
+		ttile := tegola.TileFromSlippyTile(mt.Tile)
+
+		for _, v := range variants[mt.MapName] {
+			log.Infof("purging map (%v) %v (%v/%v/%v)", mt.MapName, variantString(v), z, x, y)
 
-	if err = atlas.PurgeMapTile(ctx, m, ttile); err != nil {
-		return seedPurgeWorkerTileError{
-			Purge: true,
-			Tile:  mt.Tile,
-			Err:   err,
+			if err = atlas.PurgeMapTileVariant(ctx, m, ttile, v); err != nil {
+				return seedPurgeWorkerTileError{
+					Purge: true,
+					Tile:  mt.Tile,
+					Err:   err,
+				}
+			}
 		}
-	}
 
-	return nil
+		return nil
+	}
 }
@@ -0,0 +1,139 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/cache/mbtiles"
+	"github.com/go-spatial/tegola/internal/pmtiles"
+	"github.com/go-spatial/tegola/mapbox/tilejson"
+)
+
+// the supported export formats
+const (
+	FormatDir     = "dir"
+	FormatMBTiles = "mbtiles"
+	FormatPMTiles = "pmtiles"
+)
+
+// archive is the destination of the exported tiles. WriteTile is called from a single goroutine.
+type archive interface {
+	WriteTile(ctx context.Context, z, x, y uint, data []byte) error
+	// Close writes the metadata describing the tileset and completes the archive
+	Close(ctx context.Context, desc cache.Tileset) error
+	// Abort releases the archive of a failed export
+	Abort() error
+}
+
+// newArchive creates the archive of the format at path
+func newArchive(format, path string) (archive, error) {
+	switch format {
+	case FormatDir:
+		if err := os.MkdirAll(path, os.ModePerm); err != nil {
+			return nil, err
+		}
+		return dirArchive{basepath: path}, nil
+	case FormatMBTiles:
+		w, err := mbtiles.NewWriter(path)
+		if err != nil {
+			return nil, err
+		}
+		return mbtilesArchive{w}, nil
+	case FormatPMTiles:
+		w, err := pmtiles.NewWriter(path)
+		if err != nil {
+			return nil, err
+		}
+		return pmtilesArchive{w}, nil
+	default:
+		return nil, fmt.Errorf("invalid value for format (%v). expecting one of %v, %v or %v", format, FormatDir, FormatMBTiles, FormatPMTiles)
+	}
+}
+
+// dirArchive writes the tiles to a z/x/y.pbf directory tree, along with a TileJSON
+// metadata.json file. Tiles are gzipped and must be served with a gzip Content-Encoding.
+type dirArchive struct {
+	basepath string
+}
+
+func (a dirArchive) WriteTile(_ context.Context, z, x, y uint, data []byte) error {
+	dir := filepath.Join(a.basepath, strconv.FormatUint(uint64(z), 10), strconv.FormatUint(uint64(x), 10))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, strconv.FormatUint(uint64(y), 10)+".pbf"), data, 0666)
+}
+
+func (a dirArchive) Close(_ context.Context, desc cache.Tileset) error {
+	tj := tilejson.TileJSON{
+		Attribution: &desc.Attribution,
+		Bounds:      desc.Bounds,
+		Center:      desc.Center,
+		Format:      "pbf",
+		MinZoom:     desc.MinZoom,
+		MaxZoom:     desc.MaxZoom,
+		Name:        &desc.Name,
+		Scheme:      tilejson.SchemeXYZ,
+		TileJSON:    tilejson.Version,
+		// relative to the metadata file
+		Tiles:   []string{"{z}/{x}/{y}.pbf"},
+		Grids:   make([]string, 0),
+		Data:    make([]string, 0),
+		Version: "1.0.0",
+	}
+	for _, l := range desc.Layers {
+		tj.VectorLayers = append(tj.VectorLayers, tilejson.VectorLayer{
+			Version: 2,
+			Extent:  4096,
+			ID:      l.ID,
+			Name:    l.ID,
+			MinZoom: l.MinZoom,
+			MaxZoom: l.MaxZoom,
+			Tiles:   make([]string, 0),
+		})
+	}
+
+	b, err := json.MarshalIndent(tj, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(a.basepath, "metadata.json"), b, 0666)
+}
+
+// Abort keeps the tiles written so far, as the directory may have existed before the export
+func (a dirArchive) Abort() error { return nil }
+
+// mbtilesArchive writes the tiles to a MBTiles file
+type mbtilesArchive struct {
+	w *mbtiles.Writer
+}
+
+func (a mbtilesArchive) WriteTile(ctx context.Context, z, x, y uint, data []byte) error {
+	return a.w.WriteTile(ctx, z, x, y, data)
+}
+
+func (a mbtilesArchive) Close(ctx context.Context, desc cache.Tileset) error {
+	return a.w.Close(ctx, desc)
+}
+
+func (a mbtilesArchive) Abort() error { return a.w.Abort() }
+
+// pmtilesArchive writes the tiles to a clustered PMTiles archive
+type pmtilesArchive struct {
+	w *pmtiles.Writer
+}
+
+func (a pmtilesArchive) WriteTile(_ context.Context, z, x, y uint, data []byte) error {
+	return a.w.WriteTile(uint8(z), uint32(x), uint32(y), data)
+}
+
+func (a pmtilesArchive) Close(_ context.Context, desc cache.Tileset) error {
+	return a.w.Close(desc)
+}
+
+func (a pmtilesArchive) Abort() error { return a.w.Abort() }
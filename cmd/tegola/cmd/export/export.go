@@ -0,0 +1,390 @@
+// Package export implements the export command, which writes the tiles of a map to a
+// static tile directory or a tileset archive which can be used without tegola
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/go-spatial/cobra"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/proj"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/cache"
+	cachecmd "github.com/go-spatial/tegola/cmd/tegola/cmd/cache"
+	gdcmd "github.com/go-spatial/tegola/internal/cmd"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/observability"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// flag parameters
+var (
+	exportMap         string
+	exportFormat      string
+	exportOutput      string
+	exportBounds      string
+	exportMinZoom     uint
+	exportMaxZoom     uint
+	exportConcurrency int
+	// exportNoCache renders every tile instead of reading the cached ones
+	exportNoCache bool
+	// exportSkipEmpty leaves the tiles without features out of the export
+	exportSkipEmpty bool
+	// exportOverwrite replaces an existing archive
+	exportOverwrite bool
+)
+
+var Cmd = &cobra.Command{
+	Use:     "export",
+	Short:   "export the tiles of a map to a tile directory, MBTiles or PMTiles archive",
+	Example: "tegola export --map osm --format pmtiles --output osm.pmtiles --bounds -10,35,30,60 --max-zoom 10",
+	PreRunE: exportValidate,
+	RunE:    exportCommand,
+}
+
+func init() {
+	Cmd.Flags().StringVarP(&exportMap, "map", "", "", "map name as defined in the config")
+	Cmd.Flags().StringVarP(&exportFormat, "format", "", FormatPMTiles, "format of the export: dir, mbtiles or pmtiles")
+	Cmd.Flags().StringVarP(&exportOutput, "output", "o", "", "path of the archive file, or of the directory for the dir format")
+	Cmd.Flags().StringVarP(&exportBounds, "bounds", "", "", "lng/lat bounds to export in the format: minx, miny, maxx, maxy. defaults to the bounds of the map")
+	Cmd.Flags().UintVarP(&exportMinZoom, "min-zoom", "", 0, "min zoom to export")
+	Cmd.Flags().UintVarP(&exportMaxZoom, "max-zoom", "", 0, "max zoom to export. defaults to the max zoom of the map layers")
+	Cmd.Flags().IntVarP(&exportConcurrency, "concurrency", "", runtime.NumCPU(), "the number of tiles rendered concurrently. defaults to the number of CPUs on the machine")
+	Cmd.Flags().BoolVarP(&exportNoCache, "no-cache", "", false, "render every tile instead of reading the tiles present in the cache")
+	Cmd.Flags().BoolVarP(&exportSkipEmpty, "skip-empty", "", false, "leave the tiles without features out of the export")
+	Cmd.Flags().BoolVarP(&exportOverwrite, "overwrite", "", false, "replace an existing archive, or write into an existing non empty directory")
+}
+
+func exportValidate(_ *cobra.Command, _ []string) error {
+	switch {
+	case exportMap == "":
+		return fmt.Errorf("map is required")
+	case exportOutput == "":
+		return fmt.Errorf("output is required")
+	case exportConcurrency <= 0:
+		return fmt.Errorf("invalid value for concurrency (%v). expecting a positive number", exportConcurrency)
+	}
+
+	switch exportFormat {
+	case FormatDir, FormatMBTiles, FormatPMTiles:
+	default:
+		return fmt.Errorf("invalid value for format (%v). expecting one of %v, %v or %v", exportFormat, FormatDir, FormatMBTiles, FormatPMTiles)
+	}
+
+	if exportOverwrite {
+		return nil
+	}
+	fi, err := os.Stat(exportOutput)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	case !fi.IsDir():
+		return fmt.Errorf("output (%v) already exists. use overwrite to replace it", exportOutput)
+	}
+	entries, err := os.ReadDir(exportOutput)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("output directory (%v) is not empty. use overwrite to write into it", exportOutput)
+	}
+	return nil
+}
+
+// parseBounds parses lng/lat bounds in the format minx, miny, maxx, maxy
+func parseBounds(str string) (bounds [4]float64, err error) {
+	parts := strings.Split(strings.TrimSpace(str), ",")
+	if len(parts) != 4 {
+		return bounds, fmt.Errorf("invalid value for bounds (%v). expecting minx, miny, maxx, maxy", str)
+	}
+
+	var ok bool
+	for i, part := range parts {
+		if i%2 == 0 {
+			bounds[i], ok = cachecmd.IsValidLngString(part)
+		} else {
+			bounds[i], ok = cachecmd.IsValidLatString(part)
+		}
+		if !ok {
+			return bounds, fmt.Errorf("invalid value (%v) for bounds (%v)", part, str)
+		}
+	}
+	if bounds[0] > bounds[2] || bounds[1] > bounds[3] {
+		return bounds, fmt.Errorf("invalid value for bounds (%v). expecting minx <= maxx and miny <= maxy", str)
+	}
+	return bounds, nil
+}
+
+func exportCommand(cmd *cobra.Command, _ []string) (err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer gdcmd.New().Complete()
+	gdcmd.OnComplete(provider.Cleanup)
+	gdcmd.OnComplete(observability.Cleanup)
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gdcmd.Cancelled():
+			cancel()
+		}
+	}()
+
+	m, err := atlas.GetMap(exportMap)
+	if err != nil {
+		return err
+	}
+	if !m.Cacheable() {
+		return fmt.Errorf("map (%v) has live layers, its tiles are rendered for every request and can't be exported", m.Name)
+	}
+
+	desc := m.Tileset("")
+	if exportBounds != "" {
+		if desc.Bounds, err = parseBounds(exportBounds); err != nil {
+			return err
+		}
+	}
+	desc.MinZoom = exportMinZoom
+	if cmd.Flags().Changed("max-zoom") {
+		desc.MaxZoom = exportMaxZoom
+	}
+	if desc.MaxZoom < desc.MinZoom {
+		return fmt.Errorf("invalid zoom range, min (%v) is greater than max (%v)", desc.MinZoom, desc.MaxZoom)
+	}
+	if desc.MaxZoom > atlas.MaxZoom {
+		return fmt.Errorf("invalid value for max-zoom (%v). expecting at most %v", desc.MaxZoom, atlas.MaxZoom)
+	}
+
+	var c cache.Interface
+	if !exportNoCache {
+		c = atlas.GetCache()
+	}
+
+	if exportOverwrite && exportFormat != FormatDir {
+		// the mbtiles writer would add the tiles to the existing file
+		if err = os.Remove(exportOutput); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	a, err := newArchive(exportFormat, exportOutput)
+	if err != nil {
+		return err
+	}
+
+	stats, err := runExport(ctx, m, c, clampTileset(desc), a, exportConcurrency, exportSkipEmpty)
+	if err != nil {
+		if aerr := a.Abort(); aerr != nil {
+			log.Errorf("error removing the archive (%v): %v", exportOutput, aerr)
+		}
+		return err
+	}
+
+	log.Infof("exported %v tiles of %v bytes to (%v): %v rendered, %v from the cache, %v empty tiles skipped",
+		stats.Written, stats.Bytes, exportOutput, stats.Rendered, stats.Cached, stats.Skipped)
+	return nil
+}
+
+// clampTileset limits the layers of the tileset to its zoom range, and moves the center
+// into the bounds and zoom range when it's outside of them
+func clampTileset(desc cache.Tileset) cache.Tileset {
+	layers := desc.Layers[:0:0]
+	for _, l := range desc.Layers {
+		if l.MaxZoom < desc.MinZoom || l.MinZoom > desc.MaxZoom {
+			continue
+		}
+		if l.MinZoom < desc.MinZoom {
+			l.MinZoom = desc.MinZoom
+		}
+		if l.MaxZoom > desc.MaxZoom {
+			l.MaxZoom = desc.MaxZoom
+		}
+		layers = append(layers, l)
+	}
+	desc.Layers = layers
+
+	lng, lat, z := desc.Center[0], desc.Center[1], uint(desc.Center[2])
+	if lng < desc.Bounds[0] || lng > desc.Bounds[2] || lat < desc.Bounds[1] || lat > desc.Bounds[3] {
+		lng, lat = (desc.Bounds[0]+desc.Bounds[2])/2, (desc.Bounds[1]+desc.Bounds[3])/2
+	}
+	if z < desc.MinZoom {
+		z = desc.MinZoom
+	}
+	if z > desc.MaxZoom {
+		z = desc.MaxZoom
+	}
+	desc.Center = [3]float64{lng, lat, float64(z)}
+
+	return desc
+}
+
+// exportStats counts the tiles of an export
+type exportStats struct {
+	Written  uint64
+	Rendered uint64
+	Cached   uint64
+	Skipped  uint64
+	Bytes    uint64
+}
+
+// exportTile is a tile ready to be written to the archive
+type exportTile struct {
+	tile slippy.Tile
+	data []byte
+}
+
+// runExport writes the tiles of the map within the bounds and zoom range of desc to the
+// archive, and completes the archive with desc. Tiles are read from the cache c when
+// present, or else rendered by concurrency workers.
+func runExport(ctx context.Context, m atlas.Map, c cache.Interface, desc cache.Tileset, a archive, concurrency int, skipEmpty bool) (stats exportStats, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		errOnce sync.Once
+		runErr  error
+		tiles   = make(chan slippy.Tile)
+		results = make(chan exportTile)
+		// guards the rendered and cached counts
+		mu sync.Mutex
+	)
+	stop := func(err error) {
+		errOnce.Do(func() {
+			runErr = err
+			cancel()
+		})
+	}
+
+	// generate the tiles
+	go func() {
+		defer close(tiles)
+
+		grid := slippy.NewGrid(proj.EPSG4326, 0)
+		var extent geom.Extent = desc.Bounds
+		for z := desc.MinZoom; z <= desc.MaxZoom; z++ {
+			zoomTiles, err := slippy.FromBounds(grid, &extent, slippy.Zoom(z))
+			if err != nil {
+				stop(fmt.Errorf("got error trying to get tiles: %w", err))
+				return
+			}
+			for _, tile := range zoomTiles {
+				select {
+				case tiles <- tile:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for tile := range tiles {
+				data, empty, cached, err := tileData(ctx, m, c, tile, skipEmpty)
+				if err != nil {
+					stop(err)
+					continue
+				}
+
+				mu.Lock()
+				if cached {
+					stats.Cached++
+				} else {
+					stats.Rendered++
+				}
+				if empty && skipEmpty {
+					stats.Skipped++
+				}
+				mu.Unlock()
+
+				if empty && skipEmpty {
+					continue
+				}
+				select {
+				case results <- exportTile{tile: tile, data: data}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for res := range results {
+		if ctx.Err() != nil {
+			// drain the results of a failed export
+			continue
+		}
+		z, x, y := res.tile.ZXY()
+		if err := a.WriteTile(ctx, uint(z), x, y, res.data); err != nil {
+			stop(fmt.Errorf("error writing tile (%v/%v/%v): %w", z, x, y, err))
+			continue
+		}
+		stats.Written++
+		stats.Bytes += uint64(len(res.data))
+	}
+
+	// the workers are done, runErr is set
+	if runErr != nil {
+		return stats, runErr
+	}
+	if err = ctx.Err(); err != nil {
+		return stats, err
+	}
+	return stats, a.Close(ctx, desc)
+}
+
+// tileData returns the encoded tile, read from the cache c when present or else rendered.
+// empty reports a tile without features, and is only checked when skipEmpty is true.
+func tileData(ctx context.Context, m atlas.Map, c cache.Interface, tile slippy.Tile, skipEmpty bool) (data []byte, empty, cached bool, err error) {
+	if c != nil {
+		val, hit, err := c.Get(ctx, atlas.CacheKey(m, tile, atlas.TileVariant{}))
+		if err != nil {
+			// a cache failure is not fatal, the tile is rendered instead
+			log.Warnf("error reading tile (%v/%v/%v) from the cache: %v", tile.Z, tile.X, tile.Y, err)
+		}
+		switch {
+		case !hit:
+		case cache.IsEmptyTile(val) && skipEmpty:
+			return nil, true, true, nil
+		case cache.IsEmptyTile(val):
+			// the sentinel has no data, the empty tile is rendered
+		default:
+			if skipEmpty {
+				if empty, err = atlas.IsEmptyTile(val); err != nil {
+					return nil, false, false, fmt.Errorf("error decoding cached tile (%v/%v/%v): %w", tile.Z, tile.X, tile.Y, err)
+				}
+			}
+			return val, empty, true, nil
+		}
+	}
+
+	zm := m.FilterLayersByZoom(tile.Z)
+	params, err := zm.ParamValues(nil)
+	if err != nil {
+		return nil, false, false, err
+	}
+	if skipEmpty {
+		data, empty, err = zm.EncodeCheckEmpty(ctx, tile, params)
+	} else {
+		data, err = zm.Encode(ctx, tile, params)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		err = fmt.Errorf("error rendering tile (%v/%v/%v): %w", tile.Z, tile.X, tile.Y, err)
+	}
+	return data, empty, false, err
+}
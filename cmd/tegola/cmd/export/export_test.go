@@ -0,0 +1,229 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/cache/file"
+	"github.com/go-spatial/tegola/cache/mbtiles"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/pmtiles"
+	"github.com/go-spatial/tegola/mapbox/tilejson"
+	"github.com/go-spatial/tegola/provider"
+	"github.com/go-spatial/tegola/provider/test"
+	"github.com/go-spatial/tegola/provider/test/emptycollection"
+)
+
+func testMap(p provider.Tiler) atlas.Map {
+	m := atlas.NewWebMercatorMap("osm")
+	m.Layers = []atlas.Layer{{
+		Name:              "points",
+		ProviderLayerName: "test-layer",
+		MinZoom:           0,
+		MaxZoom:           20,
+		Provider:          p,
+		GeomType:          geom.Point{},
+	}}
+	return m
+}
+
+func TestRunExport(t *testing.T) {
+	type tcase struct {
+		format string
+		// check verifies the archive at path
+		check func(t *testing.T, path string)
+	}
+
+	ctx := context.Background()
+	m := testMap(&test.TileProvider{})
+	desc := m.Tileset("")
+	desc.MinZoom, desc.MaxZoom = 0, 1
+	desc = clampTileset(desc)
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "osm."+tc.format)
+
+			a, err := newArchive(tc.format, path)
+			if errors.Is(err, mbtiles.ErrUnsupported) {
+				t.Skip("mbtiles requires cgo")
+			}
+			if err != nil {
+				t.Fatalf("new archive, expected nil got %v", err)
+			}
+
+			stats, err := runExport(ctx, m, nil, desc, a, 2, false)
+			if err != nil {
+				t.Fatalf("export, expected nil got %v", err)
+			}
+			// 1 tile at zoom 0 and 4 at zoom 1
+			if stats.Written != 5 || stats.Rendered != 5 {
+				t.Errorf("written and rendered, expected 5 5 got %v %v", stats.Written, stats.Rendered)
+			}
+
+			tc.check(t, path)
+		}
+	}
+
+	tests := map[string]tcase{
+		"dir": {
+			format: FormatDir,
+			check: func(t *testing.T, path string) {
+				if _, err := os.Stat(filepath.Join(path, "1", "1", "0.pbf")); err != nil {
+					t.Errorf("tile 1/1/0, expected nil got %v", err)
+				}
+
+				b, err := os.ReadFile(filepath.Join(path, "metadata.json"))
+				if err != nil {
+					t.Fatalf("read metadata, expected nil got %v", err)
+				}
+				var tj tilejson.TileJSON
+				if err = json.Unmarshal(b, &tj); err != nil {
+					t.Fatalf("decode metadata, expected nil got %v", err)
+				}
+				if tj.MaxZoom != 1 || len(tj.VectorLayers) != 1 || tj.VectorLayers[0].ID != "points" || tj.VectorLayers[0].MaxZoom != 1 {
+					t.Errorf("unexpected metadata %+v", tj)
+				}
+			},
+		},
+		"mbtiles": {
+			format: FormatMBTiles,
+			check: func(t *testing.T, path string) {
+				if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+					t.Errorf("archive, expected a file got %v", err)
+				}
+			},
+		},
+		"pmtiles": {
+			format: FormatPMTiles,
+			check: func(t *testing.T, path string) {
+				b, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("read archive, expected nil got %v", err)
+				}
+				var h pmtiles.Header
+				if err = h.UnmarshalBinary(b); err != nil {
+					t.Fatalf("read header, expected nil got %v", err)
+				}
+				if h.AddressedTilesCount != 5 || h.MaxZoom != 1 || h.TileType != pmtiles.TileTypeMVT {
+					t.Errorf("unexpected header %+v", h)
+				}
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestTileData(t *testing.T) {
+	type tcase struct {
+		cached    []byte
+		skipEmpty bool
+		empty     bool
+		fromCache bool
+	}
+
+	var (
+		ctx  = context.Background()
+		m    = testMap(&emptycollection.TileProvider{})
+		tile = slippy.Tile{Z: 1, X: 1, Y: 0}
+	)
+
+	rendered, err := m.Encode(ctx, tile, nil)
+	if err != nil {
+		t.Fatalf("encode, expected nil got %v", err)
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			c, err := file.New(dict.Dict{"basepath": t.TempDir()})
+			if err != nil {
+				t.Fatalf("new cache, expected nil got %v", err)
+			}
+			if tc.cached != nil {
+				if err = c.Set(ctx, atlas.CacheKey(m, tile, atlas.TileVariant{}), tc.cached); err != nil {
+					t.Fatalf("set, expected nil got %v", err)
+				}
+			}
+
+			data, empty, fromCache, err := tileData(ctx, m, c, tile, tc.skipEmpty)
+			if err != nil {
+				t.Fatalf("tile data, expected nil got %v", err)
+			}
+			if empty != tc.empty || fromCache != tc.fromCache {
+				t.Errorf("empty and cached, expected %v %v got %v %v", tc.empty, tc.fromCache, empty, fromCache)
+			}
+			if !tc.skipEmpty && len(data) == 0 {
+				t.Errorf("data, expected a tile got none")
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		// emptiness is only checked when empty tiles are skipped
+		"render": {},
+		"render skipped": {
+			skipEmpty: true,
+			empty:     true,
+		},
+		"cached": {
+			cached:    rendered,
+			fromCache: true,
+		},
+		"cached empty skipped": {
+			cached:    rendered,
+			skipEmpty: true,
+			empty:     true,
+			fromCache: true,
+		},
+		"sentinel rendered": {
+			cached: cache.EmptyTile,
+		},
+		"sentinel skipped": {
+			cached:    cache.EmptyTile,
+			skipEmpty: true,
+			empty:     true,
+			fromCache: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestClampTileset(t *testing.T) {
+	desc := clampTileset(cache.Tileset{
+		Bounds:  [4]float64{0, 0, 10, 10},
+		Center:  [3]float64{-50, 20, 14},
+		MinZoom: 2,
+		MaxZoom: 8,
+		Layers: []cache.TilesetLayer{
+			{ID: "countries", MinZoom: 0, MaxZoom: 4},
+			{ID: "roads", MinZoom: 6, MaxZoom: 20},
+			{ID: "buildings", MinZoom: 14, MaxZoom: 20},
+		},
+	})
+
+	if expected := [3]float64{5, 5, 8}; desc.Center != expected {
+		t.Errorf("center, expected %v got %v", expected, desc.Center)
+	}
+	expected := []cache.TilesetLayer{
+		{ID: "countries", MinZoom: 2, MaxZoom: 4},
+		{ID: "roads", MinZoom: 6, MaxZoom: 8},
+	}
+	if !reflect.DeepEqual(desc.Layers, expected) {
+		t.Errorf("layers, expected %v got %v", expected, desc.Layers)
+	}
+}
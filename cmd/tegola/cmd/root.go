@@ -9,6 +9,8 @@ import (
 	"github.com/go-spatial/tegola/atlas"
 	"github.com/go-spatial/tegola/cmd/internal/register"
 	cachecmd "github.com/go-spatial/tegola/cmd/tegola/cmd/cache"
+	exportcmd "github.com/go-spatial/tegola/cmd/tegola/cmd/export"
+	tilecmd "github.com/go-spatial/tegola/cmd/tegola/cmd/tile"
 	"github.com/go-spatial/tegola/config"
 	"github.com/go-spatial/tegola/dict"
 	"github.com/go-spatial/tegola/internal/build"
@@ -40,6 +42,10 @@ func init() {
 	// cache seed / purge
 	cachecmd.Config = &conf
 	RootCmd.AddCommand(cachecmd.Cmd)
+	// export
+	RootCmd.AddCommand(exportcmd.Cmd)
+	// tile render / inspect
+	RootCmd.AddCommand(tilecmd.Cmd)
 	// version
 	RootCmd.AddCommand(versionCmd)
 }
@@ -56,7 +62,8 @@ func rootCmdValidatePersistent(cmd *cobra.Command, _ []string) (err error) {
 	requireCache := RequireCache || cachecmd.RequireCache
 	cmdName := cmd.CalledAs()
 	switch cmdName {
-	case "help", "version":
+	// tile inspect only decodes a file
+	case "help", "version", "inspect":
 		build.Commands = append(build.Commands, cmdName)
 		return nil
 	default:
@@ -100,7 +107,7 @@ func initConfig(configFile string, cacheRequired bool, logLevel string) (err err
 	}
 
 	// init our maps
-	if err = register.Maps(nil, conf.Maps, providers); err != nil {
+	if err = register.Maps(nil, conf.Maps, providers, provArr); err != nil {
 		return fmt.Errorf("could not register maps: %v", err)
 	}
 	if len(conf.Cache) == 0 && cacheRequired {
@@ -117,6 +124,18 @@ func initConfig(configFile string, cacheRequired bool, logLevel string) (err err
 		if cache != nil {
 			atlas.SetCache(cache)
 		}
+
+		versioning, err := register.CacheKeyVersioning(conf.Cache)
+		if err != nil {
+			return fmt.Errorf("could not register cache: %v", err)
+		}
+		atlas.SetCacheKeyVersioning(versioning)
+
+		sweepUnversioned, err := register.CacheSweepUnversioned(conf.Cache)
+		if err != nil {
+			return fmt.Errorf("could not register cache: %v", err)
+		}
+		atlas.SetCacheSweepUnversioned(sweepUnversioned)
 	}
 	observer, err := register.Observer(conf.Observer)
 	if err != nil {
@@ -20,6 +20,7 @@ import (
 	"github.com/go-spatial/tegola/observability"
 	"github.com/go-spatial/tegola/provider"
 	"github.com/go-spatial/tegola/server"
+	"github.com/go-spatial/tegola/tilestats"
 )
 
 var (
@@ -54,6 +55,20 @@ var serverCmd = &cobra.Command{
 		build.Commands = append(build.Commands, cmd.Name())
 		atlas.StartSubProcesses()
 
+		// the tiles cached under the versions of an older config are only swept when a map
+		// is updated. the ones left over from previous runs are cleaned up when asked for, as
+		// other instances sharing the cache, i.e. during a rolling deploy, may still use them
+		if !serverNoCache && len(conf.Cache) > 0 {
+			sweep, err := register.CacheSweepOnStart(conf.Cache)
+			if err != nil {
+				log.Errorf("could not register cache: %v", err)
+				os.Exit(1)
+			}
+			if sweep {
+				go atlas.SweepGenerations()
+			}
+		}
+
 		// set user defined response headers
 		for name, value := range conf.Webserver.Headers {
 			// cast to string
@@ -92,6 +107,22 @@ var serverCmd = &cobra.Command{
 			server.SSLKey = string(conf.Webserver.SSLKey)
 		}
 
+		// record the tile requests if configured
+		if len(conf.TileStats) > 0 {
+			store, err := tilestats.New(conf.TileStats)
+			if err != nil {
+				log.Errorf("could not set up tile_stats: %v", err)
+				os.Exit(1)
+			}
+
+			server.TileStats = store
+			gdcmd.OnComplete(func() {
+				if err := store.Close(); err != nil {
+					log.Errorf("error closing tile_stats: %v", err)
+				}
+			})
+		}
+
 		// initialize config source if configured
 		var configWatcher *source.ConfigWatcher
 		log.Infof("Full config struct: %+v", conf)
@@ -186,7 +217,7 @@ func handleConfigUpdate(app source.App) {
 	}
 
 	// register new maps
-	if err = register.Maps(nil, app.Maps, providers); err != nil {
+	if err = register.Maps(nil, app.Maps, providers, provArr); err != nil {
 		log.Errorf("Failed to register maps for app %s: %v", app.Key, err)
 		return
 	}
@@ -205,5 +236,10 @@ func shutdown(srv *http.Server) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 		defer cancel() // releases resources if slowOperation completes before timeout elapses
 		srv.Shutdown(ctx)
+
+		// some cache backends buffer their writes
+		if err := atlas.FlushCache(context.Background()); err != nil {
+			log.Errorf("error flushing the cache: %v", err)
+		}
 	})
 }
@@ -0,0 +1,178 @@
+package tile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-spatial/cobra"
+	vectorTile "github.com/go-spatial/geom/encoding/mvt/vector_tile"
+	"github.com/golang/protobuf/proto"
+)
+
+var InspectCmd = &cobra.Command{
+	Use:     "inspect filename|-",
+	Short:   "decode a MVT tile and report its layers, features, attributes and geometry types",
+	Example: "tegola tile inspect 14/8185/5449.pbf\n  curl -s http://localhost:8080/maps/osm/14/8185/5449.pbf | tegola tile inspect -",
+	Args:    cobra.ExactArgs(1),
+	RunE:    inspectCommand,
+}
+
+func init() {
+	InspectCmd.Flags().StringVarP(&inspectFormat, "format", "", FormatText, "output format: text or json")
+}
+
+// tileReport describes a decoded MVT tile
+type tileReport struct {
+	Map  string `json:"map,omitempty"`
+	Tile string `json:"tile,omitempty"`
+	// Bytes is the size of the tile as read or rendered, and Gzipped whether it's compressed
+	Bytes   int  `json:"bytes"`
+	Gzipped bool `json:"gzipped"`
+	// RenderTime is the time to render the tile, when rendered
+	RenderTime time.Duration `json:"render_time,omitempty"`
+	// QueryTime is the time spent in the MVT provider of a rendered tile, which queries
+	// all the layers at once
+	QueryTime time.Duration `json:"query_time,omitempty"`
+	Layers    []layerReport `json:"layers"`
+}
+
+// layerReport describes a layer of a decoded MVT tile
+type layerReport struct {
+	Name     string `json:"name"`
+	Version  uint32 `json:"version"`
+	Extent   uint32 `json:"extent"`
+	Features int    `json:"features"`
+	// Keys are the attribute keys of the layer, sorted
+	Keys []string `json:"keys"`
+	// GeometryTypes counts the features by geometry type
+	GeometryTypes map[string]int `json:"geometry_types"`
+	// the following are set for rendered tiles
+	QueryTime time.Duration `json:"query_time,omitempty"`
+	// ProviderFeatures is the number of features returned by the provider, before empty
+	// geometries are dropped
+	ProviderFeatures int    `json:"provider_features,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// inspectTile decodes an MVT tile, gzipped or not
+func inspectTile(b []byte) (tileReport, error) {
+	report := tileReport{Bytes: len(b)}
+
+	raw := b
+	// gzip magic number
+	if len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b {
+		report.Gzipped = true
+
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return report, err
+		}
+		if raw, err = io.ReadAll(r); err != nil {
+			return report, fmt.Errorf("error decompressing tile: %w", err)
+		}
+	}
+
+	var tile vectorTile.Tile
+	if err := proto.Unmarshal(raw, &tile); err != nil {
+		return report, fmt.Errorf("error decoding tile: %w", err)
+	}
+
+	report.Layers = make([]layerReport, 0, len(tile.Layers))
+	for _, l := range tile.Layers {
+		lr := layerReport{
+			Name:          l.GetName(),
+			Version:       l.GetVersion(),
+			Extent:        l.GetExtent(),
+			Features:      len(l.Features),
+			Keys:          append([]string{}, l.Keys...),
+			GeometryTypes: map[string]int{},
+		}
+		sort.Strings(lr.Keys)
+		for _, f := range l.Features {
+			lr.GeometryTypes[strings.ToLower(f.GetType().String())]++
+		}
+		report.Layers = append(report.Layers, lr)
+	}
+
+	return report, nil
+}
+
+func inspectCommand(_ *cobra.Command, args []string) error {
+	var (
+		b   []byte
+		err error
+	)
+	if args[0] == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := inspectTile(b)
+	if err != nil {
+		return err
+	}
+	return writeReport(os.Stdout, inspectFormat, report)
+}
+
+// writeReport writes the report as a table for the text format, or as JSON
+func writeReport(w io.Writer, format string, r tileReport) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatText:
+	default:
+		return fmt.Errorf("invalid value for format (%v). expecting %v or %v", format, FormatText, FormatJSON)
+	}
+
+	if r.Map != "" {
+		fmt.Fprintf(w, "map: %v tile: %v\n", r.Map, r.Tile)
+	}
+	fmt.Fprintf(w, "bytes: %v gzipped: %v", r.Bytes, r.Gzipped)
+	if r.RenderTime > 0 {
+		fmt.Fprintf(w, " render time: %v", r.RenderTime)
+	}
+	if r.QueryTime > 0 {
+		fmt.Fprintf(w, " query time: %v", r.QueryTime)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "layer\tfeatures\tgeometry types\tkeys\tquery time\t\n")
+	for _, l := range r.Layers {
+		types := make([]string, 0, len(l.GeometryTypes))
+		for t, n := range l.GeometryTypes {
+			types = append(types, fmt.Sprintf("%v:%d", t, n))
+		}
+		sort.Strings(types)
+
+		queryTime := "-"
+		if l.QueryTime > 0 {
+			queryTime = l.QueryTime.String()
+		}
+		fmt.Fprintf(tw, "%v\t%d\t%v\t%v\t%v\t\n", l.Name, l.Features, strings.Join(types, " "), strings.Join(l.Keys, ","), queryTime)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, l := range r.Layers {
+		if l.Error != "" {
+			fmt.Fprintf(w, "layer (%v) error: %v\n", l.Name, l.Error)
+		}
+	}
+	return nil
+}
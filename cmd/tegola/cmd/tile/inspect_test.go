@@ -0,0 +1,121 @@
+package tile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-spatial/geom/slippy"
+)
+
+func TestInspectTile(t *testing.T) {
+	type tcase struct {
+		gzipped bool
+		expErr  bool
+		data    []byte
+	}
+
+	tile := slippy.Tile{Z: 2, X: 1, Y: 1}
+	gzipped, err := testMap().FilterLayersByZoom(tile.Z).Encode(context.Background(), tile, nil)
+	if err != nil {
+		t.Fatalf("encode, expected nil got %v", err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gzipped))
+	if err != nil {
+		t.Fatalf("gzip reader, expected nil got %v", err)
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompress, expected nil got %v", err)
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			report, err := inspectTile(tc.data)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("inspect, expected an error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("inspect, expected nil got %v", err)
+			}
+			if report.Gzipped != tc.gzipped || report.Bytes != len(tc.data) {
+				t.Errorf("gzipped and bytes, expected %v %v got %v %v", tc.gzipped, len(tc.data), report.Gzipped, report.Bytes)
+			}
+			if len(report.Layers) != 1 {
+				t.Fatalf("layers, expected 1 got %+v", report.Layers)
+			}
+			l := report.Layers[0]
+			if l.Name != "outlines" || l.Features != 1 || l.GeometryTypes["polygon"] != 1 || strings.Join(l.Keys, ",") != "type" {
+				t.Errorf("unexpected layer %+v", l)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"gzipped": {
+			data:    gzipped,
+			gzipped: true,
+		},
+		"raw": {
+			data: raw,
+		},
+		"truncated gzip": {
+			data:   gzipped[:len(gzipped)/2],
+			expErr: true,
+		},
+		"not a tile": {
+			data:   []byte("not a tile"),
+			expErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	report := tileReport{
+		Bytes: 42,
+		Layers: []layerReport{{
+			Name:          "roads",
+			Features:      2,
+			Keys:          []string{"class", "name"},
+			GeometryTypes: map[string]int{"linestring": 2},
+			Error:         "connection refused",
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, FormatText, report); err != nil {
+		t.Fatalf("text, expected nil got %v", err)
+	}
+	for _, s := range []string{"roads", "linestring:2", "class,name", "layer (roads) error: connection refused"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("text, expected %q in %q", s, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := writeReport(&buf, FormatJSON, report); err != nil {
+		t.Fatalf("json, expected nil got %v", err)
+	}
+	var decoded tileReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode json, expected nil got %v", err)
+	}
+	if decoded.Layers[0].GeometryTypes["linestring"] != 2 {
+		t.Errorf("json, unexpected report %+v", decoded)
+	}
+
+	if err := writeReport(&buf, FormatMVT, report); err == nil {
+		t.Errorf("mvt, expected an error got nil")
+	}
+}
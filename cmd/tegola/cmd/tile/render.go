@@ -0,0 +1,291 @@
+package tile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-spatial/cobra"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+	cachecmd "github.com/go-spatial/tegola/cmd/tegola/cmd/cache"
+	gdcmd "github.com/go-spatial/tegola/internal/cmd"
+	"github.com/go-spatial/tegola/observability"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// flag parameters
+var (
+	renderMap    string
+	renderZ      uint
+	renderX      uint
+	renderY      uint
+	renderLayer  string
+	renderParams []string
+	// renderOutput is the file the MVT tile is written to
+	renderOutput string
+)
+
+var RenderCmd = &cobra.Command{
+	Use:     "render",
+	Short:   "render a single tile and print it, or report its layers with the provider query timings",
+	Example: "tegola tile render --map osm --z 14 --x 8185 --y 5449 --format text\n  tegola tile render --map osm --z 3 --x 1 --y 2 --layer roads --param year=2020 > tile.mvt",
+	PreRunE: renderValidate,
+	RunE:    renderCommand,
+}
+
+func init() {
+	RenderCmd.Flags().StringVarP(&renderMap, "map", "", "", "map name as defined in the config")
+	RenderCmd.Flags().UintVarP(&renderZ, "z", "", 0, "zoom of the tile")
+	RenderCmd.Flags().UintVarP(&renderX, "x", "", 0, "column of the tile")
+	RenderCmd.Flags().UintVarP(&renderY, "y", "", 0, "row of the tile")
+	RenderCmd.Flags().StringVarP(&renderLayer, "layer", "", "", "render the tile of a single layer")
+	RenderCmd.Flags().StringArrayVarP(&renderParams, "param", "", nil, "query parameter value of the map, in the format name=value. can be repeated")
+	RenderCmd.Flags().StringVarP(&renderFormat, "format", "", FormatMVT, "output format: mvt for the uncompressed tile, text or json for a report of the tile")
+	RenderCmd.Flags().StringVarP(&renderOutput, "output", "o", "-", "file to write the mvt tile to. use - for stdout")
+}
+
+func renderValidate(_ *cobra.Command, _ []string) error {
+	switch {
+	case renderMap == "":
+		return fmt.Errorf("map is required")
+	case renderZ > atlas.MaxZoom:
+		return fmt.Errorf("invalid value for z (%v). expecting at most %v", renderZ, atlas.MaxZoom)
+	case renderX >= 1<<renderZ || renderY >= 1<<renderZ:
+		return fmt.Errorf("invalid tile (%v/%v/%v). x and y are expecting to be less than %v", renderZ, renderX, renderY, 1<<renderZ)
+	}
+
+	switch renderFormat {
+	case FormatMVT, FormatText, FormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("invalid value for format (%v). expecting %v, %v or %v", renderFormat, FormatMVT, FormatText, FormatJSON)
+	}
+}
+
+func renderCommand(_ *cobra.Command, _ []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer gdcmd.New().Complete()
+	gdcmd.OnComplete(provider.Cleanup)
+	gdcmd.OnComplete(observability.Cleanup)
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gdcmd.Cancelled():
+			cancel()
+		}
+	}()
+
+	m, err := atlas.GetMap(renderMap)
+	if err != nil {
+		return err
+	}
+	values, err := cachecmd.ParseParamFlags(renderParams)
+	if err != nil {
+		return err
+	}
+
+	tile := slippy.Tile{Z: slippy.Zoom(renderZ), X: renderX, Y: renderY}
+	b, report, err := renderTile(ctx, m, tile, renderLayer, values)
+	if err != nil {
+		return err
+	}
+
+	if renderFormat != FormatMVT {
+		return writeReport(os.Stdout, renderFormat, report)
+	}
+
+	// the encoded tile is gzipped
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	if renderOutput == "-" {
+		_, err = io.Copy(os.Stdout, r)
+		return err
+	}
+	f, err := os.Create(renderOutput)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// renderTile renders the tile of the map, or of a single layer when layer is set, and
+// reports its layers along with the time spent querying the providers. The rendered
+// tile is gzipped.
+func renderTile(ctx context.Context, m atlas.Map, tile slippy.Tile, layer string, values map[string]string) ([]byte, tileReport, error) {
+	if layer != "" {
+		if m = m.FilterLayersByName(layer); len(m.Layers) == 0 {
+			return nil, tileReport{}, fmt.Errorf("map (%v) has no layer (%v)", m.Name, layer)
+		}
+	}
+	m = m.FilterLayersByZoom(tile.Z)
+
+	params, err := m.ParamValues(values)
+	if err != nil {
+		return nil, tileReport{}, err
+	}
+
+	timings := instrument(&m)
+
+	start := time.Now()
+	b, err := m.Encode(ctx, tile, params)
+	if err != nil {
+		return nil, tileReport{}, err
+	}
+	renderTime := time.Since(start)
+
+	report, err := inspectTile(b)
+	if err != nil {
+		return nil, tileReport{}, err
+	}
+	report.Map = m.Name
+	report.Tile = fmt.Sprintf("%v/%v/%v", tile.Z, tile.X, tile.Y)
+	report.RenderTime = renderTime
+	if timings.mvt != nil {
+		report.QueryTime = timings.mvt.query
+	}
+
+	for _, name := range timings.names {
+		lt := timings.layers[name]
+
+		i := 0
+		for i < len(report.Layers) && report.Layers[i].Name != name {
+			i++
+		}
+		if i == len(report.Layers) {
+			// layers without features or which failed are not encoded
+			report.Layers = append(report.Layers, layerReport{Name: name, GeometryTypes: map[string]int{}, Keys: []string{}})
+		}
+
+		report.Layers[i].QueryTime = lt.query
+		report.Layers[i].ProviderFeatures = lt.features
+		if lt.err != nil {
+			report.Layers[i].Error = lt.err.Error()
+		}
+	}
+
+	return b, report, nil
+}
+
+// layerTiming measures the provider queries of a layer
+type layerTiming struct {
+	// query is the time spent in the provider, excluding the encoding of the features
+	query    time.Duration
+	features int
+	err      error
+}
+
+// tileTimings are the provider timings of the layers of a tile by MVT layer name. Layers
+// sharing a name are summed.
+type tileTimings struct {
+	sync.Mutex
+	// names are the layer names in the order of the map layers
+	names  []string
+	layers map[string]*layerTiming
+	// mvt is the timing of the MVT providers of the map, which query all their layers at once
+	mvt *layerTiming
+}
+
+// instrument replaces the providers of the map layers, or the MVT provider of the map,
+// with ones timing their queries. The MVT providers of the layers of composite maps
+// share the MVT timing.
+func instrument(m *atlas.Map) *tileTimings {
+	timings := &tileTimings{layers: map[string]*layerTiming{}}
+
+	add := func(name string) *layerTiming {
+		if lt, ok := timings.layers[name]; ok {
+			return lt
+		}
+		lt := &layerTiming{}
+		timings.layers[name] = lt
+		timings.names = append(timings.names, name)
+		return lt
+	}
+
+	if m.HasMVTProvider() {
+		timings.mvt = &layerTiming{}
+		m.SetMVTProvider(m.MVTProviderName(), timedMVTTiler{MVTTiler: m.MVTProvider(), timings: timings, timing: timings.mvt})
+		return timings
+	}
+
+	// the layers are shared with the registered map
+	layers := make([]atlas.Layer, len(m.Layers))
+	copy(layers, m.Layers)
+	for i := range layers {
+		if layers[i].MVTProvider != nil {
+			if timings.mvt == nil {
+				timings.mvt = &layerTiming{}
+			}
+			layers[i].MVTProvider = timedMVTTiler{MVTTiler: layers[i].MVTProvider, timings: timings, timing: timings.mvt}
+			continue
+		}
+		layers[i].Provider = timedTiler{Tiler: layers[i].Provider, timings: timings, timing: add(layers[i].MVTName())}
+	}
+	m.Layers = layers
+
+	return timings
+}
+
+// timedTiler times the TileFeatures queries of a layer
+type timedTiler struct {
+	provider.Tiler
+	timings *tileTimings
+	timing  *layerTiming
+}
+
+func (t timedTiler) TileFeatures(ctx context.Context, layer string, tile provider.Tile, params provider.Params, fn func(f *provider.Feature) error) error {
+	var (
+		features int
+		encoding time.Duration
+		start    = time.Now()
+	)
+	err := t.Tiler.TileFeatures(ctx, layer, tile, params, func(f *provider.Feature) error {
+		features++
+		s := time.Now()
+		defer func() { encoding += time.Since(s) }()
+		return fn(f)
+	})
+	query := time.Since(start) - encoding
+
+	t.timings.Lock()
+	defer t.timings.Unlock()
+	t.timing.query += query
+	t.timing.features += features
+	if err != nil && t.timing.err == nil {
+		t.timing.err = err
+	}
+	return err
+}
+
+// timedMVTTiler times the MVTForLayers queries of a map
+type timedMVTTiler struct {
+	provider.MVTTiler
+	timings *tileTimings
+	timing  *layerTiming
+}
+
+func (t timedMVTTiler) MVTForLayers(ctx context.Context, tile provider.Tile, params provider.Params, layers []provider.Layer) ([]byte, error) {
+	start := time.Now()
+	b, err := t.MVTTiler.MVTForLayers(ctx, tile, params, layers)
+
+	t.timings.Lock()
+	defer t.timings.Unlock()
+	t.timing.query += time.Since(start)
+	if err != nil && t.timing.err == nil {
+		t.timing.err = err
+	}
+	return b, err
+}
@@ -0,0 +1,137 @@
+package tile
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/slippy"
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/provider"
+	"github.com/go-spatial/tegola/provider/test"
+)
+
+// failingTiler is a provider whose queries fail
+type failingTiler struct {
+	*test.TileProvider
+}
+
+func (failingTiler) TileFeatures(context.Context, string, provider.Tile, provider.Params, func(*provider.Feature) error) error {
+	return errors.New("connection refused")
+}
+
+func testMap() atlas.Map {
+	m := atlas.NewWebMercatorMap("osm")
+	m.Layers = []atlas.Layer{
+		{
+			Name:              "outlines",
+			ProviderLayerName: "test-layer",
+			MinZoom:           0,
+			MaxZoom:           20,
+			Provider:          &test.TileProvider{},
+			GeomType:          geom.Polygon{},
+		},
+		{
+			Name:              "roads",
+			ProviderLayerName: "test-layer",
+			MinZoom:           0,
+			MaxZoom:           20,
+			Provider:          failingTiler{&test.TileProvider{}},
+			GeomType:          geom.Polygon{},
+		},
+		{
+			Name:              "buildings",
+			ProviderLayerName: "test-layer",
+			MinZoom:           10,
+			MaxZoom:           20,
+			Provider:          &test.TileProvider{},
+			GeomType:          geom.Polygon{},
+		},
+	}
+	return m
+}
+
+func TestRenderTile(t *testing.T) {
+	type tcase struct {
+		layer string
+		// layers are the expected layers of the report, by name with their features
+		layers map[string]int
+		// errs are the layers expected to report an error
+		errs   []string
+		expErr bool
+	}
+
+	ctx := context.Background()
+	tile := slippy.Tile{Z: 2, X: 1, Y: 1}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			m := testMap()
+			b, report, err := renderTile(ctx, m, tile, tc.layer, nil)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("render, expected an error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("render, expected nil got %v", err)
+			}
+			if len(b) == 0 {
+				t.Errorf("tile, expected bytes got none")
+			}
+			if report.Map != "osm" || report.Tile != "2/1/1" || !report.Gzipped {
+				t.Errorf("unexpected report %+v", report)
+			}
+
+			if len(report.Layers) != len(tc.layers) {
+				t.Fatalf("layers, expected %v got %+v", len(tc.layers), report.Layers)
+			}
+			for _, l := range report.Layers {
+				features, ok := tc.layers[l.Name]
+				if !ok {
+					t.Errorf("unexpected layer %v", l.Name)
+					continue
+				}
+				if l.Features != features {
+					t.Errorf("layer %v features, expected %v got %v", l.Name, features, l.Features)
+				}
+				if l.QueryTime <= 0 {
+					t.Errorf("layer %v query time, expected to be set", l.Name)
+				}
+			}
+			for _, name := range tc.errs {
+				for _, l := range report.Layers {
+					if l.Name == name && l.Error == "" {
+						t.Errorf("layer %v error, expected an error got none", name)
+					}
+				}
+			}
+
+			// the layers of the registered map are not instrumented
+			if _, ok := m.Layers[0].Provider.(timedTiler); ok {
+				t.Errorf("map layers, expected the original providers")
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"map": {
+			layers: map[string]int{"outlines": 1, "roads": 0},
+			errs:   []string{"roads"},
+		},
+		"layer": {
+			layer:  "outlines",
+			layers: map[string]int{"outlines": 1},
+		},
+		"missing layer": {
+			layer:  "rivers",
+			expErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
@@ -0,0 +1,31 @@
+// Package tile implements the tile command, which renders a single tile of a map or
+// decodes a tile to debug its layers and the provider queries behind them
+package tile
+
+import (
+	"github.com/go-spatial/cobra"
+)
+
+// output formats
+const (
+	// FormatMVT writes the uncompressed MVT tile
+	FormatMVT  = "mvt"
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// flag parameters
+var (
+	renderFormat  string
+	inspectFormat string
+)
+
+var Cmd = &cobra.Command{
+	Use:   "tile",
+	Short: "render or inspect a single tile",
+}
+
+func init() {
+	Cmd.AddCommand(RenderCmd)
+	Cmd.AddCommand(InspectCmd)
+}
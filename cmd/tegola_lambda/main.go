@@ -73,7 +73,7 @@ func init() {
 	}
 
 	// register the maps
-	if err = register.Maps(nil, conf.Maps, providers); err != nil {
+	if err = register.Maps(nil, conf.Maps, providers, provArr); err != nil {
 		log.Error(err)
 		os.Exit(1)
 	}
@@ -89,6 +89,20 @@ func init() {
 		if cache != nil {
 			atlas.SetCache(cache)
 		}
+
+		versioning, err := register.CacheKeyVersioning(conf.Cache)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		atlas.SetCacheKeyVersioning(versioning)
+
+		sweepUnversioned, err := register.CacheSweepUnversioned(conf.Cache)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		atlas.SetCacheSweepUnversioned(sweepUnversioned)
 	}
 
 	// set our server version
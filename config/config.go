@@ -58,6 +58,7 @@ type Config struct {
 	Webserver        Webserver `toml:"webserver"`
 	Cache            env.Dict  `toml:"cache"`
 	Observer         env.Dict  `toml:"observer"`
+	TileStats        env.Dict  `toml:"tile_stats"`
 	AppConfigSource  env.Dict  `toml:"app_config_source"`
 	// Map of providers.
 	//  all providers must have at least two entries.
@@ -169,9 +170,9 @@ func (c *Config) Validate() error {
 		drivers[name] = int(provider.TypeMvt)
 		knownTypes = append(knownTypes, name)
 	}
-	// mvtproviders maps a known provider name to whether that provider is
-	// an mvt provider or not.
-	mvtproviders := make(map[string]bool, len(c.Providers))
+	// providerNames are the names of the known providers. Maps can mix the
+	// layers of any of them, including mvt providers.
+	providerNames := make(map[string]struct{}, len(c.Providers))
 	for i, prvd := range c.Providers {
 		name, _ := prvd.String("name", nil)
 		if name == "" {
@@ -182,18 +183,17 @@ func (c *Config) Validate() error {
 			return ErrProviderTypeRequired{Pos: i}
 		}
 		// Check to see if the name has already been seen before.
-		if _, ok := mvtproviders[name]; ok {
+		if _, ok := providerNames[name]; ok {
 			return ErrProviderNameDuplicate{Pos: i}
 		}
-		drv, ok := drivers[typ]
-		if !ok {
+		if _, ok := drivers[typ]; !ok {
 			return ErrUnknownProviderType{
 				Name:           name,
 				Type:           typ,
 				KnownProviders: knownTypes,
 			}
 		}
-		mvtproviders[name] = drv == int(provider.TypeMvt)
+		providerNames[name] = struct{}{}
 	}
 	// check for map layer name / zoom collisions
 	// map of layers to providers
@@ -215,51 +215,19 @@ func (c *Config) Validate() error {
 			mapLayers[string(m.Name)] = map[string]provider.MapLayer{}
 		}
 
-		// Set current provider to empty, for MVT providers
-		// we can only have the same provider for all layers.
-		// This allow us to track what the first found provider
-		// is.
-		currentProvider := ""
-		isMVTProvider := false
 		for layerKey, l := range m.Layers {
 			pname, _, err := l.ProviderLayerName()
 			if err != nil {
 				return err
 			}
 
-			if currentProvider == "" {
-				// This is the first provider we found.
-				// For MVTProviders all others need to be the same, so store it
-				// so we can check later
-				currentProvider = pname
-			}
-
-			isMvt, doesExists := mvtproviders[pname]
-			if !doesExists {
+			if _, ok := providerNames[pname]; !ok {
 				return ErrInvalidProviderForMap{
 					MapName:      string(m.Name),
 					ProviderName: pname,
 				}
 			}
 
-			// check to see if any of the prior provider or this one is
-			// an mvt provider. If it is, then the mvtProvider check needs
-			// to be done
-			isMVTProvider = isMVTProvider || isMvt
-
-			// only need to do this check if we are dealing with MVTProviders
-			if isMVTProvider && pname != currentProvider {
-				// for mvt_providers we can only have the same provider
-				// for all layers
-				// check to see
-				if mvtproviders[pname] || isMVTProvider {
-					return ErrMVTDifferentProviders{
-						Original: currentProvider,
-						Current:  pname,
-					}
-				}
-			}
-
 			name, err := l.GetName()
 			if err != nil {
 				return err
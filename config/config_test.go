@@ -1070,10 +1070,6 @@ func TestValidate(t *testing.T) {
 			},
 		},
 		"mvt_provider comingle": {
-			expectedErr: config.ErrMVTDifferentProviders{
-				Original: "provider1",
-				Current:  "stdprovider1",
-			},
 			config: config.Config{
 				Providers: []env.Dict{
 					{
@@ -1094,7 +1090,7 @@ func TestValidate(t *testing.T) {
 								ProviderLayer: "provider1.water_default_z",
 							},
 							{
-								ProviderLayer: "stdprovider1.water_default_z",
+								ProviderLayer: "stdprovider1.land_default_z",
 							},
 						},
 					},
@@ -1102,10 +1098,6 @@ func TestValidate(t *testing.T) {
 			},
 		},
 		"mvt_provider comingle; flip": {
-			expectedErr: config.ErrMVTDifferentProviders{
-				Original: "stdprovider1",
-				Current:  "provider1",
-			},
 			config: config.Config{
 				Providers: []env.Dict{
 					{
@@ -1123,7 +1115,7 @@ func TestValidate(t *testing.T) {
 						Attribution: "Test Attribution",
 						Layers: []provider.MapLayer{
 							{
-								ProviderLayer: "stdprovider1.water_default_z",
+								ProviderLayer: "stdprovider1.land_default_z",
 							},
 							{
 								ProviderLayer: "provider1.water_default_z",
@@ -1133,6 +1125,34 @@ func TestValidate(t *testing.T) {
 				},
 			},
 		},
+		"mvt_provider comingle; two mvt providers": {
+			config: config.Config{
+				Providers: []env.Dict{
+					{
+						"name": "provider1",
+						"type": "mvt_test",
+					},
+					{
+						"name": "provider2",
+						"type": "mvt_test",
+					},
+				},
+				Maps: []provider.Map{
+					{
+						Name:        "comingle",
+						Attribution: "Test Attribution",
+						Layers: []provider.MapLayer{
+							{
+								ProviderLayer: "provider1.water_default_z",
+							},
+							{
+								ProviderLayer: "provider2.land_default_z",
+							},
+						},
+					},
+				},
+			},
+		},
 		"reserved token name": {
 			config: config.Config{
 				Maps: []provider.Map{
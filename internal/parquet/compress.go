@@ -0,0 +1,136 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compression codecs
+const (
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+	codecZstd         = 6
+	codecLZ4Raw       = 7
+)
+
+// maxPageSize bounds the uncompressed size of a page
+const maxPageSize = 1 << 30
+
+// zstdDecoder decodes the zstd pages, DecodeAll can be called concurrently
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(maxPageSize))
+
+// decompress decompresses a page of the codec to its uncompressed size
+func decompress(codec int32, b []byte, size int) ([]byte, error) {
+	if size > maxPageSize {
+		return nil, fmt.Errorf("page of %v bytes exceeds the max page size", size)
+	}
+
+	var (
+		out []byte
+		err error
+	)
+	switch codec {
+	case codecUncompressed:
+		out = b
+	case codecSnappy:
+		out, err = snappy.Decode(make([]byte, size), b)
+	case codecGzip:
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(b)); err != nil {
+			return nil, err
+		}
+		out = make([]byte, size)
+		_, err = io.ReadFull(zr, out)
+	case codecZstd:
+		out, err = zstdDecoder.DecodeAll(b, make([]byte, 0, size))
+	case codecLZ4Raw:
+		out, err = decodeLZ4Block(b, size)
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %v", codec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != size {
+		return nil, fmt.Errorf("page of %v bytes, expected %v", len(out), size)
+	}
+	return out, nil
+}
+
+var errLZ4 = errors.New("invalid lz4 block")
+
+// decodeLZ4Block decodes an LZ4 block, which is a sequence of literals each followed by
+// a match copied from the decoded bytes, except for the last
+func decodeLZ4Block(b []byte, size int) ([]byte, error) {
+	var (
+		out = make([]byte, 0, size)
+		pos int
+	)
+	length := func(l int) (int, error) {
+		if l != 15 {
+			return l, nil
+		}
+		for {
+			if pos >= len(b) {
+				return 0, errLZ4
+			}
+			c := b[pos]
+			pos++
+			l += int(c)
+			if l > size {
+				return 0, errLZ4
+			}
+			if c != 255 {
+				return l, nil
+			}
+		}
+	}
+
+	for pos < len(b) {
+		token := b[pos]
+		pos++
+
+		literals, err := length(int(token >> 4))
+		if err != nil {
+			return nil, err
+		}
+		if pos+literals > len(b) || len(out)+literals > size {
+			return nil, errLZ4
+		}
+		out = append(out, b[pos:pos+literals]...)
+		pos += literals
+		if pos == len(b) {
+			break
+		}
+
+		if pos+2 > len(b) {
+			return nil, errLZ4
+		}
+		offset := int(b[pos]) | int(b[pos+1])<<8
+		pos += 2
+		if offset == 0 || offset > len(out) {
+			return nil, errLZ4
+		}
+		match, err := length(int(token & 0x0f))
+		if err != nil {
+			return nil, err
+		}
+		match += 4
+		if len(out)+match > size {
+			return nil, errLZ4
+		}
+		// the match may overlap the bytes it copies
+		start := len(out) - offset
+		for i := 0; i < match; i++ {
+			out = append(out, out[start+i])
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,224 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errEncodingBounds = errors.New("truncated encoded values")
+
+// bitsAt reads width bits at the bit position pos of b, least significant bit first,
+// which is the bit packing of parquet
+func bitsAt(b []byte, pos, width uint) uint64 {
+	var v uint64
+	for read := uint(0); read < width; {
+		shift := pos % 8
+		n := min(8-shift, width-read)
+		v |= uint64((b[pos/8]>>shift)&(1<<n-1)) << read
+		read += n
+		pos += n
+	}
+	return v
+}
+
+// decodeHybrid decodes n values of the RLE / bit packing hybrid encoding of levels,
+// dictionary indices and booleans
+func decodeHybrid(b []byte, width int, n int) ([]uint32, error) {
+	if width < 0 || width > 32 {
+		return nil, fmt.Errorf("invalid bit width %v", width)
+	}
+
+	var (
+		out       = make([]uint32, 0, n)
+		byteWidth = (width + 7) / 8
+		pos       int
+	)
+	for len(out) < n {
+		h, k := binary.Uvarint(b[pos:])
+		if k <= 0 {
+			return nil, errEncodingBounds
+		}
+		pos += k
+
+		if h&1 == 0 {
+			// a run of a repeated value
+			count := h >> 1
+			if pos+byteWidth > len(b) {
+				return nil, errEncodingBounds
+			}
+			var v uint32
+			for i := 0; i < byteWidth; i++ {
+				v |= uint32(b[pos+i]) << (8 * i)
+			}
+			pos += byteWidth
+			for i := uint64(0); i < count && len(out) < n; i++ {
+				out = append(out, v)
+			}
+			continue
+		}
+
+		// groups of 8 bit packed values
+		groups := int(h >> 1)
+		length := groups * width
+		if groups > len(b) || pos+length > len(b) {
+			// the last group may be truncated
+			length = len(b) - pos
+		}
+		count := groups * 8
+		if width > 0 {
+			count = min(count, length*8/width)
+		}
+		for i := 0; i < count && len(out) < n; i++ {
+			out = append(out, uint32(bitsAt(b[pos:], uint(i*width), uint(width))))
+		}
+		pos += length
+	}
+	return out, nil
+}
+
+// decodeDeltaBinaryPacked decodes the delta encoded integers at the start of b, and
+// returns the number of bytes read. At most max values are decoded.
+func decodeDeltaBinaryPacked(b []byte, max int) ([]int64, int, error) {
+	pos := 0
+	uvarint := func() (uint64, error) {
+		v, k := binary.Uvarint(b[pos:])
+		if k <= 0 {
+			return 0, errEncodingBounds
+		}
+		pos += k
+		return v, nil
+	}
+	varint := func() (int64, error) {
+		v, err := uvarint()
+		return int64(v>>1) ^ -int64(v&1), err
+	}
+
+	blockSize, err := uvarint()
+	if err != nil {
+		return nil, 0, err
+	}
+	miniblocks, err := uvarint()
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := uvarint()
+	if err != nil {
+		return nil, 0, err
+	}
+	first, err := varint()
+	if err != nil {
+		return nil, 0, err
+	}
+	if blockSize == 0 || miniblocks == 0 || blockSize%miniblocks != 0 || (blockSize/miniblocks)%8 != 0 {
+		return nil, 0, fmt.Errorf("invalid delta block of %v values in %v miniblocks", blockSize, miniblocks)
+	}
+	if total > uint64(max) {
+		return nil, 0, fmt.Errorf("%v delta encoded values exceed the %v values of the page", total, max)
+	}
+	if total == 0 {
+		return nil, pos, nil
+	}
+
+	var (
+		values   = make([]int64, 1, total)
+		perBlock = int(blockSize / miniblocks)
+		last     = first
+	)
+	values[0] = first
+	for uint64(len(values)) < total {
+		minDelta, err := varint()
+		if err != nil {
+			return nil, 0, err
+		}
+		if pos+int(miniblocks) > len(b) {
+			return nil, 0, errEncodingBounds
+		}
+		widths := b[pos : pos+int(miniblocks)]
+		pos += int(miniblocks)
+
+		for _, w := range widths {
+			if uint64(len(values)) >= total {
+				break
+			}
+			if w > 64 {
+				return nil, 0, fmt.Errorf("invalid bit width %v", w)
+			}
+			length := perBlock * int(w) / 8
+			if pos+length > len(b) {
+				return nil, 0, errEncodingBounds
+			}
+			for i := 0; i < perBlock && uint64(len(values)) < total; i++ {
+				// the values wrap around on overflow
+				last += minDelta + int64(bitsAt(b[pos:], uint(i)*uint(w), uint(w)))
+				values = append(values, last)
+			}
+			pos += length
+		}
+	}
+	return values, pos, nil
+}
+
+// decodeDeltaLengthByteArray decodes the delta encoded lengths followed by the
+// concatenated byte arrays
+func decodeDeltaLengthByteArray(b []byte, max int) ([][]byte, error) {
+	lengths, pos, err := decodeDeltaBinaryPacked(b, max)
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(lengths))
+	for i, l := range lengths {
+		if l < 0 || int64(len(b)-pos) < l {
+			return nil, errEncodingBounds
+		}
+		values[i] = b[pos : pos+int(l)]
+		pos += int(l)
+	}
+	return values, nil
+}
+
+// decodeDeltaByteArray decodes the incremental encoding of byte arrays, the length of
+// the prefix shared with the previous value followed by the suffix
+func decodeDeltaByteArray(b []byte, max int) ([][]byte, error) {
+	prefixes, pos, err := decodeDeltaBinaryPacked(b, max)
+	if err != nil {
+		return nil, err
+	}
+	suffixes, err := decodeDeltaLengthByteArray(b[pos:], max)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefixes) != len(suffixes) {
+		return nil, fmt.Errorf("%v prefixes for %v suffixes", len(prefixes), len(suffixes))
+	}
+
+	var (
+		values = make([][]byte, len(suffixes))
+		prev   []byte
+	)
+	for i, p := range prefixes {
+		if p < 0 || p > int64(len(prev)) {
+			return nil, fmt.Errorf("invalid prefix length %v", p)
+		}
+		v := make([]byte, int(p)+len(suffixes[i]))
+		copy(v, prev[:p])
+		copy(v[p:], suffixes[i])
+		values[i], prev = v, v
+	}
+	return values, nil
+}
+
+// decodeByteStreamSplit joins the bytes of n values of width bytes, which are split in
+// a stream for each byte
+func decodeByteStreamSplit(b []byte, width, n int) ([]byte, error) {
+	if width <= 0 || len(b) < width*n {
+		return nil, errEncodingBounds
+	}
+	out := make([]byte, width*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < width; j++ {
+			out[i*width+j] = b[j*n+i]
+		}
+	}
+	return out, nil
+}
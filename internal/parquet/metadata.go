@@ -0,0 +1,492 @@
+package parquet
+
+import "fmt"
+
+// Type is the physical type of a column
+type Type int32
+
+const (
+	Boolean           Type = 0
+	Int32             Type = 1
+	Int64             Type = 2
+	Int96             Type = 3
+	Float             Type = 4
+	Double            Type = 5
+	ByteArray         Type = 6
+	FixedLenByteArray Type = 7
+)
+
+func (t Type) String() string {
+	switch t {
+	case Boolean:
+		return "BOOLEAN"
+	case Int32:
+		return "INT32"
+	case Int64:
+		return "INT64"
+	case Int96:
+		return "INT96"
+	case Float:
+		return "FLOAT"
+	case Double:
+		return "DOUBLE"
+	case ByteArray:
+		return "BYTE_ARRAY"
+	case FixedLenByteArray:
+		return "FIXED_LEN_BYTE_ARRAY"
+	default:
+		return fmt.Sprintf("Type(%d)", int32(t))
+	}
+}
+
+// repetition types of the schema
+const (
+	required = 0
+	optional = 1
+	repeated = 2
+)
+
+// converted types, the legacy annotations of the schema
+const (
+	convertedUTF8            = 0
+	convertedEnum            = 4
+	convertedDecimal         = 5
+	convertedDate            = 6
+	convertedTimestampMillis = 9
+	convertedTimestampMicros = 10
+	convertedUint8           = 11
+	convertedUint64          = 14
+	convertedJSON            = 19
+)
+
+// logical types, the field ids of the LogicalType union of the schema
+const (
+	logicalString    = 1
+	logicalEnum      = 4
+	logicalDecimal   = 5
+	logicalDate      = 6
+	logicalTimestamp = 8
+	logicalInteger   = 10
+	logicalJSON      = 12
+	logicalUUID      = 14
+	logicalFloat16   = 15
+)
+
+// time units of timestamps, the field ids of the TimeUnit union
+const (
+	unitMillis = 1
+	unitMicros = 2
+	unitNanos  = 3
+)
+
+// page types
+const (
+	pageData       = 0
+	pageIndex      = 1
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+// encodings
+const (
+	encodingPlain                = 0
+	encodingPlainDictionary      = 2
+	encodingRLE                  = 3
+	encodingBitPacked            = 4
+	encodingDeltaBinaryPacked    = 5
+	encodingDeltaLengthByteArray = 6
+	encodingDeltaByteArray       = 7
+	encodingRLEDictionary        = 8
+	encodingByteStreamSplit      = 9
+)
+
+// schemaElement is an element of the flattened schema tree
+type schemaElement struct {
+	typ         Type
+	hasType     bool
+	typeLength  int32
+	repetition  int32
+	name        string
+	numChildren int32
+	converted   int32
+	scale       int32
+	logical     logicalType
+}
+
+// logicalType is the annotation of a column
+type logicalType struct {
+	// kind is the field id of the set member of the union, 0 when not set
+	kind  int16
+	scale int32
+	// unit is the time unit of timestamps
+	unit int16
+	// signed is false for unsigned integers
+	signed bool
+}
+
+type fileMetaData struct {
+	schema    []schemaElement
+	numRows   int64
+	rowGroups []rowGroup
+	keyValues map[string]string
+}
+
+type rowGroup struct {
+	columns []columnChunk
+	numRows int64
+}
+
+type columnChunk struct {
+	path                 []string
+	codec                int32
+	numValues            int64
+	totalCompressedSize  int64
+	dataPageOffset       int64
+	dictionaryPageOffset int64
+	stats                statistics
+	// geoStats is the bounding box of the geospatial statistics of GEOMETRY and
+	// GEOGRAPHY columns
+	geoStats *BBox
+}
+
+type statistics struct {
+	min, max  []byte
+	hasMinMax bool
+}
+
+// BBox is a bounding box of min x, min y, max x and max y
+type BBox [4]float64
+
+type pageHeader struct {
+	typ              int32
+	uncompressedSize int32
+	compressedSize   int32
+	numValues        int32
+	encoding         int32
+	defEncoding      int32
+	// the fields of data pages v2
+	defLength    int32
+	repLength    int32
+	isCompressed bool
+}
+
+func readFileMetaData(b []byte) (*fileMetaData, error) {
+	var (
+		m = fileMetaData{keyValues: map[string]string{}}
+		r = thriftReader{b: b}
+	)
+	err := r.structure(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 2 && typ == thriftList:
+			return r.list(func(byte) error {
+				var e schemaElement
+				err := r.schemaElement(&e)
+				m.schema = append(m.schema, e)
+				return err
+			})
+		case id == 3 && typ == thriftI64:
+			m.numRows, err = r.varint()
+		case id == 4 && typ == thriftList:
+			return r.list(func(byte) error {
+				var rg rowGroup
+				err := r.rowGroup(&rg)
+				m.rowGroups = append(m.rowGroups, rg)
+				return err
+			})
+		case id == 5 && typ == thriftList:
+			return r.list(func(byte) error {
+				k, v, err := r.keyValue()
+				m.keyValues[k] = v
+				return err
+			})
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *thriftReader) schemaElement(e *schemaElement) error {
+	e.converted = -1
+	return r.structure(func(id int16, typ byte) error {
+		var (
+			v   int32
+			err error
+		)
+		switch {
+		case id == 1 && typ == thriftI32:
+			v, err = r.i32()
+			e.typ, e.hasType = Type(v), true
+		case id == 2 && typ == thriftI32:
+			e.typeLength, err = r.i32()
+		case id == 3 && typ == thriftI32:
+			e.repetition, err = r.i32()
+		case id == 4 && typ == thriftBinary:
+			e.name, err = r.string()
+		case id == 5 && typ == thriftI32:
+			e.numChildren, err = r.i32()
+		case id == 6 && typ == thriftI32:
+			e.converted, err = r.i32()
+		case id == 7 && typ == thriftI32:
+			e.scale, err = r.i32()
+		case id == 10 && typ == thriftStruct:
+			err = r.logicalType(&e.logical)
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+}
+
+func (r *thriftReader) logicalType(l *logicalType) error {
+	return r.structure(func(id int16, typ byte) error {
+		if typ != thriftStruct {
+			return r.skip(typ)
+		}
+		l.kind = id
+		switch id {
+		case logicalDecimal:
+			return r.structure(func(id int16, typ byte) error {
+				var err error
+				if id == 1 && typ == thriftI32 {
+					l.scale, err = r.i32()
+				} else {
+					err = r.skip(typ)
+				}
+				return err
+			})
+		case logicalTimestamp:
+			return r.structure(func(id int16, typ byte) error {
+				if id != 2 || typ != thriftStruct {
+					return r.skip(typ)
+				}
+				// the time unit is a union of empty structs
+				return r.structure(func(id int16, typ byte) error {
+					l.unit = id
+					return r.skip(typ)
+				})
+			})
+		case logicalInteger:
+			return r.structure(func(id int16, typ byte) error {
+				if id == 2 && (typ == thriftTrue || typ == thriftFalse) {
+					l.signed = typ == thriftTrue
+					return nil
+				}
+				return r.skip(typ)
+			})
+		default:
+			return r.skip(typ)
+		}
+	})
+}
+
+func (r *thriftReader) keyValue() (key, value string, err error) {
+	err = r.structure(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 1 && typ == thriftBinary:
+			key, err = r.string()
+		case id == 2 && typ == thriftBinary:
+			value, err = r.string()
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+	return key, value, err
+}
+
+func (r *thriftReader) rowGroup(rg *rowGroup) error {
+	return r.structure(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 1 && typ == thriftList:
+			return r.list(func(byte) error {
+				var cc columnChunk
+				err := r.columnChunk(&cc)
+				rg.columns = append(rg.columns, cc)
+				return err
+			})
+		case id == 3 && typ == thriftI64:
+			rg.numRows, err = r.varint()
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+}
+
+func (r *thriftReader) columnChunk(cc *columnChunk) error {
+	var hasMetaData bool
+	err := r.structure(func(id int16, typ byte) error {
+		switch {
+		case id == 1 && typ == thriftBinary:
+			return fmt.Errorf("column chunks in other files are not supported")
+		case id == 3 && typ == thriftStruct:
+			hasMetaData = true
+			return r.columnMetaData(cc)
+		default:
+			return r.skip(typ)
+		}
+	})
+	if err == nil && !hasMetaData {
+		err = fmt.Errorf("column chunk without metadata")
+	}
+	return err
+}
+
+func (r *thriftReader) columnMetaData(cc *columnChunk) error {
+	return r.structure(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 3 && typ == thriftList:
+			return r.list(func(byte) error {
+				s, err := r.string()
+				cc.path = append(cc.path, s)
+				return err
+			})
+		case id == 4 && typ == thriftI32:
+			cc.codec, err = r.i32()
+		case id == 5 && typ == thriftI64:
+			cc.numValues, err = r.varint()
+		case id == 7 && typ == thriftI64:
+			cc.totalCompressedSize, err = r.varint()
+		case id == 9 && typ == thriftI64:
+			cc.dataPageOffset, err = r.varint()
+		case id == 11 && typ == thriftI64:
+			cc.dictionaryPageOffset, err = r.varint()
+		case id == 12 && typ == thriftStruct:
+			err = r.statistics(&cc.stats)
+		case id == 17 && typ == thriftStruct:
+			err = r.geospatialStatistics(cc)
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+}
+
+// statistics reads the min and max values of statistics. The min_value and max_value
+// fields are used over the deprecated min and max fields, which are only used for
+// the types ordered by the signed order of their bytes.
+func (r *thriftReader) statistics(s *statistics) error {
+	var (
+		min, max           []byte
+		minValue, maxValue []byte
+	)
+	err := r.structure(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 1 && typ == thriftBinary:
+			max, err = r.binary()
+		case id == 2 && typ == thriftBinary:
+			min, err = r.binary()
+		case id == 5 && typ == thriftBinary:
+			maxValue, err = r.binary()
+		case id == 6 && typ == thriftBinary:
+			minValue, err = r.binary()
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+	switch {
+	case minValue != nil && maxValue != nil:
+		s.min, s.max, s.hasMinMax = minValue, maxValue, true
+	case min != nil && max != nil:
+		s.min, s.max, s.hasMinMax = min, max, true
+	}
+	return err
+}
+
+// geospatialStatistics reads the bounding box of the geospatial statistics
+func (r *thriftReader) geospatialStatistics(cc *columnChunk) error {
+	return r.structure(func(id int16, typ byte) error {
+		if id != 1 || typ != thriftStruct {
+			return r.skip(typ)
+		}
+		var (
+			bbox BBox
+			set  int
+		)
+		err := r.structure(func(id int16, typ byte) error {
+			if typ != thriftDouble || id < 1 || id > 4 {
+				return r.skip(typ)
+			}
+			v, err := r.double()
+			// the fields are xmin, xmax, ymin and ymax
+			bbox[[]int{0, 2, 1, 3}[id-1]] = v
+			set++
+			return err
+		})
+		if set == 4 {
+			cc.geoStats = &bbox
+		}
+		return err
+	})
+}
+
+func readPageHeader(r *thriftReader) (*pageHeader, error) {
+	h := pageHeader{isCompressed: true}
+	err := r.structure(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 1 && typ == thriftI32:
+			h.typ, err = r.i32()
+		case id == 2 && typ == thriftI32:
+			h.uncompressedSize, err = r.i32()
+		case id == 3 && typ == thriftI32:
+			h.compressedSize, err = r.i32()
+		case (id == 5 || id == 7) && typ == thriftStruct:
+			// data page and dictionary page headers
+			err = r.structure(func(id int16, typ byte) error {
+				var err error
+				switch {
+				case id == 1 && typ == thriftI32:
+					h.numValues, err = r.i32()
+				case id == 2 && typ == thriftI32:
+					h.encoding, err = r.i32()
+				case id == 3 && typ == thriftI32:
+					h.defEncoding, err = r.i32()
+				default:
+					err = r.skip(typ)
+				}
+				return err
+			})
+		case id == 8 && typ == thriftStruct:
+			err = r.structure(func(id int16, typ byte) error {
+				var err error
+				switch {
+				case id == 1 && typ == thriftI32:
+					h.numValues, err = r.i32()
+				case id == 4 && typ == thriftI32:
+					h.encoding, err = r.i32()
+				case id == 5 && typ == thriftI32:
+					h.defLength, err = r.i32()
+				case id == 6 && typ == thriftI32:
+					h.repLength, err = r.i32()
+				case id == 7 && (typ == thriftTrue || typ == thriftFalse):
+					h.isCompressed = typ == thriftTrue
+				default:
+					err = r.skip(typ)
+				}
+				return err
+			})
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if h.compressedSize < 0 || h.uncompressedSize < 0 || h.numValues < 0 || h.defLength < 0 || h.repLength < 0 {
+		return nil, fmt.Errorf("invalid page header")
+	}
+	return &h, nil
+}
@@ -0,0 +1,689 @@
+// Package parquet reads the columns of Apache Parquet files, the storage format of
+// GeoParquet. It supports the flat and nested columns without repetition, the plain,
+// dictionary, delta and byte stream split encodings and the snappy, gzip, zstd and LZ4
+// compressions. The spec is at https://github.com/apache/parquet-format
+package parquet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const magic = "PAR1"
+
+// maxFooterLength bounds the length of the metadata of a file
+const maxFooterLength = 1 << 28
+
+// Column is a leaf column of the schema of a file
+type Column struct {
+	// Path is the path of the column in the schema, with an element for each group
+	// the column is in
+	Path []string
+	// Type is the physical type of the column
+	Type Type
+
+	typeLength int
+	maxDef     int
+	maxRep     int
+	kind       valueKind
+	// scale of decimals
+	scale int32
+	// unit of timestamps
+	unit int16
+}
+
+// Name is the path of the column joined with dots
+func (c Column) Name() string { return strings.Join(c.Path, ".") }
+
+// Repeated reports whether the column is in a list or repeated group, which is not
+// supported
+func (c Column) Repeated() bool { return c.maxRep > 0 }
+
+// valueKind is the conversion of the values of a column
+type valueKind int
+
+const (
+	kindPlain valueKind = iota
+	kindString
+	kindDecimal
+	kindDate
+	kindTimestamp
+	kindUnsigned
+	kindUUID
+	kindFloat16
+)
+
+// File is a parquet file
+type File struct {
+	r       io.ReaderAt
+	meta    *fileMetaData
+	columns []Column
+}
+
+// Open reads the metadata of the parquet file of size bytes
+func Open(r io.ReaderAt, size int64) (*File, error) {
+	if size < 12 {
+		return nil, fmt.Errorf("file of %v bytes is not a parquet file", size)
+	}
+
+	header, footer := make([]byte, 4), make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	if _, err := r.ReadAt(footer, size-8); err != nil {
+		return nil, err
+	}
+	switch string(footer[4:]) {
+	case magic:
+	case "PARE":
+		return nil, fmt.Errorf("encrypted parquet files are not supported")
+	default:
+		return nil, fmt.Errorf("not a parquet file")
+	}
+	if string(header) != magic {
+		return nil, fmt.Errorf("not a parquet file")
+	}
+
+	length := int64(binary.LittleEndian.Uint32(footer))
+	if length > maxFooterLength || length > size-12 {
+		return nil, fmt.Errorf("invalid metadata length %v", length)
+	}
+	b := make([]byte, length)
+	if _, err := r.ReadAt(b, size-8-length); err != nil {
+		return nil, err
+	}
+	meta, err := readFileMetaData(b)
+	if err != nil {
+		return nil, fmt.Errorf("reading metadata: %w", err)
+	}
+
+	f := File{r: r, meta: meta}
+	if len(meta.schema) == 0 {
+		return nil, fmt.Errorf("file without schema")
+	}
+	// the first element is the root of the schema
+	next := 1
+	if err := f.walkSchema(&next, int(meta.schema[0].numChildren), nil, 0, 0); err != nil {
+		return nil, err
+	}
+
+	for i, rg := range meta.rowGroups {
+		if len(rg.columns) != len(f.columns) {
+			return nil, fmt.Errorf("row group %v has %v columns, expected %v", i, len(rg.columns), len(f.columns))
+		}
+		for j, cc := range rg.columns {
+			if strings.Join(cc.path, ".") != f.columns[j].Name() {
+				return nil, fmt.Errorf("row group %v has column %v, expected %v", i, strings.Join(cc.path, "."), f.columns[j].Name())
+			}
+		}
+	}
+	return &f, nil
+}
+
+// walkSchema adds the leaf columns of the children of a group, which are the elements
+// of the schema following the group in depth first order
+func (f *File) walkSchema(next *int, children int, path []string, def, rep int) error {
+	for i := 0; i < children; i++ {
+		if *next >= len(f.meta.schema) {
+			return fmt.Errorf("truncated schema")
+		}
+		e := f.meta.schema[*next]
+		*next++
+
+		p := append(path[:len(path):len(path)], e.name)
+		d, r := def, rep
+		switch e.repetition {
+		case optional:
+			d++
+		case repeated:
+			d++
+			r++
+		}
+
+		if e.numChildren > 0 {
+			if len(p) > maxThriftDepth {
+				return fmt.Errorf("schema nested too deep")
+			}
+			if err := f.walkSchema(next, int(e.numChildren), p, d, r); err != nil {
+				return err
+			}
+			continue
+		}
+		if !e.hasType {
+			return fmt.Errorf("column %v without type", strings.Join(p, "."))
+		}
+
+		c := Column{
+			Path:       p,
+			Type:       e.typ,
+			typeLength: int(e.typeLength),
+			maxDef:     d,
+			maxRep:     r,
+			scale:      e.scale,
+		}
+		switch {
+		case e.logical.kind == logicalString, e.logical.kind == logicalEnum, e.logical.kind == logicalJSON,
+			e.converted == convertedUTF8, e.converted == convertedEnum, e.converted == convertedJSON:
+			c.kind = kindString
+		case e.logical.kind == logicalDecimal:
+			c.kind, c.scale = kindDecimal, e.logical.scale
+		case e.converted == convertedDecimal:
+			c.kind = kindDecimal
+		case e.logical.kind == logicalDate, e.converted == convertedDate:
+			c.kind = kindDate
+		case e.logical.kind == logicalTimestamp:
+			c.kind, c.unit = kindTimestamp, e.logical.unit
+		case e.converted == convertedTimestampMillis:
+			c.kind, c.unit = kindTimestamp, unitMillis
+		case e.converted == convertedTimestampMicros:
+			c.kind, c.unit = kindTimestamp, unitMicros
+		case e.logical.kind == logicalInteger && !e.logical.signed,
+			e.converted >= convertedUint8 && e.converted <= convertedUint64:
+			c.kind = kindUnsigned
+		case e.logical.kind == logicalUUID:
+			c.kind = kindUUID
+		case e.logical.kind == logicalFloat16:
+			c.kind = kindFloat16
+		}
+		if c.Type == FixedLenByteArray && c.typeLength <= 0 {
+			return fmt.Errorf("column %v has invalid length %v", c.Name(), c.typeLength)
+		}
+		f.columns = append(f.columns, c)
+	}
+	return nil
+}
+
+// Columns returns the leaf columns of the schema
+func (f *File) Columns() []Column { return f.columns }
+
+// Column returns the index of the column with the name, or -1
+func (f *File) Column(name string) int {
+	for i, c := range f.columns {
+		if c.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// NumRows is the number of rows of the file
+func (f *File) NumRows() int64 { return f.meta.numRows }
+
+// NumRowGroups is the number of row groups of the file
+func (f *File) NumRowGroups() int { return len(f.meta.rowGroups) }
+
+// RowGroupNumRows is the number of rows of a row group
+func (f *File) RowGroupNumRows(rg int) int64 { return f.meta.rowGroups[rg].numRows }
+
+// KeyValue returns a value of the key value metadata of the file, such as the "geo"
+// metadata of GeoParquet
+func (f *File) KeyValue(key string) (string, bool) {
+	v, ok := f.meta.keyValues[key]
+	return v, ok
+}
+
+// Statistics returns the min and max values of a column in a row group, if the file
+// has them
+func (f *File) Statistics(rg, col int) (min, max any, ok bool) {
+	s := f.meta.rowGroups[rg].columns[col].stats
+	if !s.hasMinMax {
+		return nil, nil, false
+	}
+	c := &f.columns[col]
+	if min, ok = c.statValue(s.min); !ok {
+		return nil, nil, false
+	}
+	if max, ok = c.statValue(s.max); !ok {
+		return nil, nil, false
+	}
+	return min, max, true
+}
+
+// GeospatialStatistics returns the bounding box of the geospatial statistics of a
+// GEOMETRY or GEOGRAPHY column in a row group, if the file has them
+func (f *File) GeospatialStatistics(rg, col int) (BBox, bool) {
+	if bbox := f.meta.rowGroups[rg].columns[col].geoStats; bbox != nil {
+		return *bbox, true
+	}
+	return BBox{}, false
+}
+
+// ReadColumn reads the values of a column in a row group, with a value for each row.
+// Null values are nil. The values are bool, int64, uint64 for unsigned integers,
+// float32, float64 for doubles and decimals, string for strings, dates and uuids,
+// time.Time for timestamps or []byte.
+func (f *File) ReadColumn(rg, col int) ([]any, error) {
+	c := &f.columns[col]
+	if c.Repeated() {
+		return nil, fmt.Errorf("column %v: repeated columns are not supported", c.Name())
+	}
+
+	cc := f.meta.rowGroups[rg].columns[col]
+	start := cc.dataPageOffset
+	if cc.dictionaryPageOffset > 0 && cc.dictionaryPageOffset < start {
+		start = cc.dictionaryPageOffset
+	}
+	if cc.totalCompressedSize < 0 || cc.totalCompressedSize > math.MaxInt32 || cc.numValues < 0 {
+		return nil, fmt.Errorf("column %v: invalid column chunk", c.Name())
+	}
+	b := make([]byte, cc.totalCompressedSize)
+	if _, err := f.r.ReadAt(b, start); err != nil {
+		return nil, fmt.Errorf("column %v: %w", c.Name(), err)
+	}
+
+	var (
+		values = make([]any, 0, min(cc.numValues, f.meta.rowGroups[rg].numRows, 1<<20))
+		dict   []any
+		r      = thriftReader{b: b}
+	)
+	for int64(len(values)) < cc.numValues && r.pos < len(b) {
+		h, err := readPageHeader(&r)
+		if err != nil {
+			return nil, fmt.Errorf("column %v: reading page header: %w", c.Name(), err)
+		}
+		if r.pos+int(h.compressedSize) > len(b) {
+			return nil, fmt.Errorf("column %v: truncated page", c.Name())
+		}
+		data := b[r.pos : r.pos+int(h.compressedSize)]
+		r.pos += int(h.compressedSize)
+
+		switch h.typ {
+		case pageDictionary:
+			if data, err = decompress(cc.codec, data, int(h.uncompressedSize)); err != nil {
+				return nil, fmt.Errorf("column %v: %w", c.Name(), err)
+			}
+			if dict, err = c.decodePlain(data, int(h.numValues)); err != nil {
+				return nil, fmt.Errorf("column %v: dictionary page: %w", c.Name(), err)
+			}
+
+		case pageData:
+			if data, err = decompress(cc.codec, data, int(h.uncompressedSize)); err != nil {
+				return nil, fmt.Errorf("column %v: %w", c.Name(), err)
+			}
+			var defs []uint32
+			if c.maxDef > 0 {
+				if h.defEncoding != encodingRLE {
+					return nil, fmt.Errorf("column %v: unsupported definition level encoding %v", c.Name(), h.defEncoding)
+				}
+				if len(data) < 4 {
+					return nil, fmt.Errorf("column %v: truncated page", c.Name())
+				}
+				l := int(binary.LittleEndian.Uint32(data))
+				if l < 0 || 4+l > len(data) {
+					return nil, fmt.Errorf("column %v: truncated page", c.Name())
+				}
+				if defs, err = decodeHybrid(data[4:4+l], bitWidth(c.maxDef), int(h.numValues)); err != nil {
+					return nil, fmt.Errorf("column %v: definition levels: %w", c.Name(), err)
+				}
+				data = data[4+l:]
+			}
+			if values, err = c.appendValues(values, data, h.encoding, defs, int(h.numValues), dict); err != nil {
+				return nil, fmt.Errorf("column %v: %w", c.Name(), err)
+			}
+
+		case pageDataV2:
+			// the levels precede the values and are never compressed
+			levels := int(h.repLength) + int(h.defLength)
+			if levels > len(data) {
+				return nil, fmt.Errorf("column %v: truncated page", c.Name())
+			}
+			var defs []uint32
+			if c.maxDef > 0 {
+				if defs, err = decodeHybrid(data[h.repLength:levels], bitWidth(c.maxDef), int(h.numValues)); err != nil {
+					return nil, fmt.Errorf("column %v: definition levels: %w", c.Name(), err)
+				}
+			}
+			data = data[levels:]
+			if h.isCompressed {
+				if data, err = decompress(cc.codec, data, int(h.uncompressedSize)-levels); err != nil {
+					return nil, fmt.Errorf("column %v: %w", c.Name(), err)
+				}
+			}
+			if values, err = c.appendValues(values, data, h.encoding, defs, int(h.numValues), dict); err != nil {
+				return nil, fmt.Errorf("column %v: %w", c.Name(), err)
+			}
+		}
+	}
+
+	if int64(len(values)) != f.meta.rowGroups[rg].numRows {
+		return nil, fmt.Errorf("column %v: read %v values of %v rows", c.Name(), len(values), f.meta.rowGroups[rg].numRows)
+	}
+	return values, nil
+}
+
+// bitWidth is the number of bits of the max value
+func bitWidth(max int) int {
+	w := 0
+	for ; max > 0; max >>= 1 {
+		w++
+	}
+	return w
+}
+
+// appendValues appends the n values of a data page to values, nil for the values with a
+// definition level below the max
+func (c *Column) appendValues(values []any, data []byte, encoding int32, defs []uint32, n int, dict []any) ([]any, error) {
+	count := n
+	if defs != nil {
+		count = 0
+		for _, d := range defs {
+			if int(d) == c.maxDef {
+				count++
+			}
+		}
+	}
+
+	var (
+		decoded []any
+		err     error
+	)
+	switch encoding {
+	case encodingPlain:
+		decoded, err = c.decodePlain(data, count)
+
+	case encodingPlainDictionary, encodingRLEDictionary:
+		if dict == nil {
+			return nil, fmt.Errorf("dictionary encoded page without dictionary")
+		}
+		if len(data) == 0 {
+			if count > 0 {
+				return nil, errEncodingBounds
+			}
+			break
+		}
+		var indices []uint32
+		if indices, err = decodeHybrid(data[1:], int(data[0]), count); err != nil {
+			return nil, err
+		}
+		decoded = make([]any, count)
+		for i, idx := range indices {
+			if int(idx) >= len(dict) {
+				return nil, fmt.Errorf("dictionary index %v out of range", idx)
+			}
+			decoded[i] = dict[idx]
+		}
+
+	case encodingRLE:
+		if c.Type != Boolean {
+			return nil, fmt.Errorf("unsupported encoding %v of %v", encoding, c.Type)
+		}
+		if len(data) < 4 {
+			return nil, errEncodingBounds
+		}
+		l := int(binary.LittleEndian.Uint32(data))
+		if l < 0 || 4+l > len(data) {
+			return nil, errEncodingBounds
+		}
+		var bits []uint32
+		if bits, err = decodeHybrid(data[4:4+l], 1, count); err != nil {
+			return nil, err
+		}
+		decoded = make([]any, count)
+		for i, b := range bits {
+			decoded[i] = b == 1
+		}
+
+	case encodingDeltaBinaryPacked:
+		if c.Type != Int32 && c.Type != Int64 {
+			return nil, fmt.Errorf("unsupported encoding %v of %v", encoding, c.Type)
+		}
+		var ints []int64
+		if ints, _, err = decodeDeltaBinaryPacked(data, count); err != nil {
+			return nil, err
+		}
+		decoded = make([]any, len(ints))
+		for i, v := range ints {
+			if c.Type == Int32 {
+				decoded[i] = c.convert(int32(v))
+			} else {
+				decoded[i] = c.convert(v)
+			}
+		}
+
+	case encodingDeltaLengthByteArray, encodingDeltaByteArray:
+		if c.Type != ByteArray && c.Type != FixedLenByteArray {
+			return nil, fmt.Errorf("unsupported encoding %v of %v", encoding, c.Type)
+		}
+		var arrays [][]byte
+		if encoding == encodingDeltaLengthByteArray {
+			arrays, err = decodeDeltaLengthByteArray(data, count)
+		} else {
+			arrays, err = decodeDeltaByteArray(data, count)
+		}
+		if err != nil {
+			return nil, err
+		}
+		decoded = make([]any, len(arrays))
+		for i, v := range arrays {
+			decoded[i] = c.convert(v)
+		}
+
+	case encodingByteStreamSplit:
+		var width int
+		switch c.Type {
+		case Int32, Float:
+			width = 4
+		case Int64, Double:
+			width = 8
+		case FixedLenByteArray:
+			width = c.typeLength
+		default:
+			return nil, fmt.Errorf("unsupported encoding %v of %v", encoding, c.Type)
+		}
+		var joined []byte
+		if joined, err = decodeByteStreamSplit(data, width, count); err != nil {
+			return nil, err
+		}
+		decoded, err = c.decodePlain(joined, count)
+
+	default:
+		return nil, fmt.Errorf("unsupported encoding %v", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) != count {
+		return nil, fmt.Errorf("decoded %v values, expected %v", len(decoded), count)
+	}
+
+	if defs == nil {
+		return append(values, decoded...), nil
+	}
+	next := 0
+	for _, d := range defs {
+		if int(d) == c.maxDef {
+			values = append(values, decoded[next])
+			next++
+		} else {
+			values = append(values, nil)
+		}
+	}
+	return values, nil
+}
+
+// decodePlain decodes n plain encoded values
+func (c *Column) decodePlain(b []byte, n int) ([]any, error) {
+	var width int
+	switch c.Type {
+	case Boolean:
+		if len(b) < (n+7)/8 {
+			return nil, errEncodingBounds
+		}
+		values := make([]any, n)
+		for i := range values {
+			values[i] = b[i/8]>>(i%8)&1 == 1
+		}
+		return values, nil
+	case Int32, Float:
+		width = 4
+	case Int64, Double:
+		width = 8
+	case Int96:
+		width = 12
+	case FixedLenByteArray:
+		width = c.typeLength
+	case ByteArray:
+		values := make([]any, n)
+		pos := 0
+		for i := range values {
+			if pos+4 > len(b) {
+				return nil, errEncodingBounds
+			}
+			l := int(binary.LittleEndian.Uint32(b[pos:]))
+			pos += 4
+			if l < 0 || pos+l > len(b) {
+				return nil, errEncodingBounds
+			}
+			values[i] = c.convert(b[pos : pos+l])
+			pos += l
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %v", c.Type)
+	}
+
+	if n < 0 || len(b)/width < n {
+		return nil, errEncodingBounds
+	}
+	values := make([]any, n)
+	for i := range values {
+		values[i] = c.convert(c.fixed(b[i*width : (i+1)*width]))
+	}
+	return values, nil
+}
+
+// fixed decodes a plain value of a fixed width type
+func (c *Column) fixed(b []byte) any {
+	switch c.Type {
+	case Int32:
+		return int32(binary.LittleEndian.Uint32(b))
+	case Int64:
+		return int64(binary.LittleEndian.Uint64(b))
+	case Float:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	case Double:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case Int96:
+		// the nanoseconds of the day and the julian day of legacy timestamps
+		nanos := int64(binary.LittleEndian.Uint64(b))
+		days := int64(binary.LittleEndian.Uint32(b[8:]))
+		return time.Unix((days-2440588)*86400, nanos).UTC()
+	default:
+		return b
+	}
+}
+
+// statValue decodes a min or max value of the statistics, which are plain encoded
+// without the length of byte arrays
+func (c *Column) statValue(b []byte) (any, bool) {
+	switch c.Type {
+	case Boolean:
+		if len(b) < 1 {
+			return nil, false
+		}
+		return b[0] == 1, true
+	case ByteArray:
+		return c.convert(b), true
+	}
+	values, err := c.decodePlain(b, 1)
+	if err != nil {
+		return nil, false
+	}
+	return values[0], true
+}
+
+// convert converts a physical value to the value of the annotation of the column
+func (c *Column) convert(v any) any {
+	switch v := v.(type) {
+	case int32:
+		if c.kind == kindUnsigned {
+			return uint64(uint32(v))
+		}
+		return c.convertInt(int64(v))
+	case int64:
+		if c.kind == kindUnsigned {
+			return uint64(v)
+		}
+		return c.convertInt(v)
+	case []byte:
+		switch c.kind {
+		case kindString:
+			return string(v)
+		case kindDecimal:
+			return decimalBytes(v, c.scale)
+		case kindUUID:
+			if len(v) == 16 {
+				h := hex.EncodeToString(v)
+				return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+			}
+		case kindFloat16:
+			if len(v) == 2 {
+				return float16(binary.LittleEndian.Uint16(v))
+			}
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func (c *Column) convertInt(v int64) any {
+	switch c.kind {
+	case kindDecimal:
+		return float64(v) / math.Pow10(int(c.scale))
+	case kindDate:
+		return time.Unix(v*86400, 0).UTC().Format(time.DateOnly)
+	case kindTimestamp:
+		switch c.unit {
+		case unitMillis:
+			return time.UnixMilli(v).UTC()
+		case unitMicros:
+			return time.UnixMicro(v).UTC()
+		case unitNanos:
+			return time.Unix(0, v).UTC()
+		}
+	}
+	return v
+}
+
+// decimalBytes converts the big endian two's complement unscaled value of a decimal
+func decimalBytes(b []byte, scale int32) float64 {
+	i := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	f, _ := new(big.Float).SetInt(i).Float64()
+	return f / math.Pow10(int(scale))
+}
+
+// float16 converts a half precision float
+func float16(h uint16) float32 {
+	var (
+		sign = uint32(h>>15) << 31
+		exp  = int32(h>>10) & 0x1f
+		frac = uint32(h & 0x3ff)
+	)
+	switch {
+	case exp == 0x1f:
+		// infinity and NaN
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	case exp == 0:
+		// zero and subnormals
+		return math.Float32frombits(sign) + float32(frac)*float32(math.Pow(2, -24))*signum(sign)
+	default:
+		return math.Float32frombits(sign | uint32(exp-15+127)<<23 | frac<<13)
+	}
+}
+
+func signum(sign uint32) float32 {
+	if sign != 0 {
+		return -1
+	}
+	return 1
+}
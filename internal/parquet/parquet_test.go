@@ -0,0 +1,320 @@
+package parquet
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func openFile(t *testing.T, name string) *File {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("unable to read %v: %v", name, err)
+	}
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("unable to open %v: %v", name, err)
+	}
+	return f
+}
+
+// typesRow is the row i of the types fixtures
+func typesRow(i int) map[string]any {
+	var (
+		names = []string{"alpha", "beta", "gamma", "delta", "epsilon"}
+		epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		name  any
+	)
+	if i%7 != 0 {
+		name = names[i%5]
+	}
+	return map[string]any{
+		"id":        int64(i*3 - 100),
+		"name":      name,
+		"label":     fmt.Sprintf("label-%03d", i),
+		"score":     float64(i) * 1.5,
+		"ratio":     float32(i) / 4,
+		"flag":      i%3 == 0,
+		"count":     int64(i * i),
+		"small":     uint64(i * 200),
+		"price":     float64(i*101) / 100,
+		"day":       epoch.AddDate(0, 0, i).Format(time.DateOnly),
+		"ts":        epoch.Add(time.Duration(i*1000003) * time.Millisecond),
+		"data":      []byte{byte(i), byte(i >> 8)},
+		"bbox.xmin": float32(i),
+		"bbox.ymin": float32(-i),
+		"bbox.xmax": float32(i + 1),
+		"bbox.ymax": float32(-i + 1),
+	}
+}
+
+func TestReadColumn(t *testing.T) {
+	type tcase struct {
+		filename string
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			f := openFile(t, tc.filename)
+
+			if f.NumRows() != 300 {
+				t.Errorf("num rows, expected 300 got %v", f.NumRows())
+			}
+			if f.NumRowGroups() != 3 {
+				t.Fatalf("num row groups, expected 3 got %v", f.NumRowGroups())
+			}
+
+			var row int
+			for rg := 0; rg < f.NumRowGroups(); rg++ {
+				n := int(f.RowGroupNumRows(rg))
+				for col, c := range f.Columns() {
+					values, err := f.ReadColumn(rg, col)
+					if err != nil {
+						t.Fatalf("row group %v column %v, unexpected error: %v", rg, c.Name(), err)
+					}
+					if len(values) != n {
+						t.Fatalf("row group %v column %v, expected %v values got %v", rg, c.Name(), n, len(values))
+					}
+					for i, v := range values {
+						expected := typesRow(row + i)[c.Name()]
+						if !reflect.DeepEqual(expected, v) {
+							t.Errorf("row %v column %v, expected %v (%T) got %v (%T)", row+i, c.Name(), expected, expected, v, v)
+						}
+					}
+				}
+				row += n
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"data page v1": {filename: "testdata/types_v1.parquet"},
+		"data page v2": {filename: "testdata/types_v2.parquet"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestColumns(t *testing.T) {
+	f := openFile(t, "testdata/types_v1.parquet")
+
+	var names []string
+	for _, c := range f.Columns() {
+		names = append(names, c.Name())
+	}
+	expected := []string{"id", "name", "label", "score", "ratio", "flag", "count", "small", "price", "day", "ts", "data", "bbox.xmin", "bbox.ymin", "bbox.xmax", "bbox.ymax"}
+	if !reflect.DeepEqual(expected, names) {
+		t.Errorf("columns, expected %v got %v", expected, names)
+	}
+
+	if col := f.Column("bbox.ymax"); col != 15 {
+		t.Errorf("column bbox.ymax, expected 15 got %v", col)
+	}
+	if col := f.Column("missing"); col != -1 {
+		t.Errorf("column missing, expected -1 got %v", col)
+	}
+	if typ := f.Columns()[0].Type; typ != Int64 {
+		t.Errorf("column type, expected %v got %v", Int64, typ)
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	type tcase struct {
+		column   string
+		rowGroup int
+		min, max any
+	}
+
+	f := openFile(t, "testdata/types_v2.parquet")
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			min, max, ok := f.Statistics(tc.rowGroup, f.Column(tc.column))
+			if !ok {
+				t.Fatalf("expected statistics")
+			}
+			if !reflect.DeepEqual(tc.min, min) {
+				t.Errorf("min, expected %v (%T) got %v (%T)", tc.min, tc.min, min, min)
+			}
+			if !reflect.DeepEqual(tc.max, max) {
+				t.Errorf("max, expected %v (%T) got %v (%T)", tc.max, tc.max, max, max)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"int64": {
+			column:   "id",
+			rowGroup: 1,
+			min:      int64(200),
+			max:      int64(497),
+		},
+		"float": {
+			column:   "bbox.ymin",
+			rowGroup: 2,
+			min:      float32(-299),
+			max:      float32(-200),
+		},
+		"string": {
+			column:   "label",
+			rowGroup: 0,
+			min:      "label-000",
+			max:      "label-099",
+		},
+		"date": {
+			column:   "day",
+			rowGroup: 0,
+			min:      "2024-01-01",
+			max:      "2024-04-09",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	b, err := os.ReadFile("testdata/types_v1.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]byte{
+		"empty":         {},
+		"no magic":      append([]byte("PAR2"), b[4:]...),
+		"no footer":     b[:len(b)-8],
+		"encrypted":     append(append([]byte{}, b[:len(b)-4]...), "PARE"...),
+		"short footer":  append(append([]byte{}, b[:len(b)-8]...), 0xff, 0xff, 0xff, 0x7f, 'P', 'A', 'R', '1'),
+		"bad metadata":  append(append([]byte{}, b[:len(b)-8-100]...), append(bytes.Repeat([]byte{0xff}, 100), b[len(b)-8:]...)...),
+		"not a parquet": []byte("not a parquet file at all"),
+	}
+
+	for name, b := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Open(bytes.NewReader(b), int64(len(b))); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestDecodeHybrid(t *testing.T) {
+	type tcase struct {
+		b        []byte
+		width    int
+		n        int
+		expected []uint32
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			values, err := decodeHybrid(tc.b, tc.width, tc.n)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.expected, values) {
+				t.Errorf("expected %v got %v", tc.expected, values)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"run": {
+			// a run of 5 of the value 3
+			b:        []byte{5 << 1, 3},
+			width:    2,
+			n:        5,
+			expected: []uint32{3, 3, 3, 3, 3},
+		},
+		"bit packed": {
+			// the example of the parquet spec, 0 to 7 in 3 bits
+			b:        []byte{1<<1 | 1, 0x88, 0xc6, 0xfa},
+			width:    3,
+			n:        8,
+			expected: []uint32{0, 1, 2, 3, 4, 5, 6, 7},
+		},
+		"run then bit packed": {
+			b:        []byte{2 << 1, 1, 1<<1 | 1, 0x0e},
+			width:    1,
+			n:        5,
+			expected: []uint32{1, 1, 0, 1, 1},
+		},
+		"zero width": {
+			b:        []byte{4 << 1},
+			width:    0,
+			n:        4,
+			expected: []uint32{0, 0, 0, 0},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestDecodeLZ4Block(t *testing.T) {
+	type tcase struct {
+		b        []byte
+		size     int
+		expected string
+		err      bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			out, err := decodeLZ4Block(tc.b, tc.size)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(out) != tc.expected {
+				t.Errorf("expected %q got %q", tc.expected, out)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"literals": {
+			b:        append([]byte{5 << 4}, "hello"...),
+			size:     5,
+			expected: "hello",
+		},
+		"overlapping match": {
+			// "ab" then a match of 6 at offset 2, then the literal "c"
+			b:        append(append([]byte{2<<4 | 2}, "ab"...), 2, 0, 1<<4, 'c'),
+			size:     9,
+			expected: "abababab" + "c",
+		},
+		"long literals": {
+			b:        append([]byte{15 << 4, 5}, bytes.Repeat([]byte{'x'}, 20)...),
+			size:     20,
+			expected: string(bytes.Repeat([]byte{'x'}, 20)),
+		},
+		"offset out of range": {
+			b:    append(append([]byte{2<<4 | 2}, "ab"...), 3, 0, 1<<4, 'c'),
+			size: 9,
+			err:  true,
+		},
+		"exceeds size": {
+			b:    append([]byte{5 << 4}, "hello"...),
+			size: 4,
+			err:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
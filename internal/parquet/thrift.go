@@ -0,0 +1,202 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// thrift compact protocol types
+const (
+	thriftStop      = 0
+	thriftTrue      = 1
+	thriftFalse     = 2
+	thriftByte      = 3
+	thriftI16       = 4
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftDouble    = 7
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftSet       = 10
+	thriftMap       = 11
+	thriftStruct    = 12
+	maxThriftDepth  = 64
+	maxThriftLength = 1 << 30
+)
+
+var errThriftBounds = errors.New("truncated thrift data")
+
+// thriftReader decodes the thrift compact protocol the parquet metadata is encoded with
+type thriftReader struct {
+	b     []byte
+	pos   int
+	depth int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.b) {
+		return 0, errThriftBounds
+	}
+	c := r.b[r.pos]
+	r.pos++
+	return c, nil
+}
+
+func (r *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		return 0, errThriftBounds
+	}
+	r.pos += n
+	return v, nil
+}
+
+// varint reads a zigzag encoded int, which the i16, i32 and i64 types are
+func (r *thriftReader) varint() (int64, error) {
+	v, err := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (r *thriftReader) i32() (int32, error) {
+	v, err := r.varint()
+	return int32(v), err
+}
+
+func (r *thriftReader) double() (float64, error) {
+	if r.pos+8 > len(r.b) {
+		return 0, errThriftBounds
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.pos:]))
+	r.pos += 8
+	return v, nil
+}
+
+// binary returns the bytes of a binary or string, which are not copied
+func (r *thriftReader) binary() ([]byte, error) {
+	n, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > maxThriftLength || r.pos+int(n) > len(r.b) {
+		return nil, errThriftBounds
+	}
+	b := r.b[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+func (r *thriftReader) string() (string, error) {
+	b, err := r.binary()
+	return string(b), err
+}
+
+// list reads the header of a list or set and calls fn for each element
+func (r *thriftReader) list(fn func(typ byte) error) error {
+	h, err := r.byte()
+	if err != nil {
+		return err
+	}
+	size, typ := int(h>>4), h&0x0f
+	if size == 15 {
+		n, err := r.uvarint()
+		if err != nil {
+			return err
+		}
+		if n > maxThriftLength {
+			return errThriftBounds
+		}
+		size = int(n)
+	}
+	for i := 0; i < size; i++ {
+		if err := fn(typ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structure reads the fields of a struct, calling fn with the id and type of each
+// field. fn must read the value of the fields it knows and skip the others.
+func (r *thriftReader) structure(fn func(id int16, typ byte) error) error {
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > maxThriftDepth {
+		return errors.New("thrift data nested too deep")
+	}
+
+	var id int16
+	for {
+		h, err := r.byte()
+		if err != nil {
+			return err
+		}
+		typ := h & 0x0f
+		if typ == thriftStop {
+			return nil
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, err := r.varint()
+			if err != nil {
+				return err
+			}
+			id = int16(v)
+		}
+		if err := fn(id, typ); err != nil {
+			return err
+		}
+	}
+}
+
+// skip skips a value of the type
+func (r *thriftReader) skip(typ byte) error {
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > maxThriftDepth {
+		return errors.New("thrift data nested too deep")
+	}
+
+	var err error
+	switch typ {
+	case thriftTrue, thriftFalse:
+	case thriftByte:
+		_, err = r.byte()
+	case thriftI16, thriftI32, thriftI64:
+		_, err = r.uvarint()
+	case thriftDouble:
+		_, err = r.double()
+	case thriftBinary:
+		_, err = r.binary()
+	case thriftList, thriftSet:
+		err = r.list(func(typ byte) error {
+			// the booleans of lists are bytes
+			if typ == thriftTrue || typ == thriftFalse {
+				_, err := r.byte()
+				return err
+			}
+			return r.skip(typ)
+		})
+	case thriftMap:
+		var n uint64
+		if n, err = r.uvarint(); err != nil || n == 0 {
+			return err
+		}
+		var kv byte
+		if kv, err = r.byte(); err != nil {
+			return err
+		}
+		for i := uint64(0); i < n && err == nil; i++ {
+			if err = r.skip(kv >> 4); err == nil {
+				err = r.skip(kv & 0x0f)
+			}
+		}
+	case thriftStruct:
+		err = r.structure(func(_ int16, typ byte) error { return r.skip(typ) })
+	default:
+		err = fmt.Errorf("unknown thrift type %v", typ)
+	}
+	return err
+}
@@ -0,0 +1,369 @@
+// Package pmtiles implements the PMTiles version 3 archive format, a single file
+// tileset addressed by a Hilbert curve tile id, which can be served from static hosting
+// with HTTP range requests. The spec is at https://github.com/protomaps/PMTiles/blob/main/spec/v3/spec.md
+package pmtiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// HeaderLength is the length of the fixed size header at the start of an archive
+	HeaderLength = 127
+	// maxRootLength is the max length of the root directory, so the header and root
+	// directory can be fetched with a single 16 KiB request
+	maxRootLength = 16384 - HeaderLength
+	// magic starts every archive
+	magic = "PMTiles"
+	// specVersion is the version of the spec implemented
+	specVersion = 3
+)
+
+// Compression is the compression of the directories, metadata or tiles of an archive
+type Compression uint8
+
+const (
+	CompressionUnknown Compression = 0
+	CompressionNone    Compression = 1
+	CompressionGzip    Compression = 2
+	CompressionBrotli  Compression = 3
+	CompressionZstd    Compression = 4
+)
+
+// TileType is the format of the tiles of an archive
+type TileType uint8
+
+const (
+	TileTypeUnknown TileType = 0
+	TileTypeMVT     TileType = 1
+)
+
+var (
+	ErrInvalidMagic   = errors.New("pmtiles: not a pmtiles archive")
+	ErrInvalidVersion = errors.New("pmtiles: unsupported spec version")
+	ErrCompression    = errors.New("pmtiles: unsupported compression")
+)
+
+// Header is the fixed size header of an archive. Offsets and lengths are in bytes from
+// the start of the archive.
+type Header struct {
+	RootOffset          uint64
+	RootLength          uint64
+	MetadataOffset      uint64
+	MetadataLength      uint64
+	LeafDirsOffset      uint64
+	LeafDirsLength      uint64
+	TileDataOffset      uint64
+	TileDataLength      uint64
+	AddressedTilesCount uint64
+	TileEntriesCount    uint64
+	TileContentsCount   uint64
+	// Clustered is true when the tile data is ordered by tile id
+	Clustered           bool
+	InternalCompression Compression
+	TileCompression     Compression
+	TileType            TileType
+	MinZoom             uint8
+	MaxZoom             uint8
+	// the bounds and center are in WGS84, stored as integers of 1e-7 degrees
+	MinLon     float64
+	MinLat     float64
+	MaxLon     float64
+	MaxLat     float64
+	CenterZoom uint8
+	CenterLon  float64
+	CenterLat  float64
+}
+
+// e7 converts degrees to the integer representation of the header
+func e7(deg float64) int32 {
+	return int32(math.Round(deg * 1e7))
+}
+
+// MarshalBinary encodes the header
+func (h Header) MarshalBinary() ([]byte, error) {
+	b := make([]byte, HeaderLength)
+	copy(b, magic)
+	b[7] = specVersion
+
+	for i, v := range []uint64{
+		h.RootOffset, h.RootLength,
+		h.MetadataOffset, h.MetadataLength,
+		h.LeafDirsOffset, h.LeafDirsLength,
+		h.TileDataOffset, h.TileDataLength,
+		h.AddressedTilesCount, h.TileEntriesCount, h.TileContentsCount,
+	} {
+		binary.LittleEndian.PutUint64(b[8+i*8:], v)
+	}
+
+	if h.Clustered {
+		b[96] = 1
+	}
+	b[97] = byte(h.InternalCompression)
+	b[98] = byte(h.TileCompression)
+	b[99] = byte(h.TileType)
+	b[100] = h.MinZoom
+	b[101] = h.MaxZoom
+	binary.LittleEndian.PutUint32(b[102:], uint32(e7(h.MinLon)))
+	binary.LittleEndian.PutUint32(b[106:], uint32(e7(h.MinLat)))
+	binary.LittleEndian.PutUint32(b[110:], uint32(e7(h.MaxLon)))
+	binary.LittleEndian.PutUint32(b[114:], uint32(e7(h.MaxLat)))
+	b[118] = h.CenterZoom
+	binary.LittleEndian.PutUint32(b[119:], uint32(e7(h.CenterLon)))
+	binary.LittleEndian.PutUint32(b[123:], uint32(e7(h.CenterLat)))
+
+	return b, nil
+}
+
+// UnmarshalBinary decodes the header
+func (h *Header) UnmarshalBinary(b []byte) error {
+	if len(b) < HeaderLength || string(b[:7]) != magic {
+		return ErrInvalidMagic
+	}
+	if b[7] != specVersion {
+		return fmt.Errorf("%w (%v)", ErrInvalidVersion, b[7])
+	}
+
+	for i, v := range []*uint64{
+		&h.RootOffset, &h.RootLength,
+		&h.MetadataOffset, &h.MetadataLength,
+		&h.LeafDirsOffset, &h.LeafDirsLength,
+		&h.TileDataOffset, &h.TileDataLength,
+		&h.AddressedTilesCount, &h.TileEntriesCount, &h.TileContentsCount,
+	} {
+		*v = binary.LittleEndian.Uint64(b[8+i*8:])
+	}
+
+	deg := func(b []byte) float64 {
+		return float64(int32(binary.LittleEndian.Uint32(b))) / 1e7
+	}
+
+	h.Clustered = b[96] == 1
+	h.InternalCompression = Compression(b[97])
+	h.TileCompression = Compression(b[98])
+	h.TileType = TileType(b[99])
+	h.MinZoom = b[100]
+	h.MaxZoom = b[101]
+	h.MinLon, h.MinLat = deg(b[102:]), deg(b[106:])
+	h.MaxLon, h.MaxLat = deg(b[110:]), deg(b[114:])
+	h.CenterZoom = b[118]
+	h.CenterLon, h.CenterLat = deg(b[119:]), deg(b[123:])
+
+	return nil
+}
+
+// TileID returns the id of the z/x/y tile: the number of tiles of the lower zooms plus
+// the position of the tile along the Hilbert curve of its zoom
+func TileID(z uint8, x, y uint32) uint64 {
+	// the number of tiles of the zooms below z: (4^z - 1) / 3
+	id := ((uint64(1) << (2 * uint64(z))) - 1) / 3
+
+	n := uint32(1) << z
+	for s := n / 2; s > 0; s /= 2 {
+		var rx, ry uint32
+		if x&s > 0 {
+			rx = 1
+		}
+		if y&s > 0 {
+			ry = 1
+		}
+		id += uint64(s) * uint64(s) * uint64((3*rx)^ry)
+
+		// rotate the quadrant
+		if ry == 0 {
+			if rx == 1 {
+				x, y = n-1-x, n-1-y
+			}
+			x, y = y, x
+		}
+	}
+
+	return id
+}
+
+// Entry is an entry of a directory, addressing RunLength tiles with consecutive ids
+// starting at TileID which share the same data. An entry with a RunLength of 0 addresses
+// a leaf directory instead. Offsets are relative to the tile data or leaf directories section.
+type Entry struct {
+	TileID    uint64
+	Offset    uint64
+	Length    uint32
+	RunLength uint32
+}
+
+// serializeEntries encodes a directory, sorted by tile id, and compresses it
+func serializeEntries(entries []Entry, compression Compression) ([]byte, error) {
+	var (
+		buf = make([]byte, 0, len(entries)*8)
+		tmp [binary.MaxVarintLen64]byte
+	)
+	put := func(v uint64) {
+		n := binary.PutUvarint(tmp[:], v)
+		buf = append(buf, tmp[:n]...)
+	}
+
+	put(uint64(len(entries)))
+
+	var last uint64
+	for _, e := range entries {
+		put(e.TileID - last)
+		last = e.TileID
+	}
+	for _, e := range entries {
+		put(uint64(e.RunLength))
+	}
+	for _, e := range entries {
+		put(uint64(e.Length))
+	}
+	for i, e := range entries {
+		// 0 marks an entry whose data directly follows the data of the previous entry
+		if i > 0 && e.Offset == entries[i-1].Offset+uint64(entries[i-1].Length) {
+			put(0)
+			continue
+		}
+		put(e.Offset + 1)
+	}
+
+	return compress(buf, compression)
+}
+
+// deserializeEntries decompresses and decodes a directory
+func deserializeEntries(b []byte, compression Compression) ([]Entry, error) {
+	raw, err := decompress(b, compression)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(raw)
+
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("pmtiles: invalid directory: %w", err)
+	}
+	// each entry takes at least 4 bytes
+	if n > uint64(len(raw)) {
+		return nil, fmt.Errorf("pmtiles: invalid directory of %v entries", n)
+	}
+
+	entries := make([]Entry, n)
+	read := func(set func(i int, v uint64)) error {
+		for i := range entries {
+			v, err := binary.ReadUvarint(r)
+			if err != nil {
+				return fmt.Errorf("pmtiles: invalid directory: %w", err)
+			}
+			set(i, v)
+		}
+		return nil
+	}
+
+	var last uint64
+	steps := []func(i int, v uint64){
+		func(i int, v uint64) {
+			last += v
+			entries[i].TileID = last
+		},
+		func(i int, v uint64) { entries[i].RunLength = uint32(v) },
+		func(i int, v uint64) { entries[i].Length = uint32(v) },
+		func(i int, v uint64) {
+			if v == 0 && i > 0 {
+				entries[i].Offset = entries[i-1].Offset + uint64(entries[i-1].Length)
+				return
+			}
+			entries[i].Offset = v - 1
+		},
+	}
+	for _, step := range steps {
+		if err := read(step); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// findEntry returns the entry addressing the tile id in a directory sorted by tile id
+func findEntry(entries []Entry, id uint64) (Entry, bool) {
+	lo, hi := 0, len(entries)-1
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		switch {
+		case entries[mid].TileID < id:
+			lo = mid + 1
+		case entries[mid].TileID > id:
+			hi = mid - 1
+		default:
+			return entries[mid], true
+		}
+	}
+
+	// hi is the last entry before the id, which may be a run or a leaf directory covering it
+	if hi >= 0 {
+		e := entries[hi]
+		if e.RunLength == 0 || id-e.TileID < uint64(e.RunLength) {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+func compress(b []byte, compression Compression) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return b, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionBrotli:
+		var buf bytes.Buffer
+		w := brotli.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("%w (%v)", ErrCompression, compression)
+	}
+}
+
+func decompress(b []byte, compression Compression) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return b, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	case CompressionBrotli:
+		return io.ReadAll(brotli.NewReader(bytes.NewReader(b)))
+	case CompressionZstd:
+		r, err := zstd.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("%w (%v)", ErrCompression, compression)
+	}
+}
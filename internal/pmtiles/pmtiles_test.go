@@ -0,0 +1,128 @@
+package pmtiles
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTileID(t *testing.T) {
+	type tcase struct {
+		z    uint8
+		x, y uint32
+		id   uint64
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			if id := TileID(tc.z, tc.x, tc.y); id != tc.id {
+				t.Errorf("expected %v got %v", tc.id, id)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"0/0/0":   {z: 0, x: 0, y: 0, id: 0},
+		"1/0/0":   {z: 1, x: 0, y: 0, id: 1},
+		"1/0/1":   {z: 1, x: 0, y: 1, id: 2},
+		"1/1/1":   {z: 1, x: 1, y: 1, id: 3},
+		"1/1/0":   {z: 1, x: 1, y: 0, id: 4},
+		"2/0/0":   {z: 2, x: 0, y: 0, id: 5},
+		"3/0/0":   {z: 3, x: 0, y: 0, id: 21},
+		"3/7/0":   {z: 3, x: 7, y: 0, id: 84},
+		"20/0/0":  {z: 20, x: 0, y: 0, id: 366503875925},
+		"max z26": {z: 26, x: 1<<26 - 1, y: 0, id: (1<<52-1)/3 + 1<<52 - 1},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestHeader(t *testing.T) {
+	h := Header{
+		RootOffset:          HeaderLength,
+		RootLength:          20,
+		MetadataOffset:      147,
+		MetadataLength:      30,
+		LeafDirsOffset:      177,
+		TileDataOffset:      177,
+		TileDataLength:      1000,
+		AddressedTilesCount: 10,
+		TileEntriesCount:    8,
+		TileContentsCount:   6,
+		Clustered:           true,
+		InternalCompression: CompressionGzip,
+		TileCompression:     CompressionGzip,
+		TileType:            TileTypeMVT,
+		MinZoom:             2,
+		MaxZoom:             14,
+		MinLon:              -180,
+		MinLat:              -85.0511,
+		MaxLon:              180,
+		MaxLat:              85.0511,
+		CenterZoom:          4,
+		CenterLon:           -122.4194155,
+		CenterLat:           37.7749295,
+	}
+
+	b, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal, expected nil got %v", err)
+	}
+	if len(b) != HeaderLength {
+		t.Fatalf("length, expected %v got %v", HeaderLength, len(b))
+	}
+
+	var got Header
+	if err = got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("unmarshal, expected nil got %v", err)
+	}
+	if !reflect.DeepEqual(got, h) {
+		t.Errorf("expected %+v got %+v", h, got)
+	}
+
+	b[7] = 2
+	if err = got.UnmarshalBinary(b); err == nil {
+		t.Errorf("unmarshal version 2, expected error got nil")
+	}
+}
+
+func TestEntries(t *testing.T) {
+	entries := []Entry{
+		{TileID: 0, Offset: 0, Length: 10, RunLength: 1},
+		{TileID: 1, Offset: 10, Length: 5, RunLength: 3},
+		{TileID: 5, Offset: 0, Length: 10, RunLength: 1},
+		{TileID: 100, Offset: 15, Length: 7, RunLength: 0},
+	}
+
+	for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionBrotli} {
+		b, err := serializeEntries(entries, compression)
+		if err != nil {
+			t.Fatalf("serialize, expected nil got %v", err)
+		}
+		got, err := deserializeEntries(b, compression)
+		if err != nil {
+			t.Fatalf("deserialize, expected nil got %v", err)
+		}
+		if !reflect.DeepEqual(got, entries) {
+			t.Errorf("compression %v, expected %v got %v", compression, entries, got)
+		}
+	}
+
+	for id, expected := range map[uint64]int{
+		0:   0,
+		2:   1,
+		3:   1,
+		4:   -1,
+		5:   2,
+		6:   -1,
+		100: 3,
+		// past the last entry, which is a leaf directory
+		150: 3,
+	} {
+		e, ok := findEntry(entries, id)
+		if ok != (expected >= 0) || ok && e != entries[expected] {
+			t.Errorf("find %v, expected entry %v got %v %v", id, expected, e, ok)
+		}
+	}
+}
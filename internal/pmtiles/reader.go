@@ -0,0 +1,138 @@
+package pmtiles
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxDepth is the max number of directories read to find a tile: the root directory
+// and up to 3 levels of leaf directories
+const maxDepth = 4
+
+// maxCachedLeaves is the number of leaf directories kept in memory by a Reader
+const maxCachedLeaves = 64
+
+// Reader reads the tiles and metadata of an archive
+type Reader struct {
+	r      io.ReaderAt
+	Header Header
+	root   []Entry
+
+	leavesLock sync.Mutex
+	// leaves are the decoded leaf directories read so far, by offset
+	leaves map[uint64][]Entry
+}
+
+// NewReader reads the header and root directory of an archive
+func NewReader(r io.ReaderAt) (*Reader, error) {
+	b := make([]byte, HeaderLength)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		if err == io.EOF {
+			return nil, ErrInvalidMagic
+		}
+		return nil, err
+	}
+
+	rd := Reader{r: r, leaves: map[uint64][]Entry{}}
+	if err := rd.Header.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+
+	// tiles are only decompressed when they're read, so their compression is checked
+	// upfront
+	switch rd.Header.TileCompression {
+	case CompressionNone, CompressionGzip, CompressionBrotli, CompressionZstd:
+	default:
+		return nil, fmt.Errorf("%w of tiles (%v)", ErrCompression, rd.Header.TileCompression)
+	}
+
+	b, err := rd.read(rd.Header.RootOffset, rd.Header.RootLength)
+	if err != nil {
+		return nil, fmt.Errorf("pmtiles: reading root directory: %w", err)
+	}
+	if rd.root, err = deserializeEntries(b, rd.Header.InternalCompression); err != nil {
+		return nil, err
+	}
+
+	return &rd, nil
+}
+
+func (rd *Reader) read(offset, length uint64) ([]byte, error) {
+	b := make([]byte, length)
+	if _, err := rd.r.ReadAt(b, int64(offset)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Metadata returns the decompressed JSON metadata of the archive
+func (rd *Reader) Metadata() ([]byte, error) {
+	b, err := rd.read(rd.Header.MetadataOffset, rd.Header.MetadataLength)
+	if err != nil {
+		return nil, fmt.Errorf("pmtiles: reading metadata: %w", err)
+	}
+	return decompress(b, rd.Header.InternalCompression)
+}
+
+// leaf returns the leaf directory addressed by an entry of a directory
+func (rd *Reader) leaf(e Entry) ([]Entry, error) {
+	rd.leavesLock.Lock()
+	entries, ok := rd.leaves[e.Offset]
+	rd.leavesLock.Unlock()
+	if ok {
+		return entries, nil
+	}
+
+	b, err := rd.read(rd.Header.LeafDirsOffset+e.Offset, uint64(e.Length))
+	if err != nil {
+		return nil, fmt.Errorf("pmtiles: reading leaf directory: %w", err)
+	}
+	if entries, err = deserializeEntries(b, rd.Header.InternalCompression); err != nil {
+		return nil, err
+	}
+
+	rd.leavesLock.Lock()
+	// the cache is bounded by starting over when full, which keeps the directories of
+	// the area being served
+	if len(rd.leaves) >= maxCachedLeaves {
+		rd.leaves = map[uint64][]Entry{}
+	}
+	rd.leaves[e.Offset] = entries
+	rd.leavesLock.Unlock()
+
+	return entries, nil
+}
+
+// Tile returns the decompressed data of the z/x/y tile, or nil when the archive doesn't
+// have the tile
+func (rd *Reader) Tile(z uint8, x, y uint32) ([]byte, error) {
+	if z < rd.Header.MinZoom || z > rd.Header.MaxZoom {
+		return nil, nil
+	}
+
+	var (
+		id      = TileID(z, x, y)
+		entries = rd.root
+	)
+	for depth := 0; depth < maxDepth; depth++ {
+		e, ok := findEntry(entries, id)
+		if !ok {
+			return nil, nil
+		}
+		if e.RunLength > 0 {
+			b, err := rd.read(rd.Header.TileDataOffset+e.Offset, uint64(e.Length))
+			if err != nil {
+				return nil, fmt.Errorf("pmtiles: reading tile %v/%v/%v: %w", z, x, y, err)
+			}
+			return decompress(b, rd.Header.TileCompression)
+		}
+
+		var err error
+		if entries, err = rd.leaf(e); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("pmtiles: directories of tile %v/%v/%v nested too deep", z, x, y)
+}
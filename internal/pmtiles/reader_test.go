@@ -0,0 +1,249 @@
+package pmtiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/go-spatial/tegola/cache"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(w, s); err != nil {
+		t.Fatalf("gzip, expected nil got %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("gzip, expected nil got %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "osm.pmtiles")
+
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatalf("new writer, expected nil got %v", err)
+	}
+	tiles := map[[3]uint32]string{
+		{0, 0, 0}: "root",
+		{1, 1, 0}: "ocean",
+		{6, 3, 5}: "land",
+		{6, 3, 6}: "land",
+	}
+	for zxy, data := range tiles {
+		if err = w.WriteTile(uint8(zxy[0]), zxy[1], zxy[2], gzipped(t, data)); err != nil {
+			t.Fatalf("write tile, expected nil got %v", err)
+		}
+	}
+	desc := cache.Tileset{
+		Name:    "osm",
+		MaxZoom: 6,
+		Layers:  []cache.TilesetLayer{{ID: "roads", MaxZoom: 6}},
+	}
+	if err = w.Close(desc); err != nil {
+		t.Fatalf("close, expected nil got %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open, expected nil got %v", err)
+	}
+	defer f.Close()
+
+	rd, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("new reader, expected nil got %v", err)
+	}
+	if rd.Header.MaxZoom != 6 {
+		t.Errorf("max zoom, expected 6 got %v", rd.Header.MaxZoom)
+	}
+
+	for zxy, expected := range tiles {
+		data, err := rd.Tile(uint8(zxy[0]), zxy[1], zxy[2])
+		if err != nil {
+			t.Fatalf("tile %v, expected nil got %v", zxy, err)
+		}
+		if string(data) != expected {
+			t.Errorf("tile %v, expected %v got %s", zxy, expected, data)
+		}
+	}
+	for _, zxy := range [][3]uint32{{1, 0, 0}, {6, 3, 7}, {7, 0, 0}} {
+		data, err := rd.Tile(uint8(zxy[0]), zxy[1], zxy[2])
+		if err != nil || data != nil {
+			t.Errorf("tile %v, expected nil nil got %s %v", zxy, data, err)
+		}
+	}
+
+	raw, err := rd.Metadata()
+	if err != nil {
+		t.Fatalf("metadata, expected nil got %v", err)
+	}
+	var md metadata
+	if err = json.Unmarshal(raw, &md); err != nil {
+		t.Fatalf("decode metadata, expected nil got %v", err)
+	}
+	if md.Name != "osm" || len(md.VectorLayers) != 1 || md.VectorLayers[0].ID != "roads" {
+		t.Errorf("unexpected metadata %+v", md)
+	}
+}
+
+func TestReaderLeaves(t *testing.T) {
+	// tiles scattered over a zoom do not fit in the root directory
+	var (
+		rnd     = rand.New(rand.NewSource(1))
+		zxys    = map[uint64][3]uint32{}
+		entries []Entry
+	)
+	for len(zxys) < 200000 {
+		x, y := uint32(rnd.Intn(1<<12)), uint32(rnd.Intn(1<<12))
+		zxys[TileID(12, x, y)] = [3]uint32{12, x, y}
+	}
+	for id := range zxys {
+		// the tile data of an entry is its tile id
+		entries = append(entries, Entry{TileID: id, Length: 8, RunLength: 1})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].TileID < entries[j].TileID })
+
+	var data []byte
+	for i := range entries {
+		entries[i].Offset = uint64(len(data))
+		data = binary.LittleEndian.AppendUint64(data, entries[i].TileID)
+	}
+
+	root, leaves, err := buildDirectories(entries)
+	if err != nil {
+		t.Fatalf("build, expected nil got %v", err)
+	}
+	if len(leaves) == 0 {
+		t.Fatalf("leaves, expected some got none")
+	}
+
+	h := Header{
+		RootOffset:          HeaderLength,
+		RootLength:          uint64(len(root)),
+		LeafDirsOffset:      HeaderLength + uint64(len(root)),
+		LeafDirsLength:      uint64(len(leaves)),
+		TileDataOffset:      HeaderLength + uint64(len(root)+len(leaves)),
+		TileDataLength:      uint64(len(data)),
+		InternalCompression: CompressionGzip,
+		TileCompression:     CompressionNone,
+		TileType:            TileTypeMVT,
+		MinZoom:             12,
+		MaxZoom:             12,
+	}
+	archive, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal header, expected nil got %v", err)
+	}
+	archive = append(append(append(archive, root...), leaves...), data...)
+
+	rd, err := NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("new reader, expected nil got %v", err)
+	}
+	for _, i := range []int{0, 4095, 4096, 123457, len(entries) - 1} {
+		zxy := zxys[entries[i].TileID]
+		b, err := rd.Tile(uint8(zxy[0]), zxy[1], zxy[2])
+		if err != nil {
+			t.Fatalf("tile %v, expected nil got %v", zxy, err)
+		}
+		if len(b) != 8 || binary.LittleEndian.Uint64(b) != entries[i].TileID {
+			t.Errorf("tile %v, expected the data of %v got %v", zxy, entries[i].TileID, b)
+		}
+	}
+}
+
+func TestNewReaderInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":    nil,
+		"no magic": bytes.Repeat([]byte{0}, HeaderLength),
+	}
+
+	for name, b := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewReader(bytes.NewReader(b)); !errors.Is(err, ErrInvalidMagic) {
+				t.Errorf("expected %v got %v", ErrInvalidMagic, err)
+			}
+		})
+	}
+}
+
+func TestReaderCompression(t *testing.T) {
+	type tcase struct {
+		// internal is the compression of the directories and tiles the one of the tiles
+		internal Compression
+		tiles    Compression
+		err      error
+	}
+
+	const data = "tile data"
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			tile, err := compress([]byte(data), tc.tiles)
+			if err != nil {
+				// the tiles of unsupported compressions are written as is
+				tile = []byte(data)
+			}
+			root, err := serializeEntries([]Entry{{TileID: 0, Length: uint32(len(tile)), RunLength: 1}}, tc.internal)
+			if err != nil {
+				t.Fatalf("serialize, expected nil got %v", err)
+			}
+
+			h := Header{
+				RootOffset:          HeaderLength,
+				RootLength:          uint64(len(root)),
+				TileDataOffset:      HeaderLength + uint64(len(root)),
+				TileDataLength:      uint64(len(tile)),
+				InternalCompression: tc.internal,
+				TileCompression:     tc.tiles,
+				TileType:            TileTypeMVT,
+			}
+			archive, err := h.MarshalBinary()
+			if err != nil {
+				t.Fatalf("marshal header, expected nil got %v", err)
+			}
+			archive = append(append(archive, root...), tile...)
+
+			rd, err := NewReader(bytes.NewReader(archive))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("new reader, expected %v got %v", tc.err, err)
+			}
+			if tc.err != nil {
+				return
+			}
+
+			b, err := rd.Tile(0, 0, 0)
+			if err != nil {
+				t.Fatalf("tile, expected nil got %v", err)
+			}
+			if string(b) != data {
+				t.Errorf("tile, expected %q got %q", data, b)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"none":          {internal: CompressionNone, tiles: CompressionNone},
+		"gzip":          {internal: CompressionGzip, tiles: CompressionGzip},
+		"brotli":        {internal: CompressionBrotli, tiles: CompressionBrotli},
+		"unknown tiles": {internal: CompressionGzip, tiles: CompressionUnknown, err: ErrCompression},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
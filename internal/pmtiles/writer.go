@@ -0,0 +1,290 @@
+package pmtiles
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-spatial/tegola/cache"
+)
+
+// Writer writes gzipped MVT tiles to a PMTiles archive. Tiles can be written in any
+// order: they are spooled to a temporary file and written to the archive by tile id
+// on Close, so the archive is clustered. Tiles with the same content are stored once.
+type Writer struct {
+	path string
+	// tmp holds the tile data in the order it was written
+	tmp    *os.File
+	tmpLen uint64
+	// tiles are the written tiles, in the order they were written
+	tiles []tileRef
+	// contents maps the hash of the tile contents to their offset in tmp
+	contents map[[sha256.Size]byte]uint64
+}
+
+// tileRef is the location of the data of a tile in the temporary file
+type tileRef struct {
+	id     uint64
+	offset uint64
+	length uint32
+}
+
+// NewWriter creates a writer of the archive at path. The temporary file is created in
+// the directory of path.
+func NewWriter(path string) (*Writer, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		path:     path,
+		tmp:      tmp,
+		contents: map[[sha256.Size]byte]uint64{},
+	}, nil
+}
+
+// WriteTile adds the gzipped MVT data of the z/x/y tile to the archive. Writing a tile
+// twice keeps the last data.
+func (w *Writer) WriteTile(z uint8, x, y uint32, data []byte) error {
+	ref := tileRef{id: TileID(z, x, y), length: uint32(len(data))}
+
+	hash := sha256.Sum256(data)
+	if offset, ok := w.contents[hash]; ok {
+		ref.offset = offset
+		w.tiles = append(w.tiles, ref)
+		return nil
+	}
+
+	if _, err := w.tmp.Write(data); err != nil {
+		return err
+	}
+	ref.offset = w.tmpLen
+	w.tmpLen += uint64(len(data))
+	w.contents[hash] = ref.offset
+	w.tiles = append(w.tiles, ref)
+
+	return nil
+}
+
+// metadata is the JSON metadata of an archive
+type metadata struct {
+	Name         string        `json:"name"`
+	Format       string        `json:"format"`
+	Type         string        `json:"type"`
+	Attribution  string        `json:"attribution,omitempty"`
+	Version      string        `json:"version,omitempty"`
+	VectorLayers []vectorLayer `json:"vector_layers"`
+}
+
+// vectorLayer is an entry of the vector_layers metadata
+type vectorLayer struct {
+	ID          string            `json:"id"`
+	Description string            `json:"description"`
+	MinZoom     uint              `json:"minzoom"`
+	MaxZoom     uint              `json:"maxzoom"`
+	Fields      map[string]string `json:"fields"`
+}
+
+// Close writes the archive described by desc and removes the temporary file
+func (w *Writer) Close(desc cache.Tileset) (err error) {
+	defer func() {
+		w.tmp.Close()
+		os.Remove(w.tmp.Name())
+	}()
+
+	md := metadata{
+		Name:        desc.Name,
+		Format:      "pbf",
+		Type:        "overlay",
+		Attribution: desc.Attribution,
+		Version:     desc.Version,
+	}
+	for _, l := range desc.Layers {
+		fields := l.Fields
+		if fields == nil {
+			fields = map[string]string{}
+		}
+		md.VectorLayers = append(md.VectorLayers, vectorLayer{
+			ID:      l.ID,
+			MinZoom: l.MinZoom,
+			MaxZoom: l.MaxZoom,
+			Fields:  fields,
+		})
+	}
+	mdJSON, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+	if mdJSON, err = compress(mdJSON, CompressionGzip); err != nil {
+		return err
+	}
+
+	// the last write of a tile wins
+	sort.SliceStable(w.tiles, func(i, j int) bool { return w.tiles[i].id < w.tiles[j].id })
+	tiles := w.tiles[:0]
+	for i, t := range w.tiles {
+		if i+1 < len(w.tiles) && w.tiles[i+1].id == t.id {
+			continue
+		}
+		tiles = append(tiles, t)
+	}
+
+	// lay the tile contents out by tile id, and merge the runs of consecutive tiles
+	// sharing the same contents
+	var (
+		entries  []Entry
+		layout   []tileRef
+		placed   = map[uint64]uint64{}
+		dataLen  uint64
+		contents uint64
+	)
+	for _, t := range tiles {
+		offset, ok := placed[t.offset]
+		if !ok {
+			offset = dataLen
+			placed[t.offset] = offset
+			layout = append(layout, t)
+			dataLen += uint64(t.length)
+			contents++
+		}
+
+		if n := len(entries); n > 0 {
+			last := &entries[n-1]
+			if t.id == last.TileID+uint64(last.RunLength) && offset == last.Offset {
+				last.RunLength++
+				continue
+			}
+		}
+		entries = append(entries, Entry{TileID: t.id, Offset: offset, Length: t.length, RunLength: 1})
+	}
+
+	root, leaves, err := buildDirectories(entries)
+	if err != nil {
+		return err
+	}
+
+	h := Header{
+		RootOffset:          HeaderLength,
+		RootLength:          uint64(len(root)),
+		MetadataOffset:      HeaderLength + uint64(len(root)),
+		MetadataLength:      uint64(len(mdJSON)),
+		AddressedTilesCount: uint64(len(tiles)),
+		TileEntriesCount:    uint64(len(entries)),
+		TileContentsCount:   contents,
+		Clustered:           true,
+		InternalCompression: CompressionGzip,
+		TileCompression:     CompressionGzip,
+		TileType:            TileTypeMVT,
+		MinZoom:             uint8(desc.MinZoom),
+		MaxZoom:             uint8(desc.MaxZoom),
+		MinLon:              desc.Bounds[0],
+		MinLat:              desc.Bounds[1],
+		MaxLon:              desc.Bounds[2],
+		MaxLat:              desc.Bounds[3],
+		CenterLon:           desc.Center[0],
+		CenterLat:           desc.Center[1],
+		CenterZoom:          uint8(desc.Center[2]),
+	}
+	h.LeafDirsOffset = h.MetadataOffset + h.MetadataLength
+	h.LeafDirsLength = uint64(len(leaves))
+	h.TileDataOffset = h.LeafDirsOffset + h.LeafDirsLength
+	h.TileDataLength = dataLen
+
+	header, err := h.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(w.path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	out := bufio.NewWriter(f)
+	for _, b := range [][]byte{header, root, mdJSON, leaves} {
+		if _, err = out.Write(b); err != nil {
+			return err
+		}
+	}
+
+	var buf []byte
+	for _, t := range layout {
+		if cap(buf) < int(t.length) {
+			buf = make([]byte, t.length)
+		}
+		buf = buf[:t.length]
+		if _, err = w.tmp.ReadAt(buf, int64(t.offset)); err != nil && err != io.EOF {
+			return fmt.Errorf("error reading spooled tile: %w", err)
+		}
+		if _, err = out.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	return out.Flush()
+}
+
+// Abort removes the temporary file without writing the archive
+func (w *Writer) Abort() error {
+	w.tmp.Close()
+	return os.Remove(w.tmp.Name())
+}
+
+// buildDirectories serializes the entries as the root directory when it fits in
+// maxRootLength, or else into leaf directories addressed by the root directory. The leaves
+// grow until the root directory fits.
+func buildDirectories(entries []Entry) (root, leaves []byte, err error) {
+	if root, err = serializeEntries(entries, CompressionGzip); err != nil {
+		return nil, nil, err
+	}
+	if len(root) <= maxRootLength {
+		return root, nil, nil
+	}
+
+	leafSize := len(entries) / 3500
+	if leafSize < 4096 {
+		leafSize = 4096
+	}
+
+	for {
+		var rootEntries []Entry
+		leaves = leaves[:0]
+
+		for i := 0; i < len(entries); i += leafSize {
+			end := i + leafSize
+			if end > len(entries) {
+				end = len(entries)
+			}
+
+			leaf, err := serializeEntries(entries[i:end], CompressionGzip)
+			if err != nil {
+				return nil, nil, err
+			}
+			rootEntries = append(rootEntries, Entry{
+				TileID: entries[i].TileID,
+				Offset: uint64(len(leaves)),
+				Length: uint32(len(leaf)),
+			})
+			leaves = append(leaves, leaf...)
+		}
+
+		if root, err = serializeEntries(rootEntries, CompressionGzip); err != nil {
+			return nil, nil, err
+		}
+		if len(root) <= maxRootLength {
+			return root, leaves, nil
+		}
+		leafSize += leafSize / 5
+	}
+}
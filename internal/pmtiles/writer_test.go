@@ -0,0 +1,184 @@
+package pmtiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-spatial/tegola/cache"
+)
+
+// readTile looks up the data of a tile in an archive
+func readTile(t *testing.T, archive []byte, h Header, z uint8, x, y uint32) ([]byte, bool) {
+	t.Helper()
+
+	id := TileID(z, x, y)
+	dir := archive[h.RootOffset : h.RootOffset+h.RootLength]
+	for depth := 0; depth < 4; depth++ {
+		entries, err := deserializeEntries(dir, h.InternalCompression)
+		if err != nil {
+			t.Fatalf("deserialize directory, expected nil got %v", err)
+		}
+
+		e, ok := findEntry(entries, id)
+		if !ok {
+			return nil, false
+		}
+		if e.RunLength > 0 {
+			start := h.TileDataOffset + e.Offset
+			return archive[start : start+uint64(e.Length)], true
+		}
+
+		start := h.LeafDirsOffset + e.Offset
+		dir = archive[start : start+uint64(e.Length)]
+	}
+
+	t.Fatalf("directories nested too deep")
+	return nil, false
+}
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "osm.pmtiles")
+
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatalf("new writer, expected nil got %v", err)
+	}
+
+	tiles := map[[3]uint32][]byte{
+		{0, 0, 0}: []byte("root"),
+		{1, 0, 0}: []byte("ocean"),
+		{1, 0, 1}: []byte("ocean"),
+		{1, 1, 1}: []byte("land"),
+		{1, 1, 0}: []byte("ocean"),
+		{2, 3, 3}: []byte("ocean"),
+	}
+	for zxy, data := range tiles {
+		if err = w.WriteTile(uint8(zxy[0]), zxy[1], zxy[2], data); err != nil {
+			t.Fatalf("write tile, expected nil got %v", err)
+		}
+	}
+	// rewriting a tile keeps the last data
+	tiles[[3]uint32{0, 0, 0}] = []byte("new root")
+	if err = w.WriteTile(0, 0, 0, []byte("new root")); err != nil {
+		t.Fatalf("write tile, expected nil got %v", err)
+	}
+
+	desc := cache.Tileset{
+		Name:    "osm",
+		Bounds:  [4]float64{-180, -85.0511, 180, 85.0511},
+		Center:  [3]float64{0, 0, 1},
+		MaxZoom: 2,
+		Layers:  []cache.TilesetLayer{{ID: "roads", MaxZoom: 2}},
+	}
+	if err = w.Close(desc); err != nil {
+		t.Fatalf("close, expected nil got %v", err)
+	}
+
+	// the temporary file is removed
+	if matches, _ := filepath.Glob(path + ".*.tmp"); len(matches) != 0 {
+		t.Errorf("temporary files, expected none got %v", matches)
+	}
+
+	archive, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read archive, expected nil got %v", err)
+	}
+	var h Header
+	if err = h.UnmarshalBinary(archive); err != nil {
+		t.Fatalf("read header, expected nil got %v", err)
+	}
+
+	if h.AddressedTilesCount != 6 || h.TileContentsCount != 3 {
+		t.Errorf("addressed tiles and contents, expected 6 3 got %v %v", h.AddressedTilesCount, h.TileContentsCount)
+	}
+	// 1/0/1 and 1/1/1 are not consecutive with the same contents, 1/0/0 and 1/0/1 are
+	if h.TileEntriesCount != 5 {
+		t.Errorf("tile entries, expected 5 got %v", h.TileEntriesCount)
+	}
+	if !h.Clustered || h.MaxZoom != 2 || h.CenterZoom != 1 {
+		t.Errorf("unexpected header %+v", h)
+	}
+
+	for zxy, expected := range tiles {
+		data, ok := readTile(t, archive, h, uint8(zxy[0]), zxy[1], zxy[2])
+		if !ok || !bytes.Equal(data, expected) {
+			t.Errorf("tile %v, expected %s got %s (%v)", zxy, expected, data, ok)
+		}
+	}
+	if _, ok := readTile(t, archive, h, 2, 0, 0); ok {
+		t.Errorf("tile 2/0/0, expected a miss")
+	}
+
+	raw, err := decompress(archive[h.MetadataOffset:h.MetadataOffset+h.MetadataLength], h.InternalCompression)
+	if err != nil {
+		t.Fatalf("read metadata, expected nil got %v", err)
+	}
+	var md metadata
+	if err = json.Unmarshal(raw, &md); err != nil {
+		t.Fatalf("decode metadata, expected nil got %v", err)
+	}
+	if md.Name != "osm" || len(md.VectorLayers) != 1 || md.VectorLayers[0].ID != "roads" {
+		t.Errorf("unexpected metadata %+v", md)
+	}
+}
+
+func TestBuildDirectoriesLeaves(t *testing.T) {
+	// entries with scattered ids and offsets do not fit in the root directory
+	var (
+		rnd     = rand.New(rand.NewSource(1))
+		entries = make([]Entry, 200000)
+		id      uint64
+	)
+	for i := range entries {
+		id += 1 + uint64(rnd.Intn(1000))
+		entries[i] = Entry{
+			TileID:    id,
+			Offset:    uint64(rnd.Int63n(1 << 40)),
+			Length:    uint32(rnd.Intn(1 << 20)),
+			RunLength: 1,
+		}
+	}
+
+	root, leaves, err := buildDirectories(entries)
+	if err != nil {
+		t.Fatalf("build, expected nil got %v", err)
+	}
+	if len(root) > maxRootLength || len(leaves) == 0 {
+		t.Fatalf("root and leaves, expected at most %v and some got %v %v", maxRootLength, len(root), len(leaves))
+	}
+
+	h := Header{
+		RootOffset:          0,
+		RootLength:          uint64(len(root)),
+		LeafDirsOffset:      uint64(len(root)),
+		InternalCompression: CompressionGzip,
+	}
+	archive := append(append([]byte{}, root...), leaves...)
+
+	for _, i := range []int{0, 4095, 4096, 123457, len(entries) - 1} {
+		id := entries[i].TileID
+
+		dir := archive[h.RootOffset : h.RootOffset+h.RootLength]
+		rootEntries, err := deserializeEntries(dir, h.InternalCompression)
+		if err != nil {
+			t.Fatalf("deserialize root, expected nil got %v", err)
+		}
+		leaf, ok := findEntry(rootEntries, id)
+		if !ok || leaf.RunLength != 0 {
+			t.Fatalf("entry %v, expected a leaf got %v %v", i, leaf, ok)
+		}
+
+		start := h.LeafDirsOffset + leaf.Offset
+		leafEntries, err := deserializeEntries(archive[start:start+uint64(leaf.Length)], h.InternalCompression)
+		if err != nil {
+			t.Fatalf("deserialize leaf, expected nil got %v", err)
+		}
+		if e, ok := findEntry(leafEntries, id); !ok || e != entries[i] {
+			t.Errorf("entry %v, expected %v got %v %v", i, entries[i], e, ok)
+		}
+	}
+}
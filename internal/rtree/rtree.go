@@ -0,0 +1,148 @@
+// Package rtree implements a static R-tree for finding the items whose extents intersect
+// a search extent. The tree is bulk loaded with the Sort-Tile-Recursive algorithm, so it's
+// built once for a set of items and rebuilt when they change.
+package rtree
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultNodeSize is the max number of entries of a node
+const DefaultNodeSize = 16
+
+// Extent is a bounding box in the format: minx, miny, maxx, maxy
+type Extent [4]float64
+
+// Intersects reports whether the extents overlap. Touching extents intersect.
+func (e Extent) Intersects(o Extent) bool {
+	return e[0] <= o[2] && o[0] <= e[2] && e[1] <= o[3] && o[1] <= e[3]
+}
+
+// expand grows the extent to contain o
+func (e *Extent) expand(o Extent) {
+	e[0] = math.Min(e[0], o[0])
+	e[1] = math.Min(e[1], o[1])
+	e[2] = math.Max(e[2], o[2])
+	e[3] = math.Max(e[3], o[3])
+}
+
+// node is a node of the tree. The entries of a leaf are item indexes, and those of
+// the other nodes are node indexes
+type node struct {
+	extent  Extent
+	leaf    bool
+	entries []int
+}
+
+// RTree indexes items by their extents. The items are referenced by their index in the
+// slice of extents the tree is built from.
+type RTree struct {
+	items []Extent
+	nodes []node
+	root  int
+}
+
+// entry is an item or node being packed into a parent node
+type entry struct {
+	extent Extent
+	index  int
+}
+
+// New builds a tree of the extents, with at most nodeSize entries per node. A nodeSize
+// less than 2 uses DefaultNodeSize.
+func New(extents []Extent, nodeSize int) *RTree {
+	if nodeSize < 2 {
+		nodeSize = DefaultNodeSize
+	}
+
+	t := &RTree{items: append([]Extent(nil), extents...), root: -1}
+	if len(extents) == 0 {
+		return t
+	}
+
+	entries := make([]entry, len(extents))
+	for i, e := range extents {
+		entries[i] = entry{extent: e, index: i}
+	}
+
+	leaf := true
+	for {
+		entries = t.pack(entries, nodeSize, leaf)
+		leaf = false
+		if len(entries) == 1 {
+			t.root = entries[0].index
+			return t
+		}
+	}
+}
+
+// pack groups the entries into nodes using Sort-Tile-Recursive: the entries are sorted
+// by x into vertical slices, and each slice is sorted by y and cut into nodes. It returns
+// the entries of the new nodes.
+func (t *RTree) pack(entries []entry, nodeSize int, leaf bool) []entry {
+	nodeCount := (len(entries) + nodeSize - 1) / nodeSize
+	sliceCount := int(math.Ceil(math.Sqrt(float64(nodeCount))))
+	sliceSize := sliceCount * nodeSize
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].extent[0]+entries[i].extent[2] < entries[j].extent[0]+entries[j].extent[2]
+	})
+
+	parents := make([]entry, 0, nodeCount)
+	for s := 0; s < len(entries); s += sliceSize {
+		slice := entries[s:min(s+sliceSize, len(entries))]
+		sort.Slice(slice, func(i, j int) bool {
+			return slice[i].extent[1]+slice[i].extent[3] < slice[j].extent[1]+slice[j].extent[3]
+		})
+
+		for n := 0; n < len(slice); n += nodeSize {
+			children := slice[n:min(n+nodeSize, len(slice))]
+
+			nd := node{extent: children[0].extent, leaf: leaf, entries: make([]int, len(children))}
+			for i, c := range children {
+				nd.extent.expand(c.extent)
+				nd.entries[i] = c.index
+			}
+			t.nodes = append(t.nodes, nd)
+			parents = append(parents, entry{extent: nd.extent, index: len(t.nodes) - 1})
+		}
+	}
+	return parents
+}
+
+// Len returns the number of items in the tree
+func (t *RTree) Len() int {
+	return len(t.items)
+}
+
+// Search returns the indexes of the items intersecting the extent, in increasing order
+func (t *RTree) Search(extent Extent) []int {
+	if t.root < 0 {
+		return nil
+	}
+
+	var (
+		items []int
+		stack = []int{t.root}
+	)
+	for len(stack) > 0 {
+		nd := &t.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !nd.extent.Intersects(extent) {
+			continue
+		}
+		if !nd.leaf {
+			stack = append(stack, nd.entries...)
+			continue
+		}
+		for _, i := range nd.entries {
+			if t.items[i].Intersects(extent) {
+				items = append(items, i)
+			}
+		}
+	}
+
+	sort.Ints(items)
+	return items
+}
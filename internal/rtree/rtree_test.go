@@ -0,0 +1,85 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	type tcase struct {
+		items    int
+		nodeSize int
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			r := rand.New(rand.NewSource(int64(tc.items)))
+			randExtent := func(maxSize float64) Extent {
+				x, y := r.Float64()*1000, r.Float64()*1000
+				return Extent{x, y, x + r.Float64()*maxSize, y + r.Float64()*maxSize}
+			}
+
+			extents := make([]Extent, tc.items)
+			for i := range extents {
+				extents[i] = randExtent(20)
+			}
+			tree := New(extents, tc.nodeSize)
+			if tree.Len() != tc.items {
+				t.Errorf("len, expected %v got %v", tc.items, tree.Len())
+			}
+
+			for q := 0; q < 50; q++ {
+				search := randExtent(200)
+
+				var expected []int
+				for i, e := range extents {
+					if e.Intersects(search) {
+						expected = append(expected, i)
+					}
+				}
+
+				if got := tree.Search(search); !reflect.DeepEqual(got, expected) {
+					t.Fatalf("search %v, expected %v got %v", search, expected, got)
+				}
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"empty":            {items: 0},
+		"single":           {items: 1},
+		"single node":      {items: 10},
+		"default":          {items: 1000},
+		"small nodes":      {items: 500, nodeSize: 2},
+		"partial last row": {items: 333, nodeSize: 5},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestIntersects(t *testing.T) {
+	e := Extent{0, 0, 10, 10}
+
+	tests := map[string]struct {
+		o          Extent
+		intersects bool
+	}{
+		"inside":   {o: Extent{2, 2, 3, 3}, intersects: true},
+		"contains": {o: Extent{-1, -1, 11, 11}, intersects: true},
+		"touching": {o: Extent{10, 10, 12, 12}, intersects: true},
+		"point":    {o: Extent{5, 5, 5, 5}, intersects: true},
+		"left":     {o: Extent{-5, 0, -1, 10}},
+		"above":    {o: Extent{0, 11, 10, 12}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := e.Intersects(tc.o); got != tc.intersects {
+				t.Errorf("expected %v got %v", tc.intersects, got)
+			}
+		})
+	}
+}
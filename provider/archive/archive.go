@@ -0,0 +1,162 @@
+// Package archive implements the mvt_mbtiles and mvt_pmtiles providers, which serve the
+// tiles of pre-built MBTiles and PMTiles vector tilesets. The stored tiles are filtered
+// to the layers of the map, and the tiles of the max zoom of an archive are overzoomed
+// to serve the zooms past it.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/go-spatial/geom"
+	vectorTile "github.com/go-spatial/geom/encoding/mvt/vector_tile"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/provider"
+	"github.com/golang/protobuf/proto"
+)
+
+// the names of the providers, which are registered as mvt_mbtiles and mvt_pmtiles
+const (
+	NameMBTiles = "mbtiles"
+	NamePMTiles = "pmtiles"
+)
+
+// config keys
+const (
+	ConfigKeyFilePath = "filepath"
+	ConfigKeyOverzoom = "overzoom"
+)
+
+// tileset is the storage of the tiles of an archive
+type tileset interface {
+	// tile returns the data of the z/x/y tile, which may be gzipped, or nil when the
+	// archive doesn't have the tile
+	tile(ctx context.Context, z, x, y uint) ([]byte, error)
+	close() error
+}
+
+// tilesetInfo is the metadata of an archive
+type tilesetInfo struct {
+	// metadata is the TileJSON like metadata of the archive, with its vector_layers
+	metadata         []byte
+	minZoom, maxZoom uint
+}
+
+type Layer struct {
+	name     string
+	geomType geom.Geometry
+}
+
+func (l Layer) Name() string            { return l.name }
+func (l Layer) GeomType() geom.Geometry { return l.geomType }
+func (l Layer) SRID() uint64            { return tegola.WebMercator }
+
+type Provider struct {
+	// Filepath is the archive file
+	Filepath string
+	tileset  tileset
+	// layers are the vector layers of the archive, in the order of its metadata
+	layers  []Layer
+	minZoom uint
+	maxZoom uint
+	// overzoom is true when the tiles of maxZoom are overzoomed to serve the zooms past it
+	overzoom bool
+}
+
+func (p *Provider) Layers() ([]provider.LayerInfo, error) {
+	ls := make([]provider.LayerInfo, 0, len(p.layers))
+	for _, l := range p.layers {
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
+
+// MVTForLayers returns the stored tile with only the requested layers, renamed to their
+// MVT names. The tiles of the zooms past the max zoom of the archive are cut from the
+// tile of the max zoom which contains them. Query parameters are not supported.
+func (p *Provider) MVTForLayers(ctx context.Context, tile provider.Tile, _ provider.Params, layers []provider.Layer) ([]byte, error) {
+	z, x, y := tile.ZXY()
+	zoom := uint(z)
+
+	// dz is the number of zooms the stored tile is overzoomed
+	var dz uint
+	switch {
+	case zoom < p.minZoom:
+		return nil, nil
+	case zoom > p.maxZoom:
+		if !p.overzoom {
+			return nil, nil
+		}
+		dz = zoom - p.maxZoom
+	}
+
+	data, err := p.tileset.tile(ctx, zoom-dz, x>>dz, y>>dz)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tile (%v/%v/%v) from %v: %w", zoom-dz, x>>dz, y>>dz, p.Filepath, err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	// tiles are usually stored gzipped
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("error decompressing tile (%v/%v/%v) from %v: %w", zoom-dz, x>>dz, y>>dz, p.Filepath, err)
+		}
+	}
+
+	var stored vectorTile.Tile
+	if err = proto.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("error decoding tile (%v/%v/%v) from %v: %w", zoom-dz, x>>dz, y>>dz, p.Filepath, err)
+	}
+	storedLayers := make(map[string]*vectorTile.Tile_Layer, len(stored.Layers))
+	for _, l := range stored.Layers {
+		storedLayers[l.GetName()] = l
+	}
+
+	var mvtTile vectorTile.Tile
+	for _, l := range layers {
+		sl, ok := storedLayers[l.Name]
+		if !ok {
+			continue
+		}
+
+		name := l.MVTName
+		if name == "" {
+			name = l.Name
+		}
+		layer := &vectorTile.Tile_Layer{
+			Version:  sl.Version,
+			Name:     &name,
+			Features: sl.Features,
+			Keys:     sl.Keys,
+			Values:   sl.Values,
+			Extent:   sl.Extent,
+		}
+		if dz > 0 {
+			// the position of the tile in the children of the stored tile
+			mask := uint(1)<<dz - 1
+			if layer.Features = overzoom(sl.Features, sl.GetExtent(), dz, x&mask, y&mask); len(layer.Features) == 0 {
+				continue
+			}
+		}
+		mvtTile.Layers = append(mvtTile.Layers, layer)
+	}
+
+	if len(mvtTile.Layers) == 0 {
+		return nil, nil
+	}
+	return proto.Marshal(&mvtTile)
+}
+
+// Close closes the archive
+func (p *Provider) Close() error {
+	return p.tileset.close()
+}
@@ -0,0 +1,321 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	vectorTile "github.com/go-spatial/geom/encoding/mvt/vector_tile"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/cache"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/pmtiles"
+	"github.com/go-spatial/tegola/provider"
+	"github.com/golang/protobuf/proto"
+)
+
+// testTileset describes the archives of the tests, whose max zoom is 1
+var testTileset = cache.Tileset{
+	Name:    "test",
+	MaxZoom: 1,
+	Layers: []cache.TilesetLayer{
+		{ID: "pois", MaxZoom: 1},
+		{ID: "roads", MaxZoom: 1},
+		{ID: "water", MaxZoom: 1},
+	},
+}
+
+// testTile returns the gzipped 1/0/0 tile of the test archives
+func testTile(t *testing.T) []byte {
+	t.Helper()
+
+	layer := func(name string, f *vectorTile.Tile_Feature) *vectorTile.Tile_Layer {
+		var (
+			version = uint32(2)
+			extent  = uint32(4096)
+			key     = "name"
+			value   = name
+		)
+		return &vectorTile.Tile_Layer{
+			Version:  &version,
+			Name:     &name,
+			Features: []*vectorTile.Tile_Feature{f},
+			Keys:     []string{key},
+			Values:   []*vectorTile.Tile_Value{{StringValue: &value}},
+			Extent:   &extent,
+		}
+	}
+
+	mvtTile := vectorTile.Tile{Layers: []*vectorTile.Tile_Layer{
+		layer("pois", feature(vectorTile.Tile_POINT, []point{{100, 100}, {3000, 3000}})),
+		layer("roads", feature(vectorTile.Tile_LINESTRING, []point{{0, 2048}, {4096, 2048}})),
+		layer("water", feature(vectorTile.Tile_POLYGON, square(2500, 4000, true))),
+	}}
+	b, err := proto.Marshal(&mvtTile)
+	if err != nil {
+		t.Fatalf("marshal, expected nil got %v", err)
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err = w.Write(b); err != nil {
+		t.Fatalf("gzip, expected nil got %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("gzip, expected nil got %v", err)
+	}
+	return buf.Bytes()
+}
+
+// writePMTiles writes a PMTiles archive with the test tile
+func writePMTiles(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.pmtiles")
+	w, err := pmtiles.NewWriter(path)
+	if err != nil {
+		t.Fatalf("new writer, expected nil got %v", err)
+	}
+	if err = w.WriteTile(1, 0, 0, testTile(t)); err != nil {
+		t.Fatalf("write tile, expected nil got %v", err)
+	}
+	if err = w.Close(testTileset); err != nil {
+		t.Fatalf("close, expected nil got %v", err)
+	}
+	return path
+}
+
+// testMVTForLayers checks the tiles of a provider of a test archive
+func testMVTForLayers(t *testing.T, p provider.MVTTiler) {
+	type tcase struct {
+		tile   provider.Tile
+		layers []provider.Layer
+		// expected are the names of the layers and the parts of the geometries of their
+		// features
+		expected map[string][][]point
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			b, err := p.MVTForLayers(context.Background(), tc.tile, nil, tc.layers)
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if tc.expected == nil {
+				if len(b) != 0 {
+					t.Fatalf("expected an empty tile got %v bytes", len(b))
+				}
+				return
+			}
+
+			var mvtTile vectorTile.Tile
+			if err = proto.Unmarshal(b, &mvtTile); err != nil {
+				t.Fatalf("unmarshal, expected nil got %v", err)
+			}
+			got := map[string][][]point{}
+			for _, l := range mvtTile.Layers {
+				if len(l.Features) != 1 || len(l.Values) != 1 {
+					t.Errorf("layer %v, expected 1 feature and value got %v %v", l.GetName(), len(l.Features), len(l.Values))
+					continue
+				}
+				got[l.GetName()] = decodeGeometry(l.Features[0].Geometry)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, got)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"filtered and renamed": {
+			tile: provider.NewTile(1, 0, 0, 64, tegola.WebMercator),
+			layers: []provider.Layer{
+				{Name: "roads", MVTName: "streets"},
+				{Name: "pois", MVTName: "pois"},
+			},
+			expected: map[string][][]point{
+				"streets": {{{0, 2048}, {4096, 2048}}},
+				"pois":    {{{100, 100}}, {{3000, 3000}}},
+			},
+		},
+		"overzoom": {
+			tile: provider.NewTile(2, 0, 0, 64, tegola.WebMercator),
+			layers: []provider.Layer{
+				{Name: "pois", MVTName: "pois"},
+				{Name: "roads", MVTName: "roads"},
+				{Name: "water", MVTName: "water"},
+			},
+			// the water is outside of the tile
+			expected: map[string][][]point{
+				"pois":  {{{200, 200}}},
+				"roads": {{{0, 4096}, {4160, 4096}}},
+			},
+		},
+		"overzoom 2 zooms": {
+			tile:   provider.NewTile(3, 3, 3, 64, tegola.WebMercator),
+			layers: []provider.Layer{{Name: "water", MVTName: "water"}},
+			expected: map[string][][]point{
+				"water": {{{-64, -64}, {3712, -64}, {3712, 3712}, {-64, 3712}}},
+			},
+		},
+		"missing tile": {
+			tile:   provider.NewTile(1, 1, 1, 64, tegola.WebMercator),
+			layers: []provider.Layer{{Name: "roads", MVTName: "roads"}},
+		},
+		"missing layer": {
+			tile:   provider.NewTile(1, 0, 0, 64, tegola.WebMercator),
+			layers: []provider.Layer{{Name: "rail", MVTName: "rail"}},
+		},
+		"below min zoom": {
+			tile:   provider.NewTile(0, 0, 0, 64, tegola.WebMercator),
+			layers: []provider.Layer{{Name: "roads", MVTName: "roads"}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestPMTilesProvider(t *testing.T) {
+	path := writePMTiles(t)
+
+	p, err := NewPMTilesProvider(dict.Dict{ConfigKeyFilePath: path}, nil)
+	if err != nil {
+		t.Fatalf("new provider, expected nil got %v", err)
+	}
+	defer p.(*Provider).Close()
+
+	layers, err := p.Layers()
+	if err != nil {
+		t.Fatalf("layers, expected nil got %v", err)
+	}
+	var names []string
+	for _, l := range layers {
+		names = append(names, l.Name())
+		if l.SRID() != tegola.WebMercator {
+			t.Errorf("srid of %v, expected %v got %v", l.Name(), tegola.WebMercator, l.SRID())
+		}
+	}
+	if expected := []string{"pois", "roads", "water"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("layers, expected %v got %v", expected, names)
+	}
+
+	testMVTForLayers(t, p)
+}
+
+func TestPMTilesProviderNoOverzoom(t *testing.T) {
+	path := writePMTiles(t)
+
+	p, err := NewPMTilesProvider(dict.Dict{ConfigKeyFilePath: path, ConfigKeyOverzoom: false}, nil)
+	if err != nil {
+		t.Fatalf("new provider, expected nil got %v", err)
+	}
+	defer p.(*Provider).Close()
+
+	b, err := p.MVTForLayers(context.Background(), provider.NewTile(2, 0, 0, 64, tegola.WebMercator), nil, []provider.Layer{{Name: "roads", MVTName: "roads"}})
+	if err != nil || len(b) != 0 {
+		t.Errorf("expected an empty tile got %v bytes and %v", len(b), err)
+	}
+}
+
+func TestNewPMTilesProviderErrors(t *testing.T) {
+	type tcase struct {
+		config dict.Dict
+		err    error
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			_, err := NewPMTilesProvider(tc.config, nil)
+			if !errors.Is(err, tc.err) {
+				t.Errorf("expected %v got %v", tc.err, err)
+			}
+		}
+	}
+
+	var (
+		dir      = t.TempDir()
+		missing  = filepath.Join(dir, "missing.pmtiles")
+		notTiles = filepath.Join(dir, "tiles.txt")
+	)
+	if err := os.WriteFile(notTiles, []byte("not an archive"), 0644); err != nil {
+		t.Fatalf("write, expected nil got %v", err)
+	}
+
+	tests := map[string]tcase{
+		"missing filepath": {
+			config: dict.Dict{},
+			err:    ErrInvalidFilePath{},
+		},
+		"missing file": {
+			config: dict.Dict{ConfigKeyFilePath: missing},
+			err:    ErrInvalidFilePath{missing},
+		},
+		"not an archive": {
+			config: dict.Dict{ConfigKeyFilePath: notTiles},
+			err:    pmtiles.ErrInvalidMagic,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestLayersOf(t *testing.T) {
+	type tcase struct {
+		metadata string
+		expected []Layer
+		err      error
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			layers, err := layersOf([]byte(tc.metadata))
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("expected %v got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if !reflect.DeepEqual(layers, tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, layers)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"vector layers": {
+			metadata: `{"vector_layers": [{"id": "roads", "fields": {}}, {"id": "water"}]}`,
+			expected: []Layer{{name: "roads"}, {name: "water"}},
+		},
+		"tilestats": {
+			metadata: `{
+				"vector_layers": [{"id": "roads"}, {"id": "water"}, {"id": "pois"}],
+				"tilestats": {"layers": [
+					{"layer": "water", "geometry": "Polygon"},
+					{"layer": "pois", "geometry": "Point"}
+				]}
+			}`,
+			expected: []Layer{{name: "roads"}, {name: "water", geomType: geom.Polygon{}}, {name: "pois", geomType: geom.Point{}}},
+		},
+		"no vector layers": {
+			metadata: `{"name": "raster"}`,
+			err:      ErrNoVectorLayers,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
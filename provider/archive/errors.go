@@ -0,0 +1,30 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoVectorLayers = errors.New("archive: metadata has no vector_layers")
+	ErrNotVectorTiles = errors.New("archive: tiles are not vector tiles")
+)
+
+type ErrInvalidFilePath struct {
+	FilePath string
+}
+
+func (e ErrInvalidFilePath) Error() string {
+	return fmt.Sprintf("archive: invalid filepath: %v", e.FilePath)
+}
+
+// ErrInvalidMetadata is returned when the metadata of an archive can't be decoded
+type ErrInvalidMetadata struct {
+	Err error
+}
+
+func (e ErrInvalidMetadata) Error() string {
+	return fmt.Sprintf("archive: invalid metadata: %v", e.Err)
+}
+
+func (e ErrInvalidMetadata) Unwrap() error { return e.Err }
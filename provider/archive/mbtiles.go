@@ -0,0 +1,99 @@
+//go:build cgo
+// +build cgo
+
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// mbtilesTileset is an MBTiles archive
+type mbtilesTileset struct {
+	db *sql.DB
+}
+
+// openMBTiles opens an MBTiles archive of vector tiles read only
+func openMBTiles(filename string) (_ tileset, _ tilesetInfo, err error) {
+	// sqlite creates missing files, even read only
+	if _, err := os.Stat(filename); err != nil {
+		return nil, tilesetInfo{}, ErrInvalidFilePath{filename}
+	}
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", filename))
+	if err != nil {
+		return nil, tilesetInfo{}, err
+	}
+	defer func() {
+		if err != nil {
+			db.Close()
+		}
+	}()
+
+	rows, err := db.Query("SELECT name, value FROM metadata")
+	if err != nil {
+		return nil, tilesetInfo{}, fmt.Errorf("error reading the metadata of %v: %w", filename, err)
+	}
+	md := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err = rows.Scan(&name, &value); err != nil {
+			rows.Close()
+			return nil, tilesetInfo{}, err
+		}
+		md[name] = value
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, tilesetInfo{}, err
+	}
+
+	if format, ok := md["format"]; ok && format != "pbf" {
+		return nil, tilesetInfo{}, ErrNotVectorTiles
+	}
+
+	info := tilesetInfo{metadata: []byte(md["json"])}
+	if len(info.metadata) == 0 {
+		return nil, tilesetInfo{}, ErrNoVectorLayers
+	}
+
+	// the zoom range defaults to the zooms of the tiles
+	minZoom, minErr := strconv.ParseUint(md["minzoom"], 10, 8)
+	maxZoom, maxErr := strconv.ParseUint(md["maxzoom"], 10, 8)
+	if minErr != nil || maxErr != nil {
+		var min, max sql.NullInt64
+		if err = db.QueryRow("SELECT MIN(zoom_level), MAX(zoom_level) FROM tiles").Scan(&min, &max); err != nil {
+			return nil, tilesetInfo{}, fmt.Errorf("error reading the zooms of %v: %w", filename, err)
+		}
+		if !max.Valid {
+			return nil, tilesetInfo{}, fmt.Errorf("%v has no tiles", filename)
+		}
+		minZoom, maxZoom = uint64(min.Int64), uint64(max.Int64)
+	}
+	info.minZoom, info.maxZoom = uint(minZoom), uint(maxZoom)
+
+	return &mbtilesTileset{db: db}, info, nil
+}
+
+func (ts *mbtilesTileset) tile(ctx context.Context, z, x, y uint) ([]byte, error) {
+	var data []byte
+	// MBTiles rows are numbered from the bottom, as in TMS
+	err := ts.db.QueryRowContext(ctx,
+		"SELECT tile_data FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?",
+		z, x, (uint(1)<<z)-1-y,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (ts *mbtilesTileset) close() error {
+	return ts.db.Close()
+}
@@ -0,0 +1,11 @@
+//go:build !cgo
+// +build !cgo
+
+package archive
+
+import "github.com/go-spatial/tegola/provider"
+
+// openMBTiles reports MBTiles archives are not supported, as SQLite requires cgo
+func openMBTiles(_ string) (tileset, tilesetInfo, error) {
+	return nil, tilesetInfo{}, provider.ErrUnsupported
+}
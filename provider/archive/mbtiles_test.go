@@ -0,0 +1,50 @@
+//go:build cgo
+// +build cgo
+
+package archive
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-spatial/tegola/cache/mbtiles"
+	"github.com/go-spatial/tegola/dict"
+)
+
+func TestMBTilesProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mbtiles")
+
+	w, err := mbtiles.NewWriter(path)
+	if err != nil {
+		t.Fatalf("new writer, expected nil got %v", err)
+	}
+	if err = w.WriteTile(context.Background(), 1, 0, 0, testTile(t)); err != nil {
+		t.Fatalf("write tile, expected nil got %v", err)
+	}
+	if err = w.Close(context.Background(), testTileset); err != nil {
+		t.Fatalf("close, expected nil got %v", err)
+	}
+
+	p, err := NewMBTilesProvider(dict.Dict{ConfigKeyFilePath: path}, nil)
+	if err != nil {
+		t.Fatalf("new provider, expected nil got %v", err)
+	}
+	defer p.(*Provider).Close()
+
+	layers, err := p.Layers()
+	if err != nil || len(layers) != 3 {
+		t.Fatalf("layers, expected 3 got %v %v", len(layers), err)
+	}
+
+	testMVTForLayers(t, p)
+}
+
+func TestNewMBTilesProviderMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.mbtiles")
+
+	_, err := NewMBTilesProvider(dict.Dict{ConfigKeyFilePath: path}, nil)
+	if err != (ErrInvalidFilePath{path}) {
+		t.Errorf("expected %v got %v", ErrInvalidFilePath{path}, err)
+	}
+}
@@ -0,0 +1,53 @@
+package archive
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/go-spatial/geom"
+)
+
+// metadata is the part of the TileJSON like metadata of an archive describing its layers
+type metadata struct {
+	VectorLayers []struct {
+		ID string `json:"id"`
+	} `json:"vector_layers"`
+	// Tilestats are the statistics written by tippecanoe, which have the geometry type
+	// of the layers
+	Tilestats struct {
+		Layers []struct {
+			Layer    string `json:"layer"`
+			Geometry string `json:"geometry"`
+		} `json:"layers"`
+	} `json:"tilestats"`
+}
+
+// layersOf returns the layers of the vector_layers of the metadata of an archive. The
+// geometry type of the layers is read from the tilestats when present.
+func layersOf(b []byte) ([]Layer, error) {
+	var md metadata
+	if err := json.Unmarshal(b, &md); err != nil {
+		return nil, ErrInvalidMetadata{Err: err}
+	}
+	if len(md.VectorLayers) == 0 {
+		return nil, ErrNoVectorLayers
+	}
+
+	geomTypes := make(map[string]geom.Geometry, len(md.Tilestats.Layers))
+	for _, l := range md.Tilestats.Layers {
+		switch strings.ToLower(l.Geometry) {
+		case "point":
+			geomTypes[l.Layer] = geom.Point{}
+		case "linestring":
+			geomTypes[l.Layer] = geom.LineString{}
+		case "polygon":
+			geomTypes[l.Layer] = geom.Polygon{}
+		}
+	}
+
+	layers := make([]Layer, 0, len(md.VectorLayers))
+	for _, vl := range md.VectorLayers {
+		layers = append(layers, Layer{name: vl.ID, geomType: geomTypes[vl.ID]})
+	}
+	return layers, nil
+}
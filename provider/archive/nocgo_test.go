@@ -0,0 +1,19 @@
+//go:build !cgo
+// +build !cgo
+
+package archive
+
+import (
+	"testing"
+
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// MBTiles archives are not supported without cgo
+func TestNewMBTilesProviderNoCgo(t *testing.T) {
+	_, err := NewMBTilesProvider(dict.Dict{ConfigKeyFilePath: "test.mbtiles"}, nil)
+	if err != provider.ErrUnsupported {
+		t.Fatalf("unsupported, expected %v got %v", provider.ErrUnsupported, err)
+	}
+}
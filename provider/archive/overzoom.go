@@ -0,0 +1,366 @@
+package archive
+
+import (
+	"math"
+
+	vectorTile "github.com/go-spatial/geom/encoding/mvt/vector_tile"
+	"github.com/go-spatial/tegola"
+)
+
+// the geometry commands of the vector tile spec
+const (
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+type point [2]float64
+
+// overzoom returns the features of a tile of a lower zoom in the tile dz zooms above it,
+// whose position in the tile is the column cx and row cy of its 2^dz by 2^dz children.
+// The geometries are scaled and clipped to the tile and its buffer, while the tags are
+// kept as is. Features left without a geometry are dropped.
+func overzoom(features []*vectorTile.Tile_Feature, extent uint32, dz, cx, cy uint) []*vectorTile.Tile_Feature {
+	var (
+		scale  = math.Exp2(float64(dz))
+		size   = float64(extent)
+		buffer = size * tegola.DefaultTileBuffer / tegola.DefaultExtent
+		c      = clipper{min: -buffer, max: size + buffer}
+		out    = make([]*vectorTile.Tile_Feature, 0, len(features))
+	)
+	transform := func(p point) point {
+		return point{p[0]*scale - float64(cx)*size, p[1]*scale - float64(cy)*size}
+	}
+
+	for _, f := range features {
+		var geometry []uint32
+		switch f.GetType() {
+		case vectorTile.Tile_POINT:
+			var points []point
+			for _, part := range decodeGeometry(f.Geometry) {
+				for _, p := range part {
+					if p = transform(p); c.contains(p) {
+						points = append(points, p)
+					}
+				}
+			}
+			geometry = encodePoints(points)
+
+		case vectorTile.Tile_LINESTRING:
+			var lines [][]point
+			for _, part := range decodeGeometry(f.Geometry) {
+				for i := range part {
+					part[i] = transform(part[i])
+				}
+				lines = append(lines, c.clipLine(part)...)
+			}
+			geometry = encodeLines(lines, false)
+
+		case vectorTile.Tile_POLYGON:
+			var (
+				rings [][]point
+				// keepHoles is false when the exterior ring of the holes being read was dropped
+				keepHoles bool
+			)
+			for _, ring := range decodeGeometry(f.Geometry) {
+				exterior := ringArea(ring) > 0
+				if !exterior && !keepHoles {
+					continue
+				}
+				for i := range ring {
+					ring[i] = transform(ring[i])
+				}
+				clipped := roundPoints(c.clipRing(ring))
+				// rings must be left with an area, with the orientation of their kind
+				if len(clipped) < 3 || (ringArea(clipped) > 0) != exterior {
+					if exterior {
+						keepHoles = false
+					}
+					continue
+				}
+				if exterior {
+					keepHoles = true
+				}
+				rings = append(rings, clipped)
+			}
+			geometry = encodeLines(rings, true)
+		}
+
+		if len(geometry) == 0 {
+			continue
+		}
+		out = append(out, &vectorTile.Tile_Feature{
+			Id:       f.Id,
+			Tags:     f.Tags,
+			Type:     f.Type,
+			Geometry: geometry,
+		})
+	}
+	return out
+}
+
+// decodeGeometry decodes the commands of a geometry into its parts: the points of a
+// MoveTo, the lines started by each MoveTo, or the rings ended by each ClosePath
+func decodeGeometry(geometry []uint32) [][]point {
+	var (
+		parts  [][]point
+		cursor point
+	)
+	for i := 0; i < len(geometry); {
+		cmd, count := geometry[i]&0x7, int(geometry[i]>>3)
+		i++
+
+		switch cmd {
+		case cmdMoveTo, cmdLineTo:
+			if cmd == cmdMoveTo || len(parts) == 0 {
+				parts = append(parts, nil)
+			}
+			for ; count > 0 && i+1 < len(geometry); count-- {
+				cursor[0] += float64(unzigzag(geometry[i]))
+				cursor[1] += float64(unzigzag(geometry[i+1]))
+				i += 2
+
+				// every point of a MoveTo with a count is a point of a multi point
+				if cmd == cmdMoveTo && len(parts[len(parts)-1]) > 0 {
+					parts = append(parts, nil)
+				}
+				parts[len(parts)-1] = append(parts[len(parts)-1], cursor)
+			}
+		case cmdClosePath:
+		default:
+			// an invalid command leaves the rest of the geometry undecodable
+			return parts
+		}
+	}
+	return parts
+}
+
+func unzigzag(v uint32) int32 {
+	return int32(v>>1) ^ -int32(v&1)
+}
+
+func zigzag(v int32) uint32 {
+	return uint32((v << 1) ^ (v >> 31))
+}
+
+func command(cmd uint32, count int) uint32 {
+	return cmd&0x7 | uint32(count)<<3
+}
+
+// encoder encodes the parameters of the commands of a geometry, relative to the cursor
+type encoder struct {
+	geometry []uint32
+	cursor   [2]int32
+}
+
+func (e *encoder) point(p point) {
+	x, y := int32(p[0]), int32(p[1])
+	e.geometry = append(e.geometry, zigzag(x-e.cursor[0]), zigzag(y-e.cursor[1]))
+	e.cursor = [2]int32{x, y}
+}
+
+// encodePoints rounds and encodes points as a single MoveTo
+func encodePoints(points []point) []uint32 {
+	if points = roundPoints(points); len(points) == 0 {
+		return nil
+	}
+
+	var e encoder
+	e.geometry = append(e.geometry, command(cmdMoveTo, len(points)))
+	for _, p := range points {
+		e.point(p)
+	}
+	return e.geometry
+}
+
+// encodeLines rounds and encodes lines, which must have 2 distinct points, or rings,
+// which must have 3 and are closed with ClosePath
+func encodeLines(lines [][]point, closed bool) []uint32 {
+	var e encoder
+	for _, line := range lines {
+		line = roundPoints(line)
+		if closed && len(line) > 1 && line[0] == line[len(line)-1] {
+			line = line[:len(line)-1]
+		}
+		if (closed && len(line) < 3) || len(line) < 2 {
+			continue
+		}
+
+		e.geometry = append(e.geometry, command(cmdMoveTo, 1))
+		e.point(line[0])
+		e.geometry = append(e.geometry, command(cmdLineTo, len(line)-1))
+		for _, p := range line[1:] {
+			e.point(p)
+		}
+		if closed {
+			e.geometry = append(e.geometry, command(cmdClosePath, 1))
+		}
+	}
+	return e.geometry
+}
+
+// roundPoints rounds the coordinates of points to the integer grid of the tile, dropping
+// the points which end up on the previous point
+func roundPoints(points []point) []point {
+	out := make([]point, 0, len(points))
+	for _, p := range points {
+		p = point{math.Round(p[0]), math.Round(p[1])}
+		if len(out) > 0 && out[len(out)-1] == p {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// ringArea returns the signed area of a ring, which is positive for exterior rings in
+// the coordinates of a tile, where y points down
+func ringArea(ring []point) float64 {
+	var area float64
+	for i := range ring {
+		j := (i + 1) % len(ring)
+		area += ring[i][0]*ring[j][1] - ring[j][0]*ring[i][1]
+	}
+	return area / 2
+}
+
+// clipper clips geometries to the square from min to max on both axes
+type clipper struct {
+	min, max float64
+}
+
+func (c clipper) contains(p point) bool {
+	return p[0] >= c.min && p[0] <= c.max && p[1] >= c.min && p[1] <= c.max
+}
+
+// clipLine clips a line, which may split it into several lines
+func (c clipper) clipLine(line []point) [][]point {
+	var (
+		lines   [][]point
+		current []point
+	)
+	for i := 0; i+1 < len(line); i++ {
+		a, b, ok := c.clipSegment(line[i], line[i+1])
+		if !ok {
+			continue
+		}
+		// the segment continues the current line when it starts where the line ends
+		if len(current) == 0 || current[len(current)-1] != a {
+			if len(current) > 1 {
+				lines = append(lines, current)
+			}
+			current = []point{a}
+		}
+		current = append(current, b)
+	}
+	if len(current) > 1 {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// clipSegment clips the segment from a to b with the Liang–Barsky algorithm. It returns
+// false when the segment is outside.
+func (c clipper) clipSegment(a, b point) (point, point, bool) {
+	var (
+		t0, t1 = 0.0, 1.0
+		dx     = b[0] - a[0]
+		dy     = b[1] - a[1]
+	)
+	for _, edge := range [4][2]float64{
+		{-dx, a[0] - c.min},
+		{dx, c.max - a[0]},
+		{-dy, a[1] - c.min},
+		{dy, c.max - a[1]},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 {
+			if r > t1 {
+				return a, b, false
+			}
+			if r > t0 {
+				t0 = r
+			}
+		} else {
+			if r < t0 {
+				return a, b, false
+			}
+			if r < t1 {
+				t1 = r
+			}
+		}
+	}
+
+	clippedA, clippedB := a, b
+	if t0 > 0 {
+		clippedA = point{a[0] + t0*dx, a[1] + t0*dy}
+	}
+	if t1 < 1 {
+		clippedB = point{a[0] + t1*dx, a[1] + t1*dy}
+	}
+	return clippedA, clippedB, true
+}
+
+// clipRing clips a ring with the Sutherland–Hodgman algorithm, which keeps its
+// orientation
+func (c clipper) clipRing(ring []point) []point {
+	edges := []struct {
+		inside    func(p point) bool
+		intersect func(a, b point) point
+	}{
+		{
+			inside: func(p point) bool { return p[0] >= c.min },
+			intersect: func(a, b point) point {
+				return point{c.min, a[1] + (b[1]-a[1])*(c.min-a[0])/(b[0]-a[0])}
+			},
+		},
+		{
+			inside: func(p point) bool { return p[0] <= c.max },
+			intersect: func(a, b point) point {
+				return point{c.max, a[1] + (b[1]-a[1])*(c.max-a[0])/(b[0]-a[0])}
+			},
+		},
+		{
+			inside: func(p point) bool { return p[1] >= c.min },
+			intersect: func(a, b point) point {
+				return point{a[0] + (b[0]-a[0])*(c.min-a[1])/(b[1]-a[1]), c.min}
+			},
+		},
+		{
+			inside: func(p point) bool { return p[1] <= c.max },
+			intersect: func(a, b point) point {
+				return point{a[0] + (b[0]-a[0])*(c.max-a[1])/(b[1]-a[1]), c.max}
+			},
+		},
+	}
+
+	out := ring
+	for _, edge := range edges {
+		if len(out) == 0 {
+			return nil
+		}
+		in := out
+		out = make([]point, 0, len(in)+4)
+		prev := in[len(in)-1]
+		for _, p := range in {
+			switch {
+			case edge.inside(p):
+				if !edge.inside(prev) {
+					out = append(out, edge.intersect(prev, p))
+				}
+				out = append(out, p)
+			case edge.inside(prev):
+				out = append(out, edge.intersect(prev, p))
+			}
+			prev = p
+		}
+	}
+	return out
+}
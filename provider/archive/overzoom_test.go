@@ -0,0 +1,175 @@
+package archive
+
+import (
+	"reflect"
+	"testing"
+
+	vectorTile "github.com/go-spatial/geom/encoding/mvt/vector_tile"
+)
+
+// square returns the ring of a square, as an exterior ring or a hole
+func square(min, max float64, exterior bool) []point {
+	ring := []point{{min, min}, {max, min}, {max, max}, {min, max}}
+	if !exterior {
+		ring = []point{{min, min}, {min, max}, {max, max}, {max, min}}
+	}
+	return ring
+}
+
+func feature(geomType vectorTile.Tile_GeomType, parts ...[]point) *vectorTile.Tile_Feature {
+	var geometry []uint32
+	switch geomType {
+	case vectorTile.Tile_POINT:
+		var points []point
+		for _, part := range parts {
+			points = append(points, part...)
+		}
+		geometry = encodePoints(points)
+	default:
+		geometry = encodeLines(parts, geomType == vectorTile.Tile_POLYGON)
+	}
+	id := uint64(1)
+	return &vectorTile.Tile_Feature{Id: &id, Tags: []uint32{0, 0}, Type: &geomType, Geometry: geometry}
+}
+
+func TestDecodeGeometry(t *testing.T) {
+	type tcase struct {
+		geometry []uint32
+		expected [][]point
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			if got := decodeGeometry(tc.geometry); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, got)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		// examples of the vector tile spec
+		"point": {
+			geometry: []uint32{9, 50, 34},
+			expected: [][]point{{{25, 17}}},
+		},
+		"multi point": {
+			geometry: []uint32{17, 10, 14, 3, 9},
+			expected: [][]point{{{5, 7}}, {{3, 2}}},
+		},
+		"multi linestring": {
+			geometry: []uint32{9, 4, 4, 18, 0, 16, 16, 0, 9, 17, 17, 10, 4, 8},
+			expected: [][]point{{{2, 2}, {2, 10}, {10, 10}}, {{1, 1}, {3, 5}}},
+		},
+		"polygon": {
+			geometry: []uint32{9, 6, 12, 18, 10, 12, 24, 44, 15},
+			expected: [][]point{{{3, 6}, {8, 12}, {20, 34}}},
+		},
+		"invalid command": {
+			geometry: []uint32{9, 50, 34, 4, 1, 1},
+			expected: [][]point{{{25, 17}}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestOverzoom(t *testing.T) {
+	type tcase struct {
+		feature  *vectorTile.Tile_Feature
+		dz       uint
+		cx, cy   uint
+		expected [][]point
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			got := overzoom([]*vectorTile.Tile_Feature{tc.feature}, 4096, tc.dz, tc.cx, tc.cy)
+			if tc.expected == nil {
+				if len(got) != 0 {
+					t.Fatalf("expected no features got %v", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("expected 1 feature got %v", len(got))
+			}
+			if got[0].GetId() != 1 || !reflect.DeepEqual(got[0].Tags, tc.feature.Tags) {
+				t.Errorf("id and tags, expected %v %v got %v %v", 1, tc.feature.Tags, got[0].GetId(), got[0].Tags)
+			}
+			if parts := decodeGeometry(got[0].Geometry); !reflect.DeepEqual(parts, tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, parts)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"points": {
+			feature:  feature(vectorTile.Tile_POINT, []point{{100, 100}, {2100, 100}, {3000, 3000}}),
+			dz:       1,
+			expected: [][]point{{{200, 200}}},
+		},
+		"points of the second column": {
+			feature:  feature(vectorTile.Tile_POINT, []point{{100, 100}, {2100, 100}, {3000, 3000}}),
+			dz:       1,
+			cx:       1,
+			expected: [][]point{{{104, 200}}},
+		},
+		"points outside": {
+			feature: feature(vectorTile.Tile_POINT, []point{{3000, 3000}}),
+			dz:      1,
+		},
+		"linestring": {
+			feature:  feature(vectorTile.Tile_LINESTRING, []point{{0, 2048}, {4096, 2048}}),
+			dz:       1,
+			expected: [][]point{{{0, 4096}, {4160, 4096}}},
+		},
+		"linestring split": {
+			// leaves the tile to the right and comes back
+			feature:  feature(vectorTile.Tile_LINESTRING, []point{{1000, 100}, {3000, 100}, {3000, 200}, {1000, 200}}),
+			dz:       1,
+			expected: [][]point{{{2000, 200}, {4160, 200}}, {{4160, 400}, {2000, 400}}},
+		},
+		"linestring 2 zooms": {
+			feature:  feature(vectorTile.Tile_LINESTRING, []point{{0, 0}, {4096, 4096}}),
+			dz:       2,
+			cx:       3,
+			cy:       3,
+			expected: [][]point{{{-64, -64}, {4096, 4096}}},
+		},
+		"linestring outside": {
+			feature: feature(vectorTile.Tile_LINESTRING, []point{{3000, 100}, {3000, 1000}}),
+			dz:      1,
+		},
+		"polygon with hole": {
+			feature: feature(vectorTile.Tile_POLYGON, square(1024, 3072, true), square(1536, 2560, false)),
+			dz:      1,
+			expected: [][]point{
+				{{2048, 4160}, {2048, 2048}, {4160, 2048}, {4160, 4160}},
+				{{4160, 4160}, {4160, 3072}, {3072, 3072}, {3072, 4160}},
+			},
+		},
+		"polygon hole outside": {
+			feature:  feature(vectorTile.Tile_POLYGON, square(100, 3072, true), square(2500, 3000, false)),
+			dz:       1,
+			expected: [][]point{{{200, 4160}, {200, 200}, {4160, 200}, {4160, 4160}}},
+		},
+		"multi polygon first outside": {
+			feature: feature(vectorTile.Tile_POLYGON,
+				square(2500, 4000, true), square(3000, 3500, false),
+				square(100, 200, true),
+			),
+			dz:       1,
+			expected: [][]point{{{200, 200}, {400, 200}, {400, 400}, {200, 400}}},
+		},
+		"polygon outside": {
+			feature: feature(vectorTile.Tile_POLYGON, square(2500, 4000, true)),
+			dz:      1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
@@ -0,0 +1,56 @@
+package archive
+
+import (
+	"context"
+	"os"
+
+	"github.com/go-spatial/tegola/internal/pmtiles"
+)
+
+// pmtilesTileset is a PMTiles archive
+type pmtilesTileset struct {
+	fh     *os.File
+	reader *pmtiles.Reader
+}
+
+// openPMTiles opens a PMTiles archive of vector tiles
+func openPMTiles(filename string) (_ tileset, _ tilesetInfo, err error) {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return nil, tilesetInfo{}, ErrInvalidFilePath{filename}
+	}
+	defer func() {
+		if err != nil {
+			fh.Close()
+		}
+	}()
+
+	reader, err := pmtiles.NewReader(fh)
+	if err != nil {
+		return nil, tilesetInfo{}, err
+	}
+	if reader.Header.TileType != pmtiles.TileTypeMVT {
+		return nil, tilesetInfo{}, ErrNotVectorTiles
+	}
+
+	info := tilesetInfo{
+		minZoom: uint(reader.Header.MinZoom),
+		maxZoom: uint(reader.Header.MaxZoom),
+	}
+	if info.metadata, err = reader.Metadata(); err != nil {
+		return nil, tilesetInfo{}, err
+	}
+
+	return &pmtilesTileset{fh: fh, reader: reader}, info, nil
+}
+
+func (ts *pmtilesTileset) tile(ctx context.Context, z, x, y uint) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ts.reader.Tile(uint8(z), uint32(x), uint32(y))
+}
+
+func (ts *pmtilesTileset) close() error {
+	return ts.fh.Close()
+}
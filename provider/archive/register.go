@@ -0,0 +1,88 @@
+package archive
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/provider"
+)
+
+func init() {
+	provider.MVTRegister(provider.TypeMvt.Prefix()+NameMBTiles, NewMBTilesProvider, Cleanup)
+	provider.MVTRegister(provider.TypeMvt.Prefix()+NamePMTiles, NewPMTilesProvider, Cleanup)
+}
+
+// NewMBTilesProvider instantiates a provider of the tiles of an MBTiles archive
+func NewMBTilesProvider(config dict.Dicter, _ []provider.Map) (provider.MVTTiler, error) {
+	return newProvider(config, openMBTiles)
+}
+
+// NewPMTilesProvider instantiates a provider of the tiles of a PMTiles archive
+func NewPMTilesProvider(config dict.Dicter, _ []provider.Map) (provider.MVTTiler, error) {
+	return newProvider(config, openPMTiles)
+}
+
+func newProvider(config dict.Dicter, open func(filename string) (tileset, tilesetInfo, error)) (*Provider, error) {
+	var path string
+	path, err := config.String(ConfigKeyFilePath, &path)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, ErrInvalidFilePath{path}
+	}
+
+	overzoom := true
+	if overzoom, err = config.Bool(ConfigKeyOverzoom, &overzoom); err != nil {
+		return nil, err
+	}
+
+	ts, info, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	layers, err := layersOf(info.metadata)
+	if err != nil {
+		ts.close()
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	p := Provider{
+		Filepath: path,
+		tileset:  ts,
+		layers:   layers,
+		minZoom:  info.minZoom,
+		maxZoom:  info.maxZoom,
+		overzoom: overzoom,
+	}
+	log.Infof("opened archive (%v) with %v layers for zooms %v to %v", path, len(layers), info.minZoom, info.maxZoom)
+
+	// track the provider so we can clean it up later
+	providersLock.Lock()
+	providers = append(providers, &p)
+	providersLock.Unlock()
+
+	return &p, nil
+}
+
+var (
+	providersLock sync.Mutex
+	// reference to all instantiated providers
+	providers []*Provider
+)
+
+// Cleanup closes the archives of all the previously instantiated providers
+func Cleanup() {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+
+	for _, p := range providers {
+		if err := p.Close(); err != nil {
+			log.Errorf("err closing archive provider: %v", err)
+		}
+	}
+	providers = nil
+}
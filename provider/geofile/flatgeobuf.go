@@ -0,0 +1,566 @@
+package geofile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola"
+)
+
+// fgbMagic are the first bytes of a FlatGeobuf file. The fourth byte is the major version
+// and the last the patch version.
+var fgbMagic = []byte{'f', 'g', 'b', 3, 'f', 'g', 'b'}
+
+// FlatGeobuf geometry types
+const (
+	fgbUnknown            = 0
+	fgbPoint              = 1
+	fgbLineString         = 2
+	fgbPolygon            = 3
+	fgbMultiPoint         = 4
+	fgbMultiLineString    = 5
+	fgbMultiPolygon       = 6
+	fgbGeometryCollection = 7
+)
+
+// FlatGeobuf column types
+const (
+	fgbByte = iota
+	fgbUByte
+	fgbBool
+	fgbShort
+	fgbUShort
+	fgbInt
+	fgbUInt
+	fgbLong
+	fgbULong
+	fgbFloat
+	fgbDouble
+	fgbString
+	fgbJSON
+	fgbDateTime
+	fgbBinary
+)
+
+// field indexes of the FlatGeobuf tables
+const (
+	fgbHeaderGeometryType = 2
+	fgbHeaderColumns      = 7
+	fgbHeaderFeatureCount = 8
+	fgbHeaderIndexNode    = 9
+	fgbHeaderCRS          = 10
+
+	fgbColumnName = 0
+	fgbColumnType = 1
+
+	fgbCRSCode = 1
+
+	fgbFeatureGeometry   = 0
+	fgbFeatureProperties = 1
+
+	fgbGeometryEnds  = 0
+	fgbGeometryXY    = 1
+	fgbGeometryType  = 6
+	fgbGeometryParts = 7
+)
+
+// fgbDefaultIndexNodeSize is the node size of the index when the header doesn't set one
+const fgbDefaultIndexNodeSize = 16
+
+var errFlatbufferBounds = errors.New("flatbuffer offset out of bounds")
+
+// fgbColumn is an attribute column of a FlatGeobuf file
+type fgbColumn struct {
+	name string
+	typ  uint8
+}
+
+// readFlatGeobuf decodes the features of a FlatGeobuf file. Only the 2D coordinates
+// are read.
+func readFlatGeobuf(b []byte) (features []rawFeature, srid uint64, geomType geom.Geometry, err error) {
+	if len(b) < 12 || !bytes.Equal(b[:len(fgbMagic)], fgbMagic) {
+		return nil, 0, nil, fmt.Errorf("not a FlatGeobuf version 3 file")
+	}
+
+	headerSize := int(binary.LittleEndian.Uint32(b[8:]))
+	if 12+headerSize > len(b) {
+		return nil, 0, nil, fmt.Errorf("header: %w", errFlatbufferBounds)
+	}
+	header, err := rootTable(b[12 : 12+headerSize])
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("header: %w", err)
+	}
+
+	// the srid defaults to WGS84 as for GeoJSON
+	srid = tegola.WGS84
+	if crs, ok, err := header.table(fgbHeaderCRS); err != nil {
+		return nil, 0, nil, fmt.Errorf("header crs: %w", err)
+	} else if ok {
+		if code := crs.int32(fgbCRSCode, 0); code != 0 {
+			srid = uint64(code)
+		}
+	}
+
+	headerGeomType := header.uint8(fgbHeaderGeometryType, fgbUnknown)
+	geomType = fgbLayerGeomType(headerGeomType)
+
+	var columns []fgbColumn
+	n, err := header.vectorLen(fgbHeaderColumns)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("header columns: %w", err)
+	}
+	for i := 0; i < n; i++ {
+		col, err := header.vectorTable(fgbHeaderColumns, i)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("header column %v: %w", i, err)
+		}
+		name, err := col.string(fgbColumnName)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("header column %v: %w", i, err)
+		}
+		columns = append(columns, fgbColumn{name: name, typ: col.uint8(fgbColumnType, fgbByte)})
+	}
+
+	// skip the packed Hilbert R-tree index, which is replaced by the provider's index
+	pos := 12 + headerSize
+	featureCount := header.uint64(fgbHeaderFeatureCount, 0)
+	if nodeSize := header.uint16(fgbHeaderIndexNode, fgbDefaultIndexNodeSize); nodeSize > 0 && featureCount > 0 {
+		pos += fgbIndexSize(featureCount, uint64(nodeSize))
+	}
+
+	for pos < len(b) {
+		if pos+4 > len(b) {
+			return nil, 0, nil, fmt.Errorf("feature %v: %w", len(features), errFlatbufferBounds)
+		}
+		size := int(binary.LittleEndian.Uint32(b[pos:]))
+		pos += 4
+		if size < 0 || pos+size > len(b) {
+			return nil, 0, nil, fmt.Errorf("feature %v: %w", len(features), errFlatbufferBounds)
+		}
+
+		f, err := decodeFGBFeature(b[pos:pos+size], headerGeomType, columns)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("feature %v: %w", len(features), err)
+		}
+		features = append(features, f)
+		pos += size
+	}
+
+	return features, srid, geomType, nil
+}
+
+// fgbIndexSize is the size in bytes of the packed Hilbert R-tree of a FlatGeobuf file.
+// Each node is an extent of 4 doubles and an offset. The tree always has a root node
+// above the leaves.
+func fgbIndexSize(featureCount, nodeSize uint64) int {
+	nodeSize = max(nodeSize, 2)
+	n := featureCount
+	nodes := n
+	for {
+		n = (n + nodeSize - 1) / nodeSize
+		nodes += n
+		if n == 1 {
+			return int(nodes * 40)
+		}
+	}
+}
+
+func decodeFGBFeature(b []byte, headerGeomType uint8, columns []fgbColumn) (rawFeature, error) {
+	var f rawFeature
+
+	table, err := rootTable(b)
+	if err != nil {
+		return f, err
+	}
+
+	g, ok, err := table.table(fgbFeatureGeometry)
+	if err != nil {
+		return f, fmt.Errorf("geometry: %w", err)
+	}
+	if ok {
+		if f.geometry, err = decodeFGBGeometry(g, headerGeomType); err != nil {
+			return f, fmt.Errorf("geometry: %w", err)
+		}
+	}
+
+	props, err := table.bytes(fgbFeatureProperties)
+	if err != nil {
+		return f, fmt.Errorf("properties: %w", err)
+	}
+	if f.properties, err = decodeFGBProperties(props, columns); err != nil {
+		return f, fmt.Errorf("properties: %w", err)
+	}
+	return f, nil
+}
+
+// decodeFGBProperties decodes the properties of a feature, each the index of its column
+// followed by the value
+func decodeFGBProperties(b []byte, columns []fgbColumn) (map[string]interface{}, error) {
+	props := make(map[string]interface{}, len(columns))
+
+	for pos := 0; pos < len(b); {
+		if pos+2 > len(b) {
+			return nil, errFlatbufferBounds
+		}
+		i := int(binary.LittleEndian.Uint16(b[pos:]))
+		pos += 2
+		if i >= len(columns) {
+			return nil, fmt.Errorf("invalid column index %v", i)
+		}
+		col := columns[i]
+
+		size := 0
+		switch col.typ {
+		case fgbByte, fgbUByte, fgbBool:
+			size = 1
+		case fgbShort, fgbUShort:
+			size = 2
+		case fgbInt, fgbUInt, fgbFloat:
+			size = 4
+		case fgbLong, fgbULong, fgbDouble:
+			size = 8
+		case fgbString, fgbJSON, fgbDateTime, fgbBinary:
+			if pos+4 > len(b) {
+				return nil, errFlatbufferBounds
+			}
+			size = int(binary.LittleEndian.Uint32(b[pos:]))
+			pos += 4
+		default:
+			return nil, fmt.Errorf("column (%v) has an unsupported type %v", col.name, col.typ)
+		}
+		if size < 0 || pos+size > len(b) {
+			return nil, errFlatbufferBounds
+		}
+		v := b[pos : pos+size]
+		pos += size
+
+		switch col.typ {
+		case fgbByte:
+			props[col.name] = int8(v[0])
+		case fgbUByte:
+			props[col.name] = v[0]
+		case fgbBool:
+			props[col.name] = v[0] != 0
+		case fgbShort:
+			props[col.name] = int16(binary.LittleEndian.Uint16(v))
+		case fgbUShort:
+			props[col.name] = binary.LittleEndian.Uint16(v)
+		case fgbInt:
+			props[col.name] = int32(binary.LittleEndian.Uint32(v))
+		case fgbUInt:
+			props[col.name] = binary.LittleEndian.Uint32(v)
+		case fgbLong:
+			props[col.name] = int64(binary.LittleEndian.Uint64(v))
+		case fgbULong:
+			props[col.name] = binary.LittleEndian.Uint64(v)
+		case fgbFloat:
+			props[col.name] = math.Float32frombits(binary.LittleEndian.Uint32(v))
+		case fgbDouble:
+			props[col.name] = math.Float64frombits(binary.LittleEndian.Uint64(v))
+		case fgbString, fgbJSON, fgbDateTime:
+			props[col.name] = string(v)
+		case fgbBinary:
+			// binary values can't be encoded as MVT tags
+		}
+	}
+	return props, nil
+}
+
+// decodeFGBGeometry decodes a geometry. The type of the geometry is set by the header,
+// or by the geometry when the header type is unknown. Parts inherit the type expected
+// by their parent.
+func decodeFGBGeometry(t flatTable, typ uint8) (geom.Geometry, error) {
+	if typ == fgbUnknown {
+		typ = t.uint8(fgbGeometryType, fgbUnknown)
+	}
+
+	switch typ {
+	case fgbMultiPolygon, fgbGeometryCollection:
+		n, err := t.vectorLen(fgbGeometryParts)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			polygons    geom.MultiPolygon
+			geometries  []geom.Geometry
+			partGeomTyp uint8 = fgbUnknown
+		)
+		if typ == fgbMultiPolygon {
+			partGeomTyp = fgbPolygon
+		}
+		for i := 0; i < n; i++ {
+			part, err := t.vectorTable(fgbGeometryParts, i)
+			if err != nil {
+				return nil, err
+			}
+			g, err := decodeFGBGeometry(part, partGeomTyp)
+			if err != nil {
+				return nil, err
+			}
+			if typ == fgbMultiPolygon {
+				polygons = append(polygons, [][][2]float64(g.(geom.Polygon)))
+				continue
+			}
+			geometries = append(geometries, g)
+		}
+
+		if typ == fgbMultiPolygon {
+			return polygons, nil
+		}
+		return geom.Collection(geometries), nil
+	}
+
+	xy, err := t.float64s(fgbGeometryXY)
+	if err != nil {
+		return nil, err
+	}
+	if len(xy)%2 != 0 {
+		return nil, fmt.Errorf("odd number of coordinates")
+	}
+	points := make([][2]float64, len(xy)/2)
+	for i := range points {
+		points[i] = [2]float64{xy[2*i], xy[2*i+1]}
+	}
+
+	ends, err := t.uint32s(fgbGeometryEnds)
+	if err != nil {
+		return nil, err
+	}
+	// rings and lines are split at the ends, which are point indexes
+	split := func() ([][][2]float64, error) {
+		if len(ends) == 0 {
+			return [][][2]float64{points}, nil
+		}
+		parts := make([][][2]float64, 0, len(ends))
+		start := uint32(0)
+		for _, end := range ends {
+			if end < start || int(end) > len(points) {
+				return nil, fmt.Errorf("invalid end %v", end)
+			}
+			parts = append(parts, points[start:end])
+			start = end
+		}
+		return parts, nil
+	}
+
+	switch typ {
+	case fgbPoint:
+		if len(points) != 1 {
+			return nil, fmt.Errorf("point with %v coordinates", len(points))
+		}
+		return geom.Point(points[0]), nil
+	case fgbMultiPoint:
+		return geom.MultiPoint(points), nil
+	case fgbLineString:
+		return geom.LineString(points), nil
+	case fgbMultiLineString:
+		parts, err := split()
+		return geom.MultiLineString(parts), err
+	case fgbPolygon:
+		parts, err := split()
+		return geom.Polygon(parts), err
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %v", typ)
+	}
+}
+
+// fgbLayerGeomType maps the geometry type of a FlatGeobuf header to the geometry type of
+// the layer. nil is returned when the types of the features vary.
+func fgbLayerGeomType(typ uint8) geom.Geometry {
+	switch typ {
+	case fgbPoint:
+		return geom.Point{}
+	case fgbLineString:
+		return geom.LineString{}
+	case fgbPolygon:
+		return geom.Polygon{}
+	case fgbMultiPoint:
+		return geom.MultiPoint{}
+	case fgbMultiLineString:
+		return geom.MultiLineString{}
+	case fgbMultiPolygon:
+		return geom.MultiPolygon{}
+	default:
+		return nil
+	}
+}
+
+// flatTable reads the fields of a flatbuffers table. See
+// https://flatbuffers.dev/internals/ for the layout.
+type flatTable struct {
+	b      []byte
+	pos    int
+	vtable int
+}
+
+// rootTable reads the root table of a flatbuffer
+func rootTable(b []byte) (flatTable, error) {
+	if len(b) < 4 {
+		return flatTable{}, errFlatbufferBounds
+	}
+	return newFlatTable(b, int(binary.LittleEndian.Uint32(b)))
+}
+
+func newFlatTable(b []byte, pos int) (flatTable, error) {
+	if pos < 0 || pos+4 > len(b) {
+		return flatTable{}, errFlatbufferBounds
+	}
+	vtable := pos - int(int32(binary.LittleEndian.Uint32(b[pos:])))
+	if vtable < 0 || vtable+4 > len(b) {
+		return flatTable{}, errFlatbufferBounds
+	}
+	return flatTable{b: b, pos: pos, vtable: vtable}, nil
+}
+
+// field returns the position of a field, or 0 when it's not set
+func (t flatTable) field(i int) int {
+	vtableSize := int(binary.LittleEndian.Uint16(t.b[t.vtable:]))
+	slot := 4 + 2*i
+	if slot+2 > vtableSize || t.vtable+slot+2 > len(t.b) {
+		return 0
+	}
+	off := int(binary.LittleEndian.Uint16(t.b[t.vtable+slot:]))
+	if off == 0 {
+		return 0
+	}
+	return t.pos + off
+}
+
+// scalar returns the bytes of a scalar field, or nil when it's not set
+func (t flatTable) scalar(i, size int) []byte {
+	pos := t.field(i)
+	if pos == 0 || pos+size > len(t.b) {
+		return nil
+	}
+	return t.b[pos : pos+size]
+}
+
+func (t flatTable) uint8(i int, def uint8) uint8 {
+	if v := t.scalar(i, 1); v != nil {
+		return v[0]
+	}
+	return def
+}
+
+func (t flatTable) uint16(i int, def uint16) uint16 {
+	if v := t.scalar(i, 2); v != nil {
+		return binary.LittleEndian.Uint16(v)
+	}
+	return def
+}
+
+func (t flatTable) int32(i int, def int32) int32 {
+	if v := t.scalar(i, 4); v != nil {
+		return int32(binary.LittleEndian.Uint32(v))
+	}
+	return def
+}
+
+func (t flatTable) uint64(i int, def uint64) uint64 {
+	if v := t.scalar(i, 8); v != nil {
+		return binary.LittleEndian.Uint64(v)
+	}
+	return def
+}
+
+// indirect follows the offset of a reference field. ok is false when the field is not set
+func (t flatTable) indirect(i int) (pos int, ok bool, err error) {
+	pos = t.field(i)
+	if pos == 0 {
+		return 0, false, nil
+	}
+	if pos+4 > len(t.b) {
+		return 0, false, errFlatbufferBounds
+	}
+	return pos + int(binary.LittleEndian.Uint32(t.b[pos:])), true, nil
+}
+
+// table returns a sub table. ok is false when the field is not set
+func (t flatTable) table(i int) (flatTable, bool, error) {
+	pos, ok, err := t.indirect(i)
+	if err != nil || !ok {
+		return flatTable{}, false, err
+	}
+	sub, err := newFlatTable(t.b, pos)
+	return sub, err == nil, err
+}
+
+// vector returns the position of the first element and the length of a vector field
+func (t flatTable) vector(i int) (start, n int, err error) {
+	pos, ok, err := t.indirect(i)
+	if err != nil || !ok {
+		return 0, 0, err
+	}
+	if pos+4 > len(t.b) {
+		return 0, 0, errFlatbufferBounds
+	}
+	return pos + 4, int(binary.LittleEndian.Uint32(t.b[pos:])), nil
+}
+
+func (t flatTable) vectorLen(i int) (int, error) {
+	_, n, err := t.vector(i)
+	return n, err
+}
+
+// vectorTable returns the table at index j of a vector of tables
+func (t flatTable) vectorTable(i, j int) (flatTable, error) {
+	start, n, err := t.vector(i)
+	if err != nil {
+		return flatTable{}, err
+	}
+	pos := start + 4*j
+	if j >= n || pos+4 > len(t.b) {
+		return flatTable{}, errFlatbufferBounds
+	}
+	return newFlatTable(t.b, pos+int(binary.LittleEndian.Uint32(t.b[pos:])))
+}
+
+// bytes returns the elements of a vector of bytes
+func (t flatTable) bytes(i int) ([]byte, error) {
+	return t.elements(i, 1)
+}
+
+func (t flatTable) string(i int) (string, error) {
+	b, err := t.elements(i, 1)
+	return string(b), err
+}
+
+func (t flatTable) elements(i, size int) ([]byte, error) {
+	start, n, err := t.vector(i)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || start+n*size > len(t.b) {
+		return nil, errFlatbufferBounds
+	}
+	return t.b[start : start+n*size], nil
+}
+
+func (t flatTable) float64s(i int) ([]float64, error) {
+	b, err := t.elements(i, 8)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]float64, len(b)/8)
+	for j := range vals {
+		vals[j] = math.Float64frombits(binary.LittleEndian.Uint64(b[8*j:]))
+	}
+	return vals, nil
+}
+
+func (t flatTable) uint32s(i int) ([]uint32, error) {
+	b, err := t.elements(i, 4)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]uint32, len(b)/4)
+	for j := range vals {
+		vals[j] = binary.LittleEndian.Uint32(b[4*j:])
+	}
+	return vals, nil
+}
@@ -0,0 +1,330 @@
+package geofile
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola"
+)
+
+// fbTable is a flatbuffers table for the test encoder, by field index. The values are
+// uint8, uint16, int32 or uint64 scalars, strings, []byte, []float64, []uint32, fbTable
+// or []fbTable.
+type fbTable map[int]interface{}
+
+// encodeFlatbuffer encodes a flatbuffer with the table as root. The children of a table
+// are written after it, so the offsets which are unsigned are always forward.
+func encodeFlatbuffer(root fbTable) []byte {
+	b := make([]byte, 4)
+	var pos int
+	b, pos = appendFBTable(b, root)
+	binary.LittleEndian.PutUint32(b, uint32(pos))
+	return b
+}
+
+func appendFBTable(b []byte, t fbTable) ([]byte, int) {
+	maxField := -1
+	for i := range t {
+		if i > maxField {
+			maxField = i
+		}
+	}
+
+	// the field offsets of the table
+	offsets := make([]int, maxField+1)
+	size := 4
+	for i := 0; i <= maxField; i++ {
+		v, ok := t[i]
+		if !ok {
+			continue
+		}
+		offsets[i] = size
+		switch v.(type) {
+		case uint8:
+			size++
+		case uint16:
+			size += 2
+		case uint64:
+			size += 8
+		default:
+			size += 4
+		}
+	}
+
+	// vtable
+	vtable := len(b)
+	b = binary.LittleEndian.AppendUint16(b, uint16(4+2*len(offsets)))
+	b = binary.LittleEndian.AppendUint16(b, uint16(size))
+	for _, off := range offsets {
+		b = binary.LittleEndian.AppendUint16(b, uint16(off))
+	}
+
+	// table
+	pos := len(b)
+	b = binary.LittleEndian.AppendUint32(b, uint32(int32(pos-vtable)))
+	b = append(b, make([]byte, size-4)...)
+
+	for i, off := range offsets {
+		v, ok := t[i]
+		if !ok {
+			continue
+		}
+		field := pos + off
+
+		var child int
+		switch val := v.(type) {
+		case uint8:
+			b[field] = val
+			continue
+		case uint16:
+			binary.LittleEndian.PutUint16(b[field:], val)
+			continue
+		case int32:
+			binary.LittleEndian.PutUint32(b[field:], uint32(val))
+			continue
+		case uint64:
+			binary.LittleEndian.PutUint64(b[field:], val)
+			continue
+		case string:
+			child = len(b)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(val)))
+			b = append(b, val...)
+		case []byte:
+			child = len(b)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(val)))
+			b = append(b, val...)
+		case []float64:
+			child = len(b)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(val)))
+			for _, f := range val {
+				b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+			}
+		case []uint32:
+			child = len(b)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(val)))
+			for _, u := range val {
+				b = binary.LittleEndian.AppendUint32(b, u)
+			}
+		case fbTable:
+			b, child = appendFBTable(b, val)
+		case []fbTable:
+			child = len(b)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(val)))
+			slots := len(b)
+			b = append(b, make([]byte, 4*len(val))...)
+			for j, sub := range val {
+				var subPos int
+				b, subPos = appendFBTable(b, sub)
+				slot := slots + 4*j
+				binary.LittleEndian.PutUint32(b[slot:], uint32(subPos-slot))
+			}
+		default:
+			panic("unsupported flatbuffer value")
+		}
+		binary.LittleEndian.PutUint32(b[field:], uint32(child-field))
+	}
+
+	return b, pos
+}
+
+// fgbProperty is a property value of a test feature
+type fgbProperty struct {
+	column uint16
+	value  []byte
+}
+
+func encodeFGBProperties(props ...fgbProperty) []byte {
+	var b []byte
+	for _, p := range props {
+		b = binary.LittleEndian.AppendUint16(b, p.column)
+		b = append(b, p.value...)
+	}
+	return b
+}
+
+func fgbStringValue(s string) []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(s))), s...)
+}
+
+// encodeFlatGeobuf encodes a FlatGeobuf file of the header and features. When indexed
+// the file has a zeroed index of the size the features require.
+func encodeFlatGeobuf(header fbTable, features []fbTable, indexed bool) []byte {
+	header[fgbHeaderFeatureCount] = uint64(len(features))
+	if !indexed {
+		header[fgbHeaderIndexNode] = uint16(0)
+	}
+
+	b := append([]byte{}, fgbMagic...)
+	b = append(b, 0)
+
+	h := encodeFlatbuffer(header)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(h)))
+	b = append(b, h...)
+
+	if indexed && len(features) > 0 {
+		b = append(b, make([]byte, fgbIndexSize(uint64(len(features)), fgbDefaultIndexNodeSize))...)
+	}
+
+	for _, f := range features {
+		fb := encodeFlatbuffer(f)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(fb)))
+		b = append(b, fb...)
+	}
+	return b
+}
+
+func TestReadFlatGeobuf(t *testing.T) {
+	type tcase struct {
+		data     []byte
+		srid     uint64
+		geomType geom.Geometry
+		features []rawFeature
+		expErr   bool
+	}
+
+	columns := []fbTable{
+		{fgbColumnName: "name", fgbColumnType: uint8(fgbString)},
+		{fgbColumnName: "population", fgbColumnType: uint8(fgbLong)},
+		{fgbColumnName: "area", fgbColumnType: uint8(fgbDouble)},
+		{fgbColumnName: "capital", fgbColumnType: uint8(fgbBool)},
+	}
+	polygon := fbTable{
+		fgbFeatureGeometry: fbTable{
+			fgbGeometryXY:   []float64{0, 0, 10, 0, 10, 10, 0, 0, 2, 2, 4, 2, 4, 4, 2, 2},
+			fgbGeometryEnds: []uint32{4, 8},
+		},
+		fgbFeatureProperties: encodeFGBProperties(
+			fgbProperty{0, fgbStringValue("Paris")},
+			fgbProperty{1, binary.LittleEndian.AppendUint64(nil, 2100000)},
+			fgbProperty{3, []byte{1}},
+		),
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			features, srid, geomType, err := readFlatGeobuf(tc.data)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("expected an error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if srid != tc.srid {
+				t.Errorf("srid, expected %v got %v", tc.srid, srid)
+			}
+			if !reflect.DeepEqual(geomType, tc.geomType) {
+				t.Errorf("geometry type, expected %T got %T", tc.geomType, geomType)
+			}
+			if !reflect.DeepEqual(features, tc.features) {
+				t.Errorf("features, expected %+v got %+v", tc.features, features)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"polygons": {
+			data: encodeFlatGeobuf(fbTable{
+				fgbHeaderGeometryType: uint8(fgbPolygon),
+				fgbHeaderColumns:      columns,
+				fgbHeaderCRS:          fbTable{fgbCRSCode: int32(3857)},
+			}, []fbTable{polygon}, true),
+			srid:     tegola.WebMercator,
+			geomType: geom.Polygon{},
+			features: []rawFeature{{
+				geometry: geom.Polygon{
+					{{0, 0}, {10, 0}, {10, 10}, {0, 0}},
+					{{2, 2}, {4, 2}, {4, 4}, {2, 2}},
+				},
+				properties: map[string]interface{}{"name": "Paris", "population": int64(2100000), "capital": true},
+			}},
+		},
+		"mixed types": {
+			data: encodeFlatGeobuf(fbTable{
+				fgbHeaderColumns: columns,
+			}, []fbTable{
+				{
+					fgbFeatureGeometry: fbTable{fgbGeometryType: uint8(fgbPoint), fgbGeometryXY: []float64{2.35, 48.85}},
+					fgbFeatureProperties: encodeFGBProperties(
+						fgbProperty{2, binary.LittleEndian.AppendUint64(nil, math.Float64bits(105.4))},
+					),
+				},
+				{
+					fgbFeatureGeometry: fbTable{
+						fgbGeometryType: uint8(fgbMultiPolygon),
+						fgbGeometryParts: []fbTable{
+							{fgbGeometryXY: []float64{0, 0, 1, 0, 1, 1, 0, 0}},
+							{fgbGeometryXY: []float64{5, 5, 6, 5, 6, 6, 5, 5}},
+						},
+					},
+				},
+				{
+					// no geometry
+					fgbFeatureProperties: encodeFGBProperties(fgbProperty{0, fgbStringValue("")}),
+				},
+			}, false),
+			srid: tegola.WGS84,
+			features: []rawFeature{
+				{
+					geometry:   geom.Point{2.35, 48.85},
+					properties: map[string]interface{}{"area": 105.4},
+				},
+				{
+					geometry: geom.MultiPolygon{
+						{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+						{{{5, 5}, {6, 5}, {6, 6}, {5, 5}}},
+					},
+					properties: map[string]interface{}{},
+				},
+				{
+					properties: map[string]interface{}{"name": ""},
+				},
+			},
+		},
+		"not flatgeobuf": {
+			data:   []byte(`{"type":"FeatureCollection","features":[]}`),
+			expErr: true,
+		},
+		"truncated": {
+			data: func() []byte {
+				b := encodeFlatGeobuf(fbTable{fgbHeaderColumns: columns}, []fbTable{polygon}, false)
+				return b[:len(b)-10]
+			}(),
+			expErr: true,
+		},
+		"invalid column": {
+			data: encodeFlatGeobuf(fbTable{}, []fbTable{{
+				fgbFeatureProperties: encodeFGBProperties(fgbProperty{7, []byte{1}}),
+			}}, false),
+			expErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestFGBIndexSize(t *testing.T) {
+	tests := map[string]struct {
+		features, nodeSize uint64
+		size               int
+	}{
+		"single":     {features: 1, nodeSize: 16, size: 2 * 40},
+		"one level":  {features: 16, nodeSize: 16, size: 17 * 40},
+		"two levels": {features: 17, nodeSize: 16, size: (17 + 2 + 1) * 40},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if size := fgbIndexSize(tc.features, tc.nodeSize); size != tc.size {
+				t.Errorf("expected %v got %v", tc.size, size)
+			}
+		})
+	}
+}
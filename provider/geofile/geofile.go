@@ -0,0 +1,259 @@
+// Package geofile implements the geojson and flatgeobuf providers. The features of
+// GeoJSON and FlatGeobuf files are loaded into memory and indexed in an R-tree, each file
+// being a layer, and the files are reloaded when they change on disk.
+package geofile
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/basic"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/internal/rtree"
+	"github.com/go-spatial/tegola/provider"
+)
+
+const (
+	NameGeoJSON    = "geojson"
+	NameFlatGeobuf = "flatgeobuf"
+)
+
+// config keys
+const (
+	ConfigKeyFilePath    = "filepath"
+	ConfigKeyReload      = "reload"
+	ConfigKeyLayers      = "layers"
+	ConfigKeyLayerName   = "name"
+	ConfigKeyFileName    = "filename"
+	ConfigKeyGeomIDField = "id_fieldname"
+	ConfigKeyFields      = "fields"
+)
+
+// reloadDelay is how long a file must be left unchanged before it's reloaded, so a file
+// being copied is read once complete
+var reloadDelay = 500 * time.Millisecond
+
+// rawFeature is a feature as decoded from a file
+type rawFeature struct {
+	// id is the id of a GeoJSON feature
+	id         interface{}
+	geometry   geom.Geometry
+	properties map[string]interface{}
+}
+
+// format is a file format read by the provider
+type format struct {
+	name string
+	// extensions of the files of the format, the first being the default
+	extensions []string
+	read       func(b []byte) (features []rawFeature, srid uint64, geomType geom.Geometry, err error)
+}
+
+var (
+	formatGeoJSON = format{
+		name:       NameGeoJSON,
+		extensions: []string{".geojson", ".json"},
+		read: func(b []byte) ([]rawFeature, uint64, geom.Geometry, error) {
+			// GeoJSON coordinates are WGS84 per RFC 7946
+			features, geomType, err := readGeoJSON(b)
+			return features, tegola.WGS84, geomType, err
+		},
+	}
+	formatFlatGeobuf = format{
+		name:       NameFlatGeobuf,
+		extensions: []string{".fgb"},
+		read:       readFlatGeobuf,
+	}
+)
+
+type Provider struct {
+	// Filepath is the file, or directory of files, of the layers
+	Filepath string
+	format   format
+
+	// mu guards layers, which are replaced when their file is reloaded
+	mu     sync.RWMutex
+	layers map[string]*Layer
+
+	// reloadMu serializes the reloads so an older read never replaces a newer one
+	reloadMu sync.Mutex
+	watcher  *fsnotify.Watcher
+}
+
+func (p *Provider) Layers() ([]provider.LayerInfo, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ls := make([]provider.LayerInfo, 0, len(p.layers))
+	for _, l := range p.layers {
+		ls = append(ls, *l)
+	}
+	return ls, nil
+}
+
+func (p *Provider) TileFeatures(ctx context.Context, layer string, tile provider.Tile, queryParams provider.Params, fn func(f *provider.Feature) error) error {
+	p.mu.RLock()
+	l, ok := p.layers[layer]
+	p.mu.RUnlock()
+	if !ok {
+		return ErrUnknownLayer{Name: layer}
+	}
+
+	// read the tile extent
+	tileBBox, tileSRID := tile.BufferedExtent()
+
+	// convert the tile extent to the SRID of the layer
+	if l.srid != tileSRID {
+		minGeo, err := basic.FromWebMercator(l.srid, geom.Point{tileBBox.MinX(), tileBBox.MinY()})
+		if err != nil {
+			return fmt.Errorf("error converting point: %v ", err)
+		}
+
+		maxGeo, err := basic.FromWebMercator(l.srid, geom.Point{tileBBox.MaxX(), tileBBox.MaxY()})
+		if err != nil {
+			return fmt.Errorf("error converting point: %v ", err)
+		}
+
+		tileBBox = geom.NewExtent(minGeo.(geom.Point), maxGeo.(geom.Point))
+	}
+
+	for _, i := range l.index.Search(rtree.Extent(tileBBox.Extent())) {
+		// check if the context cancelled or timed out
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		f := l.features[i]
+		// the tags are copied as the features are shared by every tile
+		tags := make(map[string]interface{}, len(f.tags))
+		for k, v := range f.tags {
+			tags[k] = v
+		}
+
+		if err := fn(&provider.Feature{ID: f.id, Geometry: f.geometry, SRID: l.srid, Tags: tags}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// watch reloads the layers when their files change
+func (p *Provider) watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// files are often replaced by renaming a new file, so their directories are watched
+	dirs := make(map[string]bool)
+	for _, l := range p.layers {
+		dirs[filepath.Dir(l.filename)] = true
+	}
+	for dir := range dirs {
+		if err := w.Add(dir); err != nil {
+			w.Close()
+			return err
+		}
+	}
+
+	p.watcher = w
+	go p.watchLoop(w)
+	return nil
+}
+
+func (p *Provider) watchLoop(w *fsnotify.Watcher) {
+	// timers delay the reloads by file
+	timers := make(map[string]*time.Timer)
+	defer func() {
+		for _, t := range timers {
+			t.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+
+			filename := filepath.Clean(event.Name)
+			if !p.hasFile(filename) {
+				continue
+			}
+
+			if t, ok := timers[filename]; ok {
+				t.Reset(reloadDelay)
+				continue
+			}
+			timers[filename] = time.AfterFunc(reloadDelay, func() { p.reload(filename) })
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf("watching the files of the %v provider: %v", p.format.name, err)
+		}
+	}
+}
+
+// hasFile reports whether the file is the file of a layer
+func (p *Provider) hasFile(filename string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, l := range p.layers {
+		if l.filename == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// reload loads the layers of a file again. A layer whose file fails to load keeps its
+// previous features.
+func (p *Provider) reload(filename string) {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	var layers []Layer
+	p.mu.RLock()
+	for _, l := range p.layers {
+		if l.filename == filename {
+			layers = append(layers, *l)
+		}
+	}
+	p.mu.RUnlock()
+
+	for _, l := range layers {
+		loaded, err := l.load(p.format)
+		if err != nil {
+			log.Errorf("reloading layer (%v), keeping the previous features: %v", l.name, err)
+			continue
+		}
+
+		p.mu.Lock()
+		p.layers[l.name] = &loaded
+		p.mu.Unlock()
+
+		log.Infof("reloaded layer (%v) with %v features from %v", l.name, len(loaded.features), filename)
+	}
+}
+
+// Close stops reloading the layers
+func (p *Provider) Close() error {
+	if p.watcher == nil {
+		return nil
+	}
+	return p.watcher.Close()
+}
@@ -0,0 +1,298 @@
+package geofile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/provider"
+)
+
+const (
+	// places has points around Paris and one in Sydney
+	places = `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "id": 1, "geometry": {"type": "Point", "coordinates": [2.35, 48.85]}, "properties": {"name": "Paris", "ref": 75}},
+		{"type": "Feature", "id": 2, "geometry": {"type": "Point", "coordinates": [2.29, 48.86]}, "properties": {"name": "Eiffel Tower", "ref": 76}},
+		{"type": "Feature", "id": 3, "geometry": {"type": "Point", "coordinates": [151.2, -33.86]}, "properties": {"name": "Sydney", "ref": 2000}}
+	]}`
+	roads = `{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[2.3, 48.8], [2.4, 48.9]]}, "properties": {"class": "primary"}}`
+)
+
+func writeFile(t *testing.T, filename, data string) {
+	t.Helper()
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatalf("write %v, expected nil got %v", filename, err)
+	}
+}
+
+// tileFeatures returns the features of the layer in the tile of Paris at zoom 10
+func tileFeatures(t *testing.T, p provider.Tiler, layer string) []provider.Feature {
+	t.Helper()
+	var features []provider.Feature
+	err := p.TileFeatures(context.Background(), layer, provider.NewTile(10, 518, 352, 64, tegola.WebMercator), nil, func(f *provider.Feature) error {
+		features = append(features, *f)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("tile features, expected nil got %v", err)
+	}
+	return features
+}
+
+func TestNewTileProvider(t *testing.T) {
+	type tcase struct {
+		// files are written to the directory of the provider
+		files  map[string]string
+		config dict.Dict
+		// layers are the expected layer names
+		layers []string
+		expErr bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tc.files {
+				writeFile(t, filepath.Join(dir, name), data)
+			}
+
+			config := dict.Dict{ConfigKeyReload: false}
+			for k, v := range tc.config {
+				config[k] = v
+			}
+			if path, ok := config[ConfigKeyFilePath].(string); ok {
+				config[ConfigKeyFilePath] = filepath.Join(dir, path)
+			}
+
+			p, err := NewGeoJSONTileProvider(config, nil)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("expected an error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+
+			infos, err := p.Layers()
+			if err != nil {
+				t.Fatalf("layers, expected nil got %v", err)
+			}
+			var names []string
+			for _, info := range infos {
+				names = append(names, info.Name())
+			}
+			sort.Strings(names)
+			if len(names) != len(tc.layers) {
+				t.Fatalf("layers, expected %v got %v", tc.layers, names)
+			}
+			for i := range names {
+				if names[i] != tc.layers[i] {
+					t.Errorf("layers, expected %v got %v", tc.layers, names)
+				}
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"directory": {
+			files:  map[string]string{"places.geojson": places, "roads.json": roads, "README.md": "not a layer"},
+			config: dict.Dict{ConfigKeyFilePath: "."},
+			layers: []string{"places", "roads"},
+		},
+		"file": {
+			files:  map[string]string{"places.geojson": places, "roads.json": roads},
+			config: dict.Dict{ConfigKeyFilePath: "places.geojson"},
+			layers: []string{"places"},
+		},
+		"layers": {
+			files: map[string]string{"places.geojson": places, "roads.json": roads},
+			config: dict.Dict{
+				ConfigKeyFilePath: ".",
+				ConfigKeyLayers: []map[string]interface{}{
+					{ConfigKeyLayerName: "cities", ConfigKeyFileName: "places.geojson"},
+					{ConfigKeyLayerName: "roads"},
+				},
+			},
+			layers: []string{"cities", "roads"},
+		},
+		"missing file": {
+			files: map[string]string{"places.geojson": places},
+			config: dict.Dict{
+				ConfigKeyFilePath: ".",
+				ConfigKeyLayers:   []map[string]interface{}{{ConfigKeyLayerName: "roads"}},
+			},
+			expErr: true,
+		},
+		"duplicate layer": {
+			files:  map[string]string{"places.geojson": places, "places.json": places},
+			config: dict.Dict{ConfigKeyFilePath: "."},
+			expErr: true,
+		},
+		"no files": {
+			files:  map[string]string{"README.md": "not a layer"},
+			config: dict.Dict{ConfigKeyFilePath: "."},
+			expErr: true,
+		},
+		"invalid file": {
+			files:  map[string]string{"places.geojson": "{"},
+			config: dict.Dict{ConfigKeyFilePath: "."},
+			expErr: true,
+		},
+		"missing filepath": {
+			config: dict.Dict{ConfigKeyFilePath: "nope"},
+			expErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestTileFeatures(t *testing.T) {
+	type tcase struct {
+		layer map[string]interface{}
+		// features are the expected features by id
+		features map[uint64]map[string]interface{}
+	}
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "places.geojson"), places)
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			p, err := NewGeoJSONTileProvider(dict.Dict{
+				ConfigKeyFilePath: dir,
+				ConfigKeyReload:   false,
+				ConfigKeyLayers:   []map[string]interface{}{tc.layer},
+			}, nil)
+			if err != nil {
+				t.Fatalf("new provider, expected nil got %v", err)
+			}
+
+			features := tileFeatures(t, p, "places")
+			if len(features) != len(tc.features) {
+				t.Fatalf("features, expected %v got %+v", len(tc.features), features)
+			}
+			for _, f := range features {
+				tags, ok := tc.features[f.ID]
+				if !ok {
+					t.Errorf("unexpected feature %+v", f)
+					continue
+				}
+				if f.SRID != tegola.WGS84 {
+					t.Errorf("srid, expected %v got %v", tegola.WGS84, f.SRID)
+				}
+				if len(f.Tags) != len(tags) {
+					t.Errorf("tags, expected %v got %v", tags, f.Tags)
+				}
+				for k, v := range tags {
+					if f.Tags[k] != v {
+						t.Errorf("tag %v, expected %v got %v", k, v, f.Tags[k])
+					}
+				}
+				// the tags of the provider are not shared with the features
+				f.Tags["name"] = "changed"
+			}
+
+			// the tags changed by the previous features are not seen
+			for _, f := range tileFeatures(t, p, "places") {
+				if f.Tags["name"] == "changed" {
+					t.Errorf("tags, expected a copy for each tile")
+				}
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"all properties": {
+			layer: map[string]interface{}{ConfigKeyLayerName: "places"},
+			features: map[uint64]map[string]interface{}{
+				1: {"name": "Paris", "ref": int64(75)},
+				2: {"name": "Eiffel Tower", "ref": int64(76)},
+			},
+		},
+		"fields and id field": {
+			layer: map[string]interface{}{
+				ConfigKeyLayerName:   "places",
+				ConfigKeyGeomIDField: "ref",
+				ConfigKeyFields:      []string{"name"},
+			},
+			features: map[uint64]map[string]interface{}{
+				75: {"name": "Paris"},
+				76: {"name": "Eiffel Tower"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+
+	t.Run("unknown layer", func(t *testing.T) {
+		p, err := NewGeoJSONTileProvider(dict.Dict{ConfigKeyFilePath: dir, ConfigKeyReload: false}, nil)
+		if err != nil {
+			t.Fatalf("new provider, expected nil got %v", err)
+		}
+		err = p.TileFeatures(context.Background(), "roads", provider.NewTile(0, 0, 0, 64, tegola.WebMercator), nil, func(*provider.Feature) error { return nil })
+		if _, ok := err.(ErrUnknownLayer); !ok {
+			t.Errorf("expected ErrUnknownLayer got %v", err)
+		}
+	})
+}
+
+func TestReload(t *testing.T) {
+	defer func(d time.Duration) { reloadDelay = d }(reloadDelay)
+	reloadDelay = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "places.geojson")
+	writeFile(t, filename, places)
+
+	p, err := NewGeoJSONTileProvider(dict.Dict{ConfigKeyFilePath: dir}, nil)
+	if err != nil {
+		t.Fatalf("new provider, expected nil got %v", err)
+	}
+	defer p.(*Provider).Close()
+
+	if n := len(tileFeatures(t, p, "places")); n != 2 {
+		t.Fatalf("features, expected 2 got %v", n)
+	}
+
+	// waitFeatures waits for the layer to have n features in the tile
+	waitFeatures := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for len(tileFeatures(t, p, "places")) != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("features, expected %v got %v", n, len(tileFeatures(t, p, "places")))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// replace the file by renaming a new file over it
+	tmp := filepath.Join(dir, "places.tmp")
+	writeFile(t, tmp, roads)
+	if err := os.Rename(tmp, filename); err != nil {
+		t.Fatalf("rename, expected nil got %v", err)
+	}
+	waitFeatures(1)
+
+	// an invalid file keeps the previous features
+	writeFile(t, filename, "{")
+	time.Sleep(20 * reloadDelay)
+	if n := len(tileFeatures(t, p, "places")); n != 1 {
+		t.Errorf("features after an invalid file, expected 1 got %v", n)
+	}
+
+	writeFile(t, filename, places)
+	waitFeatures(2)
+}
@@ -0,0 +1,120 @@
+package geofile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+)
+
+// geojsonObject is a GeoJSON Feature or FeatureCollection
+type geojsonObject struct {
+	Type string `json:"type"`
+	// Features are the features of a FeatureCollection
+	Features []geojsonFeature `json:"features"`
+	// the members of a Feature
+	geojsonFeature
+}
+
+type geojsonFeature struct {
+	ID         interface{}            `json:"id"`
+	Geometry   *geojson.Geometry      `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// readGeoJSON decodes the features of a GeoJSON FeatureCollection or Feature. Numbers are
+// decoded as int64 when they are integers and float64 otherwise.
+func readGeoJSON(b []byte) ([]rawFeature, geom.Geometry, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var obj geojsonObject
+	if err := dec.Decode(&obj); err != nil {
+		return nil, nil, err
+	}
+
+	var gjFeatures []geojsonFeature
+	switch strings.ToLower(obj.Type) {
+	case "featurecollection":
+		gjFeatures = obj.Features
+	case "feature":
+		gjFeatures = []geojsonFeature{obj.geojsonFeature}
+	default:
+		return nil, nil, fmt.Errorf("unsupported GeoJSON type (%v). expecting FeatureCollection or Feature", obj.Type)
+	}
+
+	var (
+		features = make([]rawFeature, 0, len(gjFeatures))
+		geomType geom.Geometry
+		mixed    bool
+	)
+	for _, gf := range gjFeatures {
+		f := rawFeature{
+			id:         jsonValue(gf.ID),
+			properties: make(map[string]interface{}, len(gf.Properties)),
+		}
+		for k, v := range gf.Properties {
+			f.properties[k] = jsonValue(v)
+		}
+		if gf.Geometry != nil {
+			f.geometry = gf.Geometry.Geometry
+		}
+		features = append(features, f)
+
+		// the layer has a geometry type when all the features have the same
+		switch {
+		case f.geometry == nil || mixed:
+		case geomType == nil:
+			geomType = f.geometry
+		case fmt.Sprintf("%T", geomType) != fmt.Sprintf("%T", f.geometry):
+			geomType, mixed = nil, true
+		}
+	}
+
+	return features, emptyGeometry(geomType), nil
+}
+
+// jsonValue converts decoded JSON numbers to int64 or float64. Objects and arrays are
+// encoded back to JSON strings, as MVT tags can't be nested.
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return nil
+		}
+		return string(b)
+	default:
+		return v
+	}
+}
+
+// emptyGeometry returns the zero value of the type of the geometry, used as the geometry
+// type of a layer
+func emptyGeometry(g geom.Geometry) geom.Geometry {
+	switch g.(type) {
+	case geom.Point:
+		return geom.Point{}
+	case geom.MultiPoint:
+		return geom.MultiPoint{}
+	case geom.LineString:
+		return geom.LineString{}
+	case geom.MultiLineString:
+		return geom.MultiLineString{}
+	case geom.Polygon:
+		return geom.Polygon{}
+	case geom.MultiPolygon:
+		return geom.MultiPolygon{}
+	default:
+		return nil
+	}
+}
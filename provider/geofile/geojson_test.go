@@ -0,0 +1,96 @@
+package geofile
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-spatial/geom"
+)
+
+func TestReadGeoJSON(t *testing.T) {
+	type tcase struct {
+		data     string
+		geomType geom.Geometry
+		features []rawFeature
+		expErr   bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			features, geomType, err := readGeoJSON([]byte(tc.data))
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("expected an error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if !reflect.DeepEqual(geomType, tc.geomType) {
+				t.Errorf("geometry type, expected %T got %T", tc.geomType, geomType)
+			}
+			if !reflect.DeepEqual(features, tc.features) {
+				t.Errorf("features, expected %+v got %+v", tc.features, features)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"feature collection": {
+			data: `{"type": "FeatureCollection", "features": [
+				{"type": "Feature", "id": 7, "geometry": {"type": "Point", "coordinates": [2.35, 48.85]},
+					"properties": {"name": "Paris", "population": 2100000, "area": 105.4, "capital": true, "tags": {"a": 1}, "note": null}},
+				{"type": "Feature", "id": "node/42", "geometry": {"type": "Point", "coordinates": [13.4, 52.52]}, "properties": null},
+				{"type": "Feature", "geometry": null, "properties": {"name": "nowhere"}}
+			]}`,
+			geomType: geom.Point{},
+			features: []rawFeature{
+				{
+					id:       int64(7),
+					geometry: geom.Point{2.35, 48.85},
+					properties: map[string]interface{}{
+						"name": "Paris", "population": int64(2100000), "area": 105.4, "capital": true, "tags": `{"a":1}`, "note": nil,
+					},
+				},
+				{
+					id:         "node/42",
+					geometry:   geom.Point{13.4, 52.52},
+					properties: map[string]interface{}{},
+				},
+				{
+					properties: map[string]interface{}{"name": "nowhere"},
+				},
+			},
+		},
+		"mixed types": {
+			data: `{"type": "FeatureCollection", "features": [
+				{"type": "Feature", "geometry": {"type": "Point", "coordinates": [0, 0]}},
+				{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[0, 0], [1, 1]]}}
+			]}`,
+			features: []rawFeature{
+				{geometry: geom.Point{0, 0}, properties: map[string]interface{}{}},
+				{geometry: geom.LineString{{0, 0}, {1, 1}}, properties: map[string]interface{}{}},
+			},
+		},
+		"feature": {
+			data:     `{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}, "properties": {"id": "a"}}`,
+			geomType: geom.Polygon{},
+			features: []rawFeature{
+				{geometry: geom.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}, properties: map[string]interface{}{"id": "a"}},
+			},
+		},
+		"geometry": {
+			data:   `{"type": "Point", "coordinates": [0, 0]}`,
+			expErr: true,
+		},
+		"invalid json": {
+			data:   `{"type": "FeatureCollection", "features": [`,
+			expErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
@@ -0,0 +1,106 @@
+package geofile
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/internal/rtree"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// feature is a feature of a layer with the tags the layer is configured to include
+type feature struct {
+	id       uint64
+	geometry geom.Geometry
+	tags     map[string]interface{}
+}
+
+type Layer struct {
+	name string
+	// filename is the path of the file of the layer
+	filename    string
+	idFieldname string
+	// fields are the properties included as tags. All the properties are included when empty
+	fields []string
+
+	// the following are set when the file is loaded, and replaced when it's reloaded
+	geomType geom.Geometry
+	srid     uint64
+	features []feature
+	index    *rtree.RTree
+}
+
+func (l Layer) Name() string            { return l.name }
+func (l Layer) GeomType() geom.Geometry { return l.geomType }
+func (l Layer) SRID() uint64            { return l.srid }
+
+// load reads the file of the layer and returns a copy of the layer with its features
+// and their index
+func (l Layer) load(f format) (Layer, error) {
+	b, err := os.ReadFile(l.filename)
+	if err != nil {
+		return l, err
+	}
+
+	raw, srid, geomType, err := f.read(b)
+	if err != nil {
+		return l, fmt.Errorf("reading %v: %w", l.filename, err)
+	}
+
+	// the tile extents are converted to the srid of the layer
+	if srid != tegola.WGS84 && srid != tegola.WebMercator {
+		return l, fmt.Errorf("reading %v: unsupported srid %v. expecting %v or %v", l.filename, srid, tegola.WGS84, tegola.WebMercator)
+	}
+	l.srid, l.geomType = srid, geomType
+	l.features = make([]feature, 0, len(raw))
+	extents := make([]rtree.Extent, 0, len(raw))
+
+	for i, rf := range raw {
+		if rf.geometry == nil {
+			continue
+		}
+		// empty geometries have no extent and are never part of a tile
+		ext, err := geom.NewExtentFromGeometry(rf.geometry)
+		if err != nil {
+			continue
+		}
+
+		ft := feature{
+			geometry: rf.geometry,
+			tags:     make(map[string]interface{}, len(rf.properties)),
+		}
+
+		if l.idFieldname != "" {
+			if v, ok := rf.properties[l.idFieldname]; ok && v != nil {
+				if ft.id, err = provider.ConvertFeatureID(v); err != nil {
+					return l, fmt.Errorf("reading %v: feature %v: %w", l.filename, i, err)
+				}
+			}
+		} else if rf.id != nil {
+			// GeoJSON ids may be strings which are not numbers. those are left out
+			ft.id, _ = provider.ConvertFeatureID(rf.id)
+		}
+
+		if len(l.fields) > 0 {
+			for _, name := range l.fields {
+				if v, ok := rf.properties[name]; ok && v != nil {
+					ft.tags[name] = v
+				}
+			}
+		} else {
+			for name, v := range rf.properties {
+				if name != l.idFieldname && v != nil {
+					ft.tags[name] = v
+				}
+			}
+		}
+
+		l.features = append(l.features, ft)
+		extents = append(extents, rtree.Extent(ext.Extent()))
+	}
+
+	l.index = rtree.New(extents, rtree.DefaultNodeSize)
+	return l, nil
+}
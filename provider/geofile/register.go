@@ -0,0 +1,229 @@
+package geofile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/provider"
+)
+
+func init() {
+	provider.Register(provider.TypeStd.Prefix()+NameGeoJSON, NewGeoJSONTileProvider, Cleanup)
+	provider.Register(provider.TypeStd.Prefix()+NameFlatGeobuf, NewFlatGeobufTileProvider, Cleanup)
+}
+
+// NewGeoJSONTileProvider instantiates a provider of the layers of GeoJSON files
+func NewGeoJSONTileProvider(config dict.Dicter, _ []provider.Map) (provider.Tiler, error) {
+	return newTileProvider(formatGeoJSON, config)
+}
+
+// NewFlatGeobufTileProvider instantiates a provider of the layers of FlatGeobuf files
+func NewFlatGeobufTileProvider(config dict.Dicter, _ []provider.Map) (provider.Tiler, error) {
+	return newTileProvider(formatFlatGeobuf, config)
+}
+
+func newTileProvider(f format, config dict.Dicter) (*Provider, error) {
+	path, err := config.String(ConfigKeyFilePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, ErrInvalidFilePath{path}
+	}
+	path = filepath.Clean(path)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, ErrInvalidFilePath{path}
+	}
+
+	reload := true
+	if reload, err = config.Bool(ConfigKeyReload, &reload); err != nil {
+		return nil, err
+	}
+
+	layers, err := config.MapSlice(ConfigKeyLayers)
+	if err != nil {
+		return nil, err
+	}
+
+	var configured []Layer
+	if len(layers) == 0 {
+		configured, err = discoverLayers(f, path, fi.IsDir())
+	} else {
+		configured, err = configLayers(f, path, fi.IsDir(), layers)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := Provider{
+		Filepath: path,
+		format:   f,
+		layers:   make(map[string]*Layer, len(configured)),
+	}
+	for _, l := range configured {
+		loaded, err := l.load(f)
+		if err != nil {
+			return nil, fmt.Errorf("for layer (%v): %w", l.name, err)
+		}
+		p.layers[l.name] = &loaded
+		log.Infof("loaded layer (%v) with %v features from %v", l.name, len(loaded.features), l.filename)
+	}
+
+	if reload {
+		if err := p.watch(); err != nil {
+			return nil, fmt.Errorf("watching %v: %w", path, err)
+		}
+	}
+
+	// track the provider so we can clean it up later
+	providersLock.Lock()
+	providers = append(providers, &p)
+	providersLock.Unlock()
+
+	return &p, nil
+}
+
+// hasExtension reports whether the file has one of the extensions of the format
+func (f format) hasExtension(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, e := range f.extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// discoverLayers returns a layer for each file of the format, named after the file
+// without its extension
+func discoverLayers(f format, path string, isDir bool) ([]Layer, error) {
+	layerFor := func(filename string) Layer {
+		base := filepath.Base(filename)
+		return Layer{name: strings.TrimSuffix(base, filepath.Ext(base)), filename: filename}
+	}
+
+	if !isDir {
+		return []Layer{layerFor(path)}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		layers []Layer
+		seen   = make(map[string]string)
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || !f.hasExtension(entry.Name()) {
+			continue
+		}
+
+		l := layerFor(filepath.Join(path, entry.Name()))
+		if other, ok := seen[l.name]; ok {
+			return nil, fmt.Errorf("files %v and %v are both layer (%v)", other, entry.Name(), l.name)
+		}
+		seen[l.name] = entry.Name()
+		layers = append(layers, l)
+	}
+
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("directory %v has no %v files (%v)", path, f.name, strings.Join(f.extensions, ", "))
+	}
+	return layers, nil
+}
+
+// configLayers returns the layers of the layers config. The filename of a layer is
+// relative to the directory of the provider, and defaults to the layer name with an
+// extension of the format.
+func configLayers(f format, path string, isDir bool, layers []dict.Dicter) ([]Layer, error) {
+	var (
+		configured []Layer
+		lyrsSeen   = make(map[string]int)
+	)
+	for i, layerConf := range layers {
+		layerName, err := layerConf.String(ConfigKeyLayerName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("for layer (%v) we got the following error trying to get the layer's name field: %v", i, err)
+		}
+		if layerName == "" {
+			return nil, ErrMissingLayerName
+		}
+
+		// check if we have already seen this layer
+		if j, ok := lyrsSeen[layerName]; ok {
+			return nil, fmt.Errorf("layer name (%v) is duplicated in both layer %v and layer %v", layerName, i, j)
+		}
+		lyrsSeen[layerName] = i
+
+		var filename string
+		if filename, err = layerConf.String(ConfigKeyFileName, &filename); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+
+		switch {
+		case !isDir:
+			if filename != "" && filename != filepath.Base(path) {
+				return nil, fmt.Errorf("for layer (%v) %v : %v is only supported when %v is a directory", i, layerName, ConfigKeyFileName, ConfigKeyFilePath)
+			}
+			filename = path
+		case filename != "":
+			filename = filepath.Join(path, filename)
+		default:
+			for _, ext := range f.extensions {
+				if _, err := os.Stat(filepath.Join(path, layerName+ext)); err == nil {
+					filename = filepath.Join(path, layerName+ext)
+					break
+				}
+			}
+			if filename == "" {
+				return nil, fmt.Errorf("for layer (%v) %v : no %v file named %v in %v", i, layerName, f.name, layerName, path)
+			}
+		}
+
+		var idFieldname string
+		if idFieldname, err = layerConf.String(ConfigKeyGeomIDField, &idFieldname); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+
+		fields, err := layerConf.StringSlice(ConfigKeyFields)
+		if err != nil { // empty slices are okay
+			return nil, fmt.Errorf("for layer (%v) %v, %q field had the following error: %v", i, layerName, ConfigKeyFields, err)
+		}
+
+		configured = append(configured, Layer{
+			name:        layerName,
+			filename:    filename,
+			idFieldname: idFieldname,
+			fields:      fields,
+		})
+	}
+	return configured, nil
+}
+
+var (
+	providersLock sync.Mutex
+	// reference to all instantiated providers
+	providers []*Provider
+)
+
+// Cleanup stops reloading the layers of all the previously instantiated providers
+func Cleanup() {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+
+	for _, p := range providers {
+		if err := p.Close(); err != nil {
+			log.Errorf("err closing file watcher: %v", err)
+		}
+	}
+	providers = nil
+}
@@ -0,0 +1,18 @@
+package geoparquet
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	EnvSQLDebugName    = "TEGOLA_SQL_DEBUG"
+	EnvSQLDebugExecute = "EXECUTE_SQL"
+)
+
+// debugExecuteSQL logs the sql of the sql layers when they are executed
+var debugExecuteSQL bool
+
+func init() {
+	debugExecuteSQL = strings.Contains(os.Getenv(EnvSQLDebugName), EnvSQLDebugExecute)
+}
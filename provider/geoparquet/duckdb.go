@@ -0,0 +1,269 @@
+package geoparquet
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/wkb"
+	"github.com/go-spatial/tegola/basic"
+	"github.com/go-spatial/tegola/config"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// driverName is the database/sql driver the sql layers are executed with. DuckDB
+// drivers require cgo and the DuckDB library, so none is linked by default. The duckdb
+// build tag links one, see duckdb_cgo.go.
+var driverName = "duckdb"
+
+// openDuckDB opens the DuckDB database at path, or an in-memory database when path is
+// empty, and loads its spatial extension from extension, or from the extensions
+// installed in the DuckDB home directory when extension is empty. The extension is
+// never installed, as that downloads it, which fails on hosts without network access.
+func openDuckDB(ctx context.Context, path, extension string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return nil, ErrDuckDBDriver{Err: err}
+	}
+
+	stmt := "LOAD spatial"
+	if extension != "" {
+		stmt = "LOAD '" + strings.ReplaceAll(extension, "'", "''") + "'"
+	}
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("geoparquet: loading the DuckDB spatial extension (%v), install it with INSTALL spatial or set %v: %w", stmt, ConfigKeyDuckDBSpatialExtension, err)
+	}
+	return db, nil
+}
+
+// replaceTokens replaces tokens in the provided SQL string
+//
+// !BBOX! - the bounding box of the tile, as an ST_MakeEnvelope in the SRID of the layer
+// !ZOOM! - the tile Z value
+// !X! - the tile X value
+// !Y! - the tile Y value
+// !Z! - the tile Z value
+// !SCALE_DENOMINATOR! - scale denominator, assuming 90.7 DPI (i.e. 0.28mm pixel size)
+// !PIXEL_WIDTH! - the pixel width in meters, assuming 256x256 tiles
+// !PIXEL_HEIGHT! - the pixel height in meters, assuming 256x256 tiles
+// !ID_FIELD! - the id field name
+// !GEOM_FIELD! - the geom field name
+// !GEOM_TYPE! - the geom field type if defined otherwise ""
+func replaceTokens(sql string, l *Layer, tile provider.Tile) (string, error) {
+	extent, _ := tile.BufferedExtent()
+
+	minGeo, err := basic.FromWebMercator(l.srid, geom.Point{extent.MinX(), extent.MinY()})
+	if err != nil {
+		return "", fmt.Errorf("error converting point: %w", err)
+	}
+	maxGeo, err := basic.FromWebMercator(l.srid, geom.Point{extent.MaxX(), extent.MaxY()})
+	if err != nil {
+		return "", fmt.Errorf("error converting point: %w", err)
+	}
+	minPt, maxPt := minGeo.(geom.Point), maxGeo.(geom.Point)
+
+	bbox := fmt.Sprintf("ST_MakeEnvelope(%.8f, %.8f, %.8f, %.8f)", minPt.X(), minPt.Y(), maxPt.X(), maxPt.Y())
+
+	extent, _ = tile.Extent()
+	// TODO: Always convert to meter if we support different projections
+	pixelWidth := (extent.MaxX() - extent.MinX()) / 256
+	pixelHeight := (extent.MaxY() - extent.MinY()) / 256
+	scaleDenominator := pixelWidth / 0.00028 /* px size in m */
+
+	var geoType string
+	if l.geomType != nil {
+		geoType = fmt.Sprintf("%v", l.geomType)
+	}
+
+	// replace query string tokens
+	z, x, y := tile.ZXY()
+	tokenReplacer := strings.NewReplacer(
+		config.BboxToken, bbox,
+		config.ZoomToken, strconv.FormatUint(uint64(z), 10),
+		config.ZToken, strconv.FormatUint(uint64(z), 10),
+		config.XToken, strconv.FormatUint(uint64(x), 10),
+		config.YToken, strconv.FormatUint(uint64(y), 10),
+		config.ScaleDenominatorToken, strconv.FormatFloat(scaleDenominator, 'f', 8, 64),
+		config.PixelWidthToken, strconv.FormatFloat(pixelWidth, 'f', 8, 64),
+		config.PixelHeightToken, strconv.FormatFloat(pixelHeight, 'f', 8, 64),
+		config.IdFieldToken, l.idFieldname,
+		config.GeomFieldToken, l.geomFieldname,
+		config.GeomTypeToken, geoType,
+	)
+
+	// tokens can be in any case
+	uppercaseTokenSQL := provider.ParameterTokenRegexp.ReplaceAllStringFunc(sql, strings.ToUpper)
+
+	return tokenReplacer.Replace(uppercaseTokenSQL), nil
+}
+
+// sqlFeatures calls fn for the features of the rows of the sql of the layer
+func (p *Provider) sqlFeatures(ctx context.Context, l *Layer, tile provider.Tile, params provider.Params, fn func(f *provider.Feature) error) error {
+	query, err := replaceTokens(l.sql, l, tile)
+	if err != nil {
+		return fmt.Errorf("error replacing layer tokens for layer (%v) SQL (%v): %w", l.name, l.sql, err)
+	}
+
+	// replace configured query parameters if any
+	args := make([]interface{}, 0)
+	query = params.ReplaceParams(query, &args)
+
+	if debugExecuteSQL {
+		log.Debugf("TEGOLA_SQL_DEBUG:EXECUTE_SQL for layer (%v): %v with args %v", l.name, query, args)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("error running layer (%v) SQL (%v) with args %v: %w", l.name, query, args, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("error running layer (%v) SQL (%v): %w", l.name, query, err)
+	}
+	geomCol := -1
+	for i, name := range columns {
+		if name == l.geomFieldname {
+			geomCol = i
+		}
+	}
+	if geomCol == -1 {
+		return ErrGeomFieldNotFound{GeomFieldName: l.geomFieldname, LayerName: l.name}
+	}
+
+	var (
+		vals     = make([]any, len(columns))
+		reported bool
+	)
+	for rows.Next() {
+		// context check
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		for i := range vals {
+			vals[i] = new(any)
+		}
+		if err := rows.Scan(vals...); err != nil {
+			return fmt.Errorf("error running layer (%v) SQL (%v): %w", l.name, query, err)
+		}
+
+		feature := provider.Feature{
+			SRID: l.srid,
+			Tags: make(map[string]interface{}, len(columns)),
+		}
+		var geobytes []byte
+		for i, name := range columns {
+			v := *(vals[i].(*any))
+			if v == nil {
+				continue
+			}
+
+			switch {
+			case i == geomCol:
+				var ok bool
+				if geobytes, ok = v.([]byte); !ok {
+					return fmt.Errorf("unable to convert geometry field (%v) of layer (%v) into bytes, select it with ST_AsWKB", name, l.name)
+				}
+				continue
+			case name == l.idFieldname:
+				if feature.ID, err = provider.ConvertFeatureID(sqlValue(v)); err != nil {
+					return fmt.Errorf("for layer (%v) id field (%v): %w", l.name, name, err)
+				}
+				continue
+			}
+
+			tag, err := sqlTagValue(v)
+			if err != nil {
+				return fmt.Errorf("unable to convert field (%v) of layer (%v): %w", name, l.name, err)
+			}
+			feature.Tags[name] = tag
+		}
+
+		// check that we have geometry data. if not, skip the feature
+		if len(geobytes) == 0 {
+			continue
+		}
+		if feature.Geometry, err = wkb.DecodeBytes(geobytes); err != nil {
+			if _, ok := err.(wkb.ErrUnknownGeometryType); ok {
+				// only report to the log once per tile
+				if !reported {
+					reported = true
+					log.Warnf("Ignoring unsupported geometry in layer (%v). Only basic 2D geometry type are supported.", l.name)
+				}
+				continue
+			}
+			return fmt.Errorf("unable to decode layer (%v) geometry field (%v) into wkb where (%v = %v): %w", l.name, l.geomFieldname, l.idFieldname, feature.ID, err)
+		}
+
+		// pass the feature to the provided callback
+		if err := fn(&feature); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// sqlValue normalizes the integers and floats of the DuckDB driver to int64, uint64
+// and float64
+func sqlValue(v any) any {
+	switch v := v.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint:
+		return uint64(v)
+	case float32:
+		return float64(v)
+	case *big.Int:
+		// HUGEINT
+		if v.IsInt64() {
+			return v.Int64()
+		}
+		if v.IsUint64() {
+			return v.Uint64()
+		}
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f
+	case interface{ Float64() float64 }:
+		// DECIMAL
+		return v.Float64()
+	default:
+		return v
+	}
+}
+
+// sqlTagValue converts a value of the DuckDB driver to a tag value
+func sqlTagValue(v any) (any, error) {
+	switch v := sqlValue(v).(type) {
+	case int64, uint64, float64, bool, string:
+		return v, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	default:
+		return nil, fmt.Errorf("%T values are not supported, cast the field to a string or number in the sql", v)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build cgo && duckdb
+
+package geoparquet
+
+// the DuckDB driver registers itself as driverName. it needs cgo, and is only
+// linked with the duckdb build tag.
+import _ "github.com/marcboeker/go-duckdb"
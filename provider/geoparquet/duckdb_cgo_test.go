@@ -0,0 +1,76 @@
+//go:build cgo && duckdb
+
+package geoparquet
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// the query of the points of the north east quadrant, by their bbox covering
+const northEastSQL = "FROM read_parquet('testdata/points.parquet') WHERE bbox.xmin >= 0 AND bbox.ymin >= 0"
+
+func TestDuckDBDriver(t *testing.T) {
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		t.Fatalf("open, expected nil got %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err = db.QueryRow("SELECT count(*) " + northEastSQL).Scan(&count); err != nil {
+		t.Fatalf("query, expected nil got %v", err)
+	}
+	if count != 25 {
+		t.Errorf("count, expected 25 got %v", count)
+	}
+}
+
+func TestDuckDBSQLLayer(t *testing.T) {
+	config := dict.Dict{
+		ConfigKeyLayers: []map[string]interface{}{
+			{
+				ConfigKeyLayerName:   "points",
+				ConfigKeyGeomIDField: "id",
+				ConfigKeySQL:         "SELECT id, geometry " + northEastSQL + " AND !ZOOM! >= 0",
+			},
+		},
+	}
+	p, err := NewTileProvider(config, nil)
+	if err != nil && strings.Contains(err.Error(), "spatial extension") {
+		// the extension must be installed beforehand
+		t.Skipf("the DuckDB spatial extension is not available: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	defer p.(*Provider).Close()
+
+	expected := make(map[geom.Point]bool, 25)
+	for row := 25; row < 50; row++ {
+		expected[point(row)] = true
+	}
+
+	var count int
+	err = p.TileFeatures(context.Background(), "points", provider.NewTile(0, 0, 0, 0, tegola.WebMercator), nil, func(f *provider.Feature) error {
+		pt, ok := f.Geometry.(geom.Point)
+		if !ok || !expected[pt] {
+			t.Errorf("feature %v, expected a point of the north east quadrant got %v", f.ID, f.Geometry)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("tile features, expected nil got %v", err)
+	}
+	if count != 25 {
+		t.Errorf("features, expected 25 got %v", count)
+	}
+}
@@ -0,0 +1,51 @@
+package geoparquet
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrMissingLayerName = errors.New("geoparquet: layer is missing 'name'")
+)
+
+type ErrInvalidFilePath struct {
+	FilePath string
+}
+
+func (e ErrInvalidFilePath) Error() string {
+	return fmt.Sprintf("geoparquet: invalid filepath: %v", e.FilePath)
+}
+
+// ErrUnknownLayer is returned when features are requested for a layer the provider
+// doesn't have
+type ErrUnknownLayer struct {
+	Name string
+}
+
+func (e ErrUnknownLayer) Error() string {
+	return fmt.Sprintf("geoparquet: unknown layer (%v)", e.Name)
+}
+
+// ErrDuckDBDriver is returned for sql layers when no DuckDB driver is linked into the
+// binary
+type ErrDuckDBDriver struct {
+	Err error
+}
+
+func (e ErrDuckDBDriver) Error() string {
+	return fmt.Sprintf("geoparquet: sql layers require a DuckDB database/sql driver registered as %q, linked with the duckdb build tag: %v", driverName, e.Err)
+}
+
+func (e ErrDuckDBDriver) Unwrap() error { return e.Err }
+
+// ErrGeomFieldNotFound is returned when the result of the sql of a layer has no
+// geometry field
+type ErrGeomFieldNotFound struct {
+	GeomFieldName string
+	LayerName     string
+}
+
+func (e ErrGeomFieldNotFound) Error() string {
+	return fmt.Sprintf("geoparquet: geometry field (%v) not found for layer (%v)", e.GeomFieldName, e.LayerName)
+}
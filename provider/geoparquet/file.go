@@ -0,0 +1,358 @@
+package geoparquet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/wkb"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/internal/parquet"
+	"github.com/go-spatial/tegola/internal/rtree"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// parquetFile is a GeoParquet file of a layer
+type parquetFile struct {
+	filename string
+	fh       *os.File
+	file     *parquet.File
+
+	// extent is the bbox of the geo metadata of the file, or nil
+	extent *rtree.Extent
+	srid   uint64
+	// geomType is the type of all the geometries of the file, or nil
+	geomType geom.Geometry
+
+	// the indices of the columns of the geometries, the bbox covering, or -1 without a
+	// covering, the id, or -1 without an id, and the tags
+	geom     int
+	covering [4]int
+	id       int
+	tags     []int
+
+	// rowOffsets are the numbers of the first rows of the row groups, counted across
+	// the files of the layer, which are the ids of the features without an id column
+	rowOffsets []int64
+}
+
+// openFile opens a GeoParquet file. The geometry column defaults to the primary column
+// of the geo metadata, and the tags to all the columns except for the geometry,
+// covering and id columns.
+func openFile(filename string, geomFieldname, idFieldname string, fields []string) (_ *parquetFile, err error) {
+	pf := &parquetFile{filename: filename, covering: [4]int{-1, -1, -1, -1}, id: -1}
+
+	if pf.fh, err = os.Open(filename); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			pf.fh.Close()
+		}
+	}()
+
+	fi, err := pf.fh.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if pf.file, err = parquet.Open(pf.fh, fi.Size()); err != nil {
+		return nil, fmt.Errorf("reading %v: %w", filename, err)
+	}
+
+	var md geoColumnMetadata
+	switch v, ok := pf.file.KeyValue("geo"); {
+	case ok:
+		geo, err := parseGeoMetadata(v)
+		if err != nil {
+			return nil, fmt.Errorf("reading %v: %w", filename, err)
+		}
+		if geomFieldname == "" {
+			geomFieldname = geo.PrimaryColumn
+		}
+		if md, ok = geo.Columns[geomFieldname]; !ok {
+			return nil, fmt.Errorf("%v has no geometry column %q", filename, geomFieldname)
+		}
+		if !strings.EqualFold(md.Encoding, "WKB") {
+			return nil, fmt.Errorf("%v: unsupported encoding %q of geometry column %q, expecting WKB", filename, md.Encoding, geomFieldname)
+		}
+		pf.srid = md.srid()
+
+	case geomFieldname == "":
+		return nil, fmt.Errorf("%v has no geo metadata, set the %v of its WKB geometry column", filename, ConfigKeyGeomField)
+	}
+
+	if pf.geom = pf.file.Column(geomFieldname); pf.geom == -1 {
+		return nil, fmt.Errorf("%v has no column %q", filename, geomFieldname)
+	}
+	if c := pf.file.Columns()[pf.geom]; c.Type != parquet.ByteArray || c.Repeated() {
+		return nil, fmt.Errorf("%v: geometry column %q is not a binary column", filename, geomFieldname)
+	}
+	if len(md.BBox) == 4 {
+		pf.extent = &rtree.Extent{md.BBox[0], md.BBox[1], md.BBox[2], md.BBox[3]}
+	}
+	pf.geomType = md.geomType()
+
+	if names, ok := md.coveringColumns(); ok {
+		for i, name := range names {
+			if pf.covering[i] = pf.file.Column(name); pf.covering[i] == -1 {
+				return nil, fmt.Errorf("%v has no bbox covering column %q", filename, name)
+			}
+		}
+	}
+
+	if idFieldname != "" {
+		if pf.id = pf.file.Column(idFieldname); pf.id == -1 {
+			return nil, fmt.Errorf("%v has no column %q", filename, idFieldname)
+		}
+	}
+
+	if len(fields) != 0 {
+		for _, name := range fields {
+			col := pf.file.Column(name)
+			if col == -1 {
+				return nil, fmt.Errorf("%v has no column %q", filename, name)
+			}
+			if pf.file.Columns()[col].Repeated() {
+				return nil, fmt.Errorf("%v: column %q is a list, which is not supported", filename, name)
+			}
+			pf.tags = append(pf.tags, col)
+		}
+	} else {
+		for col, c := range pf.file.Columns() {
+			if col == pf.geom || col == pf.id || slices.Contains(pf.covering[:], col) || c.Repeated() {
+				continue
+			}
+			pf.tags = append(pf.tags, col)
+		}
+	}
+
+	return pf, nil
+}
+
+// setRowOffset numbers the rows of the file from offset, and returns the offset of the
+// next file
+func (pf *parquetFile) setRowOffset(offset int64) int64 {
+	pf.rowOffsets = make([]int64, pf.file.NumRowGroups())
+	for rg := range pf.rowOffsets {
+		pf.rowOffsets[rg] = offset
+		offset += pf.file.RowGroupNumRows(rg)
+	}
+	return offset
+}
+
+// rowGroupExtent returns the extent of the geometries of a row group, from the
+// statistics of its bbox covering columns or else the geospatial statistics of its
+// geometry column. It returns false when the row group has no statistics.
+func (pf *parquetFile) rowGroupExtent(rg int) (rtree.Extent, bool) {
+	if pf.covering[0] != -1 {
+		var e rtree.Extent
+		for i, col := range pf.covering {
+			min, max, ok := pf.file.Statistics(rg, col)
+			if !ok {
+				return rtree.Extent{}, false
+			}
+			// the min of the mins and max of the maxes
+			v := min
+			if i >= 2 {
+				v = max
+			}
+			if e[i], ok = toFloat(v); !ok {
+				return rtree.Extent{}, false
+			}
+		}
+		return e, true
+	}
+
+	if bbox, ok := pf.file.GeospatialStatistics(rg, pf.geom); ok {
+		return rtree.Extent(bbox), true
+	}
+	return rtree.Extent{}, false
+}
+
+// features calls fn for the features of the file which intersect the extent, skipping
+// the row groups whose statistics show they don't
+func (pf *parquetFile) features(ctx context.Context, extent rtree.Extent, srid uint64, fn func(f *provider.Feature) error) error {
+	if pf.extent != nil && !pf.extent.Intersects(extent) {
+		return nil
+	}
+
+	for rg := 0; rg < pf.file.NumRowGroups(); rg++ {
+		// check if the context cancelled or timed out
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if e, ok := pf.rowGroupExtent(rg); ok && !e.Intersects(extent) {
+			continue
+		}
+		if err := pf.rowGroupFeatures(ctx, rg, extent, srid, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowGroupFeatures calls fn for the features of a row group which intersect the
+// extent. The id and tag columns are only read when some do.
+func (pf *parquetFile) rowGroupFeatures(ctx context.Context, rg int, extent rtree.Extent, srid uint64, fn func(f *provider.Feature) error) error {
+	var candidates []int
+	if pf.covering[0] != -1 {
+		var bboxes [4][]any
+		for i, col := range pf.covering {
+			values, err := pf.file.ReadColumn(rg, col)
+			if err != nil {
+				return fmt.Errorf("reading %v: %w", pf.filename, err)
+			}
+			bboxes[i] = values
+		}
+		for row := range bboxes[0] {
+			var (
+				e  rtree.Extent
+				ok = true
+			)
+			for i := range e {
+				if e[i], ok = toFloat(bboxes[i][row]); !ok {
+					break
+				}
+			}
+			if ok && e.Intersects(extent) {
+				candidates = append(candidates, row)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+	}
+
+	geoms, err := pf.file.ReadColumn(rg, pf.geom)
+	if err != nil {
+		return fmt.Errorf("reading %v: %w", pf.filename, err)
+	}
+	if candidates == nil {
+		candidates = make([]int, len(geoms))
+		for row := range candidates {
+			candidates[row] = row
+		}
+	}
+
+	type match struct {
+		row      int
+		geometry geom.Geometry
+	}
+	var (
+		matches  []match
+		reported bool
+	)
+	for _, row := range candidates {
+		b, ok := geoms[row].([]byte)
+		if !ok || len(b) == 0 {
+			continue
+		}
+		geometry, err := wkb.DecodeBytes(b)
+		if err != nil {
+			if _, ok := err.(wkb.ErrUnknownGeometryType); ok {
+				// only report to the log once per row group
+				if !reported {
+					reported = true
+					log.Warnf("Ignoring unsupported geometries of %v. Only basic 2D geometry types are supported.", pf.filename)
+				}
+				continue
+			}
+			return fmt.Errorf("unable to decode the geometry of row %v of row group %v of %v: %w", row, rg, pf.filename, err)
+		}
+		// without a covering only the extent of the geometry shows if it's in the tile
+		if pf.covering[0] == -1 {
+			ge, err := geom.NewExtentFromGeometry(geometry)
+			if err != nil || !rtree.Extent(ge.Extent()).Intersects(extent) {
+				continue
+			}
+		}
+		matches = append(matches, match{row: row, geometry: geometry})
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	var ids []any
+	if pf.id != -1 {
+		if ids, err = pf.file.ReadColumn(rg, pf.id); err != nil {
+			return fmt.Errorf("reading %v: %w", pf.filename, err)
+		}
+	}
+	tags := make([][]any, len(pf.tags))
+	for i, col := range pf.tags {
+		if tags[i], err = pf.file.ReadColumn(rg, col); err != nil {
+			return fmt.Errorf("reading %v: %w", pf.filename, err)
+		}
+	}
+
+	columns := pf.file.Columns()
+	for _, m := range matches {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		feature := provider.Feature{
+			// rows are numbered from 1
+			ID:       uint64(pf.rowOffsets[rg] + int64(m.row) + 1),
+			Geometry: m.geometry,
+			SRID:     srid,
+			Tags:     make(map[string]interface{}, len(pf.tags)),
+		}
+		if ids != nil && ids[m.row] != nil {
+			if feature.ID, err = provider.ConvertFeatureID(ids[m.row]); err != nil {
+				return err
+			}
+		}
+		for i, col := range pf.tags {
+			if v := tagValue(tags[i][m.row]); v != nil {
+				feature.Tags[columns[col].Name()] = v
+			}
+		}
+
+		// pass the feature to the provided call back
+		if err := fn(&feature); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pf *parquetFile) close() error {
+	return pf.fh.Close()
+}
+
+// toFloat converts a numeric value of a column
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// tagValue converts a value of a column to a tag value. Null and binary values are
+// left out.
+func tagValue(v any) any {
+	switch v := v.(type) {
+	case float32:
+		return float64(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return nil
+	default:
+		return v
+	}
+}
@@ -0,0 +1,149 @@
+package geoparquet
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola"
+)
+
+// geoMetadata is the "geo" key value metadata of a GeoParquet file. The spec is at
+// https://geoparquet.org/releases/v1.1.0/
+type geoMetadata struct {
+	Version       string                       `json:"version"`
+	PrimaryColumn string                       `json:"primary_column"`
+	Columns       map[string]geoColumnMetadata `json:"columns"`
+}
+
+type geoColumnMetadata struct {
+	Encoding      string   `json:"encoding"`
+	GeometryTypes []string `json:"geometry_types"`
+	// CRS is the PROJJSON of the coordinate system of the column. It's WGS84 when absent
+	// and unknown when null.
+	CRS  json.RawMessage `json:"crs"`
+	BBox []float64       `json:"bbox"`
+	// Covering are the columns of the bounding boxes of the geometries
+	Covering *struct {
+		BBox *struct {
+			Xmin []string `json:"xmin"`
+			Ymin []string `json:"ymin"`
+			Xmax []string `json:"xmax"`
+			Ymax []string `json:"ymax"`
+		} `json:"bbox"`
+	} `json:"covering"`
+}
+
+func parseGeoMetadata(s string) (*geoMetadata, error) {
+	var md geoMetadata
+	if err := json.Unmarshal([]byte(s), &md); err != nil {
+		return nil, fmt.Errorf("invalid geo metadata: %w", err)
+	}
+	return &md, nil
+}
+
+// coveringColumns returns the names of the xmin, ymin, xmax and ymax columns of the
+// bounding boxes of the geometries of the column, if it has a bbox covering
+func (c geoColumnMetadata) coveringColumns() ([4]string, bool) {
+	if c.Covering == nil || c.Covering.BBox == nil {
+		return [4]string{}, false
+	}
+	bbox := c.Covering.BBox
+	paths := [4][]string{bbox.Xmin, bbox.Ymin, bbox.Xmax, bbox.Ymax}
+
+	var names [4]string
+	for i, path := range paths {
+		if len(path) == 0 {
+			return [4]string{}, false
+		}
+		names[i] = strings.Join(path, ".")
+	}
+	return names, true
+}
+
+// srid returns the EPSG code of the crs of the column, or 0 when it's unknown
+func (c geoColumnMetadata) srid() uint64 {
+	if c.CRS == nil {
+		// the default crs is OGC:CRS84, the longitude and latitude of WGS84
+		return tegola.WGS84
+	}
+
+	var crs struct {
+		ID *struct {
+			Authority string          `json:"authority"`
+			Code      json.RawMessage `json:"code"`
+		} `json:"id"`
+	}
+	if err := json.Unmarshal(c.CRS, &crs); err != nil || crs.ID == nil {
+		return 0
+	}
+
+	// codes are numbers or strings such as "CRS84"
+	code := strings.Trim(string(crs.ID.Code), `"`)
+	switch strings.ToUpper(crs.ID.Authority) {
+	case "EPSG":
+		srid, err := strconv.ParseUint(code, 10, 64)
+		if err != nil {
+			return 0
+		}
+		return srid
+	case "OGC":
+		if code == "CRS84" {
+			return tegola.WGS84
+		}
+	}
+	return 0
+}
+
+// geomType returns the type of the geometries of the column when they are all of one
+// type, or nil
+func (c geoColumnMetadata) geomType() geom.Geometry {
+	if len(c.GeometryTypes) != 1 {
+		return nil
+	}
+	// the Z variants, such as "Point Z", are read as 2D geometries
+	switch strings.TrimSuffix(c.GeometryTypes[0], " Z") {
+	case "Point":
+		return geom.Point{}
+	case "LineString":
+		return geom.LineString{}
+	case "Polygon":
+		return geom.Polygon{}
+	case "MultiPoint":
+		return geom.MultiPoint{}
+	case "MultiLineString":
+		return geom.MultiLineString{}
+	case "MultiPolygon":
+		return geom.MultiPolygon{}
+	case "GeometryCollection":
+		return geom.Collection{}
+	default:
+		return nil
+	}
+}
+
+// geomTypeOf parses the geometry_type config of a layer
+func geomTypeOf(name string) (geom.Geometry, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return nil, nil
+	case "point":
+		return geom.Point{}, nil
+	case "linestring":
+		return geom.LineString{}, nil
+	case "polygon":
+		return geom.Polygon{}, nil
+	case "multipoint":
+		return geom.MultiPoint{}, nil
+	case "multilinestring":
+		return geom.MultiLineString{}, nil
+	case "multipolygon":
+		return geom.MultiPolygon{}, nil
+	case "geometrycollection":
+		return geom.Collection{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported %v (%v)", ConfigKeyGeomType, name)
+	}
+}
@@ -0,0 +1,128 @@
+// Package geoparquet implements the geoparquet provider, which serves the features of
+// GeoParquet files. The files are read in place for each tile, skipping the row groups
+// whose statistics show they are outside of the tile, or queried with the sql of a
+// layer on DuckDB.
+package geoparquet
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola/basic"
+	"github.com/go-spatial/tegola/internal/rtree"
+	"github.com/go-spatial/tegola/provider"
+)
+
+const Name = "geoparquet"
+
+// config keys
+const (
+	ConfigKeyFilePath               = "filepath"
+	ConfigKeySRID                   = "srid"
+	ConfigKeyDuckDBPath             = "duckdb_path"
+	ConfigKeyDuckDBSpatialExtension = "duckdb_spatial_extension"
+	ConfigKeyLayers                 = "layers"
+	ConfigKeyLayerName              = "name"
+	ConfigKeyFileName               = "filename"
+	ConfigKeyGeomField              = "geometry_fieldname"
+	ConfigKeyGeomIDField            = "id_fieldname"
+	ConfigKeyFields                 = "fields"
+	ConfigKeyGeomType               = "geometry_type"
+	ConfigKeySQL                    = "sql"
+)
+
+// DefaultSQLGeomField is the geometry field of sql layers without a geometry_fieldname
+const DefaultSQLGeomField = "geometry"
+
+type Layer struct {
+	name string
+	// filename is the file, or glob of files, of the layer
+	filename      string
+	geomFieldname string
+	idFieldname   string
+	// fields are the columns included as tags. All the columns are included when empty
+	fields   []string
+	geomType geom.Geometry
+	srid     uint64
+	// sql is the query of the layer executed on DuckDB, instead of reading its files
+	sql string
+
+	files []*parquetFile
+}
+
+func (l Layer) Name() string            { return l.name }
+func (l Layer) GeomType() geom.Geometry { return l.geomType }
+func (l Layer) SRID() uint64            { return l.srid }
+
+type Provider struct {
+	// Filepath is the GeoParquet file, or directory of files, of the layers
+	Filepath string
+	layers   map[string]*Layer
+	// db is the DuckDB database of the sql layers, or nil when there are none
+	db *sql.DB
+}
+
+func (p *Provider) Layers() ([]provider.LayerInfo, error) {
+	ls := make([]provider.LayerInfo, 0, len(p.layers))
+	for _, l := range p.layers {
+		ls = append(ls, *l)
+	}
+	return ls, nil
+}
+
+func (p *Provider) TileFeatures(ctx context.Context, layer string, tile provider.Tile, queryParams provider.Params, fn func(f *provider.Feature) error) error {
+	l, ok := p.layers[layer]
+	if !ok {
+		return ErrUnknownLayer{Name: layer}
+	}
+
+	if l.sql != "" {
+		return p.sqlFeatures(ctx, l, tile, queryParams, fn)
+	}
+
+	// read the tile extent
+	tileBBox, tileSRID := tile.BufferedExtent()
+
+	// convert the tile extent to the SRID of the layer
+	if l.srid != tileSRID {
+		minGeo, err := basic.FromWebMercator(l.srid, geom.Point{tileBBox.MinX(), tileBBox.MinY()})
+		if err != nil {
+			return fmt.Errorf("error converting point: %v ", err)
+		}
+
+		maxGeo, err := basic.FromWebMercator(l.srid, geom.Point{tileBBox.MaxX(), tileBBox.MaxY()})
+		if err != nil {
+			return fmt.Errorf("error converting point: %v ", err)
+		}
+
+		tileBBox = geom.NewExtent(minGeo.(geom.Point), maxGeo.(geom.Point))
+	}
+	extent := rtree.Extent(tileBBox.Extent())
+
+	for _, pf := range l.files {
+		if err := pf.features(ctx, extent, l.srid, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the files of the layers and the DuckDB database
+func (p *Provider) Close() error {
+	var firstErr error
+	for _, l := range p.layers {
+		for _, pf := range l.files {
+			if err := pf.close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if p.db != nil {
+		if err := p.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,539 @@
+package geoparquet
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/wkb"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/rtree"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// the fixtures of testdata are
+//
+//	points.parquet: 100 points in WGS84 with a bbox covering, in 4 row groups of the
+//	points of each quadrant of the world, north west, north east, south west and
+//	south east
+//	polygons.parquet: 20 squares in web mercator, in a GEOMETRY column with
+//	geospatial statistics, in 2 row groups of the squares in the west and east
+
+// point is the point of the row of points.parquet
+func point(row int) geom.Point {
+	quadrants := [][2]float64{{-180, 0}, {0, 0}, {-180, -90}, {0, -90}}
+	origin, i, j := quadrants[row/25], (row%25)/5, row%5
+	return geom.Point{origin[0] + 10 + float64(i)*30, origin[1] + 5 + float64(j)*15}
+}
+
+func TestNewTileProvider(t *testing.T) {
+	type tcase struct {
+		config dict.Dict
+		// layers are the expected layer names, srids and geometry types
+		layers   []string
+		srids    []uint64
+		geomType []geom.Geometry
+		expErr   bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			config := dict.Dict{}
+			for k, v := range tc.config {
+				config[k] = v
+			}
+			if path, ok := config[ConfigKeyFilePath].(string); ok {
+				config[ConfigKeyFilePath] = filepath.Join("testdata", path)
+			}
+
+			p, err := NewTileProvider(config, nil)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("expected an error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			defer p.(*Provider).Close()
+
+			infos, err := p.Layers()
+			if err != nil {
+				t.Fatalf("layers, expected nil got %v", err)
+			}
+			sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+			var (
+				names    []string
+				srids    []uint64
+				geomType []geom.Geometry
+			)
+			for _, info := range infos {
+				names = append(names, info.Name())
+				srids = append(srids, info.SRID())
+				geomType = append(geomType, info.GeomType())
+			}
+			if !reflect.DeepEqual(names, tc.layers) {
+				t.Errorf("layers, expected %v got %v", tc.layers, names)
+			}
+			if !reflect.DeepEqual(srids, tc.srids) {
+				t.Errorf("srids, expected %v got %v", tc.srids, srids)
+			}
+			if !reflect.DeepEqual(geomType, tc.geomType) {
+				t.Errorf("geometry types, expected %v got %v", tc.geomType, geomType)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"directory": {
+			config:   dict.Dict{ConfigKeyFilePath: "."},
+			layers:   []string{"points", "polygons"},
+			srids:    []uint64{tegola.WGS84, tegola.WebMercator},
+			geomType: []geom.Geometry{geom.Point{}, geom.Polygon{}},
+		},
+		"file": {
+			config:   dict.Dict{ConfigKeyFilePath: "points.parquet"},
+			layers:   []string{"points"},
+			srids:    []uint64{tegola.WGS84},
+			geomType: []geom.Geometry{geom.Point{}},
+		},
+		"layers": {
+			config: dict.Dict{
+				ConfigKeyFilePath: ".",
+				ConfigKeyLayers: []map[string]interface{}{
+					{ConfigKeyLayerName: "all", ConfigKeyFileName: "*.parquet", ConfigKeySRID: 3857},
+					{ConfigKeyLayerName: "places", ConfigKeyFileName: "points.parquet", ConfigKeyFields: []string{"name"}},
+					{ConfigKeyLayerName: "polygons", ConfigKeyGeomType: "MultiPolygon"},
+				},
+			},
+			layers:   []string{"all", "places", "polygons"},
+			srids:    []uint64{tegola.WebMercator, tegola.WGS84, tegola.WebMercator},
+			geomType: []geom.Geometry{nil, geom.Point{}, geom.MultiPolygon{}},
+		},
+		"different coordinate systems": {
+			config: dict.Dict{
+				ConfigKeyFilePath: ".",
+				ConfigKeyLayers: []map[string]interface{}{
+					{ConfigKeyLayerName: "all", ConfigKeyFileName: "*.parquet"},
+				},
+			},
+			expErr: true,
+		},
+		"unsupported srid": {
+			config: dict.Dict{ConfigKeyFilePath: ".", ConfigKeySRID: 2154},
+			expErr: true,
+		},
+		"unknown field": {
+			config: dict.Dict{
+				ConfigKeyFilePath: ".",
+				ConfigKeyLayers: []map[string]interface{}{
+					{ConfigKeyLayerName: "points", ConfigKeyGeomIDField: "gid"},
+				},
+			},
+			expErr: true,
+		},
+		"unknown geometry column": {
+			config: dict.Dict{
+				ConfigKeyFilePath: ".",
+				ConfigKeyLayers: []map[string]interface{}{
+					{ConfigKeyLayerName: "points", ConfigKeyGeomField: "name"},
+				},
+			},
+			expErr: true,
+		},
+		"missing layer file": {
+			config: dict.Dict{
+				ConfigKeyFilePath: ".",
+				ConfigKeyLayers: []map[string]interface{}{
+					{ConfigKeyLayerName: "roads"},
+				},
+			},
+			expErr: true,
+		},
+		"sql and filename": {
+			config: dict.Dict{
+				ConfigKeyFilePath: ".",
+				ConfigKeyLayers: []map[string]interface{}{
+					{ConfigKeyLayerName: "points", ConfigKeyFileName: "points.parquet", ConfigKeySQL: "SELECT 1"},
+				},
+			},
+			expErr: true,
+		},
+		"missing filepath": {
+			expErr: true,
+		},
+		"invalid filepath": {
+			config: dict.Dict{ConfigKeyFilePath: "missing"},
+			expErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestTileFeatures(t *testing.T) {
+	type tcase struct {
+		layers   []map[string]interface{}
+		layer    string
+		tile     provider.Tile
+		features []provider.Feature
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			config := dict.Dict{ConfigKeyFilePath: "testdata"}
+			if tc.layers != nil {
+				config[ConfigKeyLayers] = tc.layers
+			}
+			p, err := NewTileProvider(config, nil)
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			defer p.(*Provider).Close()
+
+			var features []provider.Feature
+			err = p.TileFeatures(context.Background(), tc.layer, tc.tile, nil, func(f *provider.Feature) error {
+				features = append(features, *f)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("tile features, expected nil got %v", err)
+			}
+			if !reflect.DeepEqual(features, tc.features) {
+				t.Errorf("features, expected %+v got %+v", tc.features, features)
+			}
+		}
+	}
+
+	// the points of the north west of the world
+	var northWest, northWestFields []provider.Feature
+	for row := 0; row < 25; row++ {
+		id := int64(row + 1)
+		tags := map[string]interface{}{"id": id, "name": "q0"}
+		fieldTags := map[string]interface{}{"name": "q0"}
+		if id%2 == 0 {
+			tags["rank"] = id
+			fieldTags["rank"] = id
+		}
+		northWest = append(northWest, provider.Feature{ID: uint64(id), Geometry: point(row), SRID: tegola.WGS84, Tags: tags})
+		northWestFields = append(northWestFields, provider.Feature{ID: uint64(id), Geometry: point(row), SRID: tegola.WGS84, Tags: fieldTags})
+	}
+
+	// the squares of the east of the world
+	var east []provider.Feature
+	for row := 10; row < 20; row++ {
+		x, y := 5000000+float64(row%10)*1000000, float64(row%10)*500000
+		east = append(east, provider.Feature{
+			ID:       uint64(row + 1),
+			Geometry: geom.Polygon{{{x, y}, {x + 500000, y}, {x + 500000, y + 500000}, {x, y + 500000}}},
+			SRID:     tegola.WebMercator,
+			Tags:     map[string]interface{}{"gid": int64(row + 1), "class": []string{"park", "water"}[row%2]},
+		})
+	}
+
+	tests := map[string]tcase{
+		"covering": {
+			layer:    "points",
+			tile:     provider.NewTile(1, 0, 0, 64, tegola.WebMercator),
+			features: northWest,
+		},
+		"id and fields": {
+			layers: []map[string]interface{}{
+				{ConfigKeyLayerName: "places", ConfigKeyFileName: "points.parquet", ConfigKeyGeomIDField: "id", ConfigKeyFields: []string{"name", "rank"}},
+			},
+			layer:    "places",
+			tile:     provider.NewTile(1, 0, 0, 64, tegola.WebMercator),
+			features: northWestFields,
+		},
+		"geospatial statistics": {
+			layer:    "polygons",
+			tile:     provider.NewTile(1, 1, 0, 64, tegola.WebMercator),
+			features: east,
+		},
+		"no features": {
+			layer: "polygons",
+			tile:  provider.NewTile(2, 0, 3, 64, tegola.WebMercator),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestRowGroupExtent(t *testing.T) {
+	type tcase struct {
+		filename string
+		extents  []rtree.Extent
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			pf, err := openFile(filepath.Join("testdata", tc.filename), "", "", nil)
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			defer pf.close()
+
+			var extents []rtree.Extent
+			for rg := 0; rg < pf.file.NumRowGroups(); rg++ {
+				e, ok := pf.rowGroupExtent(rg)
+				if !ok {
+					t.Fatalf("row group %v, expected an extent", rg)
+				}
+				extents = append(extents, e)
+			}
+			if !reflect.DeepEqual(extents, tc.extents) {
+				t.Errorf("expected %v got %v", tc.extents, extents)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"covering": {
+			filename: "points.parquet",
+			extents: []rtree.Extent{
+				{-170, 5, -50, 65},
+				{10, 5, 130, 65},
+				{-170, -85, -50, -25},
+				{10, -85, 130, -25},
+			},
+		},
+		"geospatial statistics": {
+			filename: "polygons.parquet",
+			extents: []rtree.Extent{
+				{-15000000, 0, -5500000, 5000000},
+				{5000000, 0, 14500000, 5000000},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestUnknownLayer(t *testing.T) {
+	p, err := NewTileProvider(dict.Dict{ConfigKeyFilePath: "testdata"}, nil)
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	defer p.(*Provider).Close()
+
+	err = p.TileFeatures(context.Background(), "roads", provider.NewTile(0, 0, 0, 64, tegola.WebMercator), nil, func(*provider.Feature) error { return nil })
+	if !errors.As(err, &ErrUnknownLayer{}) {
+		t.Errorf("expected ErrUnknownLayer got %v", err)
+	}
+}
+
+func TestGeoColumnSRID(t *testing.T) {
+	tests := map[string]struct {
+		crs  string
+		srid uint64
+	}{
+		"absent":      {srid: tegola.WGS84},
+		"null":        {crs: `null`, srid: 0},
+		"epsg":        {crs: `{"id":{"authority":"EPSG","code":3857}}`, srid: tegola.WebMercator},
+		"epsg string": {crs: `{"id":{"authority":"EPSG","code":"4326"}}`, srid: tegola.WGS84},
+		"crs84":       {crs: `{"id":{"authority":"OGC","code":"CRS84"}}`, srid: tegola.WGS84},
+		"no id":       {crs: `{"name":"WGS 84 / UTM zone 33N"}`, srid: 0},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var md geoColumnMetadata
+			if tc.crs != "" {
+				md.CRS = []byte(tc.crs)
+			}
+			if srid := md.srid(); srid != tc.srid {
+				t.Errorf("expected %v got %v", tc.srid, srid)
+			}
+		})
+	}
+}
+
+// fakeDriver is a database/sql driver standing in for DuckDB. It records the
+// statements it executes and returns the rows of fakeRows for queries.
+type fakeDriver struct{}
+
+var (
+	fakeMu         sync.Mutex
+	fakeStatements []string
+	fakeArgs       []driver.Value
+	fakeColumns    = []string{"gid", "name", "height", "geometry"}
+	fakeRows       [][]driver.Value
+)
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query: query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	query string
+}
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	fakeStatements = append(fakeStatements, s.query)
+	return driver.RowsAffected(0), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	fakeStatements = append(fakeStatements, s.query)
+	fakeArgs = args
+	return &fakeResult{rows: fakeRows}, nil
+}
+
+type fakeResult struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeResult) Columns() []string { return fakeColumns }
+func (r *fakeResult) Close() error      { return nil }
+
+func (r *fakeResult) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("geoparquet_fake", fakeDriver{})
+}
+
+func TestSQLLayer(t *testing.T) {
+	defer func(name string) { driverName = name }(driverName)
+	driverName = "geoparquet_fake"
+
+	pt, err := wkb.EncodeBytes(geom.Point{2.35, 48.85})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeMu.Lock()
+	fakeStatements = nil
+	fakeRows = [][]driver.Value{
+		{int64(7), "paris", 35.5, pt},
+		// rows without geometries are skipped
+		{int64(8), "nowhere", nil, nil},
+	}
+	fakeMu.Unlock()
+
+	config := dict.Dict{
+		ConfigKeyLayers: []map[string]interface{}{
+			{
+				ConfigKeyLayerName:   "buildings",
+				ConfigKeyGeomIDField: "gid",
+				ConfigKeySQL:         "SELECT gid, name, height, ST_AsWKB(geometry) AS geometry FROM read_parquet('buildings/*.parquet') WHERE bbox.xmin <= ST_XMax(!BBOX!) AND height > !MIN_HEIGHT! AND !zoom! >= 0",
+			},
+		},
+	}
+	p, err := NewTileProvider(config, nil)
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	defer p.(*Provider).Close()
+
+	params := provider.Params{
+		"!MIN_HEIGHT!": {Token: "!MIN_HEIGHT!", SQL: "?", Value: 10},
+	}
+	var features []provider.Feature
+	err = p.TileFeatures(context.Background(), "buildings", provider.NewTile(0, 0, 0, 0, tegola.WebMercator), params, func(f *provider.Feature) error {
+		features = append(features, *f)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("tile features, expected nil got %v", err)
+	}
+
+	expected := []provider.Feature{
+		{ID: 7, Geometry: geom.Point{2.35, 48.85}, SRID: tegola.WGS84, Tags: map[string]interface{}{"name": "paris", "height": 35.5}},
+	}
+	if !reflect.DeepEqual(features, expected) {
+		t.Errorf("features, expected %+v got %+v", expected, features)
+	}
+
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	expectedStatements := []string{
+		"LOAD spatial",
+		"SELECT gid, name, height, ST_AsWKB(geometry) AS geometry FROM read_parquet('buildings/*.parquet') WHERE bbox.xmin <= ST_XMax(ST_MakeEnvelope(-180.00000000, -85.05112878, 180.00000000, 85.05112878)) AND height > $1 AND 0 >= 0",
+	}
+	if !reflect.DeepEqual(fakeStatements, expectedStatements) {
+		t.Errorf("statements, expected %q got %q", expectedStatements, fakeStatements)
+	}
+	if !reflect.DeepEqual(fakeArgs, []driver.Value{int64(10)}) {
+		t.Errorf("args, expected [10] got %v", fakeArgs)
+	}
+}
+
+func TestSQLLayerSpatialExtension(t *testing.T) {
+	defer func(name string) { driverName = name }(driverName)
+	driverName = "geoparquet_fake"
+
+	fakeMu.Lock()
+	fakeStatements = nil
+	fakeMu.Unlock()
+
+	config := dict.Dict{
+		ConfigKeyDuckDBSpatialExtension: "/opt/duckdb/it's/spatial.duckdb_extension",
+		ConfigKeyLayers: []map[string]interface{}{
+			{ConfigKeyLayerName: "buildings", ConfigKeySQL: "SELECT ST_AsWKB(geometry) AS geometry FROM read_parquet('buildings.parquet')"},
+		},
+	}
+	p, err := NewTileProvider(config, nil)
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	p.(*Provider).Close()
+
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	expected := []string{"LOAD '/opt/duckdb/it''s/spatial.duckdb_extension'"}
+	if !reflect.DeepEqual(fakeStatements, expected) {
+		t.Errorf("statements, expected %q got %q", expected, fakeStatements)
+	}
+}
+
+func TestSQLLayerNoDriver(t *testing.T) {
+	for _, name := range sql.Drivers() {
+		if name == driverName {
+			t.Skipf("a %v driver is linked", driverName)
+		}
+	}
+
+	config := dict.Dict{
+		ConfigKeyLayers: []map[string]interface{}{
+			{ConfigKeyLayerName: "buildings", ConfigKeySQL: "SELECT ST_AsWKB(geometry) AS geometry FROM read_parquet('buildings.parquet')"},
+		},
+	}
+	_, err := NewTileProvider(config, nil)
+	if !errors.As(err, &ErrDuckDBDriver{}) {
+		t.Errorf("expected ErrDuckDBDriver got %v", err)
+	}
+}
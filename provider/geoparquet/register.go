@@ -0,0 +1,333 @@
+package geoparquet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/provider"
+)
+
+func init() {
+	provider.Register(provider.TypeStd.Prefix()+Name, NewTileProvider, Cleanup)
+}
+
+// NewTileProvider instantiates a provider of the layers of GeoParquet files
+func NewTileProvider(config dict.Dicter, _ []provider.Map) (provider.Tiler, error) {
+	var path string
+	path, err := config.String(ConfigKeyFilePath, &path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the srid of all the layers
+	defaultSRID, err := configSRID(config, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var duckDBPath string
+	if duckDBPath, err = config.String(ConfigKeyDuckDBPath, &duckDBPath); err != nil {
+		return nil, err
+	}
+	var spatialExtension string
+	if spatialExtension, err = config.String(ConfigKeyDuckDBSpatialExtension, &spatialExtension); err != nil {
+		return nil, err
+	}
+
+	layers, err := config.MapSlice(ConfigKeyLayers)
+	if err != nil {
+		return nil, err
+	}
+
+	// the filepath is only optional when all the layers are sql layers
+	var isDir bool
+	if path != "" {
+		path = filepath.Clean(path)
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, ErrInvalidFilePath{path}
+		}
+		isDir = fi.IsDir()
+	}
+
+	var configured []*Layer
+	if len(layers) == 0 {
+		if path == "" {
+			return nil, ErrInvalidFilePath{path}
+		}
+		configured, err = discoverLayers(path, isDir, defaultSRID)
+	} else {
+		configured, err = configLayers(path, isDir, layers, defaultSRID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := Provider{
+		Filepath: path,
+		layers:   make(map[string]*Layer, len(configured)),
+	}
+	for _, l := range configured {
+		p.layers[l.name] = l
+		if l.sql != "" {
+			continue
+		}
+		if err := l.open(); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("for layer (%v): %w", l.name, err)
+		}
+
+		var rows int64
+		for _, pf := range l.files {
+			rows += pf.file.NumRows()
+		}
+		log.Infof("opened layer (%v) with %v rows from %v files", l.name, rows, len(l.files))
+	}
+
+	for _, l := range configured {
+		if l.sql == "" {
+			continue
+		}
+		if p.db, err = openDuckDB(context.Background(), duckDBPath, spatialExtension); err != nil {
+			p.Close()
+			return nil, err
+		}
+		break
+	}
+
+	// track the provider so we can clean it up later
+	providersLock.Lock()
+	providers = append(providers, &p)
+	providersLock.Unlock()
+
+	return &p, nil
+}
+
+// configSRID reads the srid config, which must be 4326 or 3857 when set
+func configSRID(config dict.Dicter, def uint64) (uint64, error) {
+	srid := int(def)
+	srid, err := config.Int(ConfigKeySRID, &srid)
+	if err != nil {
+		return 0, err
+	}
+	switch srid {
+	case 0, 4326, 3857:
+		return uint64(srid), nil
+	default:
+		return 0, fmt.Errorf("unsupported %v %v. expecting 4326 or 3857", ConfigKeySRID, srid)
+	}
+}
+
+func isParquet(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".parquet"
+}
+
+// discoverLayers returns a layer for each .parquet file, named after the file without
+// its extension
+func discoverLayers(path string, isDir bool, srid uint64) ([]*Layer, error) {
+	layerFor := func(filename string) *Layer {
+		base := filepath.Base(filename)
+		return &Layer{
+			name:     strings.TrimSuffix(base, filepath.Ext(base)),
+			filename: filename,
+			srid:     srid,
+		}
+	}
+
+	if !isDir {
+		return []*Layer{layerFor(path)}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		layers []*Layer
+		seen   = make(map[string]string)
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || !isParquet(entry.Name()) {
+			continue
+		}
+
+		l := layerFor(filepath.Join(path, entry.Name()))
+		if other, ok := seen[l.name]; ok {
+			return nil, fmt.Errorf("files %v and %v are both layer (%v)", other, entry.Name(), l.name)
+		}
+		seen[l.name] = entry.Name()
+		layers = append(layers, l)
+	}
+
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("directory %v has no .parquet files", path)
+	}
+	return layers, nil
+}
+
+// configLayers returns the layers of the layers config. The filename of a layer is a
+// file or glob relative to the directory of the provider, and defaults to the layer
+// name with the .parquet extension. Layers with sql are queried on DuckDB and have no
+// files.
+func configLayers(path string, isDir bool, layers []dict.Dicter, srid uint64) ([]*Layer, error) {
+	var (
+		configured []*Layer
+		lyrsSeen   = make(map[string]int)
+	)
+	for i, layerConf := range layers {
+		layerName, err := layerConf.String(ConfigKeyLayerName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("for layer (%v) we got the following error trying to get the layer's name field: %v", i, err)
+		}
+		if layerName == "" {
+			return nil, ErrMissingLayerName
+		}
+
+		// check if we have already seen this layer
+		if j, ok := lyrsSeen[layerName]; ok {
+			return nil, fmt.Errorf("layer name (%v) is duplicated in both layer %v and layer %v", layerName, i, j)
+		}
+		lyrsSeen[layerName] = i
+
+		l := Layer{name: layerName}
+
+		if l.filename, err = layerConf.String(ConfigKeyFileName, &l.filename); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+		if l.sql, err = layerConf.String(ConfigKeySQL, &l.sql); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+		if l.geomFieldname, err = layerConf.String(ConfigKeyGeomField, &l.geomFieldname); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+		if l.idFieldname, err = layerConf.String(ConfigKeyGeomIDField, &l.idFieldname); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+		if l.fields, err = layerConf.StringSlice(ConfigKeyFields); err != nil { // empty slices are okay
+			return nil, fmt.Errorf("for layer (%v) %v, %q field had the following error: %v", i, layerName, ConfigKeyFields, err)
+		}
+		if l.srid, err = configSRID(layerConf, srid); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+
+		var geomType string
+		if geomType, err = layerConf.String(ConfigKeyGeomType, &geomType); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+		if l.geomType, err = geomTypeOf(geomType); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+
+		if l.sql != "" {
+			switch {
+			case l.filename != "":
+				return nil, fmt.Errorf("for layer (%v) %v : %v and %v are mutually exclusive, read the files in the sql with read_parquet", i, layerName, ConfigKeyFileName, ConfigKeySQL)
+			case len(l.fields) != 0:
+				return nil, fmt.Errorf("for layer (%v) %v : %v are not supported with %v, select the fields in the sql", i, layerName, ConfigKeyFields, ConfigKeySQL)
+			}
+			if l.geomFieldname == "" {
+				l.geomFieldname = DefaultSQLGeomField
+			}
+			// GeoParquet coordinates default to WGS84
+			if l.srid == 0 {
+				l.srid = tegola.WGS84
+			}
+			configured = append(configured, &l)
+			continue
+		}
+
+		switch {
+		case path == "":
+			return nil, ErrInvalidFilePath{path}
+		case !isDir:
+			if l.filename != "" && l.filename != filepath.Base(path) {
+				return nil, fmt.Errorf("for layer (%v) %v : %v is only supported when %v is a directory", i, layerName, ConfigKeyFileName, ConfigKeyFilePath)
+			}
+			l.filename = path
+		case l.filename != "":
+			l.filename = filepath.Join(path, l.filename)
+		default:
+			l.filename = filepath.Join(path, layerName+".parquet")
+		}
+
+		configured = append(configured, &l)
+	}
+	return configured, nil
+}
+
+// open opens the files matched by the filename of the layer. The srid and geometry type
+// of the layer, when not configured, are those of the geo metadata of the files.
+func (l *Layer) open() error {
+	filenames, err := filepath.Glob(l.filename)
+	if err != nil {
+		return err
+	}
+	if len(filenames) == 0 {
+		return fmt.Errorf("no files match %v", l.filename)
+	}
+
+	var offset int64
+	for _, filename := range filenames {
+		pf, err := openFile(filename, l.geomFieldname, l.idFieldname, l.fields)
+		if err != nil {
+			return err
+		}
+		l.files = append(l.files, pf)
+		offset = pf.setRowOffset(offset)
+	}
+
+	first := l.files[0]
+	if l.srid == 0 {
+		for _, pf := range l.files {
+			if pf.srid != first.srid {
+				return fmt.Errorf("%v and %v have different coordinate systems", first.filename, pf.filename)
+			}
+		}
+		switch first.srid {
+		case tegola.WGS84, tegola.WebMercator:
+			l.srid = first.srid
+		case 0:
+			return fmt.Errorf("%v has an unknown coordinate system, set the %v of the layer", first.filename, ConfigKeySRID)
+		default:
+			return fmt.Errorf("%v is in EPSG:%v, only 4326 and 3857 are supported", first.filename, first.srid)
+		}
+	}
+
+	if l.geomType == nil && first.geomType != nil {
+		l.geomType = first.geomType
+		for _, pf := range l.files {
+			if pf.geomType == nil || fmt.Sprintf("%T", pf.geomType) != fmt.Sprintf("%T", first.geomType) {
+				l.geomType = nil
+				break
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	providersLock sync.Mutex
+	// reference to all instantiated providers
+	providers []*Provider
+)
+
+// Cleanup closes the files and databases of all the previously instantiated providers
+func Cleanup() {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+
+	for _, p := range providers {
+		if err := p.Close(); err != nil {
+			log.Errorf("err closing geoparquet provider: %v", err)
+		}
+	}
+	providers = nil
+}
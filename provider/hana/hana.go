@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"fmt"
 	"net/url"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -207,6 +208,9 @@ const (
 	ConfigKeyGeomField       = "geometry_fieldname"
 	ConfigKeyFeatureIDField  = "id_fieldname"
 	ConfigKeyGeomType        = "geometry_type"
+	ConfigKeyVariants        = "variants"
+	ConfigKeyMinZoom         = "min_zoom"
+	ConfigKeyMaxZoom         = "max_zoom"
 	ConfigKeyBuffer          = "buffer"
 	ConfigKeyClipGeometry    = "clip_geometry"
 )
@@ -379,6 +383,8 @@ func CreateConnection(config dict.Dicter) (*sql.DB, error) {
 //
 //			!BBOX! - [Required] will be replaced with the bounding box of the tile before the query is sent to the database.
 //			!ZOOM! - [Optional] will be replaced with the "Z" (zoom) value of the requested tile.
+//
+//		variants ([]map[string]struct{}): [Optional] an ordered list of tablename or sql, fields, min_zoom and max_zoom, used instead of the layer tablename or sql. A tile uses the first variant covering its zoom.
 func CreateProvider(config dict.Dicter, maps []provider.Map, providerType string) (*Provider, error) {
 	conn, err := CreateConnection(config)
 	if err != nil {
@@ -462,20 +468,23 @@ func CreateProvider(config dict.Dicter, maps []provider.Map, providerType string
 			return nil, fmt.Errorf("for layer (%v) %v : %w", i, lName, err)
 		}
 
-		var tblName string
-		tblName, err = layer.String(ConfigKeyTablename, &lName)
-		if err != nil {
-			return nil, fmt.Errorf("for %v layer (%v) %v has an error: %w", i, lName, ConfigKeyTablename, err)
-		}
-
-		var sql string
-		sql, err = layer.String(ConfigKeySQL, &sql)
+		variants, err := layer.MapSlice(ConfigKeyVariants)
 		if err != nil {
-			return nil, fmt.Errorf("for %v layer (%v) %v has an error: %w", i, lName, ConfigKeySQL, err)
+			return nil, fmt.Errorf("for layer (%v) %v : %w", i, lName, err)
 		}
 
-		if tblName != lName && sql != "" {
-			log.Debugf("both %v and %v field are specified for layer (%v) %v, using only %[2]v field.", ConfigKeyTablename, ConfigKeySQL, i, lName)
+		var sources []layerSource
+		if len(variants) == 0 {
+			src, err := readLayerSource(layer, i, lName, lName)
+			if err != nil {
+				return nil, err
+			}
+			src.fields = fields
+			sources = []layerSource{src}
+		} else {
+			if sources, err = readLayerVariants(layer, i, lName, variants, fields); err != nil {
+				return nil, err
+			}
 		}
 
 		var lsrid = srid
@@ -485,10 +494,11 @@ func CreateProvider(config dict.Dicter, maps []provider.Map, providerType string
 
 		if lsrid < 0 {
 			// we try to auto detect SRID if it is not specified neither
-			// for the provider nor for the layer.
-			sqlQuery := sql
+			// for the provider nor for the layer. The SRID of layers with
+			// variants is detected on the first variant.
+			sqlQuery := sources[0].sql
 			if sqlQuery == "" {
-				sqlQuery = fmt.Sprintf(`(SELECT * FROM %v)`, quoteIdentifier(tblName))
+				sqlQuery = fmt.Sprintf(`(SELECT * FROM %v)`, quoteIdentifier(sources[0].tblName))
 			}
 
 			lsrid, err = getGeometryColumnSRID(p.pool, p.dbVersion, sqlQuery, geomfld)
@@ -511,52 +521,32 @@ func CreateProvider(config dict.Dicter, maps []provider.Map, providerType string
 			srid:      uint64(lsrid),
 		}
 
-		if sql != "" && !isSelectQuery(sql) {
-			// if it is not a SELECT query, then we assume we have a sub-query
-			// (`(select ...) as foo`) which we can handle like a tablename
-			tblName = sql
-			sql = ""
-		}
-
-		if sql != "" {
-			sql = sanitizeSQL(sql)
-			// make sure that the sql has a !BBOX! token
-			if !strings.Contains(sql, bboxToken) {
-				return nil, fmt.Errorf("SQL for layer (%v) %v is missing required token: %v", i, lName, bboxToken)
-			}
-			if !strings.Contains(sql, "*") {
-				if !strings.Contains(sql, geomfld) {
-					return nil, ErrGeomFieldNotFound{
-						GeomFieldName: geomfld,
-						LayerName:     lName,
-					}
-				}
-				if !strings.Contains(sql, idfld) {
-					return nil, fmt.Errorf("SQL for layer (%v) %v does not contain the id field for the geometry: %v", i, lName, sql)
-				}
+		// the variants are layers of their own until they're folded into the layer
+		vlyrs := make([]Layer, len(sources))
+		for j, src := range sources {
+			vl := l
+			if vl.sql, err = p.layerSQL(&vl, i, src.tblName, src.sql, src.fields, providerType); err != nil {
+				return nil, err
 			}
 
-			l.sql = sql
-		} else {
-			// Tablename and Fields will be used to build the query.
-			// We need to do some work. We need to check to see Fields contains the geom and gid fields
-			// and if not add them to the list. If Fields list is empty/nil we will use '*' for the field list.
-			if len(fields) == 0 {
-				fields, err = getTableFieldNames(p.pool, &l, tblName)
-				if err != nil {
-					return nil, err
-				}
+			vl.fields, err = getLayerFields(p.pool, &vl, vl.sql)
+			if err != nil {
+				return nil, err
 			}
 
-			l.sql, err = genSQL(&l, tblName, fields, true, providerType)
-			if err != nil {
-				return nil, fmt.Errorf("could not generate sql, for layer(%v): %w", lName, err)
+			// the features of the layer have one schema at every zoom
+			if j > 0 && !sameFields(getFieldNames(vlyrs[0].fields), getFieldNames(vl.fields)) {
+				return nil, fmt.Errorf(
+					"for layer (%v) %v variant (%v) : fields %v differ from the fields %v of the first variant",
+					i,
+					lName,
+					j,
+					getFieldNames(vl.fields),
+					getFieldNames(vlyrs[0].fields),
+				)
 			}
-		}
 
-		l.fields, err = getLayerFields(p.pool, &l, l.sql)
-		if err != nil {
-			return nil, err
+			vlyrs[j] = vl
 		}
 
 		// set the layer geom type
@@ -570,11 +560,35 @@ func CreateProvider(config dict.Dicter, maps []provider.Map, providerType string
 				return nil, err
 			}
 
-			if err = p.inspectLayerGeomType(pname, &l, maps); err != nil {
-				return nil, fmt.Errorf("error fetching geometry type for layer (%v): %w", l.name, err)
+			// the geometry type is inspected on every variant, which have to return the
+			// same geometry type
+			for j := range vlyrs {
+				if err = p.inspectLayerGeomType(pname, &vlyrs[j], maps); err != nil {
+					return nil, fmt.Errorf("error fetching geometry type for layer (%v): %w", l.name, err)
+				}
+
+				switch vgeomType := vlyrs[j].geomType; {
+				case vgeomType == nil:
+					// the variant returned no rows to inspect
+				case l.geomType == nil:
+					l.geomType = vgeomType
+				case reflect.TypeOf(vgeomType) != reflect.TypeOf(l.geomType):
+					return nil, fmt.Errorf(
+						"for layer (%v) %v variant (%v) : geometry type %T differs from the geometry type %T of the other variants",
+						i,
+						lName,
+						j,
+						vgeomType,
+						l.geomType,
+					)
+				}
 			}
 		}
 
+		for j := range vlyrs {
+			vlyrs[j].geomType = l.geomType
+		}
+
 		if providerType == MVTProviderType {
 			var buffer uint = 256
 			if buffer, err = layer.Uint(ConfigKeyBuffer, &buffer); err != nil {
@@ -586,14 +600,31 @@ func CreateProvider(config dict.Dicter, maps []provider.Map, providerType string
 				return nil, err
 			}
 
-			l.sql, err = genMVTSQL(&l, getFieldNames(l.fields), buffer, clipGeom)
-			if err != nil {
-				return nil, err
+			for j := range vlyrs {
+				vlyrs[j].sql, err = genMVTSQL(&vlyrs[j], getFieldNames(vlyrs[j].fields), buffer, clipGeom)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if len(variants) == 0 {
+			l.sql, l.fields = vlyrs[0].sql, vlyrs[0].fields
+		} else {
+			for j, src := range sources {
+				l.variants = append(l.variants, variant{
+					minZoom: src.minZoom,
+					maxZoom: src.maxZoom,
+					sql:     vlyrs[j].sql,
+					fields:  vlyrs[j].fields,
+				})
 			}
 		}
 
 		if debugLayerSQL {
-			log.Debugf("SQL for Layer(%v):\n%v\n", lName, l.sql)
+			for _, vl := range vlyrs {
+				log.Debugf("SQL for Layer(%v):\n%v\n", lName, vl.sql)
+			}
 		}
 
 		lyrs[lName] = l
@@ -606,6 +637,156 @@ func CreateProvider(config dict.Dicter, maps []provider.Map, providerType string
 	return &p, nil
 }
 
+// layerSource is where the features of a layer, or of a layer variant, are queried from
+type layerSource struct {
+	minZoom uint
+	maxZoom uint
+	tblName string
+	sql     string
+	fields  []string
+}
+
+// readLayerSource reads the tablename and sql of a layer, or of a layer variant. The
+// tablename defaults to tblName.
+func readLayerSource(config dict.Dicter, i int, lName string, tblName string) (layerSource, error) {
+	src := layerSource{maxZoom: tegola.MaxZ}
+
+	var err error
+	src.tblName, err = config.String(ConfigKeyTablename, &tblName)
+	if err != nil {
+		return src, fmt.Errorf("for %v layer (%v) %v has an error: %w", i, lName, ConfigKeyTablename, err)
+	}
+
+	src.sql, err = config.String(ConfigKeySQL, &src.sql)
+	if err != nil {
+		return src, fmt.Errorf("for %v layer (%v) %v has an error: %w", i, lName, ConfigKeySQL, err)
+	}
+
+	if src.tblName != lName && src.tblName != "" && src.sql != "" {
+		log.Debugf("both %v and %v field are specified for layer (%v) %v, using only %[2]v field.", ConfigKeyTablename, ConfigKeySQL, i, lName)
+	}
+
+	return src, nil
+}
+
+// readLayerVariants reads the variants of a layer, which query a tablename or sql of
+// their own for a range of zooms. The variants inherit the fields of the layer.
+func readLayerVariants(layer dict.Dicter, i int, lName string, configs []dict.Dicter, fields []string) ([]layerSource, error) {
+	for _, key := range []string{ConfigKeyTablename, ConfigKeySQL} {
+		if _, ok := layer.Interface(key); ok {
+			return nil, fmt.Errorf("for layer (%v) %v : %v can not be set alongside %v", i, lName, key, ConfigKeyVariants)
+		}
+	}
+
+	sources := make([]layerSource, 0, len(configs))
+	for j, config := range configs {
+		src, err := readLayerSource(config, i, lName, "")
+		if err != nil {
+			return nil, err
+		}
+		if src.tblName == "" && src.sql == "" {
+			return nil, fmt.Errorf("for layer (%v) %v variant (%v) : either %v or %v is required", i, lName, j, ConfigKeyTablename, ConfigKeySQL)
+		}
+
+		minZoom := 0
+		if minZoom, err = config.Int(ConfigKeyMinZoom, &minZoom); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v variant (%v) : %w", i, lName, j, err)
+		}
+
+		maxZoom := tegola.MaxZ
+		if maxZoom, err = config.Int(ConfigKeyMaxZoom, &maxZoom); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v variant (%v) : %w", i, lName, j, err)
+		}
+
+		if minZoom < 0 || minZoom > maxZoom {
+			return nil, fmt.Errorf("for layer (%v) %v variant (%v) : %v (%v) and %v (%v) are not a zoom range", i, lName, j, ConfigKeyMinZoom, minZoom, ConfigKeyMaxZoom, maxZoom)
+		}
+		src.minZoom, src.maxZoom = uint(minZoom), uint(maxZoom)
+
+		if src.fields, err = config.StringSlice(ConfigKeyFields); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v variant (%v) : %w", i, lName, j, err)
+		}
+		if src.fields == nil {
+			src.fields = fields
+		}
+
+		sources = append(sources, src)
+	}
+
+	return sources, nil
+}
+
+// layerSQL validates the custom sql of a layer, or generates the sql from the
+// tablename and fields when the sql is not set.
+func (p Provider) layerSQL(l *Layer, i int, tblName string, sql string, fields []string, providerType string) (string, error) {
+	if sql != "" && !isSelectQuery(sql) {
+		// if it is not a SELECT query, then we assume we have a sub-query
+		// (`(select ...) as foo`) which we can handle like a tablename
+		tblName = sql
+		sql = ""
+	}
+
+	if sql == "" {
+		// Tablename and Fields will be used to build the query.
+		// We need to do some work. We need to check to see Fields contains the geom and gid fields
+		// and if not add them to the list. If Fields list is empty/nil we will use '*' for the field list.
+		// genSQL quotes the fields in place, so it's handed a copy.
+		fields = append([]string(nil), fields...)
+		if len(fields) == 0 {
+			var err error
+			fields, err = getTableFieldNames(p.pool, l, tblName)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		sql, err := genSQL(l, tblName, fields, true, providerType)
+		if err != nil {
+			return "", fmt.Errorf("could not generate sql, for layer(%v): %w", l.name, err)
+		}
+		return sql, nil
+	}
+
+	sql = sanitizeSQL(sql)
+	// make sure that the sql has a !BBOX! token
+	if !strings.Contains(sql, bboxToken) {
+		return "", fmt.Errorf("SQL for layer (%v) %v is missing required token: %v", i, l.name, bboxToken)
+	}
+	if !strings.Contains(sql, "*") {
+		if !strings.Contains(sql, l.geomField) {
+			return "", ErrGeomFieldNotFound{
+				GeomFieldName: l.geomField,
+				LayerName:     l.name,
+			}
+		}
+		if !strings.Contains(sql, l.idField) {
+			return "", fmt.Errorf("SQL for layer (%v) %v does not contain the id field for the geometry: %v", i, l.name, sql)
+		}
+	}
+
+	return sql, nil
+}
+
+// sameFields reports whether the field lists have the same fields, in any order.
+func sameFields(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	fields := make(map[string]int, len(a))
+	for _, f := range a {
+		fields[f]++
+	}
+	for _, f := range b {
+		if fields[f] == 0 {
+			return false
+		}
+		fields[f]--
+	}
+
+	return true
+}
+
 // setLayerGeomType sets the geomType field on the layer to one of point,
 // linestring, polygon, multipoint, multilinestring, multipolygon or
 // geometrycollection
@@ -755,6 +936,12 @@ func (p Provider) TileFeatures(ctx context.Context, layer string, tile provider.
 		return ErrLayerNotFound{layer}
 	}
 
+	z, _, _ := tile.ZXY()
+	if plyr, ok = plyr.atZoom(uint(z)); !ok {
+		// none of the layer variants cover the zoom of the tile
+		return nil
+	}
+
 	sqlQuery, err := replaceTokens(p.dbVersion, plyr.sql, plyr.IDFieldName(), plyr.GeomFieldName(), plyr.GeomType(), plyr.SRID(), tile, true)
 	if err != nil {
 		return fmt.Errorf("error replacing layer tokens for layer (%v) SQL (%v): %w", layer, sqlQuery, err)
@@ -782,7 +969,6 @@ func (p Provider) TileFeatures(ctx context.Context, layer string, tile provider.
 	}
 
 	if p.queryHistogramSeconds != nil {
-		z, _, _ := tile.ZXY()
 		lbls := prometheus.Labels{
 			"z":          strconv.FormatUint(uint64(z), 10),
 			"map_name":   mapName,
@@ -876,6 +1062,7 @@ func (p Provider) MVTForLayers(ctx context.Context, tile provider.Tile, params p
 	var mvtBytes bytes.Buffer
 	var totalSeconds float64
 	totalSeconds = 0.0
+	z, _, _ := tile.ZXY()
 
 	for i := range layers {
 		layer := layers[i]
@@ -889,6 +1076,10 @@ func (p Provider) MVTForLayers(ctx context.Context, tile provider.Tile, params p
 			// spam the user?
 			log.Warnf("provider layer not found %v", layer.Name)
 		}
+		if l, ok = l.atZoom(uint(z)); !ok {
+			// none of the layer variants cover the zoom of the tile
+			continue
+		}
 		if debugLayerSQL {
 			log.Debugf("SQL for Layer(%v):\n%v\n", l.Name(), l.sql)
 		}
@@ -948,7 +1139,6 @@ func (p Provider) MVTForLayers(ctx context.Context, tile provider.Tile, params p
 	}
 
 	if p.mvtProviderQueryHistogramSeconds != nil {
-		z, _, _ := tile.ZXY()
 		lbls := prometheus.Labels{
 			"z":        strconv.FormatUint(uint64(z), 10),
 			"map_name": mapName,
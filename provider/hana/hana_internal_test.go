@@ -0,0 +1,90 @@
+package hana
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-spatial/tegola/dict"
+)
+
+func TestReadLayerVariants(t *testing.T) {
+	type tcase struct {
+		layer    dict.Dict
+		expected []layerSource
+		err      string
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			variants, _ := tc.layer.MapSlice(ConfigKeyVariants)
+			sources, err := readLayerVariants(tc.layer, 0, "roads", variants, []string{"name"})
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Errorf("expected %v got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if !reflect.DeepEqual(sources, tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, sources)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"variants": {
+			layer: dict.Dict{
+				ConfigKeyVariants: []map[string]interface{}{
+					{ConfigKeyMaxZoom: 8, ConfigKeyTablename: "roads_low"},
+					{ConfigKeyMinZoom: 9, ConfigKeySQL: "SELECT * FROM roads WHERE !BBOX!", ConfigKeyFields: []string{"name", "class"}},
+				},
+			},
+			expected: []layerSource{
+				{minZoom: 0, maxZoom: 8, tblName: "roads_low", fields: []string{"name"}},
+				{minZoom: 9, maxZoom: 22, sql: "SELECT * FROM roads WHERE !BBOX!", fields: []string{"name", "class"}},
+			},
+		},
+		"sql alongside variants": {
+			layer: dict.Dict{
+				ConfigKeySQL:      "SELECT * FROM roads WHERE !BBOX!",
+				ConfigKeyVariants: []map[string]interface{}{{ConfigKeyTablename: "roads"}},
+			},
+			err: "for layer (0) roads : sql can not be set alongside variants",
+		},
+		"missing tablename": {
+			layer: dict.Dict{
+				ConfigKeyVariants: []map[string]interface{}{{ConfigKeyMaxZoom: 8}},
+			},
+			err: "for layer (0) roads variant (0) : either tablename or sql is required",
+		},
+		"invalid zooms": {
+			layer: dict.Dict{
+				ConfigKeyVariants: []map[string]interface{}{{ConfigKeyMinZoom: -1, ConfigKeyTablename: "roads"}},
+			},
+			err: "for layer (0) roads variant (0) : min_zoom (-1) and max_zoom (22) are not a zoom range",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestLayerAtZoom(t *testing.T) {
+	l := Layer{
+		name: "roads",
+		variants: []variant{
+			{minZoom: 0, maxZoom: 8, sql: "low"},
+			{minZoom: 5, maxZoom: 12, sql: "mid"},
+		},
+	}
+
+	for z, expected := range map[uint]string{0: "low", 8: "low", 9: "mid", 12: "mid", 13: ""} {
+		vl, ok := l.atZoom(z)
+		if ok != (expected != "") || vl.sql != expected {
+			t.Errorf("zoom %v, expected %q got %q", z, expected, vl.sql)
+		}
+	}
+}
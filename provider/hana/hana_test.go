@@ -228,6 +228,26 @@ func TestNewTileProvider(t *testing.T) {
 	}
 }
 
+func TestNewTileProviderVariantGeomTypes(t *testing.T) {
+	ttools.ShouldSkip(t, TESTENV)
+
+	config := TCConfig{
+		LayerConfig: []map[string]interface{}{{
+			hana.ConfigKeyLayerName: "land",
+			hana.ConfigKeyVariants: []map[string]interface{}{
+				{hana.ConfigKeyMaxZoom: 0, hana.ConfigKeySQL: `(SELECT "scalerank", "geom".ST_Centroid() AS "geom" FROM "TEGOLACI"."ne_10m_land_scale_rank" WHERE !BBOX!) AS sub`},
+				{hana.ConfigKeyMinZoom: 1, hana.ConfigKeyTablename: `"TEGOLACI"."ne_10m_land_scale_rank"`},
+			},
+		}},
+	}.Config()
+
+	expected := "for layer (0) land variant (1) : geometry type geom.MultiPolygon differs from the geometry type geom.Point of the other variants"
+	_, err := hana.NewTileProvider(config, nil)
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %v got %v", expected, err)
+	}
+}
+
 func TestTileFeatures(t *testing.T) {
 	ttools.ShouldSkip(t, TESTENV)
 
@@ -293,6 +313,33 @@ func TestTileFeatures(t *testing.T) {
 			expectedFeatureCount: 1212,
 			expectedTags:         []string{"scalerank", "featurecla"},
 		},
+		"variants": {
+			TCConfig: TCConfig{
+				LayerConfig: []map[string]interface{}{{
+					hana.ConfigKeyLayerName: "land",
+					hana.ConfigKeyFields:    []string{"scalerank"},
+					hana.ConfigKeyVariants: []map[string]interface{}{
+						{hana.ConfigKeyMaxZoom: 0, hana.ConfigKeySQL: `(SELECT "geom", "scalerank" FROM "TEGOLACI"."ne_10m_land_scale_rank" WHERE !BBOX! LIMIT 10) AS sub`},
+						{hana.ConfigKeyMinZoom: 1, hana.ConfigKeyTablename: `"TEGOLACI"."ne_10m_land_scale_rank"`},
+					},
+				}},
+			},
+			tile:                 provider.NewTile(1, 1, 1, 64, tegola.WebMercator),
+			expectedFeatureCount: 1212,
+			expectedTags:         []string{"scalerank"},
+		},
+		"variants not covering the zoom": {
+			TCConfig: TCConfig{
+				LayerConfig: []map[string]interface{}{{
+					hana.ConfigKeyLayerName: "land",
+					hana.ConfigKeyVariants: []map[string]interface{}{
+						{hana.ConfigKeyMinZoom: 5, hana.ConfigKeyTablename: `"TEGOLACI"."ne_10m_land_scale_rank"`},
+					},
+				}},
+			},
+			tile:                 provider.NewTile(1, 1, 1, 64, tegola.WebMercator),
+			expectedFeatureCount: 0,
+		},
 		"tablename query with fields": {
 			TCConfig: TCConfig{
 				LayerConfig: []map[string]interface{}{{
@@ -18,6 +18,34 @@ type Layer struct {
 	srid uint64
 	// The description of fields in the sql query. Used only by the non-MVT provider.
 	fields []FieldDescription
+	// The variants of the SQL by zoom, which are used instead of sql and fields when set.
+	variants []variant
+}
+
+// variant is the SQL of a layer for a range of zooms.
+type variant struct {
+	minZoom uint
+	maxZoom uint
+	sql     string
+	fields  []FieldDescription
+}
+
+// atZoom returns the layer with the SQL and fields of the first variant covering the
+// zoom, for layers with variants. It reports false when none of the variants cover the
+// zoom, in which case the layer has no features at the zoom.
+func (l Layer) atZoom(z uint) (Layer, bool) {
+	if len(l.variants) == 0 {
+		return l, true
+	}
+
+	for _, v := range l.variants {
+		if z >= v.minZoom && z <= v.maxZoom {
+			l.sql, l.fields = v.sql, v.fields
+			return l, true
+		}
+	}
+
+	return l, false
 }
 
 func (l Layer) Name() string {
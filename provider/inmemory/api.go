@@ -0,0 +1,93 @@
+package inmemory
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MaxRequestSize is the max size of the body of a write request
+const MaxRequestSize = 32 << 20
+
+// ServeHTTP serves the write api of the provider. The requests must be authenticated
+// with the write token as a bearer token. The paths are relative to the route of the
+// provider:
+//
+//	POST   /layers/:layer_name/features      upserts the features of a GeoJSON Feature or FeatureCollection by id
+//	PUT    /layers/:layer_name/features      same as POST
+//	DELETE /layers/:layer_name/features      deletes all the features of the layer
+//	DELETE /layers/:layer_name/features/:id  deletes a feature
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="tegola"`)
+		http.Error(w, "invalid or missing write token", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] != "layers" || parts[2] != "features" {
+		http.NotFound(w, r)
+		return
+	}
+	l, ok := p.layers[parts[1]]
+	if !ok {
+		http.Error(w, ErrUnknownLayer{Name: parts[1]}.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 3 && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		features, err := decodeFeatures(b)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid GeoJSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		l.upsert(features)
+		p.written()
+
+	case len(parts) == 3 && r.Method == http.MethodDelete:
+		l.clear()
+		p.written()
+
+	case len(parts) == 4 && r.Method == http.MethodDelete:
+		id, err := strconv.ParseUint(parts[3], 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid feature id (%v)", parts[3]), http.StatusBadRequest)
+			return
+		}
+		if !l.delete(id) {
+			http.Error(w, fmt.Sprintf("feature (%v) not found", id), http.StatusNotFound)
+			return
+		}
+		p.written()
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorized reports whether the request has the write token as a bearer token
+func (p *Provider) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(p.writeToken)) == 1
+}
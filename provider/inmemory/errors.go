@@ -0,0 +1,22 @@
+package inmemory
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrMissingLayerName  = errors.New("inmemory: layer is missing 'name'")
+	ErrMissingLayers     = errors.New("inmemory: no layers configured")
+	ErrMissingWriteToken = errors.New("inmemory: missing 'write_token', which authenticates the write api")
+)
+
+// ErrUnknownLayer is returned when features are requested or written for a layer the
+// provider doesn't have
+type ErrUnknownLayer struct {
+	Name string
+}
+
+func (e ErrUnknownLayer) Error() string {
+	return fmt.Sprintf("inmemory: unknown layer (%v)", e.Name)
+}
@@ -0,0 +1,153 @@
+package inmemory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// geojsonObject is a GeoJSON Feature or FeatureCollection
+type geojsonObject struct {
+	Type string `json:"type"`
+	// Features are the features of a FeatureCollection
+	Features []geojsonFeature `json:"features"`
+	// the members of a Feature
+	geojsonFeature
+}
+
+type geojsonFeature struct {
+	ID         interface{}            `json:"id"`
+	Geometry   *geojson.Geometry      `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// decodeFeatures decodes the features of a GeoJSON FeatureCollection or Feature. The
+// features must have ids, which are numbers or strings of numbers, and geometries.
+func decodeFeatures(b []byte) ([]*feature, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var obj geojsonObject
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+
+	var gjFeatures []geojsonFeature
+	switch strings.ToLower(obj.Type) {
+	case "featurecollection":
+		gjFeatures = obj.Features
+	case "feature":
+		gjFeatures = []geojsonFeature{obj.geojsonFeature}
+	default:
+		return nil, fmt.Errorf("unsupported GeoJSON type (%v). expecting FeatureCollection or Feature", obj.Type)
+	}
+
+	features := make([]*feature, 0, len(gjFeatures))
+	for i, gf := range gjFeatures {
+		if gf.ID == nil {
+			return nil, fmt.Errorf("feature %v has no id", i)
+		}
+		id, err := provider.ConvertFeatureID(jsonValue(gf.ID))
+		if err != nil {
+			return nil, fmt.Errorf("feature %v: %w", i, err)
+		}
+		if gf.Geometry == nil || gf.Geometry.Geometry == nil {
+			return nil, fmt.Errorf("feature %v has no geometry", id)
+		}
+
+		// the snapshots encode the geometries as GeoJSON, which closes the rings of
+		// polygons in place. the rings are closed before the features are shared.
+		g := closeRings(gf.Geometry.Geometry)
+		ext, err := geom.NewExtentFromGeometry(g)
+		if err != nil {
+			return nil, fmt.Errorf("feature %v: %w", id, err)
+		}
+
+		f := feature{
+			id:       id,
+			geometry: g,
+			extent:   ext,
+			tags:     make(map[string]interface{}, len(gf.Properties)),
+		}
+		for k, v := range gf.Properties {
+			if v != nil {
+				f.tags[k] = jsonValue(v)
+			}
+		}
+		features = append(features, &f)
+	}
+	return features, nil
+}
+
+// encodeFeatures encodes features as a GeoJSON FeatureCollection
+func encodeFeatures(features []*feature) geojson.FeatureCollection {
+	fc := geojson.FeatureCollection{Features: make([]geojson.Feature, 0, len(features))}
+	for _, f := range features {
+		id := f.id
+		fc.Features = append(fc.Features, geojson.Feature{
+			ID:         &id,
+			Geometry:   geojson.Geometry{Geometry: f.geometry},
+			Properties: f.tags,
+		})
+	}
+	return fc
+}
+
+// jsonValue converts decoded JSON numbers to int64 or float64. Objects and arrays are
+// encoded back to JSON strings, as MVT tags can't be nested.
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return nil
+		}
+		return string(b)
+	default:
+		return v
+	}
+}
+
+// closeRings returns the geometry with the rings of its polygons closed
+func closeRings(g geom.Geometry) geom.Geometry {
+	closePolygon := func(p geom.Polygon) geom.Polygon {
+		closed := make(geom.Polygon, len(p))
+		for i, ring := range p {
+			closed[i] = ring
+			if len(ring) > 0 && ring[0] != ring[len(ring)-1] {
+				closed[i] = append(ring[:len(ring):len(ring)], ring[0])
+			}
+		}
+		return closed
+	}
+
+	switch g := g.(type) {
+	case geom.Polygon:
+		return closePolygon(g)
+	case geom.MultiPolygon:
+		closed := make(geom.MultiPolygon, len(g))
+		for i := range g {
+			closed[i] = closePolygon(g[i])
+		}
+		return closed
+	case geom.Collection:
+		closed := make(geom.Collection, len(g))
+		for i := range g {
+			closed[i] = closeRings(g[i])
+		}
+		return closed
+	default:
+		return g
+	}
+}
@@ -0,0 +1,129 @@
+package inmemory
+
+import (
+	"math"
+
+	"github.com/go-spatial/geom"
+)
+
+const (
+	// cellSize is the size, in degrees, of the cells of the grid index
+	cellSize = 0.1
+	// maxCells is the number of cells a feature can be added to. Larger features are
+	// kept apart and checked by every search.
+	maxCells = 64
+)
+
+type cell struct {
+	x, y int
+}
+
+// cellRange is the range of the cells an extent covers
+type cellRange struct {
+	minX, minY, maxX, maxY int
+}
+
+func cellRangeOf(ext *geom.Extent) cellRange {
+	return cellRange{
+		minX: int(math.Floor(ext.MinX() / cellSize)),
+		minY: int(math.Floor(ext.MinY() / cellSize)),
+		maxX: int(math.Floor(ext.MaxX() / cellSize)),
+		maxY: int(math.Floor(ext.MaxY() / cellSize)),
+	}
+}
+
+func (cr cellRange) len() int {
+	return (cr.maxX - cr.minX + 1) * (cr.maxY - cr.minY + 1)
+}
+
+func (cr cellRange) contains(c cell) bool {
+	return c.x >= cr.minX && c.x <= cr.maxX && c.y >= cr.minY && c.y <= cr.maxY
+}
+
+// index is a grid index of the features of a layer by their ids. Unlike an R-tree bulk
+// loaded once, the grid is updated in place as the features are written.
+type index struct {
+	cells map[cell]map[uint64]struct{}
+	// large are the features covering more than maxCells cells
+	large map[uint64]struct{}
+}
+
+func newIndex() *index {
+	return &index{
+		cells: map[cell]map[uint64]struct{}{},
+		large: map[uint64]struct{}{},
+	}
+}
+
+func (idx *index) insert(id uint64, ext *geom.Extent) {
+	cr := cellRangeOf(ext)
+	if cr.len() > maxCells {
+		idx.large[id] = struct{}{}
+		return
+	}
+
+	for x := cr.minX; x <= cr.maxX; x++ {
+		for y := cr.minY; y <= cr.maxY; y++ {
+			c := cell{x, y}
+			ids, ok := idx.cells[c]
+			if !ok {
+				ids = map[uint64]struct{}{}
+				idx.cells[c] = ids
+			}
+			ids[id] = struct{}{}
+		}
+	}
+}
+
+// remove removes a feature inserted with the extent
+func (idx *index) remove(id uint64, ext *geom.Extent) {
+	cr := cellRangeOf(ext)
+	if cr.len() > maxCells {
+		delete(idx.large, id)
+		return
+	}
+
+	for x := cr.minX; x <= cr.maxX; x++ {
+		for y := cr.minY; y <= cr.maxY; y++ {
+			c := cell{x, y}
+			ids := idx.cells[c]
+			delete(ids, id)
+			if len(ids) == 0 {
+				delete(idx.cells, c)
+			}
+		}
+	}
+}
+
+// search returns the ids of the features in the cells the extent covers, which may not
+// intersect the extent themselves
+func (idx *index) search(ext *geom.Extent) map[uint64]struct{} {
+	found := make(map[uint64]struct{}, len(idx.large))
+	for id := range idx.large {
+		found[id] = struct{}{}
+	}
+
+	add := func(ids map[uint64]struct{}) {
+		for id := range ids {
+			found[id] = struct{}{}
+		}
+	}
+
+	cr := cellRangeOf(ext)
+	// an extent at low zooms covers many more cells than have features
+	if cr.len() > len(idx.cells) {
+		for c, ids := range idx.cells {
+			if cr.contains(c) {
+				add(ids)
+			}
+		}
+		return found
+	}
+
+	for x := cr.minX; x <= cr.maxX; x++ {
+		for y := cr.minY; y <= cr.maxY; y++ {
+			add(idx.cells[cell{x, y}])
+		}
+	}
+	return found
+}
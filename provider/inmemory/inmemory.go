@@ -0,0 +1,206 @@
+// Package inmemory implements the inmemory provider. The features of its layers are
+// written over an http api and held in memory in a grid index, so layers such as vehicle
+// positions can be updated many times a minute. The tiles of the layers are not cached,
+// and the features can be snapshotted to disk to be restored on restarts.
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/basic"
+	"github.com/go-spatial/tegola/provider"
+)
+
+const Name = "inmemory"
+
+// config keys
+const (
+	ConfigKeyWriteToken       = "write_token"
+	ConfigKeySnapshotPath     = "snapshot_path"
+	ConfigKeySnapshotInterval = "snapshot_interval"
+	ConfigKeyLayers           = "layers"
+	ConfigKeyLayerName        = "name"
+	ConfigKeyGeomType         = "geometry_type"
+)
+
+const DefaultSnapshotInterval = "30s"
+
+// feature is a feature of a layer, which is replaced and never modified once written
+type feature struct {
+	id       uint64
+	geometry geom.Geometry
+	extent   *geom.Extent
+	tags     map[string]interface{}
+}
+
+type Layer struct {
+	name     string
+	geomType geom.Geometry
+
+	// mu guards features and their index
+	mu       sync.RWMutex
+	features map[uint64]*feature
+	index    *index
+}
+
+func newLayer(name string, geomType geom.Geometry) *Layer {
+	return &Layer{
+		name:     name,
+		geomType: geomType,
+		features: map[uint64]*feature{},
+		index:    newIndex(),
+	}
+}
+
+func (l *Layer) Name() string            { return l.name }
+func (l *Layer) GeomType() geom.Geometry { return l.geomType }
+
+// SRID of the features, which are GeoJSON features in WGS84
+func (l *Layer) SRID() uint64 { return tegola.WGS84 }
+
+// upsert adds the features, replacing the features with the same ids
+func (l *Layer) upsert(features []*feature) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, f := range features {
+		if old, ok := l.features[f.id]; ok {
+			l.index.remove(old.id, old.extent)
+		}
+		l.features[f.id] = f
+		l.index.insert(f.id, f.extent)
+	}
+}
+
+// delete removes a feature, and reports whether the layer had it
+func (l *Layer) delete(id uint64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.features[id]
+	if !ok {
+		return false
+	}
+	l.index.remove(f.id, f.extent)
+	delete(l.features, id)
+	return true
+}
+
+// clear removes all the features
+func (l *Layer) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.features = map[uint64]*feature{}
+	l.index = newIndex()
+}
+
+// intersecting returns the features intersecting the extent by id
+func (l *Layer) intersecting(ext *geom.Extent) []*feature {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	ids := l.index.search(ext)
+	features := make([]*feature, 0, len(ids))
+	for id := range ids {
+		f := l.features[id]
+		// the extents of points have no area, so touching extents intersect
+		if f.extent.MinX() <= ext.MaxX() && f.extent.MaxX() >= ext.MinX() &&
+			f.extent.MinY() <= ext.MaxY() && f.extent.MaxY() >= ext.MinY() {
+			features = append(features, f)
+		}
+	}
+	sort.Slice(features, func(i, j int) bool { return features[i].id < features[j].id })
+	return features
+}
+
+// all returns all the features by id
+func (l *Layer) all() []*feature {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	features := make([]*feature, 0, len(l.features))
+	for _, f := range l.features {
+		features = append(features, f)
+	}
+	sort.Slice(features, func(i, j int) bool { return features[i].id < features[j].id })
+	return features
+}
+
+type Provider struct {
+	layers map[string]*Layer
+	// writeToken authenticates the requests of the write api
+	writeToken string
+
+	// SnapshotPath is the file the features are snapshotted to, if any
+	SnapshotPath     string
+	snapshotInterval time.Duration
+	// writes counts the writes, and snapshotted the writes of the last snapshot
+	writes      uint64
+	snapshotted uint64
+	snapshotMu  sync.Mutex
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+func (p *Provider) Layers() ([]provider.LayerInfo, error) {
+	ls := make([]provider.LayerInfo, 0, len(p.layers))
+	for _, l := range p.layers {
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
+
+// Live reports the features of the provider are live, so the tiles of its layers are
+// not cached
+func (p *Provider) Live() bool { return true }
+
+func (p *Provider) TileFeatures(ctx context.Context, layer string, tile provider.Tile, queryParams provider.Params, fn func(f *provider.Feature) error) error {
+	l, ok := p.layers[layer]
+	if !ok {
+		return ErrUnknownLayer{Name: layer}
+	}
+
+	// convert the tile extent to the SRID of the features
+	tileBBox, _ := tile.BufferedExtent()
+	minGeo, err := basic.FromWebMercator(tegola.WGS84, geom.Point{tileBBox.MinX(), tileBBox.MinY()})
+	if err != nil {
+		return fmt.Errorf("error converting point: %v ", err)
+	}
+	maxGeo, err := basic.FromWebMercator(tegola.WGS84, geom.Point{tileBBox.MaxX(), tileBBox.MaxY()})
+	if err != nil {
+		return fmt.Errorf("error converting point: %v ", err)
+	}
+	tileBBox = geom.NewExtent(minGeo.(geom.Point), maxGeo.(geom.Point))
+
+	for _, f := range l.intersecting(tileBBox) {
+		// check if the context cancelled or timed out
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// the tags are copied as the features are shared by every tile
+		tags := make(map[string]interface{}, len(f.tags))
+		for k, v := range f.tags {
+			tags[k] = v
+		}
+
+		if err := fn(&provider.Feature{ID: f.id, Geometry: f.geometry, SRID: tegola.WGS84, Tags: tags}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// written records a write, for the snapshots
+func (p *Provider) written() {
+	atomic.AddUint64(&p.writes, 1)
+}
@@ -0,0 +1,293 @@
+package inmemory
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/provider"
+)
+
+const testToken = "secret"
+
+func newTestProvider(t *testing.T, config dict.Dict) *Provider {
+	t.Helper()
+
+	c := dict.Dict{
+		ConfigKeyWriteToken: testToken,
+		ConfigKeyLayers: []map[string]interface{}{
+			{"name": "vehicles", "geometry_type": "point"},
+			{"name": "incidents"},
+		},
+	}
+	for k, v := range config {
+		c[k] = v
+	}
+
+	p, err := NewTileProvider(c, nil)
+	if err != nil {
+		t.Fatalf("new provider, expected nil got %v", err)
+	}
+	t.Cleanup(func() { p.(*Provider).Close() })
+	return p.(*Provider)
+}
+
+// write requests the write api of the provider, and returns the response status
+func write(t *testing.T, p *Provider, method, path, token, body string) int {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	return w.Code
+}
+
+// tileFeatures returns the features of the layer in the tile by id
+func tileFeatures(t *testing.T, p *Provider, layer string, tile provider.Tile) map[uint64]provider.Feature {
+	t.Helper()
+
+	features := map[uint64]provider.Feature{}
+	err := p.TileFeatures(context.Background(), layer, tile, nil, func(f *provider.Feature) error {
+		features[f.ID] = *f
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("tile features, expected nil got %v", err)
+	}
+	return features
+}
+
+func TestWriteAPI(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}
+
+	type tcase struct {
+		requests []request
+		// expected are the ids of the features of the vehicles layer in the world tile
+		expected []uint64
+	}
+
+	const (
+		vehicles = `{"type": "FeatureCollection", "features": [
+			{"type": "Feature", "id": 1, "geometry": {"type": "Point", "coordinates": [-122.41, 37.77]}, "properties": {"route": "N"}},
+			{"type": "Feature", "id": "2", "geometry": {"type": "Point", "coordinates": [2.35, 48.85]}, "properties": {"route": "A"}}
+		]}`
+		moved = `{"type": "Feature", "id": 1, "geometry": {"type": "Point", "coordinates": [-122.40, 37.78]}, "properties": {"route": "N"}}`
+	)
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			p := newTestProvider(t, nil)
+			for i, r := range tc.requests {
+				if status := write(t, p, r.method, r.path, r.token, r.body); status != r.status {
+					t.Fatalf("request %v status, expected %v got %v", i, r.status, status)
+				}
+			}
+
+			features := tileFeatures(t, p, "vehicles", provider.NewTile(0, 0, 0, 64, tegola.WebMercator))
+			var ids []uint64
+			for id := range features {
+				ids = append(ids, id)
+			}
+			if len(ids) != len(tc.expected) {
+				t.Fatalf("expected %v got %v", tc.expected, ids)
+			}
+			for _, id := range tc.expected {
+				if _, ok := features[id]; !ok {
+					t.Errorf("expected %v got %v", tc.expected, ids)
+				}
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"upsert": {
+			requests: []request{
+				{http.MethodPost, "/layers/vehicles/features", testToken, vehicles, http.StatusNoContent},
+				{http.MethodPut, "/layers/vehicles/features", testToken, moved, http.StatusNoContent},
+			},
+			expected: []uint64{1, 2},
+		},
+		"delete": {
+			requests: []request{
+				{http.MethodPost, "/layers/vehicles/features", testToken, vehicles, http.StatusNoContent},
+				{http.MethodDelete, "/layers/vehicles/features/2", testToken, "", http.StatusNoContent},
+				{http.MethodDelete, "/layers/vehicles/features/2", testToken, "", http.StatusNotFound},
+			},
+			expected: []uint64{1},
+		},
+		"clear": {
+			requests: []request{
+				{http.MethodPost, "/layers/vehicles/features", testToken, vehicles, http.StatusNoContent},
+				{http.MethodDelete, "/layers/vehicles/features", testToken, "", http.StatusNoContent},
+			},
+		},
+		"unauthorized": {
+			requests: []request{
+				{http.MethodPost, "/layers/vehicles/features", "", vehicles, http.StatusUnauthorized},
+				{http.MethodPost, "/layers/vehicles/features", "wrong", vehicles, http.StatusUnauthorized},
+			},
+		},
+		"invalid": {
+			requests: []request{
+				{http.MethodPost, "/layers/vehicles/features", testToken, `{"type": "Point", "coordinates": [0, 0]}`, http.StatusBadRequest},
+				{http.MethodPost, "/layers/vehicles/features", testToken, `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [0, 0]}}`, http.StatusBadRequest},
+				{http.MethodPost, "/layers/vehicles/features", testToken, `{"type": "Feature", "id": 3, "geometry": null}`, http.StatusBadRequest},
+				{http.MethodPost, "/layers/roads/features", testToken, vehicles, http.StatusNotFound},
+				{http.MethodGet, "/layers/vehicles/features", testToken, "", http.StatusMethodNotAllowed},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestTileFeatures(t *testing.T) {
+	p := newTestProvider(t, nil)
+	body := `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "id": 1, "geometry": {"type": "Point", "coordinates": [-122.41, 37.77]}, "properties": {"route": "N", "stops": {"next": 3}}},
+		{"type": "Feature", "id": 2, "geometry": {"type": "Point", "coordinates": [2.35, 48.85]}},
+		{"type": "Feature", "id": 3, "geometry": {"type": "Polygon", "coordinates": [[[-180, -80], [180, -80], [180, 80], [-180, 80]]]}}
+	]}`
+	if status := write(t, p, http.MethodPost, "/layers/incidents/features", testToken, body); status != http.StatusNoContent {
+		t.Fatalf("status, expected %v got %v", http.StatusNoContent, status)
+	}
+
+	type tcase struct {
+		tile     provider.Tile
+		expected []uint64
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			features := tileFeatures(t, p, "incidents", tc.tile)
+			var ids []uint64
+			for id := range features {
+				ids = append(ids, id)
+			}
+			if len(ids) != len(tc.expected) {
+				t.Fatalf("expected %v got %v", tc.expected, ids)
+			}
+			for _, id := range tc.expected {
+				if _, ok := features[id]; !ok {
+					t.Errorf("expected %v got %v", tc.expected, ids)
+				}
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"world": {
+			tile:     provider.NewTile(0, 0, 0, 64, tegola.WebMercator),
+			expected: []uint64{1, 2, 3},
+		},
+		"san francisco": {
+			tile:     provider.NewTile(12, 655, 1583, 64, tegola.WebMercator),
+			expected: []uint64{1, 3},
+		},
+		"paris": {
+			tile:     provider.NewTile(12, 2074, 1409, 64, tegola.WebMercator),
+			expected: []uint64{2, 3},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+
+	// the tags are decoded, and the rings of polygons closed
+	features := tileFeatures(t, p, "incidents", provider.NewTile(0, 0, 0, 64, tegola.WebMercator))
+	if expected := map[string]interface{}{"route": "N", "stops": `{"next":3}`}; !reflect.DeepEqual(features[1].Tags, expected) {
+		t.Errorf("tags, expected %v got %v", expected, features[1].Tags)
+	}
+	if expected := (geom.Polygon{{{-180, -80}, {180, -80}, {180, 80}, {-180, 80}, {-180, -80}}}); !reflect.DeepEqual(features[3].Geometry, expected) {
+		t.Errorf("geometry, expected %v got %v", expected, features[3].Geometry)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "live.json")
+	config := dict.Dict{ConfigKeySnapshotPath: path}
+
+	p := newTestProvider(t, config)
+	body := `{"type": "Feature", "id": 7, "geometry": {"type": "Point", "coordinates": [2.35, 48.85]}, "properties": {"route": "A", "speed": 42.5}}`
+	if status := write(t, p, http.MethodPost, "/layers/vehicles/features", testToken, body); status != http.StatusNoContent {
+		t.Fatalf("status, expected %v got %v", http.StatusNoContent, status)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("close, expected nil got %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("snapshot, expected nil got %v", err)
+	}
+
+	restored := newTestProvider(t, config)
+	features := tileFeatures(t, restored, "vehicles", provider.NewTile(0, 0, 0, 64, tegola.WebMercator))
+	expected := map[uint64]provider.Feature{7: {
+		ID:       7,
+		Geometry: geom.Point{2.35, 48.85},
+		SRID:     tegola.WGS84,
+		Tags:     map[string]interface{}{"route": "A", "speed": 42.5},
+	}}
+	if !reflect.DeepEqual(features, expected) {
+		t.Errorf("expected %v got %v", expected, features)
+	}
+}
+
+func TestNewTileProvider(t *testing.T) {
+	type tcase struct {
+		config dict.Dict
+		err    string
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			_, err := NewTileProvider(tc.config, nil)
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("expected %v got %v", tc.err, err)
+			}
+		}
+	}
+
+	layers := []map[string]interface{}{{"name": "vehicles"}}
+	tests := map[string]tcase{
+		"missing write token": {
+			config: dict.Dict{ConfigKeyLayers: layers},
+			err:    ErrMissingWriteToken.Error(),
+		},
+		"missing layers": {
+			config: dict.Dict{ConfigKeyWriteToken: testToken},
+			err:    ErrMissingLayers.Error(),
+		},
+		"invalid snapshot interval": {
+			config: dict.Dict{ConfigKeyWriteToken: testToken, ConfigKeyLayers: layers, ConfigKeySnapshotInterval: "0s"},
+			err:    "invalid snapshot_interval (0s), expecting a positive duration",
+		},
+		"invalid geometry type": {
+			config: dict.Dict{ConfigKeyWriteToken: testToken, ConfigKeyLayers: []map[string]interface{}{{"name": "vehicles", "geometry_type": "circle"}}},
+			err:    "for layer (0) vehicles : unsupported geometry_type (circle)",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
@@ -0,0 +1,137 @@
+package inmemory
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/provider"
+)
+
+func init() {
+	provider.Register(provider.TypeStd.Prefix()+Name, NewTileProvider, Cleanup)
+}
+
+// NewTileProvider instantiates a provider of layers whose features are written over the
+// write api of the provider. The features are restored from the snapshot file when one
+// is configured.
+func NewTileProvider(config dict.Dicter, _ []provider.Map) (provider.Tiler, error) {
+	var token string
+	token, err := config.String(ConfigKeyWriteToken, &token)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, ErrMissingWriteToken
+	}
+
+	p := Provider{
+		layers:     map[string]*Layer{},
+		writeToken: token,
+		done:       make(chan struct{}),
+	}
+
+	if p.SnapshotPath, err = config.String(ConfigKeySnapshotPath, &p.SnapshotPath); err != nil {
+		return nil, err
+	}
+	interval := DefaultSnapshotInterval
+	if interval, err = config.String(ConfigKeySnapshotInterval, &interval); err != nil {
+		return nil, err
+	}
+	if p.snapshotInterval, err = time.ParseDuration(interval); err != nil {
+		return nil, fmt.Errorf("invalid %v (%v): %w", ConfigKeySnapshotInterval, interval, err)
+	}
+	if p.snapshotInterval <= 0 {
+		return nil, fmt.Errorf("invalid %v (%v), expecting a positive duration", ConfigKeySnapshotInterval, interval)
+	}
+
+	layers, err := config.MapSlice(ConfigKeyLayers)
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) == 0 {
+		return nil, ErrMissingLayers
+	}
+
+	for i, layerConf := range layers {
+		layerName, err := layerConf.String(ConfigKeyLayerName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("for layer (%v) we got the following error trying to get the layer's name field: %v", i, err)
+		}
+		if layerName == "" {
+			return nil, ErrMissingLayerName
+		}
+		if _, ok := p.layers[layerName]; ok {
+			return nil, fmt.Errorf("layer name (%v) is duplicated", layerName)
+		}
+
+		var geomType string
+		if geomType, err = layerConf.String(ConfigKeyGeomType, &geomType); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+		g, err := geomTypeOf(geomType)
+		if err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+		p.layers[layerName] = newLayer(layerName, g)
+	}
+
+	if p.SnapshotPath != "" {
+		if err = p.restore(); err != nil {
+			return nil, err
+		}
+		go p.snapshotLoop()
+	}
+
+	// track the provider so we can clean it up later
+	providersLock.Lock()
+	providers = append(providers, &p)
+	providersLock.Unlock()
+
+	return &p, nil
+}
+
+// geomTypeOf returns the geometry of a geometry_type config, or nil when not set
+func geomTypeOf(geomType string) (geom.Geometry, error) {
+	switch strings.ToLower(geomType) {
+	case "":
+		return nil, nil
+	case "point":
+		return geom.Point{}, nil
+	case "multipoint":
+		return geom.MultiPoint{}, nil
+	case "linestring":
+		return geom.LineString{}, nil
+	case "multilinestring":
+		return geom.MultiLineString{}, nil
+	case "polygon":
+		return geom.Polygon{}, nil
+	case "multipolygon":
+		return geom.MultiPolygon{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported %v (%v)", ConfigKeyGeomType, geomType)
+	}
+}
+
+var (
+	providersLock sync.Mutex
+	// reference to all instantiated providers
+	providers []*Provider
+)
+
+// Cleanup snapshots the features of all the previously instantiated providers a last time
+func Cleanup() {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+
+	for _, p := range providers {
+		if err := p.Close(); err != nil {
+			log.Errorf("err snapshotting inmemory provider: %v", err)
+		}
+	}
+	providers = nil
+}
@@ -0,0 +1,121 @@
+package inmemory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/go-spatial/tegola/internal/log"
+)
+
+// snapshot writes the features of the layers to the snapshot file, as a GeoJSON
+// FeatureCollection by layer name, when they were written since the last snapshot. The
+// file is replaced once written, so a failed snapshot leaves the previous one.
+func (p *Provider) snapshot() error {
+	p.snapshotMu.Lock()
+	defer p.snapshotMu.Unlock()
+
+	writes := atomic.LoadUint64(&p.writes)
+	if writes == p.snapshotted {
+		return nil
+	}
+
+	layers := make(map[string]geojson.FeatureCollection, len(p.layers))
+	for name, l := range p.layers {
+		layers[name] = encodeFeatures(l.all())
+	}
+	b, err := json.Marshal(layers)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.SnapshotPath), filepath.Base(p.SnapshotPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), p.SnapshotPath); err != nil {
+		return err
+	}
+
+	p.snapshotted = writes
+	return nil
+}
+
+// restore reads the features of the layers from the snapshot file, if there's one.
+// The features of layers which are no longer configured are dropped.
+func (p *Provider) restore() error {
+	b, err := os.ReadFile(p.SnapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var layers map[string]json.RawMessage
+	if err = json.Unmarshal(b, &layers); err != nil {
+		return fmt.Errorf("reading snapshot %v: %w", p.SnapshotPath, err)
+	}
+
+	for name, raw := range layers {
+		l, ok := p.layers[name]
+		if !ok {
+			log.Warnf("dropping the snapshot features of layer (%v), which is not configured", name)
+			continue
+		}
+		features, err := decodeFeatures(raw)
+		if err != nil {
+			return fmt.Errorf("reading snapshot %v: layer (%v): %w", p.SnapshotPath, name, err)
+		}
+		l.upsert(features)
+		log.Infof("restored layer (%v) with %v features from %v", name, len(features), p.SnapshotPath)
+	}
+	return nil
+}
+
+// snapshotLoop snapshots the features at the snapshot interval, until the provider is
+// closed
+func (p *Provider) snapshotLoop() {
+	t := time.NewTicker(p.snapshotInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-t.C:
+			if err := p.snapshot(); err != nil {
+				log.Errorf("snapshotting the features of the %v provider to %v: %v", Name, p.SnapshotPath, err)
+			}
+		}
+	}
+}
+
+// Close stops the snapshots, and snapshots the features a last time
+func (p *Provider) Close() error {
+	if p.SnapshotPath == "" {
+		return nil
+	}
+
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.done)
+		err = p.snapshot()
+	})
+	return err
+}
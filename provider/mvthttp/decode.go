@@ -0,0 +1,73 @@
+package mvthttp
+
+import (
+	"fmt"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/mvt"
+	vectorTile "github.com/go-spatial/geom/encoding/mvt/vector_tile"
+)
+
+// decodeGeometry decodes the geometry of a feature in the coordinates of its tile. It
+// returns nil for features without a geometry.
+func decodeGeometry(f *vectorTile.Tile_Feature) (geometry geom.Geometry, err error) {
+	switch f.GetType() {
+	case vectorTile.Tile_POINT, vectorTile.Tile_LINESTRING, vectorTile.Tile_POLYGON:
+	default:
+		return nil, fmt.Errorf("unknown geometry type %v", f.GetType())
+	}
+	if len(f.Geometry) == 0 {
+		return nil, nil
+	}
+
+	// the decoder panics on some malformed geometries, which must not take down the
+	// server as they come from another server
+	defer func() {
+		if r := recover(); r != nil {
+			geometry, err = nil, fmt.Errorf("malformed %v geometry", f.GetType())
+		}
+	}()
+	return mvt.DecodeGeometry(f.GetType(), f.Geometry)
+}
+
+// featureTags decodes the tags of a feature from the keys and values of its layer
+func featureTags(l *vectorTile.Tile_Layer, f *vectorTile.Tile_Feature) (map[string]interface{}, error) {
+	if len(f.Tags)%2 != 0 {
+		return nil, fmt.Errorf("odd number of tags (%v) for feature %v", len(f.Tags), f.GetId())
+	}
+
+	tags := make(map[string]interface{}, len(f.Tags)/2)
+	for i := 0; i < len(f.Tags); i += 2 {
+		k, v := int(f.Tags[i]), int(f.Tags[i+1])
+		if k >= len(l.Keys) || v >= len(l.Values) {
+			return nil, fmt.Errorf("tag (%v, %v) of feature %v out of range", k, v, f.GetId())
+		}
+		if value := tagValue(l.Values[v]); value != nil {
+			tags[l.Keys[k]] = value
+		}
+	}
+	return tags, nil
+}
+
+// tagValue returns the value of a tag, or nil for values without any of the types of
+// the spec
+func tagValue(v *vectorTile.Tile_Value) interface{} {
+	switch {
+	case v.StringValue != nil:
+		return v.GetStringValue()
+	case v.FloatValue != nil:
+		return float64(v.GetFloatValue())
+	case v.DoubleValue != nil:
+		return v.GetDoubleValue()
+	case v.IntValue != nil:
+		return v.GetIntValue()
+	case v.UintValue != nil:
+		return v.GetUintValue()
+	case v.SintValue != nil:
+		return v.GetSintValue()
+	case v.BoolValue != nil:
+		return v.GetBoolValue()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,42 @@
+package mvthttp
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrMissingLayerName = errors.New("mvt_http: layer is missing 'name'")
+	ErrMissingLayers    = errors.New("mvt_http: either layers or tilejson_url must be configured")
+)
+
+// ErrInvalidURL is returned when the url template is not an http url with the {z}, {x}
+// and {y} tokens
+type ErrInvalidURL struct {
+	URL string
+}
+
+func (e ErrInvalidURL) Error() string {
+	return fmt.Sprintf("mvt_http: invalid url template (%v), expecting an http url with the {z}, {x} and {y} tokens", e.URL)
+}
+
+// ErrUnknownLayer is returned when features are requested for a layer the provider
+// doesn't have
+type ErrUnknownLayer struct {
+	Name string
+}
+
+func (e ErrUnknownLayer) Error() string {
+	return fmt.Sprintf("mvt_http: unknown layer (%v)", e.Name)
+}
+
+// ErrUpstreamStatus is returned when the upstream server responds with an unexpected
+// status
+type ErrUpstreamStatus struct {
+	URL        string
+	StatusCode int
+}
+
+func (e ErrUpstreamStatus) Error() string {
+	return fmt.Sprintf("mvt_http: upstream server responded to %v with status %v", e.URL, e.StatusCode)
+}
@@ -0,0 +1,209 @@
+package mvthttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	vectorTile "github.com/go-spatial/geom/encoding/mvt/vector_tile"
+	"github.com/golang/protobuf/proto"
+)
+
+// fetch requests an upstream tile, retrying on network errors and the statuses which
+// may be temporary. It returns nil when the upstream server doesn't have the tile.
+func (p *Provider) fetch(ctx context.Context, url string) (*vectorTile.Tile, error) {
+	var (
+		delay = p.retryDelay
+		err   error
+	)
+	for attempt := 0; ; attempt++ {
+		var (
+			b         []byte
+			retryable bool
+		)
+		b, retryable, err = p.get(ctx, url)
+		if err == nil {
+			if b == nil {
+				return nil, nil
+			}
+			return decodeTile(b, url)
+		}
+		if !retryable || attempt >= p.retries {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// get requests a url, and reports if a failed request can be retried
+func (p *Provider) get(ctx context.Context, url string) (_ []byte, retryable bool, _ error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	for k, v := range p.headers {
+		req.Header[k] = v
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// the request may have failed because the context was cancelled
+		return nil, ctx.Err() == nil, fmt.Errorf("requesting %v: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotFound:
+		return nil, false, nil
+	default:
+		err := ErrUpstreamStatus{URL: url, StatusCode: resp.StatusCode}
+		return nil, resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ctx.Err() == nil, fmt.Errorf("reading %v: %w", url, err)
+	}
+	return b, false, nil
+}
+
+// decodeTile decodes the data of a tile, which may be gzipped when the server doesn't
+// set the Content-Encoding of gzipped tiles
+func decodeTile(b []byte, url string) (*vectorTile.Tile, error) {
+	if len(b) > 2 && b[0] == 0x1f && b[1] == 0x8b {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		if b, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("decompressing %v: %w", url, err)
+		}
+	}
+
+	var mvtTile vectorTile.Tile
+	if err := proto.Unmarshal(b, &mvtTile); err != nil {
+		return nil, fmt.Errorf("decoding %v: %w", url, err)
+	}
+	return &mvtTile, nil
+}
+
+// DefaultTileCacheSize and DefaultTileCacheTTL bound the upstream tiles kept in memory.
+// The tiles are kept so the layers of a map, whose features are requested one layer
+// at a time, share a single request.
+const (
+	DefaultTileCacheSize = 256
+	DefaultTileCacheTTL  = 30 * time.Second
+)
+
+type tileKey struct {
+	z, x, y uint
+}
+
+type tileEntry struct {
+	// done is closed once the tile is fetched
+	done    chan struct{}
+	tile    *vectorTile.Tile
+	err     error
+	fetched time.Time
+}
+
+// tileCache keeps the upstream tiles fetched recently, and fetches a tile once for the
+// concurrent requests of it
+type tileCache struct {
+	lock    sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[tileKey]*tileEntry
+	// order are the keys of the entries, from the oldest
+	order []tileKey
+}
+
+func newTileCache(size int, ttl time.Duration) *tileCache {
+	return &tileCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[tileKey]*tileEntry, size),
+	}
+}
+
+// get returns the tile of a key, calling fetch when it's not in the cache. Failed
+// fetches are not kept.
+func (c *tileCache) get(ctx context.Context, key tileKey, fetch func() (*vectorTile.Tile, error)) (*vectorTile.Tile, error) {
+	c.lock.Lock()
+	e, ok := c.entries[key]
+	if ok {
+		select {
+		case <-e.done:
+			if time.Since(e.fetched) > c.ttl {
+				ok = false
+			}
+		default:
+		}
+	}
+	if ok {
+		c.lock.Unlock()
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// the request which was fetching the tile may have been cancelled
+		if errors.Is(e.err, context.Canceled) && ctx.Err() == nil {
+			return c.get(ctx, key, fetch)
+		}
+		return e.tile, e.err
+	}
+
+	e = &tileEntry{done: make(chan struct{})}
+	c.add(key, e)
+	c.lock.Unlock()
+
+	tile, err := fetch()
+
+	c.lock.Lock()
+	e.tile, e.err, e.fetched = tile, err, time.Now()
+	if err != nil && c.entries[key] == e {
+		delete(c.entries, key)
+	}
+	c.lock.Unlock()
+	close(e.done)
+
+	return tile, err
+}
+
+// add adds an entry, evicting the oldest entries when full. The lock must be held.
+func (c *tileCache) add(key tileKey, e *tileEntry) {
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = e
+
+	for len(c.entries) > c.size && len(c.order) > 0 {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.entries, oldest)
+	}
+	// drop the keys of the entries removed after failed fetches
+	if len(c.order) > 2*c.size {
+		order := c.order[:0]
+		for _, k := range c.order {
+			if _, ok := c.entries[k]; ok {
+				order = append(order, k)
+			}
+		}
+		c.order = order
+	}
+}
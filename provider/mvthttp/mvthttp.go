@@ -0,0 +1,168 @@
+// Package mvthttp implements the mvt_http provider, which proxies the vector tiles of an
+// upstream tile server. Despite its name it's a standard provider: the upstream tiles are
+// decoded to features, so their layers can be mixed with the layers of other providers
+// in a map, and are encoded and cached as any other tile.
+package mvthttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-spatial/geom"
+	vectorTile "github.com/go-spatial/geom/encoding/mvt/vector_tile"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/provider"
+)
+
+const Name = "mvt_http"
+
+// config keys
+const (
+	ConfigKeyURL        = "url"
+	ConfigKeyTileJSON   = "tilejson_url"
+	ConfigKeyHeaders    = "headers"
+	ConfigKeyTimeout    = "timeout"
+	ConfigKeyRetries    = "retries"
+	ConfigKeyLayers     = "layers"
+	ConfigKeyLayerName  = "name"
+	ConfigKeyGeomType   = "geometry_type"
+	ConfigKeyRetryDelay = "retry_delay"
+)
+
+const (
+	DefaultTimeout    = "10s"
+	DefaultRetries    = 2
+	DefaultRetryDelay = "200ms"
+)
+
+// the tokens of the url template
+const (
+	tokenZ = "{z}"
+	tokenX = "{x}"
+	tokenY = "{y}"
+)
+
+type Layer struct {
+	name     string
+	geomType geom.Geometry
+}
+
+func (l Layer) Name() string            { return l.name }
+func (l Layer) GeomType() geom.Geometry { return l.geomType }
+func (l Layer) SRID() uint64            { return tegola.WebMercator }
+
+type Provider struct {
+	// URL is the template of the upstream tile urls, with the {z}, {x} and {y} tokens
+	URL     string
+	headers http.Header
+	client  *http.Client
+	// retries is the number of times a failed request is retried, waiting retryDelay
+	// and then twice as long as the previous time
+	retries    int
+	retryDelay time.Duration
+	layers     map[string]Layer
+	// tiles are the upstream tiles fetched recently, which are shared by the layers of a map
+	tiles *tileCache
+}
+
+func (p *Provider) Layers() ([]provider.LayerInfo, error) {
+	ls := make([]provider.LayerInfo, 0, len(p.layers))
+	for _, l := range p.layers {
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
+
+// tileURL returns the upstream url of the z/x/y tile
+func (p *Provider) tileURL(z, x, y uint) string {
+	return strings.NewReplacer(
+		tokenZ, strconv.FormatUint(uint64(z), 10),
+		tokenX, strconv.FormatUint(uint64(x), 10),
+		tokenY, strconv.FormatUint(uint64(y), 10),
+	).Replace(p.URL)
+}
+
+// TileFeatures decodes the features of the layer of the upstream tile. Upstream tiles
+// which are not found have no features.
+func (p *Provider) TileFeatures(ctx context.Context, layer string, tile provider.Tile, _ provider.Params, fn func(f *provider.Feature) error) error {
+	if _, ok := p.layers[layer]; !ok {
+		return ErrUnknownLayer{Name: layer}
+	}
+
+	z, x, y := tile.ZXY()
+	mvtTile, err := p.tiles.get(ctx, tileKey{z: uint(z), x: x, y: y}, func() (*vectorTile.Tile, error) {
+		return p.fetch(ctx, p.tileURL(uint(z), x, y))
+	})
+	if err != nil {
+		return err
+	}
+	if mvtTile == nil {
+		return nil
+	}
+
+	extent, _ := tile.Extent()
+	for _, l := range mvtTile.Layers {
+		if l.GetName() != layer {
+			continue
+		}
+		if err := layerFeatures(ctx, l, extent, fn); err != nil {
+			return fmt.Errorf("layer (%v) of upstream tile (%v/%v/%v): %w", layer, z, x, y, err)
+		}
+	}
+	return nil
+}
+
+// layerFeatures calls fn for the features of a layer of a tile whose extent is in web
+// mercator. Features of unknown or invalid geometries are skipped.
+func layerFeatures(ctx context.Context, l *vectorTile.Tile_Layer, extent *geom.Extent, fn func(f *provider.Feature) error) error {
+	var (
+		size   = float64(l.GetExtent())
+		scaleX = (extent.MaxX() - extent.MinX()) / size
+		scaleY = (extent.MaxY() - extent.MinY()) / size
+		// tile coordinates grow down from the top left corner of the tile
+		toWebMercator = func(coords ...float64) ([]float64, error) {
+			return []float64{extent.MinX() + coords[0]*scaleX, extent.MaxY() - coords[1]*scaleY}, nil
+		}
+		reported bool
+	)
+
+	for i, f := range l.Features {
+		// check if the context cancelled or timed out
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		geometry, err := decodeGeometry(f)
+		if err != nil || geometry == nil {
+			// only report to the log once per layer
+			if err != nil && !reported {
+				reported = true
+				log.Warnf("skipping invalid geometry of feature %v of upstream layer (%v): %v", i, l.GetName(), err)
+			}
+			continue
+		}
+		if geometry, err = geom.ApplyToPoints(geometry, toWebMercator); err != nil {
+			return err
+		}
+
+		tags, err := featureTags(l, f)
+		if err != nil {
+			return err
+		}
+
+		if err = fn(&provider.Feature{
+			ID:       f.GetId(),
+			Geometry: geometry,
+			SRID:     tegola.WebMercator,
+			Tags:     tags,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+package mvthttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	vectorTile "github.com/go-spatial/geom/encoding/mvt/vector_tile"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/provider"
+	"github.com/golang/protobuf/proto"
+)
+
+// testTile returns the encoded tile served by the test server, with a point at the
+// center of the tile and a line along its top edge
+func testTile(t *testing.T, gzipped bool) []byte {
+	t.Helper()
+
+	var (
+		version = uint32(2)
+		extent  = uint32(4096)
+		id      = uint64(7)
+		name    = "roads"
+		class   = "primary"
+		lanes   = int64(2)
+		point   = vectorTile.Tile_POINT
+		line    = vectorTile.Tile_LINESTRING
+		pois    = "pois"
+	)
+	mvtTile := vectorTile.Tile{Layers: []*vectorTile.Tile_Layer{
+		{
+			Version: &version,
+			Name:    &name,
+			Keys:    []string{"class", "lanes"},
+			Values:  []*vectorTile.Tile_Value{{StringValue: &class}, {IntValue: &lanes}},
+			Extent:  &extent,
+			Features: []*vectorTile.Tile_Feature{{
+				Id:   &id,
+				Tags: []uint32{0, 0, 1, 1},
+				Type: &line,
+				// MoveTo(0, 0) LineTo(4096, 0)
+				Geometry: []uint32{9, 0, 0, 10, 8192, 0},
+			}},
+		},
+		{
+			Version: &version,
+			Name:    &pois,
+			Extent:  &extent,
+			Features: []*vectorTile.Tile_Feature{
+				// MoveTo(2048, 2048)
+				{Id: &id, Type: &point, Geometry: []uint32{9, 4096, 4096}},
+				// a truncated line is skipped
+				{Type: &line, Geometry: []uint32{9, 4096}},
+			},
+		},
+	}}
+
+	b, err := proto.Marshal(&mvtTile)
+	if err != nil {
+		t.Fatalf("marshal, expected nil got %v", err)
+	}
+	if !gzipped {
+		return b
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err = w.Write(b); err != nil {
+		t.Fatalf("gzip, expected nil got %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("gzip, expected nil got %v", err)
+	}
+	return buf.Bytes()
+}
+
+// upstream is a test tile server, which serves the test tile as 1/0/0 and fails the
+// first failures requests
+type upstream struct {
+	*httptest.Server
+	requests int32
+	failures int32
+}
+
+func newUpstream(t *testing.T, failures int32, gzipped bool) *upstream {
+	u := upstream{failures: failures}
+	tile := testTile(t, gzipped)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tiles/", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&u.requests, 1)
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if n <= u.failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/tiles/1/0/0.pbf" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(tile)
+	})
+	mux.HandleFunc("/tiles.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tilejson": "3.0.0", "vector_layers": [{"id": "roads"}, {"id": "pois"}]}`))
+	})
+	u.Server = httptest.NewServer(mux)
+	t.Cleanup(u.Close)
+
+	return &u
+}
+
+func (u *upstream) config() dict.Dict {
+	return dict.Dict{
+		ConfigKeyURL:        u.URL + "/tiles/{z}/{x}/{y}.pbf",
+		ConfigKeyHeaders:    dict.Dict{"Authorization": "Bearer token"},
+		ConfigKeyRetryDelay: "1ms",
+		ConfigKeyLayers: []map[string]interface{}{
+			{"name": "roads", "geometry_type": "linestring"},
+			{"name": "pois"},
+		},
+	}
+}
+
+func TestTileFeatures(t *testing.T) {
+	type tcase struct {
+		failures int32
+		gzipped  bool
+		layer    string
+		tile     provider.Tile
+		expected []provider.Feature
+		// requests is the expected number of upstream requests
+		requests int32
+		// status is the status of the upstream error, if any
+		status int
+	}
+
+	extent, _ := provider.NewTile(1, 0, 0, 0, tegola.WebMercator).Extent()
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			u := newUpstream(t, tc.failures, tc.gzipped)
+			p, err := NewTileProvider(u.config(), nil)
+			if err != nil {
+				t.Fatalf("new provider, expected nil got %v", err)
+			}
+
+			var got []provider.Feature
+			err = p.TileFeatures(context.Background(), tc.layer, tc.tile, nil, func(f *provider.Feature) error {
+				got = append(got, *f)
+				return nil
+			})
+			if tc.status != 0 {
+				var statusErr ErrUpstreamStatus
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != tc.status {
+					t.Errorf("expected status %v got %v", tc.status, err)
+				}
+				if u.requests != tc.requests {
+					t.Errorf("requests, expected %v got %v", tc.requests, u.requests)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, got)
+			}
+			if u.requests != tc.requests {
+				t.Errorf("requests, expected %v got %v", tc.requests, u.requests)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"line": {
+			layer: "roads",
+			tile:  provider.NewTile(1, 0, 0, 64, tegola.WebMercator),
+			expected: []provider.Feature{{
+				ID:       7,
+				Geometry: geom.LineString{{extent.MinX(), extent.MaxY()}, {extent.MaxX(), extent.MaxY()}},
+				SRID:     tegola.WebMercator,
+				Tags:     map[string]interface{}{"class": "primary", "lanes": int64(2)},
+			}},
+			requests: 1,
+		},
+		"point gzipped": {
+			gzipped: true,
+			layer:   "pois",
+			tile:    provider.NewTile(1, 0, 0, 64, tegola.WebMercator),
+			expected: []provider.Feature{{
+				ID:       7,
+				Geometry: geom.Point{(extent.MinX() + extent.MaxX()) / 2, (extent.MinY() + extent.MaxY()) / 2},
+				SRID:     tegola.WebMercator,
+				Tags:     map[string]interface{}{},
+			}},
+			requests: 1,
+		},
+		"retried": {
+			failures: 2,
+			layer:    "pois",
+			tile:     provider.NewTile(1, 0, 0, 64, tegola.WebMercator),
+			expected: []provider.Feature{{
+				ID:       7,
+				Geometry: geom.Point{(extent.MinX() + extent.MaxX()) / 2, (extent.MinY() + extent.MaxY()) / 2},
+				SRID:     tegola.WebMercator,
+				Tags:     map[string]interface{}{},
+			}},
+			requests: 3,
+		},
+		"not found": {
+			layer:    "roads",
+			tile:     provider.NewTile(1, 1, 1, 64, tegola.WebMercator),
+			requests: 1,
+		},
+		"failed": {
+			failures: 3,
+			layer:    "roads",
+			tile:     provider.NewTile(1, 0, 0, 64, tegola.WebMercator),
+			// the request and 2 retries
+			requests: 3,
+			status:   http.StatusServiceUnavailable,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestTileFeaturesSharedRequest(t *testing.T) {
+	u := newUpstream(t, 0, false)
+	p, err := NewTileProvider(u.config(), nil)
+	if err != nil {
+		t.Fatalf("new provider, expected nil got %v", err)
+	}
+
+	// the layers of a map are requested concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, layer := range []string{"roads", "pois"} {
+			wg.Add(1)
+			go func(layer string) {
+				defer wg.Done()
+				err := p.TileFeatures(context.Background(), layer, provider.NewTile(1, 0, 0, 64, tegola.WebMercator), nil, func(f *provider.Feature) error { return nil })
+				if err != nil {
+					t.Errorf("layer %v, expected nil got %v", layer, err)
+				}
+			}(layer)
+		}
+	}
+	wg.Wait()
+
+	if u.requests != 1 {
+		t.Errorf("requests, expected 1 got %v", u.requests)
+	}
+}
+
+func TestNewTileProvider(t *testing.T) {
+	type tcase struct {
+		config   func(u *upstream) dict.Dict
+		expected []string
+		err      string
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			u := newUpstream(t, 0, false)
+			p, err := NewTileProvider(tc.config(u), nil)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Errorf("expected %v got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+
+			layers, err := p.Layers()
+			if err != nil {
+				t.Fatalf("layers, expected nil got %v", err)
+			}
+			names := map[string]bool{}
+			for _, l := range layers {
+				names[l.Name()] = true
+			}
+			for _, name := range tc.expected {
+				if !names[name] {
+					t.Errorf("expected layer %v got %v", name, names)
+				}
+			}
+			if len(layers) != len(tc.expected) {
+				t.Errorf("layers, expected %v got %v", len(tc.expected), len(layers))
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"configured layers": {
+			config:   (*upstream).config,
+			expected: []string{"roads", "pois"},
+		},
+		"tilejson": {
+			config: func(u *upstream) dict.Dict {
+				return dict.Dict{
+					ConfigKeyURL:      u.URL + "/tiles/{z}/{x}/{y}.pbf",
+					ConfigKeyTileJSON: u.URL + "/tiles.json",
+				}
+			},
+			expected: []string{"roads", "pois"},
+		},
+		"no layers": {
+			config: func(u *upstream) dict.Dict {
+				return dict.Dict{ConfigKeyURL: u.URL + "/tiles/{z}/{x}/{y}.pbf"}
+			},
+			err: ErrMissingLayers.Error(),
+		},
+		"missing token": {
+			config: func(u *upstream) dict.Dict {
+				return dict.Dict{ConfigKeyURL: "http://tiles.example.com/{z}/{x}.pbf"}
+			},
+			err: ErrInvalidURL{URL: "http://tiles.example.com/{z}/{x}.pbf"}.Error(),
+		},
+		"invalid timeout": {
+			config: func(u *upstream) dict.Dict {
+				c := u.config()
+				c[ConfigKeyTimeout] = "10"
+				return c
+			},
+			err: `invalid timeout (10): time: missing unit in duration "10"`,
+		},
+		"invalid geometry type": {
+			config: func(u *upstream) dict.Dict {
+				c := u.config()
+				c[ConfigKeyLayers] = []map[string]interface{}{{"name": "roads", "geometry_type": "circle"}}
+				return c
+			},
+			err: "for layer (0) roads : unsupported geometry_type (circle), expecting point, linestring or polygon",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
@@ -0,0 +1,185 @@
+package mvthttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/env"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/provider"
+)
+
+func init() {
+	provider.Register(provider.TypeStd.Prefix()+Name, NewTileProvider, nil)
+}
+
+// NewTileProvider instantiates a provider of the layers of the tiles of an upstream
+// tile server. The layers are configured, or read from the vector_layers of the
+// TileJSON of the upstream tileset.
+func NewTileProvider(config dict.Dicter, _ []provider.Map) (provider.Tiler, error) {
+	tileURL, err := config.String(ConfigKeyURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(tileURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+		!strings.Contains(tileURL, tokenZ) || !strings.Contains(tileURL, tokenX) || !strings.Contains(tileURL, tokenY) {
+		return nil, ErrInvalidURL{URL: tileURL}
+	}
+
+	p := Provider{
+		URL:    tileURL,
+		layers: map[string]Layer{},
+		tiles:  newTileCache(DefaultTileCacheSize, DefaultTileCacheTTL),
+	}
+
+	if v, ok := config.Interface(ConfigKeyHeaders); ok {
+		if p.headers, err = headersOf(v); err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := DefaultTimeout
+	if timeout, err = config.String(ConfigKeyTimeout, &timeout); err != nil {
+		return nil, err
+	}
+	p.client = &http.Client{}
+	if p.client.Timeout, err = time.ParseDuration(timeout); err != nil {
+		return nil, fmt.Errorf("invalid %v (%v): %w", ConfigKeyTimeout, timeout, err)
+	}
+
+	p.retries = DefaultRetries
+	if p.retries, err = config.Int(ConfigKeyRetries, &p.retries); err != nil {
+		return nil, err
+	}
+	if p.retries < 0 {
+		return nil, fmt.Errorf("invalid %v (%v), expecting 0 or more", ConfigKeyRetries, p.retries)
+	}
+
+	retryDelay := DefaultRetryDelay
+	if retryDelay, err = config.String(ConfigKeyRetryDelay, &retryDelay); err != nil {
+		return nil, err
+	}
+	if p.retryDelay, err = time.ParseDuration(retryDelay); err != nil {
+		return nil, fmt.Errorf("invalid %v (%v): %w", ConfigKeyRetryDelay, retryDelay, err)
+	}
+
+	layers, err := config.MapSlice(ConfigKeyLayers)
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) == 0 {
+		var tileJSONURL string
+		if tileJSONURL, err = config.String(ConfigKeyTileJSON, &tileJSONURL); err != nil {
+			return nil, err
+		}
+		if tileJSONURL == "" {
+			return nil, ErrMissingLayers
+		}
+		if err = p.tileJSONLayers(tileJSONURL); err != nil {
+			return nil, err
+		}
+		log.Infof("read %v layers from the TileJSON of %v", len(p.layers), tileJSONURL)
+		return &p, nil
+	}
+
+	for i, layerConf := range layers {
+		layerName, err := layerConf.String(ConfigKeyLayerName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("for layer (%v) we got the following error trying to get the layer's name field: %v", i, err)
+		}
+		if layerName == "" {
+			return nil, ErrMissingLayerName
+		}
+		if _, ok := p.layers[layerName]; ok {
+			return nil, fmt.Errorf("layer name (%v) is duplicated", layerName)
+		}
+
+		var geomType string
+		if geomType, err = layerConf.String(ConfigKeyGeomType, &geomType); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+		l := Layer{name: layerName}
+		if l.geomType, err = geomTypeOf(geomType); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+		p.layers[layerName] = l
+	}
+
+	return &p, nil
+}
+
+// headersOf returns the headers of the headers config, a table of strings
+func headersOf(v interface{}) (http.Header, error) {
+	var m map[string]interface{}
+	switch v := v.(type) {
+	case env.Dict:
+		m = v
+	case dict.Dict:
+		m = v
+	case map[string]interface{}:
+		m = v
+	default:
+		return nil, fmt.Errorf("invalid %v, expecting a table of header names and values got %T", ConfigKeyHeaders, v)
+	}
+
+	headers := make(http.Header, len(m))
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid %v value of %v, expecting a string got %T", ConfigKeyHeaders, k, v)
+		}
+		headers.Set(k, s)
+	}
+	return headers, nil
+}
+
+// geomTypeOf returns the geometry of a geometry_type config, or nil when not set
+func geomTypeOf(geomType string) (geom.Geometry, error) {
+	switch strings.ToLower(geomType) {
+	case "":
+		return nil, nil
+	case "point":
+		return geom.Point{}, nil
+	case "linestring":
+		return geom.LineString{}, nil
+	case "polygon":
+		return geom.Polygon{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported %v (%v), expecting point, linestring or polygon", ConfigKeyGeomType, geomType)
+	}
+}
+
+// tileJSONLayers reads the layers of the vector_layers of a TileJSON
+func (p *Provider) tileJSONLayers(tileJSONURL string) error {
+	b, _, err := p.get(context.Background(), tileJSONURL)
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		return ErrUpstreamStatus{URL: tileJSONURL, StatusCode: http.StatusNotFound}
+	}
+
+	var tileJSON struct {
+		VectorLayers []struct {
+			ID string `json:"id"`
+		} `json:"vector_layers"`
+	}
+	if err = json.Unmarshal(b, &tileJSON); err != nil {
+		return fmt.Errorf("decoding the TileJSON of %v: %w", tileJSONURL, err)
+	}
+	if len(tileJSON.VectorLayers) == 0 {
+		return fmt.Errorf("the TileJSON of %v has no vector_layers", tileJSONURL)
+	}
+
+	for _, vl := range tileJSON.VectorLayers {
+		p.layers[vl.ID] = Layer{name: vl.ID}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNilLayer = errors.New("layer is nil")
+)
+
+type ErrLayerNotFound struct {
+	LayerName string
+}
+
+func (e ErrLayerNotFound) Error() string {
+	return fmt.Sprintf("mysql: layer (%v) not found ", e.LayerName)
+}
+
+type ErrGeomFieldNotFound struct {
+	GeomFieldName string
+	LayerName     string
+}
+
+func (e ErrGeomFieldNotFound) Error() string {
+	return fmt.Sprintf("mysql: geom fieldname (%v) not found for layer (%v)", e.GeomFieldName, e.LayerName)
+}
+
+type ErrInvalidURI struct {
+	Err error
+	Msg string
+}
+
+func (e ErrInvalidURI) Error() string {
+	if e.Msg == "" {
+		if e.Err != nil {
+			return fmt.Sprintf("mysql: %v", e.Err.Error())
+		} else {
+			return "mysql: invalid uri"
+		}
+	}
+
+	return fmt.Sprintf("mysql: invalid uri (%v)", e.Msg)
+}
+
+func (e ErrInvalidURI) Unwrap() error {
+	return e.Err
+}
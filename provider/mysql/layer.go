@@ -0,0 +1,42 @@
+package mysql
+
+import "github.com/go-spatial/geom"
+
+// layer holds information about a query.
+type Layer struct {
+	// The Name of the layer
+	name string
+	// The SQL to use when querying MySQL for this layer
+	sql string
+	// The ID field name, this will default to 'gid' if not set to something other then empty string.
+	idField string
+	// The Geometery field name, this will default to 'geom' if not set to something other then empty string.
+	geomField string
+	// GeomType is the the type of geometry returned from the SQL
+	geomType geom.Geometry
+	// The SRID that the data in the table is stored in. This will default to WebMercator
+	srid uint64
+	// latLong is true when the server returns the coordinates of the layer in latitude,
+	// longitude order, which is the axis order of geographic SRIDs in MySQL 8
+	latLong bool
+}
+
+func (l Layer) Name() string {
+	return l.name
+}
+
+func (l Layer) GeomType() geom.Geometry {
+	return l.geomType
+}
+
+func (l Layer) SRID() uint64 {
+	return l.srid
+}
+
+func (l Layer) GeomFieldName() string {
+	return l.geomField
+}
+
+func (l Layer) IDFieldName() string {
+	return l.idField
+}
@@ -0,0 +1,33 @@
+package mysql
+
+import (
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/provider"
+)
+
+func init() {
+	provider.Register(provider.TypeStd.Prefix()+Name, NewTileProvider, Cleanup)
+}
+
+// NewTileProvider instantiates and returns a new mysql provider or an error.
+// The function will validate that the config object looks good before
+// trying to create a driver. This Provider supports the following fields
+// in the provided map[string]interface{} map:
+//
+//	uri (string): [Required] MySQL connection string
+//	srid (int): [Optional] The default SRID for the provider. Defaults to WebMercator (3857) but also supports WGS84 (4326)
+//	layers (map[string]struct{})  — This is map of layers keyed by the layer name. supports the following properties
+//
+//		name (string): [Required] the name of the layer. This is used to reference this layer from map layers.
+//		tablename (string): [*Required] the name of the database table to query against. Required if sql is not defined.
+//		geometry_fieldname (string): [Optional] the name of the filed which contains the geometry for the feature. defaults to geom
+//		id_fieldname (string): [Optional] the name of the feature id field. defaults to gid
+//		fields ([]string): [Optional] a list of fields to include alongside the feature. Can be used if sql is not defined.
+//		srid (int): [Optional] the SRID of the layer. Supports 3857 (WebMercator) or 4326 (WGS84).
+//		sql (string): [*Required] custom SQL to use use. Required if tablename is not defined. Supports the following tokens:
+//
+//			!BBOX! - [Required] will be replaced with the bounding box of the tile before the query is sent to the database.
+//			!ZOOM! - [Optional] will be replaced with the "Z" (zoom) value of the requested tile.
+func NewTileProvider(config dict.Dicter, maps []provider.Map) (provider.Tiler, error) {
+	return CreateProvider(config, maps)
+}
@@ -0,0 +1,418 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/basic"
+	"github.com/go-spatial/tegola/config"
+	"github.com/go-spatial/tegola/internal/env"
+	"github.com/go-spatial/tegola/provider"
+)
+
+// genSQL will fill in the SQL field of a layer given a pool, and list of fields.
+func genSQL(
+	l *Layer,
+	pool *connectionPoolCollector,
+	tblname string,
+	flds []string,
+	axisOrder bool,
+) (sql string, err error) {
+	// we need to hit the database to see what the fields are.
+	if len(flds) == 0 {
+		sql := fmt.Sprintf(fldsSQL, tblname)
+
+		//	if a subquery is set in the 'sql' config the subquery is set to the layer's
+		//	'tablename' param. because of this case normal SQL token replacement needs to be
+		//	applied to tablename SQL generation
+		tile := provider.NewTile(0, 0, 0, 64, tegola.WebMercator)
+		sql, err = replaceTokens(sql, l, tile, true, axisOrder)
+		if err != nil {
+			return "", err
+		}
+
+		rows, err := pool.Query(sql)
+		if err != nil {
+			return "", err
+		}
+		defer rows.Close()
+
+		if flds, err = rows.Columns(); err != nil {
+			return "", err
+		}
+		if len(flds) == 0 {
+			return "", fmt.Errorf("no fields were returned for table %v", tblname)
+		}
+	}
+
+	fgeom := -1
+
+	// to avoid field names possibly colliding with MySQL keywords,
+	// we wrap the field names in backticks
+	for i, f := range flds {
+		if f == l.geomField {
+			fgeom = i
+		}
+		flds[i] = fmt.Sprintf("`%v`", flds[i])
+	}
+
+	if fgeom == -1 {
+		flds = append(flds, fmt.Sprintf("ST_AsWKB(`%v`) AS `%[1]v`", l.geomField))
+	} else {
+		flds[fgeom] = fmt.Sprintf("ST_AsWKB(`%v`) AS `%[1]v`", l.geomField)
+	}
+
+	// add required id field
+	if l.idField != "" {
+		flds = append(flds, fmt.Sprintf("`%v`", l.idField))
+	}
+
+	selectClause := strings.Join(flds, ", ")
+
+	return fmt.Sprintf(stdSQL, selectClause, tblname, l.geomField), nil
+}
+
+// replaceTokens replaces tokens in the provided SQL string
+//
+// !BBOX! - the bounding box of the tile
+// !ZOOM! - the tile Z value
+// !X! - the tile X value
+// !Y! - the tile Y value
+// !Z! - the tile Z value
+// !SCALE_DENOMINATOR! - scale denominator, assuming 90.7 DPI (i.e. 0.28mm pixel size)
+// !PIXEL_WIDTH! - the pixel width in meters, assuming 256x256 tiles
+// !PIXEL_HEIGHT! - the pixel height in meters, assuming 256x256 tiles
+// !GEOM_FIELD! - the geom field name
+// !GEOM_TYPE! - the geom field type if defined otherwise ""
+//
+// The bounding box is a polygon in the SRID of the layer. When axisOrder is set, the
+// coordinates are marked as longitude, latitude for the geographic SRIDs of MySQL 8.
+func replaceTokens(sql string, lyr *Layer, tile provider.Tile, withBuffer bool, axisOrder bool) (string, error) {
+	var (
+		extent  *geom.Extent
+		geoType string
+	)
+
+	if lyr == nil {
+		return "", ErrNilLayer
+	}
+	srid := lyr.SRID()
+
+	if withBuffer {
+		extent, _ = tile.BufferedExtent()
+	} else {
+		extent, _ = tile.Extent()
+	}
+
+	minGeo, err := basic.FromWebMercator(srid, geom.Point{extent.MinX(), extent.MinY()})
+	if err != nil {
+		return "", fmt.Errorf("Error trying to convert tile point: %w ", err)
+	}
+
+	maxGeo, err := basic.FromWebMercator(srid, geom.Point{extent.MaxX(), extent.MaxY()})
+	if err != nil {
+		return "", fmt.Errorf("Error trying to convert tile point: %w ", err)
+	}
+
+	minPt, maxPt := minGeo.(geom.Point), maxGeo.(geom.Point)
+	if srid == tegola.WGS84 {
+		// MySQL rejects geographic coordinates out of range, which the buffer of the
+		// tiles at the edges of the world exceeds
+		minPt = geom.Point{math.Max(minPt.X(), -180), math.Max(minPt.Y(), -90)}
+		maxPt = geom.Point{math.Min(maxPt.X(), 180), math.Min(maxPt.Y(), 90)}
+	}
+
+	polygon := fmt.Sprintf(
+		"POLYGON((%.8[1]f %.8[2]f,%.8[3]f %.8[2]f,%.8[3]f %.8[4]f,%.8[1]f %.8[4]f,%.8[1]f %.8[2]f))",
+		minPt.X(),
+		minPt.Y(),
+		maxPt.X(),
+		maxPt.Y(),
+	)
+
+	bbox := fmt.Sprintf("ST_GeomFromText('%v',%d)", polygon, srid)
+	if axisOrder {
+		bbox = fmt.Sprintf("ST_GeomFromText('%v',%d,'axis-order=long-lat')", polygon, srid)
+	}
+
+	extent, _ = tile.Extent()
+	// TODO: Always convert to meter if we support different projections
+	pixelWidth := (extent.MaxX() - extent.MinX()) / 256
+	pixelHeight := (extent.MaxY() - extent.MinY()) / 256
+	scaleDenominator := pixelWidth / 0.00028 /* px size in m */
+
+	if lyr.GeomType() != nil {
+		geoType = fmt.Sprintf("%v", lyr.GeomType())
+	}
+
+	// replace query string tokens
+	z, x, y := tile.ZXY()
+	tokenReplacer := strings.NewReplacer(
+		config.BboxToken, bbox,
+		config.ZoomToken, strconv.FormatUint(uint64(z), 10),
+		config.ZToken, strconv.FormatUint(uint64(z), 10),
+		config.XToken, strconv.FormatUint(uint64(x), 10),
+		config.YToken, strconv.FormatUint(uint64(y), 10),
+		config.ScaleDenominatorToken, strconv.FormatFloat(scaleDenominator, 'f', 8, 64),
+		config.PixelWidthToken, strconv.FormatFloat(pixelWidth, 'f', 8, 64),
+		config.PixelHeightToken, strconv.FormatFloat(pixelHeight, 'f', 8, 64),
+		config.IdFieldToken, lyr.IDFieldName(),
+		config.GeomFieldToken, lyr.GeomFieldName(),
+		config.GeomTypeToken, geoType,
+	)
+
+	uppercaseTokenSQL := uppercaseTokens(sql)
+
+	return tokenReplacer.Replace(uppercaseTokenSQL), nil
+}
+
+// replaceParams substitutes the query parameter tokens of the SQL with their SQL and
+// returns the arguments of the query. MySQL placeholders are not numbered, so an
+// argument is added for each placeholder in the order they appear.
+func replaceParams(params provider.Params, sql string) (string, []any) {
+	if params == nil {
+		return sql, nil
+	}
+
+	var (
+		args []any
+		sb   strings.Builder
+		last int
+	)
+	for _, loc := range provider.ParameterTokenRegexp.FindAllStringIndex(sql, -1) {
+		param, ok := params[sql[loc[0]:loc[1]]]
+		if !ok {
+			// Unknown token, ignoring
+			continue
+		}
+
+		sb.WriteString(sql[last:loc[0]])
+		for _, c := range param.SQL {
+			if c == '?' {
+				args = append(args, param.Value)
+			}
+			sb.WriteRune(c)
+		}
+		last = loc[1]
+	}
+	sb.WriteString(sql[last:])
+
+	return sb.String(), args
+}
+
+// extractQueryParamValues finds default values for SQL tokens and constructs query parameter values out of them
+func extractQueryParamValues(pname string, maps []provider.Map, layer *Layer) provider.Params {
+	result := make(provider.Params, 0)
+
+	expectedMapName := fmt.Sprintf("%s.%s", pname, layer.name)
+	for _, m := range maps {
+		for _, l := range m.Layers {
+			if l.ProviderLayer == env.String(expectedMapName) {
+				for _, p := range m.Parameters {
+					pv, err := p.ToDefaultValue()
+					if err == nil {
+						result[p.Token] = pv
+					}
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// uppercaseTokens converts all !tokens! to uppercase !TOKENS!. Tokens can
+// contain alphanumerics, dash and underline chars.
+func uppercaseTokens(str string) string {
+	return provider.ParameterTokenRegexp.ReplaceAllStringFunc(str, strings.ToUpper)
+}
+
+// transformVal converts a value of a column to a tag value. The driver returns numbers
+// as int64 or float64 and the other types as bytes, which are converted by the
+// database type of the column.
+func transformVal(dbType string, val any) (any, error) {
+	switch vt := val.(type) {
+	case int64, uint64, float64, bool, string:
+		return vt, nil
+	case float32:
+		return float64(vt), nil
+	case time.Time:
+		return vt.Format(time.RFC3339), nil
+	case []byte:
+		switch strings.TrimPrefix(dbType, "UNSIGNED ") {
+		case "DECIMAL", "FLOAT", "DOUBLE":
+			return strconv.ParseFloat(string(vt), 64)
+		case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+			if strings.HasPrefix(dbType, "UNSIGNED ") {
+				return strconv.ParseUint(string(vt), 10, 64)
+			}
+			return strconv.ParseInt(string(vt), 10, 64)
+		case "BIT":
+			var u uint64
+			for _, b := range vt {
+				u = u<<8 | uint64(b)
+			}
+			return u, nil
+		case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "GEOMETRY", "VECTOR":
+			return nil, fmt.Errorf("%v type is not supported", dbType)
+		default:
+			return string(vt), nil
+		}
+	default:
+		return nil, fmt.Errorf("%v type is not supported. (%T)", dbType, val)
+	}
+}
+
+// decipherFields is responsible for processing the SQL result set, decoding geometries, ids and feature tags.
+// The values are pointers to the scanned values.
+func decipherFields(
+	ctx context.Context,
+	geomFieldname, idFieldname string,
+	columns []*sql.ColumnType,
+	values []any,
+) (gid uint64, geom []byte, tags map[string]any, err error) {
+	var ok bool
+
+	tags = make(map[string]any)
+
+	var idParsed bool
+	for i := range values {
+
+		// do a quick check
+		if err := ctx.Err(); err != nil {
+			return 0, nil, nil, err
+		}
+
+		value := *(values[i].(*any))
+		// skip nil values.
+		if value == nil {
+			continue
+		}
+
+		name := columns[i].Name()
+
+		switch name {
+		case geomFieldname:
+			if geom, ok = value.([]byte); !ok {
+				return 0, nil, nil, fmt.Errorf(
+					"unable to convert geometry field (%v) into bytes",
+					geomFieldname,
+				)
+			}
+		case idFieldname:
+			// the id has to be parsed once but it can also be a tag
+			if !idParsed {
+				gid, err = gId(value)
+				if err != nil {
+					return 0, nil, nil, err
+				}
+				idParsed = true
+				break
+			}
+
+			// adds id as a tag
+			fallthrough
+		default:
+			v, err := transformVal(columns[i].DatabaseTypeName(), value)
+			if err != nil {
+				return gid, geom, tags, fmt.Errorf(
+					"unable to convert field [%v] (%v) of type (%v) to a suitable value: %w",
+					i,
+					name,
+					columns[i].DatabaseTypeName(),
+					err,
+				)
+			}
+			tags[name] = v
+		}
+	}
+
+	return gid, geom, tags, nil
+}
+
+func gId(v any) (gid uint64, err error) {
+	switch aval := v.(type) {
+	case float64:
+		return uint64(aval), nil
+	case int64:
+		return uint64(aval), nil
+	case uint64:
+		return aval, nil
+	case []byte:
+		// decimal ids
+		f, err := strconv.ParseFloat(string(aval), 64)
+		if err != nil {
+			return gid, fmt.Errorf("unable to convert field into a uint64: %w", err)
+		}
+		return uint64(f), nil
+	case string:
+		return strconv.ParseUint(aval, 10, 64)
+	default:
+		return gid, fmt.Errorf("unable to convert field into a uint64")
+	}
+}
+
+// swapAxes swaps the x and y coordinates of a geometry
+func swapAxes(g geom.Geometry) geom.Geometry {
+	swap := func(pts [][2]float64) [][2]float64 {
+		swapped := make([][2]float64, len(pts))
+		for i, pt := range pts {
+			swapped[i] = [2]float64{pt[1], pt[0]}
+		}
+		return swapped
+	}
+
+	switch g := g.(type) {
+	case geom.Point:
+		return geom.Point{g[1], g[0]}
+	case geom.MultiPoint:
+		return geom.MultiPoint(swap(g))
+	case geom.LineString:
+		return geom.LineString(swap(g))
+	case geom.MultiLineString:
+		lines := make(geom.MultiLineString, len(g))
+		for i := range g {
+			lines[i] = swap(g[i])
+		}
+		return lines
+	case geom.Polygon:
+		rings := make(geom.Polygon, len(g))
+		for i := range g {
+			rings[i] = swap(g[i])
+		}
+		return rings
+	case geom.MultiPolygon:
+		polygons := make(geom.MultiPolygon, len(g))
+		for i := range g {
+			polygons[i] = swapAxes(geom.Polygon(g[i])).(geom.Polygon)
+		}
+		return polygons
+	case geom.Collection:
+		geoms := make(geom.Collection, len(g))
+		for i := range g {
+			geoms[i] = swapAxes(g[i])
+		}
+		return geoms
+	default:
+		return g
+	}
+}
+
+// ctxErr will check if the supplied context has an error (i.e. context canceled)
+// and if so, return that error, else return the supplied error. This is useful
+// as not all of Go's stdlib has adopted error wrapping so context.Canceled
+// errors are not always easy to capture.
+func ctxErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
@@ -23,6 +23,12 @@ func (e ErrInvalidSSLMode) Error() string {
 	return fmt.Sprintf("postgis: invalid ssl mode (%v)", string(e))
 }
 
+type ErrInvalidReplicaBalancing string
+
+func (e ErrInvalidReplicaBalancing) Error() string {
+	return fmt.Sprintf("postgis: invalid replica balancing (%v), expecting %v or %v", string(e), BalancingRoundRobin, BalancingLeastConnections)
+}
+
 type ErrUnclosedToken string
 
 func (e ErrUnclosedToken) Error() string {
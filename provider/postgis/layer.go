@@ -8,6 +8,8 @@ type Layer struct {
 	name string
 	// The SQL to use when querying PostGIS for this layer
 	sql string
+	// The variants of the SQL by zoom, which are used instead of sql when set
+	variants []variant
 	// The ID field name, this will default to 'gid' if not set to something other then empty string.
 	idField string
 	// The Geometery field name, this will default to 'geom' if not set to something other then empty string.
@@ -18,6 +20,30 @@ type Layer struct {
 	srid uint64
 }
 
+// variant is the SQL of a layer for a range of zooms
+type variant struct {
+	minZoom uint
+	maxZoom uint
+	sql     string
+}
+
+// zoomSQL returns the SQL of the layer for the zoom, which is the SQL of the first
+// variant covering the zoom for layers with variants. It reports false when none of
+// the variants cover the zoom, in which case the layer has no features at the zoom.
+func (l Layer) zoomSQL(z uint) (string, bool) {
+	if len(l.variants) == 0 {
+		return l.sql, true
+	}
+
+	for _, v := range l.variants {
+		if z >= v.minZoom && z <= v.maxZoom {
+			return v.sql, true
+		}
+	}
+
+	return "", false
+}
+
 func (l Layer) Name() string {
 	return l.name
 }
@@ -9,7 +9,9 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -39,6 +41,9 @@ type connectionPoolCollector struct {
 	// providerName the pool is created for
 	// required to make metrics unique
 	providerName string
+	// poolName is primary for the pool of the primary, and the name
+	// of the replica for the pools of the replicas
+	poolName string
 
 	maxConnectionDesc        *prometheus.Desc
 	currentConnectionsDesc   *prometheus.Desc
@@ -90,21 +95,21 @@ func (c *connectionPoolCollector) Collectors(
 		prefix+"postgres_max_connections",
 		"Max number of postgres connections in the pool",
 		nil,
-		prometheus.Labels{"provider_name": c.providerName},
+		prometheus.Labels{"provider_name": c.providerName, "pool": c.poolName},
 	)
 
 	c.currentConnectionsDesc = prometheus.NewDesc(
 		prefix+"postgres_current_connections",
 		"Current number of postgres connections in the pool",
 		nil,
-		prometheus.Labels{"provider_name": c.providerName},
+		prometheus.Labels{"provider_name": c.providerName, "pool": c.poolName},
 	)
 
 	c.availableConnectionsDesc = prometheus.NewDesc(
 		prefix+"postgres_available_connections",
 		"Current number of available postgres connections in the pool",
 		nil,
-		prometheus.Labels{"provider_name": c.providerName},
+		prometheus.Labels{"provider_name": c.providerName, "pool": c.poolName},
 	)
 
 	return []observability.Collector{c}, nil
@@ -115,6 +120,8 @@ type Provider struct {
 	config pgxpool.Config
 	name   string
 	pool   *connectionPoolCollector
+	// replicas the tile queries are routed to, if any
+	replicas *replicaSet
 
 	// map of layer name and corresponding sql
 	layers     map[string]Layer
@@ -146,6 +153,12 @@ func (p *Provider) Collectors(
 		return nil, err
 	}
 
+	rc, err := p.replicas.collectors(prefix, cfgFn)
+	if err != nil {
+		return nil, err
+	}
+	c = append(c, rc...)
+
 	// a constant label ensures that the metrics are unique
 	// this allows the registration of multiple providers in the same
 	// config.
@@ -209,8 +222,14 @@ const (
 	ConfigKeyGeomField                  = "geometry_fieldname"
 	ConfigKeyGeomIDField                = "id_fieldname"
 	ConfigKeyGeomType                   = "geometry_type"
+	ConfigKeyVariants                   = "variants"
+	ConfigKeyMinZoom                    = "min_zoom"
+	ConfigKeyMaxZoom                    = "max_zoom"
 	ConfigKeyApplicationName            = "application_name"
 	ConfigKeyDefaultTransactionReadOnly = "default_transaction_read_only"
+	ConfigKeyReplicaURIs                = "replica_uris"
+	ConfigKeyReplicaBalancing           = "replica_balancing"
+	ConfigKeyReplicaHealthCheckInterval = "replica_health_check_interval"
 )
 
 // isSelectQuery is a regexp to check if a query starts with `SELECT`,
@@ -267,6 +286,12 @@ func BuildURI(config dict.Dicter) (*url.URL, *url.Values, error) {
 		return nil, nil, err
 	}
 
+	return parseURI(uri, sslmode)
+}
+
+// parseURI validates and parses a database URI, adding the sslmode to the
+// URI when it's missing.
+func parseURI(uri string, sslmode string) (*url.URL, *url.Values, error) {
 	if err := validateURI(uri); err != nil {
 		return nil, nil, err
 	}
@@ -387,6 +412,9 @@ func BuildDBConfig(opts *DBConfigOptions) (*pgxpool.Config, error) {
 //		password (string): [Required] postgis database password
 //		srid (int): [Optional] The default SRID for the provider. Defaults to WebMercator (3857) but also supports WGS84 (4326)
 //		max_connections : [Optional] The max connections to maintain in the connection pool. Default is 100. 0 means no max.
+//		replica_uris ([]string): [Optional] the uris of read replicas the tile queries are routed to. Layers are inspected on the primary.
+//		replica_balancing (string): [Optional] how the tile queries are balanced across the healthy replicas, round_robin or least_connections. Defaults to round_robin.
+//		replica_health_check_interval (string): [Optional] how often the replicas are health checked. Defaults to 10s.
 //		layers (map[string]struct{})  — This is map of layers keyed by the layer name. supports the following properties
 //
 //			name (string): [Required] the name of the layer. This is used to reference this layer from map layers.
@@ -396,6 +424,7 @@ func BuildDBConfig(opts *DBConfigOptions) (*pgxpool.Config, error) {
 //			fields ([]string): [Optional] a list of fields to include alongside the feature. Can be used if sql is not defined.
 //			srid (int): [Optional] the SRID of the layer. Supports 3857 (WebMercator) or 4326 (WGS84).
 //			sql (string): [*Required] custom SQL to use use. Required if tablename is not defined. Supports the following tokens:
+//			variants ([]map[string]struct{}): [Optional] an ordered list of tablename or sql, fields, min_zoom and max_zoom, used instead of the layer tablename or sql. A tile uses the first variant covering its zoom.
 func CreateProvider(
 	config dict.Dicter,
 	maps []provider.Map,
@@ -475,7 +504,22 @@ func CreateProvider(
 	if err != nil {
 		return nil, fmt.Errorf("failed while creating connection pool: %w", err)
 	}
-	p.pool = &connectionPoolCollector{Pool: pool, providerName: name}
+	p.pool = &connectionPoolCollector{Pool: pool, providerName: name, poolName: "primary"}
+
+	if p.replicas, err = createReplicas(
+		config,
+		name,
+		sslkey,
+		sslcert,
+		sslrootcert,
+		&DBConfigOptions{
+			DefaultTransactionReadOnly: default_transaction_read_only,
+			ApplicationName:            application_name,
+		},
+	); err != nil {
+		p.pool.Close()
+		return nil, err
+	}
 
 	layers, err := config.MapSlice(ConfigKeyLayers)
 	if err != nil {
@@ -550,40 +594,6 @@ func CreateProvider(
 			return nil, fmt.Errorf("for layer (%v) %v : %w", i, lName, err)
 		}
 
-		var tblName string
-		tblName, err = layer.String(ConfigKeyTablename, &lName)
-		if err != nil {
-			return nil, fmt.Errorf(
-				"for %v layer (%v) %v has an error: %w",
-				i,
-				lName,
-				ConfigKeyTablename,
-				err,
-			)
-		}
-
-		var sql string
-		sql, err = layer.String(ConfigKeySQL, &sql)
-		if err != nil {
-			return nil, fmt.Errorf(
-				"for %v layer (%v) %v has an error: %w",
-				i,
-				lName,
-				ConfigKeySQL,
-				err,
-			)
-		}
-
-		if tblName != lName && sql != "" {
-			log.Debugf(
-				"both %v and %v field are specified for layer (%v) %v, using only %[2]v field.",
-				ConfigKeyTablename,
-				ConfigKeySQL,
-				i,
-				lName,
-			)
-		}
-
 		lsrid := srid
 		if lsrid, err = layer.Int(ConfigKeySRID, &lsrid); err != nil {
 			return nil, err
@@ -596,75 +606,35 @@ func CreateProvider(
 			srid:      uint64(lsrid),
 		}
 
-		if sql != "" && !isSelectQuery.MatchString(sql) {
-			// if it is not a SELECT query, then we assume we have a sub-query
-			// (`(select ...) as foo`) which we can handle like a tablename
-			tblName = sql
-			sql = ""
+		variants, err := layer.MapSlice(ConfigKeyVariants)
+		if err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %w", i, lName, err)
 		}
 
-		if sql != "" {
-			// convert !BOX! (MapServer) and !bbox! (Mapnik) to !BBOX! for compatibility
-			sql := strings.Replace(
-				strings.Replace(sql, "!BOX!", conf.BboxToken, -1),
-				"!bbox!",
-				conf.BboxToken,
-				-1,
-			)
-			// make sure that the sql has a !BBOX! token
-			if !strings.Contains(sql, conf.BboxToken) {
-				return nil, fmt.Errorf(
-					"SQL for layer (%v) %v is missing required token: %v",
-					i,
-					lName,
-					conf.BboxToken,
-				)
-			}
-			if !strings.Contains(sql, "*") {
-				if !strings.Contains(sql, geomfld) {
-					return nil, fmt.Errorf(
-						"SQL for layer (%v) %v does not contain the geometry field: %v",
-						i,
-						lName,
-						geomfld,
-					)
-				}
-				if !strings.Contains(sql, idfld) {
-					return nil, fmt.Errorf(
-						"SQL for layer (%v) %v does not contain the id field for the geometry: %v",
-						i,
-						lName,
-						sql,
-					)
-				}
+		if len(variants) == 0 {
+			tblName, sql, err := layerSource(layer, i, lName, lName)
+			if err != nil {
+				return nil, err
 			}
 
-			// check all tokens are valid
-			for _, token := range provider.ParameterTokenRegexp.FindAllString(sql, -1) {
-				if _, ok := conf.ReservedTokens[token]; !ok {
-					return nil, fmt.Errorf(
-						"SQL for layer (%v) %v references an unknown token %s: %v",
-						i,
-						lName,
-						token,
-						sql,
-					)
-				}
+			l.sql, err = p.layerSQL(&l, i, tblName, sql, fields, providerType)
+			if err != nil {
+				return nil, err
 			}
 
-			l.sql = sql
+			if debugLayerSQL {
+				log.Debugf("SQL for Layer(%v):\n%v\n", lName, l.sql)
+			}
 		} else {
-			// Tablename and Fields will be used to build the query.
-			// We need to do some work. We need to check to see Fields contains the geom and gid fields
-			// and if not add them to the list. If Fields list is empty/nil we will use '*' for the field list.
-			l.sql, err = genSQL(&l, p.pool, tblName, fields, true, providerType)
-			if err != nil {
-				return nil, fmt.Errorf("could not generate sql, for layer(%v): %w", lName, err)
+			if l.variants, err = p.layerVariants(&l, i, layer, variants, fields, providerType); err != nil {
+				return nil, err
 			}
-		}
 
-		if debugLayerSQL {
-			log.Debugf("SQL for Layer(%v):\n%v\n", lName, l.sql)
+			if debugLayerSQL {
+				for _, v := range l.variants {
+					log.Debugf("SQL for Layer(%v) zooms %v-%v:\n%v\n", lName, v.minZoom, v.maxZoom, v.sql)
+				}
+			}
 		}
 
 		// set the layer geom type
@@ -676,13 +646,21 @@ func CreateProvider(
 					err,
 				)
 			}
-		} else {
+		}
+
+		if geomType == "" || len(l.variants) > 0 {
 			pname, err := config.String(ConfigKeyName, nil)
 			if err != nil {
 				return nil, err
 			}
 
-			if err = p.inspectLayerGeomType(pname, &l, maps); err != nil {
+			if len(l.variants) > 0 {
+				// the variants are inspected even when the geometry type is set, as
+				// they have to return the same columns
+				if err = p.inspectLayerVariants(pname, i, &l, maps); err != nil {
+					return nil, err
+				}
+			} else if err = p.inspectLayerGeomType(pname, &l, maps); err != nil {
 				return nil, fmt.Errorf("error fetching geometry type for layer (%v): %w\nif custom parameters are used, remember to set %s for the provider", l.name, err, ConfigKeyGeomType)
 			}
 		}
@@ -691,12 +669,238 @@ func CreateProvider(
 	}
 	p.layers = lyrs
 
+	p.replicas.start()
+
 	// track the provider so we can clean it up later
 	providers = append(providers, p)
 
 	return &p, nil
 }
 
+// layerSource reads the tablename and sql of a layer, or of a layer variant. The
+// tablename defaults to tblName.
+func layerSource(config dict.Dicter, i int, lName string, tblName string) (string, string, error) {
+	tblName, err := config.String(ConfigKeyTablename, &tblName)
+	if err != nil {
+		return "", "", fmt.Errorf(
+			"for %v layer (%v) %v has an error: %w",
+			i,
+			lName,
+			ConfigKeyTablename,
+			err,
+		)
+	}
+
+	var sql string
+	sql, err = config.String(ConfigKeySQL, &sql)
+	if err != nil {
+		return "", "", fmt.Errorf(
+			"for %v layer (%v) %v has an error: %w",
+			i,
+			lName,
+			ConfigKeySQL,
+			err,
+		)
+	}
+
+	if tblName != lName && tblName != "" && sql != "" {
+		log.Debugf(
+			"both %v and %v field are specified for layer (%v) %v, using only %[2]v field.",
+			ConfigKeyTablename,
+			ConfigKeySQL,
+			i,
+			lName,
+		)
+	}
+
+	return tblName, sql, nil
+}
+
+// layerSQL validates the custom sql of a layer, or generates the sql from the
+// tablename and fields when the sql is not set.
+func (p *Provider) layerSQL(l *Layer, i int, tblName string, sql string, fields []string, providerType string) (string, error) {
+	if sql != "" && !isSelectQuery.MatchString(sql) {
+		// if it is not a SELECT query, then we assume we have a sub-query
+		// (`(select ...) as foo`) which we can handle like a tablename
+		tblName = sql
+		sql = ""
+	}
+
+	if sql == "" {
+		// Tablename and Fields will be used to build the query.
+		// We need to do some work. We need to check to see Fields contains the geom and gid fields
+		// and if not add them to the list. If Fields list is empty/nil we will use '*' for the field list.
+		// genSQL quotes the fields in place, so it's handed a copy.
+		sql, err := genSQL(l, p.pool, tblName, append([]string(nil), fields...), true, providerType)
+		if err != nil {
+			return "", fmt.Errorf("could not generate sql, for layer(%v): %w", l.name, err)
+		}
+		return sql, nil
+	}
+
+	// convert !BOX! (MapServer) and !bbox! (Mapnik) to !BBOX! for compatibility
+	sql = strings.Replace(
+		strings.Replace(sql, "!BOX!", conf.BboxToken, -1),
+		"!bbox!",
+		conf.BboxToken,
+		-1,
+	)
+	// make sure that the sql has a !BBOX! token
+	if !strings.Contains(sql, conf.BboxToken) {
+		return "", fmt.Errorf(
+			"SQL for layer (%v) %v is missing required token: %v",
+			i,
+			l.name,
+			conf.BboxToken,
+		)
+	}
+	if !strings.Contains(sql, "*") {
+		if !strings.Contains(sql, l.geomField) {
+			return "", fmt.Errorf(
+				"SQL for layer (%v) %v does not contain the geometry field: %v",
+				i,
+				l.name,
+				l.geomField,
+			)
+		}
+		if !strings.Contains(sql, l.idField) {
+			return "", fmt.Errorf(
+				"SQL for layer (%v) %v does not contain the id field for the geometry: %v",
+				i,
+				l.name,
+				sql,
+			)
+		}
+	}
+
+	// check all tokens are valid
+	for _, token := range provider.ParameterTokenRegexp.FindAllString(sql, -1) {
+		if _, ok := conf.ReservedTokens[token]; !ok {
+			return "", fmt.Errorf(
+				"SQL for layer (%v) %v references an unknown token %s: %v",
+				i,
+				l.name,
+				token,
+				sql,
+			)
+		}
+	}
+
+	return sql, nil
+}
+
+// layerVariants builds the variants of a layer, which query a tablename or sql of their
+// own for a range of zooms. The variants inherit the fields of the layer, and have to
+// share them, as the features of the layer have one schema at every zoom.
+func (p *Provider) layerVariants(l *Layer, i int, layer dict.Dicter, configs []dict.Dicter, fields []string, providerType string) ([]variant, error) {
+	for _, key := range []string{ConfigKeyTablename, ConfigKeySQL} {
+		if _, ok := layer.Interface(key); ok {
+			return nil, fmt.Errorf(
+				"for layer (%v) %v : %v can not be set alongside %v",
+				i,
+				l.name,
+				key,
+				ConfigKeyVariants,
+			)
+		}
+	}
+
+	var schema []string
+	variants := make([]variant, 0, len(configs))
+	for j, config := range configs {
+		minZoom := 0
+		minZoom, err := config.Int(ConfigKeyMinZoom, &minZoom)
+		if err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v variant (%v) : %w", i, l.name, j, err)
+		}
+
+		maxZoom := tegola.MaxZ
+		if maxZoom, err = config.Int(ConfigKeyMaxZoom, &maxZoom); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v variant (%v) : %w", i, l.name, j, err)
+		}
+
+		if minZoom < 0 || minZoom > maxZoom {
+			return nil, fmt.Errorf(
+				"for layer (%v) %v variant (%v) : %v (%v) and %v (%v) are not a zoom range",
+				i,
+				l.name,
+				j,
+				ConfigKeyMinZoom,
+				minZoom,
+				ConfigKeyMaxZoom,
+				maxZoom,
+			)
+		}
+
+		vfields, err := config.StringSlice(ConfigKeyFields)
+		if err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v variant (%v) : %w", i, l.name, j, err)
+		}
+		if vfields == nil {
+			vfields = fields
+		}
+
+		if j == 0 {
+			schema = vfields
+		} else if !sameFields(schema, vfields) {
+			return nil, fmt.Errorf(
+				"for layer (%v) %v variant (%v) : %v %v differ from the %v %v of the first variant",
+				i,
+				l.name,
+				j,
+				ConfigKeyFields,
+				vfields,
+				ConfigKeyFields,
+				schema,
+			)
+		}
+
+		tblName, sql, err := layerSource(config, i, l.name, "")
+		if err != nil {
+			return nil, err
+		}
+		if tblName == "" && sql == "" {
+			return nil, fmt.Errorf(
+				"for layer (%v) %v variant (%v) : either %v or %v is required",
+				i,
+				l.name,
+				j,
+				ConfigKeyTablename,
+				ConfigKeySQL,
+			)
+		}
+
+		sql, err = p.layerSQL(l, i, tblName, sql, vfields, providerType)
+		if err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, variant{minZoom: uint(minZoom), maxZoom: uint(maxZoom), sql: sql})
+	}
+
+	return variants, nil
+}
+
+// sameFields reports whether the field lists have the same fields, in any order.
+func sameFields(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	fields := make(map[string]int, len(a))
+	for _, f := range a {
+		fields[f]++
+	}
+	for _, f := range b {
+		if fields[f] == 0 {
+			return false
+		}
+		fields[f]--
+	}
+
+	return true
+}
+
 // ConfigTLS is derived from github.com/jackc/pgx configTLS (https://github.com/jackc/pgx/blob/master/conn.go)
 func ConfigTLS(
 	sslMode string,
@@ -781,6 +985,69 @@ func (p Provider) setLayerGeomType(l *Layer, geomType string) error {
 // inspectLayerGeomType sets the geomType field on the layer by running the SQL
 // and reading the geom type in the result set
 func (p Provider) inspectLayerGeomType(pname string, l *Layer, maps []provider.Map) error {
+	geomType, _, err := p.inspectSQL(pname, l, l.sql, maps)
+	if err != nil {
+		return err
+	}
+
+	l.geomType = geomType
+	return nil
+}
+
+// inspectLayerVariants runs the SQL of every variant of the layer, which have to
+// return the same columns and geometry type, as the features of the layer have one
+// schema at every zoom. It sets the geomType field on the layer when it's not set.
+func (p Provider) inspectLayerVariants(pname string, i int, l *Layer, maps []provider.Map) error {
+	var columns []string
+	for j, v := range l.variants {
+		geomType, vcolumns, err := p.inspectSQL(pname, l, v.sql, maps)
+		if err != nil {
+			return fmt.Errorf(
+				"for layer (%v) %v variant (%v) : error fetching geometry type: %w",
+				i,
+				l.name,
+				j,
+				err,
+			)
+		}
+
+		if j == 0 {
+			columns = vcolumns
+		} else if !sameFields(columns, vcolumns) {
+			return fmt.Errorf(
+				"for layer (%v) %v variant (%v) : columns %v differ from the columns %v of the first variant",
+				i,
+				l.name,
+				j,
+				vcolumns,
+				columns,
+			)
+		}
+
+		switch {
+		case geomType == nil:
+			// the variant returned no rows to inspect
+		case l.geomType == nil:
+			l.geomType = geomType
+		case reflect.TypeOf(geomType) != reflect.TypeOf(l.geomType):
+			return fmt.Errorf(
+				"for layer (%v) %v variant (%v) : geometry type %T differs from the geometry type %T of the layer",
+				i,
+				l.name,
+				j,
+				geomType,
+				l.geomType,
+			)
+		}
+	}
+
+	return nil
+}
+
+// inspectSQL runs the SQL of the layer and returns the geom type in the result set,
+// which is nil when the SQL returns no rows, and the names of the columns of the
+// result set.
+func (p Provider) inspectSQL(pname string, l *Layer, sql string, maps []provider.Map) (geom.Geometry, []string, error) {
 	var err error
 
 	// we want to know the geom type instead of returning the geom data so we modify the SQL
@@ -791,11 +1058,16 @@ func (p Provider) inspectLayerGeomType(pname string, l *Layer, maps []provider.M
 	// case insensitive search
 
 	re := regexp.MustCompile(`(?i)ST_AsBinary`)
-	sql := re.ReplaceAllString(l.sql, "ST_GeometryType")
+	sql = re.ReplaceAllString(sql, "ST_GeometryType")
+
+	// the columns holding the geom type
+	typeColumns := []string{l.geomField, "st_geometrytype"}
 
 	re = regexp.MustCompile(`(?i)(ST_AsMVTGeom\(.*\))`)
 	if re.MatchString(sql) {
-		sql = fmt.Sprintf("SELECT ST_GeometryType(%v) FROM (%v) as q", l.geomField, sql)
+		// the columns of the SQL are kept for comparison, alongside its geom type
+		sql = fmt.Sprintf("SELECT ST_GeometryType(q.%v) AS tegola_geometrytype, q.* FROM (%v) as q", l.geomField, sql)
+		typeColumns = []string{"tegola_geometrytype"}
 	}
 
 	// we only need a single result set to sniff out the geometry type
@@ -816,7 +1088,7 @@ func (p Provider) inspectLayerGeomType(pname string, l *Layer, maps []provider.M
 	// normal replacer
 	sql, err = replaceTokens(sql, l, tile, true)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// substitute default values to parameter
@@ -834,49 +1106,59 @@ func (p Provider) inspectLayerGeomType(pname string, l *Layer, maps []provider.M
 
 	rows, err := p.pool.Query(context.Background(), sql, args...)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
 	// fetch rows FieldDescriptions. this gives us the OID for the data types returned to aid in decoding
 	fdescs := rows.FieldDescriptions()
+
+	var columns []string
+	for _, fdesc := range fdescs {
+		if fdesc.Name != "tegola_geometrytype" {
+			columns = append(columns, fdesc.Name)
+		}
+	}
+
+	var geomType geom.Geometry
 	for rows.Next() {
 		vals, err := rows.Values()
 		if err != nil {
-			return fmt.Errorf("error running SQL: %v ; %w", sql, err)
+			return nil, nil, fmt.Errorf("error running SQL: %v ; %w", sql, err)
 		}
 
 		// iterate the values returned from our row, sniffing for the geomField or st_geometrytype field name
 		for i, v := range vals {
-			switch string(fdescs[i].Name) {
-			case l.geomField, "st_geometrytype":
-				switch v {
-				case "ST_Point":
-					l.geomType = geom.Point{}
-				case "ST_LineString":
-					l.geomType = geom.LineString{}
-				case "ST_Polygon":
-					l.geomType = geom.Polygon{}
-				case "ST_MultiPoint":
-					l.geomType = geom.MultiPoint{}
-				case "ST_MultiLineString":
-					l.geomType = geom.MultiLineString{}
-				case "ST_MultiPolygon":
-					l.geomType = geom.MultiPolygon{}
-				case "ST_GeometryCollection":
-					l.geomType = geom.Collection{}
-				default:
-					return fmt.Errorf(
-						"layer (%v) returned unsupported geometry type (%v)",
-						l.name,
-						v,
-					)
-				}
+			if !slices.Contains(typeColumns, fdescs[i].Name) {
+				continue
+			}
+
+			switch v {
+			case "ST_Point":
+				geomType = geom.Point{}
+			case "ST_LineString":
+				geomType = geom.LineString{}
+			case "ST_Polygon":
+				geomType = geom.Polygon{}
+			case "ST_MultiPoint":
+				geomType = geom.MultiPoint{}
+			case "ST_MultiLineString":
+				geomType = geom.MultiLineString{}
+			case "ST_MultiPolygon":
+				geomType = geom.MultiPolygon{}
+			case "ST_GeometryCollection":
+				geomType = geom.Collection{}
+			default:
+				return nil, nil, fmt.Errorf(
+					"layer (%v) returned unsupported geometry type (%v)",
+					l.name,
+					v,
+				)
 			}
 		}
 	}
 
-	return rows.Err()
+	return geomType, columns, rows.Err()
 }
 
 // Layer fetches an individual layer from the provider, if it's configured
@@ -923,7 +1205,14 @@ func (p Provider) TileFeatures(
 		return ErrLayerNotFound{layer}
 	}
 
-	sql, err := replaceTokens(plyr.sql, &plyr, tile, true)
+	z, _, _ := tile.ZXY()
+	lsql, ok := plyr.zoomSQL(uint(z))
+	if !ok {
+		// none of the layer variants cover the zoom of the tile
+		return nil
+	}
+
+	sql, err := replaceTokens(lsql, &plyr, tile, true)
 	if err := ctxErr(ctx, err); err != nil {
 		return fmt.Errorf(
 			"error replacing layer tokens for layer (%v) SQL (%v): %w",
@@ -950,9 +1239,8 @@ func (p Provider) TileFeatures(
 	}
 
 	now := time.Now()
-	rows, err := p.pool.Query(ctx, sql, args...)
+	rows, err := p.query(ctx, sql, args...)
 	if p.queryHistogramSeconds != nil {
-		z, _, _ := tile.ZXY()
 		lbls := prometheus.Labels{
 			"z":          strconv.FormatUint(uint64(z), 10),
 			"map_name":   mapName,
@@ -1090,6 +1378,7 @@ func (p Provider) MVTForLayers(
 	}
 
 	args := make([]any, 0)
+	z, _, _ := tile.ZXY()
 
 	for i := range layers {
 		if debug {
@@ -1101,10 +1390,15 @@ func (p Provider) MVTForLayers(
 			// spam the user?
 			log.Warnf("provider layer not found %v", layers[i].Name)
 		}
+		lsql, ok := l.zoomSQL(uint(z))
+		if !ok {
+			// none of the layer variants cover the zoom of the tile
+			continue
+		}
 		if debugLayerSQL {
-			log.Debugf("SQL for Layer(%v):\n%v\nargs:%v\n", l.Name(), l.sql, args)
+			log.Debugf("SQL for Layer(%v):\n%v\nargs:%v\n", l.Name(), lsql, args)
 		}
-		sql, err := replaceTokens(l.sql, &l, tile, false)
+		sql, err := replaceTokens(lsql, &l, tile, false)
 		if err := ctxErr(ctx, err); err != nil {
 			return nil, err
 		}
@@ -1133,6 +1427,11 @@ func (p Provider) MVTForLayers(
 		))
 	}
 
+	if len(sqls) == 0 {
+		// none of the layers have features at the zoom of the tile
+		return []byte{}, nil
+	}
+
 	subsqls := strings.Join(sqls, "||")
 
 	fsql := fmt.Sprintf(`SELECT (%s) AS data`, subsqls)
@@ -1144,9 +1443,8 @@ func (p Provider) MVTForLayers(
 	}
 	{
 		now := time.Now()
-		err = p.pool.QueryRow(ctx, fsql, args...).Scan(&data)
+		err = p.queryRow(ctx, []any{&data}, fsql, args...)
 		if p.mvtProviderQueryHistogramSeconds != nil {
-			z, _, _ := tile.ZXY()
 			lbls := prometheus.Labels{
 				"z":        strconv.FormatUint(uint64(z), 10),
 				"map_name": mapName,
@@ -1173,8 +1471,11 @@ func (p Provider) MVTForLayers(
 	return data, nil
 }
 
-// Close will close the Provider's database connectio
-func (p *Provider) Close() { p.pool.Close() }
+// Close will close the Provider's database connections, including the connections to the replicas
+func (p *Provider) Close() {
+	p.replicas.close()
+	p.pool.Close()
+}
 
 // reference to all instantiated providers
 var providers []Provider
@@ -457,3 +457,150 @@ func TestPGXOnNotice(t *testing.T) {
 		)
 	}
 }
+
+func TestLayerVariants(t *testing.T) {
+	type tcase struct {
+		variants []map[string]any
+		layer    map[string]any
+		// expected is the SQL by zoom, "" for zooms without features
+		expected map[uint]string
+		// geom is the expected geometry type of the layer
+		geom geom.Geometry
+		err  string
+		// db is set for the cases which run the SQL of the variants
+		db bool
+	}
+
+	const (
+		lowSQL  = "SELECT gid, scalerank, ST_AsBinary(geom) AS geom FROM ne_10m_land_scale_rank WHERE scalerank < 2 AND geom && !BBOX!"
+		highSQL = "SELECT gid, scalerank, ST_AsBinary(geom) AS geom FROM ne_10m_land_scale_rank WHERE geom && !BBOX!"
+	)
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			defaultConfig := DefaultConfig
+			if tc.db {
+				ttools.ShouldSkip(t, TESTENV)
+				defaultConfig = DefaultEnvConfig
+			}
+
+			layer := map[string]any{
+				ConfigKeyLayerName: "roads",
+				ConfigKeyVariants:  tc.variants,
+			}
+			for k, v := range tc.layer {
+				layer[k] = v
+			}
+			config := TCConfig{LayerConfig: []map[string]any{layer}}.Config(defaultConfig)
+			config[ConfigKeyName] = "provider_name"
+
+			p, err := CreateProvider(config, nil, "")
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Errorf("expected %v got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			defer p.Close()
+
+			l, _ := p.Layer("roads")
+			if !reflect.DeepEqual(tc.geom, l.geomType) {
+				t.Errorf("geom type, expected %v got %v", tc.geom, l.geomType)
+			}
+			for z, expected := range tc.expected {
+				sql, ok := l.zoomSQL(z)
+				if ok != (expected != "") || sql != expected {
+					t.Errorf("zoom %v, expected %q got %q", z, expected, sql)
+				}
+			}
+
+			layers, _ := p.Layers()
+			if len(layers) != 1 || layers[0].Name() != "roads" {
+				t.Errorf("layers, expected [roads] got %v", layers)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"by zoom": {
+			variants: []map[string]any{
+				{ConfigKeyMaxZoom: 8, ConfigKeySQL: lowSQL},
+				{ConfigKeyMinZoom: 10, ConfigKeySQL: highSQL},
+			},
+			expected: map[uint]string{0: lowSQL, 8: lowSQL, 9: "", 10: highSQL, 22: highSQL},
+			geom:     geom.MultiPolygon{},
+			db:       true,
+		},
+		"first variant wins": {
+			variants: []map[string]any{
+				{ConfigKeyMinZoom: 5, ConfigKeyMaxZoom: 8, ConfigKeySQL: lowSQL},
+				{ConfigKeySQL: highSQL},
+			},
+			expected: map[uint]string{4: highSQL, 5: lowSQL, 9: highSQL},
+			geom:     geom.MultiPolygon{},
+			db:       true,
+		},
+		"configured geometry_type": {
+			layer: map[string]any{ConfigKeyGeomType: "multipolygon"},
+			variants: []map[string]any{
+				{ConfigKeyMaxZoom: 8, ConfigKeySQL: lowSQL},
+				{ConfigKeyMinZoom: 9, ConfigKeySQL: highSQL},
+			},
+			expected: map[uint]string{8: lowSQL, 9: highSQL},
+			geom:     geom.MultiPolygon{},
+			db:       true,
+		},
+		"different columns": {
+			variants: []map[string]any{
+				{ConfigKeyMaxZoom: 8, ConfigKeySQL: lowSQL},
+				{ConfigKeyMinZoom: 9, ConfigKeySQL: "SELECT gid, ST_AsBinary(geom) AS geom FROM ne_10m_land_scale_rank WHERE geom && !BBOX!"},
+			},
+			err: "for layer (0) roads variant (1) : columns [gid geom] differ from the columns [gid scalerank geom] of the first variant",
+			db:  true,
+		},
+		"different geometry types": {
+			variants: []map[string]any{
+				{ConfigKeyMaxZoom: 8, ConfigKeySQL: "SELECT gid, scalerank, ST_AsBinary(ST_PointOnSurface(geom)) AS geom FROM ne_10m_land_scale_rank WHERE geom && !BBOX!"},
+				{ConfigKeyMinZoom: 9, ConfigKeySQL: highSQL},
+			},
+			err: "for layer (0) roads variant (1) : geometry type geom.MultiPolygon differs from the geometry type geom.Point of the layer",
+			db:  true,
+		},
+		"different configured geometry_type": {
+			layer: map[string]any{ConfigKeyGeomType: "linestring"},
+			variants: []map[string]any{
+				{ConfigKeySQL: highSQL},
+			},
+			err: "for layer (0) roads variant (0) : geometry type geom.MultiPolygon differs from the geometry type geom.LineString of the layer",
+			db:  true,
+		},
+		"tablename alongside variants": {
+			layer:    map[string]any{ConfigKeyTablename: "roads"},
+			variants: []map[string]any{{ConfigKeySQL: lowSQL}},
+			err:      "for layer (0) roads : tablename can not be set alongside variants",
+		},
+		"missing sql": {
+			variants: []map[string]any{{ConfigKeyMaxZoom: 8}},
+			err:      "for layer (0) roads variant (0) : either tablename or sql is required",
+		},
+		"invalid zooms": {
+			variants: []map[string]any{{ConfigKeyMinZoom: 9, ConfigKeyMaxZoom: 8, ConfigKeySQL: lowSQL}},
+			err:      "for layer (0) roads variant (0) : min_zoom (9) and max_zoom (8) are not a zoom range",
+		},
+		"different fields": {
+			layer: map[string]any{ConfigKeyFields: []string{"name"}},
+			variants: []map[string]any{
+				{ConfigKeyMaxZoom: 8, ConfigKeySQL: lowSQL},
+				{ConfigKeyMinZoom: 9, ConfigKeySQL: highSQL, ConfigKeyFields: []string{"name", "class"}},
+			},
+			err: "for layer (0) roads variant (1) : fields [name class] differ from the fields [name] of the first variant",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
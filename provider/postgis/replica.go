@@ -0,0 +1,386 @@
+package postgis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/observability"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// balancing strategies of the tile queries across the replicas
+const (
+	BalancingRoundRobin       = "round_robin"
+	BalancingLeastConnections = "least_connections"
+)
+
+const (
+	DefaultReplicaBalancing           = BalancingRoundRobin
+	DefaultReplicaHealthCheckInterval = "10s"
+)
+
+// replica is a read replica of the database the tile queries are routed to
+type replica struct {
+	pool *connectionPoolCollector
+	// name identifies the replica in logs and metrics, as its host and database
+	name    string
+	healthy atomic.Bool
+}
+
+// eject stops routing the tile queries to the replica, until a health check
+// re-admits it
+func (r *replica) eject(err error) {
+	if r.healthy.Swap(false) {
+		log.Warnf("postgis: ejecting replica (%v): %v", r.name, err)
+	}
+}
+
+// admit routes the tile queries to the replica
+func (r *replica) admit() {
+	if !r.healthy.Swap(true) {
+		log.Infof("postgis: admitting replica (%v)", r.name)
+	}
+}
+
+// createReplicas creates the pools of the replicas of replica_uris, which share the
+// ssl and run time parameters of the primary. It returns nil when there are no
+// replicas configured.
+func createReplicas(
+	config dict.Dicter,
+	providerName string,
+	sslkey string,
+	sslcert string,
+	sslrootcert string,
+	opts *DBConfigOptions,
+) (*replicaSet, error) {
+	uris, err := config.StringSlice(ConfigKeyReplicaURIs)
+	if err != nil {
+		return nil, err
+	}
+	if len(uris) == 0 {
+		return nil, nil
+	}
+
+	balancing := DefaultReplicaBalancing
+	if balancing, err = config.String(ConfigKeyReplicaBalancing, &balancing); err != nil {
+		return nil, err
+	}
+	switch balancing {
+	case BalancingRoundRobin, BalancingLeastConnections:
+	default:
+		return nil, ErrInvalidReplicaBalancing(balancing)
+	}
+
+	interval := DefaultReplicaHealthCheckInterval
+	if interval, err = config.String(ConfigKeyReplicaHealthCheckInterval, &interval); err != nil {
+		return nil, err
+	}
+	healthCheckInterval, err := time.ParseDuration(interval)
+	if err != nil || healthCheckInterval <= 0 {
+		return nil, fmt.Errorf(
+			"invalid %v (%v), expecting a positive duration",
+			ConfigKeyReplicaHealthCheckInterval,
+			interval,
+		)
+	}
+
+	sslmode := DefaultSSLMode
+	if sslmode, err = config.String(ConfigKeySSLMode, &sslmode); err != nil {
+		return nil, err
+	}
+
+	rs := &replicaSet{
+		balancing:           balancing,
+		healthCheckInterval: healthCheckInterval,
+		done:                make(chan struct{}),
+	}
+
+	seen := make(map[string]int, len(uris))
+	for i, uri := range uris {
+		r, err := createReplica(uri, providerName, sslmode, sslkey, sslcert, sslrootcert, opts)
+		if err != nil {
+			rs.close()
+			return nil, fmt.Errorf("for %v (%v): %w", ConfigKeyReplicaURIs, i, err)
+		}
+
+		if j, ok := seen[r.name]; ok {
+			r.pool.Close()
+			rs.close()
+			return nil, fmt.Errorf(
+				"%v replica is duplicated in both %v (%v) and (%v)",
+				r.name,
+				ConfigKeyReplicaURIs,
+				j,
+				i,
+			)
+		}
+		seen[r.name] = i
+
+		rs.replicas = append(rs.replicas, r)
+	}
+
+	return rs, nil
+}
+
+// createReplica creates the pool of a replica. The replica is healthy until it fails
+// a query or a health check.
+func createReplica(
+	uri string,
+	providerName string,
+	sslmode string,
+	sslkey string,
+	sslcert string,
+	sslrootcert string,
+	opts *DBConfigOptions,
+) (*replica, error) {
+	parsedURI, params, err := parseURI(uri, sslmode)
+	if err != nil {
+		return nil, err
+	}
+
+	replicaOpts := *opts
+	replicaOpts.Uri = parsedURI.String()
+	dbconfig, err := BuildDBConfig(&replicaOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed while building db config: %w", err)
+	}
+
+	if err = ConfigTLS(params.Get("sslmode"), sslkey, sslcert, sslrootcert, dbconfig); err != nil {
+		return nil, err
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), dbconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed while creating connection pool: %w", err)
+	}
+
+	// the credentials are left out of the name, which is used in the logs and metrics
+	r := &replica{name: parsedURI.Host + parsedURI.Path}
+	r.pool = &connectionPoolCollector{Pool: pool, providerName: providerName, poolName: r.name}
+	r.healthy.Store(true)
+
+	return r, nil
+}
+
+// replicaSet balances the tile queries across the healthy replicas of the primary
+type replicaSet struct {
+	replicas  []*replica
+	balancing string
+	// next is the round robin counter
+	next atomic.Uint64
+
+	healthCheckInterval time.Duration
+	done                chan struct{}
+	closeOnce           sync.Once
+}
+
+// pick returns a healthy replica by the balancing strategy, or nil when there are
+// none, in which case the tile queries fall back to the primary
+func (rs *replicaSet) pick() *replica {
+	if rs == nil {
+		return nil
+	}
+
+	switch rs.balancing {
+	case BalancingLeastConnections:
+		var (
+			picked *replica
+			least  int32
+		)
+		for _, r := range rs.replicas {
+			if !r.healthy.Load() {
+				continue
+			}
+			if conns := r.pool.Stat().AcquiredConns(); picked == nil || conns < least {
+				picked, least = r, conns
+			}
+		}
+		return picked
+
+	default:
+		// the healthy replicas are rotated through, so an ejected replica's share
+		// of the queries is spread evenly across the others
+		healthy := make([]*replica, 0, len(rs.replicas))
+		for _, r := range rs.replicas {
+			if r.healthy.Load() {
+				healthy = append(healthy, r)
+			}
+		}
+		if len(healthy) == 0 {
+			return nil
+		}
+		return healthy[rs.next.Add(1)%uint64(len(healthy))]
+	}
+}
+
+// failed reports whether the query failed because the replica is unavailable, and
+// ejects it if so. Queries failing on the replica for other reasons would fail the
+// same on the primary.
+func (rs *replicaSet) failed(ctx context.Context, r *replica, err error) bool {
+	if err == nil || ctx.Err() != nil || !unavailable(err) {
+		return false
+	}
+
+	r.eject(err)
+	return true
+}
+
+// unavailable reports whether the error is from a server which can't run queries,
+// rather than from the query
+func unavailable(err error) bool {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		// the server didn't respond
+		return true
+	}
+
+	// connection exceptions, and servers shutting down or starting up
+	return strings.HasPrefix(pgErr.Code, "08") ||
+		pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+}
+
+// checkHealth pings the replicas, ejecting the failing replicas and re-admitting the
+// healthy ones
+func (rs *replicaSet) checkHealth() {
+	var wg sync.WaitGroup
+	for _, r := range rs.replicas {
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), rs.healthCheckInterval)
+			defer cancel()
+
+			if err := r.pool.Ping(ctx); err != nil {
+				r.eject(err)
+				return
+			}
+			r.admit()
+		}(r)
+	}
+	wg.Wait()
+}
+
+// start checks the health of the replicas in the background
+func (rs *replicaSet) start() {
+	if rs == nil {
+		return
+	}
+
+	go rs.healthCheckLoop()
+}
+
+// healthCheckLoop checks the health of the replicas right away, and at the health
+// check interval, until the replica set is closed
+func (rs *replicaSet) healthCheckLoop() {
+	t := time.NewTicker(rs.healthCheckInterval)
+	defer t.Stop()
+
+	rs.checkHealth()
+
+	for {
+		select {
+		case <-rs.done:
+			return
+		case <-t.C:
+			rs.checkHealth()
+		}
+	}
+}
+
+// collectors returns the pool collectors of the replicas, and a gauge of their health
+func (rs *replicaSet) collectors(prefix string, cfgFn func(configKey string) map[string]any) ([]observability.Collector, error) {
+	if rs == nil {
+		return nil, nil
+	}
+
+	var collectors []observability.Collector
+	for _, r := range rs.replicas {
+		c, err := r.pool.Collectors(prefix, cfgFn)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, c...)
+
+		healthyPrefix := prefix
+		if healthyPrefix != "" && !strings.HasSuffix(healthyPrefix, "_") {
+			healthyPrefix = healthyPrefix + "_"
+		}
+		collectors = append(collectors, prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name:        healthyPrefix + "postgres_replica_healthy",
+				Help:        "Whether the postgres replica is healthy, and tile queries are routed to it",
+				ConstLabels: prometheus.Labels{"provider_name": r.pool.providerName, "pool": r.name},
+			},
+			func() float64 {
+				if r.healthy.Load() {
+					return 1
+				}
+				return 0
+			},
+		))
+	}
+
+	return collectors, nil
+}
+
+// close stops the health checks, and closes the pools of the replicas
+func (rs *replicaSet) close() {
+	if rs == nil {
+		return
+	}
+
+	rs.closeOnce.Do(func() {
+		close(rs.done)
+		for _, r := range rs.replicas {
+			r.pool.Close()
+		}
+	})
+}
+
+// query runs a tile query on a healthy replica, falling back to the primary when
+// there are none, or the replica is unavailable
+func (p Provider) query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	r := p.replicas.pick()
+	if r == nil {
+		return p.pool.Query(ctx, sql, args...)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if !p.replicas.failed(ctx, r, err) {
+		return rows, err
+	}
+	rows.Close()
+
+	return p.pool.Query(ctx, sql, args...)
+}
+
+// queryRow runs a tile query returning a single row on a healthy replica, falling
+// back to the primary when there are none, or the replica is unavailable
+func (p Provider) queryRow(ctx context.Context, dest []any, sql string, args ...any) error {
+	r := p.replicas.pick()
+	if r == nil {
+		return p.pool.QueryRow(ctx, sql, args...).Scan(dest...)
+	}
+
+	err := r.pool.QueryRow(ctx, sql, args...).Scan(dest...)
+	if !p.replicas.failed(ctx, r, err) {
+		return err
+	}
+
+	return p.pool.QueryRow(ctx, sql, args...).Scan(dest...)
+}
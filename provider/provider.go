@@ -132,6 +132,13 @@ type Tiler interface {
 	TileFeatures(ctx context.Context, layer string, t Tile, params Params, fn func(f *Feature) error) error
 }
 
+// Live is implemented by providers whose features change too often for the tiles of
+// their layers to be cached, such as providers of vehicle positions.
+type Live interface {
+	// Live reports whether the features of the provider are live
+	Live() bool
+}
+
 // TilerUnion represents either a Std Tiler or and MVTTiler; only one should be not nil.
 type TilerUnion struct {
 	Std Tiler
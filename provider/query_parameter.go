@@ -16,6 +16,10 @@ type QueryParameter struct {
 	// default_value can be specified
 	DefaultSQL   string `toml:"default_sql"`
 	DefaultValue string `toml:"default_value"`
+	// Cache turns on caching the tiles of requests using the parameter. The
+	// tiles seeded with the parameter are served either way. It doesn't change
+	// the rendered tiles, so it's not part of the map config version.
+	Cache bool `toml:"cache" json:"-"`
 }
 
 // Normalize normalizes param and sets default values
@@ -0,0 +1,213 @@
+package shapefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+)
+
+// dbfField is a field of a dBASE table
+type dbfField struct {
+	name     string
+	typ      byte
+	offset   int
+	length   int
+	decimals int
+}
+
+// dbf reads the records of the dBASE table of a shapefile
+type dbf struct {
+	r            io.ReaderAt
+	fields       []dbfField
+	count        int
+	headerLength int64
+	recordLength int
+	// ldid is the language driver id of the table, which identifies its code page
+	ldid byte
+	// enc is the encoding of the character fields and field names. nil for UTF-8
+	enc encoding.Encoding
+}
+
+// readDBFHeader reads the header of a dBASE table. The field names are decoded once the
+// encoding is set.
+func readDBFHeader(r io.ReaderAt) (*dbf, error) {
+	b := make([]byte, 32)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	d := &dbf{
+		r:            r,
+		count:        int(binary.LittleEndian.Uint32(b[4:])),
+		headerLength: int64(binary.LittleEndian.Uint16(b[8:])),
+		recordLength: int(binary.LittleEndian.Uint16(b[10:])),
+		ldid:         b[29],
+	}
+	if d.headerLength < 33 {
+		return nil, errors.New("invalid dBASE header")
+	}
+
+	desc := make([]byte, d.headerLength-32)
+	if _, err := r.ReadAt(desc, 32); err != nil {
+		return nil, fmt.Errorf("reading fields: %w", err)
+	}
+
+	// the deletion flag precedes the fields of a record
+	offset := 1
+	for pos := 0; pos+32 <= len(desc) && desc[pos] != 0x0D; pos += 32 {
+		fd := desc[pos : pos+32]
+		name := fd[:11]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+
+		f := dbfField{
+			name:     string(name),
+			typ:      fd[11],
+			offset:   offset,
+			length:   int(fd[16]),
+			decimals: int(fd[17]),
+		}
+		offset += f.length
+		d.fields = append(d.fields, f)
+	}
+	if offset > d.recordLength {
+		return nil, fmt.Errorf("fields of %v bytes exceed the record length %v", offset, d.recordLength)
+	}
+
+	return d, nil
+}
+
+// setEncoding sets the encoding of the character fields and decodes the field names
+func (d *dbf) setEncoding(enc encoding.Encoding) error {
+	d.enc = enc
+	for i := range d.fields {
+		name, err := d.decode([]byte(d.fields[i].name))
+		if err != nil {
+			return fmt.Errorf("decoding field name %q: %w", d.fields[i].name, err)
+		}
+		d.fields[i].name = name
+	}
+	return nil
+}
+
+func (d *dbf) decode(b []byte) (string, error) {
+	if d.enc == nil {
+		return string(b), nil
+	}
+	// decoders hold state, so one is used for each value
+	return d.enc.NewDecoder().String(string(b))
+}
+
+// record reads the values of a record by field name. Deleted records return nil values.
+// Only the fields in include are read, or all of them when include is nil.
+func (d *dbf) record(i int, include map[string]bool) (map[string]interface{}, error) {
+	if i < 0 || i >= d.count {
+		return nil, fmt.Errorf("record %v out of range", i)
+	}
+
+	b := make([]byte, d.recordLength)
+	if _, err := d.r.ReadAt(b, d.headerLength+int64(i)*int64(d.recordLength)); err != nil {
+		return nil, err
+	}
+	if b[0] == '*' {
+		return nil, nil
+	}
+
+	values := make(map[string]interface{}, len(d.fields))
+	for _, f := range d.fields {
+		if include != nil && !include[f.name] {
+			continue
+		}
+		v, err := d.value(f, b[f.offset:f.offset+f.length])
+		if err != nil {
+			return nil, fmt.Errorf("field %v: %w", f.name, err)
+		}
+		if v != nil {
+			values[f.name] = v
+		}
+	}
+	return values, nil
+}
+
+// value decodes the value of a field. Blank values are nil.
+func (d *dbf) value(f dbfField, b []byte) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	switch f.typ {
+	case 'C':
+		s, err := d.decode(bytes.TrimRight(b, " \x00"))
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+
+	case 'N', 'F':
+		s := strings.TrimSpace(string(bytes.Trim(b, "\x00")))
+		if s == "" || strings.Trim(s, "*") == "" {
+			return nil, nil
+		}
+		if f.decimals == 0 {
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return i, nil
+			}
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+
+	case 'L':
+		switch b[0] {
+		case 'T', 't', 'Y', 'y':
+			return true, nil
+		case 'F', 'f', 'N', 'n':
+			return false, nil
+		default:
+			return nil, nil
+		}
+
+	case 'D':
+		// dates are YYYYMMDD
+		s := strings.TrimSpace(string(b))
+		if len(s) != 8 {
+			return nil, nil
+		}
+		return s[:4] + "-" + s[4:6] + "-" + s[6:], nil
+
+	case 'I':
+		if len(b) != 4 {
+			return nil, fmt.Errorf("integer of %v bytes", len(b))
+		}
+		return int64(int32(binary.LittleEndian.Uint32(b))), nil
+
+	case 'O':
+		if len(b) != 8 {
+			return nil, fmt.Errorf("double of %v bytes", len(b))
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+
+	default:
+		// memo and binary fields are stored in other files
+		return nil, nil
+	}
+}
+
+func (d *dbf) hasField(name string) bool {
+	for _, f := range d.fields {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package shapefile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// codePages maps the Windows code pages used in .cpg files to encoding names
+var codePages = map[string]string{
+	"874":   "windows-874",
+	"932":   "shift_jis",
+	"936":   "gbk",
+	"949":   "euc-kr",
+	"950":   "big5",
+	"1250":  "windows-1250",
+	"1251":  "windows-1251",
+	"1252":  "windows-1252",
+	"1253":  "windows-1253",
+	"1254":  "windows-1254",
+	"1255":  "windows-1255",
+	"1256":  "windows-1256",
+	"1257":  "windows-1257",
+	"1258":  "windows-1258",
+	"65001": "utf-8",
+}
+
+// ldidEncodings maps the language driver ids of dBASE headers to encoding names, for
+// shapefiles without a .cpg file
+var ldidEncodings = map[byte]string{
+	0x03: "windows-1252",
+	0x13: "shift_jis",
+	0x4D: "gbk",
+	0x4E: "euc-kr",
+	0x4F: "big5",
+	0x57: "windows-1252",
+	0x58: "windows-1252",
+	0x78: "big5",
+	0x79: "euc-kr",
+	0x7A: "gbk",
+	0x7B: "shift_jis",
+	0xC8: "windows-1250",
+	0xC9: "windows-1251",
+	0xCA: "windows-1254",
+	0xCB: "windows-1253",
+	0xCC: "windows-1257",
+}
+
+// iso8859 matches the ESRI names of the ISO 8859 encodings, such as 88591 or 8859_1
+var iso8859 = regexp.MustCompile(`^(?:iso)?[-_ ]?8859[-_ ]?(\d+)$`)
+
+// lookupEncoding returns the encoding of a .cpg file or config value. nil is returned
+// for UTF-8, which doesn't need decoding.
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "ansi "), "cp")
+
+	if cp, ok := codePages[name]; ok {
+		name = cp
+	}
+	if m := iso8859.FindStringSubmatch(name); m != nil {
+		name = "iso-8859-" + m[1]
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported encoding %q", name)
+	}
+	if enc == unicode.UTF8 {
+		return nil, nil
+	}
+	return enc, nil
+}
+
+// ldidEncoding returns the encoding of a dBASE language driver id. ok is false for the
+// ids which are not known, which are decoded as UTF-8.
+func ldidEncoding(ldid byte) (enc encoding.Encoding, ok bool) {
+	name, ok := ldidEncodings[ldid]
+	if !ok {
+		return nil, false
+	}
+	enc, err := lookupEncoding(name)
+	return enc, err == nil
+}
@@ -0,0 +1,28 @@
+package shapefile
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrMissingLayerName = errors.New("shapefile: layer is missing 'name'")
+)
+
+type ErrInvalidFilePath struct {
+	FilePath string
+}
+
+func (e ErrInvalidFilePath) Error() string {
+	return fmt.Sprintf("shapefile: invalid filepath: %v", e.FilePath)
+}
+
+// ErrUnknownLayer is returned when features are requested for a layer the provider
+// doesn't have
+type ErrUnknownLayer struct {
+	Name string
+}
+
+func (e ErrUnknownLayer) Error() string {
+	return fmt.Sprintf("shapefile: unknown layer (%v)", e.Name)
+}
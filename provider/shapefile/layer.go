@@ -0,0 +1,173 @@
+package shapefile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/internal/rtree"
+	"golang.org/x/text/encoding"
+)
+
+// sibling returns the file of the shapefile with the extension, in lower or upper case,
+// or "" when there is none
+func sibling(filename, ext string) string {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	for _, e := range []string{ext, strings.ToUpper(ext)} {
+		if _, err := os.Stat(base + e); err == nil {
+			return base + e
+		}
+	}
+	return ""
+}
+
+// open opens the files of the shapefile of the layer and reads its index. The encoding
+// and srid of the layer config, when set, override the .cpg and .prj files.
+func (l *Layer) open(encodingName string, srid uint64) (err error) {
+	defer func() {
+		if err != nil {
+			l.close()
+		}
+	}()
+
+	if l.shp, err = os.Open(l.filename); err != nil {
+		return err
+	}
+	shapeType, err := readShpHeader(l.shp)
+	if err != nil {
+		return fmt.Errorf("reading %v: %w", l.filename, err)
+	}
+	if l.geomType, err = shapeGeomType(shapeType); err != nil {
+		return fmt.Errorf("reading %v: %w", l.filename, err)
+	}
+
+	shx := sibling(l.filename, ".shx")
+	if shx == "" {
+		return fmt.Errorf("%v has no .shx file", l.filename)
+	}
+	if l.records, err = readShx(shx); err != nil {
+		return err
+	}
+
+	if err = l.openIndex(); err != nil {
+		return err
+	}
+	if err = l.openDBF(encodingName); err != nil {
+		return err
+	}
+
+	switch prj := sibling(l.filename, ".prj"); {
+	case srid != 0:
+		l.srid = srid
+	case prj != "":
+		wkt, err := os.ReadFile(prj)
+		if err != nil {
+			return err
+		}
+		if l.srid, err = prjSRID(string(wkt)); err != nil {
+			return fmt.Errorf("reading %v: %w", prj, err)
+		}
+	default:
+		log.Warnf("%v has no .prj file, assuming WGS84 (%v)", l.filename, tegola.WGS84)
+		l.srid = tegola.WGS84
+	}
+
+	return nil
+}
+
+// openIndex reads the .qix index of the shapefile, or else its .sbn index, or else indexes
+// the extents of its records in memory
+func (l *Layer) openIndex() error {
+	if qix := sibling(l.filename, ".qix"); qix != "" {
+		tree, count, err := readQix(qix)
+		switch {
+		case err != nil:
+			log.Warnf("ignoring index %v: %v", qix, err)
+		case count != len(l.records):
+			log.Warnf("ignoring index %v of %v records, the shapefile has %v records", qix, count, len(l.records))
+		default:
+			l.index = tree
+			return nil
+		}
+	}
+	if sbn := sibling(l.filename, ".sbn"); sbn != "" {
+		idx, count, err := readSbn(sbn, sibling(l.filename, ".sbx"))
+		switch {
+		case err != nil:
+			log.Warnf("ignoring index %v: %v", sbn, err)
+		case count != len(l.records):
+			log.Warnf("ignoring index %v of %v records, the shapefile has %v records", sbn, count, len(l.records))
+		default:
+			l.index = idx
+			return nil
+		}
+	}
+
+	extents, err := scanShpExtents(l.filename, len(l.records))
+	if err != nil {
+		return fmt.Errorf("reading %v: %w", l.filename, err)
+	}
+	l.index = rtree.New(extents, rtree.DefaultNodeSize)
+	return nil
+}
+
+// openDBF opens the .dbf file of the shapefile. Its encoding is the configured encoding,
+// or else the encoding of the .cpg file, or else the encoding of its language driver id.
+func (l *Layer) openDBF(encodingName string) error {
+	filename := sibling(l.filename, ".dbf")
+	if filename == "" {
+		if l.idFieldname != "" || len(l.fields) != 0 {
+			return fmt.Errorf("%v has no .dbf file", l.filename)
+		}
+		return nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	if l.dbf, err = readDBFHeader(f); err != nil {
+		f.Close()
+		return fmt.Errorf("reading %v: %w", filename, err)
+	}
+
+	var enc encoding.Encoding
+	switch cpg := sibling(l.filename, ".cpg"); {
+	case encodingName != "":
+		if enc, err = lookupEncoding(encodingName); err != nil {
+			return err
+		}
+	case cpg != "":
+		name, err := os.ReadFile(cpg)
+		if err != nil {
+			return err
+		}
+		if enc, err = lookupEncoding(string(name)); err != nil {
+			return fmt.Errorf("reading %v: %w", cpg, err)
+		}
+	default:
+		enc, _ = ldidEncoding(l.dbf.ldid)
+	}
+	if err = l.dbf.setEncoding(enc); err != nil {
+		return fmt.Errorf("reading %v: %w", filename, err)
+	}
+
+	if len(l.fields) != 0 {
+		l.include = make(map[string]bool, len(l.fields)+1)
+		for _, name := range l.fields {
+			l.include[name] = true
+		}
+		if l.idFieldname != "" {
+			l.include[l.idFieldname] = true
+		}
+	}
+	for _, name := range append([]string{l.idFieldname}, l.fields...) {
+		if name != "" && !l.dbf.hasField(name) {
+			return fmt.Errorf("%v has no field %q", filename, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package shapefile
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-spatial/tegola"
+)
+
+// prjAuthority matches the EPSG authority of the coordinate system of a WKT, which is
+// the last element of the WKT
+var prjAuthority = regexp.MustCompile(`AUTHORITY\[\s*"EPSG"\s*,\s*"?(\d+)"?\s*\]\s*\]\s*$`)
+
+// prjSRID maps the WKT of a .prj file to an SRID. Only the coordinate systems which can
+// be transformed to web mercator are supported: WGS84 (4326) and web mercator (3857).
+func prjSRID(wkt string) (uint64, error) {
+	wkt = strings.TrimSpace(wkt)
+
+	if m := prjAuthority.FindStringSubmatch(wkt); m != nil {
+		srid, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		switch srid {
+		case tegola.WGS84, tegola.WebMercator:
+			return srid, nil
+		// the deprecated codes of web mercator
+		case 900913, 3785, 102100, 102113:
+			return tegola.WebMercator, nil
+		}
+		return 0, fmt.Errorf("unsupported coordinate system EPSG:%v", srid)
+	}
+
+	// ESRI .prj files have no authority, so the coordinate system is matched by name
+	upper := strings.ToUpper(wkt)
+	name := wktName(upper)
+	switch {
+	case strings.HasPrefix(upper, "PROJCS"):
+		if strings.Contains(name, "WEB_MERCATOR") || strings.Contains(name, "PSEUDO-MERCATOR") ||
+			strings.Contains(name, "PSEUDO_MERCATOR") || strings.Contains(upper, "MERCATOR_AUXILIARY_SPHERE") {
+			return tegola.WebMercator, nil
+		}
+	case strings.HasPrefix(upper, "GEOGCS"):
+		if name == "GCS_WGS_1984" || name == "WGS 84" || name == "WGS84" {
+			return tegola.WGS84, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported coordinate system %q. expecting WGS84 or web mercator", name)
+}
+
+// wktName returns the name of the root element of a WKT
+func wktName(wkt string) string {
+	start := strings.Index(wkt, `"`)
+	if start < 0 {
+		return ""
+	}
+	end := strings.Index(wkt[start+1:], `"`)
+	if end < 0 {
+		return ""
+	}
+	return wkt[start+1 : start+1+end]
+}
@@ -0,0 +1,64 @@
+package shapefile
+
+import (
+	"testing"
+
+	"github.com/go-spatial/tegola"
+)
+
+func TestPrjSRID(t *testing.T) {
+	type tcase struct {
+		wkt    string
+		srid   uint64
+		expErr bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			srid, err := prjSRID(tc.wkt)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("expected an error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if srid != tc.srid {
+				t.Errorf("expected %v got %v", tc.srid, srid)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"esri wgs84": {
+			wkt:  `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`,
+			srid: tegola.WGS84,
+		},
+		"epsg wgs84": {
+			wkt:  `GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0],UNIT["degree",0.0174532925199433],AUTHORITY["EPSG","4326"]]`,
+			srid: tegola.WGS84,
+		},
+		"esri web mercator": {
+			wkt:  `PROJCS["WGS_1984_Web_Mercator_Auxiliary_Sphere",GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]],PROJECTION["Mercator_Auxiliary_Sphere"],UNIT["Meter",1.0]]`,
+			srid: tegola.WebMercator,
+		},
+		"deprecated web mercator": {
+			wkt:  `PROJCS["Google Maps Global Mercator",GEOGCS["WGS 84",AUTHORITY["EPSG","4326"]],PROJECTION["Mercator_2SP"],UNIT["metre",1],AUTHORITY["EPSG","900913"]]`,
+			srid: tegola.WebMercator,
+		},
+		"utm": {
+			wkt:    `PROJCS["WGS 84 / UTM zone 31N",GEOGCS["WGS 84",AUTHORITY["EPSG","4326"]],PROJECTION["Transverse_Mercator"],UNIT["metre",1],AUTHORITY["EPSG","32631"]]`,
+			expErr: true,
+		},
+		"esri lambert": {
+			wkt:    `PROJCS["RGF93_Lambert_93",GEOGCS["GCS_RGF_1993",DATUM["D_RGF_1993",SPHEROID["GRS_1980",6378137.0,298.257222101]]],PROJECTION["Lambert_Conformal_Conic"],UNIT["Meter",1.0]]`,
+			expErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
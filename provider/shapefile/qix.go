@@ -0,0 +1,155 @@
+package shapefile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/go-spatial/tegola/internal/rtree"
+)
+
+// index finds the records whose extents intersect an extent. The records are 0 based
+// and returned in increasing order.
+type index interface {
+	Search(extent rtree.Extent) []int
+}
+
+// qixNode is a node of the quadtree of a .qix file
+type qixNode struct {
+	extent   rtree.Extent
+	records  []int
+	children []qixNode
+}
+
+// qixTree is the quadtree spatial index written by MapServer's shptree and GDAL
+type qixTree struct {
+	root qixNode
+}
+
+// readQix reads a .qix quadtree index. The header is "SQT", the byte order (1 for
+// little endian, 2 for big endian, 0 for native), the version, 3 reserved bytes, the
+// number of shapes and the max depth of the tree. Each node is the byte length of its
+// subtree, its extent, its shape ids and its number of children, followed by the children.
+func readQix(filename string) (*qixTree, int, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(b) < 16 || string(b[:3]) != "SQT" {
+		return nil, 0, fmt.Errorf("%v is not a quadtree index", filename)
+	}
+
+	var order binary.ByteOrder
+	switch b[3] {
+	case 0, 1:
+		order = binary.LittleEndian
+	case 2:
+		order = binary.BigEndian
+	default:
+		return nil, 0, fmt.Errorf("%v has an invalid byte order %v", filename, b[3])
+	}
+	if b[4] != 1 {
+		return nil, 0, fmt.Errorf("%v has an unsupported version %v", filename, b[4])
+	}
+	count := int(int32(order.Uint32(b[8:])))
+
+	r := qixReader{b: b, pos: 16, order: order, count: count}
+	root, err := r.node()
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading %v: %w", filename, err)
+	}
+	return &qixTree{root: root}, count, nil
+}
+
+var errQixBounds = errors.New("truncated quadtree index")
+
+type qixReader struct {
+	b     []byte
+	pos   int
+	order binary.ByteOrder
+	// count is the number of shapes, which bounds the shape ids
+	count int
+}
+
+func (r *qixReader) uint32() (int, error) {
+	if r.pos+4 > len(r.b) {
+		return 0, errQixBounds
+	}
+	v := int(int32(r.order.Uint32(r.b[r.pos:])))
+	r.pos += 4
+	return v, nil
+}
+
+func (r *qixReader) node() (qixNode, error) {
+	var n qixNode
+
+	// the length of the subtree is not needed as the whole tree is read
+	if _, err := r.uint32(); err != nil {
+		return n, err
+	}
+
+	if r.pos+32 > len(r.b) {
+		return n, errQixBounds
+	}
+	for i := range n.extent {
+		n.extent[i] = math.Float64frombits(r.order.Uint64(r.b[r.pos+8*i:]))
+	}
+	r.pos += 32
+
+	numRecords, err := r.uint32()
+	if err != nil {
+		return n, err
+	}
+	if numRecords < 0 || numRecords > r.count {
+		return n, fmt.Errorf("node with %v shapes", numRecords)
+	}
+	n.records = make([]int, numRecords)
+	for i := range n.records {
+		if n.records[i], err = r.uint32(); err != nil {
+			return n, err
+		}
+		if n.records[i] < 0 || n.records[i] >= r.count {
+			return n, fmt.Errorf("invalid shape id %v", n.records[i])
+		}
+	}
+
+	numChildren, err := r.uint32()
+	if err != nil {
+		return n, err
+	}
+	if numChildren < 0 || numChildren > 4 {
+		return n, fmt.Errorf("node with %v children", numChildren)
+	}
+	n.children = make([]qixNode, numChildren)
+	for i := range n.children {
+		if n.children[i], err = r.node(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Search returns the records of the nodes intersecting the extent. The records are not
+// filtered by their own extents, which are not in the index.
+func (t *qixTree) Search(extent rtree.Extent) []int {
+	var (
+		records []int
+		stack   = []*qixNode{&t.root}
+	)
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !n.extent.Intersects(extent) {
+			continue
+		}
+		records = append(records, n.records...)
+		for i := range n.children {
+			stack = append(stack, &n.children[i])
+		}
+	}
+	sort.Ints(records)
+	return records
+}
@@ -0,0 +1,233 @@
+package shapefile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/log"
+	"github.com/go-spatial/tegola/provider"
+)
+
+func init() {
+	provider.Register(provider.TypeStd.Prefix()+Name, NewTileProvider, Cleanup)
+}
+
+// layerConfig is a layer with the encoding and srid overrides of its config
+type layerConfig struct {
+	Layer
+	encoding string
+	srid     uint64
+}
+
+// NewTileProvider instantiates a provider of the layers of shapefiles
+func NewTileProvider(config dict.Dicter, _ []provider.Map) (provider.Tiler, error) {
+	path, err := config.String(ConfigKeyFilePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, ErrInvalidFilePath{path}
+	}
+	path = filepath.Clean(path)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, ErrInvalidFilePath{path}
+	}
+
+	// the encoding and srid of all the layers
+	var defaults layerConfig
+	if defaults.encoding, err = config.String(ConfigKeyEncoding, &defaults.encoding); err != nil {
+		return nil, err
+	}
+	if defaults.srid, err = configSRID(config, 0); err != nil {
+		return nil, err
+	}
+
+	layers, err := config.MapSlice(ConfigKeyLayers)
+	if err != nil {
+		return nil, err
+	}
+
+	var configured []layerConfig
+	if len(layers) == 0 {
+		configured, err = discoverLayers(path, fi.IsDir(), defaults)
+	} else {
+		configured, err = configLayers(path, fi.IsDir(), layers, defaults)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := Provider{
+		Filepath: path,
+		layers:   make(map[string]*Layer, len(configured)),
+	}
+	for _, lc := range configured {
+		l := lc.Layer
+		if err := l.open(lc.encoding, lc.srid); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("for layer (%v): %w", l.name, err)
+		}
+		p.layers[l.name] = &l
+		log.Infof("opened layer (%v) with %v records from %v", l.name, len(l.records), l.filename)
+	}
+
+	// track the provider so we can clean it up later
+	providersLock.Lock()
+	providers = append(providers, &p)
+	providersLock.Unlock()
+
+	return &p, nil
+}
+
+// configSRID reads the srid config, which must be 4326 or 3857 when set
+func configSRID(config dict.Dicter, def uint64) (uint64, error) {
+	srid := int(def)
+	srid, err := config.Int(ConfigKeySRID, &srid)
+	if err != nil {
+		return 0, err
+	}
+	switch srid {
+	case 0, 4326, 3857:
+		return uint64(srid), nil
+	default:
+		return 0, fmt.Errorf("unsupported %v %v. expecting 4326 or 3857", ConfigKeySRID, srid)
+	}
+}
+
+func isShp(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".shp"
+}
+
+// discoverLayers returns a layer for each .shp file, named after the file without its
+// extension
+func discoverLayers(path string, isDir bool, defaults layerConfig) ([]layerConfig, error) {
+	layerFor := func(filename string) layerConfig {
+		base := filepath.Base(filename)
+		lc := defaults
+		lc.name = strings.TrimSuffix(base, filepath.Ext(base))
+		lc.filename = filename
+		return lc
+	}
+
+	if !isDir {
+		return []layerConfig{layerFor(path)}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		layers []layerConfig
+		seen   = make(map[string]string)
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || !isShp(entry.Name()) {
+			continue
+		}
+
+		l := layerFor(filepath.Join(path, entry.Name()))
+		if other, ok := seen[l.name]; ok {
+			return nil, fmt.Errorf("files %v and %v are both layer (%v)", other, entry.Name(), l.name)
+		}
+		seen[l.name] = entry.Name()
+		layers = append(layers, l)
+	}
+
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("directory %v has no .shp files", path)
+	}
+	return layers, nil
+}
+
+// configLayers returns the layers of the layers config. The filename of a layer is
+// relative to the directory of the provider, and defaults to the layer name with the
+// .shp extension.
+func configLayers(path string, isDir bool, layers []dict.Dicter, defaults layerConfig) ([]layerConfig, error) {
+	var (
+		configured []layerConfig
+		lyrsSeen   = make(map[string]int)
+	)
+	for i, layerConf := range layers {
+		layerName, err := layerConf.String(ConfigKeyLayerName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("for layer (%v) we got the following error trying to get the layer's name field: %v", i, err)
+		}
+		if layerName == "" {
+			return nil, ErrMissingLayerName
+		}
+
+		// check if we have already seen this layer
+		if j, ok := lyrsSeen[layerName]; ok {
+			return nil, fmt.Errorf("layer name (%v) is duplicated in both layer %v and layer %v", layerName, i, j)
+		}
+		lyrsSeen[layerName] = i
+
+		var filename string
+		if filename, err = layerConf.String(ConfigKeyFileName, &filename); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+
+		switch {
+		case !isDir:
+			if filename != "" && filename != filepath.Base(path) {
+				return nil, fmt.Errorf("for layer (%v) %v : %v is only supported when %v is a directory", i, layerName, ConfigKeyFileName, ConfigKeyFilePath)
+			}
+			filename = path
+		case filename != "":
+			filename = filepath.Join(path, filename)
+		default:
+			if filename = sibling(filepath.Join(path, layerName+".shp"), ".shp"); filename == "" {
+				return nil, fmt.Errorf("for layer (%v) %v : no shapefile named %v in %v", i, layerName, layerName, path)
+			}
+		}
+
+		lc := defaults
+		lc.name = layerName
+		lc.filename = filename
+
+		if lc.idFieldname, err = layerConf.String(ConfigKeyGeomIDField, &lc.idFieldname); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+
+		if lc.fields, err = layerConf.StringSlice(ConfigKeyFields); err != nil { // empty slices are okay
+			return nil, fmt.Errorf("for layer (%v) %v, %q field had the following error: %v", i, layerName, ConfigKeyFields, err)
+		}
+
+		if lc.encoding, err = layerConf.String(ConfigKeyEncoding, &lc.encoding); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+		if lc.srid, err = configSRID(layerConf, lc.srid); err != nil {
+			return nil, fmt.Errorf("for layer (%v) %v : %v", i, layerName, err)
+		}
+
+		configured = append(configured, lc)
+	}
+	return configured, nil
+}
+
+var (
+	providersLock sync.Mutex
+	// reference to all instantiated providers
+	providers []*Provider
+)
+
+// Cleanup closes the files of all the previously instantiated providers
+func Cleanup() {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+
+	for _, p := range providers {
+		if err := p.Close(); err != nil {
+			log.Errorf("err closing shapefiles: %v", err)
+		}
+	}
+	providers = nil
+}
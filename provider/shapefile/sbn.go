@@ -0,0 +1,173 @@
+package shapefile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-spatial/tegola/internal/rtree"
+)
+
+// sbnHeaderLength is the length of the header of .sbn and .sbx files, which is laid out
+// like the header of .shp files
+const sbnHeaderLength = 100
+
+var errSbnBounds = errors.New("truncated spatial bin index")
+
+// sbnIndex is the index read from the spatial bin index written by ESRI software
+type sbnIndex struct {
+	tree *rtree.RTree
+}
+
+// Search returns the records whose extents, as rounded by the .sbn file, intersect the extent
+func (idx *sbnIndex) Search(extent rtree.Extent) []int {
+	return idx.tree.Search(extent)
+}
+
+// sbnBin is a bin of a .sbn file
+type sbnBin struct {
+	id   int
+	data []byte
+}
+
+// readSbn reads a .sbn spatial bin index, along with its .sbx file when sbx is set.
+// The header has the number of shapes at byte 28 (big endian) and the extent of the
+// shapes at byte 32 (little endian). It's followed by bins of big endian records, each
+// starting with its 1 based id and its length in 16-bit words. The first bin describes
+// the nodes of the tree of the bins, the other bins hold the shapes of the nodes: the
+// extent of each shape scaled to 0-255 within the extent of the shapes, in 4 bytes, and
+// its 1 based shape id. The .sbx file has a record of the offset and the length of each
+// bin, in 16-bit words, after its header.
+//
+// As the search only needs the extents of the shapes, the shapes of all the bins are
+// indexed in an r-tree, which doesn't depend on how the tree of the bins is split.
+func readSbn(filename, sbx string) (*sbnIndex, int, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(b) < sbnHeaderLength+8 ||
+		(binary.BigEndian.Uint32(b) != 0x270A && binary.BigEndian.Uint32(b) != 0x270D) ||
+		binary.BigEndian.Uint32(b[4:]) != 0xFFFFFE70 {
+		return nil, 0, fmt.Errorf("%v is not a spatial bin index", filename)
+	}
+	if int(binary.BigEndian.Uint32(b[24:]))*2 > len(b) {
+		return nil, 0, fmt.Errorf("reading %v: %w", filename, errSbnBounds)
+	}
+
+	count := int(int32(binary.BigEndian.Uint32(b[28:])))
+	if count < 0 {
+		return nil, 0, fmt.Errorf("%v has %v shapes", filename, count)
+	}
+	var bounds rtree.Extent
+	for i := range bounds {
+		bounds[i] = float64At(b, 32+8*i)
+	}
+	if !(bounds[0] <= bounds[2] && bounds[1] <= bounds[3]) {
+		return nil, 0, fmt.Errorf("%v has an invalid extent %v", filename, bounds)
+	}
+
+	var bins []sbnBin
+	if sbx == "" {
+		bins, err = scanSbnBins(b)
+	} else {
+		bins, err = readSbxBins(b, sbx)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading %v: %w", filename, err)
+	}
+
+	// the shapes which are not in a bin, such as null shapes, are never found
+	extents := make([]rtree.Extent, count)
+	for i := range extents {
+		extents[i] = emptyExtent
+	}
+	for _, bin := range bins[1:] {
+		for f := bin.data; len(f) >= 8; f = f[8:] {
+			id := int(int32(binary.BigEndian.Uint32(f[4:])))
+			if id < 1 || id > count {
+				return nil, 0, fmt.Errorf("reading %v: invalid shape id %v in bin %v", filename, id, bin.id)
+			}
+			extents[id-1] = sbnExtent(bounds, f[:4])
+		}
+	}
+
+	return &sbnIndex{tree: rtree.New(extents, rtree.DefaultNodeSize)}, count, nil
+}
+
+// sbnBinAt returns the bin at offset of the .sbn file
+func sbnBinAt(b []byte, offset int) (sbnBin, error) {
+	if offset < sbnHeaderLength || offset+8 > len(b) {
+		return sbnBin{}, errSbnBounds
+	}
+	bin := sbnBin{id: int(int32(binary.BigEndian.Uint32(b[offset:])))}
+	length := int(int32(binary.BigEndian.Uint32(b[offset+4:]))) * 2
+	if length < 0 || length%8 != 0 {
+		return bin, fmt.Errorf("bin %v of %v bytes", bin.id, length)
+	}
+	if offset+8+length > len(b) {
+		return bin, errSbnBounds
+	}
+	bin.data = b[offset+8 : offset+8+length]
+	return bin, nil
+}
+
+// scanSbnBins reads the bins of the .sbn file one after the other
+func scanSbnBins(b []byte) ([]sbnBin, error) {
+	var bins []sbnBin
+	for offset := sbnHeaderLength; offset < len(b); {
+		bin, err := sbnBinAt(b, offset)
+		if err != nil {
+			return nil, err
+		}
+		if bin.id != len(bins)+1 {
+			return nil, fmt.Errorf("bin %v, expected bin %v", bin.id, len(bins)+1)
+		}
+		bins = append(bins, bin)
+		offset += 8 + len(bin.data)
+	}
+	if len(bins) == 0 {
+		return nil, errSbnBounds
+	}
+	return bins, nil
+}
+
+// readSbxBins reads the bins of the .sbn file b at the offsets of the .sbx file
+func readSbxBins(b []byte, sbx string) ([]sbnBin, error) {
+	x, err := os.ReadFile(sbx)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) < sbnHeaderLength+8 || (len(x)-sbnHeaderLength)%8 != 0 {
+		return nil, fmt.Errorf("%v is not a spatial bin index", sbx)
+	}
+
+	bins := make([]sbnBin, 0, (len(x)-sbnHeaderLength)/8)
+	for r := x[sbnHeaderLength:]; len(r) >= 8; r = r[8:] {
+		offset := int(int32(binary.BigEndian.Uint32(r))) * 2
+		length := int(int32(binary.BigEndian.Uint32(r[4:]))) * 2
+
+		bin, err := sbnBinAt(b, offset)
+		if err != nil {
+			return nil, err
+		}
+		if bin.id != len(bins)+1 || len(bin.data) != length {
+			return nil, fmt.Errorf("%v doesn't match the bin %v of %v bytes at offset %v", sbx, bin.id, len(bin.data), offset)
+		}
+		bins = append(bins, bin)
+	}
+	return bins, nil
+}
+
+// sbnExtent returns the extent of a shape of a bin, scaled back from 0-255 within the
+// bounds. The extent is widened by a step, as the rounding of the scaling is unknown.
+func sbnExtent(bounds rtree.Extent, scaled []byte) rtree.Extent {
+	stepX, stepY := (bounds[2]-bounds[0])/255, (bounds[3]-bounds[1])/255
+	return rtree.Extent{
+		bounds[0] + (float64(scaled[0])-1)*stepX,
+		bounds[1] + (float64(scaled[1])-1)*stepY,
+		bounds[0] + (float64(scaled[2])+1)*stepX,
+		bounds[1] + (float64(scaled[3])+1)*stepY,
+	}
+}
@@ -0,0 +1,179 @@
+package shapefile
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-spatial/tegola/internal/rtree"
+)
+
+// sbnScale scales a coordinate to 0-255 within min and max, rounding down when floor is
+// true and up otherwise
+func sbnScale(v, min, max float64, floor bool) byte {
+	if max == min {
+		return 0
+	}
+	s := (v - min) / (max - min) * 255
+	if floor {
+		s = math.Floor(s)
+	} else {
+		s = math.Ceil(s)
+	}
+	return byte(math.Max(0, math.Min(255, s)))
+}
+
+// encodeSbn returns a .sbn index of the shapes, with all the shapes in the bins of the
+// root node, and its .sbx file
+func encodeSbn(shapes []testShape) (sbn, sbx []byte) {
+	bounds := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, s := range shapes {
+		if s.typ == shapeNull {
+			continue
+		}
+		e := s.extent()
+		bounds[0], bounds[1] = math.Min(bounds[0], e[0]), math.Min(bounds[1], e[1])
+		bounds[2], bounds[3] = math.Max(bounds[2], e[2]), math.Max(bounds[3], e[3])
+	}
+
+	var features []byte
+	var count int
+	for i, s := range shapes {
+		if s.typ == shapeNull {
+			continue
+		}
+		e := s.extent()
+		features = append(features,
+			sbnScale(e[0], bounds[0], bounds[2], true),
+			sbnScale(e[1], bounds[1], bounds[3], true),
+			sbnScale(e[2], bounds[0], bounds[2], false),
+			sbnScale(e[3], bounds[1], bounds[3], false),
+		)
+		features = binary.BigEndian.AppendUint32(features, uint32(i+1))
+		count++
+	}
+
+	// the node descriptors of the first bin: the root starts at bin 2 with all the shapes
+	descriptors := binary.BigEndian.AppendUint32(nil, 2)
+	descriptors = binary.BigEndian.AppendUint32(descriptors, uint32(count))
+
+	header := func(length int) []byte {
+		b := make([]byte, sbnHeaderLength)
+		binary.BigEndian.PutUint32(b, 0x270A)
+		binary.BigEndian.PutUint32(b[4:], 0xFFFFFE70)
+		binary.BigEndian.PutUint32(b[24:], uint32(length/2))
+		binary.BigEndian.PutUint32(b[28:], uint32(len(shapes)))
+		for i, f := range bounds {
+			binary.LittleEndian.PutUint64(b[32+8*i:], math.Float64bits(f))
+		}
+		return b
+	}
+
+	var bins []byte
+	for id, data := range [][]byte{descriptors, features} {
+		offset := sbnHeaderLength + len(bins)
+		sbx = binary.BigEndian.AppendUint32(sbx, uint32(offset/2))
+		sbx = binary.BigEndian.AppendUint32(sbx, uint32(len(data)/2))
+		bins = binary.BigEndian.AppendUint32(bins, uint32(id+1))
+		bins = binary.BigEndian.AppendUint32(bins, uint32(len(data)/2))
+		bins = append(bins, data...)
+	}
+
+	sbn = append(header(sbnHeaderLength+len(bins)), bins...)
+	sbx = append(header(sbnHeaderLength+len(sbx)), sbx...)
+	return sbn, sbx
+}
+
+func TestReadSbn(t *testing.T) {
+	type tcase struct {
+		// sbx is the .sbx file, none when nil
+		sbx []byte
+		// sbn modifies the .sbn file
+		sbn    func(b []byte) []byte
+		expErr bool
+	}
+
+	shapes := districts(t).shapes
+	sbn, sbx := encodeSbn(shapes)
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			dir := t.TempDir()
+			b := append([]byte(nil), sbn...)
+			if tc.sbn != nil {
+				b = tc.sbn(b)
+			}
+			sbnFile, sbxFile := filepath.Join(dir, "districts.sbn"), ""
+			if err := os.WriteFile(sbnFile, b, 0644); err != nil {
+				t.Fatalf("write, expected nil got %v", err)
+			}
+			if tc.sbx != nil {
+				sbxFile = filepath.Join(dir, "districts.sbx")
+				if err := os.WriteFile(sbxFile, tc.sbx, 0644); err != nil {
+					t.Fatalf("write, expected nil got %v", err)
+				}
+			}
+
+			idx, count, err := readSbn(sbnFile, sbxFile)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("expected an error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if count != len(shapes) {
+				t.Errorf("count, expected %v got %v", len(shapes), count)
+			}
+
+			// the extents are rounded to 1/255 of the extent of all the shapes, which
+			// keeps sydney apart from the others. the null shape is never found.
+			if got := idx.Search(rtree.Extent(shapes[1].extent())); !reflect.DeepEqual(got, []int{1}) {
+				t.Errorf("search sydney, expected [1] got %v", got)
+			}
+			if got := idx.Search(rtree.Extent(shapes[0].extent())); !reflect.DeepEqual(got, []int{0, 3, 4}) {
+				t.Errorf("search paris, expected [0 3 4] got %v", got)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"sbn": {},
+		"sbx": {
+			sbx: sbx,
+		},
+		"sbx not matching the bins": {
+			sbx:    append(append([]byte(nil), sbx[:len(sbx)-8]...), 0, 0, 0, 60, 0, 0, 0, 4),
+			expErr: true,
+		},
+		"not an index": {
+			sbn: func(b []byte) []byte {
+				b[3] = 0
+				return b
+			},
+			expErr: true,
+		},
+		"truncated": {
+			sbn: func(b []byte) []byte {
+				return b[:len(b)-4]
+			},
+			expErr: true,
+		},
+		"invalid shape id": {
+			sbn: func(b []byte) []byte {
+				binary.BigEndian.PutUint32(b[len(b)-4:], 6)
+				return b
+			},
+			expErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
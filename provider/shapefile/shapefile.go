@@ -0,0 +1,185 @@
+// Package shapefile implements the shapefile provider, which reads the features of ESRI
+// shapefiles (.shp, .shx, .dbf, .prj and .cpg). The features are read from disk for each
+// tile, using the .qix or .sbn index of the shapefile when present or else an index built
+// in memory.
+package shapefile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola/basic"
+	"github.com/go-spatial/tegola/internal/rtree"
+	"github.com/go-spatial/tegola/provider"
+)
+
+const Name = "shapefile"
+
+// config keys
+const (
+	ConfigKeyFilePath    = "filepath"
+	ConfigKeyEncoding    = "encoding"
+	ConfigKeySRID        = "srid"
+	ConfigKeyLayers      = "layers"
+	ConfigKeyLayerName   = "name"
+	ConfigKeyFileName    = "filename"
+	ConfigKeyGeomIDField = "id_fieldname"
+	ConfigKeyFields      = "fields"
+)
+
+type Layer struct {
+	name string
+	// filename is the path of the .shp file of the layer
+	filename    string
+	idFieldname string
+	// fields are the attributes included as tags. All the attributes are included when empty
+	fields   []string
+	geomType geom.Geometry
+	srid     uint64
+
+	shp     *os.File
+	records []shpRecord
+	index   index
+	// dbf is nil for shapefiles without attributes
+	dbf *dbf
+	// include are the attributes read from the .dbf file, the fields and id field, or nil
+	// for all
+	include map[string]bool
+}
+
+func (l Layer) Name() string            { return l.name }
+func (l Layer) GeomType() geom.Geometry { return l.geomType }
+func (l Layer) SRID() uint64            { return l.srid }
+
+type Provider struct {
+	// Filepath is the .shp file, or directory of .shp files, of the layers
+	Filepath string
+	layers   map[string]*Layer
+}
+
+func (p *Provider) Layers() ([]provider.LayerInfo, error) {
+	ls := make([]provider.LayerInfo, 0, len(p.layers))
+	for _, l := range p.layers {
+		ls = append(ls, *l)
+	}
+	return ls, nil
+}
+
+func (p *Provider) TileFeatures(ctx context.Context, layer string, tile provider.Tile, queryParams provider.Params, fn func(f *provider.Feature) error) error {
+	l, ok := p.layers[layer]
+	if !ok {
+		return ErrUnknownLayer{Name: layer}
+	}
+
+	// read the tile extent
+	tileBBox, tileSRID := tile.BufferedExtent()
+
+	// convert the tile extent to the SRID of the layer
+	if l.srid != tileSRID {
+		minGeo, err := basic.FromWebMercator(l.srid, geom.Point{tileBBox.MinX(), tileBBox.MinY()})
+		if err != nil {
+			return fmt.Errorf("error converting point: %v ", err)
+		}
+
+		maxGeo, err := basic.FromWebMercator(l.srid, geom.Point{tileBBox.MaxX(), tileBBox.MaxY()})
+		if err != nil {
+			return fmt.Errorf("error converting point: %v ", err)
+		}
+
+		tileBBox = geom.NewExtent(minGeo.(geom.Point), maxGeo.(geom.Point))
+	}
+	extent := rtree.Extent(tileBBox.Extent())
+
+	for _, i := range l.index.Search(extent) {
+		// check if the context cancelled or timed out
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		rec := l.records[i]
+		b := make([]byte, rec.length)
+		if _, err := l.shp.ReadAt(b, rec.offset); err != nil {
+			return fmt.Errorf("reading record %v of %v: %w", i+1, l.filename, err)
+		}
+		// the index may return records which are only near the tile
+		if !recordExtent(b).Intersects(extent) {
+			continue
+		}
+
+		geo, err := decodeRecord(b)
+		if err != nil {
+			return fmt.Errorf("reading record %v of %v: %w", i+1, l.filename, err)
+		}
+		if geo == nil {
+			continue
+		}
+
+		feature := provider.Feature{
+			// records are numbered from 1
+			ID:       uint64(i + 1),
+			Geometry: geo,
+			SRID:     l.srid,
+			Tags:     map[string]interface{}{},
+		}
+
+		if l.dbf != nil && i < l.dbf.count {
+			values, err := l.dbf.record(i, l.include)
+			if err != nil {
+				return fmt.Errorf("reading record %v of the attributes of %v: %w", i+1, l.filename, err)
+			}
+			// deleted records have no values
+			if values == nil {
+				continue
+			}
+
+			for name, v := range values {
+				if name == l.idFieldname {
+					if feature.ID, err = provider.ConvertFeatureID(v); err != nil {
+						return err
+					}
+					// the id field is only a tag when it's one of the fields
+					if !slices.Contains(l.fields, name) {
+						continue
+					}
+				}
+				feature.Tags[name] = v
+			}
+		}
+
+		// pass the feature to the provided call back
+		if err = fn(&feature); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes the files of the layers
+func (p *Provider) Close() error {
+	var firstErr error
+	for _, l := range p.layers {
+		if err := l.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (l *Layer) close() error {
+	var err error
+	if l.shp != nil {
+		err = l.shp.Close()
+	}
+	if l.dbf != nil {
+		if f, ok := l.dbf.r.(*os.File); ok {
+			if dbfErr := f.Close(); err == nil {
+				err = dbfErr
+			}
+		}
+	}
+	return err
+}
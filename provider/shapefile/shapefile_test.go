@@ -0,0 +1,574 @@
+package shapefile
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/provider"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// testShape is a record of a test shapefile. Points have a single part of one point and
+// null shapes have no parts.
+type testShape struct {
+	typ   int32
+	parts [][][2]float64
+}
+
+func (s testShape) extent() [4]float64 {
+	e := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, part := range s.parts {
+		for _, pt := range part {
+			e[0], e[1] = math.Min(e[0], pt[0]), math.Min(e[1], pt[1])
+			e[2], e[3] = math.Max(e[2], pt[0]), math.Max(e[3], pt[1])
+		}
+	}
+	return e
+}
+
+func (s testShape) content() []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(s.typ))
+	putFloats := func(fs ...float64) {
+		for _, f := range fs {
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+		}
+	}
+
+	switch s.typ {
+	case shapeNull:
+	case shapePoint:
+		putFloats(s.parts[0][0][0], s.parts[0][0][1])
+	default:
+		e := s.extent()
+		putFloats(e[:]...)
+		var numPoints int
+		for _, part := range s.parts {
+			numPoints += len(part)
+		}
+		// multi points have no parts
+		if s.typ != shapeMultiPoint {
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(s.parts)))
+		}
+		b = binary.LittleEndian.AppendUint32(b, uint32(numPoints))
+		if s.typ != shapeMultiPoint {
+			var start int
+			for _, part := range s.parts {
+				b = binary.LittleEndian.AppendUint32(b, uint32(start))
+				start += len(part)
+			}
+		}
+		for _, part := range s.parts {
+			for _, pt := range part {
+				putFloats(pt[0], pt[1])
+			}
+		}
+	}
+	return b
+}
+
+// testField is a field of the .dbf file of a test shapefile
+type testField struct {
+	name     string
+	typ      byte
+	length   int
+	decimals int
+}
+
+// testShapefile is written by writeShapefile. The values of the records are the encoded
+// values of the fields.
+type testShapefile struct {
+	shapeType int32
+	shapes    []testShape
+	fields    []testField
+	records   [][]string
+	deleted   map[int]bool
+	ldid      byte
+	cpg       string
+	prj       string
+	// qix writes a .qix index of the shapes
+	qix bool
+	// sbn writes a .sbn index of the shapes, and sbx its .sbx file
+	sbn, sbx bool
+}
+
+func shpHeader(shapeType int32, length int) []byte {
+	b := make([]byte, shpHeaderLength)
+	binary.BigEndian.PutUint32(b, shpFileCode)
+	binary.BigEndian.PutUint32(b[24:], uint32(length/2))
+	binary.LittleEndian.PutUint32(b[28:], 1000)
+	binary.LittleEndian.PutUint32(b[32:], uint32(shapeType))
+	return b
+}
+
+// writeShapefile writes the files of a shapefile named base in the directory
+func writeShapefile(t *testing.T, dir, base string, s testShapefile) {
+	t.Helper()
+
+	var shp, shx []byte
+	for i, shape := range s.shapes {
+		content := shape.content()
+		shx = binary.BigEndian.AppendUint32(shx, uint32((shpHeaderLength+len(shp))/2))
+		shx = binary.BigEndian.AppendUint32(shx, uint32(len(content)/2))
+		shp = binary.BigEndian.AppendUint32(shp, uint32(i+1))
+		shp = binary.BigEndian.AppendUint32(shp, uint32(len(content)/2))
+		shp = append(shp, content...)
+	}
+	shp = append(shpHeader(s.shapeType, shpHeaderLength+len(shp)), shp...)
+	shx = append(shpHeader(s.shapeType, shpHeaderLength+len(shx)), shx...)
+
+	files := map[string][]byte{".shp": shp, ".shx": shx}
+	if s.fields != nil {
+		files[".dbf"] = encodeDBF(s)
+	}
+	if s.cpg != "" {
+		files[".cpg"] = []byte(s.cpg)
+	}
+	if s.prj != "" {
+		files[".prj"] = []byte(s.prj)
+	}
+	if s.qix {
+		files[".qix"] = encodeQix(s.shapes)
+	}
+	if s.sbn {
+		sbn, sbx := encodeSbn(s.shapes)
+		files[".sbn"] = sbn
+		if s.sbx {
+			files[".sbx"] = sbx
+		}
+	}
+
+	for ext, b := range files {
+		filename := filepath.Join(dir, base+ext)
+		if err := os.WriteFile(filename, b, 0644); err != nil {
+			t.Fatalf("write %v, expected nil got %v", filename, err)
+		}
+	}
+}
+
+func encodeDBF(s testShapefile) []byte {
+	recordLength := 1
+	for _, f := range s.fields {
+		recordLength += f.length
+	}
+	headerLength := 32 + 32*len(s.fields) + 1
+
+	b := make([]byte, 32, headerLength)
+	b[0] = 0x03
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(s.records)))
+	binary.LittleEndian.PutUint16(b[8:], uint16(headerLength))
+	binary.LittleEndian.PutUint16(b[10:], uint16(recordLength))
+	b[29] = s.ldid
+
+	for _, f := range s.fields {
+		fd := make([]byte, 32)
+		copy(fd[:11], f.name)
+		fd[11] = f.typ
+		fd[16] = byte(f.length)
+		fd[17] = byte(f.decimals)
+		b = append(b, fd...)
+	}
+	b = append(b, 0x0D)
+
+	for i, values := range s.records {
+		flag := byte(' ')
+		if s.deleted[i] {
+			flag = '*'
+		}
+		b = append(b, flag)
+		for j, f := range s.fields {
+			v := make([]byte, f.length)
+			for k := range v {
+				v[k] = ' '
+			}
+			if f.typ == 'N' {
+				// numbers are right aligned
+				copy(v[f.length-len(values[j]):], values[j])
+			} else {
+				copy(v, values[j])
+			}
+			b = append(b, v...)
+		}
+	}
+	return append(b, 0x1A)
+}
+
+func encodeQix(shapes []testShape) []byte {
+	b := []byte("SQT")
+	b = append(b, 1, 1, 0, 0, 0)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(shapes)))
+	b = binary.LittleEndian.AppendUint32(b, 2)
+
+	node := func(b []byte, extent [4]float64, ids []int, children int) []byte {
+		b = binary.LittleEndian.AppendUint32(b, 0)
+		for _, f := range extent {
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+		}
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(ids)))
+		for _, id := range ids {
+			b = binary.LittleEndian.AppendUint32(b, uint32(id))
+		}
+		return binary.LittleEndian.AppendUint32(b, uint32(children))
+	}
+
+	// the root has a child node of the first shape and a child node of the others
+	b = node(b, [4]float64{-180, -90, 180, 90}, nil, 2)
+	b = node(b, shapes[0].extent(), []int{0}, 0)
+	extent := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	ids := make([]int, 0, len(shapes)-1)
+	for i, s := range shapes[1:] {
+		e := s.extent()
+		extent[0], extent[1] = math.Min(extent[0], e[0]), math.Min(extent[1], e[1])
+		extent[2], extent[3] = math.Max(extent[2], e[2]), math.Max(extent[3], e[3])
+		ids = append(ids, i+1)
+	}
+	return node(b, extent, ids, 0)
+}
+
+func gbk(t *testing.T, s string) string {
+	t.Helper()
+	encoded, err := simplifiedchinese.GBK.NewEncoder().String(s)
+	if err != nil {
+		t.Fatalf("encoding %q, expected nil got %v", s, err)
+	}
+	return encoded
+}
+
+var (
+	// paris is a polygon with a hole
+	paris = [][][2]float64{
+		{{2.3, 48.8}, {2.3, 48.9}, {2.4, 48.9}, {2.4, 48.8}, {2.3, 48.8}},
+		{{2.33, 48.83}, {2.37, 48.83}, {2.37, 48.87}, {2.33, 48.87}, {2.33, 48.83}},
+	}
+	sydney = [][][2]float64{
+		{{151.1, -33.9}, {151.1, -33.8}, {151.3, -33.8}, {151.3, -33.9}, {151.1, -33.9}},
+	}
+	// islands are two polygons
+	islands = [][][2]float64{
+		{{2.35, 48.85}, {2.35, 48.86}, {2.36, 48.86}, {2.35, 48.85}},
+		{{2.38, 48.85}, {2.38, 48.86}, {2.39, 48.86}, {2.38, 48.85}},
+	}
+)
+
+// districts returns a polygon shapefile of GBK attributes. The third record is null and
+// the fourth is deleted.
+func districts(t *testing.T) testShapefile {
+	return testShapefile{
+		shapeType: shapePolygon,
+		shapes: []testShape{
+			{typ: shapePolygon, parts: paris},
+			{typ: shapePolygon, parts: sydney},
+			{typ: shapeNull},
+			{typ: shapePolygon, parts: paris},
+			{typ: shapePolygon, parts: islands},
+		},
+		fields: []testField{
+			{name: gbk(t, "名称"), typ: 'C', length: 20},
+			{name: "POP", typ: 'N', length: 10},
+			{name: "AREA", typ: 'N', length: 10, decimals: 2},
+			{name: "CODE", typ: 'N', length: 5},
+			{name: "OPENED", typ: 'D', length: 8},
+		},
+		records: [][]string{
+			{gbk(t, "巴黎"), "2100000", "105.40", "75", "19000101"},
+			{gbk(t, "悉尼"), "5300000", "", "2000", ""},
+			{"", "", "", "0", ""},
+			{gbk(t, "已删除"), "1", "1.00", "1", ""},
+			{gbk(t, "岛屿"), "", "0.50", "76", ""},
+		},
+		deleted: map[int]bool{3: true},
+		cpg:     "GBK",
+		prj:     `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`,
+	}
+}
+
+func TestNewTileProvider(t *testing.T) {
+	type tcase struct {
+		// shapefiles are written to the directory of the provider by name
+		shapefiles map[string]testShapefile
+		config     dict.Dict
+		// layers are the expected layer names
+		layers []string
+		srid   uint64
+		expErr bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			dir := t.TempDir()
+			for name, s := range tc.shapefiles {
+				writeShapefile(t, dir, name, s)
+			}
+
+			config := dict.Dict{}
+			for k, v := range tc.config {
+				config[k] = v
+			}
+			if path, ok := config[ConfigKeyFilePath].(string); ok {
+				config[ConfigKeyFilePath] = filepath.Join(dir, path)
+			}
+
+			p, err := NewTileProvider(config, nil)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("expected an error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			defer p.(*Provider).Close()
+
+			infos, err := p.Layers()
+			if err != nil {
+				t.Fatalf("layers, expected nil got %v", err)
+			}
+			var names []string
+			for _, info := range infos {
+				names = append(names, info.Name())
+				if info.SRID() != tc.srid {
+					t.Errorf("srid of %v, expected %v got %v", info.Name(), tc.srid, info.SRID())
+				}
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tc.layers) {
+				t.Errorf("layers, expected %v got %v", tc.layers, names)
+			}
+		}
+	}
+
+	noPrj := districts(t)
+	noPrj.prj = ""
+	mercator := districts(t)
+	mercator.prj = `PROJCS["WGS_1984_Web_Mercator_Auxiliary_Sphere",GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]],PROJECTION["Mercator_Auxiliary_Sphere"],UNIT["Meter",1.0]]`
+	lambert := districts(t)
+	lambert.prj = `PROJCS["RGF93_Lambert_93",GEOGCS["GCS_RGF_1993",DATUM["D_RGF_1993",SPHEROID["GRS_1980",6378137.0,298.257222101]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]],PROJECTION["Lambert_Conformal_Conic"],UNIT["Meter",1.0]]`
+	badCPG := districts(t)
+	badCPG.cpg = "klingon"
+
+	tests := map[string]tcase{
+		"directory": {
+			shapefiles: map[string]testShapefile{"districts": districts(t), "suburbs": noPrj},
+			config:     dict.Dict{ConfigKeyFilePath: "."},
+			layers:     []string{"districts", "suburbs"},
+			srid:       tegola.WGS84,
+		},
+		"file": {
+			shapefiles: map[string]testShapefile{"districts": districts(t), "suburbs": noPrj},
+			config:     dict.Dict{ConfigKeyFilePath: "districts.shp"},
+			layers:     []string{"districts"},
+			srid:       tegola.WGS84,
+		},
+		"prj web mercator": {
+			shapefiles: map[string]testShapefile{"districts": mercator},
+			config:     dict.Dict{ConfigKeyFilePath: "."},
+			layers:     []string{"districts"},
+			srid:       tegola.WebMercator,
+		},
+		"srid overrides prj": {
+			shapefiles: map[string]testShapefile{"districts": lambert},
+			config:     dict.Dict{ConfigKeyFilePath: ".", ConfigKeySRID: 3857},
+			layers:     []string{"districts"},
+			srid:       tegola.WebMercator,
+		},
+		"layers": {
+			shapefiles: map[string]testShapefile{"districts": districts(t), "suburbs": noPrj},
+			config: dict.Dict{
+				ConfigKeyFilePath: ".",
+				ConfigKeyLayers: []map[string]interface{}{
+					{ConfigKeyLayerName: "arrondissements", ConfigKeyFileName: "districts.shp"},
+					{ConfigKeyLayerName: "suburbs", ConfigKeyFields: []string{"POP"}},
+				},
+			},
+			layers: []string{"arrondissements", "suburbs"},
+			srid:   tegola.WGS84,
+		},
+		"unsupported prj": {
+			shapefiles: map[string]testShapefile{"districts": lambert},
+			config:     dict.Dict{ConfigKeyFilePath: "."},
+			expErr:     true,
+		},
+		"unsupported srid": {
+			shapefiles: map[string]testShapefile{"districts": districts(t)},
+			config:     dict.Dict{ConfigKeyFilePath: ".", ConfigKeySRID: 2154},
+			expErr:     true,
+		},
+		"unsupported encoding": {
+			shapefiles: map[string]testShapefile{"districts": badCPG},
+			config:     dict.Dict{ConfigKeyFilePath: "."},
+			expErr:     true,
+		},
+		"unknown field": {
+			shapefiles: map[string]testShapefile{"districts": districts(t)},
+			config: dict.Dict{
+				ConfigKeyFilePath: ".",
+				ConfigKeyLayers: []map[string]interface{}{
+					{ConfigKeyLayerName: "districts", ConfigKeyGeomIDField: "ID"},
+				},
+			},
+			expErr: true,
+		},
+		"missing layer file": {
+			shapefiles: map[string]testShapefile{"districts": districts(t)},
+			config: dict.Dict{
+				ConfigKeyFilePath: ".",
+				ConfigKeyLayers: []map[string]interface{}{
+					{ConfigKeyLayerName: "roads"},
+				},
+			},
+			expErr: true,
+		},
+		"no shapefiles": {
+			config: dict.Dict{ConfigKeyFilePath: "."},
+			expErr: true,
+		},
+		"missing filepath": {
+			expErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestTileFeatures(t *testing.T) {
+	type tcase struct {
+		shapefile testShapefile
+		layers    []map[string]interface{}
+		layer     string
+		features  []provider.Feature
+		// qix and sbn are whether the .qix or .sbn index is expected to be used
+		qix, sbn bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			dir := t.TempDir()
+			writeShapefile(t, dir, "districts", tc.shapefile)
+
+			config := dict.Dict{ConfigKeyFilePath: dir}
+			if tc.layers != nil {
+				config[ConfigKeyLayers] = tc.layers
+			}
+			p, err := NewTileProvider(config, nil)
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			defer p.(*Provider).Close()
+
+			if _, ok := p.(*Provider).layers[tc.layer].index.(*qixTree); ok != tc.qix {
+				t.Errorf("qix index, expected %v got %v", tc.qix, ok)
+			}
+			if _, ok := p.(*Provider).layers[tc.layer].index.(*sbnIndex); ok != tc.sbn {
+				t.Errorf("sbn index, expected %v got %v", tc.sbn, ok)
+			}
+
+			var features []provider.Feature
+			// the tile of Paris at zoom 10
+			err = p.TileFeatures(context.Background(), tc.layer, provider.NewTile(10, 518, 352, 64, tegola.WebMercator), nil, func(f *provider.Feature) error {
+				features = append(features, *f)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("tile features, expected nil got %v", err)
+			}
+			if !reflect.DeepEqual(features, tc.features) {
+				t.Errorf("features, expected %+v got %+v", tc.features, features)
+			}
+		}
+	}
+
+	parisPolygon := geom.Polygon(paris)
+	islandsPolygons := geom.MultiPolygon{{islands[0]}, {islands[1]}}
+	allFeatures := []provider.Feature{
+		{
+			ID:       1,
+			Geometry: parisPolygon,
+			SRID:     tegola.WGS84,
+			Tags:     map[string]interface{}{"名称": "巴黎", "POP": int64(2100000), "AREA": 105.4, "CODE": int64(75), "OPENED": "1900-01-01"},
+		},
+		{
+			ID:       5,
+			Geometry: islandsPolygons,
+			SRID:     tegola.WGS84,
+			Tags:     map[string]interface{}{"名称": "岛屿", "AREA": 0.5, "CODE": int64(76)},
+		},
+	}
+
+	ldid := districts(t)
+	ldid.cpg = ""
+	ldid.ldid = 0x4D
+	indexed := districts(t)
+	indexed.qix = true
+	sbnIndexed := districts(t)
+	sbnIndexed.sbn, sbnIndexed.sbx = true, true
+	sbnOnly := districts(t)
+	sbnOnly.sbn = true
+	noDBF := districts(t)
+	noDBF.fields = nil
+
+	tests := map[string]tcase{
+		"cpg": {
+			shapefile: districts(t),
+			layer:     "districts",
+			features:  allFeatures,
+		},
+		"language driver id": {
+			shapefile: ldid,
+			layer:     "districts",
+			features:  allFeatures,
+		},
+		"qix": {
+			shapefile: indexed,
+			layer:     "districts",
+			features:  allFeatures,
+			qix:       true,
+		},
+		"sbn": {
+			shapefile: sbnIndexed,
+			layer:     "districts",
+			features:  allFeatures,
+			sbn:       true,
+		},
+		"sbn without sbx": {
+			shapefile: sbnOnly,
+			layer:     "districts",
+			features:  allFeatures,
+			sbn:       true,
+		},
+		"id and fields": {
+			shapefile: districts(t),
+			layers: []map[string]interface{}{
+				{ConfigKeyLayerName: "names", ConfigKeyFileName: "districts.shp", ConfigKeyGeomIDField: "CODE", ConfigKeyFields: []string{"名称"}},
+			},
+			layer: "names",
+			features: []provider.Feature{
+				{ID: 75, Geometry: parisPolygon, SRID: tegola.WGS84, Tags: map[string]interface{}{"名称": "巴黎"}},
+				{ID: 76, Geometry: islandsPolygons, SRID: tegola.WGS84, Tags: map[string]interface{}{"名称": "岛屿"}},
+			},
+		},
+		"no attributes": {
+			shapefile: noDBF,
+			layer:     "districts",
+			features: []provider.Feature{
+				{ID: 1, Geometry: parisPolygon, SRID: tegola.WGS84, Tags: map[string]interface{}{}},
+				{ID: 4, Geometry: parisPolygon, SRID: tegola.WGS84, Tags: map[string]interface{}{}},
+				{ID: 5, Geometry: islandsPolygons, SRID: tegola.WGS84, Tags: map[string]interface{}{}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
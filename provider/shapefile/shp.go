@@ -0,0 +1,310 @@
+package shapefile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/tegola/internal/rtree"
+)
+
+// shape types
+const (
+	shapeNull        = 0
+	shapePoint       = 1
+	shapePolyLine    = 3
+	shapePolygon     = 5
+	shapeMultiPoint  = 8
+	shapePointZ      = 11
+	shapePolyLineZ   = 13
+	shapePolygonZ    = 15
+	shapeMultiPointZ = 18
+	shapePointM      = 21
+	shapePolyLineM   = 23
+	shapePolygonM    = 25
+	shapeMultiPointM = 28
+)
+
+// shpFileCode is the file code at the start of .shp and .shx files
+const shpFileCode = 9994
+
+// shpHeaderLength is the length of the header of .shp and .shx files
+const shpHeaderLength = 100
+
+// baseShapeType maps the Z and M shape types to the 2D type. Only the 2D coordinates
+// are read.
+func baseShapeType(typ int32) int32 {
+	switch typ {
+	case shapePointZ, shapePointM:
+		return shapePoint
+	case shapePolyLineZ, shapePolyLineM:
+		return shapePolyLine
+	case shapePolygonZ, shapePolygonM:
+		return shapePolygon
+	case shapeMultiPointZ, shapeMultiPointM:
+		return shapeMultiPoint
+	default:
+		return typ
+	}
+}
+
+// shapeGeomType is the geometry type of the layer of a shape type. Polygons and lines
+// are multi geometries as their records may have several parts.
+func shapeGeomType(typ int32) (geom.Geometry, error) {
+	switch baseShapeType(typ) {
+	case shapePoint:
+		return geom.Point{}, nil
+	case shapeMultiPoint:
+		return geom.MultiPoint{}, nil
+	case shapePolyLine:
+		return geom.MultiLineString{}, nil
+	case shapePolygon:
+		return geom.MultiPolygon{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported shape type %v", typ)
+	}
+}
+
+// readShpHeader reads the shape type of a .shp or .shx file
+func readShpHeader(r io.ReaderAt) (shapeType int32, err error) {
+	b := make([]byte, shpHeaderLength)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		return 0, fmt.Errorf("reading header: %w", err)
+	}
+	if binary.BigEndian.Uint32(b) != shpFileCode {
+		return 0, errors.New("not a shapefile")
+	}
+	return int32(binary.LittleEndian.Uint32(b[32:])), nil
+}
+
+// shpRecord is the position of a record of the .shp file
+type shpRecord struct {
+	// offset is the offset of the record content, past the record header
+	offset int64
+	length int32
+}
+
+// readShx reads the positions of the records of the .shp file from its .shx index
+func readShx(filename string) ([]shpRecord, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < shpHeaderLength || binary.BigEndian.Uint32(b) != shpFileCode {
+		return nil, fmt.Errorf("%v is not a shapefile index", filename)
+	}
+
+	b = b[shpHeaderLength:]
+	records := make([]shpRecord, len(b)/8)
+	for i := range records {
+		// offsets and lengths are in 16-bit words
+		records[i] = shpRecord{
+			offset: int64(binary.BigEndian.Uint32(b[8*i:]))*2 + 8,
+			length: int32(binary.BigEndian.Uint32(b[8*i+4:])) * 2,
+		}
+	}
+	return records, nil
+}
+
+// scanShpExtents reads the extent of each record of the .shp file. Null shapes have an
+// empty extent which intersects nothing.
+func scanShpExtents(filename string, count int) ([]rtree.Extent, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1<<16)
+	if _, err := r.Discard(shpHeaderLength); err != nil {
+		return nil, err
+	}
+
+	extents := make([]rtree.Extent, 0, count)
+	header := make([]byte, 8)
+	for i := 0; i < count; i++ {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, fmt.Errorf("record %v: %w", i+1, err)
+		}
+		content := make([]byte, int(binary.BigEndian.Uint32(header[4:]))*2)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, fmt.Errorf("record %v: %w", i+1, err)
+		}
+		extents = append(extents, recordExtent(content))
+	}
+	return extents, nil
+}
+
+// emptyExtent intersects no extent
+var emptyExtent = rtree.Extent{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+
+// recordExtent returns the extent of the content of a record
+func recordExtent(b []byte) rtree.Extent {
+	if len(b) < 4 {
+		return emptyExtent
+	}
+
+	switch baseShapeType(int32(binary.LittleEndian.Uint32(b))) {
+	case shapePoint:
+		if len(b) < 20 {
+			return emptyExtent
+		}
+		x, y := float64At(b, 4), float64At(b, 12)
+		return rtree.Extent{x, y, x, y}
+	case shapePolyLine, shapePolygon, shapeMultiPoint:
+		if len(b) < 36 {
+			return emptyExtent
+		}
+		return rtree.Extent{float64At(b, 4), float64At(b, 12), float64At(b, 20), float64At(b, 28)}
+	default:
+		return emptyExtent
+	}
+}
+
+func float64At(b []byte, pos int) float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(b[pos:]))
+}
+
+// decodeRecord decodes the geometry of the content of a record. Null shapes have a nil
+// geometry.
+func decodeRecord(b []byte) (geom.Geometry, error) {
+	if len(b) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	typ := int32(binary.LittleEndian.Uint32(b))
+	switch baseShapeType(typ) {
+	case shapeNull:
+		return nil, nil
+
+	case shapePoint:
+		if len(b) < 20 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return geom.Point{float64At(b, 4), float64At(b, 12)}, nil
+
+	case shapeMultiPoint:
+		if len(b) < 40 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		n := int(binary.LittleEndian.Uint32(b[36:]))
+		points, err := readPoints(b, 40, n)
+		return geom.MultiPoint(points), err
+
+	case shapePolyLine, shapePolygon:
+		if len(b) < 44 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		numParts := int(binary.LittleEndian.Uint32(b[36:]))
+		numPoints := int(binary.LittleEndian.Uint32(b[40:]))
+		if numParts < 0 || 44+4*numParts > len(b) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		points, err := readPoints(b, 44+4*numParts, numPoints)
+		if err != nil {
+			return nil, err
+		}
+
+		parts := make([][][2]float64, numParts)
+		for i := range parts {
+			start := int(binary.LittleEndian.Uint32(b[44+4*i:]))
+			end := numPoints
+			if i+1 < numParts {
+				end = int(binary.LittleEndian.Uint32(b[44+4*(i+1):]))
+			}
+			if start < 0 || start > end || end > numPoints {
+				return nil, fmt.Errorf("invalid part %v", i)
+			}
+			parts[i] = points[start:end]
+		}
+
+		if baseShapeType(typ) == shapePolyLine {
+			if len(parts) == 1 {
+				return geom.LineString(parts[0]), nil
+			}
+			return geom.MultiLineString(parts), nil
+		}
+		return ringsToPolygons(parts), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported shape type %v", typ)
+	}
+}
+
+func readPoints(b []byte, pos, n int) ([][2]float64, error) {
+	if n < 0 || pos+16*n > len(b) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	points := make([][2]float64, n)
+	for i := range points {
+		points[i] = [2]float64{float64At(b, pos+16*i), float64At(b, pos+16*i+8)}
+	}
+	return points, nil
+}
+
+// ringsToPolygons groups the rings of a polygon record into polygons. Outer rings are
+// clockwise and holes are counter clockwise. A hole belongs to the outer ring which
+// contains it, or else to the preceding outer ring.
+func ringsToPolygons(rings [][][2]float64) geom.Geometry {
+	var polygons [][][][2]float64
+	var holes [][][2]float64
+
+	for _, ring := range rings {
+		if len(ring) == 0 {
+			continue
+		}
+		if signedArea(ring) <= 0 {
+			polygons = append(polygons, [][][2]float64{ring})
+			continue
+		}
+		if len(polygons) == 0 {
+			// a hole without an outer ring is an outer ring wound the wrong way
+			polygons = append(polygons, [][][2]float64{ring})
+			continue
+		}
+		holes = append(holes, ring)
+	}
+
+HoleLoop:
+	for _, hole := range holes {
+		for i := range polygons {
+			if ringContains(polygons[i][0], hole[0]) {
+				polygons[i] = append(polygons[i], hole)
+				continue HoleLoop
+			}
+		}
+		polygons[len(polygons)-1] = append(polygons[len(polygons)-1], hole)
+	}
+
+	if len(polygons) == 1 {
+		return geom.Polygon(polygons[0])
+	}
+	return geom.MultiPolygon(polygons)
+}
+
+// signedArea is positive for counter clockwise rings
+func signedArea(ring [][2]float64) float64 {
+	var a float64
+	for i := range ring {
+		j := (i + 1) % len(ring)
+		a += ring[i][0]*ring[j][1] - ring[j][0]*ring[i][1]
+	}
+	return a / 2
+}
+
+// ringContains reports whether the point is inside the ring, using ray casting
+func ringContains(ring [][2]float64, pt [2]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > pt[1]) != (b[1] > pt[1]) && pt[0] < (b[0]-a[0])*(pt[1]-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
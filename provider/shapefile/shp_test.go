@@ -0,0 +1,84 @@
+package shapefile
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-spatial/geom"
+)
+
+func TestDecodeRecord(t *testing.T) {
+	type tcase struct {
+		shape  testShape
+		geom   geom.Geometry
+		expErr bool
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			content := tc.shape.content()
+			if tc.expErr {
+				content = content[:len(content)-4]
+			}
+
+			g, err := decodeRecord(content)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("expected an error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if !reflect.DeepEqual(g, tc.geom) {
+				t.Errorf("expected %v got %v", tc.geom, g)
+			}
+		}
+	}
+
+	// a hole of the second polygon, listed after both outer rings
+	hole := [][2]float64{{5.5, 5.5}, {6, 5.5}, {6, 6}, {5.5, 5.5}}
+	outers := [][][2]float64{
+		{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}},
+		{{5, 5}, {5, 7}, {7, 7}, {7, 5}, {5, 5}},
+	}
+
+	tests := map[string]tcase{
+		"null": {
+			shape: testShape{typ: shapeNull},
+		},
+		"point": {
+			shape: testShape{typ: shapePoint, parts: [][][2]float64{{{2.35, 48.85}}}},
+			geom:  geom.Point{2.35, 48.85},
+		},
+		"multi point": {
+			shape: testShape{typ: shapeMultiPoint, parts: [][][2]float64{{{0, 1}, {2, 3}}}},
+			geom:  geom.MultiPoint{{0, 1}, {2, 3}},
+		},
+		"line": {
+			shape: testShape{typ: shapePolyLine, parts: [][][2]float64{{{0, 0}, {1, 1}}}},
+			geom:  geom.LineString{{0, 0}, {1, 1}},
+		},
+		"multi line": {
+			shape: testShape{typ: shapePolyLine, parts: [][][2]float64{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}}}},
+			geom:  geom.MultiLineString{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}}},
+		},
+		"polygon with hole": {
+			shape: testShape{typ: shapePolygon, parts: paris},
+			geom:  geom.Polygon(paris),
+		},
+		"hole of the containing polygon": {
+			shape: testShape{typ: shapePolygon, parts: [][][2]float64{outers[0], outers[1], hole}},
+			geom:  geom.MultiPolygon{{outers[0]}, {outers[1], hole}},
+		},
+		"truncated": {
+			shape:  testShape{typ: shapePolygon, parts: paris},
+			expErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
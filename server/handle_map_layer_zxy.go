@@ -217,6 +217,10 @@ func (req HandleMapLayerZXY) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// mimetype for mapbox vector tiles
 	// https://www.iana.org/assignments/media-types/application/vnd.mapbox-vector-tile
 	w.Header().Add("Content-Type", mvt.MimeType)
+	// the tiles of live layers are not cached by clients either
+	if !m.Cacheable() {
+		w.Header().Add("Cache-Control", "no-cache, no-store, must-revalidate")
+	}
 	w.Header().Add("Content-Length", fmt.Sprintf("%d", len(pbyte)))
 	w.WriteHeader(http.StatusOK)
 
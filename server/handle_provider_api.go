@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dimfeld/httptreemux"
+
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/internal/log"
+)
+
+type HandleProviderAPI struct {
+	// the Atlas to use, nil (default) is the default atlas
+	Atlas *atlas.Atlas
+}
+
+// routes the requests to the api of a provider, such as the write api of the inmemory
+// provider. The provider must be the provider of a layer of a map, and implement
+// http.Handler.
+//
+// URI scheme: /providers/:provider_name/*path
+//
+//	provider_name - provider name in the config file
+//	path - the path of the request in the api of the provider
+func (req HandleProviderAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := httptreemux.ContextParams(r.Context())
+	name := params["provider_name"]
+
+	h := providerHandler(req.Atlas, name)
+	if h == nil {
+		msg := fmt.Sprintf("provider (%v) not configured or has no api", name)
+		log.Debug(msg)
+		http.Error(w, msg, http.StatusNotFound)
+		return
+	}
+
+	// the provider api is served relative to the route of the provider
+	r = r.Clone(r.Context())
+	r.URL.Path = "/" + params["path"]
+	r.URL.RawPath = ""
+	h.ServeHTTP(w, r)
+}
+
+// providerHandler returns the provider of the layers of the maps with the name if it
+// serves an api, otherwise nil
+func providerHandler(a *atlas.Atlas, name string) http.Handler {
+	for _, m := range a.AllMaps() {
+		for _, l := range m.Layers {
+			if l.ProviderName != name {
+				continue
+			}
+			h, _ := l.Provider.(http.Handler)
+			return h
+		}
+	}
+	return nil
+}
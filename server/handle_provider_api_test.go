@@ -0,0 +1,107 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-spatial/geom"
+
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/cache/memory"
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/provider/inmemory"
+	"github.com/go-spatial/tegola/server"
+)
+
+func TestHandleProviderAPI(t *testing.T) {
+	live, err := inmemory.NewTileProvider(dict.Dict{
+		inmemory.ConfigKeyWriteToken: "secret",
+		inmemory.ConfigKeyLayers:     []map[string]interface{}{{"name": "vehicles"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("new provider, expected nil got %v", err)
+	}
+
+	a := newTestMapWithLayers(atlas.Layer{
+		Name:              "vehicles",
+		ProviderLayerName: "vehicles",
+		ProviderName:      "live",
+		MinZoom:           0,
+		MaxZoom:           20,
+		Provider:          live,
+		GeomType:          geom.Point{},
+	})
+	cacher, _ := memory.New(nil)
+	a.SetCache(cacher)
+
+	type tcase struct {
+		method   string
+		uri      string
+		token    string
+		body     string
+		expected int
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			r, err := http.NewRequest(tc.method, tc.uri, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("new request, expected nil got %v", err)
+			}
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			w := httptest.NewRecorder()
+			server.NewRouter(a).ServeHTTP(w, r)
+			if w.Code != tc.expected {
+				t.Errorf("status, expected %v got %v: %v", tc.expected, w.Code, w.Body.String())
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"upsert": {
+			method:   http.MethodPost,
+			uri:      "/providers/live/layers/vehicles/features",
+			token:    "secret",
+			body:     `{"type": "Feature", "id": 1, "geometry": {"type": "Point", "coordinates": [2.35, 48.85]}}`,
+			expected: http.StatusNoContent,
+		},
+		"unauthorized": {
+			method:   http.MethodPost,
+			uri:      "/providers/live/layers/vehicles/features",
+			body:     `{"type": "Feature", "id": 1, "geometry": {"type": "Point", "coordinates": [2.35, 48.85]}}`,
+			expected: http.StatusUnauthorized,
+		},
+		"provider without api": {
+			method:   http.MethodDelete,
+			uri:      "/providers/test/layers/vehicles/features",
+			token:    "secret",
+			expected: http.StatusNotFound,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+
+	// the tiles of live layers are not cached
+	for i := 0; i < 2; i++ {
+		w, _, err := doRequest(t, a, http.MethodGet, "/maps/test-map/1/1/0.pbf", nil)
+		if err != nil {
+			t.Fatalf("request, expected nil got %v", err)
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("status, expected %v got %v", http.StatusOK, w.Code)
+		}
+		if got := w.Header().Get("Tegola-Cache"); got != "" {
+			t.Errorf("header Tegola-Cache, expected none got %v", got)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "no-cache, no-store, must-revalidate" {
+			t.Errorf("header Cache-Control, expected no-cache, no-store, must-revalidate got %v", got)
+		}
+	}
+}
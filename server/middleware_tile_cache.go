@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"path"
 	"strings"
 
@@ -28,12 +29,6 @@ func TileCacheHandler(a *atlas.Atlas, next http.Handler) http.Handler {
 			return
 		}
 
-		// ignore requests with query parameters
-		if r.URL.RawQuery != "" {
-			next.ServeHTTP(w, r)
-			return
-		}
-
 		// parse our URI into a cache key structure (remove any configured URIPrefix + "maps/" )
 		key, err := cache.ParseKey(strings.TrimPrefix(r.URL.Path, path.Join(URIPrefix, "maps")))
 		if err != nil {
@@ -42,6 +37,36 @@ func TileCacheHandler(a *atlas.Atlas, next http.Handler) http.Handler {
 			return
 		}
 
+		m, mapErr := a.Map(key.MapName)
+		// when cache key versioning is on, tiles are keyed by the current map config version
+		if mapErr == nil {
+			key.Version = a.KeyVersion(m)
+		}
+
+		// the tiles of live layers are not cached
+		if mapErr == nil && !tileMap(m, key.LayerName).Cacheable() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// requests with query parameters are keyed by the decoded parameter values. requests
+		// with other query strings (i.e. debug) are ignored. the tiles of parameters without
+		// caching turned on are read from the cache, as they may have been seeded, but are
+		// not written to it, so the clients can't grow the cache with arbitrary values.
+		store := true
+		if r.URL.RawQuery != "" {
+			values, ok := queryParamValues(m, r.URL.Query())
+			if mapErr != nil || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if key.Params, err = m.ParamsKey(values); err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			store = key.Params == "" || cachedParams(m, values)
+		}
+
 		// use the URL path as the key
 		cachedTile, hit, err := cacher.Get(r.Context(), key)
 		if err != nil {
@@ -50,6 +75,11 @@ func TileCacheHandler(a *atlas.Atlas, next http.Handler) http.Handler {
 			return
 		}
 
+		if !hit && !store {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// cache miss
 		if !hit {
 			// buffer which will hold a copy of the response for writing to the cache
@@ -76,6 +106,14 @@ func TileCacheHandler(a *atlas.Atlas, next http.Handler) http.Handler {
 			return
 		}
 
+		// tiles without features seeded as a sentinel have no content
+		if cache.IsEmptyTile(cachedTile) {
+			w.Header().Del("Content-Encoding")
+			w.Header().Add("Tegola-Cache", "HIT")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
 		// mimetype for mapbox vector tiles
 		w.Header().Add("Content-Type", mvt.MimeType)
 
@@ -88,6 +126,38 @@ func TileCacheHandler(a *atlas.Atlas, next http.Handler) http.Handler {
 	})
 }
 
+// tileMap returns the map with the layers of a tile of the map, which are all the
+// layers of the map or those with the layer name
+func tileMap(m atlas.Map, layerName string) atlas.Map {
+	if layerName == "" {
+		return m
+	}
+	return m.FilterLayersByName(layerName)
+}
+
+// queryParamValues returns the values of the query string by name. ok is false
+// when the query string has a value which is not a query parameter of the map.
+func queryParamValues(m atlas.Map, query url.Values) (values map[string]string, ok bool) {
+	values = make(map[string]string, len(query))
+	for name := range query {
+		if !m.HasParam(name) {
+			return nil, false
+		}
+		values[name] = query.Get(name)
+	}
+	return values, true
+}
+
+// cachedParams reports if caching is turned on for all the parameters of values
+func cachedParams(m atlas.Map, values map[string]string) bool {
+	for _, param := range m.Params {
+		if _, ok := values[param.Name]; ok && !param.Cache {
+			return false
+		}
+	}
+	return true
+}
+
 func newTileCacheResponseWriter(resp http.ResponseWriter, w io.Writer) http.ResponseWriter {
 	return &tileCacheResponseWriter{
 		resp:  resp,
@@ -1,11 +1,15 @@
 package server_test
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-spatial/tegola/atlas"
+	"github.com/go-spatial/tegola/cache"
 	"github.com/go-spatial/tegola/cache/memory"
+	"github.com/go-spatial/tegola/provider"
 	"github.com/go-spatial/tegola/server"
 )
 
@@ -139,3 +143,95 @@ func TestMiddlewareTileCacheHandlerIgnoreParams(t *testing.T) {
 		t.Run(name, fn(tc))
 	}
 }
+
+func TestMiddlewareTileCacheHandlerParams(t *testing.T) {
+	type tcase struct {
+		uri      string
+		cache    bool
+		expected []string
+	}
+
+	fn := func(tc tcase) func(t *testing.T) {
+		return func(t *testing.T) {
+			server.URIPrefix = "/"
+
+			testMap := atlas.NewWebMercatorMap(testMapName)
+			testMap.Layers = append(testMap.Layers, testLayer1, testLayer2, testLayer3)
+			testMap.Params = []provider.QueryParameter{
+				{Name: "count", Token: "!COUNT!", Type: "int", SQL: "?", DefaultValue: "1", Cache: tc.cache},
+			}
+
+			a := &atlas.Atlas{}
+			a.AddMap(testMap)
+			cacher, _ := memory.New(nil)
+			a.SetCache(cacher)
+
+			for i, expected := range tc.expected {
+				w, _, err := doRequest(t, a, http.MethodGet, tc.uri, nil)
+				if err != nil {
+					t.Fatalf("error making request, expected nil got %v", err)
+				}
+				if got := w.Header().Get("Tegola-Cache"); got != expected {
+					t.Errorf("request %v header Tegola-Cache, expected %q got %q", i, expected, got)
+				}
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"param": {
+			uri:      "/maps/test-map/10/2/3.pbf?count=5",
+			cache:    true,
+			expected: []string{"MISS", "HIT"},
+		},
+		"layer param": {
+			uri:      "/maps/test-map/test-layer/10/2/3.pbf?count=5",
+			cache:    true,
+			expected: []string{"MISS", "HIT"},
+		},
+		"param without caching": {
+			uri:      "/maps/test-map/10/2/3.pbf?count=5",
+			expected: []string{"", ""},
+		},
+		"default param value without caching": {
+			uri:      "/maps/test-map/10/2/3.pbf?count=01",
+			expected: []string{"MISS", "HIT"},
+		},
+		"unknown param": {
+			uri:      "/maps/test-map/10/2/3.pbf?count=5&debug=true",
+			cache:    true,
+			expected: []string{"", ""},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestMiddlewareTileCacheHandlerEmptyTile(t *testing.T) {
+	server.URIPrefix = "/"
+
+	a := newTestMapWithLayers(testLayer1, testLayer2, testLayer3)
+	cacher, _ := memory.New(nil)
+	a.SetCache(cacher)
+
+	key := cache.Key{MapName: testMapName, Z: 10, X: 2, Y: 3}
+	if err := cacher.Set(context.Background(), &key, cache.EmptyTile); err != nil {
+		t.Fatalf("set, expected nil got %v", err)
+	}
+
+	w, _, err := doRequest(t, a, http.MethodGet, "/maps/test-map/10/2/3.pbf", nil)
+	if err != nil {
+		t.Fatalf("error making request, expected nil got %v", err)
+	}
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status, expected %v got %v", http.StatusNoContent, w.Code)
+	}
+	if got := w.Header().Get("Tegola-Cache"); got != "HIT" {
+		t.Errorf("header Tegola-Cache, expected HIT got %v", got)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body, expected empty got %v bytes", w.Body.Len())
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-spatial/tegola/tilestats"
+)
+
+// TileStats records the number of requests of each tile when set.
+// configurable via the tegola config.toml file (set in main.go)
+var TileStats tilestats.Store
+
+// TileStatsHandler is middleware recording the successful tile requests to TileStats.
+// Requests of a single layer are counted as requests of the map tile, and requests
+// of empty tiles answered with no content are counted too.
+func TileStatsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		store := TileStats
+		if store == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		if sw.status != http.StatusOK && sw.status != http.StatusNoContent {
+			return
+		}
+
+		var req HandleMapLayerZXY
+		if err := req.parseURI(r); err != nil {
+			return
+		}
+		store.Record(req.mapName, req.z, req.x, req.y)
+	})
+}
+
+// statusResponseWriter keeps track of the status code of the response
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,57 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/server"
+	"github.com/go-spatial/tegola/tilestats"
+)
+
+func TestMiddlewareTileStatsHandler(t *testing.T) {
+	store, err := tilestats.New(dict.Dict{"type": "file", "path": filepath.Join(t.TempDir(), "tiles.bin")})
+	if err != nil {
+		t.Fatalf("new tile stats, expected nil got %v", err)
+	}
+	defer store.Close()
+
+	server.URIPrefix = "/"
+	server.TileStats = store
+	defer func() { server.TileStats = nil }()
+
+	a := newTestMapWithLayers(testLayer1, testLayer2, testLayer3)
+
+	for _, uri := range []string{
+		"/maps/test-map/10/2/3.pbf",
+		"/maps/test-map/10/2/3.pbf",
+		"/maps/test-map/test-layer/10/2/3.pbf",
+		"/maps/test-map/4/2/3.pbf",
+		// not counted
+		"/maps/missing-map/4/2/3.pbf",
+		"/maps/test-map/4/200/3.pbf",
+	} {
+		if _, _, err := doRequest(t, a, http.MethodGet, uri, nil); err != nil {
+			t.Fatalf("error making request, expected nil got %v", err)
+		}
+	}
+
+	got, err := store.Top(context.Background(), "test-map", 0, 0)
+	if err != nil {
+		t.Fatalf("top, expected nil got %v", err)
+	}
+	expected := []tilestats.TileCount{
+		{Z: 10, X: 2, Y: 3, Hits: 3},
+		{Z: 4, X: 2, Y: 3, Hits: 1},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("top, expected %v got %v", expected, got)
+	}
+
+	if got, _ = store.Top(context.Background(), "missing-map", 0, 0); len(got) != 0 {
+		t.Errorf("top of missing map, expected no tiles got %v", got)
+	}
+}
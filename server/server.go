@@ -93,14 +93,21 @@ func NewRouter(a *atlas.Atlas) *httptreemux.TreeMux {
 	// map tiles
 	hMapLayerZXY := HandleMapLayerZXY{Atlas: a}
 	group.UsingContext().
-		Handler(observability.InstrumentAPIHandler(http.MethodGet, "/maps/:map_name/:z/:x/:y", o, HeadersHandler(GZipHandler(TileCacheHandler(a, hMapLayerZXY)))))
+		Handler(observability.InstrumentAPIHandler(http.MethodGet, "/maps/:map_name/:z/:x/:y", o, HeadersHandler(TileStatsHandler(GZipHandler(TileCacheHandler(a, hMapLayerZXY))))))
 	group.UsingContext().
-		Handler(observability.InstrumentAPIHandler(http.MethodGet, "/maps/:map_name/:layer_name/:z/:x/:y", o, HeadersHandler(GZipHandler(TileCacheHandler(a, hMapLayerZXY)))))
+		Handler(observability.InstrumentAPIHandler(http.MethodGet, "/maps/:map_name/:layer_name/:z/:x/:y", o, HeadersHandler(TileStatsHandler(GZipHandler(TileCacheHandler(a, hMapLayerZXY))))))
 
 	// map style
 	group.UsingContext().
 		Handler(observability.InstrumentAPIHandler(http.MethodGet, "/maps/:map_name/style.json", o, HeadersHandler(HandleMapStyle{})))
 
+	// provider apis, such as the write api of the inmemory provider
+	hProviderAPI := HandleProviderAPI{Atlas: a}
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		group.UsingContext().
+			Handler(observability.InstrumentAPIHandler(method, "/providers/:provider_name/*path", o, hProviderAPI))
+	}
+
 	// setup viewer routes, which can be excluded via build flags
 	setupViewer(o, group)
 
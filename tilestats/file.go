@@ -0,0 +1,230 @@
+package tilestats
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-spatial/tegola/dict"
+)
+
+const ConfigKeyPath = "path"
+
+var ErrMissingPath = errors.New("tilestats: missing required param 'path'")
+
+// fileMagic starts the stats files, followed by the format version
+var fileMagic = []byte("TGTS\x01")
+
+// minCompactSize is the size the file grows to before it's first compacted
+const minCompactSize = 1 << 20
+
+// fileBackend stores the counts in a compact binary file. Each tile is a record of
+// uvarints: the length of the map name followed by the map name, then z, x, y and hits.
+// Flushes append their counts to the file, so a tile can have several records which
+// add up. The file is compacted to a record per tile once it has doubled in size
+// since the last compaction.
+type fileBackend struct {
+	// mu serializes the writes of the file
+	mu   sync.Mutex
+	path string
+	// compactedSize is the size of the file after the last compaction
+	compactedSize int64
+}
+
+func newFileBackend(config dict.Dicter) (*fileBackend, error) {
+	path, err := config.String(ConfigKeyPath, nil)
+	if err != nil || path == "" {
+		return nil, ErrMissingPath
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	fb := &fileBackend{path: path}
+	if info, err := os.Stat(path); err == nil {
+		fb.compactedSize = info.Size()
+	}
+	return fb, nil
+}
+
+// read calls fn for each tile of the file. A missing file has no tiles.
+func (fb *fileBackend) read(fn func(k tileKey, hits uint64)) error {
+	f, err := os.Open(fb.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	magic := make([]byte, len(fileMagic))
+	if _, err = io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, fileMagic) {
+		return fmt.Errorf("tilestats: %v is not a tile stats file", fb.path)
+	}
+
+	// a record cut short by an interrupted append ends the file
+	var v [5]uint64
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("tilestats: reading %v: %w", fb.path, err)
+		}
+
+		name := make([]byte, n)
+		if _, err = io.ReadFull(r, name); err != nil {
+			if err == io.ErrUnexpectedEOF || err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("tilestats: reading %v: %w", fb.path, err)
+		}
+		for i := 0; i < 4; i++ {
+			if v[i], err = binary.ReadUvarint(r); err != nil {
+				if err == io.ErrUnexpectedEOF || err == io.EOF {
+					return nil
+				}
+				return fmt.Errorf("tilestats: reading %v: %w", fb.path, err)
+			}
+		}
+
+		fn(tileKey{mapName: string(name), z: uint(v[0]), x: uint(v[1]), y: uint(v[2])}, v[3])
+	}
+}
+
+// merge appends the counts to the file, and compacts it once it has doubled in size
+func (fb *fileBackend) merge(_ context.Context, counts map[tileKey]uint64) error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	f, err := os.OpenFile(fb.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	if info.Size() == 0 {
+		w.Write(fileMagic)
+	}
+	writeRecords(w, counts)
+
+	if err = w.Flush(); err != nil {
+		// drop the partial records, so the next appends are read
+		f.Truncate(info.Size())
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if info, err = os.Stat(fb.path); err != nil {
+		return err
+	}
+	if info.Size() < 2*fb.compactedSize || info.Size() < minCompactSize {
+		return nil
+	}
+	return fb.compact()
+}
+
+// compact rewrites the file with a single record per tile
+func (fb *fileBackend) compact() error {
+	merged := map[tileKey]uint64{}
+	err := fb.read(func(k tileKey, hits uint64) {
+		merged[k] += hits
+	})
+	if err != nil {
+		return err
+	}
+
+	// write to a temp file which replaces the stats file, so readers never see a partial file
+	tmp := fb.path + "-tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	w.Write(fileMagic)
+	writeRecords(w, merged)
+
+	if err = w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = os.Rename(tmp, fb.path); err != nil {
+		return err
+	}
+	fb.compactedSize = info.Size()
+	return nil
+}
+
+// writeRecords writes a record per tile of counts
+func writeRecords(w *bufio.Writer, counts map[tileKey]uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	put := func(v uint64) {
+		w.Write(buf[:binary.PutUvarint(buf, v)])
+	}
+	for k, hits := range counts {
+		put(uint64(len(k.mapName)))
+		w.WriteString(k.mapName)
+		put(uint64(k.z))
+		put(uint64(k.x))
+		put(uint64(k.y))
+		put(hits)
+	}
+}
+
+func (fb *fileBackend) top(_ context.Context, mapName string, n int, minHits uint64) ([]TileCount, error) {
+	// the records of a tile add up
+	hits := map[tileKey]uint64{}
+	err := fb.read(func(k tileKey, n uint64) {
+		if k.mapName == mapName {
+			hits[k] += n
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []TileCount
+	for k, n := range hits {
+		if n < minHits {
+			continue
+		}
+		counts = append(counts, TileCount{Z: k.z, X: k.x, Y: k.y, Hits: n})
+	}
+
+	return sortTop(counts, n), nil
+}
+
+func (fb *fileBackend) close() error { return nil }
@@ -0,0 +1,64 @@
+package tilestats
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileBackendCompact(t *testing.T) {
+	ctx := context.Background()
+
+	fb := &fileBackend{path: filepath.Join(t.TempDir(), "tiles.bin")}
+
+	osm := tileKey{mapName: "osm", z: 1}
+	for _, counts := range []map[tileKey]uint64{
+		{osm: 2},
+		{osm: 3, {mapName: "osm", z: 2, x: 1}: 1},
+	} {
+		if err := fb.merge(ctx, counts); err != nil {
+			t.Fatalf("merge, expected nil got %v", err)
+		}
+	}
+
+	expected := []TileCount{
+		{Z: 1, Hits: 5},
+		{Z: 2, X: 1, Hits: 1},
+	}
+
+	appended, err := os.Stat(fb.path)
+	if err != nil {
+		t.Fatalf("stat, expected nil got %v", err)
+	}
+	got, err := fb.top(ctx, "osm", 0, 0)
+	if err != nil {
+		t.Fatalf("top, expected nil got %v", err)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("top of the appended counts, expected %v got %v", expected, got)
+	}
+
+	if err = fb.compact(); err != nil {
+		t.Fatalf("compact, expected nil got %v", err)
+	}
+	compacted, err := os.Stat(fb.path)
+	if err != nil {
+		t.Fatalf("stat, expected nil got %v", err)
+	}
+	if compacted.Size() >= appended.Size() {
+		t.Errorf("size, expected less than %v got %v", appended.Size(), compacted.Size())
+	}
+	if fb.compactedSize != compacted.Size() {
+		t.Errorf("compacted size, expected %v got %v", compacted.Size(), fb.compactedSize)
+	}
+
+	got, err = fb.top(ctx, "osm", 0, 0)
+	if err != nil {
+		t.Fatalf("top, expected nil got %v", err)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("top of the compacted counts, expected %v got %v", expected, got)
+	}
+}
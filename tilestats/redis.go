@@ -0,0 +1,103 @@
+package tilestats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/go-spatial/tegola/dict"
+)
+
+const (
+	ConfigKeyURI       = "uri"
+	ConfigKeyKeyPrefix = "key_prefix"
+)
+
+var defaultKeyPrefix = "tegola:tile_stats"
+
+var ErrMissingURI = errors.New("tilestats: missing required param 'uri'")
+
+// redisBackend stores the counts of each map in a sorted set, keyed by the map
+// name, whose members are z/x/y tile names scored by their hits
+type redisBackend struct {
+	client *redis.Client
+	prefix string
+}
+
+func newRedisBackend(config dict.Dicter) (*redisBackend, error) {
+	uri, err := config.String(ConfigKeyURI, nil)
+	if err != nil || uri == "" {
+		return nil, ErrMissingURI
+	}
+
+	prefix, err := config.String(ConfigKeyKeyPrefix, &defaultKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := redis.ParseURL(uri)
+	if err != nil {
+		return nil, fmt.Errorf("tilestats: invalid uri (%v): %w", uri, err)
+	}
+
+	client := redis.NewClient(opts)
+	if err = client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("tilestats: connecting to %v: %w", opts.Addr, err)
+	}
+
+	return &redisBackend{client: client, prefix: prefix}, nil
+}
+
+func (rb *redisBackend) key(mapName string) string {
+	return rb.prefix + ":" + mapName
+}
+
+func (rb *redisBackend) merge(ctx context.Context, counts map[tileKey]uint64) error {
+	_, err := rb.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for k, hits := range counts {
+			pipe.ZIncrBy(ctx, rb.key(k.mapName), float64(hits), fmt.Sprintf("%v/%v/%v", k.z, k.x, k.y))
+		}
+		return nil
+	})
+	return err
+}
+
+func (rb *redisBackend) top(ctx context.Context, mapName string, n int, minHits uint64) ([]TileCount, error) {
+	args := redis.ZRangeArgs{
+		Key:     rb.key(mapName),
+		Start:   strconv.FormatUint(minHits, 10),
+		Stop:    "+inf",
+		ByScore: true,
+		Rev:     true,
+	}
+	if n > 0 {
+		args.Count = int64(n)
+	}
+
+	members, err := rb.client.ZRangeArgsWithScores(ctx, args).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]TileCount, 0, len(members))
+	for _, m := range members {
+		var tc TileCount
+		name, _ := m.Member.(string)
+		if _, err := fmt.Sscanf(name, "%d/%d/%d", &tc.Z, &tc.X, &tc.Y); err != nil {
+			return nil, fmt.Errorf("tilestats: invalid tile (%v) in %v", name, rb.key(mapName))
+		}
+		tc.Hits = uint64(m.Score)
+		counts = append(counts, tc)
+	}
+
+	// members with the same score are ordered by name, sort them like the file backend
+	return sortTop(counts, 0), nil
+}
+
+func (rb *redisBackend) close() error {
+	return rb.client.Close()
+}
@@ -0,0 +1,257 @@
+// Package tilestats records how many times each tile is requested, so the cache
+// can be seeded with the tiles which are requested the most.
+package tilestats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/internal/log"
+)
+
+const (
+	ConfigKeyType             = "type"
+	ConfigKeyFlushInterval    = "flush_interval"
+	ConfigKeyMaxBufferedTiles = "max_buffered_tiles"
+)
+
+// store types
+const (
+	TypeFile  = "file"
+	TypeRedis = "redis"
+)
+
+var (
+	defaultFlushInterval    = 60
+	defaultMaxBufferedTiles = 100000
+)
+
+var ErrMissingType = errors.New("tilestats: missing required param 'type'")
+
+// ErrUnknownType is returned when the configured type is not a known store type
+type ErrUnknownType string
+
+func (e ErrUnknownType) Error() string {
+	return fmt.Sprintf("tilestats: unknown type (%v). expected %q or %q", string(e), TypeFile, TypeRedis)
+}
+
+// TileCount is the number of requests of a tile
+type TileCount struct {
+	Z, X, Y uint
+	Hits    uint64
+}
+
+// Store records tile requests and reports the most requested tiles
+type Store interface {
+	// Record counts a request of a tile. Counts are buffered in memory until the next flush,
+	// which starts early once the buffer is full. Requests of tiles which are not buffered
+	// are dropped while the buffer is full.
+	Record(mapName string, z, x, y uint)
+	// Flush persists the buffered counts
+	Flush(ctx context.Context) error
+	// Top returns the tiles of mapName requested at least minHits times, the most
+	// requested first. n <= 0 returns all of them.
+	Top(ctx context.Context, mapName string, n int, minHits uint64) ([]TileCount, error)
+	// Close flushes the buffered counts and releases the store
+	Close() error
+}
+
+// tileKey identifies a tile of a map in the buffered counts
+type tileKey struct {
+	mapName string
+	z, x, y uint
+}
+
+// backend persists the counts of a store
+type backend interface {
+	// merge adds counts to the persisted counts
+	merge(ctx context.Context, counts map[tileKey]uint64) error
+	top(ctx context.Context, mapName string, n int, minHits uint64) ([]TileCount, error)
+	close() error
+}
+
+// New instantiates a Store. The config expects the following params:
+//
+//	type (string): the store type, "file" or "redis"
+//	flush_interval (int): number of seconds between flushes of the buffered counts. defaults to 60
+//	max_buffered_tiles (int): max number of tiles buffered between flushes. defaults to 100000
+//
+// along with the params of the store type.
+func New(config dict.Dicter) (Store, error) {
+	typ, err := config.String(ConfigKeyType, nil)
+	if err != nil {
+		return nil, ErrMissingType
+	}
+
+	var b backend
+	switch typ {
+	case TypeFile:
+		b, err = newFileBackend(config)
+	case TypeRedis:
+		b, err = newRedisBackend(config)
+	default:
+		return nil, ErrUnknownType(typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	flushInterval, err := config.Int(ConfigKeyFlushInterval, &defaultFlushInterval)
+	if err != nil {
+		return nil, err
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+
+	maxTiles, err := config.Int(ConfigKeyMaxBufferedTiles, &defaultMaxBufferedTiles)
+	if err != nil {
+		return nil, err
+	}
+	if maxTiles <= 0 {
+		maxTiles = defaultMaxBufferedTiles
+	}
+
+	s := &store{
+		backend:  b,
+		counts:   map[tileKey]uint64{},
+		maxTiles: maxTiles,
+		full:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.flusher(time.Duration(flushInterval) * time.Second)
+
+	return s, nil
+}
+
+// store buffers the counts in memory and periodically merges them into its backend
+type store struct {
+	backend backend
+
+	mu     sync.Mutex
+	counts map[tileKey]uint64
+	// maxTiles bounds the number of buffered tiles, so a crawler requesting distinct
+	// tiles can't exhaust the memory between flushes
+	maxTiles int
+	// dropped is the number of requests dropped since the last flush
+	dropped uint64
+
+	// full is signaled when the buffer is full, to flush it early
+	full      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func (s *store) Record(mapName string, z, x, y uint) {
+	k := tileKey{mapName: mapName, z: z, x: x, y: y}
+
+	s.mu.Lock()
+	_, ok := s.counts[k]
+	full := !ok && len(s.counts) >= s.maxTiles
+	if full {
+		s.dropped++
+	} else {
+		s.counts[k]++
+	}
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *store) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	counts, dropped := s.counts, s.dropped
+	s.counts, s.dropped = map[tileKey]uint64{}, 0
+	s.mu.Unlock()
+
+	if dropped > 0 {
+		log.Warnf("tilestats: dropped %v tile requests while the buffer of %v tiles was full", dropped, s.maxTiles)
+	}
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	if err := s.backend.merge(ctx, counts); err != nil {
+		// put the counts back for the next flush, within the bounds of the buffer
+		s.mu.Lock()
+		for k, n := range counts {
+			if _, ok := s.counts[k]; ok || len(s.counts) < s.maxTiles {
+				s.counts[k] += n
+			}
+		}
+		s.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (s *store) Top(ctx context.Context, mapName string, n int, minHits uint64) ([]TileCount, error) {
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return s.backend.top(ctx, mapName, n, minHits)
+}
+
+func (s *store) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	s.wg.Wait()
+
+	return errors.Join(s.Flush(context.Background()), s.backend.close())
+}
+
+func (s *store) flusher(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		case <-s.full:
+		}
+
+		if err := s.Flush(context.Background()); err != nil {
+			log.Errorf("tilestats: flushing the tile counts: %v", err)
+		}
+	}
+}
+
+// sortTop sorts the counts, the most requested first, and keeps the n first ones
+func sortTop(counts []TileCount, n int) []TileCount {
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.Hits != b.Hits {
+			return a.Hits > b.Hits
+		}
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Y < b.Y
+	})
+
+	if n > 0 && len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
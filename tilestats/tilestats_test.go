@@ -0,0 +1,215 @@
+package tilestats_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/go-spatial/tegola/dict"
+	"github.com/go-spatial/tegola/tilestats"
+)
+
+func TestNew(t *testing.T) {
+	type tcase struct {
+		config dict.Dict
+		err    error
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			s, err := tilestats.New(tc.config)
+			if tc.err != nil {
+				if err == nil || err.Error() != tc.err.Error() {
+					t.Errorf("error, expected %v got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error, expected nil got %v", err)
+			}
+			s.Close()
+		}
+	}
+
+	tests := map[string]tcase{
+		"missing type": {
+			config: dict.Dict{},
+			err:    tilestats.ErrMissingType,
+		},
+		"unknown type": {
+			config: dict.Dict{"type": "postgis"},
+			err:    tilestats.ErrUnknownType("postgis"),
+		},
+		"file missing path": {
+			config: dict.Dict{"type": "file"},
+			err:    tilestats.ErrMissingPath,
+		},
+		"redis missing uri": {
+			config: dict.Dict{"type": "redis"},
+			err:    tilestats.ErrMissingURI,
+		},
+		"file": {
+			config: dict.Dict{"type": "file", "path": filepath.Join(t.TempDir(), "stats")},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestTop(t *testing.T) {
+	type tcase struct {
+		config func(t *testing.T) dict.Dict
+	}
+
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			ctx := context.Background()
+			config := tc.config(t)
+
+			s, err := tilestats.New(config)
+			if err != nil {
+				t.Fatalf("new, expected nil got %v", err)
+			}
+
+			record := func(s tilestats.Store, mapName string, z, x, y uint, hits int) {
+				for i := 0; i < hits; i++ {
+					s.Record(mapName, z, x, y)
+				}
+			}
+
+			record(s, "osm", 1, 0, 0, 5)
+			record(s, "osm", 2, 1, 1, 3)
+			record(s, "osm", 2, 1, 2, 1)
+			record(s, "other", 2, 1, 2, 10)
+			if err = s.Flush(ctx); err != nil {
+				t.Fatalf("flush, expected nil got %v", err)
+			}
+			// counts are added to the flushed counts
+			record(s, "osm", 2, 1, 2, 3)
+			if err = s.Close(); err != nil {
+				t.Fatalf("close, expected nil got %v", err)
+			}
+
+			// a new store reads the counts of the previous one
+			if s, err = tilestats.New(config); err != nil {
+				t.Fatalf("new, expected nil got %v", err)
+			}
+			defer s.Close()
+
+			tests := []struct {
+				n        int
+				minHits  uint64
+				expected []tilestats.TileCount
+			}{
+				{
+					expected: []tilestats.TileCount{
+						{Z: 1, X: 0, Y: 0, Hits: 5},
+						{Z: 2, X: 1, Y: 2, Hits: 4},
+						{Z: 2, X: 1, Y: 1, Hits: 3},
+					},
+				},
+				{
+					n: 1,
+					expected: []tilestats.TileCount{
+						{Z: 1, X: 0, Y: 0, Hits: 5},
+					},
+				},
+				{
+					minHits: 4,
+					expected: []tilestats.TileCount{
+						{Z: 1, X: 0, Y: 0, Hits: 5},
+						{Z: 2, X: 1, Y: 2, Hits: 4},
+					},
+				},
+			}
+
+			for _, test := range tests {
+				got, err := s.Top(ctx, "osm", test.n, test.minHits)
+				if err != nil {
+					t.Fatalf("top, expected nil got %v", err)
+				}
+				if !reflect.DeepEqual(test.expected, got) {
+					t.Errorf("top n=%v min hits=%v, expected %v got %v", test.n, test.minHits, test.expected, got)
+				}
+			}
+
+			got, err := s.Top(ctx, "missing", 0, 0)
+			if err != nil || len(got) != 0 {
+				t.Errorf("top of missing map, expected no tiles got %v, %v", got, err)
+			}
+		}
+	}
+
+	tests := map[string]tcase{
+		"file": {
+			config: func(t *testing.T) dict.Dict {
+				return dict.Dict{"type": "file", "path": filepath.Join(t.TempDir(), "stats", "tiles.bin")}
+			},
+		},
+		"redis": {
+			config: func(t *testing.T) dict.Dict {
+				return dict.Dict{"type": "redis", "uri": "redis://" + miniredis.RunT(t).Addr()}
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestMaxBufferedTiles(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := tilestats.New(dict.Dict{
+		"type":               "file",
+		"path":               filepath.Join(t.TempDir(), "tiles.bin"),
+		"flush_interval":     3600,
+		"max_buffered_tiles": 2,
+	})
+	if err != nil {
+		t.Fatalf("new, expected nil got %v", err)
+	}
+	defer s.Close()
+
+	s.Record("osm", 1, 0, 0)
+	s.Record("osm", 1, 0, 1)
+	// the buffer is full, the requests of buffered tiles are still counted
+	s.Record("osm", 1, 1, 0)
+	s.Record("osm", 1, 0, 0)
+
+	got, err := s.Top(ctx, "osm", 0, 0)
+	if err != nil {
+		t.Fatalf("top, expected nil got %v", err)
+	}
+	expected := []tilestats.TileCount{
+		{Z: 1, X: 0, Y: 0, Hits: 2},
+		{Z: 1, X: 0, Y: 1, Hits: 1},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("top, expected %v got %v", expected, got)
+	}
+}
+
+func TestCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tiles.bin")
+	if err := os.WriteFile(path, []byte("not a stats file"), 0666); err != nil {
+		t.Fatalf("write file, expected nil got %v", err)
+	}
+
+	s, err := tilestats.New(dict.Dict{"type": "file", "path": path})
+	if err != nil {
+		t.Fatalf("new, expected nil got %v", err)
+	}
+	defer s.Close()
+
+	if _, err = s.Top(context.Background(), "osm", 0, 0); err == nil {
+		t.Errorf("top, expected error got nil")
+	}
+}